@@ -0,0 +1,304 @@
+// Package anomaly learns what a device's metrics usually are at each hour
+// of the day, and flags readings far from it: "vs_app1 connection rate is
+// 5x its usual for this hour". Each virtual server's connection rate and
+// the device's CPU usage have a rolling baseline, a mean and variance
+// weighted towards recent readings, per hour; a reading is unusual when
+// its z-score against that hour's baseline passes a threshold. Baselines
+// are kept in a file of the user's, so they carry over between sessions.
+package anomaly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/notify"
+)
+
+// The metrics with baselines, as named in alert keys and metric labels
+const (
+	ConnectionRate = "connection_rate"
+	CPU            = "cpu_usage"
+)
+
+// metricNames are the metrics as messages name them
+var metricNames = map[string]string{
+	ConnectionRate: "connection rate",
+	CPU:            "CPU usage",
+}
+
+const (
+	// window is roughly how many readings a baseline remembers: two days
+	// of an hour read every 30 seconds. Older readings weigh less and less.
+	window = 240
+	// minReadings is how many readings of an hour a baseline needs before
+	// it flags anything
+	minReadings = 20
+	// minRateInterval is the shortest time between readings of the
+	// connection counters that a rate is worked out over
+	minRateInterval = time.Second
+)
+
+// minSpread is the smallest standard deviation a metric's baseline is
+// taken to have, so that a metric that barely moves, such as an idle
+// virtual server at 0 connections a second, isn't flagged for a blip. A
+// tenth of the mean is the floor otherwise.
+var minSpread = map[string]float64{
+	ConnectionRate: 1,
+	CPU:            2,
+}
+
+// Device is what the detector reads from a BIG-IP; the client and the
+// mock both are one
+type Device interface {
+	GetStats(kind string) (map[string]bigip.ObjectStats, error)
+	GetCPUUsage() (float64, error)
+}
+
+var (
+	_ Device = (*bigip.Client)(nil)
+	_ Device = (*bigip.MockClient)(nil)
+)
+
+// Anomaly is a reading far from its baseline
+type Anomaly struct {
+	Time time.Time
+	// Metric is ConnectionRate or CPU, and Object the virtual server's
+	// full path, "" for the device's CPU
+	Metric string
+	Object string
+	// Value is the reading, Usual the baseline's mean for the hour and Z
+	// how many standard deviations apart they are
+	Value float64
+	Usual float64
+	Z     float64
+}
+
+// String says what is unusual: "vs_app1 connection rate is 5x its usual
+// for this hour (120.0/s against 24.0/s)"
+func (a Anomaly) String() string {
+	subject := metricNames[a.Metric]
+	if a.Object != "" {
+		subject = a.Object[strings.LastIndex(a.Object, "/")+1:] + " " + subject
+	}
+	amounts := fmt.Sprintf("(%s against %s)", a.format(a.Value), a.format(a.Usual))
+	switch {
+	case a.Usual > 0 && a.Value >= 2*a.Usual:
+		return fmt.Sprintf("%s is %sx its usual for this hour %s", subject, ratio(a.Value/a.Usual), amounts)
+	case a.Usual > 0 && a.Value <= a.Usual/2:
+		return fmt.Sprintf("%s is %d%% of its usual for this hour %s", subject, int(math.Round(100*a.Value/a.Usual)), amounts)
+	case a.Value > a.Usual:
+		return fmt.Sprintf("%s is unusually high for this hour %s", subject, amounts)
+	}
+	return fmt.Sprintf("%s is unusually low for this hour %s", subject, amounts)
+}
+
+// format shows a reading in the metric's unit
+func (a Anomaly) format(v float64) string {
+	if a.Metric == CPU {
+		return fmt.Sprintf("%.0f%%", v)
+	}
+	return fmt.Sprintf("%.1f/s", v)
+}
+
+// ratio is "5" or "2.5"
+func ratio(r float64) string {
+	if r >= 10 {
+		return strconv.Itoa(int(math.Round(r)))
+	}
+	return strconv.FormatFloat(math.Round(r*10)/10, 'f', -1, 64)
+}
+
+// Key identifies the anomaly for notifications, so a lasting one is sent
+// once per deduplication window
+func (a Anomaly) Key() string {
+	if a.Object == "" {
+		return "anomaly:" + a.Metric
+	}
+	return "anomaly:" + a.Metric + ":" + a.Object
+}
+
+// Event is the warning sent about the anomaly; the sender fills in the
+// source, device and time
+func (a Anomaly) Event() notify.Event {
+	fields := map[string]string{
+		"metric": a.Metric,
+		"value":  a.format(a.Value),
+		"usual":  a.format(a.Usual),
+		"z":      strconv.FormatFloat(math.Round(a.Z*10)/10, 'f', -1, 64),
+	}
+	if a.Object != "" {
+		fields["virtual_server"] = a.Object
+	}
+	return notify.Event{
+		Key:      a.Key(),
+		Severity: notify.SeverityWarning,
+		Title:    "Unusual " + metricNames[a.Metric],
+		Message:  a.String(),
+		Fields:   fields,
+	}
+}
+
+// baseline is a metric's rolling mean and variance at one hour of the day
+type baseline struct {
+	N    int     `json:"n"`
+	Mean float64 `json:"mean"`
+	Var  float64 `json:"var"`
+}
+
+// add takes a reading into the baseline, weighing it as one of the last
+// window readings once there are that many
+func (b *baseline) add(v float64) {
+	b.N++
+	w := 1 / float64(min(b.N, window))
+	d := v - b.Mean
+	b.Mean += w * d
+	b.Var = (1 - w) * (b.Var + w*d*d)
+}
+
+// counter is a virtual server's total connections when last read
+type counter struct {
+	total int64
+	at    time.Time
+}
+
+// Detector keeps the baselines of every device it has checked. Threshold
+// is the z-score past which a reading is unusual.
+type Detector struct {
+	mu        sync.Mutex
+	file      string
+	threshold float64
+	// baselines are by device, then by metric, object and hour
+	baselines map[string]map[string]*baseline
+	// counters are by device, then by virtual server
+	counters map[string]map[string]counter
+}
+
+// Open loads the baselines from file. An empty file name keeps them in
+// memory only; a missing file starts with none.
+func Open(file string, threshold float64) (*Detector, error) {
+	if threshold <= 0 {
+		return nil, fmt.Errorf("the threshold must be positive, not %g", threshold)
+	}
+	d := &Detector{file: file, threshold: threshold, baselines: map[string]map[string]*baseline{}, counters: map[string]map[string]counter{}}
+	if file == "" {
+		return d, nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &d.baselines); err != nil {
+		return nil, fmt.Errorf("unreadable baselines in %s: %w", file, err)
+	}
+	return d, nil
+}
+
+// Check reads the device's connection counters and CPU usage, compares
+// them with the baselines for the hour, then adds them to the baselines.
+// It returns the unusual readings, largest z-score first. A metric that
+// couldn't be read is reported in the error, and the others are still
+// checked. The first check of a virtual server has no rate to compare; its
+// counter is kept for the next.
+func (d *Detector) Check(name string, device Device, now time.Time) ([]Anomaly, error) {
+	type reading struct {
+		metric, object string
+		value          float64
+	}
+	var readings []reading
+	var errs []error
+
+	stats, err := device.GetStats("virtual")
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cpu, err := device.GetCPUUsage()
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		readings = append(readings, reading{CPU, "", cpu})
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	counters := d.counters[name]
+	if counters == nil {
+		counters = map[string]counter{}
+		d.counters[name] = counters
+	}
+	for vs, s := range stats {
+		last, seen := counters[vs]
+		elapsed := now.Sub(last.at)
+		if seen && elapsed < minRateInterval {
+			continue
+		}
+		counters[vs] = counter{s.TotalConnections, now}
+		// A counter that went down was reset, by a reboot or a reload
+		if seen && s.TotalConnections >= last.total {
+			readings = append(readings, reading{ConnectionRate, vs, float64(s.TotalConnections-last.total) / elapsed.Seconds()})
+		}
+	}
+	// Virtual servers deleted from the device are forgotten
+	if stats != nil {
+		for vs := range counters {
+			if _, ok := stats[vs]; !ok {
+				delete(counters, vs)
+			}
+		}
+	}
+
+	baselines := d.baselines[name]
+	if baselines == nil {
+		baselines = map[string]*baseline{}
+		d.baselines[name] = baselines
+	}
+	var found []Anomaly
+	for _, r := range readings {
+		key := fmt.Sprintf("%s|%s|%02d", r.metric, r.object, now.Hour())
+		b := baselines[key]
+		if b == nil {
+			b = &baseline{}
+			baselines[key] = b
+		}
+		if b.N >= minReadings {
+			spread := max(math.Sqrt(b.Var), math.Abs(b.Mean)/10, minSpread[r.metric])
+			if z := (r.value - b.Mean) / spread; math.Abs(z) >= d.threshold {
+				found = append(found, Anomaly{Time: now, Metric: r.metric, Object: r.object, Value: r.value, Usual: b.Mean, Z: z})
+			}
+		}
+		b.add(r.value)
+	}
+	sort.SliceStable(found, func(a, b int) bool { return math.Abs(found[a].Z) > math.Abs(found[b].Z) })
+
+	if err := d.write(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to save the baselines: %w", err))
+	}
+	return found, errors.Join(errs...)
+}
+
+// write saves the baselines to the file; callers hold d.mu
+func (d *Detector) write() error {
+	if d.file == "" {
+		return nil
+	}
+	data, err := json.Marshal(d.baselines)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(d.file, append(data, '\n'), 0o600)
+}
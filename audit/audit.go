@@ -0,0 +1,129 @@
+// Package audit keeps an append-only log of the queries asked of the
+// BIG-IP, one JSON object per line: who asked, when, what the query
+// resolved to, the iControl REST calls made to answer it and whether any of
+// them changed the device.
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"f5chat/bigip"
+)
+
+// Entry is a query's line in the audit log
+type Entry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Device string    `json:"device,omitempty"`
+	Query  string    `json:"query"`
+	// Intent is the operations the query resolved to, none for commands
+	// such as /partition
+	Intent []Intent     `json:"intent"`
+	Calls  []bigip.Call `json:"calls"`
+	// Modified is whether any of the calls could have changed the device
+	Modified bool   `json:"modified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Intent is an operation a query resolved to, with its arguments and the
+// device it ran on when that isn't the session's
+type Intent struct {
+	Operation string            `json:"operation"`
+	Args      map[string]string `json:"args,omitempty"`
+	Device    string            `json:"device,omitempty"`
+}
+
+// Log is an audit log file, only ever appended to
+type Log struct {
+	mu      sync.Mutex
+	file    *os.File
+	user    string
+	forward func(Entry)
+}
+
+// Open opens the audit log at path for appending, creating it readable only
+// by its owner if need be. user names who asks the queries; "" is the login
+// user.
+func Open(path, user string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if user == "" {
+		user = LoginUser()
+	}
+	return &Log{file: f, user: user}, nil
+}
+
+// LoginUser is the name of the user chatf5 runs as, who entries name by
+// default here and in the change journal
+func LoginUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// Forward has each entry passed to to once it is written, such as to send
+// it on to a SIEM
+func (l *Log) Forward(to func(Entry)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.forward = to
+}
+
+// Record appends an entry, stamped with the time and the log's user unless
+// it gives them, and marked modified if any of its calls could have changed
+// the device. Each entry is synced to disk before Record returns, so the log
+// keeps what was done even if chatf5 doesn't exit cleanly, and only then
+// forwarded.
+func (l *Log) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.User == "" {
+		e.User = l.user
+	}
+	if e.Intent == nil {
+		e.Intent = []Intent{}
+	}
+	if e.Calls == nil {
+		e.Calls = []bigip.Call{}
+	}
+	for _, call := range e.Calls {
+		e.Modified = e.Modified || call.Modifies()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	forward := l.forward
+	_, err = l.file.Write(append(data, '\n'))
+	if err == nil {
+		err = l.file.Sync()
+	}
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if forward != nil {
+		forward(e)
+	}
+	return nil
+}
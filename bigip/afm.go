@@ -0,0 +1,204 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// FirewallList is an AFM address list or port list, which firewall rules
+// match traffic against
+type FirewallList struct {
+	// Kind is "address" or "port"
+	Kind        string
+	Name        string
+	Partition   string
+	FullPath    string
+	Description string
+	// Entries are what the list holds. An address list's are addresses,
+	// networks and ranges ("10.0.0.0/8", "10.1.1.1-10.1.1.9"), and
+	// "fqdn www.example.com", "geo CN" and "address-list /Common/other"
+	// for its FQDNs, countries and nested lists; a port list's are ports
+	// and ranges ("443", "8000-8080") and "port-list /Common/other".
+	Entries []string
+}
+
+// firewallListItem is a list as iControl REST gives it, one array per kind
+// of entry
+type firewallListItem struct {
+	Name         string          `json:"name"`
+	Partition    string          `json:"partition"`
+	FullPath     string          `json:"fullPath"`
+	Description  string          `json:"description"`
+	Addresses    []firewallEntry `json:"addresses"`
+	FQDNs        []firewallEntry `json:"fqdns"`
+	Geo          []firewallEntry `json:"geo"`
+	AddressLists []firewallEntry `json:"addressLists"`
+	Ports        []firewallEntry `json:"ports"`
+	PortLists    []firewallEntry `json:"portLists"`
+}
+
+type firewallEntry struct {
+	Name      string `json:"name"`
+	Partition string `json:"partition,omitempty"`
+}
+
+// firewallFields are the arrays of a list and the prefix their entries are
+// shown with; the first of a kind has none
+var firewallFields = map[string][]struct{ field, prefix string }{
+	"address": {{"addresses", ""}, {"fqdns", "fqdn "}, {"geo", "geo "}, {"addressLists", "address-list "}},
+	"port":    {{"ports", ""}, {"portLists", "port-list "}},
+}
+
+// entries returns the item's arrays by field name
+func (item *firewallListItem) entries() map[string][]firewallEntry {
+	return map[string][]firewallEntry{
+		"addresses": item.Addresses, "fqdns": item.FQDNs, "geo": item.Geo, "addressLists": item.AddressLists,
+		"ports": item.Ports, "portLists": item.PortLists,
+	}
+}
+
+// list converts the item into a FirewallList of the kind given
+func (item *firewallListItem) list(kind string) FirewallList {
+	l := FirewallList{Kind: kind, Name: item.Name, Partition: item.Partition, FullPath: item.FullPath, Description: item.Description}
+	arrays := item.entries()
+	for _, f := range firewallFields[kind] {
+		for _, e := range arrays[f.field] {
+			name := e.Name
+			if e.Partition != "" && !strings.HasPrefix(name, "/") {
+				name = "/" + e.Partition + "/" + name
+			}
+			l.Entries = append(l.Entries, f.prefix+name)
+		}
+	}
+	return l
+}
+
+// firewallEndpoint is the collection of lists of a kind, "address" or "port"
+func firewallEndpoint(kind string) (string, error) {
+	if _, ok := firewallFields[kind]; !ok {
+		return "", fmt.Errorf("invalid firewall list type %q", kind)
+	}
+	return "/mgmt/tm/security/firewall/" + kind + "-list", nil
+}
+
+// GetFirewallLists retrieves the AFM lists of a kind, "address" or "port".
+// Without AFM provisioned it returns a ModuleNotProvisionedError.
+func (c *Client) GetFirewallLists(kind string) ([]FirewallList, error) {
+	endpoint, err := firewallEndpoint(kind)
+	if err != nil {
+		return nil, err
+	}
+	return cached(c, endpoint, func() ([]FirewallList, error) {
+		slog.Debug("Fetching firewall lists", "endpoint", endpoint)
+		var collection struct {
+			Items []firewallListItem `json:"items"`
+		}
+		if err := c.getCollection("GetFirewallLists", endpoint, "", &collection); err != nil {
+			return nil, fmt.Errorf("failed to get %s lists: %w", kind, err)
+		}
+		lists := make([]FirewallList, len(collection.Items))
+		for n := range collection.Items {
+			lists[n] = collection.Items[n].list(kind)
+		}
+		slog.Info("Fetched firewall lists", "type", kind, "count", len(lists))
+		return lists, nil
+	})
+}
+
+// AppendToFirewallList adds entries, in the form FirewallList.Entries holds
+// them, to the list of a kind at fullPath. The list is read afresh and
+// written back whole, each kind of entry being one array; entries it
+// already holds are skipped. It is not retried: a change that timed out may
+// still have been applied.
+func (c *Client) AppendToFirewallList(kind, fullPath string, entries []string) error {
+	collection, err := firewallEndpoint(kind)
+	if err != nil {
+		return err
+	}
+	if err := c.Connect(); err != nil {
+		return err
+	}
+	endpoint := collection + "/" + objectPath(fullPath)
+	slog.Info("Appending to firewall list", "endpoint", endpoint, "entries", len(entries))
+
+	// The arrays are also kept undecoded so that existing entries are written
+	// back exactly as read, fields this package doesn't know included
+	var current firewallListItem
+	var raw map[string]json.RawMessage
+	err = c.withRetry("GetFirewallList", func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{
+			Method:      "GET",
+			URL:         strings.TrimPrefix(endpoint, "/"),
+			ContentType: "application/json",
+		})
+		if err != nil {
+			return newAPIError(endpoint, resp, err)
+		}
+		if err := json.Unmarshal(resp, &current); err != nil {
+			return err
+		}
+		return json.Unmarshal(resp, &raw)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read %s list %s: %w", kind, fullPath, err)
+	}
+
+	have := map[string]bool{}
+	for _, e := range current.list(kind).Entries {
+		have[e] = true
+	}
+	changed := map[string][]json.RawMessage{}
+	for _, entry := range entries {
+		if have[entry] {
+			continue
+		}
+		have[entry] = true
+		field, name := firewallFields[kind][0].field, entry
+		for _, f := range firewallFields[kind][1:] {
+			if rest, ok := strings.CutPrefix(entry, f.prefix); ok {
+				field, name = f.field, rest
+			}
+		}
+		if _, ok := changed[field]; !ok {
+			var array []json.RawMessage
+			if existing, ok := raw[field]; ok {
+				if err := json.Unmarshal(existing, &array); err != nil {
+					return fmt.Errorf("failed to read %s list %s: %w", kind, fullPath, err)
+				}
+			}
+			changed[field] = array
+		}
+		added, err := json.Marshal(firewallEntry{Name: name})
+		if err != nil {
+			return err
+		}
+		changed[field] = append(changed[field], added)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(changed)
+	if err != nil {
+		return err
+	}
+
+	err = c.attempt("AppendToFirewallList", func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{
+			Method:      "PATCH",
+			URL:         strings.TrimPrefix(endpoint, "/"),
+			Body:        string(body),
+			ContentType: "application/json",
+		})
+		return newAPIError(endpoint, resp, err)
+	})()
+	if err != nil {
+		return fmt.Errorf("failed to update %s list %s: %w", kind, fullPath, err)
+	}
+	// Listings of the lists must show the new entries
+	c.ClearCache()
+	return nil
+}
@@ -0,0 +1,224 @@
+package bigip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// as3PollInterval is how often a deployment task is checked, and
+// as3DeployTimeout how long to wait for it before giving up
+var (
+	as3PollInterval  = 2 * time.Second
+	as3DeployTimeout = 3 * time.Minute
+)
+
+// AS3Result is the outcome of a declaration for one tenant
+type AS3Result struct {
+	Tenant  string `json:"tenant"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// as3Task is the body of an async AS3 task
+type as3Task struct {
+	ID      string      `json:"id"`
+	Results []AS3Result `json:"results"`
+	Errors  []string    `json:"errors"`
+}
+
+// AS3TimeoutError is returned when AS3 is still applying a declaration when
+// DeployAS3 stops waiting. The task goes on, so the declaration may yet be
+// applied; its progress is at Endpoint.
+type AS3TimeoutError struct {
+	TaskID   string
+	Endpoint string
+	After    time.Duration
+}
+
+func (e *AS3TimeoutError) Error() string {
+	return fmt.Sprintf("AS3 task %s still in progress after %s; check %s on the device", e.TaskID, e.After, e.Endpoint)
+}
+
+// AS3Tenants checks that declaration is an AS3 or ADC declaration and
+// returns the tenants it declares, sorted
+func AS3Tenants(declaration string) ([]string, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(declaration), &doc); err != nil {
+		return nil, fmt.Errorf("declaration is not valid JSON: %v", err)
+	}
+	if doc["class"] == "AS3" {
+		adc, ok := doc["declaration"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("AS3 request has no declaration")
+		}
+		doc = adc
+	}
+	if doc["class"] != "ADC" {
+		return nil, fmt.Errorf(`declaration class must be "AS3" or "ADC", got %v`, doc["class"])
+	}
+	if _, ok := doc["schemaVersion"].(string); !ok {
+		return nil, fmt.Errorf("declaration has no schemaVersion")
+	}
+
+	var tenants []string
+	for name, value := range doc {
+		if obj, ok := value.(map[string]interface{}); ok && obj["class"] == "Tenant" {
+			tenants = append(tenants, name)
+		}
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("declaration has no tenants")
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// TenantExists reports whether a partition, and so possibly an AS3
+// tenant, with this name is already on the device
+func (c *Client) TenantExists(name string) (bool, error) {
+	endpoint := "/mgmt/tm/auth/partition/" + url.PathEscape(name)
+	err := c.withRetry("TenantExists", func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{Method: "GET", URL: strings.TrimPrefix(endpoint, "/"), ContentType: "application/json"})
+		return newAPIError(endpoint, resp, err)
+	})
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check tenant '%s': %w", name, err)
+	}
+	return true, nil
+}
+
+// GetDeclaration returns the declaration AS3 manages for a tenant, "" if it
+// manages none or AS3 isn't installed
+func (c *Client) GetDeclaration(tenant string) (string, error) {
+	endpoint := "/mgmt/shared/appsvcs/declare/" + url.PathEscape(tenant)
+	var declaration string
+	err := c.withRetry("GetDeclaration", func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{Method: "GET", URL: strings.TrimPrefix(endpoint, "/"), ContentType: "application/json"})
+		if err != nil {
+			return newAPIError(endpoint, resp, err)
+		}
+		declaration = strings.TrimSpace(string(resp))
+		return nil
+	})
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get the AS3 declaration of tenant '%s': %w", tenant, err)
+	}
+	return declaration, nil
+}
+
+// DeployAS3 posts a declaration to the AS3 extension and waits for it to be
+// applied. Like other changes it is not retried: a POST that timed out may
+// still be running on the device. On error the results, if any, are how far
+// AS3 got with each tenant.
+func (c *Client) DeployAS3(declaration string) ([]AS3Result, error) {
+	tenants, err := AS3Tenants(declaration)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Connect(); err != nil {
+		return nil, err
+	}
+	const endpoint = "/mgmt/shared/appsvcs/declare"
+	slog.Info("Deploying AS3 declaration", "endpoint", endpoint, "tenants", tenants, "bytes", len(declaration))
+
+	var task as3Task
+	err = c.attempt("DeployAS3", func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{
+			Method:      "POST",
+			URL:         "mgmt/shared/appsvcs/declare?async=true",
+			Body:        declaration,
+			ContentType: "application/json",
+		})
+		if err != nil {
+			return newAPIError(endpoint, resp, err)
+		}
+		return json.Unmarshal(resp, &task)
+	})()
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		return nil, fmt.Errorf("AS3 isn't installed on the BIG-IP (%s not found); install the f5-appsvcs RPM first", endpoint)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to deploy AS3 declaration: %w", err)
+	}
+	// Anything may have changed in the deployed tenants
+	defer c.ClearCache()
+
+	results, err := c.waitForAS3(task)
+	if err != nil {
+		return results, err
+	}
+	var failed []string
+	for _, r := range results {
+		if r.Code >= 400 {
+			failed = append(failed, fmt.Sprintf("%s: %s (HTTP %d)", r.Tenant, r.Message, r.Code))
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("AS3 declaration failed for %s", strings.Join(failed, "; "))
+	}
+	slog.Info("Deployed AS3 declaration", "tenants", tenants, "task", task.ID)
+	return results, nil
+}
+
+// waitForAS3 polls an async task until AS3 has finished with every tenant
+func (c *Client) waitForAS3(task as3Task) ([]AS3Result, error) {
+	if task.ID == "" {
+		// Synchronous response: the results are already in
+		return task.Results, nil
+	}
+	endpoint := "/mgmt/shared/appsvcs/task/" + task.ID
+	deadline := time.Now().Add(as3DeployTimeout)
+	for {
+		var status as3Task
+		err := c.withRetry("AS3Task", func() error {
+			resp, err := c.session().APICall(&bigip.APIRequest{Method: "GET", URL: strings.TrimPrefix(endpoint, "/"), ContentType: "application/json"})
+			if err != nil {
+				return newAPIError(endpoint, resp, err)
+			}
+			return json.Unmarshal(resp, &status)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check AS3 task %s: %w", task.ID, err)
+		}
+		if len(status.Errors) > 0 {
+			return status.Results, fmt.Errorf("AS3 rejected the declaration: %s", strings.Join(status.Errors, "; "))
+		}
+		if !as3InProgress(status.Results) {
+			return status.Results, nil
+		}
+		if time.Now().After(deadline) {
+			return status.Results, &AS3TimeoutError{TaskID: task.ID, Endpoint: endpoint, After: as3DeployTimeout}
+		}
+		slog.Debug("AS3 task in progress", "task", task.ID)
+		time.Sleep(as3PollInterval)
+	}
+}
+
+func as3InProgress(results []AS3Result) bool {
+	if len(results) == 0 {
+		return true
+	}
+	for _, r := range results {
+		if r.Code == 0 || r.Message == "in progress" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package bigip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// Authentication methods
+const (
+	AuthBasic = "basic"
+	AuthToken = "token"
+)
+
+// login obtains a fresh X-F5-Auth-Token when token authentication is enabled.
+// With basic auth there is no session to renew, so it is a no-op.
+func (c *Client) login() error {
+	if c.authMethod != AuthToken {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"username":          c.Username,
+		"password":          c.Password,
+		"loginProviderName": c.loginProvider,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Requesting BIG-IP auth token", "loginProvider", c.loginProvider)
+	// Logging in goes without the token, which may be the one that expired
+	session := c.session()
+	session.Token = ""
+	resp, err := session.APICall(&bigip.APIRequest{
+		Method:      "post",
+		URL:         "mgmt/shared/authn/login",
+		Body:        string(body),
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return newAPIError("/mgmt/shared/authn/login", resp, err)
+	}
+
+	var login struct {
+		Token struct {
+			Token string `json:"token"`
+		} `json:"token"`
+	}
+	if err := json.Unmarshal(resp, &login); err != nil || login.Token.Token == "" {
+		return fmt.Errorf("unable to acquire authentication token: %v", err)
+	}
+	c.tokenMu.Lock()
+	c.BigIP.Token = login.Token.Token
+	c.tokenMu.Unlock()
+	slog.Debug("BIG-IP auth token acquired")
+	return nil
+}
+
+// callWithReauth runs fn and, if the device answers 401 (typically an expired
+// token after a long idle period), authenticates again and retries once
+func (c *Client) callWithReauth(operation string, fn func() error) error {
+	err := fn()
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.StatusCode != 401 {
+		return err
+	}
+
+	slog.Info("Request rejected with HTTP 401 - re-authenticating and retrying once", "operation", operation)
+	if loginErr := c.login(); loginErr != nil {
+		slog.Error("Re-authentication failed", "err", loginErr)
+		return err
+	}
+	return fn()
+}
+
+// Account returns the device the client talks to, the user it signs in as
+// and how it authenticates
+func (c *Client) Account() (host, user, authMethod string) {
+	return c.host, c.Username, c.authMethod
+}
@@ -0,0 +1,104 @@
+package bigip
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// CircuitOpenError is returned without contacting the device while the
+// circuit breaker is open
+type CircuitOpenError struct {
+	Since   time.Time
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	wait := time.Until(e.RetryAt).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return fmt.Sprintf("BIG-IP device unreachable since %s; retrying in %v", e.Since.Format("15:04"), wait)
+}
+
+// circuitBreaker stops calling the management endpoint after repeated
+// connectivity failures, so chat turns fail fast instead of sitting through
+// full retry cycles. After the cooldown one trial call is let through; its
+// outcome closes the circuit or re-opens it for another cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	trial        bool
+}
+
+// newCircuitBreaker returns nil (never trips) when threshold is zero
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns a CircuitOpenError while the circuit is open
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	retryAt := b.openedAt.Add(b.cooldown)
+	if time.Now().Before(retryAt) || b.trial {
+		return &CircuitOpenError{Since: b.firstFailure, RetryAt: retryAt}
+	}
+	slog.Info("Circuit breaker half-open - sending a trial request to BIG-IP")
+	b.trial = true
+	return nil
+}
+
+// record updates the breaker with the outcome of a call
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !countsAsOutage(err) {
+		if !b.openedAt.IsZero() {
+			slog.Info("Circuit breaker closed - BIG-IP is reachable again")
+		}
+		b.failures, b.firstFailure, b.openedAt, b.trial = 0, time.Time{}, time.Time{}, false
+		return
+	}
+
+	if b.failures == 0 {
+		b.firstFailure = time.Now()
+	}
+	b.failures++
+	if b.trial || b.failures >= b.threshold {
+		if b.openedAt.IsZero() || b.trial {
+			slog.Error("Circuit breaker open - pausing BIG-IP calls", "failures", b.failures, "cooldown", b.cooldown)
+		}
+		b.openedAt = time.Now()
+		b.trial = false
+	}
+}
+
+// countsAsOutage reports whether an error means the device itself is
+// unreachable or unhealthy, as opposed to a bad request or credentials
+func countsAsOutage(err error) bool {
+	switch classifyError(err) {
+	case ErrClassConnection, ErrClassTimeout, ErrClassDNS, ErrClassServer:
+		return true
+	}
+	return false
+}
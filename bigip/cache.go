@@ -0,0 +1,110 @@
+package bigip
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"f5chat/metrics"
+)
+
+// responseCache keeps recent API results in memory so repeated conversational
+// queries don't hit the management plane every time
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	// generation counts the times the cache was cleared. A value fetched
+	// under an earlier generation may predate the change that cleared it,
+	// so set drops it.
+	generation uint64
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (rc *responseCache) get(key string) (interface{}, bool) {
+	if rc == nil || rc.ttl <= 0 {
+		return nil, false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(rc.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// gen returns the cache's generation, to take before fetching a value and
+// pass to set with it
+func (rc *responseCache) gen() uint64 {
+	if rc == nil {
+		return 0
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.generation
+}
+
+// set caches value under key, unless the cache was cleared since gen, the
+// generation it was fetched under
+func (rc *responseCache) set(key string, value interface{}, gen uint64) {
+	if rc == nil || rc.ttl <= 0 {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if gen != rc.generation {
+		slog.Debug("Not caching a response fetched before the cache was cleared", "key", key)
+		return
+	}
+	rc.entries[key] = cacheEntry{value: value, expires: time.Now().Add(rc.ttl)}
+}
+
+func (rc *responseCache) clear() {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries = make(map[string]cacheEntry)
+	rc.generation++
+}
+
+// cached returns the cached value for the endpoint key, or calls fetch and
+// caches its result when the entry is missing or expired
+func cached[T any](c *Client, key string, fetch func() (T, error)) (T, error) {
+	if v, ok := c.cache.get(key); ok {
+		metrics.CacheLookups.WithLabelValues("hit").Inc()
+		slog.Debug("Serving from cache", "key", key, "ttl", c.cache.ttl)
+		return v.(T), nil
+	}
+	metrics.CacheLookups.WithLabelValues("miss").Inc()
+	gen := c.cache.gen()
+	v, err := fetch()
+	if err != nil {
+		return v, err
+	}
+	c.cache.set(key, v, gen)
+	return v, nil
+}
+
+// ClearCache drops all cached responses so the next query goes to the device
+func (c *Client) ClearCache() {
+	slog.Info("Clearing BIG-IP response cache")
+	c.cache.clear()
+}
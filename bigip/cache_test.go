@@ -0,0 +1,22 @@
+package bigip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheDropsValuesFetchedBeforeClear(t *testing.T) {
+	rc := newResponseCache(time.Minute)
+
+	gen := rc.gen()
+	rc.clear()
+	rc.set("/mgmt/tm/ltm/pool", "stale", gen)
+	if v, ok := rc.get("/mgmt/tm/ltm/pool"); ok {
+		t.Fatalf("got %v cached after a clear; want the value fetched before it dropped", v)
+	}
+
+	rc.set("/mgmt/tm/ltm/pool", "fresh", rc.gen())
+	if v, ok := rc.get("/mgmt/tm/ltm/pool"); !ok || v != "fresh" {
+		t.Fatalf("got %v, %v; want fresh, true", v, ok)
+	}
+}
@@ -0,0 +1,100 @@
+package bigip
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Call is an iControl REST request made to the device, as the audit log
+// records it
+type Call struct {
+	Host   string `json:"host"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Status is the response's HTTP status, 0 if none came
+	Status int `json:"status"`
+}
+
+// Modifies reports whether the request can change the device; logging in
+// for a token doesn't
+func (c Call) Modifies() bool {
+	switch c.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return !strings.HasPrefix(c.Path, "/mgmt/shared/authn/")
+}
+
+// callRecorder notes the requests made through it until they're taken
+type callRecorder struct {
+	next  http.RoundTripper
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (r *callRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if req.Context().Value(unrecordedKey{}) != nil {
+		return resp, err
+	}
+	call := Call{Host: req.URL.Host, Method: req.Method, Path: req.URL.RequestURI()}
+	if err == nil {
+		call.Status = resp.StatusCode
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+	return resp, err
+}
+
+// unrecordedKey marks the context of a request callRecorder leaves out
+type unrecordedKey struct{}
+
+// unrecordedTransport marks its requests for callRecorder to leave out
+type unrecordedTransport struct{ next http.RoundTripper }
+
+func (t unrecordedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(context.WithValue(req.Context(), unrecordedKey{}, true)))
+}
+
+// unrecorded returns a client sharing c's session, cache and limits whose
+// requests TakeCalls leaves out, for those made in the background rather
+// than for a query
+func (c *Client) unrecorded() *Client {
+	if c.calls == nil {
+		return c
+	}
+	return &Client{
+		BigIP:    c.BigIP,
+		Username: c.Username,
+		Password: c.Password,
+		cache:    c.cache,
+		retry:    c.retry,
+		limiter:  c.limiter,
+		breaker:  c.breaker,
+
+		host:      c.host,
+		connected: c.Connected(),
+
+		authMethod:    c.authMethod,
+		loginProvider: c.loginProvider,
+
+		transport: unrecordedTransport{next: c.transport},
+		tokenMu:   c.tokenMu,
+	}
+}
+
+// TakeCalls returns the requests made since it was last called and forgets
+// them; it's nil unless the client was made with AUDIT_LOG set
+func (c *Client) TakeCalls() []Call {
+	if c.calls == nil {
+		return nil
+	}
+	c.calls.mu.Lock()
+	defer c.calls.mu.Unlock()
+	calls := c.calls.calls
+	c.calls.calls = nil
+	return calls
+}
@@ -0,0 +1,49 @@
+package bigip
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// Certificate is an SSL certificate installed on the BIG-IP
+type Certificate struct {
+	*bigip.Certificate
+}
+
+// Expires returns when the certificate expires; zero when the device
+// didn't say
+func (c Certificate) Expires() time.Time {
+	if c.ExpirationDate == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.ExpirationDate, 0)
+}
+
+// GetCertificates retrieves the SSL certificates in the file store
+func (c *Client) GetCertificates() ([]Certificate, error) {
+	return cached(c, "/mgmt/tm/sys/file/ssl-cert", c.fetchCertificates)
+}
+
+func (c *Client) fetchCertificates() ([]Certificate, error) {
+	const endpoint = "/mgmt/tm/sys/file/ssl-cert"
+	slog.Debug("Fetching certificates", "endpoint", endpoint)
+
+	var certs *bigip.Certificates
+	err := c.withRetry("GetCertificates", func() error {
+		var err error
+		certs, err = c.session().Certificates()
+		return newAPIError(endpoint, nil, err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificates: %w", err)
+	}
+	out := make([]Certificate, len(certs.Certificates))
+	for n := range certs.Certificates {
+		out[n] = Certificate{Certificate: &certs.Certificates[n]}
+	}
+	slog.Info("Fetched certificates", "count", len(out))
+	return out, nil
+}
@@ -2,15 +2,22 @@ package bigip
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/f5devcentral/go-bigip"
 	"f5chat/config"
+	"f5chat/retry"
+	"f5chat/telemetry"
 )
 
 // Client wraps the F5 BIG-IP client with additional functionality
@@ -18,6 +25,27 @@ type Client struct {
 	*bigip.BigIP
 	Username string
 	Password string
+
+	cache   *responseCache
+	retry   retry.Policy
+	limiter *requestLimiter
+	breaker *circuitBreaker
+
+	host      string
+	connMu    sync.Mutex
+	connected bool
+
+	authMethod    string
+	loginProvider string
+
+	// calls records the requests made for the audit log, nil if there's none
+	calls *callRecorder
+
+	// transport makes the requests of every session
+	transport http.RoundTripper
+	// tokenMu guards the session's token, which logging in replaces; it is
+	// shared with the unrecorded client, which uses the same session
+	tokenMu *sync.Mutex
 }
 
 // VirtualServer represents a BIG-IP virtual server configuration
@@ -51,24 +79,20 @@ type WAFPolicy struct {
 	SignatureSetings map[string]interface{} `json:"signatureSettings,omitempty"`
 	BlockingMode     string                 `json:"blockingMode,omitempty"`
 	PlaceSignatures  bool                   `json:"placeSignaturesInStaging,omitempty"`
+	// VersionDatetime is when the policy last changed, as RFC 3339
+	VersionDatetime  string                 `json:"versionDatetime,omitempty"`
 }
 
 func NewClient(cfg *config.Config) (*Client, error) {
-	log.Printf("Raw BIG-IP host from environment: %s", cfg.BigIPHost)
-
 	// Parse host and port
-	hostParts := strings.Split(strings.TrimSpace(cfg.BigIPHost), ":")
-	host := hostParts[0]
-	port := "443" // default HTTPS port
-	if len(hostParts) > 1 {
-		port = hostParts[1]
+	host, port, err := ParseHostPort(cfg.BigIPHost)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("Parsed host components - Host: %s, Port: %s", host, port)
-
-	// Construct proper URL
-	baseURL := fmt.Sprintf("https://%s:%s", host, port)
-	log.Printf("Constructed base URL: %s", baseURL)
+	// Construct proper URL (JoinHostPort brackets IPv6 literals)
+	baseURL := "https://" + net.JoinHostPort(host, port)
+	slog.Debug("Parsed BIG-IP host", "raw", cfg.BigIPHost, "host", host, "port", port, "url", baseURL)
 
 	// Create configuration for BIG-IP session
 	config := &bigip.Config{
@@ -77,16 +101,41 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		Password: cfg.BigIPPassword,
 	}
 
-	log.Printf("Creating BIG-IP session with configuration: Address=%s, Username=%s",
-		config.Address, config.Username)
-
 	bigipClient := bigip.NewSession(config)
-	log.Printf("BIG-IP session created, attempting API connection...")
+	slog.Debug("BIG-IP session created", "address", config.Address, "username", config.Username)
+
+	// Self-signed certificates are accepted unless verification is asked for
+	verify := cfg.BigIPTLSVerify || cfg.BigIPCAFile != ""
+	var rootCAs *x509.CertPool
+	if cfg.BigIPCAFile != "" {
+		pem, err := os.ReadFile(cfg.BigIPCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read BIGIP_CA_FILE: %w", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in BIGIP_CA_FILE %s", cfg.BigIPCAFile)
+		}
+	}
+
+	// Lab devices may need longer than these and production ones less
+	dialTimeout, readTimeout := 30*time.Second, 45*time.Second
+	if cfg.BigIPDialTimeout > 0 {
+		dialTimeout = cfg.BigIPDialTimeout
+	}
+	if cfg.BigIPReadTimeout > 0 {
+		readTimeout = cfg.BigIPReadTimeout
+	}
 
 	// Set custom transport with enhanced TLS configuration for HTTPS
 	customTransport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
 		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true, // Required for self-signed certificates
+			InsecureSkipVerify: !verify,
+			RootCAs:            rootCAs,
 			MinVersion:         tls.VersionTLS12,
 			CipherSuites: []uint16{
 				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
@@ -96,7 +145,7 @@ func NewClient(cfg *config.Config) (*Client, error) {
 			},
 		},
 		TLSHandshakeTimeout:   45 * time.Second,
-		ResponseHeaderTimeout: 45 * time.Second,
+		ResponseHeaderTimeout: readTimeout,
 		ExpectContinueTimeout: 15 * time.Second,
 		IdleConnTimeout:       90 * time.Second,
 		DisableKeepAlives:     false,
@@ -105,90 +154,169 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		ForceAttemptHTTP2:     false,
 	}
 
-	log.Printf("Configuring TLS transport with custom settings...")
 	bigipClient.Transport = customTransport
 
-	// Test connection with timeout
-	log.Printf("Starting connection test to BIG-IP at %s", host)
-	log.Printf("Using HTTPS connection to %s/mgmt/tm/ltm/virtual", baseURL)
+	// go-bigip writes to its *http.Transport on every call, so requests are
+	// made through a transport of their own (see session) that hands them
+	// to a clone of this one, with whatever records or traces them on top
+	base := customTransport.Clone()
+	base.Proxy = http.ProxyFromEnvironment
+	var handler http.RoundTripper = base
+	var calls *callRecorder
+	if cfg.AuditLog != "" {
+		calls = &callRecorder{next: handler}
+		handler = calls
+	}
+	if cfg.TraceFile != "" {
+		tracer, err := newTracingTransport(handler, cfg.TraceFile)
+		if err != nil {
+			return nil, err
+		}
+		handler = tracer
+	}
+	tracing := cfg.OTelEndpoint != "" || cfg.OTelTracesEndpoint != ""
+	if tracing {
+		handler = telemetry.Transport(handler, "BIG-IP")
+	}
+
+	client := &Client{
+		BigIP:    bigipClient,
+		Username: cfg.BigIPUsername,
+		Password: cfg.BigIPPassword,
+		host:     cfg.BigIPHost,
+		cache:    newResponseCache(cfg.CacheTTL),
+		retry:    retry.BIGIPFromConfig(cfg),
+		limiter:  newRequestLimiter(cfg.RateLimit, cfg.RateBurst),
+		breaker:  newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+
+		authMethod:    cfg.BigIPAuthMethod,
+		loginProvider: cfg.BigIPLoginProvider,
+
+		calls:     calls,
+		transport: handler,
+		tokenMu:   new(sync.Mutex),
+	}
+	slog.Info("BIG-IP client ready - connecting on first query", "url", baseURL)
+	return client, nil
+}
+
+// ParseHostPort splits a management address into host and port, defaulting
+// the port to 443. It accepts hostnames, IPv4 and IPv6 addresses with or
+// without a port ("bigip.example.com", "10.0.0.5:8443", "2001:db8::1",
+// "[2001:db8::1]:8443") and tolerates a leading https:// scheme.
+func ParseHostPort(address string) (host, port string, err error) {
+	address = strings.TrimSpace(address)
+	address = strings.TrimPrefix(address, "https://")
+	address = strings.TrimSuffix(address, "/")
+	if address == "" {
+		return "", "", fmt.Errorf("BIG-IP host is empty")
+	}
+
+	// A bare IPv6 literal has several colons and no brackets
+	if ip := net.ParseIP(strings.Trim(address, "[]")); ip != nil && strings.Count(address, ":") > 1 && !strings.Contains(address, "]:") {
+		return ip.String(), "443", nil
+	}
+	if !strings.Contains(address, ":") || (strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]")) {
+		return strings.Trim(address, "[]"), "443", nil
+	}
+
+	host, port, err = net.SplitHostPort(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid BIG-IP host %q: %v", address, err)
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("invalid BIG-IP host %q: missing host", address)
+	}
+	if n, convErr := strconv.Atoi(port); convErr != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("invalid BIG-IP host %q: port must be 1-65535", address)
+	}
+	return host, port, nil
+}
+
+// ConnectError is returned when the device can't be reached; the client
+// tries again on the next query, so callers can report it and carry on
+type ConnectError struct {
+	Host string
+	Err  error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("unable to connect to BIG-IP at %s: %v", e.Host, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error { return e.Err }
+
+// session returns a copy of the go-bigip session for one request. go-bigip
+// sets its transport's proxy on every call, so requests made at once can't
+// share a transport; each session's hands its requests to the client's,
+// whose connections they share.
+func (c *Client) session() *bigip.BigIP {
+	c.tokenMu.Lock()
+	session := *c.BigIP
+	c.tokenMu.Unlock()
+	session.Transport = &http.Transport{}
+	session.Transport.RegisterProtocol("https", c.transport)
+	return &session
+}
+
+// Connected reports whether the connection test has succeeded
+func (c *Client) Connected() bool {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.connected
+}
+
+// Connect runs the connection test against the device. It is called lazily
+// before the first query and again after a failed attempt, so the client
+// reconnects on its own once the device comes back.
+func (c *Client) Connect() error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.connected {
+		return nil
+	}
+
+	if err := c.login(); err != nil {
+		return &ConnectError{Host: c.host, Err: err}
+	}
+
+	slog.Info("Starting connection test to BIG-IP", "host", c.host, "endpoint", c.Host+"/mgmt/tm/ltm/virtual")
 
 	// Create a channel for connection result
 	connectionStatus := make(chan error, 1)
 
-	// Maximum number of retries
-	maxRetries := 3
-	baseDelay := 5 * time.Second
-	maxDelay := 30 * time.Second
-
 	// Start connection test in a goroutine
 	go func() {
-		var lastErr error
-		for retry := 0; retry < maxRetries; retry++ {
-			if retry > 0 {
-				// Calculate exponential backoff delay
-				backoffMultiplier := uint(1) << uint(retry-1)
-				delay := baseDelay * time.Duration(backoffMultiplier)
-				if delay > maxDelay {
-					delay = maxDelay
-				}
-				log.Printf("Retry attempt %d/%d after %v delay (exponential backoff)...", retry+1, maxRetries, delay)
-				time.Sleep(delay)
-			}
-
+		connectionStatus <- runWithRetry(c.retry, "connection test", c.attempt("connection test", func() error {
 			// Try to fetch virtual servers as a connection test
-			testVs, testErr := bigipClient.VirtualServers()
-			if testErr == nil {
-				log.Printf("Connection successful on attempt %d, found %d virtual servers", retry+1, len(testVs.VirtualServers))
-				connectionStatus <- nil
-				return
-			}
-
-			lastErr = testErr
-			errLower := strings.ToLower(testErr.Error())
-			log.Printf("Connection attempt %d failed: %v", retry+1, testErr)
-
-			switch {
-			case strings.Contains(errLower, "certificate"):
-				log.Printf("Certificate validation error - modifying TLS config and retrying...")
-				bigipClient.Transport = customTransport
-				retryVs, retryErr := bigipClient.VirtualServers()
-				if retryErr == nil {
-					log.Printf("Connection successful after certificate handling, found %d virtual servers", len(retryVs.VirtualServers))
-					connectionStatus <- nil
-					return
+			testVs, testErr := c.session().VirtualServers()
+			if testErr != nil {
+				testErr = newAPIError("/mgmt/tm/ltm/virtual", nil, testErr)
+				switch classifyError(testErr) {
+				case ErrClassConnection:
+					slog.Warn("Connection refused - the port might be blocked or BIG-IP not accepting connections")
+				case ErrClassDNS:
+					slog.Warn("DNS resolution failed", "host", c.host)
 				}
-				log.Printf("Still failed after certificate handling: %v", retryErr)
-			case strings.Contains(errLower, "connection refused"):
-				log.Printf("Connection refused - port %s might be blocked or BIG-IP not accepting connections", port)
-			case strings.Contains(errLower, "no such host"):
-				log.Printf("DNS resolution failed for host: %s", host)
-			case strings.Contains(errLower, "timeout"):
-				log.Printf("Connection timed out - possible network issues or firewall blocking")
-			case strings.Contains(errLower, "unauthorized"):
-				log.Printf("Authentication failed - verify username and password")
-			default:
-				log.Printf("Unexpected error: %v", testErr)
+				return testErr
 			}
-		}
-		connectionStatus <- fmt.Errorf("failed to connect after %d attempts - last error: %v", maxRetries, lastErr)
+			slog.Debug("Connection test succeeded", "virtualServers", len(testVs.VirtualServers))
+			return nil
+		}))
 	}()
 
 	// Wait for connection test with timeout
 	select {
 	case err := <-connectionStatus:
 		if err != nil {
-			return nil, fmt.Errorf("failed to connect to BIG-IP: %v", err)
+			return &ConnectError{Host: c.host, Err: err}
 		}
-		log.Printf("Successfully connected to BIG-IP")
+		slog.Info("Connected to BIG-IP", "host", c.host)
+		c.connected = true
+		return nil
 	case <-time.After(60 * time.Second):
-		return nil, fmt.Errorf("connection timeout after 60 seconds - please verify:\n1. BIG-IP host and port (%s)\n2. Network connectivity\n3. Firewall rules\n4. BIG-IP management interface status", cfg.BigIPHost)
+		return &ConnectError{Host: c.host, Err: fmt.Errorf("connection timeout after 60 seconds - please verify:\n1. BIG-IP host and port (%s)\n2. Network connectivity\n3. Firewall rules\n4. BIG-IP management interface status", c.host)}
 	}
-
-	return &Client{
-		BigIP:    bigipClient,
-		Username: cfg.BigIPUsername,
-		Password: cfg.BigIPPassword,
-	}, nil
 }
 
 // ASMPolicy represents detailed WAF/ASM policy information in BIG-IP
@@ -216,103 +344,42 @@ type ASMPoliciesResponse struct {
 
 // GetWAFPolicies retrieves the list of WAF policies from BIG-IP
 func (c *Client) GetWAFPolicies() ([]*WAFPolicy, error) {
-	log.Printf("\n=== Starting GetWAFPolicies Operation ===")
-	log.Printf("Endpoint: /mgmt/tm/asm/policies")
-	log.Printf("Method: GET")
-	log.Printf("Authentication: Basic Auth (Username: %s)", c.Username)
-
-	maxRetries := 3
-	baseDelay := 5 * time.Second
-	maxDelay := 30 * time.Second
-	var lastErr error
-	var policies ASMPoliciesResponse
+	return cached(c, "/mgmt/tm/asm/policies", c.fetchWAFPolicies)
+}
 
-	for retry := 0; retry < maxRetries; retry++ {
-		if retry > 0 {
-			// Calculate exponential backoff delay
-			backoffMultiplier := uint(1) << uint(retry-1)
-			delay := baseDelay * time.Duration(backoffMultiplier)
-			if delay > maxDelay {
-				delay = maxDelay
-			}
-			log.Printf("Retry attempt %d/%d for WAF policies after %v delay (exponential backoff)...", retry+1, maxRetries, delay)
-			time.Sleep(delay)
-		}
+func (c *Client) fetchWAFPolicies() ([]*WAFPolicy, error) {
+	slog.Debug("Fetching WAF policies", "endpoint", "/mgmt/tm/asm/policies", "username", c.Username)
 
+	var policies ASMPoliciesResponse
+	err := c.withRetry("GetWAFPolicies", func() error {
 		req := &bigip.APIRequest{
 			Method:      "GET",
 			URL:         "mgmt/tm/asm/policies",
 			ContentType: "application/json",
 		}
 
-		log.Printf("\nMaking API request to fetch WAF policies...")
-		resp, err := c.BigIP.APICall(req)
-
-		if err == nil {
-			if err = json.Unmarshal(resp, &policies); err == nil {
-				log.Printf("\nAPI Response received and parsed successfully")
-				log.Printf("Response Kind: %s", policies.Kind)
-				log.Printf("Generation: %d", policies.Generation)
-				break
+		resp, err := c.session().APICall(req)
+		if err != nil {
+			if classifyError(err) == ErrClassConnection {
+				c.checkASMEndpoint()
 			}
-			log.Printf("Error parsing WAF policies response: %v", err)
-			lastErr = fmt.Errorf("JSON parsing error: %v", err)
-			continue
+			return newAPIError("/mgmt/tm/asm/policies", resp, err)
 		}
-
-		lastErr = err
-		errStr := err.Error()
-		log.Printf("\nAPI request failed on attempt %d: %v", retry+1, err)
-
-		// Determine if we should retry based on error type
-		shouldRetry := false
-		switch {
-		case strings.Contains(strings.ToLower(errStr), "unauthorized"):
-			log.Printf("Authentication Error: Please verify credentials and WAF module access permissions")
-			// Don't retry auth errors
-		case strings.Contains(strings.ToLower(errStr), "connection"):
-			log.Printf("Connection Error: Unable to reach BIG-IP WAF endpoint")
-			log.Printf("Please verify:\n1. Network connectivity\n2. BIG-IP management interface\n3. ASM module is provisioned and licensed")
-		log.Printf("Attempting to verify ASM module status...")
-		// Try to make a HEAD request to check if the endpoint exists
-		headReq := &bigip.APIRequest{
-			Method:      "HEAD",
-			URL:         "mgmt/tm/asm/policies",
-			ContentType: "application/json",
-		}
-		_, headErr := c.BigIP.APICall(headReq)
-		if headErr != nil {
-			log.Printf("ASM endpoint check failed: %v", headErr)
-		} else {
-			log.Printf("ASM endpoint exists but GET request failed - possible permission issue")
-		}
-			shouldRetry = true
-		case strings.Contains(strings.ToLower(errStr), "timeout"):
-			log.Printf("Timeout Error: Request timed out")
-			shouldRetry = true
-		case strings.Contains(strings.ToLower(errStr), "not found"):
-			log.Printf("Endpoint Error: WAF/ASM endpoint not found")
-			log.Printf("Please verify ASM module is provisioned on BIG-IP")
-			// Don't retry 404 errors
-		default:
-			log.Printf("Unhandled error type - Full error: %v", err)
-			shouldRetry = true
-		}
-
-		if !shouldRetry || retry == maxRetries-1 {
-			return nil, fmt.Errorf("failed to get WAF policies: %v", lastErr)
+		if err := json.Unmarshal(resp, &policies); err != nil {
+			slog.Error("Failed to parse WAF policies response", "err", err)
+			return fmt.Errorf("JSON parsing error: %v", err)
 		}
+		slog.Debug("WAF policies response parsed", "kind", policies.Kind, "generation", policies.Generation)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WAF policies: %w", err)
 	}
 
 	var wafPolicies []*WAFPolicy
-	log.Printf("\nProcessing %d WAF policies...", len(policies.Items))
-
 	for _, policy := range policies.Items {
-		log.Printf("\nProcessing policy:")
-		log.Printf("  Name: %s", policy.Name)
-		log.Printf("  ID: %s", policy.ID)
-		log.Printf("  Type: %s", policy.Type)
-		log.Printf("  Enforcement Mode: %s", policy.EnforcementMode)
+		slog.Debug("WAF policy", "name", policy.Name, "id", policy.ID, "type", policy.Type,
+			"enforcementMode", policy.EnforcementMode, "virtualServers", policy.VirtualServers)
 
 		wafPolicy := &WAFPolicy{
 			Name:             policy.Name,
@@ -327,23 +394,17 @@ func (c *Client) GetWAFPolicies() ([]*WAFPolicy, error) {
 			BlockingMode:     policy.BlockingMode,
 			PlaceSignatures:  policy.PlaceSignatures,
 			SignatureSetings: policy.SignatureSetings,
+			VersionDatetime:  policy.VersionDatetime,
 			Kind:            policy.Kind,
 			SelfLink:        policy.SelfLink,
 		}
 		wafPolicies = append(wafPolicies, wafPolicy)
 	}
 
-	log.Printf("\nFound and processed %d WAF policies successfully", len(wafPolicies))
 	if len(wafPolicies) == 0 {
-		log.Printf("\nWARNING: No WAF policies found. This could indicate that:")
-		log.Printf("1. No WAF policies are configured")
-		log.Printf("2. The ASM module might not be provisioned")
-		log.Printf("3. The user might not have permissions to view WAF policies")
+		slog.Warn("No WAF policies found - none may be configured, ASM may not be provisioned, or the user may lack permission to view them")
 	} else {
-		log.Printf("\nWAF Policies found:")
-		for i, policy := range wafPolicies {
-			log.Printf("[%d] %s (Type: %s, Mode: %s)", i+1, policy.Name, policy.Type, policy.EnforcementMode)
-		}
+		slog.Info("Fetched WAF policies", "count", len(wafPolicies))
 	}
 	return wafPolicies, nil
 }
@@ -353,86 +414,50 @@ func (c *Client) GetWAFPolicyDetails(policyName string) (*WAFPolicy, error) {
 	if policyName == "" {
 		return nil, fmt.Errorf("policy name cannot be empty")
 	}
-	log.Printf("\nAttempting to fetch details for WAF policy: %s", policyName)
-	log.Printf("\n=== Starting GetWAFPolicyDetails Operation for policy: %s ===", policyName)
-	log.Printf("Endpoint: /mgmt/tm/asm/policies")
-	log.Printf("Method: GET")
-
-	maxRetries := 3
-	baseDelay := 5 * time.Second
-	maxDelay := 30 * time.Second
-	var lastErr error
-	var policiesResp ASMPoliciesResponse
+	return cached(c, "/mgmt/tm/asm/policies?name="+policyName, func() (*WAFPolicy, error) {
+		return c.fetchWAFPolicyDetails(policyName)
+	})
+}
 
-	for retry := 0; retry < maxRetries; retry++ {
-		if retry > 0 {
-			// Calculate exponential backoff delay
-			backoffMultiplier := uint(1) << uint(retry-1)
-			delay := baseDelay * time.Duration(backoffMultiplier)
-			if delay > maxDelay {
-				delay = maxDelay
-			}
-			log.Printf("Retry attempt %d/%d after %v delay (exponential backoff)...", retry+1, maxRetries, delay)
-			time.Sleep(delay)
-		}
+func (c *Client) fetchWAFPolicyDetails(policyName string) (*WAFPolicy, error) {
+	slog.Debug("Fetching WAF policy details", "endpoint", "/mgmt/tm/asm/policies", "policy", policyName)
 
+	var policiesResp ASMPoliciesResponse
+	err := c.withRetry("GetWAFPolicyDetails", func() error {
 		req := &bigip.APIRequest{
 			Method:      "GET",
-			URL:         fmt.Sprintf("mgmt/tm/asm/policies?$filter=name+eq+%s", policyName),
+			URL:         "mgmt/tm/asm/policies?" + policyFilter(policyName),
 			ContentType: "application/json",
 		}
 
-		log.Printf("\nMaking API request to fetch details for WAF policy: %s", policyName)
-		resp, err := c.BigIP.APICall(req)
-
-		if err == nil {
-			if err = json.Unmarshal(resp, &policiesResp); err == nil {
-				log.Printf("\nAPI Response received and parsed successfully")
-				break
-			}
-			log.Printf("Error parsing WAF policy details response: %v", err)
-			lastErr = fmt.Errorf("JSON parsing error: %v", err)
-			continue
-		}
-
-		lastErr = err
-		errStr := err.Error()
-		log.Printf("\nAPI request failed on attempt %d: %v", retry+1, err)
-
-		// Determine if we should retry based on error type
-		shouldRetry := false
-		switch {
-		case strings.Contains(strings.ToLower(errStr), "unauthorized"):
-			log.Printf("Authentication Error: Please verify credentials and WAF module access permissions")
-			// Don't retry auth errors
-		case strings.Contains(strings.ToLower(errStr), "connection"):
-			log.Printf("Connection Error: Unable to reach BIG-IP WAF endpoint")
-			shouldRetry = true
-		case strings.Contains(strings.ToLower(errStr), "timeout"):
-			log.Printf("Timeout Error: Request timed out")
-			shouldRetry = true
-		case strings.Contains(strings.ToLower(errStr), "not found"):
-			log.Printf("Endpoint Error: WAF/ASM endpoint or policy not found")
-			// Don't retry 404 errors
-		default:
-			log.Printf("Unhandled error type - Full error: %v", err)
-			shouldRetry = true
+		resp, err := c.session().APICall(req)
+		if err != nil {
+			return newAPIError("/mgmt/tm/asm/policies", resp, err)
 		}
-
-		if !shouldRetry || retry == maxRetries-1 {
-			return nil, fmt.Errorf("failed to get WAF policy details: %v", lastErr)
+		if err := json.Unmarshal(resp, &policiesResp); err != nil {
+			slog.Error("Failed to parse WAF policy details response", "policy", policyName, "err", err)
+			return fmt.Errorf("JSON parsing error: %v", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WAF policy details: %w", err)
 	}
 
 	if len(policiesResp.Items) == 0 {
-		return nil, fmt.Errorf("WAF policy '%s' not found", policyName)
+		return nil, &ObjectNotFoundError{Kind: "WAF policy", Name: policyName}
+	}
+	if len(policiesResp.Items) > 1 {
+		// The same name in several partitions; don't guess which was meant
+		matches := make([]string, len(policiesResp.Items))
+		for i, p := range policiesResp.Items {
+			matches[i] = p.FullPath
+		}
+		return nil, &AmbiguousNameError{Kind: "WAF policy", Name: policyName, Matches: matches}
 	}
 
 	policy := policiesResp.Items[0]
-	log.Printf("\nSuccessfully retrieved details for WAF policy: %s", policy.Name)
-	log.Printf("Policy ID: %s", policy.ID)
-	log.Printf("Type: %s", policy.Type)
-	log.Printf("Status: %s", map[bool]string{true: "Active", false: "Inactive"}[policy.Active])
+	slog.Info("Fetched WAF policy details", "policy", policy.Name, "id", policy.ID, "type", policy.Type, "active", policy.Active)
 
 	return &WAFPolicy{
 		Name:             policy.Name,
@@ -447,101 +472,189 @@ func (c *Client) GetWAFPolicyDetails(policyName string) (*WAFPolicy, error) {
 		BlockingMode:     policy.BlockingMode,
 		PlaceSignatures:  policy.PlaceSignatures,
 		SignatureSetings: policy.SignatureSetings,
+		VersionDatetime:  policy.VersionDatetime,
 		Kind:            policy.Kind,
 		SelfLink:        policy.SelfLink,
 	}, nil
 }
 
+// GetVirtualServers retrieves the list of virtual servers from BIG-IP
 func (c *Client) GetVirtualServers() ([]VirtualServer, error) {
-	log.Println("\n=== Starting GetVirtualServers Operation ===")
-	log.Printf("Endpoint: /mgmt/tm/ltm/virtual")
-	log.Printf("Method: GET")
-	log.Printf("Authentication: Basic Auth (Username: %s)", c.Username)
+	return cached(c, "/mgmt/tm/ltm/virtual", c.fetchVirtualServers)
+}
 
-	log.Println("\nMaking API request to fetch virtual servers...")
-	vs, err := c.VirtualServers()
+func (c *Client) fetchVirtualServers() ([]VirtualServer, error) {
+	gen := c.cache.gen()
+	slog.Debug("Fetching virtual servers", "endpoint", "/mgmt/tm/ltm/virtual", "username", c.Username)
+
+	// Expanded, the listing has each virtual server's profiles and policies,
+	// which it otherwise only links to
+	items, err := c.getExpanded("GetVirtualServers", "/mgmt/tm/ltm/virtual")
 	if err != nil {
-		log.Printf("\nERROR: Failed to fetch virtual servers")
-		log.Printf("Error Type: %T", err)
-		log.Printf("Error Message: %v", err)
+		slog.Error("Failed to fetch virtual servers", "errType", fmt.Sprintf("%T", err), "err", err)
+		return nil, fmt.Errorf("API request failed: %w", err)
+	}
 
-		errStr := err.Error()
-		switch {
-		case strings.Contains(strings.ToLower(errStr), "unauthorized"):
-			log.Printf("Authentication Error: Please verify credentials")
-		case strings.Contains(strings.ToLower(errStr), "connection"):
-			log.Printf("Connection Error: Unable to reach BIG-IP")
-		case strings.Contains(strings.ToLower(errStr), "certificate"):
-			log.Printf("TLS Certificate Error: Certificate validation failed")
-		case strings.Contains(strings.ToLower(errStr), "no such host"):
-			log.Printf("DNS Error: Unable to resolve BIG-IP hostname")
-		case strings.Contains(strings.ToLower(errStr), "timeout"):
-			log.Printf("Timeout Error: Request took too long to complete")
-		default:
-			log.Printf("Unhandled error type - Full error: %v", err)
-		}
-		return nil, fmt.Errorf("API request failed: %v", err)
+	virtualServers, expanded, err := c.parseVirtualServers(items, true, gen)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		slog.Warn("No virtual servers found")
 	}
 
-	log.Println("\nAPI Response received successfully")
+	slog.Info("Fetched virtual servers", "count", len(virtualServers), "expanded", expanded)
+	return virtualServers, nil
+}
 
+// parseVirtualServers decodes the items of a virtual server listing, and
+// reports whether it was expanded. With seed set, the profiles an expanded
+// listing gave each virtual server are cached, under gen, the cache
+// generation the listing was fetched under.
+func (c *Client) parseVirtualServers(items []json.RawMessage, seed bool, gen uint64) ([]VirtualServer, bool, error) {
 	var virtualServers []VirtualServer
-	if vs != nil && vs.VirtualServers != nil {
-		count := len(vs.VirtualServers)
-		log.Printf("\nFound %d virtual server(s)", count)
-
-		for i, v := range vs.VirtualServers {
-			log.Printf("\nVirtual Server [%d/%d]:", i+1, count)
-			log.Printf("  Name:        %s", v.Name)
-			log.Printf("  Destination: %s", v.Destination)
-			log.Printf("  Pool:        %s", v.Pool)
-			log.Printf("  Status:      %s", map[bool]string{true: "Enabled", false: "Disabled"}[v.Enabled])
-			vs := v // Create a copy to avoid referencing the loop variable
-			virtualServers = append(virtualServers, VirtualServer{VirtualServer: &vs})
+	expanded := false
+	for _, raw := range items {
+		var v bigip.VirtualServer
+		var refs struct {
+			Profiles subcollection[bigip.Profile] `json:"profilesReference"`
+			Policies subcollection[struct {
+				FullPath string `json:"fullPath"`
+			}] `json:"policiesReference"`
 		}
-	} else {
-		log.Printf("\nWARNING: No virtual servers found")
-		log.Printf("Response validation:")
-		log.Printf("- vs object is nil: %v", vs == nil)
-		log.Printf("- vs.VirtualServers is nil: %v", vs != nil && vs.VirtualServers == nil)
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, false, fmt.Errorf("JSON parsing error: %v", err)
+		}
+		if err := json.Unmarshal(raw, &refs); err != nil {
+			return nil, false, fmt.Errorf("JSON parsing error: %v", err)
+		}
+		// Every virtual server has a profile, so one without any wasn't expanded
+		if refs.Profiles.Items != nil {
+			expanded = true
+			v.Profiles = refs.Profiles.Items
+			if seed {
+				c.cacheVirtualProfiles(v.FullPath, v.Profiles, gen)
+			}
+			for _, p := range refs.Policies.Items {
+				v.Policies = append(v.Policies, p.FullPath)
+			}
+		}
+		slog.Debug("Virtual server", "name", v.Name, "destination", v.Destination, "pool", v.Pool, "enabled", v.Enabled)
+		virtualServers = append(virtualServers, VirtualServer{VirtualServer: &v})
 	}
+	return virtualServers, expanded, nil
+}
 
-	log.Printf("GetVirtualServers operation completed. Returning %d virtual servers", len(virtualServers))
-	return virtualServers, nil
+// poolListing is the cached result of GetPools
+type poolListing struct {
+	pools   []Pool
+	members map[string][]PoolMember
 }
 
-func (c *Client) GetPools() ([]Pool, map[string][]string, error) {
-	pools, err := c.Pools()
+// GetPools retrieves all pools along with their members, by the pool's full
+// path. A pool whose members couldn't be read has none in the map; callers
+// that need to say why can ask GetPoolMembers for them. The result is
+// shared through the cache and must not be modified.
+func (c *Client) GetPools() ([]Pool, map[string][]PoolMember, error) {
+	listing, err := cached(c, "/mgmt/tm/ltm/pool", c.fetchPools)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get pools: %v", err)
+		return nil, nil, err
 	}
+	return listing.pools, listing.members, nil
+}
 
-	var poolList []Pool
-	poolMembers := make(map[string][]string)
+func (c *Client) fetchPools() (poolListing, error) {
+	gen := c.cache.gen()
+	// Expanded, the listing has each pool's members, so they don't take a
+	// request per pool
+	items, err := c.getExpanded("GetPools", "/mgmt/tm/ltm/pool")
+	if err != nil {
+		return poolListing{}, fmt.Errorf("failed to get pools: %w", err)
+	}
 
-	for _, p := range pools.Pools {
-		pool := p // Create a copy to avoid referencing the loop variable
+	poolList, expanded, err := parsePools(items)
+	if err != nil {
+		return poolListing{}, err
+	}
+	poolMembers := c.listPoolMembers(poolList, expanded, true, gen)
+	slog.Debug("Fetched pools", "count", len(poolList), "expanded", len(expanded) > 0)
+	return poolListing{pools: poolList, members: poolMembers}, nil
+}
+
+// parsePools decodes the items of a pool listing, with the members an
+// expanded listing gave each pool by its full path
+func parsePools(items []json.RawMessage) ([]Pool, map[string][]PoolMember, error) {
+	var poolList []Pool
+	expanded := make(map[string][]PoolMember)
+	for _, raw := range items {
+		var pool bigip.Pool
+		var refs struct {
+			Members subcollection[bigip.PoolMember] `json:"membersReference"`
+		}
+		if err := json.Unmarshal(raw, &pool); err != nil {
+			return nil, nil, fmt.Errorf("JSON parsing error: %v", err)
+		}
+		if err := json.Unmarshal(raw, &refs); err != nil {
+			return nil, nil, fmt.Errorf("JSON parsing error: %v", err)
+		}
 		poolList = append(poolList, Pool{Pool: &pool})
-		members, err := c.PoolMembers(p.Name)
-		if err != nil {
-			fmt.Printf("Warning: failed to get members for pool %s: %v\n", p.Name, err)
-			continue
+		if refs.Members.Items != nil {
+			var members []PoolMember
+			for i := range refs.Members.Items {
+				members = append(members, PoolMember{PoolMember: &refs.Members.Items[i]})
+			}
+			expanded[pool.FullPath] = members
 		}
-		var memberList []string
-		if members != nil {
-			for i := range members.PoolMembers {
-				memberList = append(memberList, members.PoolMembers[i].FullPath)
+	}
+	return poolList, expanded, nil
+}
+
+// listPoolMembers returns each pool's members by the pool's full path, from
+// the members a listing expanded or, when it expanded none, a request per
+// pool; pools whose members couldn't be read are left out. With seed set,
+// the expanded members are cached for GetPoolMembers, under gen as
+// parseVirtualServers caches profiles.
+func (c *Client) listPoolMembers(poolList []Pool, expanded map[string][]PoolMember, seed bool, gen uint64) map[string][]PoolMember {
+	names := make(map[string]int)
+	for _, p := range poolList {
+		names[p.Name]++
+	}
+	poolMembers := make(map[string][]PoolMember)
+	for _, p := range poolList {
+		members, ok := expanded[p.FullPath]
+		switch {
+		case ok, len(expanded) > 0:
+			// The pools the listing has no members for have none
+			if seed {
+				c.cachePoolMembers(p, members, names, gen)
+			}
+		default:
+			// Nothing came expanded: the device can't, or no pool has members
+			var err error
+			if members, err = c.GetPoolMembers(p.FullPath); err != nil {
+				slog.Warn("Failed to get pool members", "pool", p.FullPath, "err", err)
+				continue
 			}
 		}
-		poolMembers[p.Name] = memberList
+		poolMembers[p.FullPath] = members
 	}
-	return poolList, poolMembers, nil
+	return poolMembers
 }
 
+// GetNodes retrieves the list of backend nodes from BIG-IP
 func (c *Client) GetNodes() ([]Node, error) {
-	nodes, err := c.Nodes()
+	return cached(c, "/mgmt/tm/ltm/node", c.fetchNodes)
+}
+
+func (c *Client) fetchNodes() ([]Node, error) {
+	var nodes *bigip.Nodes
+	err := c.withRetry("GetNodes", func() error {
+		var err error
+		nodes, err = c.session().Nodes()
+		return newAPIError("/mgmt/tm/ltm/node", nil, err)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get nodes: %v", err)
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
 	}
 
 	var nodeList []Node
@@ -550,4 +663,21 @@ func (c *Client) GetNodes() ([]Node, error) {
 		nodeList = append(nodeList, Node{Node: &node})
 	}
 	return nodeList, nil
-}
\ No newline at end of file
+}
+
+// checkASMEndpoint makes a HEAD request to tell an unreachable device apart
+// from one where the ASM endpoint exists but the GET is rejected
+func (c *Client) checkASMEndpoint() {
+	slog.Debug("Verifying ASM module status")
+	headReq := &bigip.APIRequest{
+		Method:      "HEAD",
+		URL:         "mgmt/tm/asm/policies",
+		ContentType: "application/json",
+	}
+	c.limiter.wait("ASM endpoint check")
+	if _, headErr := c.session().APICall(headReq); headErr != nil {
+		slog.Warn("ASM endpoint check failed", "err", headErr)
+	} else {
+		slog.Warn("ASM endpoint exists but GET request failed - possible permission issue")
+	}
+}
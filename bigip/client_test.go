@@ -0,0 +1,43 @@
+package bigip
+
+import "testing"
+
+func TestParseHostPort(t *testing.T) {
+	tests := []struct {
+		address    string
+		host, port string
+		wantErr    bool
+	}{
+		{address: "bigip.example.com", host: "bigip.example.com", port: "443"},
+		{address: "10.0.0.5", host: "10.0.0.5", port: "443"},
+		{address: "10.0.0.5:8443", host: "10.0.0.5", port: "8443"},
+		{address: "2001:db8::1", host: "2001:db8::1", port: "443"},
+		{address: "[2001:db8::1]:8443", host: "2001:db8::1", port: "8443"},
+		{address: "[2001:db8::1]", host: "2001:db8::1", port: "443"},
+		{address: "https://host/", host: "host", port: "443"},
+		{address: "  https://host:8443/  ", host: "host", port: "8443"},
+		{address: "host:0", wantErr: true},
+		{address: "host:99999", wantErr: true},
+		{address: "host:https", wantErr: true},
+		{address: ":8443", wantErr: true},
+		{address: "", wantErr: true},
+		{address: "https://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			host, port, err := ParseHostPort(tt.address)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseHostPort(%q) = %q, %q; want an error", tt.address, host, port)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseHostPort(%q): %v", tt.address, err)
+			}
+			if host != tt.host || port != tt.port {
+				t.Errorf("ParseHostPort(%q) = %q, %q; want %q, %q", tt.address, host, port, tt.host, tt.port)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// GetCPUUsage retrieves how busy the device's CPUs have been over the last
+// minute, as a percentage averaged across them
+func (c *Client) GetCPUUsage() (float64, error) {
+	return cached(c, "/mgmt/tm/sys/cpu", c.fetchCPUUsage)
+}
+
+func (c *Client) fetchCPUUsage() (float64, error) {
+	const endpoint = "/mgmt/tm/sys/cpu"
+	slog.Debug("Fetching CPU statistics", "endpoint", endpoint)
+
+	var resp []byte
+	err := c.withRetry("GetCPUUsage", func() error {
+		var err error
+		resp, err = c.session().APICall(&bigip.APIRequest{
+			Method:      "GET",
+			URL:         "mgmt/tm/sys/cpu",
+			ContentType: "application/json",
+		})
+		return newAPIError(endpoint, resp, err)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get CPU statistics: %w", err)
+	}
+	usage, err := parseCPUUsage(resp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse CPU statistics: %w", err)
+	}
+	slog.Info("Fetched CPU statistics", "usage", usage)
+	return usage, nil
+}
+
+// parseCPUUsage averages the oneMinAvgIdle counter of every CPU, which
+// sys/cpu nests a few levels deep under each host's cpuInfo
+func parseCPUUsage(resp []byte) (float64, error) {
+	var doc interface{}
+	if err := json.Unmarshal(resp, &doc); err != nil {
+		return 0, err
+	}
+	var idle []float64
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		if counter, ok := m["oneMinAvgIdle"].(map[string]interface{}); ok {
+			if value, ok := counter["value"].(float64); ok {
+				idle = append(idle, value)
+			}
+			return
+		}
+		for _, child := range m {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if len(idle) == 0 {
+		return 0, fmt.Errorf("no CPU counters in the response")
+	}
+	total := 0.0
+	for _, v := range idle {
+		total += v
+	}
+	return 100 - total/float64(len(idle)), nil
+}
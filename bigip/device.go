@@ -0,0 +1,83 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// DeviceInfo is a device of the trust domain, as "list cm device" shows it:
+// its name, software and failover state
+type DeviceInfo struct {
+	Hostname string `json:"hostname"`
+	Version  string `json:"version"`
+	Build    string `json:"build"`
+	// Platform is the model, e.g. "BIG-IP Virtual Edition"
+	Platform string `json:"marketingName"`
+	// FailoverState is "active", "standby", "offline" or "forced-offline"
+	FailoverState string `json:"failoverState"`
+	ManagementIP  string `json:"managementIp"`
+	// Self is true for the device answering, false for its peers
+	Self bool `json:"-"`
+}
+
+// GetDevices retrieves the devices of the trust domain, the one answering
+// first; a standalone device knows only itself
+func (c *Client) GetDevices() ([]DeviceInfo, error) {
+	return cached(c, "/mgmt/tm/cm/device", c.fetchDevices)
+}
+
+func (c *Client) fetchDevices() ([]DeviceInfo, error) {
+	const endpoint = "/mgmt/tm/cm/device"
+	slog.Debug("Fetching devices", "endpoint", endpoint)
+
+	var resp []byte
+	err := c.withRetry("GetDevices", func() error {
+		var err error
+		resp, err = c.session().APICall(&bigip.APIRequest{
+			Method:      "GET",
+			URL:         "mgmt/tm/cm/device",
+			ContentType: "application/json",
+		})
+		return newAPIError(endpoint, resp, err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+	devices, err := parseDevices(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse devices: %w", err)
+	}
+	slog.Info("Fetched devices", "count", len(devices))
+	return devices, nil
+}
+
+// parseDevices reads the device collection, moving the device whose
+// selfDevice is "true" to the front
+func parseDevices(resp []byte) ([]DeviceInfo, error) {
+	var collection struct {
+		Items []struct {
+			DeviceInfo
+			SelfDevice string `json:"selfDevice"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(resp, &collection); err != nil {
+		return nil, err
+	}
+	if len(collection.Items) == 0 {
+		return nil, fmt.Errorf("no devices in the response")
+	}
+	var devices []DeviceInfo
+	for _, item := range collection.Items {
+		d := item.DeviceInfo
+		d.Self = item.SelfDevice == "true"
+		if d.Self {
+			devices = append([]DeviceInfo{d}, devices...)
+		} else {
+			devices = append(devices, d)
+		}
+	}
+	return devices, nil
+}
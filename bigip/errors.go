@@ -0,0 +1,140 @@
+package bigip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// APIError carries the HTTP status and endpoint of a failed iControl REST call.
+// The more specific error types below embed it so callers can branch with errors.As.
+type APIError struct {
+	StatusCode int
+	Endpoint   string
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s (HTTP %d from %s)", e.Err, e.StatusCode, e.Endpoint)
+	}
+	return fmt.Sprintf("%s (%s)", e.Err, e.Endpoint)
+}
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// AuthError is returned when the device rejects the credentials (HTTP 401/403)
+type AuthError struct{ APIError }
+
+func (e *AuthError) Error() string { return "authentication failed: " + e.APIError.Error() }
+
+// NotFoundError is returned when the endpoint or object doesn't exist (HTTP 404)
+type NotFoundError struct{ APIError }
+
+func (e *NotFoundError) Error() string { return "not found: " + e.APIError.Error() }
+
+// ModuleNotProvisionedError is returned when the endpoint belongs to a module
+// (ASM, GTM, AFM, ...) that isn't provisioned or licensed on the device
+type ModuleNotProvisionedError struct {
+	APIError
+	Module string
+}
+
+func (e *ModuleNotProvisionedError) Error() string {
+	return fmt.Sprintf("%s module not provisioned: %s", e.Module, e.APIError.Error())
+}
+
+// TimeoutError is returned when the request timed out before the device answered
+type TimeoutError struct{ APIError }
+
+func (e *TimeoutError) Error() string { return "request timed out: " + e.APIError.Error() }
+
+// modulePrefixes maps endpoint prefixes to the module that serves them
+var modulePrefixes = map[string]string{
+	"/mgmt/tm/asm/":      "ASM",
+	"/mgmt/tm/gtm/":      "GTM",
+	"/mgmt/tm/security/": "AFM",
+	"/mgmt/tm/apm/":      "APM",
+}
+
+// newAPIError converts an error from go-bigip into one of the typed errors.
+// body is the raw response, if any, which for iControl REST errors holds
+// {"code": ..., "message": ...}.
+func newAPIError(endpoint string, body []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	// Already typed further down the stack
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+
+	base := APIError{Endpoint: endpoint, Err: err}
+	var reqErr struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if len(body) > 0 && json.Unmarshal(body, &reqErr) == nil {
+		base.StatusCode = reqErr.Code
+	}
+
+	errLower := strings.ToLower(err.Error())
+	if base.StatusCode == 0 {
+		switch {
+		case strings.Contains(errLower, "unauthorized") || strings.Contains(errLower, "authentication failed") ||
+			strings.Contains(errLower, "authentication required") || strings.Contains(errLower, "x-f5-auth-token"):
+			// go-bigip drops the status code for typed helpers, so fall back to the message
+			base.StatusCode = 401
+		case strings.Contains(errLower, "not found"):
+			base.StatusCode = 404
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case base.StatusCode == 401 || base.StatusCode == 403:
+		return &AuthError{base}
+	case strings.Contains(errLower, "not provisioned") || strings.Contains(errLower, "not registered") ||
+		(base.StatusCode == 404 && moduleFor(endpoint) != ""):
+		return &ModuleNotProvisionedError{APIError: base, Module: moduleFor(endpoint)}
+	case base.StatusCode == 404:
+		return &NotFoundError{base}
+	case (errors.As(err, &netErr) && netErr.Timeout()) || strings.Contains(errLower, "timeout"):
+		return &TimeoutError{base}
+	}
+	return &base
+}
+
+// moduleFor returns the module that owns an endpoint, or "" for core LTM/sys endpoints
+func moduleFor(endpoint string) string {
+	path := "/" + strings.TrimPrefix(endpoint, "/")
+	for prefix, module := range modulePrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return module
+		}
+	}
+	return ""
+}
+
+// ObjectNotFoundError is returned when no object of a kind has the name
+type ObjectNotFoundError struct {
+	Kind string
+	Name string
+}
+
+func (e *ObjectNotFoundError) Error() string { return fmt.Sprintf("%s '%s' not found", e.Kind, e.Name) }
+
+// AmbiguousNameError is returned when several objects share a name, one in
+// each partition; Matches holds their full paths
+type AmbiguousNameError struct {
+	Kind    string
+	Name    string
+	Matches []string
+}
+
+func (e *AmbiguousNameError) Error() string {
+	return fmt.Sprintf("%d %ss are named '%s': %s", len(e.Matches), e.Kind, e.Name, strings.Join(e.Matches, ", "))
+}
@@ -0,0 +1,76 @@
+package bigip
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// expandQuery asks iControl REST to put each object's subcollections, such
+// as a pool's members or a virtual server's profiles and policies, in the
+// listing rather than a link to them, so a listing is one request rather
+// than one more for each object
+const expandQuery = "expandSubcollections=true"
+
+// subcollection is a listed object's reference to one of its subcollections,
+// with the subcollection's items when the listing was expanded. Items is
+// nil both for an empty subcollection and on devices that don't expand,
+// so a listing only counts as expanded if some object's items came with it.
+type subcollection[T any] struct {
+	Link  string `json:"link"`
+	Items []T    `json:"items"`
+}
+
+// getExpanded GETs the collection at endpoint with its subcollections
+// expanded, and returns its items undecoded so the caller can read both an
+// object and its subcollections from each
+func (c *Client) getExpanded(operation, endpoint string) ([]json.RawMessage, error) {
+	var collection struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	err := c.getCollection(operation, endpoint, expandQuery, &collection)
+	return collection.Items, err
+}
+
+// getCollection GETs the collection at endpoint with the query string given,
+// if any, and decodes it into collection
+func (c *Client) getCollection(operation, endpoint, query string, collection interface{}) error {
+	url := strings.TrimPrefix(endpoint, "/")
+	if query != "" {
+		url += "?" + query
+	}
+	return c.withRetry(operation, func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{
+			Method:      "GET",
+			URL:         url,
+			ContentType: "application/json",
+		})
+		if err != nil {
+			return newAPIError(endpoint, resp, err)
+		}
+		return json.Unmarshal(resp, collection)
+	})
+}
+
+// cachePoolMembers caches the members an expanded listing gave pool under
+// the keys GetPoolMembers looks them up by: the pool's full path, and its
+// name unless a pool in another partition has the same one. gen is the
+// cache generation the listing was fetched under.
+func (c *Client) cachePoolMembers(pool Pool, members []PoolMember, names map[string]int, gen uint64) {
+	c.cache.set("/mgmt/tm/ltm/pool/"+pool.FullPath+"/members", members, gen)
+	if names[pool.Name] == 1 {
+		c.cache.set("/mgmt/tm/ltm/pool/"+pool.Name+"/members", members, gen)
+	}
+}
+
+// cacheVirtualProfiles caches the profiles an expanded listing gave a
+// virtual server under the key GetVirtualProfiles looks them up by, as
+// cachePoolMembers does
+func (c *Client) cacheVirtualProfiles(virtual string, profiles []bigip.Profile, gen uint64) {
+	out := make([]VirtualProfile, len(profiles))
+	for i := range profiles {
+		out[i] = VirtualProfile{Profile: &profiles[i]}
+	}
+	c.cache.set("/mgmt/tm/ltm/virtual/"+objectPath(virtual)+"/profiles", out, gen)
+}
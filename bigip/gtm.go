@@ -0,0 +1,103 @@
+package bigip
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// TopologyRecord is a GTM topology record: clients whose local DNS server
+// matches LDNS are steered by Weight toward the destination Server names
+type TopologyRecord struct {
+	// LDNS is the client side, e.g. "continent EU", "region /Common/emea",
+	// "subnet 10.0.0.0/8" or "not country GB"
+	LDNS string
+	// Server is the destination side, e.g. "datacenter /Common/DC2" or
+	// "pool /Common/www_pool"
+	Server string
+	// Weight is the score the record gives a destination it matches
+	Weight int
+	// Order is the record's place when records aren't sorted by longest match
+	Order int
+}
+
+// Region is a GTM region: a named group of locations topology records can
+// refer to
+type Region struct {
+	Name     string
+	FullPath string
+	// Members are the locations in the region, in the same form as a
+	// record's LDNS side, e.g. "continent EU" or "not country GB"
+	Members []string
+}
+
+// Topology is the GTM topology: its records and regions, and whether
+// records are sorted by longest match or taken in their order
+type Topology struct {
+	Records      []TopologyRecord
+	Regions      []Region
+	LongestMatch bool
+}
+
+// GetTopology retrieves the GTM topology records and regions with the
+// longest match setting. Without GTM provisioned it returns a
+// ModuleNotProvisionedError.
+func (c *Client) GetTopology() (*Topology, error) {
+	return cached(c, "/mgmt/tm/gtm/topology", c.fetchTopology)
+}
+
+func (c *Client) fetchTopology() (*Topology, error) {
+	slog.Debug("Fetching GTM topology", "endpoint", "/mgmt/tm/gtm/topology")
+
+	var records struct {
+		Items []struct {
+			Name  string `json:"name"`
+			Score int    `json:"score"`
+			Order int    `json:"order"`
+		} `json:"items"`
+	}
+	if err := c.getCollection("GetTopology", "/mgmt/tm/gtm/topology", "", &records); err != nil {
+		return nil, fmt.Errorf("failed to get GTM topology records: %w", err)
+	}
+	var regions struct {
+		Items []struct {
+			Name          string `json:"name"`
+			FullPath      string `json:"fullPath"`
+			RegionMembers []struct {
+				Name string `json:"name"`
+			} `json:"regionMembers"`
+		} `json:"items"`
+	}
+	if err := c.getCollection("GetTopology", "/mgmt/tm/gtm/region", "", &regions); err != nil {
+		return nil, fmt.Errorf("failed to get GTM regions: %w", err)
+	}
+	var settings struct {
+		TopologyLongestMatch string `json:"topologyLongestMatch"`
+	}
+	if err := c.getCollection("GetTopology", "/mgmt/tm/gtm/global-settings/load-balancing", "", &settings); err != nil {
+		return nil, fmt.Errorf("failed to get GTM load balancing settings: %w", err)
+	}
+
+	// Longest match is on unless turned off
+	topology := &Topology{LongestMatch: settings.TopologyLongestMatch != "no"}
+	for _, item := range records.Items {
+		ldns, server := parseTopologyName(item.Name)
+		topology.Records = append(topology.Records, TopologyRecord{LDNS: ldns, Server: server, Weight: item.Score, Order: item.Order})
+	}
+	for _, item := range regions.Items {
+		region := Region{Name: item.Name, FullPath: item.FullPath}
+		for _, m := range item.RegionMembers {
+			region.Members = append(region.Members, m.Name)
+		}
+		topology.Regions = append(topology.Regions, region)
+	}
+	slog.Info("Fetched GTM topology", "records", len(topology.Records), "regions", len(topology.Regions))
+	return topology, nil
+}
+
+// parseTopologyName splits a record's name, "ldns: continent EU server:
+// datacenter /Common/DC2", into its two sides
+func parseTopologyName(name string) (ldns, server string) {
+	ldns, server, _ = strings.Cut(strings.TrimPrefix(name, "ldns: "), " server: ")
+	return strings.TrimSpace(ldns), strings.TrimSpace(server)
+}
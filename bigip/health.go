@@ -0,0 +1,96 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// HealthCheck is the result of a single setup/diagnostic check
+type HealthCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// CheckHealth verifies, in order, that the management address is reachable,
+// the credentials are accepted and the ASM module answers. Later checks are
+// skipped when an earlier one fails since they can't succeed.
+func (c *Client) CheckHealth() []HealthCheck {
+	var checks []HealthCheck
+
+	addr := c.Host
+	if u, err := url.Parse(c.Host); err == nil && u.Host != "" {
+		addr = u.Host
+	}
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return append(checks,
+			HealthCheck{Name: "Reachability", Detail: fmt.Sprintf("cannot open TCP connection to %s: %v", addr, err)},
+			HealthCheck{Name: "Credentials", Detail: "skipped (device unreachable)"},
+			HealthCheck{Name: "ASM module", Detail: "skipped (device unreachable)"},
+		)
+	}
+	conn.Close()
+	checks = append(checks, HealthCheck{Name: "Reachability", Passed: true, Detail: fmt.Sprintf("TCP connection to %s succeeded", addr)})
+
+	if err := c.login(); err != nil {
+		return append(checks,
+			HealthCheck{Name: "Credentials", Detail: fmt.Sprintf("token login failed: %v", err)},
+			HealthCheck{Name: "ASM module", Detail: "skipped (not authenticated)"},
+		)
+	}
+	resp, err := c.session().APICall(&bigip.APIRequest{Method: "GET", URL: "mgmt/tm/sys/version", ContentType: "application/json"})
+	if err != nil {
+		err = newAPIError("/mgmt/tm/sys/version", resp, err)
+		return append(checks,
+			HealthCheck{Name: "Credentials", Detail: fmt.Sprintf("user %s could not read /mgmt/tm/sys/version: %v", c.Username, err)},
+			HealthCheck{Name: "ASM module", Detail: "skipped (not authenticated)"},
+		)
+	}
+	checks = append(checks, HealthCheck{Name: "Credentials", Passed: true, Detail: fmt.Sprintf("authenticated as %s (BIG-IP %s)", c.Username, versionFrom(resp))})
+
+	resp, err = c.session().APICall(&bigip.APIRequest{Method: "GET", URL: "mgmt/tm/asm/policies?$top=1", ContentType: "application/json"})
+	if err != nil {
+		err = newAPIError("/mgmt/tm/asm/policies", resp, err)
+		return append(checks, HealthCheck{Name: "ASM module", Detail: fmt.Sprintf("WAF policies unavailable: %v", err)})
+	}
+	return append(checks, HealthCheck{Name: "ASM module", Passed: true, Detail: "WAF policy endpoint is available"})
+}
+
+// versionFrom extracts the version string from a /mgmt/tm/sys/version response
+func versionFrom(resp []byte) string {
+	var version struct {
+		Entries map[string]struct {
+			NestedStats struct {
+				Entries map[string]struct {
+					Description string `json:"description"`
+				} `json:"entries"`
+			} `json:"nestedStats"`
+		} `json:"entries"`
+	}
+	if json.Unmarshal(resp, &version) == nil {
+		for _, entry := range version.Entries {
+			if v, ok := entry.NestedStats.Entries["Version"]; ok {
+				return v.Description
+			}
+		}
+	}
+	return "version unknown"
+}
+
+// CheckHealth reports success for every check in demo mode
+func (m *MockClient) CheckHealth() []HealthCheck {
+	if err := m.record("CheckHealth"); err != nil {
+		return []HealthCheck{{Name: "Reachability", Detail: err.Error()}}
+	}
+	return []HealthCheck{
+		{Name: "Reachability", Passed: true, Detail: "demo mode (no device)"},
+		{Name: "Credentials", Passed: true, Detail: "demo mode (no device)"},
+		{Name: "ASM module", Passed: true, Detail: "demo mode (no device)"},
+	}
+}
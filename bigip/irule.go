@@ -0,0 +1,75 @@
+package bigip
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// IRule represents a BIG-IP LTM iRule; Rule holds its TCL source
+type IRule struct {
+	*bigip.IRule
+}
+
+// objectPath converts a name or /Partition/name path into the form used in
+// iControl REST URLs (~Partition~name)
+func objectPath(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return strings.ReplaceAll(name, "/", "~")
+	}
+	return name
+}
+
+// GetIRule retrieves a single iRule, including its TCL source
+func (c *Client) GetIRule(name string) (*IRule, error) {
+	return cached(c, "/mgmt/tm/ltm/rule/"+objectPath(name), func() (*IRule, error) {
+		return c.fetchIRule(name)
+	})
+}
+
+func (c *Client) fetchIRule(name string) (*IRule, error) {
+	endpoint := "/mgmt/tm/ltm/rule/" + objectPath(name)
+	slog.Debug("Fetching iRule", "endpoint", endpoint, "rule", name)
+
+	var rule *bigip.IRule
+	err := c.withRetry("GetIRule", func() error {
+		var err error
+		rule, err = c.session().IRule(objectPath(name))
+		return newAPIError(endpoint, nil, err)
+	})
+	var notFound *NotFoundError
+	if rule == nil && (err == nil || errors.As(err, &notFound)) {
+		return nil, fmt.Errorf("iRule '%s' not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get iRule: %w", err)
+	}
+	slog.Info("Fetched iRule", "rule", rule.FullPath, "bytes", len(rule.Rule))
+	return &IRule{IRule: rule}, nil
+}
+
+// CreateIRule uploads a new iRule. It is not retried: a create that timed
+// out may still have been applied. Existing iRules are never overwritten.
+func (c *Client) CreateIRule(name, definition string) error {
+	if err := c.Connect(); err != nil {
+		return err
+	}
+	const endpoint = "/mgmt/tm/ltm/rule"
+	slog.Info("Creating iRule", "endpoint", endpoint, "rule", name, "bytes", len(definition))
+
+	err := c.attempt("CreateIRule", func() error {
+		return newAPIError(endpoint, nil, c.session().CreateIRule(name, definition))
+	})()
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "already exists") {
+			return fmt.Errorf("iRule '%s' already exists on the BIG-IP; choose another name", name)
+		}
+		return fmt.Errorf("failed to create iRule: %w", err)
+	}
+	// Listings that include iRules must show the new one
+	c.ClearCache()
+	return nil
+}
@@ -0,0 +1,62 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// GetLogLines retrieves the last n lines of the LTM log (/var/log/ltm), where
+// monitor and pool state changes are recorded, oldest first
+func (c *Client) GetLogLines(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	endpoint := "/mgmt/tm/sys/log/ltm/stats"
+	return cached(c, fmt.Sprintf("%s?lines=%d", endpoint, n), func() ([]string, error) {
+		slog.Debug("Fetching log lines", "endpoint", endpoint, "lines", n)
+		var resp []byte
+		err := c.withRetry("GetLogLines", func() error {
+			var err error
+			resp, err = c.session().APICall(&bigip.APIRequest{
+				Method:      "GET",
+				URL:         fmt.Sprintf("mgmt/tm/sys/log/ltm/stats?options=lines,%d", n),
+				ContentType: "application/json",
+			})
+			return newAPIError(endpoint, resp, err)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get log lines: %w", err)
+		}
+		lines, err := parseLogLines(resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse log lines: %w", err)
+		}
+		return lines, nil
+	})
+}
+
+// parseLogLines reads the output of "show sys log ltm", which iControl REST
+// returns as raw text under apiRawValues, dropping its header
+func parseLogLines(resp []byte) ([]string, error) {
+	var raw struct {
+		APIRawValues struct {
+			APIAnonymous string `json:"apiAnonymous"`
+		} `json:"apiRawValues"`
+	}
+	if err := json.Unmarshal(resp, &raw); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(raw.APIRawValues.APIAnonymous, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "Sys::Log" || strings.Trim(line, "-") == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
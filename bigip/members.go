@@ -0,0 +1,53 @@
+package bigip
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// PoolMember is a pool member with its monitor and monitored state
+type PoolMember struct {
+	*bigip.PoolMember
+}
+
+// MemberPaths returns the full paths of members
+func MemberPaths(members []PoolMember) []string {
+	var paths []string
+	for _, m := range members {
+		paths = append(paths, m.FullPath)
+	}
+	return paths
+}
+
+// GetPoolMembers retrieves the members of a pool, by name or full path, with
+// their state
+func (c *Client) GetPoolMembers(pool string) ([]PoolMember, error) {
+	return cached(c, "/mgmt/tm/ltm/pool/"+pool+"/members", func() ([]PoolMember, error) {
+		return c.fetchPoolMembers(pool)
+	})
+}
+
+func (c *Client) fetchPoolMembers(pool string) ([]PoolMember, error) {
+	endpoint := "/mgmt/tm/ltm/pool/" + pool + "/members"
+	slog.Debug("Fetching pool members", "endpoint", endpoint)
+
+	var members *bigip.PoolMembers
+	err := c.withRetry("GetPoolMembers", func() error {
+		var err error
+		members, err = c.session().PoolMembers(pool)
+		return newAPIError(endpoint, nil, err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members of pool %s: %w", pool, err)
+	}
+
+	var out []PoolMember
+	if members != nil {
+		for i := range members.PoolMembers {
+			out = append(out, PoolMember{PoolMember: &members.PoolMembers[i]})
+		}
+	}
+	return out, nil
+}
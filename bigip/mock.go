@@ -0,0 +1,615 @@
+package bigip
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// MockClient is an in-memory stand-in for Client, used by demo mode and by
+// anything that needs to exercise the chat flow without a real BIG-IP
+type MockClient struct {
+	VirtualServers []VirtualServer
+	Pools          []Pool
+	PoolMembers    map[string][]string
+	Nodes          []Node
+	WAFPolicies    []*WAFPolicy
+	IRules         []IRule
+	Certificates   []Certificate
+	SyncStatus     SyncStatus
+	// Devices is the trust domain, the device answering first
+	Devices []DeviceInfo
+	// CPUUsage is the busy percentage GetCPUUsage reports
+	CPUUsage float64
+	// Profiles holds the profiles of each type, e.g. "http"
+	Profiles map[string][]Profile
+	// SecurityProfiles holds the security profiles of each type, "dos" or
+	// "bot-defense"; a type left out isn't provisioned
+	SecurityProfiles map[string][]Profile
+	// VirtualProfiles holds the profiles attached to each virtual server
+	// by full path
+	VirtualProfiles map[string][]VirtualProfile
+	// Stats holds the counters of each kind ("virtual", "pool" or "node")
+	// by full path
+	Stats map[string]map[string]ObjectStats
+	// Declarations holds the AS3 declaration deployed for each tenant
+	Declarations map[string]string
+	// Logs holds the LTM log, oldest line first
+	Logs []string
+	// Violations holds the ASM request log's blocked and alerted requests,
+	// newest first
+	Violations []Violation
+	// OWASP holds the OWASP Top 10 compliance of each WAF policy by full
+	// path; a policy left out answers as a version without the dashboard
+	OWASP map[string]*OWASPCompliance
+	// Topology is the GTM topology; nil when GTM isn't provisioned
+	Topology *Topology
+	// FirewallLists holds the AFM address and port lists by kind; nil when
+	// AFM isn't provisioned
+	FirewallLists map[string][]FirewallList
+
+	// Err, when set, is returned from every call to simulate device failures
+	Err error
+	// Calls counts invocations per method name
+	Calls map[string]int
+	// callsMu guards Calls, for the callers that read several kinds at once
+	callsMu sync.Mutex
+}
+
+// NewMockClient returns a mock populated with a small demo configuration
+func NewMockClient() *MockClient {
+	return &MockClient{
+		VirtualServers: []VirtualServer{
+			{VirtualServer: &bigip.VirtualServer{Name: "vs_app1", Partition: "Common", FullPath: "/Common/vs_app1", Destination: "/Common/10.1.10.80:443", Pool: "/Common/web_pool", Enabled: true, Description: "Customer portal"}},
+			{VirtualServer: &bigip.VirtualServer{Name: "vs_api", Partition: "Common", FullPath: "/Common/vs_api", Destination: "/Common/10.1.10.90:443", Pool: "/Common/api_pool", Enabled: true}},
+			{VirtualServer: &bigip.VirtualServer{Name: "vs_legacy", Partition: "Common", FullPath: "/Common/vs_legacy", Destination: "/Common/10.1.10.99:80", Pool: "/Common/legacy_pool", Disabled: true}},
+		},
+		Pools: []Pool{
+			{Pool: &bigip.Pool{Name: "web_pool", Partition: "Common", FullPath: "/Common/web_pool", LoadBalancingMode: "round-robin", Monitor: "/Common/http"}},
+			{Pool: &bigip.Pool{Name: "api_pool", Partition: "Common", FullPath: "/Common/api_pool", LoadBalancingMode: "least-connections-member", Monitor: "/Common/tcp"}},
+			{Pool: &bigip.Pool{Name: "legacy_pool", Partition: "Common", FullPath: "/Common/legacy_pool", LoadBalancingMode: "round-robin"}},
+		},
+		PoolMembers: map[string][]string{
+			"web_pool": {"/Common/web1:80", "/Common/web2:80"},
+			"api_pool": {"/Common/api1:8080"},
+		},
+		Nodes: []Node{
+			{Node: &bigip.Node{Name: "web1", Partition: "Common", FullPath: "/Common/web1", Address: "10.1.20.11", State: "up", Session: "monitor-enabled"}},
+			{Node: &bigip.Node{Name: "web2", Partition: "Common", FullPath: "/Common/web2", Address: "10.1.20.12", State: "down", Session: "monitor-enabled"}},
+			{Node: &bigip.Node{Name: "api1", Partition: "Common", FullPath: "/Common/api1", Address: "10.1.20.21", State: "up", Session: "monitor-enabled"}},
+		},
+		WAFPolicies: []*WAFPolicy{
+			{Name: "VS_WAF", FullPath: "/Common/VS_WAF", ID: "demo-vs-waf", Active: true, Type: "security", EnforcementMode: "blocking", VirtualServers: []string{"/Common/vs_api"}, Description: "API protection"},
+			{Name: "portal_policy", FullPath: "/Common/portal_policy", ID: "demo-portal", Active: false, Type: "security", EnforcementMode: "transparent", SignatureStaging: true,
+				VersionDatetime: time.Now().AddDate(0, 0, -75).UTC().Format(time.RFC3339)},
+		},
+		IRules: []IRule{
+			{IRule: &bigip.IRule{Name: "http_to_https", Partition: "Common", FullPath: "/Common/http_to_https",
+				Rule: "when HTTP_REQUEST {\n    HTTP::redirect https://[getfield [HTTP::host] \":\" 1][HTTP::uri]\n}"}},
+		},
+		Certificates: []Certificate{
+			{Certificate: &bigip.Certificate{Name: "default.crt", Partition: "Common", FullPath: "/Common/default.crt", Subject: "CN=localhost.localdomain",
+				Issuer: "CN=localhost.localdomain", KeyType: "rsa-private", CertificateKeySize: 2048, ExpirationDate: time.Now().AddDate(8, 0, 0).Unix()}},
+			{Certificate: &bigip.Certificate{Name: "portal.example.com.crt", Partition: "Common", FullPath: "/Common/portal.example.com.crt", Subject: "CN=portal.example.com",
+				Issuer: "CN=Example Issuing CA", KeyType: "rsa-private", CertificateKeySize: 2048, ExpirationDate: time.Now().AddDate(0, 0, 19).Unix()}},
+			{Certificate: &bigip.Certificate{Name: "legacy.example.com.crt", Partition: "Common", FullPath: "/Common/legacy.example.com.crt", Subject: "CN=legacy.example.com",
+				Issuer: "CN=Example Issuing CA", KeyType: "rsa-private", CertificateKeySize: 1024, ExpirationDate: time.Now().AddDate(0, 0, -3).Unix()}},
+		},
+		CPUUsage:   23.5,
+		SyncStatus: SyncStatus{Status: "In Sync", Color: "green", Mode: "high-availability", Summary: "All devices in the device group are in sync"},
+		Devices: []DeviceInfo{
+			{Hostname: "bigip1.demo.local", Version: "17.1.1.3", Build: "0.0.5", Platform: "BIG-IP Virtual Edition", FailoverState: "active", ManagementIP: "192.0.2.11", Self: true},
+			{Hostname: "bigip2.demo.local", Version: "17.1.1.3", Build: "0.0.5", Platform: "BIG-IP Virtual Edition", FailoverState: "standby", ManagementIP: "192.0.2.12"},
+		},
+		Profiles: map[string][]Profile{
+			"http": {
+				{"name": "http", "fullPath": "/Common/http", "insertXforwardedFor": "disabled", "serverAgentName": "BigIP", "redirectRewrite": "none"},
+				{"name": "http_xff", "fullPath": "/Common/http_xff", "defaultsFrom": "/Common/http", "insertXforwardedFor": "enabled", "serverAgentName": "BigIP", "redirectRewrite": "matching"},
+			},
+			"client-ssl": {
+				{"name": "clientssl", "fullPath": "/Common/clientssl", "tmOptions": []interface{}{"dont-insert-empty-fragments", "no-tlsv1.3"}, "ciphers": "DEFAULT", "cert": "/Common/default.crt"},
+				{"name": "portal_clientssl", "fullPath": "/Common/portal_clientssl", "defaultsFrom": "/Common/clientssl", "tmOptions": []interface{}{"dont-insert-empty-fragments", "no-tlsv1", "no-tlsv1.1"}, "ciphers": "ECDHE:!SSLv3:!TLSv1", "cert": "/Common/portal.example.com.crt"},
+			},
+		},
+		SecurityProfiles: map[string][]Profile{
+			"dos": {
+				{"name": "dos", "fullPath": "/Common/dos"},
+				{"name": "api_dos", "fullPath": "/Common/api_dos", "defaultsFrom": "/Common/dos"},
+			},
+		},
+		VirtualProfiles: map[string][]VirtualProfile{
+			"/Common/vs_app1": {
+				{Profile: &bigip.Profile{Name: "http", Partition: "Common", FullPath: "/Common/http", Context: "all"}},
+				{Profile: &bigip.Profile{Name: "portal_clientssl", Partition: "Common", FullPath: "/Common/portal_clientssl", Context: "clientside"}},
+				{Profile: &bigip.Profile{Name: "tcp", Partition: "Common", FullPath: "/Common/tcp", Context: "all"}},
+			},
+			"/Common/vs_api": {
+				{Profile: &bigip.Profile{Name: "http", Partition: "Common", FullPath: "/Common/http", Context: "all"}},
+				{Profile: &bigip.Profile{Name: "clientssl", Partition: "Common", FullPath: "/Common/clientssl", Context: "clientside"}},
+				{Profile: &bigip.Profile{Name: "api_dos", Partition: "Common", FullPath: "/Common/api_dos", Context: "all"}},
+				{Profile: &bigip.Profile{Name: "tcp", Partition: "Common", FullPath: "/Common/tcp", Context: "all"}},
+			},
+			"/Common/vs_legacy": {
+				{Profile: &bigip.Profile{Name: "http", Partition: "Common", FullPath: "/Common/http", Context: "all"}},
+				{Profile: &bigip.Profile{Name: "tcp", Partition: "Common", FullPath: "/Common/tcp", Context: "all"}},
+			},
+		},
+		Stats: map[string]map[string]ObjectStats{
+			"virtual": {
+				"/Common/vs_app1":   {CurrentConnections: 42, TotalConnections: 18230, Availability: "available"},
+				"/Common/vs_api":    {CurrentConnections: 120, TotalConnections: 96411, Availability: "available"},
+				"/Common/vs_legacy": {Availability: "unknown"},
+			},
+			"pool": {
+				"/Common/web_pool":    {CurrentConnections: 40, TotalConnections: 18002, Availability: "available"},
+				"/Common/api_pool":    {CurrentConnections: 118, TotalConnections: 96100, Availability: "available"},
+				"/Common/legacy_pool": {Availability: "unknown"},
+			},
+			"node": {
+				"/Common/web1": {CurrentConnections: 40, TotalConnections: 12001, Availability: "available"},
+				"/Common/web2": {TotalConnections: 6001, Availability: "offline"},
+				"/Common/api1": {CurrentConnections: 118, TotalConnections: 96100, Availability: "available"},
+			},
+		},
+		Declarations: make(map[string]string),
+		Violations:   demoViolations(time.Now()),
+		OWASP: map[string]*OWASPCompliance{
+			// VS_WAF blocks and covers most of the list
+			"/Common/VS_WAF": demoOWASP("/Common/VS_WAF", [10][2]int{{3, 4}, {1, 1}, {5, 5}, {2, 2}, {3, 4}, {1, 2}, {2, 2}, {1, 1}, {1, 1}, {0, 1}}),
+			// portal_policy is transparent with its signatures in staging
+			"/Common/portal_policy": demoOWASP("/Common/portal_policy", [10][2]int{{1, 4}, {0, 1}, {2, 5}, {1, 2}, {1, 4}, {0, 2}, {1, 2}, {0, 1}, {1, 1}, {0, 1}}),
+		},
+		// EMEA goes to DC2 except the UK, which stays on DC1 with the Americas
+		Topology: &Topology{
+			Records: []TopologyRecord{
+				{LDNS: "subnet 10.0.0.0/8", Server: "datacenter /Common/DC1", Weight: 300, Order: 1},
+				{LDNS: "region /Common/emea", Server: "datacenter /Common/DC2", Weight: 200, Order: 2},
+				{LDNS: "country GB", Server: "datacenter /Common/DC1", Weight: 250, Order: 3},
+				{LDNS: "continent NA", Server: "datacenter /Common/DC1", Weight: 200, Order: 4},
+				{LDNS: "continent EU", Server: "datacenter /Common/DC1", Weight: 100, Order: 5},
+				{LDNS: "not continent NA", Server: "datacenter /Common/DC2", Weight: 50, Order: 6},
+			},
+			Regions: []Region{
+				{Name: "emea", FullPath: "/Common/emea", Members: []string{"continent EU", "continent AF", "country AE"}},
+			},
+			LongestMatch: true,
+		},
+		FirewallLists: map[string][]FirewallList{
+			"address": {
+				{Kind: "address", Name: "blocked_countries", Partition: "Common", FullPath: "/Common/blocked_countries", Description: "Embargoed countries",
+					Entries: []string{"geo KP", "geo IR", "geo SY", "geo CU"}},
+				{Kind: "address", Name: "blocked_ips", Partition: "Common", FullPath: "/Common/blocked_ips",
+					Entries: []string{"203.0.113.7", "203.0.113.8"}},
+				{Kind: "address", Name: "trusted_admins", Partition: "Common", FullPath: "/Common/trusted_admins", Description: "Management access",
+					Entries: []string{"198.51.100.0/28", "fqdn bastion.example.com"}},
+			},
+			"port": {
+				{Kind: "port", Name: "admin_ports", Partition: "Common", FullPath: "/Common/admin_ports", Entries: []string{"22", "8443"}},
+				{Kind: "port", Name: "web_ports", Partition: "Common", FullPath: "/Common/web_ports", Entries: []string{"80", "443", "8080-8090"}},
+			},
+		},
+		Logs: []string{
+			"Oct 17 09:12:03 bigip1 notice mcpd[5120]: 01070638:5: Pool /Common/web_pool member /Common/web2:80 monitor status down. [ /Common/http: down; last error: /Common/http: Unable to connect; No successful responses received before deadline. @2026/10/17 09:12:03. ]  [ was up for 2hrs:4mins:12sec ]",
+			"Oct 17 09:12:03 bigip1 notice mcpd[5120]: 01070640:5: Node /Common/web2 address 10.1.20.12 monitor status down. [ /Common/icmp: down ]  [ was up for 2hrs:4mins:12sec ]",
+			"Oct 17 09:20:44 bigip1 err tmm1[11304]: 01010007:3: Per-invocation log rate exceeded; throttling.",
+			"Oct 17 09:31:02 bigip1 warning tmm[11304]: 011e0003:4: Aggressive mode sweeper: /Common/default-eviction-policy (70000000002d6) (global memory) 9 Connections killed",
+		},
+		Calls: make(map[string]int),
+	}
+}
+
+func (m *MockClient) record(method string) error {
+	m.callsMu.Lock()
+	defer m.callsMu.Unlock()
+	if m.Calls == nil {
+		m.Calls = make(map[string]int)
+	}
+	m.Calls[method]++
+	return m.Err
+}
+
+// GetVirtualServers returns the mock virtual servers
+func (m *MockClient) GetVirtualServers() ([]VirtualServer, error) {
+	if err := m.record("GetVirtualServers"); err != nil {
+		return nil, err
+	}
+	return m.VirtualServers, nil
+}
+
+// GetPools returns the mock pools and their members
+func (m *MockClient) GetPools() ([]Pool, map[string][]PoolMember, error) {
+	if err := m.record("GetPools"); err != nil {
+		return nil, nil, err
+	}
+	members := make(map[string][]PoolMember, len(m.Pools))
+	for _, p := range m.Pools {
+		members[p.FullPath] = m.members(p.FullPath)
+	}
+	return m.Pools, members, nil
+}
+
+// GetPoolMembers returns a mock pool's members
+func (m *MockClient) GetPoolMembers(pool string) ([]PoolMember, error) {
+	if err := m.record("GetPoolMembers"); err != nil {
+		return nil, err
+	}
+	return m.members(pool), nil
+}
+
+// members returns a mock pool's members, each in the state of its node and
+// monitored by the pool's monitor
+func (m *MockClient) members(pool string) []PoolMember {
+	monitor := ""
+	for _, p := range m.Pools {
+		if p.Name == pool || p.FullPath == pool {
+			monitor, pool = p.Monitor, p.Name
+		}
+	}
+	var out []PoolMember
+	for _, fullPath := range m.PoolMembers[pool] {
+		member := &bigip.PoolMember{FullPath: fullPath, Name: fullPath[strings.LastIndex(fullPath, "/")+1:], Partition: "Common", Monitor: monitor, State: "unchecked"}
+		node := fullPath
+		if i := strings.LastIndex(fullPath, ":"); i >= 0 {
+			node = fullPath[:i]
+		}
+		for _, n := range m.Nodes {
+			if n.FullPath == node {
+				member.Address, member.State, member.Session = n.Address, n.State, n.Session
+			}
+		}
+		out = append(out, PoolMember{PoolMember: member})
+	}
+	return out
+}
+
+// GetNodes returns the mock nodes
+func (m *MockClient) GetNodes() ([]Node, error) {
+	if err := m.record("GetNodes"); err != nil {
+		return nil, err
+	}
+	return m.Nodes, nil
+}
+
+// GetWAFPolicies returns the mock WAF policies
+func (m *MockClient) GetWAFPolicies() ([]*WAFPolicy, error) {
+	if err := m.record("GetWAFPolicies"); err != nil {
+		return nil, err
+	}
+	return m.WAFPolicies, nil
+}
+
+// GetWAFPolicyDetails looks a mock policy up by name (case-insensitively)
+func (m *MockClient) GetWAFPolicyDetails(policyName string) (*WAFPolicy, error) {
+	if err := m.record("GetWAFPolicyDetails"); err != nil {
+		return nil, err
+	}
+	for _, p := range m.WAFPolicies {
+		if strings.EqualFold(p.Name, policyName) || strings.EqualFold(p.FullPath, policyName) {
+			return p, nil
+		}
+	}
+	return nil, &ObjectNotFoundError{Kind: "WAF policy", Name: policyName}
+}
+
+// GetProfiles returns the mock profiles of a type
+func (m *MockClient) GetProfiles(kind string) ([]Profile, error) {
+	if err := m.record("GetProfiles"); err != nil {
+		return nil, err
+	}
+	if profiles, ok := m.Profiles[kind]; ok {
+		return profiles, nil
+	}
+	return nil, fmt.Errorf("failed to get %s profiles: %w", kind, &NotFoundError{APIError{StatusCode: 404, Endpoint: "/mgmt/tm/ltm/profile/" + kind, Err: fmt.Errorf("no such profile type")}})
+}
+
+// GetSecurityProfiles returns the mock security profiles of a type
+func (m *MockClient) GetSecurityProfiles(kind string) ([]Profile, error) {
+	if err := m.record("GetSecurityProfiles"); err != nil {
+		return nil, err
+	}
+	if profiles, ok := m.SecurityProfiles[kind]; ok {
+		return profiles, nil
+	}
+	endpoint := "/mgmt/tm/security/" + kind + "/profile"
+	return nil, fmt.Errorf("failed to get %s profiles: %w", kind, &ModuleNotProvisionedError{APIError: APIError{StatusCode: 404, Endpoint: endpoint, Err: fmt.Errorf("not found")}, Module: moduleFor(endpoint)})
+}
+
+// GetVirtualProfiles returns the profiles attached to a mock virtual server
+func (m *MockClient) GetVirtualProfiles(virtual string) ([]VirtualProfile, error) {
+	if err := m.record("GetVirtualProfiles"); err != nil {
+		return nil, err
+	}
+	for _, v := range m.VirtualServers {
+		if v.Name == virtual || v.FullPath == virtual {
+			return m.VirtualProfiles[v.FullPath], nil
+		}
+	}
+	return nil, &ObjectNotFoundError{Kind: "virtual server", Name: virtual}
+}
+
+// GetStats returns the mock counters of a kind
+func (m *MockClient) GetStats(kind string) (map[string]ObjectStats, error) {
+	if err := m.record("GetStats"); err != nil {
+		return nil, err
+	}
+	if _, ok := statsSides[kind]; !ok {
+		return nil, fmt.Errorf("no statistics for %q", kind)
+	}
+	return m.Stats[kind], nil
+}
+
+// GetIRule looks a mock iRule up by name or full path
+func (m *MockClient) GetIRule(name string) (*IRule, error) {
+	if err := m.record("GetIRule"); err != nil {
+		return nil, err
+	}
+	for i := range m.IRules {
+		if m.IRules[i].Name == name || m.IRules[i].FullPath == name {
+			return &m.IRules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("iRule '%s' not found", name)
+}
+
+// CreateIRule adds an iRule to the mock configuration in /Common
+func (m *MockClient) CreateIRule(name, definition string) error {
+	if err := m.record("CreateIRule"); err != nil {
+		return err
+	}
+	for _, r := range m.IRules {
+		if r.Name == name {
+			return fmt.Errorf("iRule '%s' already exists on the BIG-IP; choose another name", name)
+		}
+	}
+	m.IRules = append(m.IRules, IRule{IRule: &bigip.IRule{Name: name, Partition: "Common", FullPath: "/Common/" + name, Rule: definition}})
+	return nil
+}
+
+// GetCertificates returns the mock certificates
+func (m *MockClient) GetCertificates() ([]Certificate, error) {
+	if err := m.record("GetCertificates"); err != nil {
+		return nil, err
+	}
+	return m.Certificates, nil
+}
+
+// GetSyncStatus returns the mock config sync state
+func (m *MockClient) GetSyncStatus() (*SyncStatus, error) {
+	if err := m.record("GetSyncStatus"); err != nil {
+		return nil, err
+	}
+	status := m.SyncStatus
+	return &status, nil
+}
+
+// GetDevices returns the mock trust domain
+func (m *MockClient) GetDevices() ([]DeviceInfo, error) {
+	if err := m.record("GetDevices"); err != nil {
+		return nil, err
+	}
+	return m.Devices, nil
+}
+
+// GetCPUUsage returns the mock CPU usage
+func (m *MockClient) GetCPUUsage() (float64, error) {
+	if err := m.record("GetCPUUsage"); err != nil {
+		return 0, err
+	}
+	return m.CPUUsage, nil
+}
+
+// TenantExists reports whether the tenant is Common or was deployed to the mock
+func (m *MockClient) TenantExists(name string) (bool, error) {
+	if err := m.record("TenantExists"); err != nil {
+		return false, err
+	}
+	_, ok := m.Declarations[name]
+	return ok || name == "Common", nil
+}
+
+// GetDeclaration returns the declaration deployed for a mock tenant
+func (m *MockClient) GetDeclaration(tenant string) (string, error) {
+	if err := m.record("GetDeclaration"); err != nil {
+		return "", err
+	}
+	return m.Declarations[tenant], nil
+}
+
+// DeployAS3 records the declaration against each of its tenants
+func (m *MockClient) DeployAS3(declaration string) ([]AS3Result, error) {
+	if err := m.record("DeployAS3"); err != nil {
+		return nil, err
+	}
+	tenants, err := AS3Tenants(declaration)
+	if err != nil {
+		return nil, err
+	}
+	if m.Declarations == nil {
+		m.Declarations = make(map[string]string)
+	}
+	results := make([]AS3Result, 0, len(tenants))
+	for _, t := range tenants {
+		m.Declarations[t] = declaration
+		results = append(results, AS3Result{Tenant: t, Code: 200, Message: "success"})
+	}
+	return results, nil
+}
+
+// CreateQkview pretends to collect a qkview
+func (m *MockClient) CreateQkview(name string) (string, error) {
+	if err := m.record("CreateQkview"); err != nil {
+		return "", err
+	}
+	return "demo-" + name, nil
+}
+
+// DownloadQkview writes a stand-in for the qkview: a demo device has
+// nothing to collect
+func (m *MockClient) DownloadQkview(name string, w io.Writer) (int64, error) {
+	if err := m.record("DownloadQkview"); err != nil {
+		return 0, err
+	}
+	n, err := io.WriteString(w, "chatf5 demo qkview "+name+"\n")
+	return int64(n), err
+}
+
+// DeleteQkview pretends to remove a qkview from the device
+func (m *MockClient) DeleteQkview(id string) error {
+	return m.record("DeleteQkview")
+}
+
+// GetLogLines returns the last n lines of the mock LTM log
+func (m *MockClient) GetLogLines(n int) ([]string, error) {
+	if err := m.record("GetLogLines"); err != nil {
+		return nil, err
+	}
+	if n < len(m.Logs) {
+		return m.Logs[len(m.Logs)-n:], nil
+	}
+	return m.Logs, nil
+}
+
+// GetViolations returns the latest n mock WAF violations
+func (m *MockClient) GetViolations(n int) ([]Violation, error) {
+	if err := m.record("GetViolations"); err != nil {
+		return nil, err
+	}
+	if n < len(m.Violations) {
+		return m.Violations[:n], nil
+	}
+	return m.Violations, nil
+}
+
+// demoViolations is a morning's WAF log on vs_api: a SQL injection run
+// against the login page from a few addresses, a scan for leaked
+// configuration files, and cross-site scripting signatures still in
+// staging, so only alerted on
+func demoViolations(now time.Time) []Violation {
+	var out []Violation
+	add := func(count int, ip, method, url, status, violation, attack string, rating int) {
+		for n := 0; n < count; n++ {
+			out = append(out, Violation{Policy: "/Common/VS_WAF", ClientIP: ip, Method: method, URL: url, Status: status,
+				Violations: []string{violation}, AttackTypes: []string{attack}, Rating: rating})
+		}
+	}
+	add(14, "203.0.113.7", "POST", "/login", "blocked", "Attack signature detected", "SQL-Injection", 5)
+	add(10, "203.0.113.8", "POST", "/login", "blocked", "Attack signature detected", "SQL-Injection", 5)
+	add(8, "198.51.100.23", "POST", "/login", "blocked", "Attack signature detected", "SQL-Injection", 5)
+	add(5, "192.0.2.44", "GET", "/.env", "blocked", "Illegal file type", "Predictable Resource Location", 4)
+	add(2, "192.0.2.44", "GET", "/config.php.bak", "blocked", "Illegal file type", "Predictable Resource Location", 4)
+	add(2, "198.51.100.61", "GET", "/search", "alerted", "Attack signature detected", "Cross Site Scripting (XSS)", 3)
+	add(1, "198.51.100.62", "GET", "/search", "alerted", "Attack signature detected", "Cross Site Scripting (XSS)", 3)
+	// Spread them over the last six hours, newest first
+	for n := range out {
+		out[n].Time = now.Add(-time.Duration(n*6*60/len(out)) * time.Minute).UTC().Truncate(time.Second)
+		out[n].SupportID = fmt.Sprintf("1844674407370955%04d", 1600-n)
+	}
+	return out
+}
+
+// GetOWASPCompliance returns a mock WAF policy's OWASP Top 10 compliance
+func (m *MockClient) GetOWASPCompliance(policy *WAFPolicy) (*OWASPCompliance, error) {
+	if err := m.record("GetOWASPCompliance"); err != nil {
+		return nil, err
+	}
+	if c, ok := m.OWASP[policy.FullPath]; ok {
+		return c, nil
+	}
+	endpoint := "/mgmt/tm/asm/policies/" + policy.ID + "/owasp-compliance"
+	return nil, fmt.Errorf("failed to get the OWASP compliance of %s: %w", policy.FullPath,
+		&ModuleNotProvisionedError{APIError: APIError{StatusCode: 404, Endpoint: endpoint, Err: fmt.Errorf("not found")}, Module: moduleFor(endpoint)})
+}
+
+// GetTopology returns the mock GTM topology
+func (m *MockClient) GetTopology() (*Topology, error) {
+	if err := m.record("GetTopology"); err != nil {
+		return nil, err
+	}
+	if m.Topology == nil {
+		endpoint := "/mgmt/tm/gtm/topology"
+		return nil, fmt.Errorf("failed to get GTM topology records: %w",
+			&ModuleNotProvisionedError{APIError: APIError{StatusCode: 404, Endpoint: endpoint, Err: fmt.Errorf("not found")}, Module: moduleFor(endpoint)})
+	}
+	return m.Topology, nil
+}
+
+// GetFirewallLists returns the mock AFM lists of a kind
+func (m *MockClient) GetFirewallLists(kind string) ([]FirewallList, error) {
+	if err := m.record("GetFirewallLists"); err != nil {
+		return nil, err
+	}
+	endpoint, err := firewallEndpoint(kind)
+	if err != nil {
+		return nil, err
+	}
+	if m.FirewallLists == nil {
+		return nil, fmt.Errorf("failed to get %s lists: %w", kind,
+			&ModuleNotProvisionedError{APIError: APIError{StatusCode: 404, Endpoint: endpoint, Err: fmt.Errorf("not found")}, Module: moduleFor(endpoint)})
+	}
+	return m.FirewallLists[kind], nil
+}
+
+// AppendToFirewallList adds entries to a mock AFM list, skipping those it
+// already holds
+func (m *MockClient) AppendToFirewallList(kind, fullPath string, entries []string) error {
+	if err := m.record("AppendToFirewallList"); err != nil {
+		return err
+	}
+	lists := m.FirewallLists[kind]
+	for n := range lists {
+		if lists[n].FullPath != fullPath {
+			continue
+		}
+		for _, e := range entries {
+			if !slices.Contains(lists[n].Entries, e) {
+				lists[n].Entries = append(lists[n].Entries, e)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to update %s list %s: %w", kind, fullPath, &ObjectNotFoundError{Kind: kind + " list", Name: fullPath})
+}
+
+// owaspTop10 is the OWASP Top 10 of 2021, in order
+var owaspTop10 = []string{
+	"Broken Access Control", "Cryptographic Failures", "Injection", "Insecure Design", "Security Misconfiguration",
+	"Vulnerable and Outdated Components", "Identification and Authentication Failures",
+	"Software and Data Integrity Failures", "Security Logging and Monitoring Failures", "Server-Side Request Forgery",
+}
+
+// demoOWASP scores a policy from how many of each category's security
+// controls comply, of how many there are
+func demoOWASP(policy string, controls [10][2]int) *OWASPCompliance {
+	c := &OWASPCompliance{Policy: policy}
+	compliant, total := 0, 0
+	for n, name := range owaspTop10 {
+		status := "partially-compliant"
+		switch controls[n][0] {
+		case controls[n][1]:
+			status = "compliant"
+		case 0:
+			status = "not-compliant"
+		}
+		c.Categories = append(c.Categories, OWASPCategory{ID: fmt.Sprintf("A%02d:2021", n+1), Name: name, Status: status,
+			Compliant: controls[n][0], Controls: controls[n][1]})
+		compliant += controls[n][0]
+		total += controls[n][1]
+	}
+	c.Score = 100 * compliant / total
+	return c
+}
+
+// ClearCache is a no-op; the mock has nothing cached
+func (m *MockClient) ClearCache() {
+	m.record("ClearCache")
+}
+
+// Account returns the demo device, signed in to as admin
+func (m *MockClient) Account() (host, user, authMethod string) {
+	return "demo", "admin", AuthBasic
+}
@@ -0,0 +1,32 @@
+package bigip
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// odataEq builds an escaped "$filter=field eq 'value'" query parameter.
+// Single quotes in the value are doubled as OData requires, and the whole
+// expression is URL-encoded so spaces, slashes and other special characters
+// in object names survive the trip.
+func odataEq(field, value string) string {
+	literal := "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	return "$filter=" + url.QueryEscape(fmt.Sprintf("%s eq %s", field, literal))
+}
+
+// odataNe builds an escaped "$filter=field ne 'value'" query parameter,
+// quoted and encoded as odataEq does
+func odataNe(field, value string) string {
+	literal := "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	return "$filter=" + url.QueryEscape(fmt.Sprintf("%s ne %s", field, literal))
+}
+
+// policyFilter looks a policy up by fullPath when given a /Partition/name
+// path and by name otherwise
+func policyFilter(policyName string) string {
+	if strings.HasPrefix(policyName, "/") {
+		return odataEq("fullPath", policyName)
+	}
+	return odataEq("name", policyName)
+}
@@ -0,0 +1,76 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// OWASPCompliance is how well a WAF policy covers the OWASP Top 10, as the
+// OWASP Compliance Dashboard scores it
+type OWASPCompliance struct {
+	// Policy is the policy's full path
+	Policy string
+	// Score is the percentage of the policy's security controls that comply
+	Score      int
+	Categories []OWASPCategory
+}
+
+// OWASPCategory is one of the OWASP Top 10 risks and how well a policy
+// guards against it
+type OWASPCategory struct {
+	// ID is the risk's place in the list, e.g. "A03:2021", and Name what it
+	// is, e.g. "Injection"
+	ID   string
+	Name string
+	// Status is "compliant", "partially-compliant" or "not-compliant"
+	Status string
+	// Compliant is how many of the category's Controls comply
+	Compliant int
+	Controls  int
+}
+
+// GetOWASPCompliance retrieves a WAF policy's OWASP Top 10 compliance.
+// Versions without the OWASP Compliance Dashboard answer 404, which comes
+// back as a ModuleNotProvisionedError like any other ASM endpoint's.
+func (c *Client) GetOWASPCompliance(policy *WAFPolicy) (*OWASPCompliance, error) {
+	endpoint := "/mgmt/tm/asm/policies/" + policy.ID + "/owasp-compliance"
+	return cached(c, endpoint, func() (*OWASPCompliance, error) {
+		slog.Debug("Fetching OWASP compliance", "endpoint", endpoint, "policy", policy.FullPath)
+		var raw struct {
+			ComplianceScore int `json:"complianceScore"`
+			Categories      []struct {
+				ID                string `json:"id"`
+				Name              string `json:"name"`
+				ComplianceStatus  string `json:"complianceStatus"`
+				CompliantControls int    `json:"compliantControlsCount"`
+				SecurityControls  int    `json:"securityControlsCount"`
+			} `json:"categories"`
+		}
+		var resp []byte
+		err := c.withRetry("GetOWASPCompliance", func() error {
+			var err error
+			resp, err = c.session().APICall(&bigip.APIRequest{
+				Method:      "GET",
+				URL:         endpoint[1:],
+				ContentType: "application/json",
+			})
+			return newAPIError(endpoint, resp, err)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the OWASP compliance of %s: %w", policy.FullPath, err)
+		}
+		if err := json.Unmarshal(resp, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse the OWASP compliance of %s: %w", policy.FullPath, err)
+		}
+		compliance := &OWASPCompliance{Policy: policy.FullPath, Score: raw.ComplianceScore}
+		for _, cat := range raw.Categories {
+			compliance.Categories = append(compliance.Categories, OWASPCategory{
+				ID: cat.ID, Name: cat.Name, Status: cat.ComplianceStatus, Compliant: cat.CompliantControls, Controls: cat.SecurityControls,
+			})
+		}
+		return compliance, nil
+	})
+}
@@ -0,0 +1,121 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// listPageSize is how many objects each request for a page of a listing
+// asks for
+const listPageSize = 500
+
+// readPages GETs the collection at endpoint a page at a time with $top and
+// $skip, handing each page's items to each with how many the collection
+// has, until each returns false or the last page is in. A device that
+// ignores $top sends the whole collection as the first page.
+func (c *Client) readPages(operation, endpoint, query string, each func(items []json.RawMessage, total int) (bool, error)) error {
+	for skip := 0; ; {
+		var page struct {
+			Items      []json.RawMessage `json:"items"`
+			TotalItems int               `json:"totalItems"`
+		}
+		params := fmt.Sprintf("$top=%d&$skip=%d", listPageSize, skip)
+		if query != "" {
+			params = query + "&" + params
+		}
+		if err := c.getCollection(operation, endpoint, params, &page); err != nil {
+			return err
+		}
+		read := skip + len(page.Items)
+		more, err := each(page.Items, max(page.TotalItems, read))
+		if err != nil || !more || len(page.Items) < listPageSize || read >= page.TotalItems {
+			return err
+		}
+		skip = read
+	}
+}
+
+// VirtualServerPages reads the virtual servers a page at a time, handing
+// each page to each with how many there are in all, until each returns
+// false. A listing that fits on one page, or is already cached, comes as a
+// single page, and is cached as GetVirtualServers' answer.
+func (c *Client) VirtualServerPages(each func(page []VirtualServer, total int) bool) error {
+	if v, ok := c.cache.get("/mgmt/tm/ltm/virtual"); ok {
+		vs := v.([]VirtualServer)
+		each(vs, len(vs))
+		return nil
+	}
+	gen := c.cache.gen()
+	err := c.readPages("GetVirtualServers", "/mgmt/tm/ltm/virtual", expandQuery, func(items []json.RawMessage, total int) (bool, error) {
+		whole := len(items) >= total
+		vs, _, err := c.parseVirtualServers(items, whole, gen)
+		if err != nil {
+			return false, err
+		}
+		if whole {
+			c.cache.set("/mgmt/tm/ltm/virtual", vs, gen)
+		}
+		return each(vs, total), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get virtual servers: %w", err)
+	}
+	return nil
+}
+
+// PoolPages reads the pools a page at a time, with each page's members by
+// the pool's full path, as VirtualServerPages does
+func (c *Client) PoolPages(each func(page []Pool, members map[string][]PoolMember, total int) bool) error {
+	if v, ok := c.cache.get("/mgmt/tm/ltm/pool"); ok {
+		listing := v.(poolListing)
+		each(listing.pools, listing.members, len(listing.pools))
+		return nil
+	}
+	gen := c.cache.gen()
+	err := c.readPages("GetPools", "/mgmt/tm/ltm/pool", expandQuery, func(items []json.RawMessage, total int) (bool, error) {
+		whole := len(items) >= total
+		pools, expanded, err := parsePools(items)
+		if err != nil {
+			return false, err
+		}
+		members := c.listPoolMembers(pools, expanded, whole, gen)
+		if whole {
+			c.cache.set("/mgmt/tm/ltm/pool", poolListing{pools: pools, members: members}, gen)
+		}
+		return each(pools, members, total), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get pools: %w", err)
+	}
+	return nil
+}
+
+// NodePages reads the nodes a page at a time, as VirtualServerPages does
+func (c *Client) NodePages(each func(page []Node, total int) bool) error {
+	if v, ok := c.cache.get("/mgmt/tm/ltm/node"); ok {
+		nodes := v.([]Node)
+		each(nodes, len(nodes))
+		return nil
+	}
+	gen := c.cache.gen()
+	err := c.readPages("GetNodes", "/mgmt/tm/ltm/node", "", func(items []json.RawMessage, total int) (bool, error) {
+		nodes := make([]Node, len(items))
+		for n, raw := range items {
+			var node bigip.Node
+			if err := json.Unmarshal(raw, &node); err != nil {
+				return false, fmt.Errorf("JSON parsing error: %v", err)
+			}
+			nodes[n] = Node{Node: &node}
+		}
+		if len(items) >= total {
+			c.cache.set("/mgmt/tm/ltm/node", nodes, gen)
+		}
+		return each(nodes, total), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get nodes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// Profile is an LTM profile as iControl REST returns it. Its properties
+// depend on the profile type, so it is kept as a map.
+type Profile map[string]interface{}
+
+// Name returns the profile's name
+func (p Profile) Name() string { return fmt.Sprint(p["name"]) }
+
+// FullPath returns the profile's /Partition/name path
+func (p Profile) FullPath() string { return fmt.Sprint(p["fullPath"]) }
+
+// profileType matches tmsh profile types such as http, tcp or client-ssl
+var profileType = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// GetProfiles retrieves all profiles of a type, e.g. "http" or "client-ssl"
+func (c *Client) GetProfiles(kind string) ([]Profile, error) {
+	if !profileType.MatchString(kind) {
+		return nil, fmt.Errorf("invalid profile type %q", kind)
+	}
+	return cached(c, "/mgmt/tm/ltm/profile/"+kind, func() ([]Profile, error) {
+		return c.fetchProfiles(kind, "/mgmt/tm/ltm/profile/"+kind)
+	})
+}
+
+// GetSecurityProfiles retrieves all security profiles of a type, "dos" or
+// "bot-defense", which only exist when a security module is provisioned
+func (c *Client) GetSecurityProfiles(kind string) ([]Profile, error) {
+	if !profileType.MatchString(kind) {
+		return nil, fmt.Errorf("invalid profile type %q", kind)
+	}
+	endpoint := "/mgmt/tm/security/" + kind + "/profile"
+	return cached(c, endpoint, func() ([]Profile, error) {
+		return c.fetchProfiles(kind, endpoint)
+	})
+}
+
+func (c *Client) fetchProfiles(kind, endpoint string) ([]Profile, error) {
+	slog.Debug("Fetching profiles", "endpoint", endpoint)
+
+	var collection struct {
+		Items []Profile `json:"items"`
+	}
+	err := c.withRetry("GetProfiles", func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{
+			Method:      "GET",
+			URL:         strings.TrimPrefix(endpoint, "/"),
+			ContentType: "application/json",
+		})
+		if err != nil {
+			return newAPIError(endpoint, resp, err)
+		}
+		return json.Unmarshal(resp, &collection)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s profiles: %w", kind, err)
+	}
+	slog.Info("Fetched profiles", "type", kind, "count", len(collection.Items))
+	return collection.Items, nil
+}
+
+// VirtualProfile is a profile attached to a virtual server: its name and
+// the side of the connection it applies to (clientside, serverside or all).
+// Its type isn't given, so it is found by the profile listings it is in.
+type VirtualProfile struct {
+	*bigip.Profile
+}
+
+// GetVirtualProfiles retrieves the profiles attached to a virtual server,
+// by name or /Partition/name path
+func (c *Client) GetVirtualProfiles(virtual string) ([]VirtualProfile, error) {
+	return cached(c, "/mgmt/tm/ltm/virtual/"+objectPath(virtual)+"/profiles", func() ([]VirtualProfile, error) {
+		return c.fetchVirtualProfiles(virtual)
+	})
+}
+
+func (c *Client) fetchVirtualProfiles(virtual string) ([]VirtualProfile, error) {
+	endpoint := "/mgmt/tm/ltm/virtual/" + objectPath(virtual) + "/profiles"
+	slog.Debug("Fetching virtual server profiles", "endpoint", endpoint)
+
+	var profiles *bigip.Profiles
+	err := c.withRetry("GetVirtualProfiles", func() error {
+		var err error
+		profiles, err = c.session().VirtualServerProfiles(objectPath(virtual))
+		return newAPIError(endpoint, nil, err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the profiles of virtual server %s: %w", virtual, err)
+	}
+	var out []VirtualProfile
+	if profiles != nil {
+		for i := range profiles.Profiles {
+			out = append(out, VirtualProfile{Profile: &profiles.Profiles[i]})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,171 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// qkviewPollInterval is how often a qkview being generated is checked, and
+// qkviewTimeout how long to wait for it: collecting one takes minutes on a
+// busy device
+var (
+	qkviewPollInterval = 5 * time.Second
+	qkviewTimeout      = 20 * time.Minute
+)
+
+// qkviewChunk is the most the device returns of a download in one response
+const qkviewChunk = 1 << 20
+
+// qkviewTask is the body of a qkview generation task
+type qkviewTask struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failureReason"`
+}
+
+// CreateQkview has the device collect a qkview, its configuration, logs
+// and statistics for F5 support, named name, waits until it's ready to
+// download and returns the ID of the task that made it. Generating one
+// changes nothing, but loads the device's CPU for as long as it runs.
+func (c *Client) CreateQkview(name string) (string, error) {
+	if err := c.Connect(); err != nil {
+		return "", err
+	}
+	const endpoint = "/mgmt/cm/autodeploy/qkview"
+	slog.Info("Generating qkview", "endpoint", endpoint, "name", name)
+	body, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return "", err
+	}
+
+	// Not retried: a POST that timed out may have started a qkview anyway
+	var task qkviewTask
+	err = c.attempt("CreateQkview", func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{Method: "POST", URL: strings.TrimPrefix(endpoint, "/"), Body: string(body), ContentType: "application/json"})
+		if err != nil {
+			return newAPIError(endpoint, resp, err)
+		}
+		return json.Unmarshal(resp, &task)
+	})()
+	if err != nil {
+		return "", fmt.Errorf("failed to start the qkview: %w", err)
+	}
+
+	taskEndpoint := endpoint + "/" + url.PathEscape(task.ID)
+	deadline := time.Now().Add(qkviewTimeout)
+	for task.Status != "SUCCEEDED" {
+		switch {
+		case task.Status == "FAILED":
+			return task.ID, fmt.Errorf("the device couldn't generate the qkview: %s", task.FailureReason)
+		case time.Now().After(deadline):
+			return task.ID, fmt.Errorf("qkview %s still being generated after %s; check %s on the device", name, qkviewTimeout, taskEndpoint)
+		}
+		slog.Debug("qkview in progress", "name", name, "status", task.Status)
+		time.Sleep(qkviewPollInterval)
+		err := c.withRetry("QkviewTask", func() error {
+			resp, err := c.session().APICall(&bigip.APIRequest{Method: "GET", URL: strings.TrimPrefix(taskEndpoint, "/"), ContentType: "application/json"})
+			if err != nil {
+				return newAPIError(taskEndpoint, resp, err)
+			}
+			return json.Unmarshal(resp, &task)
+		})
+		if err != nil {
+			return task.ID, fmt.Errorf("failed to check qkview %s: %w", name, err)
+		}
+	}
+	slog.Info("Generated qkview", "name", name, "task", task.ID)
+	return task.ID, nil
+}
+
+// DownloadQkview copies the qkview generated with name to w, a chunk at a
+// time, and returns its size
+func (c *Client) DownloadQkview(name string, w io.Writer) (int64, error) {
+	endpoint := "/mgmt/cm/autodeploy/qkview-download/" + url.PathEscape(name)
+	var written, size int64
+	for size == 0 || written < size {
+		// Each chunk is retried on its own, so a blip doesn't restart the
+		// download; a chunk is only written once it has arrived whole
+		var chunk []byte
+		err := c.withRetry("DownloadQkview", func() error {
+			var err error
+			chunk, size, err = c.downloadChunk(endpoint, written, size)
+			return err
+		})
+		if err != nil {
+			return written, fmt.Errorf("failed to download qkview %s: %w", name, err)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return written, err
+		}
+		written += int64(len(chunk))
+	}
+	slog.Info("Downloaded qkview", "name", name, "bytes", written)
+	return written, nil
+}
+
+// downloadChunk fetches the bytes of a file transfer from start, up to
+// qkviewChunk of them, with the file's size. The device takes the range
+// in Content-Range, not Range, and answers with the same header.
+func (c *Client) downloadChunk(endpoint string, start, size int64) ([]byte, int64, error) {
+	session := c.session()
+	req, err := http.NewRequest(http.MethodGet, session.Host+endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d/%d", start, start+qkviewChunk-1, size))
+	if session.Token != "" {
+		req.Header.Set("X-F5-Auth-Token", session.Token)
+	} else {
+		req.SetBasicAuth(session.User, session.Password)
+	}
+	client := &http.Client{Transport: session.Transport, Timeout: session.ConfigOptions.APICallTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, newAPIError(endpoint, nil, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, newAPIError(endpoint, nil, err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, 0, newAPIError(endpoint, body, fmt.Errorf("HTTP %d :: %s", resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	// "0-1048575/52428800"
+	_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	if n, err := strconv.ParseInt(total, 10, 64); ok && err == nil && n > 0 {
+		size = n
+	} else if size == 0 {
+		// No size given: this is the whole file
+		size = start + int64(len(body))
+	}
+	return body, size, nil
+}
+
+// DeleteQkview removes the qkview the task with id made from the device,
+// where it takes up space in /var/tmp
+func (c *Client) DeleteQkview(id string) error {
+	endpoint := "/mgmt/cm/autodeploy/qkview/" + url.PathEscape(id)
+	err := c.attempt("DeleteQkview", func() error {
+		resp, err := c.session().APICall(&bigip.APIRequest{Method: "DELETE", URL: strings.TrimPrefix(endpoint, "/"), ContentType: "application/json"})
+		return newAPIError(endpoint, resp, err)
+	})()
+	if err != nil {
+		return fmt.Errorf("failed to delete qkview %s: %w", id, err)
+	}
+	return nil
+}
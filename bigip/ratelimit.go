@@ -0,0 +1,42 @@
+package bigip
+
+import (
+	"log/slog"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"f5chat/metrics"
+)
+
+// requestLimiter queues REST calls so multi-step queries don't flood the
+// BIG-IP management plane. A nil limiter never throttles.
+type requestLimiter struct {
+	limiter *rate.Limiter
+}
+
+// newRequestLimiter returns a limiter allowing perSecond requests with the
+// given burst, or nil when perSecond is zero (unlimited)
+func newRequestLimiter(perSecond float64, burst int) *requestLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &requestLimiter{limiter: rate.NewLimiter(rate.Limit(perSecond), burst)}
+}
+
+// wait blocks until the next request may be sent, logging when it had to queue
+func (l *requestLimiter) wait(operation string) {
+	if l == nil {
+		return
+	}
+	reservation := l.limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		metrics.Throttled.Inc()
+		slog.Info("Rate limit reached - queueing request", "operation", operation,
+			"rate", float64(l.limiter.Limit()), "burst", l.limiter.Burst(), "delay", delay.Round(time.Millisecond))
+		time.Sleep(delay)
+	}
+}
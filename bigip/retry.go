@@ -0,0 +1,160 @@
+package bigip
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"f5chat/metrics"
+	"f5chat/retry"
+)
+
+// Error classes used to decide whether a failed call is worth retrying
+const (
+	ErrClassAuth        = "auth"
+	ErrClassNotFound    = "not_found"
+	ErrClassConnection  = "connection"
+	ErrClassTimeout     = "timeout"
+	ErrClassCertificate = "certificate"
+	ErrClassDNS         = "dns"
+	ErrClassServer      = "server"
+	ErrClassParse       = "parse"
+	ErrClassCircuitOpen = "circuit_open"
+	ErrClassUnknown     = "unknown"
+)
+
+// Unreachable reports whether err means the device couldn't be reached at
+// all: the connection failed or timed out, its name didn't resolve, or the
+// circuit breaker is open after such failures
+func Unreachable(err error) bool {
+	switch classifyError(err) {
+	case ErrClassConnection, ErrClassTimeout, ErrClassDNS, ErrClassCircuitOpen:
+		return true
+	}
+	return false
+}
+
+// classifyError maps an API error onto one of the ErrClass constants
+func classifyError(err error) string {
+	var (
+		openErr     *CircuitOpenError
+		authErr     *AuthError
+		notFoundErr *NotFoundError
+		moduleErr   *ModuleNotProvisionedError
+		timeoutErr  *TimeoutError
+		apiErr      *APIError
+	)
+	switch {
+	case errors.As(err, &openErr):
+		return ErrClassCircuitOpen
+	case errors.As(err, &authErr):
+		return ErrClassAuth
+	case errors.As(err, &notFoundErr), errors.As(err, &moduleErr):
+		return ErrClassNotFound
+	case errors.As(err, &timeoutErr):
+		return ErrClassTimeout
+	case errors.As(err, &apiErr) && apiErr.StatusCode >= 500:
+		return ErrClassServer
+	}
+
+	errLower := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(errLower, "unauthorized") || strings.Contains(errLower, "authentication failed"):
+		return ErrClassAuth
+	case strings.Contains(errLower, "certificate") || strings.Contains(errLower, "x509"):
+		return ErrClassCertificate
+	case strings.Contains(errLower, "no such host"):
+		return ErrClassDNS
+	case strings.Contains(errLower, "timeout") || strings.Contains(errLower, "deadline exceeded"):
+		return ErrClassTimeout
+	case strings.Contains(errLower, "connection"):
+		return ErrClassConnection
+	case strings.Contains(errLower, "not found"):
+		return ErrClassNotFound
+	case strings.Contains(errLower, "json parsing error"):
+		return ErrClassParse
+	case strings.Contains(errLower, "service unavailable") || strings.Contains(errLower, "internal server error"):
+		return ErrClassServer
+	}
+	return ErrClassUnknown
+}
+
+// logErrorHint prints troubleshooting guidance for an error class
+func logErrorHint(class string) {
+	switch class {
+	case ErrClassAuth:
+		slog.Debug("Authentication Error: Please verify credentials and access permissions")
+	case ErrClassCertificate:
+		slog.Debug("TLS Certificate Error: Certificate validation failed - check BIGIP_CA_FILE, or turn off BIGIP_TLS_VERIFY for a self-signed certificate")
+	case ErrClassDNS:
+		slog.Debug("DNS Error: Unable to resolve BIG-IP hostname")
+	case ErrClassTimeout:
+		slog.Debug("Timeout Error: Request took too long to complete")
+	case ErrClassConnection:
+		slog.Debug("Connection Error: Unable to reach BIG-IP - verify network connectivity and the management interface")
+	case ErrClassNotFound:
+		slog.Debug("Endpoint Error: endpoint or object not found")
+	case ErrClassParse:
+		slog.Debug("Response Error: unexpected response body from BIG-IP")
+	}
+}
+
+// withRetry runs fn under the client's retry policy, backing off between
+// attempts and giving up early on error classes the policy doesn't retry.
+// The device is connected first if it hasn't been yet.
+func (c *Client) withRetry(operation string, fn func() error) error {
+	if err := c.Connect(); err != nil {
+		return err
+	}
+	return runWithRetry(c.retry, operation, c.attempt(operation, fn))
+}
+
+// attempt wraps a single API call with the circuit breaker, rate limiter and metrics
+func (c *Client) attempt(operation string, fn func() error) func() error {
+	return func() error {
+		if err := c.breaker.allow(); err != nil {
+			return err
+		}
+		c.limiter.wait(operation)
+		started := time.Now()
+		err := c.callWithReauth(operation, fn)
+		c.breaker.record(err)
+		errClass := ""
+		if err != nil {
+			errClass = classifyError(err)
+		}
+		metrics.ObserveAPICall(operation, started, errClass)
+		return err
+	}
+}
+
+func runWithRetry(p retry.Policy, operation string, fn func() error) error {
+	attempts := p.Attempts()
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if delay := p.Delay(attempt); delay > 0 {
+			slog.Info("Retrying after backoff", "operation", operation, "attempt", attempt, "of", attempts, "delay", delay)
+			time.Sleep(delay)
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		class := classifyError(err)
+		slog.Warn("BIG-IP call failed", "operation", operation, "attempt", attempt, "of", attempts, "class", class, "err", err)
+		logErrorHint(class)
+		if !p.Retryable(class) {
+			return err
+		}
+	}
+	if attempts > 1 {
+		return fmt.Errorf("failed after %d attempts - last error: %w", attempts, lastErr)
+	}
+	return lastErr
+}
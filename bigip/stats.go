@@ -0,0 +1,92 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// ObjectStats are the traffic counters of a virtual server, pool or node
+type ObjectStats struct {
+	CurrentConnections int64
+	TotalConnections   int64
+	Availability       string
+}
+
+// statsSides are the LTM collections GetStats reads, and the side of the
+// proxy their connection counters are kept on
+var statsSides = map[string]string{
+	"virtual": "clientside",
+	"pool":    "serverside",
+	"node":    "serverside",
+}
+
+// GetStats retrieves the counters of every virtual server, pool or node
+// (kind "virtual", "pool" or "node"), keyed by full path
+func (c *Client) GetStats(kind string) (map[string]ObjectStats, error) {
+	if _, ok := statsSides[kind]; !ok {
+		return nil, fmt.Errorf("no statistics for %q", kind)
+	}
+	return cached(c, "/mgmt/tm/ltm/"+kind+"/stats", func() (map[string]ObjectStats, error) {
+		return c.fetchStats(kind)
+	})
+}
+
+func (c *Client) fetchStats(kind string) (map[string]ObjectStats, error) {
+	endpoint := "/mgmt/tm/ltm/" + kind + "/stats"
+	slog.Debug("Fetching statistics", "endpoint", endpoint)
+
+	var resp []byte
+	err := c.withRetry("GetStats", func() error {
+		var err error
+		resp, err = c.session().APICall(&bigip.APIRequest{
+			Method:      "GET",
+			URL:         "mgmt/tm/ltm/" + kind + "/stats",
+			ContentType: "application/json",
+		})
+		return newAPIError(endpoint, resp, err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s statistics: %w", kind, err)
+	}
+	stats, err := parseStats(resp, statsSides[kind])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s statistics: %w", kind, err)
+	}
+	slog.Info("Fetched statistics", "type", kind, "count", len(stats))
+	return stats, nil
+}
+
+// parseStats reads a stats collection: one nested entry per object, named by
+// its tmName, with counters such as clientside.curConns
+func parseStats(resp []byte, side string) (map[string]ObjectStats, error) {
+	var collection struct {
+		Entries map[string]struct {
+			NestedStats struct {
+				Entries map[string]struct {
+					Value       float64 `json:"value"`
+					Description string  `json:"description"`
+				} `json:"entries"`
+			} `json:"nestedStats"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(resp, &collection); err != nil {
+		return nil, err
+	}
+	stats := make(map[string]ObjectStats, len(collection.Entries))
+	for _, entry := range collection.Entries {
+		e := entry.NestedStats.Entries
+		name := e["tmName"].Description
+		if name == "" {
+			continue
+		}
+		stats[name] = ObjectStats{
+			CurrentConnections: int64(e[side+".curConns"].Value),
+			TotalConnections:   int64(e[side+".totConns"].Value),
+			Availability:       e["status.availabilityState"].Description,
+		}
+	}
+	return stats, nil
+}
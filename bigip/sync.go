@@ -0,0 +1,83 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// SyncStatus is the device's config sync state, as "show cm sync-status"
+// reports it
+type SyncStatus struct {
+	// Status is e.g. "In Sync", "Changes Pending" or "Standalone"
+	Status string
+	// Color is green when in sync, yellow or red when not, black when
+	// standalone
+	Color   string
+	Mode    string
+	Summary string
+}
+
+// InSync reports whether the device group's configuration matches, or
+// there's no device group to keep in sync
+func (s SyncStatus) InSync() bool {
+	return s.Status == "In Sync" || s.Status == "Standalone"
+}
+
+// GetSyncStatus retrieves the config sync state
+func (c *Client) GetSyncStatus() (*SyncStatus, error) {
+	return cached(c, "/mgmt/tm/cm/sync-status", c.fetchSyncStatus)
+}
+
+func (c *Client) fetchSyncStatus() (*SyncStatus, error) {
+	const endpoint = "/mgmt/tm/cm/sync-status"
+	slog.Debug("Fetching sync status", "endpoint", endpoint)
+
+	var resp []byte
+	err := c.withRetry("GetSyncStatus", func() error {
+		var err error
+		resp, err = c.session().APICall(&bigip.APIRequest{
+			Method:      "GET",
+			URL:         "mgmt/tm/cm/sync-status",
+			ContentType: "application/json",
+		})
+		return newAPIError(endpoint, resp, err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync status: %w", err)
+	}
+	status, err := parseSyncStatus(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sync status: %w", err)
+	}
+	slog.Info("Fetched sync status", "status", status.Status)
+	return status, nil
+}
+
+// parseSyncStatus reads the single nested entry of the sync-status stats
+func parseSyncStatus(resp []byte) (*SyncStatus, error) {
+	var collection struct {
+		Entries map[string]struct {
+			NestedStats struct {
+				Entries map[string]struct {
+					Description string `json:"description"`
+				} `json:"entries"`
+			} `json:"nestedStats"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(resp, &collection); err != nil {
+		return nil, err
+	}
+	for _, entry := range collection.Entries {
+		e := entry.NestedStats.Entries
+		return &SyncStatus{
+			Status:  e["status"].Description,
+			Color:   e["color"].Description,
+			Mode:    e["mode"].Description,
+			Summary: e["summary"].Description,
+		}, nil
+	}
+	return nil, fmt.Errorf("no sync status in the response")
+}
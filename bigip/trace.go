@@ -0,0 +1,121 @@
+package bigip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redactedHeaders are replaced with a placeholder in trace output
+var redactedHeaders = map[string]bool{
+	"Authorization":   true,
+	"X-F5-Auth-Token": true,
+	"Cookie":          true,
+	"Set-Cookie":      true,
+}
+
+// redactedFields matches JSON credentials and tokens in request/response bodies
+var redactedFields = regexp.MustCompile(`("(?:password|token|passphrase|secret)"\s*:\s*)("(?:[^"\\]|\\.)*"|\{[^{}]*\})`)
+
+// tracingTransport records every iControl REST request/response pair to a
+// trace file with credentials redacted, for attaching to bug reports
+type tracingTransport struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	out  io.Writer
+}
+
+// newTracingTransport returns a tracer of the requests made through next
+// that appends to path
+func newTracingTransport(next http.RoundTripper, path string) (*tracingTransport, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %v", err)
+	}
+	slog.Info("Tracing iControl REST calls (credentials redacted)", "file", path)
+	return &tracingTransport{next: next, out: f}, nil
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		reqBody, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(started)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("=== %s %s %s (%v)\n", started.Format(time.RFC3339Nano), req.Method, req.URL, elapsed.Round(time.Millisecond)))
+	writeHeaders(&sb, "> ", req.Header)
+	writeBody(&sb, "> ", reqBody)
+
+	if err != nil {
+		sb.WriteString(fmt.Sprintf("< transport error: %v\n\n", err))
+		t.write(sb.String())
+		return nil, err
+	}
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	sb.WriteString(fmt.Sprintf("< %s %s\n", resp.Proto, resp.Status))
+	writeHeaders(&sb, "< ", resp.Header)
+	writeBody(&sb, "< ", respBody)
+	if readErr != nil {
+		sb.WriteString(fmt.Sprintf("< body read error: %v\n", readErr))
+	}
+	sb.WriteString("\n")
+	t.write(sb.String())
+	return resp, nil
+}
+
+func (t *tracingTransport) write(entry string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := io.WriteString(t.out, entry); err != nil {
+		slog.Warn("Failed to write trace entry", "err", err)
+	}
+}
+
+func writeHeaders(sb *strings.Builder, prefix string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			value = "[REDACTED]"
+		}
+		sb.WriteString(fmt.Sprintf("%s%s: %s\n", prefix, name, value))
+	}
+}
+
+func writeBody(sb *strings.Builder, prefix string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	sb.WriteString(prefix + "\n")
+	for _, line := range strings.Split(redactBody(string(body)), "\n") {
+		sb.WriteString(prefix + line + "\n")
+	}
+}
+
+// redactBody masks credential and token values in JSON bodies
+func redactBody(body string) string {
+	return redactedFields.ReplaceAllString(body, `$1"[REDACTED]"`)
+}
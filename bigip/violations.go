@@ -0,0 +1,136 @@
+package bigip
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/f5devcentral/go-bigip"
+)
+
+// Violation is a request a WAF policy blocked or alerted on, from the ASM
+// request log
+type Violation struct {
+	Time     time.Time
+	Policy   string
+	ClientIP string
+	Method   string
+	URL      string
+	// Status is "blocked" or "alerted"
+	Status string
+	// Violations names what the request violated, e.g. "Attack signature
+	// detected", and AttackTypes what it was taken for, e.g. "SQL-Injection"
+	Violations  []string
+	AttackTypes []string
+	// Rating is the violation rating, from 1 (probably legitimate) to 5
+	// (almost certainly an attack)
+	Rating    int
+	SupportID string
+}
+
+// GetViolations retrieves the latest n requests the WAF blocked or alerted
+// on, newest first. Passed requests are left out.
+func (c *Client) GetViolations(n int) ([]Violation, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	endpoint := "/mgmt/tm/asm/events/requests"
+	return cached(c, fmt.Sprintf("%s?top=%d", endpoint, n), func() ([]Violation, error) {
+		slog.Debug("Fetching WAF violations", "endpoint", endpoint, "top", n)
+		var resp []byte
+		err := c.withRetry("GetViolations", func() error {
+			var err error
+			resp, err = c.session().APICall(&bigip.APIRequest{
+				Method:      "GET",
+				URL:         fmt.Sprintf("mgmt/tm/asm/events/requests?$top=%d&%s", n, odataNe("requestStatus", "passed")),
+				ContentType: "application/json",
+			})
+			return newAPIError(endpoint, resp, err)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get WAF violations: %w", err)
+		}
+		violations, err := parseViolations(resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse WAF violations: %w", err)
+		}
+		return violations, nil
+	})
+}
+
+// parseViolations reads the ASM request log. Violations and attack types
+// come as names or as references to them, depending on the version.
+func parseViolations(resp []byte) ([]Violation, error) {
+	var raw struct {
+		Items []struct {
+			RequestDatetime string            `json:"requestDatetime"`
+			PolicyName      string            `json:"policyName"`
+			ClientIP        string            `json:"clientIp"`
+			Method          string            `json:"method"`
+			URL             string            `json:"url"`
+			RequestStatus   string            `json:"requestStatus"`
+			Violations      []json.RawMessage `json:"violations"`
+			AttackTypes     []json.RawMessage `json:"attackTypes"`
+			ViolationRating int               `json:"violationRating"`
+			SupportID       string            `json:"supportId"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(resp, &raw); err != nil {
+		return nil, err
+	}
+	var violations []Violation
+	for _, item := range raw.Items {
+		if item.RequestStatus == "passed" {
+			continue
+		}
+		t, _ := time.Parse(time.RFC3339, item.RequestDatetime)
+		violations = append(violations, Violation{
+			Time:        t,
+			Policy:      item.PolicyName,
+			ClientIP:    item.ClientIP,
+			Method:      item.Method,
+			URL:         item.URL,
+			Status:      item.RequestStatus,
+			Violations:  referenceNames(item.Violations),
+			AttackTypes: referenceNames(item.AttackTypes),
+			Rating:      item.ViolationRating,
+			SupportID:   item.SupportID,
+		})
+	}
+	sort.SliceStable(violations, func(a, b int) bool { return violations[a].Time.After(violations[b].Time) })
+	return violations, nil
+}
+
+// referenceNames reads a list of names, given as strings, as objects with a
+// name, or as objects with a reference to one
+func referenceNames(items []json.RawMessage) []string {
+	var names []string
+	for _, item := range items {
+		var name string
+		if json.Unmarshal(item, &name) == nil {
+			names = append(names, name)
+			continue
+		}
+		var ref struct {
+			Name      string `json:"name"`
+			Reference struct {
+				Name string `json:"name"`
+			} `json:"violationReference"`
+			AttackType struct {
+				Name string `json:"name"`
+			} `json:"attackTypeReference"`
+		}
+		if json.Unmarshal(item, &ref) != nil {
+			continue
+		}
+		for _, n := range []string{ref.Name, ref.Reference.Name, ref.AttackType.Name} {
+			if n != "" {
+				names = append(names, n)
+				break
+			}
+		}
+	}
+	return names
+}
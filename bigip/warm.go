@@ -0,0 +1,62 @@
+package bigip
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Warm reads the virtual servers, pools, nodes and WAF policies into the
+// response cache ahead of the queries that need them, connecting first if
+// the client hasn't yet. Kinds already cached are read again, so calling it
+// before they expire keeps them from lapsing. WAF policies are left out on
+// a device without ASM. Its requests aren't among those TakeCalls returns,
+// since they weren't made for a query.
+func (c *Client) Warm() error {
+	if c.cache.ttl <= 0 {
+		return nil
+	}
+	w := c.unrecorded()
+	if err := w.Connect(); err != nil {
+		return err
+	}
+	if w != c {
+		// The session w connected is c's as well
+		c.connMu.Lock()
+		c.connected = true
+		c.connMu.Unlock()
+	}
+	start := time.Now()
+	var g errgroup.Group
+	g.Go(func() error { return warm(w, "/mgmt/tm/ltm/virtual", w.fetchVirtualServers) })
+	g.Go(func() error { return warm(w, "/mgmt/tm/ltm/pool", w.fetchPools) })
+	g.Go(func() error { return warm(w, "/mgmt/tm/ltm/node", w.fetchNodes) })
+	g.Go(func() error {
+		err := warm(w, "/mgmt/tm/asm/policies", w.fetchWAFPolicies)
+		var notFound *NotFoundError
+		var notProvisioned *ModuleNotProvisionedError
+		if errors.As(err, &notFound) || errors.As(err, &notProvisioned) {
+			return nil
+		}
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	slog.Debug("Warmed the response cache", "took", time.Since(start))
+	return nil
+}
+
+// warm fetches what key caches and caches it, whether or not it's cached
+// already, unless the cache is cleared meanwhile
+func warm[T any](c *Client, key string, fetch func() (T, error)) error {
+	gen := c.cache.gen()
+	v, err := fetch()
+	if err != nil {
+		return err
+	}
+	c.cache.set(key, v, gen)
+	return nil
+}
@@ -0,0 +1,132 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"f5chat/llm"
+)
+
+// DefaultAgentSteps bounds "/agent" questions when agent mode isn't enabled
+const DefaultAgentSteps = 6
+
+// maxAgentResult caps each tool result passed back to the model
+const maxAgentResult = 4000
+
+// EnableAgent answers queries the intent classifier doesn't match with a
+// multi-step loop of read-only tool calls, at most maxSteps per question.
+// It reports false when the LLM provider can't run the loop.
+func (i *Interface) EnableAgent(maxSteps int) bool {
+	if _, ok := i.llmClient.(llm.Agent); !ok {
+		slog.Warn("LLM provider does not support agent mode; answering with single operations", "provider", i.llmClient.Name())
+		return false
+	}
+	i.agentMode = true
+	i.agentSteps = maxSteps
+	return true
+}
+
+// agentCommand returns the question after "/agent", which is investigated
+// even when agent mode is off
+func agentCommand(query string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "/agent")
+	if !ok || (rest != "" && rest[0] != ' ') {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// answerAgent investigates question and remembers the answer under query
+func (i *Interface) answerAgent(query, question string) (string, error) {
+	response, err := i.investigate(question)
+	if message, ok := unavailableMessage(err); ok {
+		return message, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("I couldn't complete the investigation. Please try again. (Error: %v)", err)
+	}
+	i.remember(query, response)
+	return response, nil
+}
+
+// investigate lets the model call read-only tools one after another,
+// seeing each result, until it answers or runs out of steps
+func (i *Interface) investigate(query string) (string, error) {
+	agent, ok := i.llmClient.(llm.Agent)
+	if !ok {
+		return fmt.Sprintf("%s can't investigate step by step; ask about one object at a time instead.", i.llmClient.Name()), nil
+	}
+	if query == "" {
+		return "What should I investigate? For example: '/agent why is vs_app1 not serving traffic?'", nil
+	}
+	maxSteps := i.agentSteps
+	if maxSteps <= 0 {
+		maxSteps = DefaultAgentSteps
+	}
+
+	ar := &llm.AgentRequest{History: i.context(), Query: query}
+	for {
+		ar.Final = len(ar.Steps) >= maxSteps
+		reply, err := agent.NextStep(ar)
+		if err != nil {
+			return "", err
+		}
+		if reply.ToolCall == nil {
+			return formatInvestigation(reply.Text, ar.Steps, ar.Final), nil
+		}
+
+		call := reply.ToolCall
+		var result string
+		if !llm.AgentTool(call.Name) {
+			// Only read-only tools are offered, but don't trust the model
+			slog.Warn("Agent asked for a tool it may not use", "tool", call.Name, "args", call.Args)
+			result = "Refused: only read-only tools can be used while investigating."
+		} else if out, err := i.executeTool(call); err != nil {
+			result = "Error: " + err.Error()
+		} else {
+			result = out
+		}
+		if len(result) > maxAgentResult {
+			result = result[:maxAgentResult] + "\n[...]"
+		}
+		slog.Info("Agent step", "step", len(ar.Steps)+1, "of", maxSteps, "tool", call.Name, "args", call.Args, "bytes", len(result))
+		ar.Steps = append(ar.Steps, llm.AgentStep{Call: call, Result: result})
+		i.setLastCall(call)
+		i.noteEntities(query, call)
+	}
+}
+
+// formatInvestigation appends the steps taken to the answer
+func formatInvestigation(answer string, steps []llm.AgentStep, limited bool) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(answer))
+	if len(steps) > 0 {
+		sb.WriteString("\n\nSteps taken:")
+		for n, s := range steps {
+			fmt.Fprintf(&sb, "\n%d. %s", n+1, describeCall(s.Call))
+		}
+	}
+	if limited {
+		fmt.Fprintf(&sb, "\n(Stopped after %d steps; ask a follow-up to dig further.)", len(steps))
+	}
+	return sb.String()
+}
+
+// describeCall renders a tool call as name(arg=value, ...)
+func describeCall(call *llm.ToolCall) string {
+	if len(call.Args) == 0 {
+		return call.Name
+	}
+	keys := make([]string, 0, len(call.Args))
+	for k := range call.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]string, len(keys))
+	for n, k := range keys {
+		args[n] = k + "=" + call.Args[k]
+	}
+	return call.Name + "(" + strings.Join(args, ", ") + ")"
+}
@@ -0,0 +1,142 @@
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+// listCount counts a listing instead of showing it, as in "how many pools
+// have fewer than 2 members"; groupBy is empty for a single total
+type listCount struct {
+	groupBy string
+}
+
+// parseListCount reads a listing tool's counting; it is nil when the call
+// asks for the list. A group without "count" still counts.
+func parseListCount(call *llm.ToolCall) (*listCount, error) {
+	key := strings.ToLower(call.Arg(llm.GroupBy))
+	switch strings.ToLower(call.Arg(llm.Aggregate)) {
+	case "":
+		if key == "" {
+			return nil, nil
+		}
+	case "count":
+	default:
+		return nil, fmt.Errorf("unknown aggregate '%s' (use count)", call.Arg(llm.Aggregate))
+	}
+	if keys := llm.GroupKeys(call.Name); key != "" && !slices.Contains(keys, key) {
+		return nil, fmt.Errorf("can't count per '%s' here (use %s)", key, strings.Join(keys, ", "))
+	}
+	return &listCount{groupBy: key}, nil
+}
+
+// groupLabels name the group keys in the report
+var groupLabels = map[string]string{
+	llm.GroupPartition: "partition",
+	llm.GroupStatus:    "status",
+	llm.GroupPool:      "pool",
+	llm.GroupMonitor:   "monitor",
+	llm.GroupLBMode:    "load balancing mode",
+}
+
+// countList reports how many of a listing's total a filter kept, per value
+// of the group key when there is one. group gives an object's value of a
+// key; objects without one are counted under "(none)".
+func countList[T any](c *listCount, kind string, f *listFilter, kept []T, total int, group func(T, string) string) string {
+	var sb strings.Builder
+	if c.groupBy != "" {
+		counts := map[string]int{}
+		for _, obj := range kept {
+			value := strings.TrimSpace(group(obj, c.groupBy))
+			if value == "" {
+				value = "(none)"
+			}
+			counts[value]++
+		}
+		values := make([]string, 0, len(counts))
+		for value := range counts {
+			values = append(values, value)
+		}
+		// Largest groups first, then by name
+		sort.Slice(values, func(a, b int) bool {
+			if counts[values[a]] != counts[values[b]] {
+				return counts[values[a]] > counts[values[b]]
+			}
+			return values[a] < values[b]
+		})
+		sb.WriteString(fmt.Sprintf("%s per %s:\n", capitalize(plural(kind, 2)), groupLabels[c.groupBy]))
+		for _, value := range values {
+			sb.WriteString(fmt.Sprintf("  %s: %d\n", value, counts[value]))
+		}
+		sb.WriteString("Total: ")
+	}
+	if f != nil {
+		sb.WriteString(fmt.Sprintf("%d of the %d %s match: %s.\n", len(kept), total, plural(kind, total), f))
+	} else {
+		sb.WriteString(fmt.Sprintf("%d %s.\n", total, plural(kind, total)))
+	}
+	return sb.String()
+}
+
+// partitionOf is an object's partition, read from its full path when the
+// listing leaves it out
+func partitionOf(partition, fullPath string) string {
+	if partition != "" {
+		return partition
+	}
+	if parts := strings.Split(fullPath, "/"); len(parts) > 2 {
+		return parts[1]
+	}
+	return "Common"
+}
+
+func virtualServerGroup(v bigip.VirtualServer, key string) string {
+	switch key {
+	case llm.GroupStatus:
+		if v.Enabled {
+			return "enabled"
+		}
+		return "disabled"
+	case llm.GroupPool:
+		return v.Pool
+	}
+	return partitionOf(v.Partition, v.FullPath)
+}
+
+func poolGroup(p bigip.Pool, key string) string {
+	switch key {
+	case llm.GroupMonitor:
+		return p.Monitor
+	case llm.GroupLBMode:
+		return p.LoadBalancingMode
+	}
+	return partitionOf(p.Partition, p.FullPath)
+}
+
+// nodeGroup groups nodes by their monitored state, as the listing shows it
+func nodeGroup(n bigip.Node, key string) string {
+	if key == llm.GroupStatus {
+		return n.State
+	}
+	return partitionOf(n.Partition, n.FullPath)
+}
+
+// plural adds an s to a kind of object unless there is exactly one
+func plural(kind string, n int) string {
+	if n == 1 {
+		return kind
+	}
+	return kind + "s"
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,150 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/notify"
+	"f5chat/utils"
+)
+
+// SetNotifier has watch mode and AlertReport send the alerts they find
+// through router; device names the BIG-IP in them
+func (i *Interface) SetNotifier(router *notify.Router, device string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.notifier, i.notifierDevice = router, device
+}
+
+// Notifier is the router alerts are sent through, nil if none is set
+func (i *Interface) Notifier() *notify.Router {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.notifier
+}
+
+// alerts checks the device for conditions worth notifying someone of:
+// enabled virtual servers that are offline, certificates expired or
+// expiring within certWarnDays, and a device group out of sync. Checks that
+// fail are reported in the error; the others' alerts are still returned.
+// A device that can't be reached at all is an alert of its own, and the
+// other checks are skipped rather than each failing the same way.
+func (i *Interface) alerts(now time.Time) ([]notify.Event, error) {
+	var events []notify.Event
+	var errs []error
+
+	if vs, err := i.bigipClient.GetVirtualServers(); err != nil {
+		if bigip.Unreachable(err) {
+			return []notify.Event{{
+				Key:      "device_unreachable",
+				Severity: notify.SeverityCritical,
+				Title:    "Device unreachable",
+				Message:  fmt.Sprintf("The iControl REST API isn't answering: %v", err),
+			}}, err
+		}
+		errs = append(errs, err)
+	} else if stats, err := i.bigipClient.GetStats("virtual"); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, v := range vs {
+			if v.Disabled || stats[v.FullPath].Availability != "offline" {
+				continue
+			}
+			events = append(events, notify.Event{
+				Key:      "vs_down:" + v.FullPath,
+				Severity: notify.SeverityCritical,
+				Title:    "Virtual server down",
+				Message:  fmt.Sprintf("%s (%s) is enabled but offline", v.FullPath, v.Destination),
+				Fields:   map[string]string{"virtual_server": v.FullPath, "pool": v.Pool},
+			})
+		}
+	}
+
+	if certs, err := i.bigipClient.GetCertificates(); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, c := range certs {
+			expires := c.Expires()
+			if expires.IsZero() {
+				continue
+			}
+			left := int(math.Round(expires.Sub(now).Hours() / 24))
+			fields := map[string]string{"certificate": c.FullPath, "expires": expires.UTC().Format("2006-01-02")}
+			switch {
+			case !expires.After(now):
+				events = append(events, notify.Event{
+					Key:      "cert_expired:" + c.FullPath,
+					Severity: notify.SeverityCritical,
+					Title:    "Certificate expired",
+					Message:  fmt.Sprintf("%s expired on %s", c.FullPath, fields["expires"]),
+					Fields:   fields,
+				})
+			case left < certWarnDays:
+				events = append(events, notify.Event{
+					Key:      "cert_expiring:" + c.FullPath,
+					Severity: notify.SeverityWarning,
+					Title:    "Certificate expiring",
+					Message:  fmt.Sprintf("%s expires in %d days, on %s", c.FullPath, left, fields["expires"]),
+					Fields:   fields,
+				})
+			}
+		}
+	}
+
+	if sync, err := i.bigipClient.GetSyncStatus(); err != nil {
+		if !errors.As(err, new(*bigip.NotFoundError)) {
+			errs = append(errs, err)
+		}
+	} else if !sync.InSync() {
+		message := "Config sync status is " + sync.Status
+		if sync.Summary != "" {
+			message += ": " + sync.Summary
+		}
+		events = append(events, notify.Event{
+			Key:      "sync:" + sync.Status,
+			Severity: notify.SeverityWarning,
+			Title:    "Config sync out of date",
+			Message:  message,
+			Fields:   map[string]string{"status": sync.Status, "mode": sync.Mode},
+		})
+	}
+	return events, errors.Join(errs...)
+}
+
+// sendAlerts checks the device for alerts and sends them through the
+// notifier, if one is set, returning them. source says what found them,
+// e.g. "watch".
+func (i *Interface) sendAlerts(source string) ([]notify.Event, error) {
+	now := time.Now()
+	events, err := i.alerts(now)
+	i.mu.Lock()
+	router, device := i.notifier, i.notifierDevice
+	i.mu.Unlock()
+	if router == nil {
+		return events, err
+	}
+	for n := range events {
+		events[n].Source, events[n].Device, events[n].Time = source, device, now
+		if sendErr := router.Notify(context.Background(), events[n]); sendErr != nil {
+			err = errors.Join(err, sendErr)
+		}
+	}
+	return events, err
+}
+
+// AlertReport checks the device for alerts, sends them through the
+// notifier and lists them. ok is false when a check or a delivery failed.
+func (i *Interface) AlertReport() (report string, ok bool) {
+	events, err := i.sendAlerts("report")
+	report = utils.FormatAlerts(events)
+	if err != nil {
+		slog.Error("Alert checks failed", "err", err)
+		report += fmt.Sprintf("\nSome checks or notifications failed: %v\n", err)
+	}
+	return report, err == nil
+}
@@ -0,0 +1,93 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"f5chat/grafana"
+	"f5chat/journal"
+	"f5chat/notify"
+)
+
+// SetAnnotations has the changes made to the device, and the failovers
+// watch mode sees, published as annotations through g
+func (i *Interface) SetAnnotations(g *grafana.Client) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.annotations = g
+}
+
+// annotate publishes an annotation, if there's a Grafana to publish it to,
+// leaving out empty tags. A failure is logged rather than returned: the
+// change it marks is made all the same.
+func (i *Interface) annotate(a grafana.Annotation) {
+	i.mu.Lock()
+	g := i.annotations
+	i.mu.Unlock()
+	if g == nil {
+		return
+	}
+	a.Tags = slices.DeleteFunc(a.Tags, func(tag string) bool { return tag == "" })
+	if err := g.Annotate(context.Background(), a); err != nil {
+		slog.Warn("Failed to publish a Grafana annotation", "text", a.Text, "err", err)
+	}
+}
+
+// annotateChange marks a change applied to device on the graphs
+func (i *Interface) annotateChange(e journal.Entry, device string) {
+	if e.Result != journal.Applied {
+		return
+	}
+	text := fmt.Sprintf("chatf5 %s: %s on %s", e.Operation, e.Object, device)
+	if e.Request != "" {
+		text += "\n" + e.Request
+	}
+	at := e.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	i.annotate(grafana.Annotation{Time: at, Text: text, Tags: []string{"chatf5", "change", e.Operation, device}})
+}
+
+// watchFailover compares the device's failover state with the previous
+// round's, and when it changed, marks the failover on the graphs, sends it
+// through the notifier if there is one and returns it to be shown. The
+// first round only notes the state.
+func (i *Interface) watchFailover(at time.Time) string {
+	i.mu.Lock()
+	router, device, previous := i.notifier, i.notifierDevice, i.failoverState
+	i.mu.Unlock()
+	devices, err := i.bigipClient.GetDevices()
+	if err != nil || len(devices) == 0 || !devices[0].Self {
+		return ""
+	}
+	self := devices[0]
+	i.mu.Lock()
+	i.failoverState = self.FailoverState
+	i.mu.Unlock()
+	if previous == "" || previous == self.FailoverState {
+		return ""
+	}
+
+	message := fmt.Sprintf("%s went from %s to %s", self.Hostname, previous, self.FailoverState)
+	i.annotate(grafana.Annotation{Time: at, Text: "Failover: " + message, Tags: []string{"chatf5", "failover", self.Hostname}})
+	if router != nil {
+		event := notify.Event{
+			Key:      "failover:" + self.FailoverState,
+			Severity: notify.SeverityWarning,
+			Title:    "Failover",
+			Message:  message,
+			Source:   "watch",
+			Device:   device,
+			Time:     at,
+			Fields:   map[string]string{"hostname": self.Hostname, "from": previous, "to": self.FailoverState},
+		}
+		if err := router.Notify(context.Background(), event); err != nil {
+			slog.Warn("Failed to send a failover", "key", event.Key, "err", err)
+		}
+	}
+	return message
+}
@@ -0,0 +1,366 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"f5chat/llm"
+	"f5chat/prompt"
+	"f5chat/utils"
+)
+
+// ansibleModules is the collection the tasks' modules are in
+const ansibleModules = "f5networks.f5_modules."
+
+// ansibleProvider is the play's header: the modules run on the control
+// node and reach the BIG-IP through its REST API, with the connection
+// details kept out of the playbook
+const ansibleProvider = `  hosts: localhost
+  connection: local
+  gather_facts: false
+  vars:
+    provider:
+      server: "{{ bigip_server }}"
+      user: "{{ bigip_user }}"
+      password: "{{ bigip_password }}"
+      validate_certs: true
+  tasks:
+`
+
+// ansibleAS3Connection is the header of a play deploying AS3, whose module
+// is in the newer collection and connects through httpapi instead
+const ansibleAS3Connection = `  hosts: localhost
+  connection: httpapi
+  gather_facts: false
+  vars:
+    ansible_host: "{{ bigip_server }}"
+    ansible_user: "{{ bigip_user }}"
+    ansible_httpapi_password: "{{ bigip_password }}"
+    ansible_network_os: f5networks.f5_bigip.bigip
+    ansible_httpapi_use_ssl: true
+    ansible_httpapi_validate_certs: true
+  tasks:
+`
+
+// yamlBlock matches the fenced YAML block in a generated answer
+var yamlBlock = regexp.MustCompile("(?s)```(?:yaml|yml|YAML)?[ \\t]*\\n(.*?)```")
+
+// fieldIndent is the level, in steps of two spaces, a parameter's value is
+// indented to when it's written as a block: one past the parameters
+const fieldIndent = 5
+
+// yamlField is a module parameter, its value already written as YAML
+type yamlField struct {
+	key, value string
+}
+
+// ansibleTask is a task of the playbook: what it's called, its module
+// by its fully qualified name, and the module's parameters
+type ansibleTask struct {
+	name   string
+	module string
+	fields []yamlField
+}
+
+// generateAnsible writes an Ansible playbook of F5 module tasks. For a
+// described change the LLM writes it; otherwise it creates a virtual
+// server as it's configured now, with its pool, the pool's members and
+// their nodes, so the objects can be kept in an existing automation
+// pipeline. A pool name exports the pool alone, and no name every virtual
+// server. With a file named, the playbook is written to it rather than
+// shown.
+func (i *Interface) generateAnsible(call *llm.ToolCall) (string, error) {
+	file := strings.Trim(call.Arg("file"), "\"'`")
+	if file != "" && !strings.HasSuffix(file, ".yml") && !strings.HasSuffix(file, ".yaml") {
+		return fmt.Sprintf("Playbooks are YAML files; try \"export vs_app1 as an Ansible playbook to %s.yml\".", strings.TrimSuffix(file, ".")), nil
+	}
+	if description := call.Arg("description"); description != "" {
+		return i.describeAnsible(description, file)
+	}
+
+	objects, reply, err := i.readLiveObjects(call)
+	if objects == nil {
+		return reply, err
+	}
+	playbook := writePlaybook("Configure "+objects.title, ansibleProvider, objects.tasks())
+
+	notes := "The tasks are idempotent, so running the playbook against the device it came from changes nothing; run it with --check --diff first to make sure. " +
+		"Profiles, iRules, monitors and persistence profiles are referred to by name rather than created, so custom ones have to exist on the device or get tasks of their own. " +
+		"It needs the f5networks.f5_modules collection, and bigip_server, bigip_user and bigip_password set as variables, e.g. from a vault."
+	if file != "" {
+		if err := writeNewFile(file, playbook, "export "+call.Arg("name")+" as an Ansible playbook to site-2.yml"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Wrote the playbook for %s to %s: %s.\n\n%s", objects.title, file, objects.counts(), notes), nil
+	}
+	return fmt.Sprintf("=== Ansible: %s ===\n%s\n\n%s, as tasks of F5's Ansible modules. %s Add \"to site.yml\" to write it to a file.",
+		objects.title, strings.TrimRight(playbook, "\n"), capitalize(objects.counts()), notes), nil
+}
+
+// describeAnsible asks the LLM for a playbook making the described change
+func (i *Interface) describeAnsible(description, file string) (string, error) {
+	answer, err := i.llmClient.RunTask(prompt.Ansible, description)
+	if errors.Is(err, llm.ErrNoLLM) {
+		return "Writing a playbook for a described change needs an LLM; it isn't available with -no-llm. " +
+			"Objects as they're configured now can still be exported, e.g. \"export vs_app1 as an Ansible playbook\".", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate the playbook: %w", err)
+	}
+
+	m := yamlBlock.FindStringSubmatchIndex(answer)
+	if m == nil {
+		// Nothing to write; show whatever the model said
+		return answer, nil
+	}
+	playbook := strings.TrimSpace(answer[m[2]:m[3]]) + "\n"
+	explanation := strings.TrimSpace(answer[:m[0]] + answer[m[1]:])
+	slog.Info("Generated Ansible playbook", "bytes", len(playbook))
+
+	var sb strings.Builder
+	if file != "" {
+		if err := writeNewFile(file, playbook, "write a playbook that ... to site-2.yml"); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "Wrote the playbook to %s.\n", file)
+	} else {
+		fmt.Fprintf(&sb, "=== Generated Ansible playbook ===\n%s", playbook)
+	}
+	if explanation != "" {
+		fmt.Fprintf(&sb, "\n%s\n", explanation)
+	}
+	sb.WriteString("\nNothing has been changed on the BIG-IP. Review the playbook and run it with ansible-playbook --check --diff before applying it.")
+	return sb.String(), nil
+}
+
+// pendingAnsible answers a request for a playbook with no object or change
+// of its own, e.g. "give me that as an Ansible task", when a generated
+// iRule or declaration is waiting for confirmation: the playbook makes that
+// change. The change stays pending, so it can still be applied from here.
+func (i *Interface) pendingAnsible(query string) (string, bool) {
+	call, ok := llm.ParseAnsible(query)
+	if !ok || call.Arg("name") != "" || call.Arg("description") != "" {
+		return "", false
+	}
+	i.mu.Lock()
+	rule, declaration := i.pending, i.pendingAS3
+	i.mu.Unlock()
+
+	var playbook, what, reply string
+	switch {
+	case rule != nil:
+		what = "iRule /Common/" + rule.name
+		playbook = writePlaybook("Upload "+what, ansibleProvider, []ansibleTask{{
+			name:   "Upload " + what,
+			module: ansibleModules + "bigip_irule",
+			fields: []yamlField{
+				{"name", utils.YAMLString(rule.name, fieldIndent)},
+				{"partition", "Common"},
+				{"module", "ltm"},
+				{"content", utils.YAMLString(rule.definition, fieldIndent)},
+				{"state", "present"},
+			},
+		}})
+		reply = "'upload'"
+	case declaration != nil:
+		what = "the AS3 declaration for tenant " + strings.Join(declaration.tenants, ", ")
+		playbook = writePlaybook("Deploy "+what, ansibleAS3Connection, []ansibleTask{{
+			name:   "Deploy " + what,
+			module: "f5networks.f5_bigip.bigip_as3_deploy",
+			fields: []yamlField{
+				{"content", utils.YAMLString(declaration.declaration, fieldIndent)},
+				{"state", "present"},
+			},
+		}})
+		reply = "'deploy'"
+	default:
+		return "", false
+	}
+
+	notes := fmt.Sprintf("The change is still waiting too: reply %s to make it here instead. Anything else discards it.", reply)
+	if file := strings.Trim(call.Arg("file"), "\"'`"); file != "" {
+		if !strings.HasSuffix(file, ".yml") && !strings.HasSuffix(file, ".yaml") {
+			return fmt.Sprintf("Playbooks are YAML files; try \"give me that as an Ansible playbook to %s.yml\".", strings.TrimSuffix(file, ".")), true
+		}
+		if err := writeNewFile(file, playbook, "give me that as an Ansible playbook to site-2.yml"); err != nil {
+			return err.Error(), true
+		}
+		return fmt.Sprintf("Wrote the playbook for %s to %s.\n\n%s", what, file, notes), true
+	}
+	return fmt.Sprintf("=== Ansible: %s ===\n%s\n\n%s", what, strings.TrimRight(playbook, "\n"), notes), true
+}
+
+// tasks lays the objects out as tasks in the order they need creating:
+// nodes, pools with their members, then virtual servers
+func (o *liveObjects) tasks() []ansibleTask {
+	var tasks []ansibleTask
+	for _, n := range o.nodes {
+		fields := []yamlField{{"name", utils.YAMLString(n.Name, fieldIndent)}, {"partition", utils.YAMLString(partitionOf(n.Partition, n.FullPath), fieldIndent)}}
+		if n.FQDN.Name != "" {
+			fields = append(fields, yamlField{"fqdn", utils.YAMLString(n.FQDN.Name, fieldIndent)})
+		} else {
+			fields = append(fields, yamlField{"address", utils.YAMLString(n.Address, fieldIndent)})
+		}
+		if n.Description != "" {
+			fields = append(fields, yamlField{"description", utils.YAMLString(n.Description, fieldIndent)})
+		}
+		if n.ConnectionLimit > 0 {
+			fields = append(fields, yamlField{"connection_limit", strconv.Itoa(n.ConnectionLimit)})
+		}
+		if monitors := monitorPaths(n.Monitor); len(monitors) > 0 {
+			fields = append(fields, yamlField{"monitors", utils.YAMLFlowList(monitors)})
+		}
+		fields = append(fields, yamlField{"state", ansibleState(n.Session == "user-disabled")})
+		tasks = append(tasks, ansibleTask{name: "Node " + n.FullPath, module: ansibleModules + "bigip_node", fields: fields})
+	}
+
+	for _, p := range o.pools {
+		partition := utils.YAMLString(partitionOf(p.Partition, p.FullPath), fieldIndent)
+		fields := []yamlField{{"name", utils.YAMLString(p.Name, fieldIndent)}, {"partition", partition}}
+		if p.LoadBalancingMode != "" {
+			fields = append(fields, yamlField{"lb_method", utils.YAMLString(p.LoadBalancingMode, fieldIndent)})
+		}
+		if monitors := monitorPaths(p.Monitor); len(monitors) > 0 {
+			fields = append(fields, yamlField{"monitors", utils.YAMLFlowList(monitors)})
+			if quorum, ok := strings.CutPrefix(p.Monitor, "min "); ok {
+				fields = append(fields, yamlField{"monitor_type", "m_of_n"}, yamlField{"quorum", strings.Fields(quorum)[0]})
+			} else if len(monitors) > 1 {
+				fields = append(fields, yamlField{"monitor_type", "and_list"})
+			}
+		}
+		if p.MinActiveMembers > 0 {
+			fields = append(fields, yamlField{"priority_group_activation", strconv.Itoa(p.MinActiveMembers)})
+		}
+		if p.Description != "" {
+			fields = append(fields, yamlField{"description", utils.YAMLString(p.Description, fieldIndent)})
+		}
+		fields = append(fields, yamlField{"state", "present"})
+		tasks = append(tasks, ansibleTask{name: "Pool " + p.FullPath, module: ansibleModules + "bigip_pool", fields: fields})
+
+		for _, m := range o.members[p.FullPath] {
+			nodePath, _, port := splitMember(m.FullPath)
+			address := m.Address
+			if n, ok := o.node(nodePath); ok && n.Address != "" {
+				address = n.Address
+			}
+			fields := []yamlField{
+				{"pool", utils.YAMLString(p.Name, fieldIndent)},
+				{"partition", partition},
+				{"name", utils.YAMLString(nodePath[strings.LastIndex(nodePath, "/")+1:], fieldIndent)},
+			}
+			if m.FQDN.Name != "" {
+				fields = append(fields, yamlField{"fqdn", utils.YAMLString(m.FQDN.Name, fieldIndent)})
+			} else {
+				fields = append(fields, yamlField{"address", utils.YAMLString(address, fieldIndent)})
+			}
+			fields = append(fields, yamlField{"port", port})
+			if m.Ratio > 1 {
+				fields = append(fields, yamlField{"ratio", strconv.Itoa(m.Ratio)})
+			}
+			if m.PriorityGroup > 0 {
+				fields = append(fields, yamlField{"priority_group", strconv.Itoa(m.PriorityGroup)})
+			}
+			if m.ConnectionLimit > 0 {
+				fields = append(fields, yamlField{"connection_limit", strconv.Itoa(m.ConnectionLimit)})
+			}
+			fields = append(fields, yamlField{"reuse_nodes", "true"}, yamlField{"state", ansibleState(m.Session == "user-disabled")})
+			tasks = append(tasks, ansibleTask{name: "Member " + m.Name + " of " + p.FullPath, module: ansibleModules + "bigip_pool_member", fields: fields})
+		}
+	}
+
+	for _, v := range o.virtualServers {
+		destination, port := splitDestination(v.Destination)
+		fields := []yamlField{
+			{"name", utils.YAMLString(v.Name, fieldIndent)},
+			{"partition", utils.YAMLString(partitionOf(v.Partition, v.FullPath), fieldIndent)},
+			{"destination", utils.YAMLString(destination, fieldIndent)},
+			{"port", port},
+		}
+		if v.Source != "" && v.Source != "0.0.0.0/0" && v.Source != "::/0" {
+			fields = append(fields, yamlField{"source", utils.YAMLString(v.Source, fieldIndent)})
+		}
+		if v.Mask != "" && v.Mask != "255.255.255.255" {
+			fields = append(fields, yamlField{"mask", utils.YAMLString(v.Mask, fieldIndent)})
+		}
+		if v.IPProtocol != "" {
+			fields = append(fields, yamlField{"ip_protocol", utils.YAMLString(v.IPProtocol, fieldIndent)})
+		}
+		if v.Pool != "" {
+			fields = append(fields, yamlField{"pool", utils.YAMLString(v.Pool, fieldIndent)})
+		}
+		var profiles []string
+		for _, p := range o.profiles[v.FullPath] {
+			context := "all"
+			switch p.Context {
+			case "clientside":
+				context = "client-side"
+			case "serverside":
+				context = "server-side"
+			}
+			profiles = append(profiles, "{name: "+utils.YAMLFlowString(p.FullPath)+", context: "+context+"}")
+		}
+		if len(profiles) > 0 {
+			fields = append(fields, yamlField{"profiles", "[" + strings.Join(profiles, ", ") + "]"})
+		}
+		if len(v.Rules) > 0 {
+			fields = append(fields, yamlField{"irules", utils.YAMLFlowList(v.Rules)})
+		}
+		if len(v.Policies) > 0 {
+			fields = append(fields, yamlField{"policies", utils.YAMLFlowList(v.Policies)})
+		}
+		if len(v.PersistenceProfiles) > 0 {
+			p := v.PersistenceProfiles[0]
+			fields = append(fields, yamlField{"default_persistence_profile", utils.YAMLString("/"+partitionOf(p.Partition, p.FullPath)+"/"+p.Name, fieldIndent)})
+		}
+		if v.FallbackPersistenceProfile != "" {
+			fields = append(fields, yamlField{"fallback_persistence_profile", utils.YAMLString(v.FallbackPersistenceProfile, fieldIndent)})
+		}
+		switch snat := v.SourceAddressTranslation; snat.Type {
+		case "automap":
+			fields = append(fields, yamlField{"snat", "Automap"})
+		case "snat":
+			fields = append(fields, yamlField{"snat", utils.YAMLString(snat.Pool, fieldIndent)})
+		}
+		if v.VlansEnabled && len(v.Vlans) > 0 {
+			fields = append(fields, yamlField{"enabled_vlans", utils.YAMLFlowList(v.Vlans)})
+		}
+		if v.Description != "" {
+			fields = append(fields, yamlField{"description", utils.YAMLString(v.Description, fieldIndent)})
+		}
+		fields = append(fields, yamlField{"state", ansibleState(v.Disabled)})
+		tasks = append(tasks, ansibleTask{name: "Virtual server " + v.FullPath, module: ansibleModules + "bigip_virtual_server", fields: fields})
+	}
+	return tasks
+}
+
+// ansibleState is the state a task leaves an object in
+func ansibleState(disabled bool) string {
+	if disabled {
+		return "disabled"
+	}
+	return "present"
+}
+
+// writePlaybook writes a playbook of a single play, called name, with the
+// given header and tasks, each passed the provider when the header has one
+func writePlaybook(name, header string, tasks []ansibleTask) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "---\n- name: %s\n%s", utils.YAMLString(name, 2), header)
+	for n, t := range tasks {
+		if n > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "    - name: %s\n      %s:\n", utils.YAMLString(t.name, 4), t.module)
+		if strings.Contains(header, "provider:") {
+			sb.WriteString("        provider: \"{{ provider }}\"\n")
+		}
+		for _, f := range t.fields {
+			fmt.Fprintf(&sb, "        %s: %s\n", f.key, f.value)
+		}
+	}
+	return sb.String()
+}
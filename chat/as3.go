@@ -0,0 +1,179 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/journal"
+	"f5chat/llm"
+	"f5chat/prompt"
+)
+
+// pendingDeclaration is a generated AS3 declaration waiting for the user to
+// confirm the deployment
+type pendingDeclaration struct {
+	declaration string
+	tenants     []string
+	description string
+}
+
+// jsonBlock matches the fenced JSON block in a generated answer
+var jsonBlock = regexp.MustCompile("(?s)```(?:json|JSON)?[ \\t]*\\n(.*?)```")
+
+// generateAS3 asks the LLM for an AS3 declaration of the described
+// application, checks it and offers to deploy it
+func (i *Interface) generateAS3(call *llm.ToolCall) (string, error) {
+	description := call.Arg("description")
+	if description == "" {
+		return "Which application should I declare? For example: 'HTTPS app on 10.0.0.80 with members 10.0.1.10 and 10.0.1.11 and an HTTP redirect'.", nil
+	}
+	answer, err := i.llmClient.RunTask(prompt.AS3, description)
+	if errors.Is(err, llm.ErrNoLLM) {
+		return "Writing AS3 declarations needs an LLM; it isn't available with -no-llm.", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate the AS3 declaration: %w", err)
+	}
+
+	m := jsonBlock.FindStringSubmatchIndex(answer)
+	if m == nil {
+		// Nothing to deploy; show whatever the model said
+		return answer, nil
+	}
+	declaration := strings.TrimSpace(answer[m[2]:m[3]])
+	explanation := strings.TrimSpace(answer[:m[0]] + answer[m[1]:])
+
+	tenants, err := bigip.AS3Tenants(declaration)
+	if err != nil {
+		slog.Warn("Generated AS3 declaration is invalid", "err", err)
+		return fmt.Sprintf("=== Generated AS3 declaration ===\n%s\n\nThis declaration can't be deployed: %v. Try describing the application again.", declaration, err), nil
+	}
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, []byte(declaration), "", "  ") == nil {
+		declaration = pretty.String()
+	}
+
+	i.mu.Lock()
+	i.pendingAS3 = &pendingDeclaration{declaration: declaration, tenants: tenants, description: description}
+	i.pending, i.pendingList = nil, nil
+	i.mu.Unlock()
+	slog.Info("Generated AS3 declaration", "tenants", tenants, "bytes", len(declaration))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "=== Generated AS3 declaration (tenant %s) ===\n%s\n", strings.Join(tenants, ", "), declaration)
+	if explanation != "" {
+		fmt.Fprintf(&sb, "\n%s\n", explanation)
+	}
+	sb.WriteString("\nReply 'deploy' to send it to the BIG-IP with AS3. Deploying replaces anything AS3 already manages in the tenant. Anything else discards it.")
+	return sb.String(), nil
+}
+
+// takePendingAS3 returns and clears the declaration awaiting confirmation
+func (i *Interface) takePendingAS3() *pendingDeclaration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	p := i.pendingAS3
+	i.pendingAS3 = nil
+	return p
+}
+
+// deployReply matches the confirmation replies: "deploy", "yes"
+var deployReply = regexp.MustCompile(`(?i)^\s*(?:yes|y|confirm|deploy(?: it)?)\s*[.!]?\s*$`)
+
+// confirmAS3 handles the reply to a generated declaration. It reports false
+// when the reply isn't a confirmation, in which case the declaration is
+// dropped and the reply is processed as a new query.
+func (i *Interface) confirmAS3(p *pendingDeclaration, reply string) (string, bool) {
+	if !deployReply.MatchString(reply) {
+		slog.Debug("Discarding generated AS3 declaration", "tenants", p.tenants)
+		return "", false
+	}
+
+	// The guardrail judges the request, so say whether it replaces anything
+	var existing []string
+	for _, t := range p.tenants {
+		exists, err := i.bigipClient.TenantExists(t)
+		if err != nil {
+			return fmt.Sprintf("The declaration wasn't deployed: %v", err), true
+		}
+		if exists {
+			existing = append(existing, t)
+		}
+	}
+	request := fmt.Sprintf("Deploy an AS3 declaration creating new tenant %s for: %s", strings.Join(p.tenants, ", "), p.description)
+	if len(existing) > 0 {
+		request = fmt.Sprintf("Deploy an AS3 declaration that will replace the existing configuration of tenant %s with: %s", strings.Join(existing, ", "), p.description)
+	}
+	call := &llm.ToolCall{Name: llm.ToolDeployAS3, Args: map[string]string{"tenants": strings.Join(p.tenants, ",")}}
+	if message, ok := i.guard(request, call); !ok {
+		return message, true
+	}
+
+	// Journal each tenant's declaration as it was, to show what was replaced
+	changes := make([]journal.Entry, len(p.tenants))
+	for n, t := range p.tenants {
+		changes[n] = journal.Entry{Operation: llm.ToolDeployAS3, Object: "tenant " + t, Request: p.description, After: p.declaration, Result: journal.Applied}
+		if before, err := i.bigipClient.GetDeclaration(t); err != nil {
+			slog.Warn("Journaling the deployment without the tenant's earlier declaration", "tenant", t, "err", err)
+		} else {
+			changes[n].Before = before
+		}
+	}
+
+	results, err := i.bigipClient.DeployAS3(p.declaration)
+	for n, change := range changes {
+		tenantResult(&change, p.tenants[n], results, err)
+		i.journalChange(change)
+	}
+	var timeout *bigip.AS3TimeoutError
+	if errors.As(err, &timeout) {
+		// Still running on the device: deploying it again would queue a
+		// second copy behind the first
+		slog.Error("AS3 declaration still being applied", "tenants", p.tenants, "task", timeout.TaskID)
+		return fmt.Sprintf("AS3 is still applying the declaration after %s, so I stopped waiting. It may yet succeed or fail: check task %s (GET %s on the BIG-IP) rather than deploying it again.",
+			timeout.After, timeout.TaskID, timeout.Endpoint), true
+	}
+	if err != nil {
+		slog.Error("Failed to deploy AS3 declaration", "tenants", p.tenants, "err", err)
+		// Keep it so the user can retry once the problem is fixed
+		i.mu.Lock()
+		i.pendingAS3 = p
+		i.mu.Unlock()
+		return fmt.Sprintf("The declaration wasn't deployed: %v\nReply 'deploy' to try again.", err), true
+	}
+	slog.Info("Deployed AS3 declaration", "tenants", p.tenants)
+	i.setLastCall(call)
+
+	var sb strings.Builder
+	sb.WriteString("Deployed the AS3 declaration:\n")
+	for _, r := range results {
+		fmt.Fprintf(&sb, "- %s: %s\n", r.Tenant, r.Message)
+	}
+	response := strings.TrimRight(sb.String(), "\n")
+	i.remember(reply, response)
+	return response, true
+}
+
+// tenantResult sets a journaled change to the outcome AS3 reported for its
+// tenant. A tenant without a finished result didn't get that far, and err,
+// if any, says why.
+func tenantResult(change *journal.Entry, tenant string, results []bigip.AS3Result, err error) {
+	for _, r := range results {
+		if r.Tenant != tenant || r.Code == 0 || r.Message == "in progress" {
+			continue
+		}
+		if r.Code >= 400 {
+			change.Result, change.Error = journal.Failed, fmt.Sprintf("%s (HTTP %d)", r.Message, r.Code)
+		}
+		return
+	}
+	if err != nil {
+		change.Result, change.Error = journal.Failed, err.Error()
+	}
+}
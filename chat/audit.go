@@ -0,0 +1,66 @@
+package chat
+
+import (
+	"log/slog"
+
+	"f5chat/audit"
+	"f5chat/bigip"
+)
+
+// callTaker is a BigIPClient that records the iControl REST calls it makes
+type callTaker interface {
+	TakeCalls() []bigip.Call
+}
+
+// SetAudit records each query, what it resolved to and the REST calls made
+// for it in log
+func (i *Interface) SetAudit(log *audit.Log) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.audit = log
+}
+
+// takeCalls returns the REST calls the session's clients have made since it
+// was last called, and forgets them
+func (i *Interface) takeCalls() []bigip.Call {
+	i.mu.Lock()
+	clients := []BigIPClient{i.bigipClient}
+	for _, d := range i.devices {
+		clients = append(clients, d.client)
+	}
+	i.mu.Unlock()
+
+	var calls []bigip.Call
+	seen := map[BigIPClient]bool{}
+	for _, client := range clients {
+		if seen[client] {
+			continue
+		}
+		seen[client] = true
+		if taker, ok := client.(callTaker); ok {
+			calls = append(calls, taker.TakeCalls()...)
+		}
+	}
+	return calls
+}
+
+// recordAudit writes the query's entry in the audit log, if there is one,
+// with the failure explainFailure found
+func (i *Interface) recordAudit(query string, ops []operation) {
+	i.mu.Lock()
+	log, device, failed := i.audit, i.notifierDevice, i.failureMessage
+	if i.failure == "" {
+		failed = ""
+	}
+	i.mu.Unlock()
+	if log == nil {
+		return
+	}
+	entry := audit.Entry{Device: device, Query: query, Calls: i.takeCalls(), Error: failed}
+	for _, op := range ops {
+		entry.Intent = append(entry.Intent, audit.Intent{Operation: op.Name, Args: op.Args, Device: op.Device})
+	}
+	if err := log.Record(entry); err != nil {
+		slog.Error("Failed to write the audit log", "err", err)
+	}
+}
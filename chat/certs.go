@@ -0,0 +1,21 @@
+package chat
+
+import (
+	"time"
+
+	"f5chat/utils"
+)
+
+// certWarnDays is how soon before it expires a certificate is reported as
+// expiring
+const certWarnDays = 30
+
+// CertificateReport lists the device's SSL certificates by expiry, marking
+// those expired or expiring within certWarnDays
+func (i *Interface) CertificateReport() (string, error) {
+	certs, err := i.bigipClient.GetCertificates()
+	if err != nil {
+		return "", err
+	}
+	return utils.FormatCertificates(certs, time.Now(), certWarnDays), nil
+}
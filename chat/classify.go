@@ -0,0 +1,33 @@
+package chat
+
+import (
+	"log/slog"
+
+	"f5chat/config"
+	"f5chat/intent"
+	"f5chat/llm"
+	"f5chat/rag"
+)
+
+// EnableIntentClassifier routes common requests by embedding similarity
+// instead of a chat completion. It reports false when the LLM provider
+// can't produce embeddings.
+func (i *Interface) EnableIntentClassifier(cfg *config.Config) bool {
+	embedder, ok := i.llmClient.(rag.Embedder)
+	if !ok {
+		slog.Warn("LLM provider does not support embeddings; intent classifier disabled", "provider", i.llmClient.Name())
+		return false
+	}
+	i.classifier = intent.NewClassifier(embedder, cfg.IntentMinScore, cfg.IntentMinMargin)
+	return true
+}
+
+// classify returns the tool call for the query when the classifier is
+// enabled and confident
+func (i *Interface) classify(query string) (*llm.ToolCall, bool) {
+	if i.classifier == nil {
+		return nil, false
+	}
+	call, _, ok := i.classifier.Classify(query)
+	return call, ok
+}
@@ -0,0 +1,182 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// compared is an object found for a comparison: its full path and the
+// value whose fields are compared
+type compared struct {
+	fullPath string
+	value    interface{}
+}
+
+// compareKindName and compareKindNames name each kind in messages
+var compareKindName = map[string]string{
+	llm.CompareVirtualServer: "virtual server",
+	llm.ComparePool:          "pool",
+	llm.CompareNode:          "node",
+	llm.CompareWAFPolicy:     "WAF policy",
+	llm.CompareProfile:       "profile",
+}
+
+var compareKindNames = map[string]string{
+	llm.CompareVirtualServer: "virtual servers",
+	llm.ComparePool:          "pools",
+	llm.CompareNode:          "nodes",
+	llm.CompareWAFPolicy:     "WAF policies",
+	llm.CompareProfile:       "profiles",
+}
+
+// compareObjects shows the configuration fields that differ between two
+// objects of the same kind. When the kind isn't given it is the first kind
+// that has both objects.
+func (i *Interface) compareObjects(call *llm.ToolCall) (string, error) {
+	first, second := strings.Trim(call.Arg("first"), "\"'`"), strings.Trim(call.Arg("second"), "\"'`")
+	if first == "" || second == "" {
+		return "Which two objects should I compare? For example: 'compare vs_app1 with vs_app2'.", nil
+	}
+	kind := strings.ToLower(call.Arg("kind"))
+	profileType := strings.ToLower(call.Arg("profile_type"))
+	if kind == llm.CompareProfile && profileType == "" {
+		return "Which type of profile? For example: 'compare http profiles http and http_xff'.", nil
+	}
+
+	kinds := []string{llm.CompareVirtualServer, llm.ComparePool, llm.CompareNode, llm.CompareWAFPolicy}
+	if kind != "" {
+		if _, ok := compareKindNames[kind]; !ok {
+			return "", fmt.Errorf("can't compare objects of kind '%s' (supported: %s)", kind, strings.Join(llm.CompareKinds, ", "))
+		}
+		kinds = []string{kind}
+	}
+
+	for _, k := range kinds {
+		objects, err := i.comparables(k, profileType)
+		if err != nil {
+			if len(kinds) > 1 {
+				// Try the next kind; e.g. ASM may not be provisioned
+				continue
+			}
+			return "", err
+		}
+		left, lerr := pickComparable(k, first, objects)
+		right, rerr := pickComparable(k, second, objects)
+		if len(kinds) > 1 && (lerr != nil || rerr != nil) {
+			continue
+		}
+		if err := errors.Join(lerr, rerr); err != nil {
+			return "", err
+		}
+
+		if k == llm.CompareWAFPolicy {
+			// The listing leaves out most settings
+			if left.value, err = i.bigipClient.GetWAFPolicyDetails(left.fullPath); err != nil {
+				return "", err
+			}
+			if right.value, err = i.bigipClient.GetWAFPolicyDetails(right.fullPath); err != nil {
+				return "", err
+			}
+		}
+		diffs, same, err := utils.Diff(left.value, right.value)
+		if err != nil {
+			return "", fmt.Errorf("failed to compare %s: %w", compareKindNames[k], err)
+		}
+		i.setData(map[string]interface{}{"kind": k, "first": left.fullPath, "second": right.fullPath, "differences": diffs, "same": same})
+		if call.Arg(llm.CompareStyle) == llm.CompareUnified {
+			lf, lerr := utils.Flatten(left.value)
+			rf, rerr := utils.Flatten(right.value)
+			if err := errors.Join(lerr, rerr); err != nil {
+				return "", fmt.Errorf("failed to compare %s: %w", compareKindNames[k], err)
+			}
+			return utils.FormatComparisonUnified(compareKindNames[k], left.fullPath, right.fullPath, lf, rf, diffs, same), nil
+		}
+		return utils.FormatComparison(compareKindNames[k], left.fullPath, right.fullPath, diffs, same), nil
+	}
+	return "", fmt.Errorf("couldn't find both '%s' and '%s' among the virtual servers, pools, nodes or WAF policies", first, second)
+}
+
+// comparables fetches the objects of a kind
+func (i *Interface) comparables(kind, profileType string) ([]compared, error) {
+	var out []compared
+	switch kind {
+	case llm.CompareVirtualServer:
+		vs, err := i.bigipClient.GetVirtualServers()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vs {
+			out = append(out, compared{fullPath: v.FullPath, value: v})
+		}
+	case llm.ComparePool:
+		pools, members, err := i.bigipClient.GetPools()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pools {
+			// Members are listed separately from the pool itself; go-bigip
+			// marshals pools itself, so they are added to its JSON form
+			value, err := utils.Flatten(p)
+			if err != nil {
+				return nil, err
+			}
+			value["members"] = strings.Join(bigip.MemberPaths(members[p.FullPath]), ", ")
+			out = append(out, compared{fullPath: p.FullPath, value: value})
+		}
+	case llm.CompareNode:
+		nodes, err := i.bigipClient.GetNodes()
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			out = append(out, compared{fullPath: n.FullPath, value: n})
+		}
+	case llm.CompareWAFPolicy:
+		policies, err := i.bigipClient.GetWAFPolicies()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range policies {
+			out = append(out, compared{fullPath: p.FullPath, value: p})
+		}
+	case llm.CompareProfile:
+		profiles, err := i.bigipClient.GetProfiles(profileType)
+		var notFound *bigip.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("there is no '%s' profile type; use the tmsh name, e.g. http, tcp or client-ssl", profileType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range profiles {
+			out = append(out, compared{fullPath: p.FullPath(), value: p})
+		}
+	}
+	return out, nil
+}
+
+// pickComparable finds the one object a name means
+func pickComparable(kind, name string, objects []compared) (compared, error) {
+	candidates := make([]named, len(objects))
+	for n, o := range objects {
+		short := o.fullPath[strings.LastIndex(o.fullPath, "/")+1:]
+		candidates[n] = named{name: short, fullPath: o.fullPath}
+	}
+	matches := matchNames(name, candidates)
+	switch len(matches) {
+	case 0:
+		return compared{}, fmt.Errorf("no %s named '%s'", compareKindName[kind], name)
+	case 1:
+		for _, o := range objects {
+			if o.fullPath == matches[0] {
+				return o, nil
+			}
+		}
+	}
+	return compared{}, fmt.Errorf("'%s' matches several %s (%s); use the full path", name, compareKindNames[kind], strings.Join(matches, ", "))
+}
@@ -0,0 +1,66 @@
+package chat
+
+import "strings"
+
+// slashCommands are the commands Tab completes at the start of a line
+var slashCommands = []string{
+	"/agent", "/compliance", "/device", "/format", "/health", "/history", "/partition", "/plan", "/redactions", "/reset",
+	"/run", "/save", "/saved", "/snapshot", "/snapshots", "/unsave", "/usage", "/verbose",
+}
+
+// Complete returns the words that could end head, the line typed so far,
+// for Tab completion: slash commands at the start of the line, output
+// formats after /format, profiles after /device, saved query names after
+// /run and /unsave, and otherwise the names and full paths of the virtual
+// servers, pools, nodes and WAF policies. Object names come from the BIG-IP client, which serves
+// them from its response cache once they've been listed.
+func (i *Interface) Complete(head string) []string {
+	if strings.HasPrefix(head, "/") && !strings.Contains(head, " ") {
+		return slashCommands
+	}
+	if fields := strings.Fields(head); len(fields) > 0 {
+		switch fields[0] {
+		case "/format":
+			return OutputFormats
+		case "/device":
+			return i.profileNames()
+		case "/run", "/unsave":
+			if saved := i.savedQueries(); saved != nil {
+				return saved.Names()
+			}
+			return nil
+		}
+	}
+	return i.objectNames()
+}
+
+// objectNames returns the names and full paths of the objects queries
+// usually name. Kinds that can't be read, such as WAF policies without
+// ASM, are left out.
+func (i *Interface) objectNames() []string {
+	var names []string
+	add := func(name, fullPath string) {
+		names = append(names, name, fullPath)
+	}
+	if vs, err := i.bigipClient.GetVirtualServers(); err == nil {
+		for _, v := range vs {
+			add(v.Name, v.FullPath)
+		}
+	}
+	if pools, _, err := i.bigipClient.GetPools(); err == nil {
+		for _, p := range pools {
+			add(p.Name, p.FullPath)
+		}
+	}
+	if nodes, err := i.bigipClient.GetNodes(); err == nil {
+		for _, n := range nodes {
+			add(n.Name, n.FullPath)
+		}
+	}
+	if policies, err := i.bigipClient.GetWAFPolicies(); err == nil {
+		for _, p := range policies {
+			add(p.Name, p.FullPath)
+		}
+	}
+	return names
+}
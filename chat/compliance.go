@@ -0,0 +1,194 @@
+package chat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/compliance"
+	"f5chat/utils"
+)
+
+// accountHolder is a client that says who it signs in as, for the
+// compliance device rules
+type accountHolder interface {
+	Account() (host, user, authMethod string)
+}
+
+// SetComplianceRules sets the rules ComplianceReport checks, the built-in
+// ones if it isn't called
+func (i *Interface) SetComplianceRules(rules []compliance.Rule) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.complianceRules = rules
+}
+
+// ComplianceReport checks the device's configuration against the
+// compliance rules, returning the formatted report and whether every rule
+// passed
+func (i *Interface) ComplianceReport() (string, bool, error) {
+	i.mu.Lock()
+	rules := i.complianceRules
+	i.mu.Unlock()
+	if rules == nil {
+		rules = compliance.Builtin()
+	}
+	objects, unavailable, err := i.complianceObjects()
+	if err != nil {
+		return "", false, err
+	}
+	report := compliance.Evaluate(rules, objects)
+	report.Unavailable = unavailable
+	return utils.FormatCompliance(report), report.Failed() == 0, nil
+}
+
+// complianceObjects reads the configuration the rules check. Kinds the
+// device doesn't have, such as WAF policies without ASM, are returned as
+// unavailable rather than failing the report.
+func (i *Interface) complianceObjects() ([]compliance.Object, []string, error) {
+	virtuals, err := i.bigipClient.GetVirtualServers()
+	if err != nil {
+		return nil, nil, err
+	}
+	pools, members, err := i.bigipClient.GetPools()
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes, err := i.bigipClient.GetNodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var objects []compliance.Object
+	add := func(kind string, fields map[string]string) {
+		objects = append(objects, compliance.Object{Kind: kind, Fields: fields})
+	}
+	monitors := map[string]string{}
+	for _, p := range pools {
+		monitors[p.FullPath] = strings.TrimSpace(p.Monitor)
+		add("pool", map[string]string{
+			"name":                p.FullPath,
+			"partition":           p.Partition,
+			"monitor":             strings.TrimSpace(p.Monitor),
+			"load_balancing_mode": p.LoadBalancingMode,
+			"members":             strconv.Itoa(len(members[p.FullPath])),
+			"min_active_members":  strconv.Itoa(p.MinActiveMembers),
+		})
+	}
+	for _, vs := range virtuals {
+		add("virtual", map[string]string{
+			"name":        vs.FullPath,
+			"partition":   vs.Partition,
+			"destination": vs.Destination,
+			"pool":        vs.Pool,
+			"monitor":     monitors[vs.Pool],
+			"enabled":     strconv.FormatBool(!vs.Disabled),
+			"rules":       strings.Join(vs.Rules, " "),
+			"policies":    strings.Join(vs.Policies, " "),
+		})
+	}
+	for _, n := range nodes {
+		add("node", map[string]string{
+			"name":      n.FullPath,
+			"partition": n.Partition,
+			"address":   n.Address,
+			"monitor":   strings.TrimSpace(n.Monitor),
+			"state":     n.State,
+		})
+	}
+
+	var unavailable []string
+	profiles, err := i.bigipClient.GetProfiles("client-ssl")
+	switch {
+	case unprovisioned(err):
+		unavailable = append(unavailable, "client-ssl")
+	case err != nil:
+		return nil, nil, err
+	}
+	for _, p := range profiles {
+		add("client-ssl", map[string]string{
+			"name":          p.FullPath(),
+			"partition":     partitionOf("", p.FullPath()),
+			"defaults_from": profileField(p, "defaultsFrom"),
+			"options":       profileOptions(p),
+			"ciphers":       profileField(p, "ciphers"),
+			"cert":          profileCert(p),
+		})
+	}
+	policies, err := i.bigipClient.GetWAFPolicies()
+	switch {
+	case unprovisioned(err):
+		unavailable = append(unavailable, "waf")
+	case err != nil:
+		return nil, nil, err
+	}
+	for _, p := range policies {
+		add("waf", map[string]string{
+			"name":              p.FullPath,
+			"partition":         partitionOf("", p.FullPath),
+			"enforcement_mode":  p.EnforcementMode,
+			"active":            strconv.FormatBool(p.Active),
+			"signature_staging": strconv.FormatBool(p.SignatureStaging),
+			"days_unchanged":    daysSince(p.VersionDatetime, time.Now()),
+			"virtual_servers":   strings.Join(p.VirtualServers, " "),
+		})
+	}
+	if account, ok := i.bigipClient.(accountHolder); ok {
+		host, user, method := account.Account()
+		add("device", map[string]string{"name": host, "host": host, "user": user, "auth_method": method})
+	}
+	return objects, unavailable, nil
+}
+
+// profileField returns a profile property as text, "" if it isn't set
+func profileField(p bigip.Profile, key string) string {
+	v, ok := p[key]
+	if !ok || v == nil || v == "none" {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// profileOptions returns an SSL profile's options separated by spaces. The
+// device lists them as "tmOptions", an array or a tmsh "{ a b }" string, or
+// on older versions as "options".
+func profileOptions(p bigip.Profile) string {
+	v, ok := p["tmOptions"]
+	if !ok {
+		v = p["options"]
+	}
+	switch options := v.(type) {
+	case []interface{}:
+		words := make([]string, len(options))
+		for n, o := range options {
+			words[n] = fmt.Sprint(o)
+		}
+		return strings.Join(words, " ")
+	case string:
+		return strings.Join(strings.Fields(strings.Trim(options, "{} ")), " ")
+	}
+	return ""
+}
+
+// profileCert returns an SSL profile's certificate, from its cert-key chain
+// if it has one
+func profileCert(p bigip.Profile) string {
+	if chain, ok := p["certKeyChain"].([]interface{}); ok && len(chain) > 0 {
+		if entry, ok := chain[0].(map[string]interface{}); ok && entry["cert"] != nil {
+			return fmt.Sprint(entry["cert"])
+		}
+	}
+	return profileField(p, "cert")
+}
+
+// daysSince returns the whole days from an RFC 3339 time to now, "" if it
+// can't be read
+func daysSince(timestamp string, now time.Time) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(int(now.Sub(t).Hours() / 24))
+}
@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"f5chat/llm"
+	"f5chat/prompt"
+	"f5chat/rag"
+)
+
+// minWordScore is the share of a concept question's words a documentation
+// section must contain to be shown without an LLM
+const minWordScore = 0.5
+
+// answerConcept answers a general question about how BIG-IP works from the
+// model and the documentation, without any request to the device
+func (i *Interface) answerConcept(query string) (string, error) {
+	_, docs := i.withDocumentation(nil, query)
+	input := query
+	if len(docs) > 0 {
+		input = rag.FormatContext(docs) + "\nQuestion: " + query
+	}
+
+	answer, err := i.llmClient.RunTask(prompt.Concepts, input)
+	if errors.Is(err, llm.ErrNoLLM) {
+		return i.conceptFromCorpus(query), nil
+	}
+	if message, ok := unavailableMessage(err); ok {
+		return message, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("I couldn't get an explanation right now. Please try again. (Error: %v)", err)
+	}
+	answer = strings.TrimSpace(answer)
+	i.remember(query, answer)
+	return citeSources(answer, docs), nil
+}
+
+// conceptFromCorpus shows the built-in documentation section that best
+// matches the question, for running without an LLM
+func (i *Interface) conceptFromCorpus(query string) string {
+	docs, err := rag.LoadDocuments("")
+	if err != nil {
+		slog.Warn("Failed to load the documentation corpus", "err", err)
+	}
+	results := rag.SearchWords(docs, query, 1)
+	if len(results) == 0 || results[0].Score < minWordScore {
+		return "Explaining BIG-IP concepts needs an LLM, and the built-in documentation doesn't cover this question; run without -no-llm to get an answer."
+	}
+	best := results[0]
+	return fmt.Sprintf("From the built-in documentation (%s):\n\n%s\n\nSources: %s", best.Title, best.Text, best.Source)
+}
@@ -0,0 +1,243 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+// device is a BIG-IP that requests across all devices ask, by name
+type device struct {
+	name   string
+	client BigIPClient
+}
+
+// AddDevice makes a BIG-IP available to requests such as "show pools
+// across all devices". Add the one given to NewInterface too, under its
+// own name, for it to be asked as well.
+func (i *Interface) AddDevice(name string, client BigIPClient) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.devices = append(i.devices, device{name: name, client: client})
+}
+
+func (i *Interface) deviceList() []device {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]device(nil), i.devices...)
+}
+
+// onDevice returns an interface that runs operations on another BIG-IP.
+// It shares nothing but the LLM and the guardrail with i.
+func (i *Interface) onDevice(d device) *Interface {
+	return &Interface{bigipClient: d.client, llmClient: i.llmClient, maxRisk: i.maxRisk, concurrent: true}
+}
+
+// eachDevice runs fn on every device at once and returns the results in
+// the order the devices were added
+func eachDevice[T any](devices []device, fn func(device) T) []T {
+	results := make([]T, len(devices))
+	var wg sync.WaitGroup
+	for n, d := range devices {
+		wg.Add(1)
+		go func(n int, d device) {
+			defer wg.Done()
+			results[n] = fn(d)
+		}(n, d)
+	}
+	wg.Wait()
+	return results
+}
+
+// answerFanOut runs a read on every configured BIG-IP, or looks for an
+// object on each, and labels each result with its device
+func (i *Interface) answerFanOut(query string, fan *llm.FanOut) (string, error) {
+	devices := i.deviceList()
+	if len(devices) < 2 {
+		return "Only one BIG-IP is configured. List several in BIGIP_DEVICES (e.g. lab=10.1.1.245,prod=bigip.example.com:8443) to ask them all at once.", nil
+	}
+	if fan.Name != "" {
+		return i.locate(query, fan, devices), nil
+	}
+	if fan.Call == nil {
+		return fmt.Sprintf("I can only run listings and lookups across all devices, and couldn't tell which one '%s' asks for. Try e.g. \"show pools across all devices\" or \"which device has pool web_pool\".", fan.Text), nil
+	}
+
+	call := fan.Call
+	fillListArgs(fan.Text, call)
+	i.scopeToPartition(fan.Text, call)
+	i.mu.Lock()
+	dryRun := i.dryRun
+	i.mu.Unlock()
+	if dryRun {
+		return fmt.Sprintf("%s  (on each of %d devices: %s)\n\nNothing was run; ask again without /plan to run it.",
+			strings.TrimRight(formatPlan(call), "\n"), len(devices), deviceNames(devices)), nil
+	}
+
+	type result struct {
+		response string
+		err      error
+		ops      []operation
+	}
+	results := eachDevice(devices, func(d device) result {
+		// Each device gets its own copy; operations may fill in arguments
+		c := &llm.ToolCall{Name: call.Name, Args: make(map[string]string, len(call.Args))}
+		for k, v := range call.Args {
+			c.Args[k] = v
+		}
+		dev := i.onDevice(d)
+		response, err := dev.executeTool(c)
+		ops := dev.takeOperations()
+		for n := range ops {
+			ops[n].Device = d.name
+		}
+		return result{response, err, ops}
+	})
+
+	var sb strings.Builder
+	failed := 0
+	fmt.Fprintf(&sb, "Asked %d devices: %s.\n", len(devices), deviceNames(devices))
+	for n, d := range devices {
+		i.addOperations(results[n].ops)
+		fmt.Fprintf(&sb, "\n##### Device: %s #####\n", d.name)
+		if err := results[n].err; err != nil {
+			failed++
+			fmt.Fprintf(&sb, "Couldn't get this from %s: %v\n", d.name, err)
+			continue
+		}
+		sb.WriteString(strings.Trim(results[n].response, "\n") + "\n")
+	}
+	if failed == len(devices) {
+		return "", fmt.Errorf("none of the %d devices answered:\n%s", len(devices), sb.String())
+	}
+
+	response := strings.TrimRight(sb.String(), "\n")
+	i.setLastCall(call)
+	i.remember(query, response)
+	return response, nil
+}
+
+// located is what was found of an object on one device
+type located struct {
+	found []string
+	err   error
+}
+
+// locate answers "which device has pool payments_pool"
+func (i *Interface) locate(query string, fan *llm.FanOut, devices []device) string {
+	results := eachDevice(devices, func(d device) located {
+		return findObject(d.client, fan.Kind, fan.Name)
+	})
+
+	what, subject := fan.Name, fan.Name
+	if fan.Kind != "" {
+		what = fan.Kind + " " + fan.Name
+		subject = capitalize(what)
+	}
+	args := map[string]string{"name": fan.Name}
+	if fan.Kind != "" {
+		args["kind"] = fan.Kind
+	}
+	var on, missing, unchecked []string
+	var sb strings.Builder
+	for n, d := range devices {
+		i.addOperations([]operation{{Name: "find_object", Args: args, Device: d.name, Data: results[n].found}})
+		switch r := results[n]; {
+		case len(r.found) > 0:
+			on = append(on, fmt.Sprintf("  %-12s %s", d.name, strings.Join(r.found, ", ")))
+		case r.err != nil:
+			unchecked = append(unchecked, fmt.Sprintf("%s (%v)", d.name, r.err))
+		default:
+			missing = append(missing, d.name)
+		}
+	}
+	switch len(on) {
+	case 0:
+		fmt.Fprintf(&sb, "No device has %s.\n", what)
+	default:
+		fmt.Fprintf(&sb, "%s is on %d of %d devices:\n%s\n", subject, len(on), len(devices), strings.Join(on, "\n"))
+		if len(missing) > 0 {
+			fmt.Fprintf(&sb, "Not on: %s.\n", strings.Join(missing, ", "))
+		}
+	}
+	if len(unchecked) > 0 {
+		fmt.Fprintf(&sb, "Couldn't check: %s.\n", strings.Join(unchecked, "; "))
+	}
+	response := strings.TrimRight(sb.String(), "\n")
+	i.remember(query, response)
+	return response
+}
+
+// findObject looks for objects of a kind (any kind when empty) whose name
+// or full path matches name, which may use * wildcards
+func findObject(client BigIPClient, kind, name string) located {
+	pattern := strings.ToLower(name)
+	matches := func(names ...string) bool {
+		for _, n := range names {
+			if ok, _ := path.Match(pattern, strings.ToLower(n)); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	var r located
+	if kind == "" || kind == llm.LocateVirtualServer {
+		vs, err := client.GetVirtualServers()
+		r.err = errors.Join(r.err, err)
+		for _, v := range vs {
+			if matches(v.Name, v.FullPath) {
+				r.found = append(r.found, "virtual server "+v.FullPath)
+			}
+		}
+	}
+	if kind == "" || kind == llm.LocatePool {
+		pools, _, err := client.GetPools()
+		r.err = errors.Join(r.err, err)
+		for _, p := range pools {
+			if matches(p.Name, p.FullPath) {
+				r.found = append(r.found, "pool "+p.FullPath)
+			}
+		}
+	}
+	if kind == "" || kind == llm.LocateNode {
+		nodes, err := client.GetNodes()
+		r.err = errors.Join(r.err, err)
+		for _, n := range nodes {
+			if matches(n.Name, n.FullPath, n.Address) {
+				r.found = append(r.found, "node "+n.FullPath)
+			}
+		}
+	}
+	if kind == "" || kind == llm.LocateWAFPolicy {
+		policies, err := client.GetWAFPolicies()
+		// Without ASM there are simply no policies to find
+		if !errors.As(err, new(*bigip.ModuleNotProvisionedError)) {
+			r.err = errors.Join(r.err, err)
+		}
+		for _, p := range policies {
+			if matches(p.Name, p.FullPath) {
+				r.found = append(r.found, "WAF policy "+p.FullPath)
+			}
+		}
+	}
+	if kind == llm.LocateIRule {
+		if rule, err := client.GetIRule(name); err == nil {
+			r.found = append(r.found, "iRule "+rule.FullPath)
+		}
+	}
+	return r
+}
+
+func deviceNames(devices []device) string {
+	names := make([]string, len(devices))
+	for n, d := range devices {
+		names[n] = d.name
+	}
+	return strings.Join(names, ", ")
+}
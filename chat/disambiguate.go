@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"f5chat/llm"
+)
+
+// maxChoices caps the numbered list; more matches need a more specific name
+const maxChoices = 10
+
+// pendingChoice is a numbered list of objects matching a name, awaiting the
+// user's pick; the chosen full path is passed to tool as its name
+type pendingChoice struct {
+	tool    string
+	options []string
+}
+
+// named is an object as matched by name: its short name and full path
+type named struct {
+	name     string
+	fullPath string
+}
+
+// matchNames returns the full paths of the objects name could mean, from
+// the most to the least specific: the full path, the exact name (possibly
+// in several partitions), the name ignoring case, then names containing it
+func matchNames(name string, objects []named) []string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	tests := []func(o named) bool{
+		func(o named) bool { return o.fullPath == name },
+		func(o named) bool { return o.name == name },
+		func(o named) bool { return strings.EqualFold(o.name, name) || strings.EqualFold(o.fullPath, name) },
+		func(o named) bool { return strings.Contains(strings.ToLower(o.name), strings.ToLower(name)) },
+	}
+	for _, test := range tests {
+		var matches []string
+		for _, o := range objects {
+			if test(o) {
+				matches = append(matches, o.fullPath)
+			}
+		}
+		if len(matches) > 0 {
+			return matches
+		}
+	}
+	return nil
+}
+
+// askChoice lists the objects that match name and remembers them, so the
+// next reply can pick one by number or full path; kinds is plural ("pools")
+func (i *Interface) askChoice(tool, kinds, name string, options []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Several %s match '%s':\n", kinds, name)
+	shown := options
+	if len(shown) > maxChoices {
+		shown = shown[:maxChoices]
+	}
+	for n, o := range shown {
+		fmt.Fprintf(&sb, "  %d. %s\n", n+1, o)
+	}
+	if len(options) > len(shown) {
+		fmt.Fprintf(&sb, "  ... and %d more; use a longer name to narrow it down.\n", len(options)-len(shown))
+	}
+	sb.WriteString("Which one did you mean? Reply with its number or full path.")
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.choice = &pendingChoice{tool: tool, options: shown}
+	return sb.String()
+}
+
+// pickChoice returns the operation for a reply picking one of the listed
+// objects. Any other reply drops the list and is handled as a new query.
+func (i *Interface) pickChoice(reply string) (*llm.ToolCall, bool) {
+	i.mu.Lock()
+	p := i.choice
+	i.choice = nil
+	i.mu.Unlock()
+	if p == nil {
+		return nil, false
+	}
+
+	reply = strings.Trim(strings.TrimSpace(reply), ".")
+	if n, err := strconv.Atoi(strings.TrimPrefix(reply, "#")); err == nil {
+		if n < 1 || n > len(p.options) {
+			return nil, false
+		}
+		return &llm.ToolCall{Name: p.tool, Args: map[string]string{"name": p.options[n-1]}}, true
+	}
+	for _, o := range p.options {
+		if strings.EqualFold(reply, o) {
+			return &llm.ToolCall{Name: p.tool, Args: map[string]string{"name": o}}, true
+		}
+	}
+	return nil, false
+}
+
+// choosing reports whether the latest answer asked the user to pick an object
+func (i *Interface) choosing() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.choice != nil
+}
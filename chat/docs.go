@@ -0,0 +1,51 @@
+package chat
+
+import (
+	"log/slog"
+	"strings"
+
+	"f5chat/config"
+	"f5chat/llm"
+	"f5chat/rag"
+)
+
+// EnableDocumentation grounds answers in the F5 documentation corpus. It
+// reports false when the LLM provider can't produce embeddings.
+func (i *Interface) EnableDocumentation(cfg *config.Config) bool {
+	embedder, ok := i.llmClient.(rag.Embedder)
+	if !ok {
+		slog.Warn("LLM provider does not support embeddings; documentation retrieval disabled", "provider", i.llmClient.Name())
+		return false
+	}
+	i.retriever = rag.NewRetriever(cfg, embedder)
+	return true
+}
+
+// withDocumentation appends the documentation relevant to the query to the
+// conversation as a system message. Retrieval problems are logged and the
+// query is answered without documentation.
+func (i *Interface) withDocumentation(history []llm.Message, query string) ([]llm.Message, []rag.Result) {
+	if i.retriever == nil {
+		return history, nil
+	}
+	docs, err := i.retriever.Retrieve(query)
+	if err != nil {
+		slog.Warn("Documentation retrieval failed; answering without it", "err", err)
+		return history, nil
+	}
+	if len(docs) == 0 {
+		return history, nil
+	}
+	for _, d := range docs {
+		slog.Debug("Retrieved documentation", "source", d.Source, "section", d.Title, "score", d.Score)
+	}
+	return append(history, llm.Message{Role: llm.RoleSystem, Content: rag.FormatContext(docs)}), docs
+}
+
+// citeSources appends the documents an answer was grounded in
+func citeSources(answer string, docs []rag.Result) string {
+	if len(docs) == 0 {
+		return answer
+	}
+	return answer + "\n\nSources: " + strings.Join(rag.Sources(docs), ", ")
+}
@@ -0,0 +1,205 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"f5chat/llm"
+)
+
+// Kinds of object a follow-up can refer back to
+const (
+	kindVirtualServer = "virtual server"
+	kindPool          = "pool"
+	kindWAFPolicy     = "WAF policy"
+	kindIRule         = "iRule"
+)
+
+// entity is an object an answer was about
+type entity struct {
+	kind string
+	name string
+	// pool is a virtual server's default pool, so "its members" resolves
+	pool string
+}
+
+// pronoun matches words that point back at an earlier answer. A bare
+// "that" is left out: "virtual servers that use web_pool" refers to nothing.
+var pronoun = regexp.MustCompile(`(?i)\b(it|its|it's|them|same)\b|\b(that|this)\s+(one|pool|policy|irule|virtual server|vs|vip)\b`)
+
+// readVerbs are the requests a reference can be answered from directly;
+// anything else ("disable it", "why is it down?") is left to the LLM with
+// the context note
+var (
+	readVerbs = regexp.MustCompile(`(?i)\b(show|list|display|get|describe|explain|details?|members?|status|view)\b`)
+	notReads  = regexp.MustCompile(`(?i)\b(why|how|mean|means|disable|enable|delete|remove|add|create|change|set)\b`)
+)
+
+// kindWords pick out which earlier object a reference means
+var kindWords = []struct {
+	pattern *regexp.Regexp
+	kind    string
+}{
+	{regexp.MustCompile(`(?i)\bmembers?\b|\bpool\b`), kindPool},
+	{regexp.MustCompile(`(?i)\b(waf|asm)\b|\bpolicy\b`), kindWAFPolicy},
+	{regexp.MustCompile(`(?i)\birule\b`), kindIRule},
+	{regexp.MustCompile(`(?i)\b(virtual[\s-]*server|vip|vs)\b`), kindVirtualServer},
+}
+
+// noteEntities records the objects a successful operation was about, most
+// recent first and one per kind. Listings count only for objects the query
+// named, e.g. "show virtual server vs_app1".
+func (i *Interface) noteEntities(query string, call *llm.ToolCall) {
+	if i.choosing() {
+		// The answer asked which object was meant
+		return
+	}
+	var found []entity
+	switch call.Name {
+	case llm.ToolGetPool:
+		found = append(found, entity{kind: kindPool, name: call.Arg("name")})
+	case llm.ToolGetWAFPolicy:
+		if name := call.Arg("name"); name != "" {
+			found = append(found, entity{kind: kindWAFPolicy, name: name})
+		}
+	case llm.ToolExplainIRule:
+		found = append(found, entity{kind: kindIRule, name: call.Arg("name")})
+	case llm.ToolListVirtualServers:
+		// Served from the cache the listing just filled
+		if vs, err := i.bigipClient.GetVirtualServers(); err == nil {
+			for _, v := range vs {
+				if mentions(query, v.Name, v.FullPath) {
+					found = append(found, entity{kind: kindVirtualServer, name: v.Name, pool: v.Pool})
+				}
+			}
+		}
+	case llm.ToolListPools:
+		if pools, _, err := i.bigipClient.GetPools(); err == nil {
+			for _, p := range pools {
+				if mentions(query, p.Name, p.FullPath) {
+					found = append(found, entity{kind: kindPool, name: p.Name})
+				}
+			}
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, e := range found {
+		if e.name == "" {
+			continue
+		}
+		focus := []entity{e}
+		for _, f := range i.focus {
+			if f.kind != e.kind {
+				focus = append(focus, f)
+			}
+		}
+		i.focus = focus
+	}
+}
+
+// mentions reports whether the query contains one of the names as a word
+func mentions(query string, names ...string) bool {
+	for _, w := range strings.Fields(query) {
+		w = strings.Trim(w, ".,!?;:'\"`()")
+		for _, name := range names {
+			if name != "" && strings.EqualFold(w, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recall returns the most recently discussed object of a kind, or of any
+// kind when kind is ""
+func (i *Interface) recall(kind string) (entity, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, e := range i.focus {
+		if kind == "" || e.kind == kind {
+			return e, true
+		}
+	}
+	return entity{}, false
+}
+
+// resolveReference answers follow-ups such as "show its members" or "what
+// about that policy?" from the objects discussed so far, without asking the
+// LLM. It only handles reads; other requests get the context note instead.
+func (i *Interface) resolveReference(query string) (*llm.ToolCall, bool) {
+	if !pronoun.MatchString(query) || !readVerbs.MatchString(query) || notReads.MatchString(query) {
+		return nil, false
+	}
+	kind := ""
+	for _, k := range kindWords {
+		if k.pattern.MatchString(query) {
+			kind = k.kind
+			break
+		}
+	}
+
+	e, ok := i.recall(kind)
+	if latest, found := i.recall(""); found && kind == kindPool && latest.kind == kindVirtualServer {
+		// "its members" after discussing a virtual server means its pool
+		e, ok = latest, true
+	}
+	if !ok {
+		return nil, false
+	}
+	switch e.kind {
+	case kindPool:
+		return &llm.ToolCall{Name: llm.ToolGetPool, Args: map[string]string{"name": e.name}}, true
+	case kindVirtualServer:
+		// There is no single virtual server view; only its pool can be shown
+		if kind != kindPool || e.pool == "" {
+			return nil, false
+		}
+		return &llm.ToolCall{Name: llm.ToolGetPool, Args: map[string]string{"name": e.pool}}, true
+	case kindWAFPolicy:
+		return &llm.ToolCall{Name: llm.ToolGetWAFPolicy, Args: map[string]string{"name": e.name}}, true
+	case kindIRule:
+		return &llm.ToolCall{Name: llm.ToolExplainIRule, Args: map[string]string{"name": e.name}}, true
+	}
+	return nil, false
+}
+
+// fillReference supplies the name a tool call is missing from the most
+// recently discussed object of that kind, for "show that pool"
+func (i *Interface) fillReference(call *llm.ToolCall) {
+	kind := map[string]string{
+		llm.ToolGetPool:      kindPool,
+		llm.ToolExplainIRule: kindIRule,
+	}[call.Name]
+	if kind == "" || call.Arg("name") != "" {
+		return
+	}
+	if e, ok := i.recall(kind); ok {
+		if call.Args == nil {
+			call.Args = map[string]string{}
+		}
+		call.Args["name"] = e.name
+	}
+}
+
+// entityNote tells the LLM which objects "it" and "that pool" refer to
+func (i *Interface) entityNote() (llm.Message, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.focus) == 0 {
+		return llm.Message{}, false
+	}
+	described := make([]string, len(i.focus))
+	for n, e := range i.focus {
+		described[n] = e.kind + " " + e.name
+		if e.pool != "" {
+			described[n] += fmt.Sprintf(" (pool %s)", e.pool)
+		}
+	}
+	return llm.Message{
+		Role:    llm.RoleSystem,
+		Content: "Objects discussed most recently, first is latest; words like \"it\", \"its\" and \"that pool\" refer to these: " + strings.Join(described, ", "),
+	}, true
+}
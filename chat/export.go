@@ -0,0 +1,240 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/journal"
+)
+
+// exportCSV matches "export this as CSV", "save that to audit.csv" and
+// "export virtual servers as csv", which runs the listing first
+var exportCSV = regexp.MustCompile(`(?i)^\s*(?:please\s+)?(?:export|save|download|write)\s+(.+?)\s+(?:as|to|in|into)\s+(?:an?\s+)?csv(?:\s+file)?(?:\s+(?:to|as|in|called|named)?\s*["'` + "`" + `]?([^\s"'` + "`" + `]+\.csv)["'` + "`" + `]?)?[\s.!]*$|^\s*(?:please\s+)?(?:export|save|download|write)\s+(.+?)\s+(?:as|to|in|into)\s+["'` + "`" + `]?([^\s"'` + "`" + `]+\.csv)["'` + "`" + `]?[\s.!]*$`)
+
+// exportThis are the words that mean the last answer
+var exportThis = regexp.MustCompile(`(?i)^(?:this|that|it|these|those|them|the\s+(?:last\s+)?(?:results?|answer|list(?:ing)?|table|output))$`)
+
+// table is a listing as rows of columns, for CSV
+type table struct {
+	// kind names the objects in file names: "virtual-servers"
+	kind   string
+	header []string
+	rows   [][]string
+}
+
+// tables turns the objects read by operations into tables, one per kind of
+// object in the order first read. Operations on other devices add a Device
+// column. Operations whose results aren't a listing are left out.
+func tables(ops []operation) []*table {
+	devices := false
+	for _, op := range ops {
+		devices = devices || op.Device != ""
+	}
+	var out []*table
+	byKind := make(map[string]*table)
+	for _, op := range ops {
+		kind, header, rows := tableRows(op.Data)
+		if kind == "" {
+			continue
+		}
+		if devices {
+			header = append([]string{"Device"}, header...)
+			for n := range rows {
+				rows[n] = append([]string{op.Device}, rows[n]...)
+			}
+		}
+		t, ok := byKind[kind]
+		if !ok {
+			t = &table{kind: kind, header: header}
+			byKind[kind] = t
+			out = append(out, t)
+		}
+		t.rows = append(t.rows, rows...)
+	}
+	return out
+}
+
+// tableRows lays out the objects an operation read; kind is "" when they
+// aren't a listing
+func tableRows(data interface{}) (kind string, header []string, rows [][]string) {
+	switch d := data.(type) {
+	case []bigip.VirtualServer:
+		header = []string{"Name", "Partition", "Full Path", "Destination", "Pool", "Enabled", "Description"}
+		for _, v := range d {
+			rows = append(rows, []string{v.Name, partitionOf(v.Partition, v.FullPath), v.FullPath, v.Destination, v.Pool,
+				strconv.FormatBool(v.Enabled && !v.Disabled), v.Description})
+		}
+		return "virtual-servers", header, rows
+	case map[string]interface{}:
+		return tableRows([]map[string]interface{}{d})
+	case []map[string]interface{}:
+		// Pools, as poolData writes them
+		header = []string{"Name", "Partition", "Full Path", "Load Balancing Mode", "Monitor", "Member Count", "Members"}
+		for _, p := range d {
+			members, _ := p["members"].([]string)
+			rows = append(rows, []string{field(p, "name"), partitionOf(field(p, "partition"), field(p, "fullPath")), field(p, "fullPath"),
+				field(p, "loadBalancingMode"), field(p, "monitor"), strconv.Itoa(len(members)), strings.Join(members, " ")})
+		}
+		return "pools", header, rows
+	case []bigip.Node:
+		header = []string{"Name", "Partition", "Full Path", "Address", "State", "Session", "Description"}
+		for _, n := range d {
+			rows = append(rows, []string{n.Name, partitionOf(n.Partition, n.FullPath), n.FullPath, n.Address, n.State, n.Session, n.Description})
+		}
+		return "nodes", header, rows
+	case *bigip.WAFPolicy:
+		return tableRows([]*bigip.WAFPolicy{d})
+	case []*bigip.WAFPolicy:
+		header = []string{"Name", "Full Path", "Enforcement Mode", "Active", "Type", "Virtual Servers", "Description"}
+		for _, p := range d {
+			rows = append(rows, []string{p.Name, p.FullPath, p.EnforcementMode, strconv.FormatBool(p.Active), p.Type,
+				strings.Join(p.VirtualServers, " "), p.Description})
+		}
+		return "waf-policies", header, rows
+	case []bigip.Violation:
+		header = []string{"Time", "Policy", "Client IP", "Method", "URL", "Status", "Attack Types", "Violations", "Rating", "Support ID"}
+		for _, v := range d {
+			rows = append(rows, []string{v.Time.UTC().Format(time.RFC3339), v.Policy, v.ClientIP, v.Method, v.URL, v.Status,
+				strings.Join(v.AttackTypes, "; "), strings.Join(v.Violations, "; "), strconv.Itoa(v.Rating), v.SupportID})
+		}
+		return "waf-violations", header, rows
+	case []bigip.TopologyRecord:
+		header = []string{"Order", "Client", "Destination", "Weight"}
+		for n, r := range d {
+			rows = append(rows, []string{strconv.Itoa(n + 1), r.LDNS, r.Server, strconv.Itoa(r.Weight)})
+		}
+		return "gtm-topology", header, rows
+	case []bigip.FirewallList:
+		header = []string{"Type", "Full Path", "Description", "Entry"}
+		for _, l := range d {
+			for _, e := range l.Entries {
+				rows = append(rows, []string{l.Kind, l.FullPath, l.Description, e})
+			}
+		}
+		return "firewall-lists", header, rows
+	case []*bigip.OWASPCompliance:
+		header = []string{"Policy", "Score", "Category", "Name", "Status", "Compliant Controls", "Controls"}
+		for _, c := range d {
+			for _, cat := range c.Categories {
+				rows = append(rows, []string{c.Policy, strconv.Itoa(c.Score), cat.ID, cat.Name, cat.Status, strconv.Itoa(cat.Compliant), strconv.Itoa(cat.Controls)})
+			}
+		}
+		return "owasp-compliance", header, rows
+	case []journal.Entry:
+		header = []string{"Time", "User", "Device", "Operation", "Object", "Result", "Error", "Request", "Before", "After"}
+		for _, e := range d {
+			rows = append(rows, []string{e.Time.UTC().Format(time.RFC3339), e.User, e.Device, e.Operation, e.Object, e.Result, e.Error,
+				e.Request, e.Before, e.After})
+		}
+		return "changes", header, rows
+	}
+	return "", nil, nil
+}
+
+// field reads a string from an object's JSON form
+func field(obj map[string]interface{}, key string) string {
+	s, _ := obj[key].(string)
+	return strings.TrimSpace(s)
+}
+
+// writeCSV writes a table as CSV with a header row
+func writeCSV(t *table) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(t.header)
+	w.WriteAll(t.rows)
+	return buf.String()
+}
+
+// formatCSV writes the listings an answer read as CSV, a blank line between
+// kinds; it reports false when the answer has no listing
+func formatCSV(ops []operation) (string, bool) {
+	ts := tables(ops)
+	if len(ts) == 0 {
+		return "", false
+	}
+	parts := make([]string, len(ts))
+	for n, t := range ts {
+		parts[n] = writeCSV(t)
+	}
+	return strings.TrimRight(strings.Join(parts, "\n"), "\n"), true
+}
+
+// exportCommand handles "export this as CSV", which writes the listings in
+// the last answer to CSV files, and "export pools as CSV", which answers
+// the listing first
+func (i *Interface) exportCommand(query string) (string, bool, error) {
+	m := exportCSV.FindStringSubmatch(query)
+	if m == nil {
+		return "", false, nil
+	}
+	what, file := m[1], m[2]
+	if m[3] != "" {
+		what, file = m[3], m[4]
+	}
+
+	i.mu.Lock()
+	dryRun := i.dryRun
+	ops := i.lastOperations
+	i.mu.Unlock()
+	if !exportThis.MatchString(strings.TrimSpace(what)) {
+		// Run the listing, then export what it read
+		response, err := i.process(what)
+		if err != nil || dryRun {
+			return response, true, err
+		}
+		i.mu.Lock()
+		ops = append([]operation(nil), i.operations...)
+		i.mu.Unlock()
+	} else if dryRun {
+		return "No request to the device: the last answer's listing is written to a file.", true, nil
+	}
+
+	ts := tables(ops)
+	if len(ts) == 0 {
+		return "There's no listing to export. Ask for one first, e.g. \"show virtual servers\", then \"export this as CSV\"; or ask \"export pools as CSV\".", true, nil
+	}
+	stamp := time.Now().Format("20060102-150405")
+	var written []string
+	for _, t := range ts {
+		name := file
+		switch {
+		case name == "":
+			name = fmt.Sprintf("%s-%s.csv", t.kind, stamp)
+		case len(ts) > 1:
+			name = strings.TrimSuffix(name, ".csv") + "-" + t.kind + ".csv"
+		}
+		if err := writeNewFile(name, writeCSV(t), "export this as CSV to audit-2.csv"); err != nil {
+			return "", true, err
+		}
+		written = append(written, fmt.Sprintf("%d %s to %s", len(t.rows), plural("row", len(t.rows)), name))
+	}
+	response := "Wrote " + strings.Join(written, ", ") + "."
+	i.remember(query, response)
+	return response, true, nil
+}
+
+// writeNewFile writes a file that mustn't exist yet, so an export never
+// replaces one; the error when it does suggests example instead
+func writeNewFile(name, content, example string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists; give another file name, e.g. \"%s\"", name, example)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't write %s: %w", name, err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return fmt.Errorf("couldn't write %s: %w", name, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"errors"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+// Kinds of failure an answer can end in (see LastFailure). Many are
+// answered with an explanation rather than an error, so scripts wrapping
+// chatf5 need the kind to tell them apart.
+const (
+	// FailureAuth is a device that rejected the credentials
+	FailureAuth = "auth"
+	// FailureUnreachable is a device that couldn't be connected to, or
+	// didn't answer in time
+	FailureUnreachable = "unreachable"
+	// FailureNotUnderstood is a query that didn't map onto any operation
+	FailureNotUnderstood = "not_understood"
+	// FailureNotFound is an object asked for by name that doesn't exist
+	FailureNotFound = "not_found"
+	// FailureLLM is an LLM API that is down, rate limiting, or over a
+	// spend limit
+	FailureLLM = "llm_unavailable"
+	// FailureOther is any other failed answer
+	FailureOther = "error"
+)
+
+// failureOf returns the kind of failure err is
+func failureOf(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, new(*bigip.AuthError)):
+		return FailureAuth
+	case errors.As(err, new(*bigip.ConnectError)), errors.As(err, new(*bigip.CircuitOpenError)),
+		errors.As(err, new(*bigip.TimeoutError)):
+		return FailureUnreachable
+	case errors.As(err, new(*bigip.ObjectNotFoundError)), errors.As(err, new(*bigip.NotFoundError)):
+		return FailureNotFound
+	case errors.As(err, new(*llm.SpendLimitError)), errors.As(err, new(*llm.UnavailableError)):
+		return FailureLLM
+	}
+	return FailureOther
+}
+
+// clearFailure forgets the last answer's failure before the next
+func (i *Interface) clearFailure() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.failure, i.failureMessage = "", ""
+}
+
+// explainFailure keeps what was said about the answer's failure, if it
+// failed: the error, or the answer explaining it
+func (i *Interface) explainFailure(response string, err error) {
+	if err != nil {
+		// Errors reworded along the way lose their type, so this is only
+		// the fallback
+		i.setFailure(failureOf(err))
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	switch {
+	case i.failure == "":
+	case err != nil:
+		i.failureMessage = err.Error()
+	default:
+		i.failureMessage = response
+	}
+}
+
+// setFailure records how the answer being given failed; the first kind
+// recorded stands
+func (i *Interface) setFailure(kind string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.failure == "" {
+		i.failure = kind
+	}
+}
+
+// LastFailure is the kind of failure the last query answered ended in,
+// such as FailureAuth, and what was said about it, in text whatever the
+// output format; kind is "" when it succeeded
+func (i *Interface) LastFailure() (kind, message string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.failure, i.failureMessage
+}
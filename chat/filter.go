@@ -0,0 +1,263 @@
+package chat
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// listFilter narrows a listing to the objects a query's qualifiers select.
+// Listings are cached, so filtering after retrieval costs no extra requests.
+type listFilter struct {
+	status  string
+	members string
+	network *net.IPNet
+	// countOp compares a pool's number of members with count
+	countOp string
+	count   int
+	// name is a name or full path, with * matching anything
+	name      string
+	partition string
+	// described is the qualifiers in the user's terms, for the summary line
+	described []string
+}
+
+// fillListArgs adds the qualifiers, ordering and counting in the query
+// that the chosen listing tool takes but wasn't given, so "only disabled
+// virtual servers" filters, "largest pools first" sorts, "how many nodes"
+// counts and "pools in detail" shows every field however the tool was
+// picked
+func fillListArgs(query string, call *llm.ToolCall) {
+	args := llm.ParseFilters(call.Name, query)
+	for key, value := range llm.ParseSort(call.Name, query) {
+		args[key] = value
+	}
+	for key, value := range llm.ParseAggregate(call.Name, query) {
+		args[key] = value
+	}
+	for key, value := range llm.ParseDetail(call.Name, query) {
+		args[key] = value
+	}
+	for key, value := range args {
+		if call.Arg(key) != "" {
+			continue
+		}
+		if call.Args == nil {
+			call.Args = map[string]string{}
+		}
+		call.Args[key] = value
+	}
+}
+
+// parseListFilter reads a listing tool's qualifiers; it is nil when there
+// are none
+func parseListFilter(call *llm.ToolCall) (*listFilter, error) {
+	f := &listFilter{
+		status:  strings.ToLower(call.Arg(llm.FilterStatus)),
+		members: strings.ToLower(call.Arg(llm.FilterMembers)),
+	}
+	switch f.status {
+	case "":
+	case "enabled", "disabled", "up", "down":
+		f.described = append(f.described, f.status)
+	default:
+		return nil, fmt.Errorf("unknown status '%s' (use %s)", f.status, strings.Join(llm.FilterStatuses, ", "))
+	}
+	switch f.members {
+	case "":
+	case "with", "without":
+		f.described = append(f.described, f.members+" members")
+	default:
+		return nil, fmt.Errorf("unknown members filter '%s' (use with or without)", f.members)
+	}
+	if count := call.Arg(llm.FilterMemberCount); count != "" {
+		m := memberCount.FindStringSubmatch(strings.TrimSpace(count))
+		if m == nil {
+			return nil, fmt.Errorf("unknown member count '%s' (use e.g. <2, =0 or >=3)", count)
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("unknown member count '%s' (use e.g. <2, =0 or >=3)", count)
+		}
+		f.countOp, f.count = m[1], n
+		if f.countOp == "" {
+			f.countOp = "="
+		}
+		f.described = append(f.described, fmt.Sprintf("%s %d members", countWords[f.countOp], f.count))
+	}
+	if name := strings.Trim(call.Arg(llm.FilterName), "\"'`"); name != "" {
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("'%s' isn't a name pattern; use * to match any characters", name)
+		}
+		f.name = name
+		f.described = append(f.described, "named "+name)
+	}
+	if partition := strings.Trim(call.Arg(llm.FilterPartition), "/\"'`"); partition != "" {
+		f.partition = partition
+		f.described = append(f.described, "in partition "+partition)
+	}
+	if address := call.Arg(llm.FilterAddress); address != "" {
+		network, err := parseNetwork(address)
+		if err != nil {
+			return nil, err
+		}
+		f.network = network
+		f.described = append(f.described, "in "+network.String())
+	}
+	if len(f.described) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// memberCount reads a member count filter: an optional operator and a number
+var memberCount = regexp.MustCompile(`^(<=|>=|<|>|=)?\s*(\d+)$`)
+
+// countWords describes a member count operator in the summary line
+var countWords = map[string]string{
+	"<": "fewer than", "<=": "at most", "=": "exactly", ">=": "at least", ">": "more than",
+}
+
+// parseNetwork reads a CIDR network, or a single address as a host network
+func parseNetwork(address string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(address); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("'%s' isn't an IP address or CIDR network, e.g. 10.2.0.0/16", address)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// contains reports whether an address in BIG-IP form, possibly with a
+// partition, route domain or port, is in the filter's network
+func (f *listFilter) contains(address string) bool {
+	ip := utils.ParseAddress(address)
+	return ip != nil && f.network.Contains(ip)
+}
+
+// named reports whether an object's name or full path matches the name
+// filter, ignoring case
+func (f *listFilter) named(name, fullPath string) bool {
+	if f.name == "" {
+		return true
+	}
+	pattern := strings.ToLower(f.name)
+	for _, candidate := range []string{name, fullPath} {
+		if ok, _ := path.Match(pattern, strings.ToLower(candidate)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inPartition reports whether an object is in the partition filter's
+// partition; partition names are case-sensitive on the device, but people
+// type "common"
+func (f *listFilter) inPartition(partition, fullPath string) bool {
+	return f.partition == "" || strings.EqualFold(partitionOf(partition, fullPath), f.partition)
+}
+
+func (f *listFilter) String() string { return strings.Join(f.described, ", ") }
+
+// filterVirtualServers keeps the virtual servers the filter selects.
+// Listings carry the administrative state only, so up and down are refused.
+func filterVirtualServers(vs []bigip.VirtualServer, f *listFilter) ([]bigip.VirtualServer, error) {
+	if f.status == "up" || f.status == "down" {
+		return nil, fmt.Errorf("virtual server availability isn't part of the listing; I can show the enabled or disabled ones")
+	}
+	var out []bigip.VirtualServer
+	for _, v := range vs {
+		if f.status != "" && v.Enabled != (f.status == "enabled") {
+			continue
+		}
+		if f.network != nil && !f.contains(v.Destination) {
+			continue
+		}
+		if !f.named(v.Name, v.FullPath) || !f.inPartition(v.Partition, v.FullPath) {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// filterPools keeps the pools the filter selects
+func filterPools(pools []bigip.Pool, members map[string][]bigip.PoolMember, f *listFilter) []bigip.Pool {
+	var out []bigip.Pool
+	for _, p := range pools {
+		if f.members != "" && (len(members[p.FullPath]) > 0) != (f.members == "with") {
+			continue
+		}
+		if f.countOp != "" && !f.countMatches(len(members[p.FullPath])) {
+			continue
+		}
+		if !f.named(p.Name, p.FullPath) || !f.inPartition(p.Partition, p.FullPath) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// countMatches reports whether a pool with n members passes the member
+// count filter
+func (f *listFilter) countMatches(n int) bool {
+	switch f.countOp {
+	case "<":
+		return n < f.count
+	case "<=":
+		return n <= f.count
+	case ">=":
+		return n >= f.count
+	case ">":
+		return n > f.count
+	}
+	return n == f.count
+}
+
+// filterNodes keeps the nodes the filter selects: disabled nodes are those
+// an administrator disabled, down ones those their monitor marked down
+func filterNodes(nodes []bigip.Node, f *listFilter) []bigip.Node {
+	var out []bigip.Node
+	for _, n := range nodes {
+		switch f.status {
+		case "enabled", "disabled":
+			if (n.Session == "user-disabled") != (f.status == "disabled") {
+				continue
+			}
+		case "up", "down":
+			if n.State != f.status {
+				continue
+			}
+		}
+		if f.network != nil && !f.contains(n.Address) {
+			continue
+		}
+		if !f.named(n.Name, n.FullPath) || !f.inPartition(n.Partition, n.FullPath) {
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// filtered reports how many of a listing a filter kept, or that none match
+func filtered(kinds string, f *listFilter, kept, total int) string {
+	if kept == 0 {
+		return fmt.Sprintf("None of the %d %s match: %s.", total, kinds, f)
+	}
+	return fmt.Sprintf("\nShowing %d of %d %s (%s).\n", kept, total, kinds, f)
+}
@@ -0,0 +1,381 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/journal"
+	"f5chat/llm"
+)
+
+// pendingListChange is entries to add to an AFM address or port list,
+// waiting for the user to confirm them
+type pendingListChange struct {
+	list    bigip.FirewallList
+	entries []string
+	request string
+}
+
+// firewallList shows the entries of the address or port list the query
+// names, or every list of the kind with how many entries each holds
+func (i *Interface) firewallList(call *llm.ToolCall) (string, error) {
+	kind := "address"
+	if call.Name == llm.ToolGetPortList {
+		kind = "port"
+	}
+	lists, err := i.bigipClient.GetFirewallLists(kind)
+	if unprovisioned(err) {
+		return fmt.Sprintf("AFM isn't provisioned on this device, so there are no firewall %s lists.", kind), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	if name == "" {
+		i.setData(lists)
+		return formatFirewallLists(kind, lists), nil
+	}
+	objects := make([]named, len(lists))
+	for n, l := range lists {
+		objects[n] = named{name: l.Name, fullPath: l.FullPath}
+	}
+	matches := matchNames(name, objects)
+	switch len(matches) {
+	case 0:
+		return "", &bigip.ObjectNotFoundError{Kind: kind + " list", Name: name}
+	case 1:
+	default:
+		return i.askChoice(call.Name, kind+" lists", name, matches), nil
+	}
+	for _, l := range lists {
+		if l.FullPath == matches[0] {
+			i.setData([]bigip.FirewallList{l})
+			return formatFirewallList(l), nil
+		}
+	}
+	return "", &bigip.ObjectNotFoundError{Kind: kind + " list", Name: name}
+}
+
+// formatFirewallLists lays out the lists of a kind and their sizes
+func formatFirewallLists(kind string, lists []bigip.FirewallList) string {
+	if len(lists) == 0 {
+		return fmt.Sprintf("There are no firewall %s lists on this device.", kind)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n=== %s Lists (%d) ===\n", firewallKindTitle(kind), len(lists))
+	sb.WriteString("----------------------------------------\n")
+	width := 0
+	for _, l := range lists {
+		width = max(width, len(l.FullPath))
+	}
+	for _, l := range lists {
+		line := fmt.Sprintf("  %-*s %s", width, l.FullPath, entryCount(len(l.Entries)))
+		if l.Description != "" {
+			line += "  (" + l.Description + ")"
+		}
+		sb.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&sb, "\nAsk e.g. \"what's in %s-list %s?\" to see a list's entries.\n", kind, lists[0].Name)
+	return sb.String()
+}
+
+// formatFirewallList lays out a list's entries
+func formatFirewallList(l bigip.FirewallList) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n=== %s List: %s ===\n", firewallKindTitle(l.Kind), l.FullPath)
+	sb.WriteString("----------------------------------------\n")
+	if l.Description != "" {
+		fmt.Fprintf(&sb, "%-13s %s\n", "Description", l.Description)
+	}
+	fmt.Fprintf(&sb, "%-13s %d\n", "Entries", len(l.Entries))
+	if len(l.Entries) > 0 {
+		sb.WriteString("----------------------------------------\n")
+		for _, e := range l.Entries {
+			sb.WriteString("  " + e + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// entryCount is "1 entry" or "n entries"
+func entryCount(n int) string {
+	if n == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", n)
+}
+
+// firewallKindTitle is "Address" or "Port"
+func firewallKindTitle(kind string) string {
+	if kind == "port" {
+		return "Port"
+	}
+	return "Address"
+}
+
+var (
+	// entrySeparator splits the entries a query lists: "a, b and c"
+	entrySeparator = regexp.MustCompile(`\s*(?:,|;|\band\b)\s*|\s+`)
+	// countryCode is a two-letter ISO 3166 country code
+	countryCode = regexp.MustCompile(`^[A-Za-z]{2}$`)
+	// hostname is a DNS name with at least one dot
+	hostname = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+)
+
+// firewallEntries turns what the user asked to add into entries as
+// bigip.FirewallList holds them, or says which one isn't valid for the kind
+func firewallEntries(kind, text string) ([]string, error) {
+	words := entrySeparator.Split(strings.TrimSpace(text), -1)
+	var entries []string
+	for n := 0; n < len(words); n++ {
+		word := strings.Trim(words[n], "\"'`")
+		if word == "" {
+			continue
+		}
+		// "geo CN", "fqdn www.example.com", "address-list /Common/x"
+		prefix := strings.ToLower(word)
+		if prefix == "country" {
+			prefix = "geo"
+		}
+		if (prefix == "geo" || prefix == "fqdn" || prefix == kind+"-list") && n+1 < len(words) {
+			n++
+			word = prefix + " " + strings.Trim(words[n], "\"'`")
+		}
+		entry, ok := firewallEntry(kind, word)
+		if !ok {
+			return nil, fmt.Errorf("'%s' isn't something a %s list can hold", word, kind)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("there are no entries to add")
+	}
+	return entries, nil
+}
+
+// firewallEntry normalises one entry of a kind of list, reporting false
+// when the list can't hold it
+func firewallEntry(kind, word string) (string, bool) {
+	if kind == "port" {
+		if rest, ok := strings.CutPrefix(word, "port-list "); ok {
+			return "port-list " + objectFullPath(rest), true
+		}
+		from, to, isRange := strings.Cut(word, "-")
+		if !validPort(from) || (isRange && (!validPort(to) || atoi(to) < atoi(from))) {
+			return "", false
+		}
+		return word, true
+	}
+
+	if rest, ok := strings.CutPrefix(word, "address-list "); ok {
+		return "address-list " + objectFullPath(rest), true
+	}
+	if rest, ok := strings.CutPrefix(word, "fqdn "); ok {
+		return "fqdn " + strings.ToLower(rest), hostname.MatchString(rest)
+	}
+	if rest, ok := strings.CutPrefix(word, "geo "); ok {
+		if countryCode.MatchString(rest) {
+			return "geo " + strings.ToUpper(rest), true
+		}
+		if c, ok := lookupCountry(rest); ok {
+			return "geo " + c.code, true
+		}
+		return "", false
+	}
+	if ip := net.ParseIP(word); ip != nil {
+		return ip.String(), true
+	}
+	if _, network, err := net.ParseCIDR(word); err == nil {
+		return network.String(), true
+	}
+	if from, to, ok := strings.Cut(word, "-"); ok && net.ParseIP(from) != nil && net.ParseIP(to) != nil {
+		return word, true
+	}
+	if c, ok := lookupCountry(word); ok && !countryCode.MatchString(word) {
+		return "geo " + c.code, true
+	}
+	if hostname.MatchString(word) {
+		return "fqdn " + strings.ToLower(word), true
+	}
+	return "", false
+}
+
+// objectFullPath puts a bare name in /Common
+func objectFullPath(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	return "/Common/" + name
+}
+
+// validPort reports whether s is a port number
+func validPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// addToFirewallList shows the entries a request would add to a list and
+// keeps them until the user confirms; nothing is changed yet
+func (i *Interface) addToFirewallList(call *llm.ToolCall) (string, error) {
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	if name == "" {
+		return "Which list should I add them to? For example: 'add 203.0.113.9 to address-list blocked_ips'.", nil
+	}
+	kinds := []string{"address", "port"}
+	if kind := strings.ToLower(call.Arg("kind")); kind == "address" || kind == "port" {
+		kinds = []string{kind}
+	}
+
+	var candidates []bigip.FirewallList
+	for _, kind := range kinds {
+		lists, err := i.bigipClient.GetFirewallLists(kind)
+		if unprovisioned(err) {
+			return "AFM isn't provisioned on this device, so there are no firewall lists to add to.", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidates = append(candidates, lists...)
+	}
+	objects := make([]named, len(candidates))
+	for n, l := range candidates {
+		objects[n] = named{name: l.Name, fullPath: l.FullPath}
+	}
+	var matched []bigip.FirewallList
+	for _, path := range matchNames(name, objects) {
+		for _, l := range candidates {
+			if l.FullPath == path {
+				matched = append(matched, l)
+			}
+		}
+	}
+	switch {
+	case len(matched) == 0:
+		return "", &bigip.ObjectNotFoundError{Kind: strings.Join(kinds, " or ") + " list", Name: name}
+	case len(matched) > 1:
+		var paths []string
+		for _, l := range matched {
+			paths = append(paths, l.FullPath+" ("+l.Kind+" list)")
+		}
+		return fmt.Sprintf("'%s' matches several firewall lists: %s. Say which, e.g. 'add ... to %s-list %s'.",
+			name, strings.Join(paths, ", "), matched[0].Kind, matched[0].FullPath), nil
+	}
+	list := matched[0]
+
+	entries, err := firewallEntries(list.Kind, call.Arg("entries"))
+	if err != nil {
+		return fmt.Sprintf("Nothing was added to %s list %s: %v.", list.Kind, list.FullPath, err), nil
+	}
+	have := map[string]bool{}
+	for _, e := range list.Entries {
+		have[e] = true
+	}
+	var added, present []string
+	for _, e := range entries {
+		switch {
+		case have[e]:
+			present = append(present, e)
+		default:
+			have[e] = true
+			added = append(added, e)
+		}
+	}
+	if len(added) == 0 {
+		return fmt.Sprintf("%s list %s already holds %s; there's nothing to add.", firewallKindTitle(list.Kind), list.FullPath, strings.Join(present, ", ")), nil
+	}
+
+	i.mu.Lock()
+	i.pendingList = &pendingListChange{list: list, entries: added, request: call.Arg("entries")}
+	i.pending, i.pendingAS3 = nil, nil
+	i.mu.Unlock()
+	slog.Info("Prepared firewall list change", "list", list.FullPath, "entries", len(added))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n=== Add to %s List: %s ===\n", firewallKindTitle(list.Kind), list.FullPath)
+	sb.WriteString("----------------------------------------\n")
+	for _, e := range added {
+		sb.WriteString("  + " + e + "\n")
+	}
+	if len(present) > 0 {
+		fmt.Fprintf(&sb, "Already in the list: %s\n", strings.Join(present, ", "))
+	}
+	fmt.Fprintf(&sb, "\nThe list holds %s now; every firewall rule using it will match these too.\n", entryCount(len(list.Entries)))
+	them := "them"
+	if len(added) == 1 {
+		them = "it"
+	}
+	fmt.Fprintf(&sb, "Reply 'yes' to add %s. Anything else discards the change.", them)
+	return sb.String(), nil
+}
+
+// takePendingList returns and clears the list change awaiting confirmation
+func (i *Interface) takePendingList() *pendingListChange {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	p := i.pendingList
+	i.pendingList = nil
+	return p
+}
+
+// addReply matches the confirmation replies: "yes", "add them"
+var addReply = regexp.MustCompile(`(?i)^\s*(?:yes|y|confirm|apply|add (?:it|them))\s*[.!]?\s*$`)
+
+// confirmListChange handles the reply to entries shown for a list. It
+// reports false when the reply isn't a confirmation, in which case the
+// change is dropped and the reply is processed as a new query.
+func (i *Interface) confirmListChange(p *pendingListChange, reply string) (string, bool) {
+	if !addReply.MatchString(reply) {
+		slog.Debug("Discarding firewall list change", "list", p.list.FullPath)
+		return "", false
+	}
+
+	request := fmt.Sprintf("Add %s to the existing AFM %s list %s, which holds %d entries, without removing any",
+		strings.Join(p.entries, ", "), p.list.Kind, p.list.FullPath, len(p.list.Entries))
+	call := &llm.ToolCall{Name: llm.ToolUpdateFirewallList, Args: map[string]string{
+		"kind": p.list.Kind, "name": p.list.FullPath, "entries": strings.Join(p.entries, ","),
+	}}
+	if message, ok := i.guard(request, call); !ok {
+		return message, true
+	}
+
+	after := append(append([]string(nil), p.list.Entries...), p.entries...)
+	change := journal.Entry{
+		Operation: llm.ToolUpdateFirewallList,
+		Object:    p.list.Kind + " list " + p.list.FullPath,
+		Request:   p.request,
+		Before:    strings.Join(p.list.Entries, "\n"),
+		After:     strings.Join(after, "\n"),
+		Result:    journal.Applied,
+	}
+	err := i.bigipClient.AppendToFirewallList(p.list.Kind, p.list.FullPath, p.entries)
+	if err != nil {
+		change.Result, change.Error = journal.Failed, err.Error()
+	}
+	i.journalChange(change)
+	if err != nil {
+		slog.Error("Failed to update firewall list", "list", p.list.FullPath, "err", err)
+		// Keep it so the user can retry once the problem is fixed
+		i.mu.Lock()
+		i.pendingList = p
+		i.mu.Unlock()
+		return fmt.Sprintf("Nothing was added to %s list %s: %v\nReply 'yes' to try again.", p.list.Kind, p.list.FullPath, err), true
+	}
+	slog.Info("Updated firewall list", "list", p.list.FullPath, "entries", len(p.entries))
+	i.setLastCall(call)
+
+	response := fmt.Sprintf("Added %s to %s list %s (%s); it now holds %d.",
+		entryCount(len(p.entries)), p.list.Kind, p.list.FullPath, strings.Join(p.entries, ", "), len(after))
+	i.remember(reply, response)
+	return response, true
+}
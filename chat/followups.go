@@ -0,0 +1,157 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+// maxFollowUps is how many follow-up questions are suggested per answer
+const maxFollowUps = 3
+
+// SetFollowUps turns suggesting follow-up questions after answers on or off
+func (i *Interface) SetFollowUps(on bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.followUps = on
+}
+
+// suggestFollowUps picks questions that naturally come next after an
+// operation, about the objects in its answer. Each one is a query the chat
+// answers as written. Listings are read again from the cache they just
+// filled.
+func (i *Interface) suggestFollowUps(query string, call *llm.ToolCall) []string {
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	var next []string
+	switch call.Name {
+	case llm.ToolListVirtualServers:
+		if vs, err := i.bigipClient.GetVirtualServers(); err == nil {
+			if v, ok := firstVirtualServer(vs, func(v bigip.VirtualServer) bool { return !v.Enabled }); ok {
+				next = append(next, fmt.Sprintf("Why is %s down?", v.Name))
+			}
+			if v, ok := firstVirtualServer(vs, func(v bigip.VirtualServer) bool { return v.Enabled && v.Pool != "" }); ok {
+				next = append(next, "Show pool "+v.Pool)
+			}
+		}
+		next = append(next, "Show WAF policies with their virtual servers")
+	case llm.ToolListPools:
+		if pools, members, err := i.bigipClient.GetPools(); err == nil {
+			for _, p := range pools {
+				if len(members[p.FullPath]) > 0 {
+					next = append(next, "Show pool "+p.Name)
+					break
+				}
+			}
+		}
+		next = append(next, "Which nodes are down?", "Count pools by load balancing method")
+	case llm.ToolGetPool:
+		if vs, err := i.bigipClient.GetVirtualServers(); err == nil {
+			if v, ok := firstVirtualServer(vs, func(v bigip.VirtualServer) bool { return poolMatches(v.Pool, name) }); ok {
+				next = append(next, fmt.Sprintf("Troubleshoot %s", v.Name))
+			}
+		}
+		next = append(next, "Which nodes are down?", "What tmsh command does this?")
+	case llm.ToolListNodes:
+		if nodes, err := i.bigipClient.GetNodes(); err == nil {
+			for _, n := range nodes {
+				if n.State == "down" || n.Session == "user-disabled" {
+					next = append(next, "Why is my app down?")
+					break
+				}
+			}
+		}
+		next = append(next, "Show pools", "What tmsh command does this?")
+	case llm.ToolListWAFPolicies:
+		if policies, err := i.bigipClient.GetWAFPolicies(); err == nil && len(policies) > 0 {
+			next = append(next, "Show policy details "+policies[0].Name)
+		}
+		next = append(next, "Show virtual servers")
+	case llm.ToolGetWAFPolicy:
+		next = append(next, "Show WAF policies with their virtual servers", "What tmsh command does this?")
+	case llm.ToolExplainIRule:
+		next = append(next, "Show virtual servers", "What tmsh command does this?")
+	case llm.ToolTroubleshoot:
+		next = append(next, "Which nodes are down?", "What tmsh command does this?")
+	case llm.ToolSecurityPosture:
+		next = append(next, "Show WAF policies with their virtual servers", "What tmsh command does this?")
+	case llm.ToolWAFViolations:
+		next = append(next, "Show WAF policies with their virtual servers", "What is our security posture?")
+	case llm.ToolGTMTopology:
+		if topology, err := i.bigipClient.GetTopology(); err == nil && call.Arg("location") == "" {
+			// Suggest the first place a record names
+			for _, r := range topology.Records {
+				if kind, value := splitLocation(r.LDNS); kind == "continent" || kind == "country" || kind == "region" {
+					next = append(next, "Where do clients in "+value[strings.LastIndex(value, "/")+1:]+" resolve to?")
+					break
+				}
+			}
+		}
+		next = append(next, "What tmsh command does this?")
+	case llm.ToolOWASPCompliance:
+		next = append(next, "What is our security posture?", "Summarize the WAF violations")
+	case llm.ToolGetAddressList:
+		next = append(next, "Show the port lists", "What tmsh command does this?")
+	case llm.ToolGetPortList:
+		next = append(next, "Show the address lists", "What tmsh command does this?")
+	case llm.ToolChangeJournal:
+		next = append(next, "What changed since yesterday?")
+	case llm.ToolHealthSummary:
+		next = append(next, "Why is my app down?", "Summarize the WAF violations")
+	case llm.ToolExportTerraform:
+		next = append(next, "Show virtual servers", "What tmsh command does this?")
+	case llm.ToolGenerateQkview:
+		if call.Arg("upload") == "" {
+			next = append(next, "Upload the qkview to iHealth")
+		}
+	case llm.ToolUploadQkview:
+		next = append(next, "Show a health summary")
+	case llm.ToolGenerateAnsible:
+		switch {
+		case call.Arg("description") != "":
+		case name != "":
+			next = append(next, "Export "+name+" as Terraform")
+		default:
+			next = append(next, "Export the virtual servers as Terraform")
+		}
+	case llm.ToolCompare:
+		next = append(next, "What tmsh command does this?")
+	}
+
+	var out []string
+	for _, q := range next {
+		if !strings.EqualFold(strings.TrimRight(q, "?"), strings.TrimRight(strings.TrimSpace(query), "?")) && len(out) < maxFollowUps {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+func firstVirtualServer(vs []bigip.VirtualServer, match func(bigip.VirtualServer) bool) (bigip.VirtualServer, bool) {
+	for _, v := range vs {
+		if match(v) {
+			return v, true
+		}
+	}
+	return bigip.VirtualServer{}, false
+}
+
+// poolMatches reports whether a virtual server's pool (a full path) is the
+// pool named, by name or path
+func poolMatches(pool, name string) bool {
+	return pool != "" && (pool == name || strings.HasSuffix(pool, "/"+name))
+}
+
+// formatFollowUps renders suggestions to append to an answer
+func formatFollowUps(questions []string) string {
+	if len(questions) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nYou could also ask:\n")
+	for _, q := range questions {
+		sb.WriteString("- " + q + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
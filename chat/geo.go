@@ -0,0 +1,110 @@
+package chat
+
+import "strings"
+
+// continents are the continent codes GTM topology uses, with the names
+// people call them by
+var continents = map[string][]string{
+	"AF": {"africa", "african"},
+	"AN": {"antarctica"},
+	"AS": {"asia", "asian", "apac"},
+	"EU": {"europe", "european"},
+	"NA": {"north america", "north american"},
+	"OC": {"oceania", "australasia"},
+	"SA": {"south america", "south american", "latam", "latin america"},
+}
+
+// country is a country GTM topology can name, by its ISO 3166 code
+type country struct {
+	code, continent string
+	names           []string
+}
+
+// countries are the countries topology questions usually ask about. Codes
+// that are also continent codes, such as AS and NA, are left out.
+var countries = []country{
+	{"AE", "AS", []string{"united arab emirates", "uae", "emirati"}},
+	{"AR", "SA", []string{"argentina", "argentinian"}},
+	{"AT", "EU", []string{"austria", "austrian"}},
+	{"AU", "OC", []string{"australia", "australian"}},
+	{"BE", "EU", []string{"belgium", "belgian"}},
+	{"BR", "SA", []string{"brazil", "brazilian"}},
+	{"CA", "NA", []string{"canada", "canadian"}},
+	{"CH", "EU", []string{"switzerland", "swiss"}},
+	{"CL", "SA", []string{"chile", "chilean"}},
+	{"CN", "AS", []string{"china", "chinese"}},
+	{"CZ", "EU", []string{"czechia", "czech republic", "czech"}},
+	{"DE", "EU", []string{"germany", "german"}},
+	{"DK", "EU", []string{"denmark", "danish"}},
+	{"EG", "AF", []string{"egypt", "egyptian"}},
+	{"ES", "EU", []string{"spain", "spanish"}},
+	{"FI", "EU", []string{"finland", "finnish"}},
+	{"FR", "EU", []string{"france", "french"}},
+	{"GB", "EU", []string{"united kingdom", "uk", "great britain", "britain", "british", "england", "english"}},
+	{"GR", "EU", []string{"greece", "greek"}},
+	{"HK", "AS", []string{"hong kong"}},
+	{"IE", "EU", []string{"ireland", "irish"}},
+	{"IL", "AS", []string{"israel", "israeli"}},
+	{"IN", "AS", []string{"india", "indian"}},
+	{"IT", "EU", []string{"italy", "italian"}},
+	{"JP", "AS", []string{"japan", "japanese"}},
+	{"KE", "AF", []string{"kenya", "kenyan"}},
+	{"KR", "AS", []string{"south korea", "korea", "korean"}},
+	{"MX", "NA", []string{"mexico", "mexican"}},
+	{"NG", "AF", []string{"nigeria", "nigerian"}},
+	{"NL", "EU", []string{"netherlands", "the netherlands", "holland", "dutch"}},
+	{"NO", "EU", []string{"norway", "norwegian"}},
+	{"NZ", "OC", []string{"new zealand"}},
+	{"PL", "EU", []string{"poland", "polish"}},
+	{"PT", "EU", []string{"portugal", "portuguese"}},
+	{"SE", "EU", []string{"sweden", "swedish"}},
+	{"SG", "AS", []string{"singapore", "singaporean"}},
+	{"TR", "AS", []string{"turkey", "turkish"}},
+	{"TW", "AS", []string{"taiwan", "taiwanese"}},
+	{"UA", "EU", []string{"ukraine", "ukrainian"}},
+	{"US", "NA", []string{"united states", "usa", "america", "american"}},
+	{"ZA", "AF", []string{"south africa", "south african"}},
+}
+
+// lookupContinent returns the code of the continent place names, or ""
+func lookupContinent(place string) string {
+	place = strings.ToLower(strings.TrimSpace(place))
+	for code, names := range continents {
+		if strings.EqualFold(place, code) {
+			return code
+		}
+		for _, name := range names {
+			if place == name {
+				return code
+			}
+		}
+	}
+	return ""
+}
+
+// lookupCountry returns the country place names, by code or name
+func lookupCountry(place string) (country, bool) {
+	place = strings.ToLower(strings.TrimSpace(place))
+	for _, c := range countries {
+		if strings.EqualFold(place, c.code) {
+			return c, true
+		}
+		for _, name := range c.names {
+			if place == name {
+				return c, true
+			}
+		}
+	}
+	return country{}, false
+}
+
+// continentOf returns the continent a country code is in, or "" when it
+// isn't one of countries
+func continentOf(code string) string {
+	for _, c := range countries {
+		if strings.EqualFold(c.code, code) {
+			return c.continent
+		}
+	}
+	return ""
+}
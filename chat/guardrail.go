@@ -0,0 +1,59 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+
+	"f5chat/llm"
+	"f5chat/metrics"
+)
+
+// SetMaxRisk sets the largest blast radius a change may have to run;
+// anything above it is refused. The default is llm.RiskLowRisk.
+func (i *Interface) SetMaxRisk(risk llm.Risk) {
+	i.maxRisk = risk
+}
+
+// guard checks a tool call before it runs. Read-only tools pass straight
+// through. Anything that could change the device gets a second, independent
+// classification of the user's request, and is refused when that pass
+// disagrees with the tool (a misrouted intent), when the blast radius is
+// above the configured maximum, or when the request can't be classified.
+// It returns the message to show when the call is refused.
+func (i *Interface) guard(query string, call *llm.ToolCall) (string, bool) {
+	declared := llm.ToolRisk(call.Name)
+	if declared == llm.RiskReadOnly {
+		return "", true
+	}
+
+	refuse := func(risk llm.Risk, message string) (string, bool) {
+		slog.Warn("Guardrail refused a change", "tool", call.Name, "args", call.Args, "risk", risk, "reason", message)
+		metrics.GuardrailDecisions.WithLabelValues(risk.String(), "blocked").Inc()
+		return message, false
+	}
+
+	classifier, ok := i.llmClient.(llm.RiskClassifier)
+	if !ok {
+		return refuse(declared, fmt.Sprintf("I can't verify the impact of %s with %s, so I won't run it.", call.Name, i.llmClient.Name()))
+	}
+	risk, reason, err := classifier.ClassifyRisk(query, call)
+	if err != nil {
+		// Fail closed: an unchecked change is worse than a refused one
+		return refuse(declared, fmt.Sprintf("I couldn't assess the impact of this change, so I haven't made it. Please try again. (Error: %v)", err))
+	}
+	slog.Info("Guardrail classified a change", "tool", call.Name, "declared", declared, "classified", risk, "reason", reason)
+
+	if risk == llm.RiskReadOnly {
+		return refuse(declared, fmt.Sprintf("Your request reads as a question rather than a change (%s), but it was matched to %s, which would modify the device. "+
+			"Nothing was changed; please rephrase if you did mean to make a change.", reason, call.Name))
+	}
+	if risk < declared {
+		risk = declared
+	}
+	if risk > i.maxRisk {
+		return refuse(risk, fmt.Sprintf("This is a %s change (%s), and changes above %s are not allowed. "+
+			"Nothing was changed; rerun with -allow-disruptive or set GUARDRAIL_MAX_RISK to allow it.", risk, reason, i.maxRisk))
+	}
+	metrics.GuardrailDecisions.WithLabelValues(risk.String(), "allowed").Inc()
+	return "", true
+}
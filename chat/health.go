@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"fmt"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// HealthReport checks device reachability, credentials, ASM availability and
+// LLM API access, returning the formatted report and whether every check passed
+func (i *Interface) HealthReport() (string, bool) {
+	checks := i.bigipClient.CheckHealth()
+
+	// Check each provider of a fallback chain on its own, so a primary
+	// outage isn't hidden by a working fallback
+	if fallback, ok := i.llmClient.(*llm.Fallback); ok {
+		checks = append(checks,
+			pingCheck("LLM API", fallback.Primary()),
+			pingCheck("LLM fallback", fallback.Secondary()))
+	} else {
+		checks = append(checks, pingCheck("LLM API", i.llmClient))
+	}
+
+	healthy := true
+	for _, c := range checks {
+		healthy = healthy && c.Passed
+	}
+	return utils.FormatHealthChecks(checks), healthy
+}
+
+// pingCheck verifies that provider accepts its credentials
+func pingCheck(name string, provider llm.Provider) bigip.HealthCheck {
+	check := bigip.HealthCheck{Name: name, Passed: true, Detail: provider.Name() + " API key accepted"}
+	if _, ok := provider.(*llm.RulesProvider); ok {
+		check.Detail = "Not used (-no-llm); requests are matched by fixed rules"
+		return check
+	}
+	if err := provider.Ping(); err != nil {
+		check.Passed = false
+		check.Detail = fmt.Sprintf("%v", err)
+	}
+	return check
+}
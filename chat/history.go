@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"f5chat/llm"
+)
+
+// DefaultHistoryTurns is how many earlier question/answer pairs are sent
+// to the LLM with each query
+const DefaultHistoryTurns = 10
+
+// maxRememberedReply caps how much of each answer is kept; the object names
+// near the top are what follow-up questions refer to
+const maxRememberedReply = 2000
+
+// SetHistoryTurns changes how many earlier turns are remembered; 0 turns
+// conversation memory off
+func (i *Interface) SetHistoryTurns(turns int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if turns < 0 {
+		turns = 0
+	}
+	i.historyTurns = turns
+	i.trimHistory()
+}
+
+// ResetHistory forgets the conversation so far
+func (i *Interface) ResetHistory() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.history = nil
+	i.lastCalls = nil
+	i.focus = nil
+	i.choice = nil
+}
+
+// conversation returns a copy of the remembered turns
+func (i *Interface) conversation() []llm.Message {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]llm.Message(nil), i.history...)
+}
+
+// context is the conversation followed by a note of the objects discussed,
+// so the LLM can resolve "it" and "that pool"
+func (i *Interface) context() []llm.Message {
+	history := i.conversation()
+	if note, ok := i.entityNote(); ok {
+		history = append(history, note)
+	}
+	return history
+}
+
+// remember records a completed turn so later queries can refer back to it
+func (i *Interface) remember(query, response string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.historyTurns == 0 {
+		return
+	}
+	if len(response) > maxRememberedReply {
+		response = response[:maxRememberedReply] + "\n[...]"
+	}
+	i.history = append(i.history,
+		llm.Message{Role: llm.RoleUser, Content: query},
+		llm.Message{Role: llm.RoleAssistant, Content: response},
+	)
+	i.trimHistory()
+}
+
+// trimHistory drops the oldest turns beyond the limit; callers hold i.mu
+func (i *Interface) trimHistory() {
+	if excess := len(i.history) - 2*i.historyTurns; excess > 0 {
+		i.history = append([]llm.Message(nil), i.history[excess:]...)
+	}
+}
@@ -0,0 +1,211 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// liveObjects are the objects read from the device to be written as
+// infrastructure as code: virtual servers with their profiles, the pools
+// they use, the pools' members and the members' nodes, each once, in the
+// order they need creating
+type liveObjects struct {
+	// title names what was asked for: a full path, or "every virtual
+	// server"
+	title          string
+	virtualServers []bigip.VirtualServer
+	profiles       map[string][]bigip.VirtualProfile
+	pools          []bigip.Pool
+	members        map[string][]bigip.PoolMember
+	nodes          []bigip.Node
+}
+
+// readLiveObjects reads the virtual server the call names, with its pool,
+// the pool's members and their nodes; a pool name reads the pool alone,
+// and no name every virtual server. reply, when not empty, is the answer
+// instead: a choice between objects the name matches, or that there's
+// nothing to read.
+func (i *Interface) readLiveObjects(call *llm.ToolCall) (objects *liveObjects, reply string, err error) {
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	vs, err := i.bigipClient.GetVirtualServers()
+	if err != nil {
+		return nil, "", err
+	}
+	pools, poolMembers, err := i.bigipClient.GetPools()
+	if err != nil {
+		return nil, "", err
+	}
+	nodes, err := i.bigipClient.GetNodes()
+	if err != nil {
+		return nil, "", err
+	}
+
+	targets, poolTargets := vs, []bigip.Pool(nil)
+	if name != "" {
+		objects := make([]named, len(vs))
+		for n, v := range vs {
+			objects[n] = named{name: v.Name, fullPath: v.FullPath}
+		}
+		matches := matchNames(name, objects)
+		if len(matches) > 1 {
+			return nil, i.askChoice(call.Name, "virtual servers", name, matches), nil
+		}
+		targets = nil
+		for _, v := range vs {
+			if len(matches) == 1 && v.FullPath == matches[0] {
+				targets = append(targets, v)
+			}
+		}
+		if len(matches) == 0 {
+			poolObjects := make([]named, len(pools))
+			for n, p := range pools {
+				poolObjects[n] = named{name: p.Name, fullPath: p.FullPath}
+			}
+			poolMatches := matchNames(name, poolObjects)
+			if len(poolMatches) > 1 {
+				return nil, i.askChoice(call.Name, "pools", name, poolMatches), nil
+			}
+			if len(poolMatches) == 0 {
+				return nil, "", fmt.Errorf("no virtual server or pool named '%s'", name)
+			}
+			for _, p := range pools {
+				if p.FullPath == poolMatches[0] {
+					poolTargets = append(poolTargets, p)
+				}
+			}
+		}
+	}
+	if len(targets) == 0 && len(poolTargets) == 0 {
+		return nil, "There are no virtual servers to export.", nil
+	}
+
+	objects = &liveObjects{title: "every virtual server", profiles: map[string][]bigip.VirtualProfile{}, members: map[string][]bigip.PoolMember{}}
+	switch {
+	case len(targets) == 1:
+		objects.title = targets[0].FullPath
+	case len(targets) == 0:
+		objects.title = poolTargets[0].FullPath
+	}
+	poolsByPath := make(map[string]bigip.Pool, len(pools))
+	for _, p := range pools {
+		poolsByPath[p.FullPath] = p
+	}
+	for _, v := range targets {
+		objects.virtualServers = append(objects.virtualServers, v)
+		profiles, err := i.bigipClient.GetVirtualProfiles(v.FullPath)
+		if err != nil {
+			return nil, "", err
+		}
+		objects.profiles[v.FullPath] = profiles
+		if p, ok := poolsByPath[v.Pool]; ok {
+			poolTargets = append(poolTargets, p)
+		} else if v.Pool != "" {
+			slog.Warn("Exporting a virtual server without its pool", "virtual", v.FullPath, "pool", v.Pool)
+		}
+	}
+	nodesByPath := make(map[string]bigip.Node, len(nodes))
+	for _, n := range nodes {
+		nodesByPath[n.FullPath] = n
+	}
+	seen := make(map[string]bool)
+	for _, p := range poolTargets {
+		if seen[p.FullPath] {
+			continue
+		}
+		seen[p.FullPath] = true
+		objects.pools = append(objects.pools, p)
+		members, err := i.membersOf(p, poolMembers)
+		if err != nil {
+			return nil, "", err
+		}
+		objects.members[p.FullPath] = members
+		for _, m := range members {
+			nodePath, _, _ := splitMember(m.FullPath)
+			if n, ok := nodesByPath[nodePath]; ok && !seen[n.FullPath] {
+				seen[n.FullPath] = true
+				objects.nodes = append(objects.nodes, n)
+			}
+		}
+	}
+	return objects, "", nil
+}
+
+// node returns the node exported with the given full path
+func (o *liveObjects) node(fullPath string) (bigip.Node, bool) {
+	for _, n := range o.nodes {
+		if n.FullPath == fullPath {
+			return n, true
+		}
+	}
+	return bigip.Node{}, false
+}
+
+// counts says how many of each kind of object there are: "1 virtual
+// server, 1 pool, 2 pool members and 2 nodes"
+func (o *liveObjects) counts() string {
+	memberCount := 0
+	for _, members := range o.members {
+		memberCount += len(members)
+	}
+	var counts []string
+	for _, c := range []struct {
+		kind string
+		n    int
+	}{{"virtual server", len(o.virtualServers)}, {"pool", len(o.pools)}, {"pool member", memberCount}, {"node", len(o.nodes)}} {
+		if c.n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.n, plural(c.kind, c.n)))
+		}
+	}
+	what := strings.Join(counts, ", ")
+	if n := strings.LastIndex(what, ", "); n >= 0 {
+		what = what[:n] + " and " + what[n+2:]
+	}
+	return what
+}
+
+// splitDestination splits a virtual server's destination into its address
+// and port: /Common/10.1.10.80:443, or /Common/2001:db8::1.443 for IPv6.
+// The port is 0 for any port.
+func splitDestination(destination string) (address, port string) {
+	address = destination[strings.LastIndex(destination, "/")+1:]
+	port = utils.ParsePort(destination)
+	if port != "" {
+		address = address[:len(address)-len(port)-1]
+	}
+	if port == "any" || port == "" {
+		port = "0"
+	}
+	return address, port
+}
+
+// splitMember splits a pool member's full path into its node's and its
+// port: /Common/web1:80, or /Common/2001:db8::1.80 for IPv6
+func splitMember(fullPath string) (node, separator, port string) {
+	host := fullPath[strings.LastIndex(fullPath, "/")+1:]
+	separator = ":"
+	if strings.Count(host, ":") > 1 {
+		separator = "."
+	}
+	n := strings.LastIndex(fullPath, separator)
+	if n < 0 {
+		return fullPath, "", ""
+	}
+	return fullPath[:n], separator, fullPath[n+1:]
+}
+
+// monitorPaths picks the monitors out of a pool's monitor rule, such as
+// "/Common/http and /Common/tcp" or "min 1 of { /Common/http /Common/tcp }"
+func monitorPaths(rule string) []string {
+	var paths []string
+	for _, word := range strings.Fields(rule) {
+		if strings.HasPrefix(word, "/") {
+			paths = append(paths, word)
+		}
+	}
+	return paths
+}
@@ -1,159 +1,707 @@
 package chat
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
+	"f5chat/anomaly"
+	"f5chat/audit"
 	"f5chat/bigip"
+	"f5chat/compliance"
+	"f5chat/grafana"
+	"f5chat/history"
+	"f5chat/ihealth"
+	"f5chat/intent"
+	"f5chat/journal"
 	"f5chat/llm"
+	"f5chat/notify"
+	"f5chat/rag"
+	"f5chat/snapshot"
+	"f5chat/telemetry"
 	"f5chat/utils"
 )
 
+// BigIPClient is the subset of BIG-IP operations the chat interface needs.
+// *bigip.Client talks to a real device; *bigip.MockClient serves demo data.
+type BigIPClient interface {
+	GetVirtualServers() ([]bigip.VirtualServer, error)
+	GetPools() ([]bigip.Pool, map[string][]bigip.PoolMember, error)
+	GetPoolMembers(pool string) ([]bigip.PoolMember, error)
+	GetNodes() ([]bigip.Node, error)
+	GetWAFPolicies() ([]*bigip.WAFPolicy, error)
+	GetWAFPolicyDetails(policyName string) (*bigip.WAFPolicy, error)
+	GetIRule(name string) (*bigip.IRule, error)
+	GetProfiles(kind string) ([]bigip.Profile, error)
+	GetSecurityProfiles(kind string) ([]bigip.Profile, error)
+	GetVirtualProfiles(virtual string) ([]bigip.VirtualProfile, error)
+	GetCertificates() ([]bigip.Certificate, error)
+	GetSyncStatus() (*bigip.SyncStatus, error)
+	GetDevices() ([]bigip.DeviceInfo, error)
+	GetStats(kind string) (map[string]bigip.ObjectStats, error)
+	GetCPUUsage() (float64, error)
+	GetLogLines(n int) ([]string, error)
+	GetViolations(n int) ([]bigip.Violation, error)
+	GetOWASPCompliance(policy *bigip.WAFPolicy) (*bigip.OWASPCompliance, error)
+	GetTopology() (*bigip.Topology, error)
+	GetFirewallLists(kind string) ([]bigip.FirewallList, error)
+	AppendToFirewallList(kind, fullPath string, entries []string) error
+	CreateIRule(name, definition string) error
+	TenantExists(name string) (bool, error)
+	GetDeclaration(tenant string) (string, error)
+	DeployAS3(declaration string) ([]bigip.AS3Result, error)
+	CreateQkview(name string) (string, error)
+	DownloadQkview(name string, w io.Writer) (int64, error)
+	DeleteQkview(id string) error
+	ClearCache()
+	CheckHealth() []bigip.HealthCheck
+}
+
+var (
+	_ BigIPClient = (*bigip.Client)(nil)
+	_ BigIPClient = (*bigip.MockClient)(nil)
+)
+
 type Interface struct {
-	bigipClient *bigip.Client
-	llmClient   *llm.OpenAIClient
+	bigipClient BigIPClient
+	llmClient   llm.Provider
+
+	retriever  *rag.Retriever
+	classifier *intent.Classifier
+
+	// maxRisk is the largest blast radius a change may have (see guard)
+	maxRisk llm.Risk
+
+	mu           sync.Mutex
+	history      []llm.Message
+	historyTurns int
+	// queries are the queries typed so far, across sessions (see
+	// fromHistory); lastQuery is the latest, for "save this as"
+	queries   *history.Store
+	lastQuery string
+	// saved holds the queries saved by name (see manageSaved)
+	saved *history.Saved
+	// planPreview starts each answer with the REST calls made for it;
+	// dryRun stops at the plan, for "/plan QUERY" (see planCommand)
+	planPreview bool
+	dryRun      bool
+	// followUps suggests questions to ask next after each answer
+	followUps bool
+	// devices are the BIG-IPs asked by requests across all devices
+	devices []device
+	// partition scopes queries that don't name one (see partitionCommand)
+	partition string
+	// profiles are the devices /device switches between, profile the one
+	// in use and openProfile connects to them (see SetProfiles)
+	profiles    []string
+	profile     string
+	openProfile ProfileOpener
+	// snapshots are the object lists taken with /snapshot, for "what
+	// changed since" questions (see answerChanges)
+	snapshots *snapshot.Store
+	// format is how answers are written (see SetOutputFormat); operations
+	// are those run for the answer in progress, with the objects they read
+	format     string
+	operations []operation
+	// templates are the user's layouts of listings (see SetTemplates)
+	templates *utils.Templates
+	// verbose shows every field of each object in listings (see
+	// verboseCommand)
+	verbose bool
+	// lastOperations are those behind the latest answer that ran any, for
+	// "export this as CSV"
+	lastOperations []operation
+	// lastAnswer is the latest answer as written, for "save that to FILE"
+	lastAnswer string
+	// notifier sends the alerts watch mode finds (see SetNotifier)
+	notifier       *notify.Router
+	notifierDevice string
+	// anomalies flags unusual connection rates and CPU usage while
+	// watching (see SetAnomalies)
+	anomalies *anomaly.Detector
+	// annotations marks changes and failovers on Grafana's graphs (see
+	// SetAnnotations), and failoverState is the device's failover state
+	// as the last watch round saw it
+	annotations   *grafana.Client
+	failoverState string
+	// iHealth analyzes the qkviews uploaded to it (see SetIHealth), and
+	// lastQkview is the file the latest qkview generated was saved to
+	iHealth    *ihealth.Client
+	lastQkview string
+	// failure is the kind of failure the answer being given ended in, and
+	// failureMessage the error or answer explaining it
+	failure, failureMessage string
+	// pending, pendingAS3 and pendingList are a generated iRule or
+	// declaration, or entries for a firewall list, awaiting the user's
+	// confirmation; at most one is set
+	pending     *pendingIRule
+	pendingAS3  *pendingDeclaration
+	pendingList *pendingListChange
+	// lastCalls are the operations behind the latest answer, for "what tmsh
+	// command does this?"
+	lastCalls []*llm.ToolCall
+	// choice lists the objects a name matched, awaiting the user's pick
+	choice *pendingChoice
+	// focus holds the objects discussed most recently, one per kind, so
+	// "show its members" resolves (see resolveReference)
+	focus []entity
+
+	// audit records each query and the REST calls made for it (see SetAudit)
+	audit *audit.Log
+	// journal records each change made to a device (see SetJournal)
+	journal *journal.Journal
+	// complianceRules are the rules /compliance checks (see
+	// SetComplianceRules)
+	complianceRules []compliance.Rule
+
+	// agentMode sends queries the classifier doesn't match through the
+	// multi-step loop, bounded by agentSteps (see EnableAgent)
+	agentMode  bool
+	agentSteps int
+
+	// stream shows long listings as they're read (see SetStream);
+	// streaming is where the answer in progress may be streamed to,
+	// streamCall the operation whose listing may be, and streamed whether
+	// it was
+	stream     func(string)
+	streaming  func(string)
+	streamCall *llm.ToolCall
+	streamed   bool
+	// warmNow wakes WarmCache to warm the client in use (see warmSoon)
+	warmNow chan struct{}
+
+	// concurrent marks the copies onDevice makes, which run alongside each
+	// other, so their operations aren't spans of their own: spans started
+	// at once would nest in each other
+	concurrent bool
 }
 
-func NewInterface(bigipClient *bigip.Client, llmClient *llm.OpenAIClient) *Interface {
+func NewInterface(bigipClient BigIPClient, llmClient llm.Provider) *Interface {
 	return &Interface{
-		bigipClient: bigipClient,
-		llmClient:   llmClient,
+		bigipClient:  bigipClient,
+		llmClient:    llmClient,
+		historyTurns: DefaultHistoryTurns,
+		maxRisk:      llm.RiskLowRisk,
+		followUps:    true,
+		format:       FormatText,
 	}
 }
 
+// ProcessQuery answers a query in the output format chosen. In the JSON
+// and YAML formats errors are part of the answer rather than returned; in the CSV
+// format answers without a listing are written as text.
 func (i *Interface) ProcessQuery(query string) (string, error) {
-	// First, use LLM to understand the intent and get structured response
-	llmResponse, err := i.llmClient.ProcessPrompt(query)
-	if err != nil {
-		return "", fmt.Errorf("I apologize, but I'm having trouble understanding your request. Could you please rephrase it? (Error: %v)", err)
+	span := telemetry.Start("query")
+	defer span.End()
+	span.Set("chatf5.query", query)
+	i.takeOperations()
+	i.takeCalls()
+	i.clearFailure()
+	i.openStream()
+	response, err := i.answer(query)
+	i.closeStream()
+	i.explainFailure(response, err)
+	ops := i.takeOperations()
+	i.recordAudit(query, ops)
+	if len(ops) > 0 {
+		i.mu.Lock()
+		i.lastOperations = ops
+		i.mu.Unlock()
+	}
+	span.Set("chatf5.operations", len(ops))
+	span.Fail(err)
+
+	format := telemetry.Start("format")
+	format.Set("chatf5.output", i.OutputFormat())
+	defer format.End()
+	switch i.OutputFormat() {
+	case FormatJSON:
+		response, err = formatJSON(query, response, err, ops), nil
+	case FormatYAML:
+		response, err = formatYAML(query, response, err, ops), nil
+	case FormatCSV:
+		if table, ok := formatCSV(ops); ok && err == nil {
+			response = table
+		}
+	case FormatMarkdown:
+		if err == nil {
+			response = formatMarkdown(response, ops)
+		}
+	}
+	if err == nil && !saveAnswer.MatchString(query) {
+		i.mu.Lock()
+		i.lastAnswer = response
+		i.mu.Unlock()
+	}
+	return response, err
+}
+
+// answer handles history and saved query commands, then answers the query
+func (i *Interface) answer(query string) (string, error) {
+	if response, handled, err := i.fromHistory(query); handled {
+		return response, err
+	}
+	if response, handled, err := i.manageSaved(query); handled {
+		return response, err
+	}
+	i.recordQuery(query)
+	if m := runCommand.FindStringSubmatch(strings.TrimSpace(query)); m != nil {
+		return i.runSaved(m[1])
+	}
+	return i.process(query)
+}
+
+// process answers a query; saved queries run through here without being
+// recorded again
+func (i *Interface) process(query string) (string, error) {
+	if response, handled, err := i.planCommand(query); handled {
+		return response, err
+	}
+	if response, handled := i.partitionCommand(query); handled {
+		return response, nil
+	}
+	if response, handled := i.deviceCommand(query); handled {
+		return response, nil
+	}
+	if response, handled := i.formatCommand(query); handled {
+		return response, nil
+	}
+	if response, handled := i.verboseCommand(query); handled {
+		return response, nil
+	}
+	if response, handled, err := i.snapshotCommand(query); handled {
+		return response, err
+	}
+	if response, handled, err := i.inventoryCommand(query); handled {
+		return response, err
+	}
+	if response, handled, err := i.exportCommand(query); handled {
+		return response, err
+	}
+	if response, handled, err := i.saveAnswerCommand(query); handled {
+		return response, err
+	}
+	if _, _, ok := ParseWatch(query); ok {
+		// Only the chat prompt and "chatf5 query -watch" can keep asking
+		return "Watching asks a query again and again until Ctrl-C, so it works at the chat prompt, or as: chatf5 query -watch 30s \"show pool web_pool\"", nil
+	}
+	i.mu.Lock()
+	dryRun, preview := i.dryRun, i.planPreview
+	i.mu.Unlock()
+
+	switch strings.TrimSpace(query) {
+	case "/health":
+		report, _ := i.HealthReport()
+		return report, nil
+	case "/compliance":
+		report, _, err := i.ComplianceReport()
+		return report, err
+	case "/reset":
+		i.ResetHistory()
+		return "Conversation history cleared.", nil
+	case "/redactions":
+		if report, ok := llm.RedactionReport(i.llmClient); ok {
+			return "Data redacted before it was sent to the LLM:\n" + report, nil
+		}
+		return "Redaction is off: nothing is sent to a hosted LLM, or REDACT_ENABLED=false.", nil
+	case "/usage":
+		if report, ok := llm.SpendReport(i.llmClient); ok {
+			return "Hosted LLM usage:\n" + report, nil
+		}
+		return "No hosted LLM is in use, so there are no spend limits.", nil
+	}
+
+	// A generated iRule or declaration is only applied if the very next
+	// reply confirms it; asking for it as a playbook keeps it waiting
+	if response, ok := i.pendingAnsible(query); ok {
+		return response, nil
+	}
+	if pending := i.takePendingIRule(); pending != nil {
+		if response, handled := i.confirmIRule(pending, query); handled {
+			return response, nil
+		}
+	}
+	if pending := i.takePendingAS3(); pending != nil {
+		if response, handled := i.confirmAS3(pending, query); handled {
+			return response, nil
+		}
+	}
+	if pending := i.takePendingList(); pending != nil {
+		if response, handled := i.confirmListChange(pending, query); handled {
+			return response, nil
+		}
+	}
+
+	if _, ok := parseTmsh(query); !ok && tmshQuestion.MatchString(query) {
+		return i.tmshForLast(), nil
+	}
+	if since, ok := llm.ParseChangesSince(query, time.Now()); ok {
+		return i.answerChanges(query, since)
+	}
+
+	// A pick from a list of matching objects runs the operation on it. Pasted
+	// tmsh reads run as the matching operation and anything else is
+	// explained; "/agent" questions are investigated step by step and
+	// general questions about BIG-IP concepts are answered without the
+	// device. Requests for every configured BIG-IP run on each device, and
+	// requests for several kinds of object are split into one operation
+	// each. Common requests are matched by the intent classifier without a
+	// chat completion. Otherwise, in agent mode the question is
+	// investigated, and if not the LLM picks the BIG-IP operation and its
+	// arguments, with earlier turns so follow-up questions resolve and any
+	// relevant docs
+	var (
+		reply *llm.Reply
+		docs  []rag.Result
+		note  string
+	)
+	// "refresh" bypasses cached device responses for this and later
+	// queries, whichever way they are answered
+	if wantsRefresh(query) && !dryRun {
+		i.bigipClient.ClearCache()
+	}
+	question, explicitAgent := agentCommand(query)
+	if call, ok := i.pickChoice(query); ok {
+		reply = &llm.Reply{ToolCall: call}
+	} else if cmd, ok := parseTmsh(query); ok {
+		call, rest, ok := tmshCall(cmd)
+		if !ok {
+			response := i.explainTmsh(cmd)
+			i.remember(query, response)
+			return response, nil
+		}
+		reply = &llm.Reply{ToolCall: call}
+		note = fmt.Sprintf("Running `%s` as %s:\n\n", cmd.raw, rest)
+	} else if dryRun && (explicitAgent || i.agentMode && !llm.ConceptQuestion(query)) {
+		return "Investigations pick each step from what the one before found, so there is no plan to show in advance.", nil
+	} else if explicitAgent {
+		return i.answerAgent(query, question)
+	} else if llm.ConceptQuestion(query) {
+		if dryRun {
+			return "No request to the device: general questions are answered from the LLM and the documentation.", nil
+		}
+		return i.answerConcept(query)
+	} else if fan, ok := llm.ParseFanOut(query); ok {
+		return i.answerFanOut(query, fan)
+	} else if clauses, ok := llm.ParseMultiple(query); ok {
+		return i.answerMultiple(query, clauses)
+	} else if call, ok := i.resolveReference(query); ok {
+		reply = &llm.Reply{ToolCall: call}
+	} else if call, ok := i.classify(query); ok {
+		reply = &llm.Reply{ToolCall: call}
+	} else if i.agentMode {
+		return i.answerAgent(query, query)
+	} else {
+		var history []llm.Message
+		history, docs = i.withDocumentation(i.context(), query)
+		var err error
+		reply, err = i.llmClient.ProcessWithTools(history, query)
+		if message, ok := unavailableMessage(err); ok {
+			i.setFailure(FailureLLM)
+			return message, nil
+		}
+		if err != nil {
+			i.setFailure(FailureNotUnderstood)
+			return "", fmt.Errorf("I apologize, but I'm having trouble understanding your request. Could you please rephrase it? (Error: %v)", err)
+		}
+	}
+	if reply.ToolCall == nil && dryRun {
+		return "No request to the device: the LLM answers this without device data.", nil
+	}
+	if reply.ToolCall == nil {
+		// General question answered without device data
+		if strings.TrimSpace(reply.Text) == "" {
+			i.setFailure(FailureNotUnderstood)
+			return helpText, nil
+		}
+		i.remember(query, reply.Text)
+		return citeSources(reply.Text, docs), nil
 	}
 
-	// Execute the appropriate BIG-IP operation based on LLM response
-	response, err := i.executeOperation(llmResponse, query)
+	i.fillReference(reply.ToolCall)
+	fillListArgs(query, reply.ToolCall)
+	i.scopeToPartition(query, reply.ToolCall)
+	i.fillTarget(query, reply.ToolCall)
+	if dryRun {
+		return formatPlan(reply.ToolCall) + "\nNothing was run; ask again without /plan to run it.", nil
+	}
+	if message, ok := i.guard(query, reply.ToolCall); !ok {
+		return message, nil
+	}
+
+	// Execute the BIG-IP operation the LLM chose. A long listing is shown
+	// as it's read, unless it's to follow the plan.
+	if !preview {
+		i.markStreamCall(reply.ToolCall)
+	}
+	response, err := i.executeTool(reply.ToolCall)
+	streamed := i.unmarkStreamCall()
+	if err != nil {
+		i.setFailure(failureOf(err))
+	}
+	var openErr *bigip.CircuitOpenError
+	if errors.As(err, &openErr) {
+		// Fail fast with the breaker's own message rather than a generic apology
+		return "", openErr
+	}
+	var connErr *bigip.ConnectError
+	if errors.As(err, &connErr) {
+		return fmt.Sprintf("I can't reach the BIG-IP at %s right now (%v).\n"+
+			"I'll try to reconnect automatically with your next question, so you can keep asking once the device is back.",
+			connErr.Host, connErr.Err), nil
+	}
+	if message, ok := unavailableMessage(err); ok {
+		// Operations that summarise with the LLM, such as explaining an iRule
+		return message, nil
+	}
 	if err != nil {
 		return "", fmt.Errorf("I understood your request about the BIG-IP configuration, but encountered an issue while fetching the information. Please try again. (Error: %v)", err)
 	}
 
+	response = note + response
+	if preview {
+		response = formatPlan(reply.ToolCall) + "\n" + strings.TrimLeft(response, "\n")
+	}
+	i.setLastCall(reply.ToolCall)
+	if !streamed {
+		i.noteEntities(query, reply.ToolCall)
+	}
+	i.remember(query, response)
+	i.mu.Lock()
+	followUps := i.followUps
+	i.mu.Unlock()
+	if followUps && !i.choosing() && !streamed {
+		// Left out of the conversation; they aren't part of the answer
+		response = strings.TrimRight(response, "\n") + formatFollowUps(i.suggestFollowUps(query, reply.ToolCall))
+	}
 	return response, nil
 }
 
-// containsAny checks if the text contains any of the given phrases
-func containsAny(text string, phrases []string) bool {
-	for _, phrase := range phrases {
-		if strings.Contains(text, phrase) {
+// unavailableMessage explains an LLM API that is rate limiting or down, or
+// a spend limit that has been reached
+func unavailableMessage(err error) (string, bool) {
+	var limit *llm.SpendLimitError
+	if errors.As(err, &limit) {
+		return fmt.Sprintf("I've stopped sending requests to the LLM: %v. Type /usage to see what has been used.", limit), true
+	}
+	var unavailable *llm.UnavailableError
+	if !errors.As(err, &unavailable) {
+		return "", false
+	}
+	if unavailable.RateLimited() {
+		return fmt.Sprintf("The %s API is rate limiting requests right now, or the key has run out of quota. Please try again in a moment.", unavailable.Provider), true
+	}
+	return fmt.Sprintf("The %s API isn't responding right now. Please try again in a moment. (Error: %v)", unavailable.Provider, unavailable.Err), true
+}
+
+// wantsRefresh reports whether the user asked for fresh data from the device
+func wantsRefresh(query string) bool {
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if strings.Trim(word, ".,!?'\"") == "refresh" {
 			return true
 		}
 	}
 	return false
 }
 
-func (i *Interface) executeOperation(llmResponse string, originalQuery string) (string, error) {
-	// Enhanced intent detection with common variations
-	lowerResponse := strings.ToLower(llmResponse)
-
-	// WAF Policy related queries
-	if containsAny(lowerResponse, []string{
-		"waf", "web application firewall", 
-		"asm", "application security",
-		"security policy", "policies",
-		"protection", "web security",
-	}) {
-		log.Printf("Detected WAF policy query request: %s", originalQuery)
-		
-		// Check if looking for a specific policy details
-		if (strings.Contains(lowerResponse, "details") || 
-			strings.Contains(lowerResponse, "show") || 
-			strings.Contains(lowerResponse, "get")) && 
-			strings.Contains(lowerResponse, "policy") {
-			
-			log.Printf("Detected request for specific WAF policy details")
-			
-			// Extract policy name from the query
-			words := strings.Fields(lowerResponse)
-			var policyName string
-			for i, word := range words {
-				if (word == "policy" || word == "waf" || word == "asm") && i+1 < len(words) {
-					policyName = words[i+1]
-					if !strings.Contains(policyName, "details") && !strings.Contains(policyName, "policy") {
-						log.Printf("Found policy name in query: %s", policyName)
-						break
-					}
-				}
-			}
-			
-			if policyName != "" {
-				log.Printf("Attempting to fetch details for WAF policy: %s", policyName)
-				policy, err := i.bigipClient.GetWAFPolicyDetails(policyName)
-				if err != nil {
-					log.Printf("Error fetching WAF policy details: %v", err)
-					return "", fmt.Errorf("failed to fetch WAF policy details: %v", err)
-				}
-				log.Printf("Successfully retrieved WAF policy details for %s", policyName)
-				return utils.FormatWAFPolicyDetails(policy), nil
-			}
+// helpText is shown when the request doesn't map onto a BIG-IP operation
+const helpText = "I understand you're asking about BIG-IP configuration. To help you better, could you please be more specific?\n\n" +
+	"You can ask questions like:\n" +
+	"1. 'Show me all virtual servers (VIPs)' - View front-end service points\n" +
+	"2. 'List all pools and their members' - See load balancing groups\n" +
+	"3. 'Display node status' - Check backend server health\n\n" +
+	"Feel free to ask about specific components or use natural language to describe what you're looking for."
+
+// executeTool runs the operation chosen by the LLM and formats the result
+func (i *Interface) executeTool(call *llm.ToolCall) (response string, err error) {
+	slog.Debug("Executing tool call", "tool", call.Name, "args", call.Args)
+	i.noteOperation(call)
+	var span *telemetry.Span
+	if !i.concurrent {
+		span = telemetry.Start("operation " + call.Name)
+		span.Set("chatf5.operation", call.Name)
+	}
+	defer func() {
+		span.Fail(err)
+		span.End()
+	}()
+
+	switch call.Name {
+	case llm.ToolListVirtualServers:
+		return i.listVirtualServers(call)
+
+	case llm.ToolListPools:
+		return i.listPools(call)
+
+	case llm.ToolGetPool:
+		name := strings.Trim(call.Arg("name"), "\"'`")
+		if name == "" {
+			return "Which pool would you like to see? Please include its name, e.g. 'show pool web_pool'.", nil
 		}
-		
-		// Default: list all policies with virtual server associations
-		log.Printf("Fetching all WAF policies with virtual server associations")
-		policies, err := i.bigipClient.GetWAFPolicies()
+		pools, poolMembers, err := i.bigipClient.GetPools()
 		if err != nil {
-			log.Printf("Error fetching WAF policies: %v", err)
-			switch {
-			case strings.Contains(err.Error(), "not found"):
-				return "", fmt.Errorf("WAF (Web Application Firewall) policies endpoint not found. Please ensure:\n1. ASM module is provisioned\n2. You have appropriate permissions\n3. WAF feature is licensed")
-			case strings.Contains(err.Error(), "unauthorized"):
-				return "", fmt.Errorf("Authentication failed. Please verify your credentials and WAF access permissions")
-			case strings.Contains(err.Error(), "connection"):
-				return "", fmt.Errorf("Connection error. Please verify:\n1. BIG-IP is accessible\n2. Network connectivity\n3. HTTPS/TLS settings")
-			default:
-				return "", fmt.Errorf("Unable to fetch WAF policies. This could be due to:\n1. ASM module not being provisioned\n2. Insufficient permissions\n3. Network connectivity issues\n\nError details: %v", err)
-			}
+			return "", err
 		}
-		log.Printf("Successfully retrieved %d WAF policies", len(policies))
-		
-		// Log policy details for debugging
-		for _, policy := range policies {
-			log.Printf("Processing policy: %s", policy.Name)
-			log.Printf("Virtual Servers: %v", policy.VirtualServers)
-			log.Printf("Status: %v", policy.Active)
-			log.Printf("Enforcement Mode: %s", policy.EnforcementMode)
+		objects := make([]named, len(pools))
+		for n, p := range pools {
+			objects[n] = named{name: p.Name, fullPath: p.FullPath}
 		}
-		
-		return utils.FormatWAFPolicies(policies), nil
-	}
+		matches := matchNames(name, objects)
+		if len(matches) > 1 {
+			return i.askChoice(call.Name, "pools", name, matches), nil
+		}
+		for _, p := range pools {
+			if len(matches) == 1 && p.FullPath == matches[0] {
+				i.setData(poolData(p, bigip.MemberPaths(poolMembers[p.FullPath])))
+				return i.render(utils.TemplatePools, utils.PoolsWithMembers([]bigip.Pool{p}, poolMembers), func() string {
+					return utils.FormatPools([]bigip.Pool{p}, poolMembers)
+				})
+			}
+		}
+		return "", &bigip.ObjectNotFoundError{Kind: "pool", Name: name}
+
+	case llm.ToolListNodes:
+		return i.listNodes(call)
 
-	// Virtual Server related queries
-	if containsAny(lowerResponse, []string{"virtual server", "vip", "virtual ip", "virtual address"}) {
-		vs, err := i.bigipClient.GetVirtualServers()
+	case llm.ToolGetWAFPolicy:
+		// Policy names and /Partition/name paths are case-sensitive, so use them as given
+		policyName := strings.Trim(call.Arg("name"), "\"'`")
+		if policyName == "" {
+			return i.listWAFPolicies(call)
+		}
+		slog.Debug("Fetching WAF policy details", "policy", policyName)
+		policy, err := i.bigipClient.GetWAFPolicyDetails(policyName)
+		var ambiguous *bigip.AmbiguousNameError
+		if errors.As(err, &ambiguous) {
+			return i.askChoice(call.Name, "WAF policies", policyName, ambiguous.Matches), nil
+		}
+		if errors.As(err, new(*bigip.ObjectNotFoundError)) {
+			// Not an exact name; look for policies it is part of
+			if matches := i.wafPolicyMatches(policyName); len(matches) > 1 {
+				return i.askChoice(call.Name, "WAF policies", policyName, matches), nil
+			} else if len(matches) == 1 {
+				policy, err = i.bigipClient.GetWAFPolicyDetails(matches[0])
+			}
+		}
 		if err != nil {
-			return "", err
+			slog.Error("Failed to fetch WAF policy details", "policy", policyName, "err", err)
+			return "", fmt.Errorf("failed to fetch WAF policy details: %w", err)
 		}
-		return utils.FormatVirtualServers(vs), nil
+		i.setData(policy)
+		return i.render(utils.TemplateWAFPolicy, policy, func() string { return utils.FormatWAFPolicyDetails(policy) })
+
+	case llm.ToolListWAFPolicies:
+		return i.listWAFPolicies(call)
+
+	case llm.ToolGenerateIRule:
+		return i.generateIRule(call)
+
+	case llm.ToolExplainIRule:
+		return i.explainIRule(call)
+
+	case llm.ToolGenerateAS3:
+		return i.generateAS3(call)
+
+	case llm.ToolCompare:
+		return i.compareObjects(call)
+
+	case llm.ToolTroubleshoot:
+		return i.troubleshoot(call)
+
+	case llm.ToolSecurityPosture:
+		return i.securityPosture(call)
+
+	case llm.ToolWAFViolations:
+		return i.wafViolations(call)
+
+	case llm.ToolOWASPCompliance:
+		return i.owaspCompliance(call)
+
+	case llm.ToolGTMTopology:
+		return i.gtmTopology(call)
+	case llm.ToolGetAddressList, llm.ToolGetPortList:
+		return i.firewallList(call)
+	case llm.ToolAddToFirewallList:
+		return i.addToFirewallList(call)
+
+	case llm.ToolChangeJournal:
+		return i.changeJournal(call)
+
+	case llm.ToolHealthSummary:
+		return i.healthSummary(call)
+
+	case llm.ToolExportTerraform:
+		return i.exportTerraform(call)
+
+	case llm.ToolGenerateAnsible:
+		return i.generateAnsible(call)
+
+	case llm.ToolGenerateQkview:
+		return i.generateQkview(call)
+
+	case llm.ToolUploadQkview:
+		return i.uploadQkview(call)
 	}
 
-	// Pool related queries
-	if containsAny(lowerResponse, []string{"pool", "server pool", "backend pool", "server group"}) {
-		pools, poolMembers, err := i.bigipClient.GetPools()
-		if err != nil {
-			return "", err
-		}
-		return utils.FormatPools(pools, poolMembers), nil
+	slog.Warn("LLM requested an unknown tool", "tool", call.Name)
+	i.setFailure(FailureNotUnderstood)
+	return helpText, nil
+}
+
+// wafPolicyMatches returns the policies a name that isn't exact could mean
+func (i *Interface) wafPolicyMatches(name string) []string {
+	policies, err := i.bigipClient.GetWAFPolicies()
+	if err != nil {
+		return nil
 	}
+	objects := make([]named, len(policies))
+	for n, p := range policies {
+		objects[n] = named{name: p.Name, fullPath: p.FullPath}
+	}
+	return matchNames(name, objects)
+}
 
-	// Node related queries
-	if containsAny(lowerResponse, []string{"node", "server", "backend", "real server"}) {
-		nodes, err := i.bigipClient.GetNodes()
-		if err != nil {
+// listWAFPolicies lists all policies with their virtual server associations,
+// turning the common failure modes into actionable messages
+func (i *Interface) listWAFPolicies(call *llm.ToolCall) (string, error) {
+	slog.Debug("Fetching all WAF policies with virtual server associations")
+	policies, err := i.bigipClient.GetWAFPolicies()
+	if err != nil {
+		slog.Error("Failed to fetch WAF policies", "err", err)
+		i.setFailure(failureOf(err))
+		var (
+			moduleErr   *bigip.ModuleNotProvisionedError
+			notFoundErr *bigip.NotFoundError
+			authErr     *bigip.AuthError
+			timeoutErr  *bigip.TimeoutError
+			apiErr      *bigip.APIError
+		)
+		switch {
+		case errors.As(err, new(*bigip.ConnectError)), errors.As(err, new(*bigip.CircuitOpenError)):
+			// Reported by ProcessQuery
 			return "", err
+		case errors.As(err, &moduleErr), errors.As(err, &notFoundErr):
+			return "", fmt.Errorf("WAF (Web Application Firewall) policies endpoint not found. Please ensure:\n1. ASM module is provisioned\n2. You have appropriate permissions\n3. WAF feature is licensed")
+		case errors.As(err, &authErr):
+			return "", fmt.Errorf("Authentication failed (HTTP %d). Please verify your credentials and WAF access permissions", authErr.StatusCode)
+		case errors.As(err, &timeoutErr):
+			return "", fmt.Errorf("The request to %s timed out. The BIG-IP management plane may be busy; please try again shortly", timeoutErr.Endpoint)
+		case errors.As(err, &apiErr) && apiErr.StatusCode == 0:
+			return "", fmt.Errorf("Connection error. Please verify:\n1. BIG-IP is accessible\n2. Network connectivity\n3. HTTPS/TLS settings")
+		default:
+			return "", fmt.Errorf("Unable to fetch WAF policies. This could be due to:\n1. ASM module not being provisioned\n2. Insufficient permissions\n3. Network connectivity issues\n\nError details: %w", err)
 		}
-		return utils.FormatNodes(nodes), nil
 	}
-
-	return "I understand you're asking about BIG-IP configuration. To help you better, could you please be more specific?\n\n" +
-		"You can ask questions like:\n" +
-		"1. 'Show me all virtual servers (VIPs)' - View front-end service points\n" +
-		"2. 'List all pools and their members' - See load balancing groups\n" +
-		"3. 'Display node status' - Check backend server health\n\n" +
-		"Feel free to ask about specific components or use natural language to describe what you're looking for.", nil
-}
\ No newline at end of file
+	slog.Debug("Retrieved WAF policies", "count", len(policies))
+	i.setData(policies)
+	return i.render(utils.TemplateWAFPolicies, policies, func() string {
+		if i.detailed(call) {
+			return utils.FormatWAFPolicies(policies)
+		}
+		return utils.FormatWAFPoliciesCompact(policies)
+	})
+}
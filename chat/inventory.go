@@ -0,0 +1,221 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"f5chat/bigip"
+)
+
+// exportInventory matches "export the full inventory", "export inventory
+// as CSV" and "save the complete inventory to audit.xlsx"
+var exportInventory = regexp.MustCompile(`(?i)^\s*(?:please\s+)?(?:export|save|download|write)\s+(?:the\s+|a\s+)?(?:(?:full|complete|whole|entire)\s+)?inventory(?:\s+(?:as|to|in|into)\s+(?:an?\s+)?(xlsx|excel|spreadsheet|workbook|csvs?)(?:\s+(?:file|workbook)s?)?)?(?:\s+(?:to|as|in|into|called|named)?\s*["'` + "`" + `]?([^\s"'` + "`" + `]+\.(?:xlsx|csv))["'` + "`" + `]?)?[\s.!]*$`)
+
+// inventoryCommand handles "export the full inventory", which reads every
+// kind of object the device has and writes them as a workbook, one sheet
+// each, or as CSV files, one each
+func (i *Interface) inventoryCommand(query string) (string, bool, error) {
+	m := exportInventory.FindStringSubmatch(query)
+	if m == nil {
+		return "", false, nil
+	}
+	format, file := strings.ToLower(m[1]), m[2]
+	wantCSV, fileCSV := strings.HasPrefix(format, "csv"), strings.HasSuffix(strings.ToLower(file), ".csv")
+	if format != "" && file != "" && wantCSV != fileCSV {
+		return fmt.Sprintf("%s isn't a %s file. Name a .xlsx file for a workbook, or a .csv one for CSV files, e.g. \"export the full inventory to audit.xlsx\".", file, format), true, nil
+	}
+	csv := wantCSV || fileCSV
+	i.mu.Lock()
+	dryRun := i.dryRun
+	i.mu.Unlock()
+	if dryRun {
+		return "Would read every virtual server, pool, pool member, node, certificate and WAF policy, and write them to a file.\nNothing was run; ask again without /plan to run it.", true, nil
+	}
+
+	host := i.hostname()
+	sheets, notes, err := i.inventorySheets()
+	if err != nil {
+		return "", true, err
+	}
+	if file == "" {
+		file = fmt.Sprintf("inventory-%s-%s.xlsx", host, time.Now().Format("20060102-150405"))
+		if csv {
+			file = strings.TrimSuffix(file, ".xlsx") + ".csv"
+		}
+	}
+
+	var written []string
+	if csv {
+		for _, s := range sheets {
+			name := strings.TrimSuffix(file, ".csv") + "-" + s.kind + ".csv"
+			if err := writeNewFile(name, writeCSV(s.table), "export the full inventory as CSV to audit-2.csv"); err != nil {
+				return "", true, err
+			}
+			written = append(written, fmt.Sprintf("%s (%d %s)", name, len(s.rows), plural("row", len(s.rows))))
+		}
+	} else {
+		counts := make([]string, len(sheets))
+		for n, s := range sheets {
+			counts[n] = fmt.Sprintf("%s (%d)", s.name, len(s.rows))
+		}
+		summary := &table{kind: "summary", header: []string{"Inventory", host}, rows: [][]string{{"Generated", time.Now().UTC().Format(time.RFC3339)}}}
+		for _, s := range sheets {
+			summary.rows = append(summary.rows, []string{s.name, strconv.Itoa(len(s.rows))})
+		}
+		for _, note := range notes {
+			summary.rows = append(summary.rows, []string{"Note", note})
+		}
+		workbook, err := writeXLSX(append([]sheet{{"Summary", summary}}, sheets...))
+		if err != nil {
+			return "", true, err
+		}
+		if err := writeNewFile(file, string(workbook), "export the full inventory to audit-2.xlsx"); err != nil {
+			return "", true, err
+		}
+		written = append(written, fmt.Sprintf("%s, a sheet each for %s", file, strings.Join(counts, ", ")))
+	}
+
+	var sb strings.Builder
+	if len(written) == 1 {
+		fmt.Fprintf(&sb, "Wrote the inventory of %s to %s.", host, written[0])
+	} else {
+		fmt.Fprintf(&sb, "Wrote the inventory of %s to %d CSV files:\n", host, len(written))
+		for _, w := range written {
+			sb.WriteString("  " + w + "\n")
+		}
+	}
+	for _, note := range notes {
+		sb.WriteString("\n" + note)
+	}
+	response := strings.TrimRight(sb.String(), "\n")
+	i.remember(query, response)
+	return response, true, nil
+}
+
+// inventorySheets reads every kind of object the inventory covers, in every
+// partition, reading the kinds at once. A module that isn't provisioned
+// leaves its sheet out, with a note saying so; statistics that can't be
+// read leave availability blank.
+func (i *Interface) inventorySheets() ([]sheet, []string, error) {
+	var (
+		virtuals           []bigip.VirtualServer
+		pools              []bigip.Pool
+		listed             map[string][]bigip.PoolMember
+		nodes              []bigip.Node
+		certs              []bigip.Certificate
+		policies           []*bigip.WAFPolicy
+		vsStats, poolStats map[string]bigip.ObjectStats
+		noWAF              bool
+		g                  errgroup.Group
+	)
+	availability := func(kind string, stats *map[string]bigip.ObjectStats) func() error {
+		return func() (err error) {
+			if *stats, err = i.bigipClient.GetStats(kind); err != nil {
+				slog.Warn("Inventory without availability", "kind", kind, "err", err)
+			}
+			return nil
+		}
+	}
+	g.Go(func() (err error) {
+		virtuals, err = i.bigipClient.GetVirtualServers()
+		return err
+	})
+	g.Go(availability("virtual", &vsStats))
+	g.Go(func() (err error) {
+		pools, listed, err = i.bigipClient.GetPools()
+		return err
+	})
+	g.Go(availability("pool", &poolStats))
+	g.Go(func() (err error) {
+		nodes, err = i.bigipClient.GetNodes()
+		return err
+	})
+	g.Go(func() (err error) {
+		certs, err = i.bigipClient.GetCertificates()
+		return err
+	})
+	g.Go(func() (err error) {
+		policies, err = i.bigipClient.GetWAFPolicies()
+		if unprovisioned(err) {
+			noWAF = true
+			return nil
+		}
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	vs := &table{kind: "virtual-servers", header: []string{"Name", "Partition", "Full Path", "Destination", "Protocol", "Pool", "Enabled", "Availability",
+		"iRules", "Policies", "Persistence", "Source Address Translation", "Description"}}
+	for _, v := range virtuals {
+		var persistence []string
+		for _, p := range v.PersistenceProfiles {
+			persistence = append(persistence, p.Name)
+		}
+		vs.rows = append(vs.rows, []string{v.Name, partitionOf(v.Partition, v.FullPath), v.FullPath, v.Destination, v.IPProtocol, v.Pool,
+			strconv.FormatBool(v.Enabled && !v.Disabled), vsStats[v.FullPath].Availability, strings.Join(v.Rules, " "), strings.Join(v.Policies, " "),
+			strings.Join(persistence, " "), v.SourceAddressTranslation.Type, v.Description})
+	}
+
+	poolTable := &table{kind: "pools", header: []string{"Name", "Partition", "Full Path", "Load Balancing Mode", "Monitor", "Minimum Active Members",
+		"Members", "Availability", "Description"}}
+	members := &table{kind: "pool-members", header: []string{"Pool", "Member", "Full Path", "Address", "State", "Session", "Ratio", "Priority Group",
+		"Connection Limit", "Monitor"}}
+	for _, p := range pools {
+		poolMembers, err := i.membersOf(p, listed)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get the members of %s: %w", p.FullPath, err)
+		}
+		poolTable.rows = append(poolTable.rows, []string{p.Name, partitionOf(p.Partition, p.FullPath), p.FullPath, p.LoadBalancingMode,
+			strings.TrimSpace(p.Monitor), strconv.Itoa(p.MinActiveMembers), strconv.Itoa(len(poolMembers)), poolStats[p.FullPath].Availability, p.Description})
+		for _, m := range poolMembers {
+			members.rows = append(members.rows, []string{p.FullPath, m.Name, m.FullPath, m.Address, m.State, m.Session, strconv.Itoa(m.Ratio),
+				strconv.Itoa(m.PriorityGroup), strconv.Itoa(m.ConnectionLimit), m.Monitor})
+		}
+	}
+
+	nodeTable := &table{kind: "nodes", header: []string{"Name", "Partition", "Full Path", "Address", "FQDN", "Monitor", "State", "Session",
+		"Connection Limit", "Description"}}
+	for _, n := range nodes {
+		nodeTable.rows = append(nodeTable.rows, []string{n.Name, partitionOf(n.Partition, n.FullPath), n.FullPath, n.Address, n.FQDN.Name,
+			n.Monitor, n.State, n.Session, strconv.Itoa(n.ConnectionLimit), n.Description})
+	}
+
+	now := time.Now()
+	certTable := &table{kind: "certificates", header: []string{"Name", "Partition", "Full Path", "Subject", "Issuer", "Subject Alternative Names",
+		"Key Type", "Key Size", "Expires", "Days Left", "Serial Number"}}
+	for _, c := range certs {
+		expires, daysLeft := "", ""
+		if at := c.Expires(); !at.IsZero() {
+			expires = at.UTC().Format(time.RFC3339)
+			daysLeft = strconv.Itoa(int(at.Sub(now).Hours() / 24))
+		}
+		certTable.rows = append(certTable.rows, []string{c.Name, partitionOf(c.Partition, c.FullPath), c.FullPath, c.Subject, c.Issuer,
+			c.SubjectAlternativeName, c.KeyType, strconv.Itoa(c.CertificateKeySize), expires, daysLeft, c.SerialNumber})
+	}
+
+	sheets := []sheet{{"Virtual Servers", vs}, {"Pools", poolTable}, {"Pool Members", members}, {"Nodes", nodeTable}, {"Certificates", certTable}}
+	var notes []string
+	if noWAF {
+		notes = append(notes, "ASM isn't provisioned on this device, so there are no WAF policies in the inventory.")
+	} else {
+		_, header, rows := tableRows(policies)
+		sheets = append(sheets, sheet{"WAF Policies", &table{kind: "waf-policies", header: header, rows: rows}})
+	}
+	return sheets, notes, nil
+}
+
+// hostname is the device's hostname, or "bigip" if it can't be read
+func (i *Interface) hostname() string {
+	if devices, err := i.bigipClient.GetDevices(); err == nil && len(devices) > 0 && devices[0].Self {
+		return devices[0].Hostname
+	}
+	return "bigip"
+}
@@ -0,0 +1,163 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"f5chat/journal"
+	"f5chat/llm"
+	"f5chat/prompt"
+	"f5chat/utils"
+)
+
+// pendingIRule is a generated iRule waiting for the user to confirm the upload
+type pendingIRule struct {
+	name        string
+	definition  string
+	requirement string
+}
+
+// defaultIRuleName is used when the model doesn't suggest a usable name
+const defaultIRuleName = "chatf5_irule"
+
+// objectName matches names BIG-IP accepts for new objects
+var objectName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]{0,62}$`)
+
+// tclBlock matches the fenced TCL block in a generated answer
+var tclBlock = regexp.MustCompile("(?s)```(?:tcl|TCL|irule)?[ \\t]*\\n(.*?)```")
+
+// generateIRule asks the LLM for an iRule meeting the requirement, shows
+// it with its explanation and offers to upload it
+func (i *Interface) generateIRule(call *llm.ToolCall) (string, error) {
+	requirement := call.Arg("requirement")
+	if requirement == "" {
+		return "What should the iRule do? For example: 'write an iRule that redirects /old-path to /new-path'.", nil
+	}
+	answer, err := i.llmClient.RunTask(prompt.IRules, requirement)
+	if errors.Is(err, llm.ErrNoLLM) {
+		return "Writing iRules needs an LLM; it isn't available with -no-llm.", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate the iRule: %w", err)
+	}
+
+	m := tclBlock.FindStringSubmatchIndex(answer)
+	if m == nil {
+		// Nothing to upload; show whatever the model said
+		return answer, nil
+	}
+	definition := strings.TrimSpace(answer[m[2]:m[3]])
+	explanation := strings.TrimSpace(answer[:m[0]] + answer[m[1]:])
+
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	if !objectName.MatchString(name) {
+		name = defaultIRuleName
+	}
+
+	i.mu.Lock()
+	i.pending = &pendingIRule{name: name, definition: definition, requirement: requirement}
+	i.pendingAS3, i.pendingList = nil, nil
+	i.mu.Unlock()
+	slog.Info("Generated iRule", "rule", name, "bytes", len(definition))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "=== Generated iRule: %s ===\n%s\n", name, definition)
+	if explanation != "" {
+		fmt.Fprintf(&sb, "\n%s\n", explanation)
+	}
+	if existing, err := i.bigipClient.GetIRule(name); err == nil {
+		// Preview what would change against the rule already there
+		diff := utils.FormatUnified(existing.FullPath, "generated", strings.Split(strings.TrimSpace(existing.Rule), "\n"), strings.Split(definition, "\n"), 3)
+		if diff == "" {
+			fmt.Fprintf(&sb, "\n%s already exists with this same definition.\n", existing.FullPath)
+		} else {
+			fmt.Fprintf(&sb, "\n%s already exists, so uploading needs another name. The generated rule differs from it:\n%s", existing.FullPath, diff)
+		}
+	}
+	fmt.Fprintf(&sb, "\nReply 'upload' to create it on the BIG-IP as /Common/%s (it won't be attached to any virtual server), "+
+		"or 'upload as <name>' to pick another name. Anything else discards it.", name)
+	return sb.String(), nil
+}
+
+// takePendingIRule returns and clears the iRule awaiting confirmation
+func (i *Interface) takePendingIRule() *pendingIRule {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	p := i.pending
+	i.pending = nil
+	return p
+}
+
+// uploadAs matches the confirmation replies: "upload", "yes", "upload as <name>"
+var uploadAs = regexp.MustCompile(`(?i)^\s*(?:yes|y|confirm|upload(?: it)?)(?:\s+as\s+(\S+))?\s*[.!]?\s*$`)
+
+// confirmIRule handles the reply to a generated iRule. It reports false
+// when the reply isn't a confirmation, in which case the iRule is dropped
+// and the reply is processed as a new query.
+func (i *Interface) confirmIRule(p *pendingIRule, reply string) (string, bool) {
+	m := uploadAs.FindStringSubmatch(reply)
+	if m == nil {
+		slog.Debug("Discarding generated iRule", "rule", p.name)
+		return "", false
+	}
+	if m[1] != "" {
+		if !objectName.MatchString(m[1]) {
+			i.mu.Lock()
+			i.pending = p
+			i.mu.Unlock()
+			return fmt.Sprintf("'%s' isn't a valid iRule name: use letters, digits, '_', '.' or '-', starting with a letter or '_'.", m[1]), true
+		}
+		p.name = m[1]
+	}
+
+	call := &llm.ToolCall{Name: llm.ToolUploadIRule, Args: map[string]string{"name": p.name}}
+	request := fmt.Sprintf("Upload a new, unattached iRule named %s that does the following: %s", p.name, p.requirement)
+	if message, ok := i.guard(request, call); !ok {
+		return message, true
+	}
+	change := journal.Entry{Operation: llm.ToolUploadIRule, Object: "/Common/" + p.name, Request: p.requirement, After: p.definition, Result: journal.Applied}
+	if err := i.bigipClient.CreateIRule(p.name, p.definition); err != nil {
+		slog.Error("Failed to upload iRule", "rule", p.name, "err", err)
+		change.Result, change.Error = journal.Failed, err.Error()
+		i.journalChange(change)
+		// Keep it so the user can retry under another name
+		i.mu.Lock()
+		i.pending = p
+		i.mu.Unlock()
+		return fmt.Sprintf("The iRule wasn't uploaded: %v\nReply 'upload as <name>' to try again.", err), true
+	}
+	slog.Info("Uploaded iRule", "rule", p.name)
+	i.journalChange(change)
+	i.setLastCall(call)
+	response := fmt.Sprintf("Uploaded iRule /Common/%s. It isn't attached to any virtual server yet; add it to a virtual server's iRules to put it in service.", p.name)
+	i.remember(reply, response)
+	return response, true
+}
+
+// explainIRule fetches an iRule from the device and has the LLM explain it.
+// Without an LLM the source is shown on its own.
+func (i *Interface) explainIRule(call *llm.ToolCall) (string, error) {
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	if name == "" {
+		return "Which iRule should I explain? Please include its name, e.g. 'explain iRule maintenance_page'.", nil
+	}
+	rule, err := i.bigipClient.GetIRule(name)
+	if err != nil {
+		return "", err
+	}
+	i.setData(rule)
+
+	source := fmt.Sprintf("=== iRule: %s ===\n%s\n", rule.FullPath, strings.TrimSpace(rule.Rule))
+	explanation, err := i.llmClient.RunTask(prompt.ExplainIRule, fmt.Sprintf("iRule %s:\n```tcl\n%s\n```", rule.FullPath, rule.Rule))
+	if errors.Is(err, llm.ErrNoLLM) {
+		return source + "\n(Explanations need an LLM; run without -no-llm to get one.)", nil
+	}
+	if err != nil {
+		slog.Warn("Failed to explain iRule", "rule", rule.FullPath, "err", err)
+		return source + fmt.Sprintf("\nI couldn't get an explanation right now (%v).", err), nil
+	}
+	return source + "\n" + strings.TrimSpace(explanation), nil
+}
@@ -0,0 +1,116 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"f5chat/journal"
+	"f5chat/llm"
+)
+
+// maxJournalShown is how many of the latest changes are listed; the rest
+// are counted and can be exported
+const maxJournalShown = 50
+
+// SetJournal records the changes made to devices, with their configuration
+// before and after, in j
+func (i *Interface) SetJournal(j *journal.Journal) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.journal = j
+}
+
+// journalChange records a change made, or tried, on the session's device,
+// named by the host the client signs in to, and marks those applied on
+// Grafana's graphs
+func (i *Interface) journalChange(e journal.Entry) {
+	i.mu.Lock()
+	j, device := i.journal, i.notifierDevice
+	i.mu.Unlock()
+	if account, ok := i.bigipClient.(accountHolder); ok {
+		device, _, _ = account.Account()
+	}
+	if e.Device == "" {
+		e.Device = device
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	i.annotateChange(e, e.Device)
+	if j == nil {
+		return
+	}
+	if err := j.Record(e); err != nil {
+		slog.Error("Failed to write the change journal", "operation", e.Operation, "object", e.Object, "err", err)
+	}
+}
+
+// changeJournal lists the changes chatf5 made, across sessions, since the
+// time the user gave and by the user they named
+func (i *Interface) changeJournal(call *llm.ToolCall) (string, error) {
+	i.mu.Lock()
+	j := i.journal
+	i.mu.Unlock()
+	if j == nil {
+		return "No change journal is kept; set CHANGE_JOURNAL to record the changes made with chatf5.", nil
+	}
+
+	var filter journal.Filter
+	filter.User = strings.Trim(call.Arg("user"), "\"'`")
+	if phrase := strings.TrimSpace(call.Arg("since")); phrase != "" {
+		since := llm.ParseSince(phrase, time.Now())
+		if since.Time.IsZero() {
+			return fmt.Sprintf("I couldn't tell when '%s' is; try 'yesterday', 'last week' or '3 days ago'.", phrase), nil
+		}
+		filter.Since = since.Time
+	}
+	entries, err := j.Entries(filter)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the change journal: %w", err)
+	}
+
+	scope := ""
+	if filter.User != "" {
+		scope += " by " + filter.User
+	}
+	if !filter.Since.IsZero() {
+		scope += " since " + filter.Since.Local().Format("Mon 2 Jan 15:04")
+	}
+	if len(entries) == 0 {
+		return "chatf5 hasn't changed anything" + scope + ".", nil
+	}
+	i.setData(entries)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n=== Changes made with chatf5%s ===\n", scope)
+	sb.WriteString("----------------------------------------\n")
+	shown := entries
+	if len(shown) > maxJournalShown {
+		shown = shown[len(shown)-maxJournalShown:]
+		fmt.Fprintf(&sb, "(the latest %d of %d)\n", maxJournalShown, len(entries))
+	}
+	failed := 0
+	for _, e := range entries {
+		if e.Result == journal.Failed {
+			failed++
+		}
+	}
+	for _, e := range shown {
+		fmt.Fprintf(&sb, "%s  %-8s %-12s %-14s %s  %s\n", e.Time.Local().Format("Mon 2 Jan 15:04"), e.Result, e.User, e.Operation, e.Object, e.Device)
+		switch {
+		case e.Error != "":
+			fmt.Fprintf(&sb, "%17s Error: %s\n", "", e.Error)
+		case e.Before != "":
+			fmt.Fprintf(&sb, "%17s Replaced the existing configuration\n", "")
+		}
+		if e.Request != "" {
+			fmt.Fprintf(&sb, "%17s Asked for: %s\n", "", e.Request)
+		}
+	}
+	sb.WriteString("----------------------------------------\n")
+	fmt.Fprintf(&sb, "%d %s: %d applied, %d failed. 'export this as CSV' writes them with the configuration before and after.\n",
+		len(entries), plural("change", len(entries)), len(entries)-failed, failed)
+	return sb.String(), nil
+}
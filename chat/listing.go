@@ -0,0 +1,312 @@
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// listSort is the order a listing is shown in
+type listSort struct {
+	key  string
+	desc bool
+}
+
+// parseListSort reads a listing tool's ordering; it is nil when there is
+// none. Names and addresses default to A to Z, counts to the most first.
+func parseListSort(call *llm.ToolCall) (*listSort, error) {
+	key := strings.ToLower(call.Arg(llm.SortBy))
+	if key == "" {
+		return nil, nil
+	}
+	if keys := llm.SortKeys(call.Name); !slices.Contains(keys, key) {
+		return nil, fmt.Errorf("can't sort by '%s' here (use %s)", key, strings.Join(keys, ", "))
+	}
+	s := &listSort{key: key, desc: key == llm.SortConnections || key == llm.SortMembers}
+	switch strings.ToLower(call.Arg(llm.SortOrder)) {
+	case "":
+	case "asc":
+		s.desc = false
+	case "desc":
+		s.desc = true
+	default:
+		return nil, fmt.Errorf("unknown sort order '%s' (use asc or desc)", call.Arg(llm.SortOrder))
+	}
+	return s, nil
+}
+
+// counted reports whether the listing is sorted by a count, whose values
+// are shown in the sort note
+func (s *listSort) counted() bool {
+	return s.key == llm.SortConnections || s.key == llm.SortMembers
+}
+
+// note describes the order, with each object's count when sorted by one
+func (s *listSort) note(names []string, counts map[string]int64) string {
+	label := map[string]string{
+		llm.SortName:        "name",
+		llm.SortAddress:     "address",
+		llm.SortConnections: "current connections",
+		llm.SortMembers:     "number of members",
+	}[s.key]
+	if !s.counted() {
+		direction := "A to Z"
+		if s.desc {
+			direction = "Z to A"
+		}
+		return fmt.Sprintf("\nSorted by %s, %s.\n", label, direction)
+	}
+	direction := "fewest first"
+	if s.desc {
+		direction = "most first"
+	}
+	ranked := make([]string, len(names))
+	for n, name := range names {
+		ranked[n] = fmt.Sprintf("%s (%d)", name, counts[name])
+	}
+	return fmt.Sprintf("\nSorted by %s, %s: %s.\n", label, direction, strings.Join(ranked, ", "))
+}
+
+// connections reads the current connection counts of a kind of object
+// ("virtual", "pool" or "node") by full path, for sorting by connections
+func (i *Interface) connections(kind string) (map[string]int64, error) {
+	stats, err := i.bigipClient.GetStats(kind)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read connection counts: %w", err)
+	}
+	counts := make(map[string]int64, len(stats))
+	for path, s := range stats {
+		counts[path] = s.CurrentConnections
+	}
+	return counts, nil
+}
+
+// listVirtualServers lists the virtual servers, filtered and sorted or
+// counted as the call asks
+func (i *Interface) listVirtualServers(call *llm.ToolCall) (string, error) {
+	f, err := parseListFilter(call)
+	if err != nil {
+		return "", err
+	}
+	s, err := parseListSort(call)
+	if err != nil {
+		return "", err
+	}
+	c, err := parseListCount(call)
+	if err != nil {
+		return "", err
+	}
+	if f == nil && s == nil && c == nil {
+		if response, ok, err := i.streamListing(call, utils.TemplateVirtualServers); ok {
+			return response, err
+		}
+	}
+	vs, err := i.bigipClient.GetVirtualServers()
+	if err != nil {
+		return "", err
+	}
+
+	kept, notes := vs, ""
+	if f != nil {
+		if kept, err = filterVirtualServers(vs, f); err != nil {
+			return "", err
+		}
+		if len(kept) == 0 && c == nil {
+			return filtered("virtual servers", f, 0, len(vs)), nil
+		}
+		notes += filtered("virtual servers", f, len(kept), len(vs))
+	}
+	i.setData(kept)
+	if c != nil {
+		return countList(c, "virtual server", f, kept, len(vs), virtualServerGroup), nil
+	}
+	if s != nil {
+		var counts map[string]int64
+		if s.key == llm.SortConnections {
+			if counts, err = i.connections("virtual"); err != nil {
+				return "", err
+			}
+		}
+		kept = utils.Sorted(kept, s.desc, func(v bigip.VirtualServer) utils.SortKey {
+			switch s.key {
+			case llm.SortAddress:
+				return utils.AddressKey(v.Destination)
+			case llm.SortConnections:
+				return utils.NumberKey(counts[v.FullPath])
+			}
+			return utils.TextKey(v.Name)
+		})
+		names := make([]string, len(kept))
+		for n, v := range kept {
+			names[n] = v.FullPath
+		}
+		notes += s.note(names, counts)
+	}
+	i.setData(kept)
+	out, err := i.render(utils.TemplateVirtualServers, kept, func() string {
+		if i.detailed(call) {
+			return utils.FormatVirtualServers(kept)
+		}
+		return utils.FormatVirtualServersCompact(kept)
+	})
+	if err != nil {
+		return "", err
+	}
+	return out + notes, nil
+}
+
+// membersOf returns a pool's members from those GetPools listed with it, or
+// asks for them when the listing couldn't read them, so the error says why
+func (i *Interface) membersOf(p bigip.Pool, members map[string][]bigip.PoolMember) ([]bigip.PoolMember, error) {
+	if m, ok := members[p.FullPath]; ok {
+		return m, nil
+	}
+	return i.bigipClient.GetPoolMembers(p.FullPath)
+}
+
+// listPools lists the pools, filtered and sorted or counted as the call asks
+func (i *Interface) listPools(call *llm.ToolCall) (string, error) {
+	f, err := parseListFilter(call)
+	if err != nil {
+		return "", err
+	}
+	s, err := parseListSort(call)
+	if err != nil {
+		return "", err
+	}
+	c, err := parseListCount(call)
+	if err != nil {
+		return "", err
+	}
+	if f == nil && s == nil && c == nil {
+		if response, ok, err := i.streamListing(call, utils.TemplatePools); ok {
+			return response, err
+		}
+	}
+	pools, poolMembers, err := i.bigipClient.GetPools()
+	if err != nil {
+		return "", err
+	}
+
+	kept, notes := pools, ""
+	if f != nil {
+		kept = filterPools(pools, poolMembers, f)
+		if len(kept) == 0 && c == nil {
+			return filtered("pools", f, 0, len(pools)), nil
+		}
+		notes += filtered("pools", f, len(kept), len(pools))
+	}
+	i.setData(poolsData(kept, poolMembers))
+	if c != nil {
+		return countList(c, "pool", f, kept, len(pools), poolGroup), nil
+	}
+	if s != nil {
+		counts := make(map[string]int64, len(kept))
+		if s.key == llm.SortConnections {
+			if counts, err = i.connections("pool"); err != nil {
+				return "", err
+			}
+		} else {
+			for _, p := range kept {
+				counts[p.FullPath] = int64(len(poolMembers[p.FullPath]))
+			}
+		}
+		kept = utils.Sorted(kept, s.desc, func(p bigip.Pool) utils.SortKey {
+			if s.counted() {
+				return utils.NumberKey(counts[p.FullPath])
+			}
+			return utils.TextKey(p.Name)
+		})
+		names := make([]string, len(kept))
+		for n, p := range kept {
+			names[n] = p.FullPath
+		}
+		notes += s.note(names, counts)
+	}
+	i.setData(poolsData(kept, poolMembers))
+	out, err := i.render(utils.TemplatePools, utils.PoolsWithMembers(kept, poolMembers), func() string {
+		if i.detailed(call) {
+			return utils.FormatPools(kept, poolMembers)
+		}
+		return utils.FormatPoolsCompact(kept, poolMembers)
+	})
+	if err != nil {
+		return "", err
+	}
+	return out + notes, nil
+}
+
+// listNodes lists the nodes, filtered and sorted or counted as the call asks
+func (i *Interface) listNodes(call *llm.ToolCall) (string, error) {
+	f, err := parseListFilter(call)
+	if err != nil {
+		return "", err
+	}
+	s, err := parseListSort(call)
+	if err != nil {
+		return "", err
+	}
+	c, err := parseListCount(call)
+	if err != nil {
+		return "", err
+	}
+	if f == nil && s == nil && c == nil {
+		if response, ok, err := i.streamListing(call, utils.TemplateNodes); ok {
+			return response, err
+		}
+	}
+	nodes, err := i.bigipClient.GetNodes()
+	if err != nil {
+		return "", err
+	}
+
+	kept, notes := nodes, ""
+	if f != nil {
+		kept = filterNodes(nodes, f)
+		if len(kept) == 0 && c == nil {
+			return filtered("nodes", f, 0, len(nodes)), nil
+		}
+		notes += filtered("nodes", f, len(kept), len(nodes))
+	}
+	i.setData(kept)
+	if c != nil {
+		return countList(c, "node", f, kept, len(nodes), nodeGroup), nil
+	}
+	if s != nil {
+		var counts map[string]int64
+		if s.key == llm.SortConnections {
+			if counts, err = i.connections("node"); err != nil {
+				return "", err
+			}
+		}
+		kept = utils.Sorted(kept, s.desc, func(n bigip.Node) utils.SortKey {
+			switch s.key {
+			case llm.SortAddress:
+				return utils.AddressKey(n.Address)
+			case llm.SortConnections:
+				return utils.NumberKey(counts[n.FullPath])
+			}
+			return utils.TextKey(n.Name)
+		})
+		names := make([]string, len(kept))
+		for n, node := range kept {
+			names[n] = node.FullPath
+		}
+		notes += s.note(names, counts)
+	}
+	i.setData(kept)
+	out, err := i.render(utils.TemplateNodes, kept, func() string {
+		if i.detailed(call) {
+			return utils.FormatNodes(kept)
+		}
+		return utils.FormatNodesCompact(kept)
+	})
+	if err != nil {
+		return "", err
+	}
+	return out + notes, nil
+}
@@ -0,0 +1,177 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+
+	"f5chat/llm"
+)
+
+var (
+	// mdHeading and mdSubheading are the titles text answers use:
+	// "=== Server Pools ===", "--- 1. show nodes ---"
+	mdHeading    = regexp.MustCompile(`^=== (.+?) ===$`)
+	mdSubheading = regexp.MustCompile(`^--- (.+?) ---$`)
+	// mdRule is a separator line of dashes or equals signs
+	mdRule = regexp.MustCompile(`^(?:-{5,}|={5,})$`)
+	// mdItem numbers an object in a listing: "[1] Node Details:"
+	mdItem = regexp.MustCompile(`^\[(\d+)\]\s+(.+?):?$`)
+	// mdField is a field of an object: "Load Balancing Mode: round-robin"
+	mdField = regexp.MustCompile(`^([A-Z][\w ()/-]{0,30}):\s+(\S.*)$`)
+	// mdNote is a line of a listing kept under its tables: how many the
+	// filter kept
+	mdNote = regexp.MustCompile(`^(?:Showing \d+ of |None of the )`)
+)
+
+// tableListings are the operations whose objects are shown as tables
+var tableListings = map[string]bool{
+	llm.ToolListVirtualServers: true,
+	llm.ToolListPools:          true,
+	llm.ToolListNodes:          true,
+	llm.ToolListWAFPolicies:    true,
+}
+
+// followUpsHeading starts the suggestions formatFollowUps appends
+const followUpsHeading = "You could also ask:"
+
+// tableTitles head each kind of table in Markdown
+var tableTitles = map[string]string{
+	"virtual-servers": "Virtual Servers",
+	"pools":           "Pools",
+	"nodes":           "Nodes",
+	"waf-policies":    "WAF Policies",
+}
+
+// formatMarkdown writes an answer as Markdown for wikis and tickets.
+// Listings become a table per kind of object, keeping how many the filter
+// kept and the follow-up suggestions; other answers, including an object's
+// details, keep their text with headings, fields and separators written the
+// Markdown way.
+func formatMarkdown(response string, ops []operation) string {
+	var listings []operation
+	for _, op := range ops {
+		if tableListings[op.Name] {
+			listings = append(listings, op)
+		}
+	}
+	var ts []*table
+	for _, t := range tables(listings) {
+		if len(t.rows) > 0 {
+			ts = append(ts, t)
+		}
+	}
+	if len(ts) == 0 {
+		return markdownText(response)
+	}
+
+	var parts []string
+	for _, t := range ts {
+		parts = append(parts, "## "+tableTitles[t.kind]+"\n\n"+markdownTable(t))
+	}
+	body, followUps, _ := strings.Cut(response, followUpsHeading)
+	var notes []string
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(line); mdNote.MatchString(line) {
+			notes = append(notes, line)
+		}
+	}
+	if len(notes) > 0 {
+		parts = append(parts, strings.Join(notes, "  \n"))
+	}
+	if followUps != "" {
+		parts = append(parts, followUpsHeading+"\n"+strings.TrimLeft(followUps, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// markdownTable writes a table with a header row; pipes and line breaks in
+// values are escaped so each object stays on one row
+func markdownTable(t *table) string {
+	cell := strings.NewReplacer("|", `\|`, "\r", "", "\n", " ")
+	row := func(values []string) string {
+		cells := make([]string, len(values))
+		for n, v := range values {
+			cells[n] = cell.Replace(v)
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	lines := []string{row(t.header), "|" + strings.Repeat(" --- |", len(t.header))}
+	for _, r := range t.rows {
+		lines = append(lines, row(r))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownText writes a text answer's headings, numbered objects and fields
+// the Markdown way and drops its separator lines. Indented lines, such as
+// commands, become code blocks unless they continue a field, and lines of
+// prose keep their line breaks. Code blocks are left as they are.
+func markdownText(text string) string {
+	var out []string
+	fenced, indented := false, false
+	last := func() string {
+		if len(out) == 0 {
+			return ""
+		}
+		return out[len(out)-1]
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			fenced = !fenced
+			out = append(out, line)
+			continue
+		}
+		if fenced {
+			out = append(out, line)
+			continue
+		}
+
+		isIndented := trimmed != "" && strings.HasPrefix(line, "  ")
+		if isIndented && !indented && strings.HasPrefix(last(), "- **") {
+			out[len(out)-1] += " " + trimmed
+			continue
+		}
+		if isIndented != indented {
+			if isIndented && last() != "" {
+				out = append(out, "")
+			}
+			out = append(out, "```")
+			if !isIndented && trimmed != "" {
+				out = append(out, "")
+			}
+			indented = isIndented
+		}
+		if isIndented {
+			out = append(out, line)
+			continue
+		}
+
+		if last() != "" && (mdHeading.MatchString(trimmed) || mdSubheading.MatchString(trimmed) || mdItem.MatchString(trimmed)) {
+			out = append(out, "")
+		}
+		if m := mdHeading.FindStringSubmatch(trimmed); m != nil {
+			out = append(out, "## "+m[1], "")
+		} else if m := mdSubheading.FindStringSubmatch(trimmed); m != nil {
+			out = append(out, "### "+m[1], "")
+		} else if m := mdItem.FindStringSubmatch(trimmed); m != nil {
+			out = append(out, "**"+m[1]+". "+m[2]+"**", "")
+		} else if m := mdField.FindStringSubmatch(line); m != nil {
+			out = append(out, "- **"+m[1]+":** "+m[2])
+		} else if trimmed == "" || mdRule.MatchString(trimmed) {
+			if last() != "" {
+				out = append(out, "")
+			}
+		} else {
+			if prev := last(); prev != "" && prev != "```" && !strings.HasPrefix(prev, "- ") && !strings.HasPrefix(prev, "#") && !strings.HasPrefix(trimmed, "- ") {
+				// Keep the break between lines of prose
+				out[len(out)-1] += "  "
+			}
+			out = append(out, line)
+		}
+	}
+	if indented {
+		out = append(out, "```")
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,141 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+// answerMultiple answers a request for several kinds of object, such as
+// "show virtual servers and their pools and any WAF policies attached",
+// with one section per clause. When virtual servers are listed with their
+// pools or WAF policies, a summary ties each virtual server to them.
+func (i *Interface) answerMultiple(query string, clauses []llm.Clause) (string, error) {
+	calls := make([]*llm.ToolCall, len(clauses))
+	for n, c := range clauses {
+		fillListArgs(c.Text, c.Call)
+		i.scopeToPartition(c.Text, c.Call)
+		calls[n] = c.Call
+	}
+
+	i.mu.Lock()
+	dryRun, preview := i.dryRun, i.planPreview
+	i.mu.Unlock()
+	if dryRun {
+		var sb strings.Builder
+		for _, call := range calls {
+			sb.WriteString(formatPlan(call))
+		}
+		return sb.String() + "\nNothing was run; ask again without /plan to run it.", nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "This answers %d requests: %s.\n", len(clauses), joinClauses(clauses))
+	answered := 0
+	for n, c := range clauses {
+		fmt.Fprintf(&sb, "\n--- %d. %s ---\n", n+1, c.Text)
+		if preview {
+			sb.WriteString(formatPlan(c.Call) + "\n")
+		}
+		response, err := i.executeTool(c.Call)
+		if err != nil {
+			// The other sections are still worth showing
+			fmt.Fprintf(&sb, "Couldn't get this: %v\n", err)
+			continue
+		}
+		answered++
+		sb.WriteString(strings.Trim(response, "\n") + "\n")
+	}
+	if answered == 0 {
+		return "", fmt.Errorf("none of the %d requests could be answered; see above", len(clauses))
+	}
+	sb.WriteString(i.relateVirtualServers(calls))
+
+	response := strings.TrimRight(sb.String(), "\n")
+	i.setLastCall(calls...)
+	for _, c := range clauses {
+		i.noteEntities(c.Text, c.Call)
+	}
+	i.remember(query, response)
+	return response, nil
+}
+
+// joinClauses lists the clauses for the opening line: "a, b and c"
+func joinClauses(clauses []llm.Clause) string {
+	texts := make([]string, len(clauses))
+	for n, c := range clauses {
+		texts[n] = c.Text
+	}
+	if len(texts) == 1 {
+		return texts[0]
+	}
+	return strings.Join(texts[:len(texts)-1], ", ") + " and " + texts[len(texts)-1]
+}
+
+// relateVirtualServers shows each virtual server with its pool and the WAF
+// policies protecting it, when the request asked for virtual servers and
+// either of those. The listings were just read, so this comes from the
+// cache.
+func (i *Interface) relateVirtualServers(calls []*llm.ToolCall) string {
+	asked := make(map[string]bool)
+	for _, call := range calls {
+		asked[call.Name] = true
+	}
+	withPools := asked[llm.ToolListPools] || asked[llm.ToolGetPool]
+	withWAF := asked[llm.ToolListWAFPolicies] || asked[llm.ToolGetWAFPolicy]
+	if !asked[llm.ToolListVirtualServers] || !withPools && !withWAF {
+		return ""
+	}
+	vs, err := i.bigipClient.GetVirtualServers()
+	if err != nil || len(vs) == 0 {
+		return ""
+	}
+	var members map[string][]bigip.PoolMember
+	if withPools {
+		if _, m, err := i.bigipClient.GetPools(); err == nil {
+			members = m
+		}
+	}
+	protectedBy := make(map[string][]string)
+	if withWAF {
+		if policies, err := i.bigipClient.GetWAFPolicies(); err == nil {
+			for _, p := range policies {
+				for _, v := range p.VirtualServers {
+					protectedBy[v] = append(protectedBy[v], p.Name)
+				}
+			}
+		}
+	}
+
+	width := 0
+	for _, v := range vs {
+		width = max(width, len(v.FullPath))
+	}
+	var sb strings.Builder
+	sb.WriteString("\n--- How they fit together ---\n")
+	for _, v := range vs {
+		var parts []string
+		if withPools {
+			switch {
+			case v.Pool == "":
+				parts = append(parts, "no default pool")
+			case members != nil:
+				n := len(members[v.Pool])
+				parts = append(parts, fmt.Sprintf("pool %s (%d %s)", v.Pool, n, plural("member", n)))
+			default:
+				parts = append(parts, "pool "+v.Pool)
+			}
+		}
+		if withWAF {
+			if names := protectedBy[v.FullPath]; len(names) > 0 {
+				parts = append(parts, "WAF policy "+strings.Join(names, ", "))
+			} else {
+				parts = append(parts, "no WAF policy")
+			}
+		}
+		fmt.Fprintf(&sb, "%-*s  -> %s\n", width, v.FullPath, strings.Join(parts, ", "))
+	}
+	return sb.String()
+}
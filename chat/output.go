@@ -0,0 +1,191 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// Output formats for answers (see SetOutputFormat)
+const (
+	FormatText = "text"
+	// FormatJSON answers with a JSON object holding the objects each
+	// operation read as well as the prose, for piping into jq
+	FormatJSON = "json"
+	// FormatCSV answers listings with CSV, one table per kind of object,
+	// for spreadsheets; other answers stay text
+	FormatCSV = "csv"
+	// FormatMarkdown answers with headings and tables, for pasting into
+	// wikis, tickets and pull requests
+	FormatMarkdown = "markdown"
+	// FormatYAML is FormatJSON's answer written as YAML, for turning what
+	// was read into declarative config
+	FormatYAML = "yaml"
+)
+
+// OutputFormats lists the formats SetOutputFormat accepts
+var OutputFormats = []string{FormatText, FormatJSON, FormatCSV, FormatMarkdown, FormatYAML}
+
+// operation is an operation run for an answer and the objects it read
+type operation struct {
+	Name   string            `json:"operation"`
+	Args   map[string]string `json:"args,omitempty"`
+	Device string            `json:"device,omitempty"`
+	Data   interface{}       `json:"data,omitempty"`
+}
+
+// jsonAnswer is an answer in the JSON output format
+type jsonAnswer struct {
+	Query      string      `json:"query"`
+	Operations []operation `json:"operations"`
+	Text       string      `json:"text,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// SetOutputFormat chooses how answers are written: FormatText,
+// FormatJSON, FormatCSV, FormatMarkdown ("md" for short) or FormatYAML
+// ("yml")
+func (i *Interface) SetOutputFormat(format string) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case "md":
+		format = FormatMarkdown
+	case "yml":
+		format = FormatYAML
+	}
+	for _, f := range OutputFormats {
+		if f == format {
+			i.mu.Lock()
+			defer i.mu.Unlock()
+			i.format = format
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format '%s' (use %s)", format, strings.Join(OutputFormats[:len(OutputFormats)-1], ", ")+" or "+OutputFormats[len(OutputFormats)-1])
+}
+
+// OutputFormat is the format answers are written in
+func (i *Interface) OutputFormat() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.format
+}
+
+// formatCommand handles "/format" and "/format NAME"
+func (i *Interface) formatCommand(query string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "/format")
+	if !ok || rest != "" && rest[0] != ' ' {
+		return "", false
+	}
+	if rest = strings.TrimSpace(rest); rest == "" {
+		return fmt.Sprintf("Answers are written as %s. Use /format %s to change it.", i.OutputFormat(), strings.Join(OutputFormats, " or /format ")), true
+	}
+	if err := i.SetOutputFormat(rest); err != nil {
+		return err.Error() + ".", true
+	}
+	switch i.OutputFormat() {
+	case FormatJSON:
+		return "Answers are now JSON objects with the objects each operation read under \"operations\" and the usual answer under \"text\".", true
+	case FormatCSV:
+		return "Listings are now written as CSV, with a header row and a blank line between kinds of object; other answers stay text.", true
+	case FormatYAML:
+		return "Answers are now YAML documents with the objects each operation read under operations and the usual answer under text.", true
+	case FormatMarkdown:
+		return "Answers are now written as Markdown, with listings as tables, ready to paste into a wiki, ticket or pull request.", true
+	}
+	return "Answers are now written as text.", true
+}
+
+// noteOperation records that an operation is being run for the current
+// answer; setData adds the objects it read
+func (i *Interface) noteOperation(call *llm.ToolCall) {
+	args := make(map[string]string, len(call.Args))
+	for k, v := range call.Args {
+		args[k] = v
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.operations = append(i.operations, operation{Name: call.Name, Args: args})
+}
+
+// setData records the objects the operation being run read
+func (i *Interface) setData(data interface{}) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if n := len(i.operations); n > 0 {
+		i.operations[n-1].Data = data
+	}
+}
+
+// takeOperations returns the operations run so far and forgets them
+func (i *Interface) takeOperations() []operation {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ops := i.operations
+	i.operations = nil
+	return ops
+}
+
+// addOperations records operations run elsewhere, such as on other devices
+func (i *Interface) addOperations(ops []operation) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.operations = append(i.operations, ops...)
+}
+
+// formatJSON writes an answer, or the error in its place, as JSON
+func formatJSON(query, response string, err error, ops []operation) string {
+	data, _ := json.MarshalIndent(structuredAnswer(query, response, err, ops), "", "  ")
+	return string(data)
+}
+
+// formatYAML writes an answer, or the error in its place, as a YAML
+// document holding what formatJSON does
+func formatYAML(query, response string, err error, ops []operation) string {
+	data, _ := utils.YAML(structuredAnswer(query, response, err, ops))
+	return "---\n" + strings.TrimRight(data, "\n")
+}
+
+// structuredAnswer is an answer for the JSON and YAML formats
+func structuredAnswer(query, response string, err error, ops []operation) jsonAnswer {
+	answer := jsonAnswer{Query: query, Operations: ops, Text: response}
+	if answer.Operations == nil {
+		answer.Operations = []operation{}
+	}
+	if err != nil {
+		answer.Error = err.Error()
+	}
+	if _, jsonErr := json.Marshal(answer); jsonErr != nil {
+		// Device data that can't be written is left out rather than the answer
+		answer.Operations = []operation{}
+		answer.Error = strings.TrimPrefix(answer.Error+"; ", "; ") + "couldn't write the device data: " + jsonErr.Error()
+	}
+	return answer
+}
+
+// poolData is a pool's JSON form with its members added; go-bigip marshals
+// pools itself, so they can't be added by embedding
+func poolData(p bigip.Pool, members []string) map[string]interface{} {
+	out := make(map[string]interface{})
+	if data, err := json.Marshal(p); err == nil {
+		json.Unmarshal(data, &out)
+	}
+	if members == nil {
+		members = []string{}
+	}
+	out["members"] = members
+	return out
+}
+
+// poolsData is poolData for each of a listing's pools
+func poolsData(pools []bigip.Pool, members map[string][]bigip.PoolMember) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(pools))
+	for n, p := range pools {
+		out[n] = poolData(p, bigip.MemberPaths(members[p.FullPath]))
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+// owaspCompliance reports how well the WAF policies, or the one the query
+// names, cover the OWASP Top 10, as the OWASP Compliance Dashboard scores
+// them
+func (i *Interface) owaspCompliance(call *llm.ToolCall) (string, error) {
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	var policies []*bigip.WAFPolicy
+	if name != "" {
+		policy, err := i.bigipClient.GetWAFPolicyDetails(name)
+		var ambiguous *bigip.AmbiguousNameError
+		if errors.As(err, &ambiguous) {
+			return i.askChoice(call.Name, "WAF policies", name, ambiguous.Matches), nil
+		}
+		if errors.As(err, new(*bigip.ObjectNotFoundError)) {
+			if matches := i.wafPolicyMatches(name); len(matches) > 1 {
+				return i.askChoice(call.Name, "WAF policies", name, matches), nil
+			} else if len(matches) == 1 {
+				policy, err = i.bigipClient.GetWAFPolicyDetails(matches[0])
+			}
+		}
+		if unprovisioned(err) {
+			return "ASM isn't provisioned on this device, so there are no WAF policies to score against the OWASP Top 10.", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		policies = []*bigip.WAFPolicy{policy}
+	} else {
+		all, err := i.bigipClient.GetWAFPolicies()
+		if unprovisioned(err) {
+			return "ASM isn't provisioned on this device, so there are no WAF policies to score against the OWASP Top 10.", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if len(all) == 0 {
+			return "There are no WAF policies on this device to score against the OWASP Top 10.", nil
+		}
+		policies = all
+	}
+
+	var scores []*bigip.OWASPCompliance
+	for _, p := range policies {
+		c, err := i.bigipClient.GetOWASPCompliance(p)
+		if unprovisioned(err) {
+			// The policy is there, so it's the dashboard that isn't
+			return "This BIG-IP version doesn't report OWASP Top 10 compliance over iControl REST, so I can't score its WAF policies. " +
+				"Versions with the OWASP Compliance Dashboard do.", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		scores = append(scores, c)
+	}
+	i.setData(scores)
+	return formatOWASPCompliance(scores), nil
+}
+
+// formatOWASPCompliance lays out each policy's score and where it stands on
+// each of the ten risks
+func formatOWASPCompliance(scores []*bigip.OWASPCompliance) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== OWASP Top 10 Compliance ===\n")
+	for _, c := range scores {
+		compliant, controls := 0, 0
+		for _, cat := range c.Categories {
+			compliant += cat.Compliant
+			controls += cat.Controls
+		}
+		sb.WriteString("----------------------------------------\n")
+		sb.WriteString(fmt.Sprintf("%s  %d%% compliant (%d of %d security controls)\n", c.Policy, c.Score, compliant, controls))
+		for _, cat := range c.Categories {
+			badge := "[WARN]"
+			switch cat.Status {
+			case "compliant":
+				badge = "[PASS]"
+			case "not-compliant":
+				badge = "[FAIL]"
+			}
+			sb.WriteString(fmt.Sprintf("  %-7s %-9s %-43s %d/%d\n", badge, cat.ID, cat.Name, cat.Compliant, cat.Controls))
+		}
+	}
+	return sb.String()
+}
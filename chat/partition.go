@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"f5chat/llm"
+)
+
+// SetPartition scopes queries that don't name a partition to this one; ""
+// asks about every partition
+func (i *Interface) SetPartition(partition string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.partition = partition
+}
+
+// partitionCommand handles "/partition", "/partition NAME" and
+// "/partition off"
+func (i *Interface) partitionCommand(query string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "/partition")
+	if !ok || rest != "" && rest[0] != ' ' {
+		return "", false
+	}
+	name := strings.Trim(strings.TrimSpace(rest), "/")
+	switch strings.ToLower(name) {
+	case "":
+		i.mu.Lock()
+		current := i.partition
+		i.mu.Unlock()
+		if current == "" {
+			return "Queries cover every partition. Use /partition NAME to look at one, e.g. /partition Common.", true
+		}
+		return fmt.Sprintf("Queries are scoped to partition %s. Add \"in Common\" (or another partition) to a query to look elsewhere, or use /partition off.", current), true
+	case "off", "all", "none":
+		i.SetPartition("")
+		return "Queries cover every partition again.", true
+	}
+
+	exists, err := i.bigipClient.TenantExists(name)
+	if err != nil {
+		return fmt.Sprintf("Couldn't check partition %s on the BIG-IP, so queries aren't scoped to it: %v", name, err), true
+	}
+	if !exists {
+		return fmt.Sprintf("There's no partition %s on the BIG-IP. Partition names are case-sensitive.", name), true
+	}
+	i.SetPartition(name)
+	return fmt.Sprintf("Queries are now scoped to partition %s. Add \"in Common\" (or another partition) to a query to look elsewhere, or use /partition off.", name), true
+}
+
+// scopeToPartition limits an operation to the partition the query names,
+// or else the session's. Listings keep the objects in it, and names looked
+// up on their own are taken to be in it.
+func (i *Interface) scopeToPartition(query string, call *llm.ToolCall) {
+	partition := llm.ParsePartition(query)
+	if partition == "" {
+		i.mu.Lock()
+		partition = i.partition
+		i.mu.Unlock()
+	}
+	if partition == "" {
+		return
+	}
+	if call.Args == nil {
+		call.Args = map[string]string{}
+	}
+	switch call.Name {
+	case llm.ToolListVirtualServers, llm.ToolListPools, llm.ToolListNodes:
+		if call.Arg(llm.FilterPartition) == "" {
+			call.Args[llm.FilterPartition] = partition
+		}
+	case llm.ToolGetPool, llm.ToolGetWAFPolicy, llm.ToolExplainIRule:
+		// A full path already says where the object is
+		if name := strings.Trim(call.Arg("name"), "\"'`"); name != "" && !strings.Contains(name, "/") {
+			call.Args["name"] = "/" + partition + "/" + name
+		}
+	}
+}
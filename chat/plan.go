@@ -0,0 +1,151 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"f5chat/llm"
+)
+
+// planStep is one iControl REST call an operation is about to make, and why
+type planStep struct {
+	call string
+	why  string
+}
+
+// SetPlanPreview turns on showing, ahead of each answer, the REST calls
+// made for it and why
+func (i *Interface) SetPlanPreview(on bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.planPreview = on
+}
+
+// planCommand handles "/plan", "/plan on|off" and "/plan QUERY", which
+// shows the plan for a query without running it
+func (i *Interface) planCommand(query string) (string, bool, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "/plan")
+	if !ok || rest != "" && rest[0] != ' ' {
+		return "", false, nil
+	}
+	switch rest = strings.TrimSpace(rest); strings.ToLower(rest) {
+	case "on":
+		i.SetPlanPreview(true)
+		return "Plan preview is on: each answer starts with the REST calls made for it.", true, nil
+	case "off":
+		i.SetPlanPreview(false)
+		return "Plan preview is off.", true, nil
+	case "":
+		i.mu.Lock()
+		on := i.planPreview
+		i.mu.Unlock()
+		state := "off"
+		if on {
+			state = "on"
+		}
+		return fmt.Sprintf("Plan preview is %s. Use /plan on or /plan off, or /plan followed by a query to see its plan without running it.", state), true, nil
+	}
+
+	i.mu.Lock()
+	i.dryRun = true
+	i.mu.Unlock()
+	defer func() {
+		i.mu.Lock()
+		i.dryRun = false
+		i.mu.Unlock()
+	}()
+	response, err := i.process(rest)
+	return response, true, err
+}
+
+// planFor lists the REST calls an operation makes. Reads may be answered
+// from the response cache instead.
+func planFor(call *llm.ToolCall) []planStep {
+	_, rest := tmshFor(call)
+	// Sorting by connections reads the listing's statistics too
+	if call.Arg(llm.SortBy) == llm.SortConnections && len(rest) > 0 {
+		switch call.Name {
+		case llm.ToolListVirtualServers, llm.ToolListPools, llm.ToolListNodes:
+			listing, _, _ := strings.Cut(rest[0], "?")
+			rest = append(rest, listing+"/stats")
+		}
+	}
+	steps := make([]planStep, len(rest))
+	for n, r := range rest {
+		steps[n] = planStep{call: r, why: restReason(r)}
+	}
+	return steps
+}
+
+// restReason says what a REST call is for
+func restReason(call string) string {
+	method, path, _ := strings.Cut(call, " ")
+	switch {
+	case method == "POST" && strings.HasPrefix(path, "/mgmt/shared/appsvcs/declare"):
+		return "deploy the declaration"
+	case strings.HasPrefix(path, "/mgmt/shared/appsvcs/task"):
+		return "wait for the deployment's result"
+	case method == "POST" && strings.HasPrefix(path, "/mgmt/tm/ltm/rule"):
+		return "create the iRule"
+	case strings.HasPrefix(path, "/mgmt/tm/sys/log"):
+		return "recent LTM log lines"
+	case strings.HasSuffix(path, "/stats"):
+		return "availability and connection counts"
+	case strings.HasSuffix(path, "/members"):
+		return "pool members and their state"
+	case strings.HasPrefix(path, "/mgmt/tm/asm/policies?"):
+		return "find the policy's ID from its name"
+	case strings.HasPrefix(path, "/mgmt/tm/asm/policies"):
+		return "WAF policies and the virtual servers they protect"
+	case strings.HasPrefix(path, "/mgmt/tm/ltm/virtual"):
+		return "virtual server configuration"
+	case strings.HasPrefix(path, "/mgmt/tm/ltm/pool"):
+		return "pool configuration"
+	case strings.HasPrefix(path, "/mgmt/tm/ltm/node"):
+		return "node addresses and state"
+	case strings.HasPrefix(path, "/mgmt/tm/ltm/rule"):
+		return "the iRule's definition"
+	case strings.HasPrefix(path, "/mgmt/tm/ltm/profile"):
+		return "profile settings"
+	}
+	return ""
+}
+
+// formatPlan renders the plan for an operation
+func formatPlan(call *llm.ToolCall) string {
+	var sb strings.Builder
+	sb.WriteString("Plan: " + call.Name)
+	if len(call.Args) > 0 {
+		keys := make([]string, 0, len(call.Args))
+		for k := range call.Args {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		args := make([]string, len(keys))
+		for n, k := range keys {
+			args[n] = k + "=" + call.Args[k]
+		}
+		sb.WriteString(" (" + strings.Join(args, ", ") + ")")
+	}
+	sb.WriteString("\n")
+
+	steps := planFor(call)
+	if len(steps) == 0 {
+		switch call.Name {
+		case llm.ToolGenerateIRule, llm.ToolGenerateAS3:
+			sb.WriteString("  No request to the device: the LLM writes it, and nothing is applied until you confirm.\n")
+		default:
+			sb.WriteString("  No request to the device.\n")
+		}
+		return sb.String()
+	}
+	width := 0
+	for _, s := range steps {
+		width = max(width, len(s.call))
+	}
+	for _, s := range steps {
+		sb.WriteString(fmt.Sprintf("  %-*s  %s\n", width, s.call, s.why))
+	}
+	return sb.String()
+}
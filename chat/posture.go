@@ -0,0 +1,359 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// Severities of security posture findings, most urgent first
+const (
+	postureHigh   = "HIGH"
+	postureMedium = "MEDIUM"
+	postureLow    = "LOW"
+)
+
+// weakCiphers are cipher string keywords that allow ciphers no client SSL
+// profile should offer, unless excluded with "!"
+var weakCiphers = []string{"RC4", "DES", "3DES", "EXPORT", "NULL", "MD5", "ADH", "AECDH"}
+
+// postureFinding is a gap in the device's protection, shared by the objects
+// it names
+type postureFinding struct {
+	severity string
+	area     string
+	objects  []string
+	problem  string
+	fix      string
+}
+
+// posture is what the security posture report found: a summary of each
+// area and the findings across them
+type posture struct {
+	areas    [][2]string
+	findings []*postureFinding
+}
+
+func (p *posture) summarize(area, summary string) {
+	p.areas = append(p.areas, [2]string{area, summary})
+}
+
+// add records a finding, merging it into an earlier one with the same
+// problem so a gap on many virtual servers is reported once
+func (p *posture) add(severity, area, object, problem, fix string) {
+	for _, f := range p.findings {
+		if f.severity == severity && f.area == area && f.problem == problem {
+			f.objects = append(f.objects, object)
+			return
+		}
+	}
+	p.findings = append(p.findings, &postureFinding{severity: severity, area: area, objects: []string{object}, problem: problem, fix: fix})
+}
+
+// unprovisioned reports whether err means the device has no such objects,
+// because the module that serves them isn't provisioned
+func unprovisioned(err error) bool {
+	var notFound *bigip.NotFoundError
+	var notProvisioned *bigip.ModuleNotProvisionedError
+	return errors.As(err, &notFound) || errors.As(err, &notProvisioned)
+}
+
+// securityPosture checks the enabled virtual servers for the gaps attackers
+// look for: no WAF policy, or one that only watches; client SSL profiles
+// that allow TLS 1.0 or 1.1 or weak ciphers; plain HTTP that doesn't
+// redirect to HTTPS; and no DoS or bot protection. The findings are listed
+// most urgent first.
+func (i *Interface) securityPosture(call *llm.ToolCall) (string, error) {
+	vs, err := i.bigipClient.GetVirtualServers()
+	if err != nil {
+		return "", err
+	}
+	var enabled []bigip.VirtualServer
+	for _, v := range vs {
+		if v.Enabled {
+			enabled = append(enabled, v)
+		}
+	}
+	if len(enabled) == 0 {
+		return "There are no enabled virtual servers, so nothing is exposed to assess.", nil
+	}
+
+	// The profiles attached to each virtual server, by type. Types the
+	// device can't list are left out, and the areas they cover skipped.
+	fullPaths := func(profiles []bigip.Profile) map[string]bool {
+		set := make(map[string]bool, len(profiles))
+		for _, p := range profiles {
+			set[p.FullPath()] = true
+		}
+		return set
+	}
+	sslProfiles, sslErr := i.bigipClient.GetProfiles("client-ssl")
+	clientSSL := fullPaths(sslProfiles)
+	httpProfiles, httpErr := i.bigipClient.GetProfiles("http")
+	http := fullPaths(httpProfiles)
+	dosProfiles, dosErr := i.bigipClient.GetSecurityProfiles("dos")
+	dos := fullPaths(dosProfiles)
+	botProfiles, botErr := i.bigipClient.GetSecurityProfiles("bot-defense")
+	bot := fullPaths(botProfiles)
+	for _, err := range []error{sslErr, httpErr, dosErr, botErr} {
+		if err != nil && !unprovisioned(err) {
+			return "", err
+		}
+	}
+
+	type exposure struct {
+		v                 bigip.VirtualServer
+		known             bool
+		http, tls         bool
+		dos, bot          bool
+		tlsProfiles       []string
+		redirectsToSecure bool
+	}
+	var exposures []exposure
+	for _, v := range enabled {
+		e := exposure{v: v}
+		attached, err := i.bigipClient.GetVirtualProfiles(v.FullPath)
+		if err != nil {
+			slog.Warn("Assessing a virtual server without its profiles", "virtual", v.FullPath, "err", err)
+		} else {
+			e.known = true
+		}
+		for _, p := range attached {
+			switch {
+			case clientSSL[p.FullPath] && p.Context != "serverside":
+				e.tls = true
+				e.tlsProfiles = append(e.tlsProfiles, p.FullPath)
+			case http[p.FullPath]:
+				e.http = true
+			case dos[p.FullPath]:
+				e.dos = true
+			case bot[p.FullPath]:
+				e.bot = true
+			}
+		}
+		port := utils.ParsePort(v.Destination)
+		if !e.known {
+			// Without the profiles, the well-known ports are the best guess
+			e.http, e.tls = port == "80" || port == "443", port == "443"
+		}
+		for _, rule := range v.Rules {
+			if strings.Contains(strings.ToLower(rule), "redirect") {
+				e.redirectsToSecure = true
+			}
+		}
+		exposures = append(exposures, e)
+	}
+
+	// Web virtual servers serve an application; one on plain HTTP that
+	// only redirects to HTTPS doesn't
+	var report posture
+	var web, plainHTTP []exposure
+	for _, e := range exposures {
+		switch {
+		case e.http && !e.tls && e.redirectsToSecure:
+		case e.http && !e.tls:
+			plainHTTP = append(plainHTTP, e)
+			web = append(web, e)
+		case e.http || e.tls:
+			web = append(web, e)
+		}
+	}
+
+	// WAF coverage of the virtual servers serving HTTP
+	policies, err := i.bigipClient.GetWAFPolicies()
+	switch {
+	case unprovisioned(err):
+		report.summarize("WAF coverage", "ASM isn't provisioned")
+		if len(web) > 0 {
+			report.add(postureHigh, "WAF", "the device", fmt.Sprintf("ASM isn't provisioned, so none of the %d web virtual servers has WAF protection", len(web)),
+				"Provision ASM (Advanced WAF) and apply a security policy to each web application.")
+		}
+	case err != nil:
+		return "", err
+	default:
+		protectedBy := map[string]*bigip.WAFPolicy{}
+		for _, p := range policies {
+			for _, v := range p.VirtualServers {
+				if protectedBy[v] == nil || p.EnforcementMode == "blocking" {
+					protectedBy[v] = p
+				}
+			}
+		}
+		blocking := 0
+		for _, e := range web {
+			switch p := protectedBy[e.v.FullPath]; {
+			case p == nil:
+				report.add(postureHigh, "WAF", e.v.FullPath, "no WAF policy protects it",
+					"Apply a WAF (ASM) security policy to the virtual server, and move it to blocking once tuned.")
+			case p.EnforcementMode != "blocking":
+				report.add(postureMedium, "WAF", e.v.FullPath+" ("+p.FullPath+")", "its WAF policy is in transparent mode, so attacks are logged but not blocked",
+					"Review the policy's learning suggestions and switch it to blocking.")
+			default:
+				blocking++
+			}
+		}
+		report.summarize("WAF coverage", fmt.Sprintf("%d of %d web virtual servers protected in blocking mode", blocking, len(web)))
+	}
+
+	// TLS quality of the client SSL profiles in use
+	if sslErr != nil {
+		report.summarize("TLS", "client SSL profiles couldn't be read")
+	} else {
+		used := map[string][]string{}
+		var order []string
+		for _, e := range exposures {
+			for _, p := range e.tlsProfiles {
+				if used[p] == nil {
+					order = append(order, p)
+				}
+				used[p] = append(used[p], e.v.FullPath)
+			}
+		}
+		weak := 0
+		for _, path := range order {
+			var profile bigip.Profile
+			for _, p := range sslProfiles {
+				if p.FullPath() == path {
+					profile = p
+				}
+			}
+			options := strings.Fields(profileOptions(profile))
+			object := fmt.Sprintf("%s (on %s)", path, strings.Join(used[path], ", "))
+			found := false
+			switch {
+			case !slices.Contains(options, "no-tlsv1"):
+				report.add(postureHigh, "TLS", object, "allows TLS 1.0",
+					"Add no-tlsv1 and no-tlsv1.1 to the profile's options.")
+				found = true
+			case !slices.Contains(options, "no-tlsv1.1"):
+				report.add(postureMedium, "TLS", object, "allows TLS 1.1",
+					"Add no-tlsv1.1 to the profile's options.")
+				found = true
+			}
+			if ciphers := weakCipherTerms(profileField(profile, "ciphers")); len(ciphers) > 0 {
+				report.add(postureHigh, "TLS", object, "allows weak ciphers ("+strings.Join(ciphers, ", ")+")",
+					"Exclude them from the cipher string with !, or use a cipher group such as f5-secure.")
+				found = true
+			}
+			if found {
+				weak++
+			}
+		}
+		if len(order) == 0 {
+			report.summarize("TLS", "no client SSL profiles in use")
+		} else {
+			report.summarize("TLS", fmt.Sprintf("%d of %d client SSL profiles in use have weaknesses", weak, len(order)))
+		}
+	}
+
+	// Web services reachable without TLS
+	for _, e := range plainHTTP {
+		report.add(postureMedium, "Plain HTTP", e.v.FullPath+" ("+e.v.Destination+")", "serves HTTP without TLS, so requests and any credentials cross the network unencrypted",
+			"Serve it over HTTPS with a client SSL profile, and keep port 80 only to redirect with the _sys_https_redirect iRule.")
+	}
+	report.summarize("Plain HTTP", fmt.Sprintf("%d of %d web virtual servers serve plain HTTP", len(plainHTTP), len(web)))
+
+	// DoS protection on every virtual server, and bot defense on web ones
+	switch {
+	case dosErr != nil:
+		report.summarize("DoS protection", "not provisioned")
+		report.add(postureMedium, "DoS", "the device", "no DoS protection module is provisioned",
+			"Provision AFM or Advanced WAF and attach a DoS profile to the exposed virtual servers.")
+	default:
+		covered := 0
+		for _, e := range exposures {
+			if e.dos {
+				covered++
+			} else if e.known {
+				report.add(postureMedium, "DoS", e.v.FullPath, "has no DoS profile",
+					"Attach a DoS profile, such as the built-in /Common/dos tuned to the application.")
+			}
+		}
+		report.summarize("DoS protection", fmt.Sprintf("%d of %d enabled virtual servers have a DoS profile", covered, len(exposures)))
+	}
+	switch {
+	case botErr != nil:
+		report.summarize("Bot defense", "not provisioned")
+		if len(web) > 0 {
+			report.add(postureLow, "Bots", "the device", "bot defense isn't available, so scrapers and credential stuffing go unchecked",
+				"Provision Advanced WAF and attach a bot defense profile to the web virtual servers.")
+		}
+	default:
+		covered := 0
+		for _, e := range web {
+			if e.bot {
+				covered++
+			} else if e.known {
+				report.add(postureLow, "Bots", e.v.FullPath, "has no bot defense profile",
+					"Attach a bot defense profile to the virtual server.")
+			}
+		}
+		report.summarize("Bot defense", fmt.Sprintf("%d of %d web virtual servers have a bot defense profile", covered, len(web)))
+	}
+
+	return formatPosture(&report, len(exposures), len(vs)-len(exposures)), nil
+}
+
+// weakCipherTerms returns the weak ciphers a cipher string allows, those it
+// names without excluding them
+func weakCipherTerms(ciphers string) []string {
+	var weak []string
+	for _, term := range strings.FieldsFunc(strings.ToUpper(ciphers), func(r rune) bool { return r == ':' || r == ',' || r == ' ' }) {
+		if strings.HasPrefix(term, "!") || strings.HasPrefix(term, "-") {
+			continue
+		}
+		for _, part := range strings.FieldsFunc(term, func(r rune) bool { return r == '+' || r == '-' }) {
+			if slices.Contains(weakCiphers, part) && !slices.Contains(weak, part) {
+				weak = append(weak, part)
+			}
+		}
+	}
+	return weak
+}
+
+// formatPosture lays the report out in the style of the health report: the
+// summary of each area, then the findings, most urgent first
+func formatPosture(p *posture, enabled, disabled int) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== Security Posture ===\n")
+	sb.WriteString("----------------------------------------\n")
+	checked := fmt.Sprintf("%d enabled virtual servers", enabled)
+	switch {
+	case disabled == 1:
+		checked += "; 1 disabled one isn't exposed, so wasn't assessed"
+	case disabled > 1:
+		checked += fmt.Sprintf("; %d disabled ones aren't exposed, so weren't assessed", disabled)
+	}
+	sb.WriteString(fmt.Sprintf("%-16s %s\n", "Assessed", checked))
+	for _, area := range p.areas {
+		sb.WriteString(fmt.Sprintf("%-16s %s\n", area[0], area[1]))
+	}
+	sb.WriteString("----------------------------------------\n")
+
+	if len(p.findings) == 0 {
+		sb.WriteString("No gaps found: every enabled virtual server is protected.\n")
+		return sb.String()
+	}
+	counts := map[string]int{}
+	sb.WriteString("Findings, most urgent first:\n")
+	for _, severity := range []string{postureHigh, postureMedium, postureLow} {
+		for _, f := range p.findings {
+			if f.severity != severity {
+				continue
+			}
+			counts[severity]++
+			sb.WriteString(fmt.Sprintf("%-8s %-10s %s: %s\n", "["+f.severity+"]", f.area, strings.Join(f.objects, ", "), f.problem))
+			sb.WriteString(fmt.Sprintf("%-19s Fix: %s\n", "", f.fix))
+		}
+	}
+	sb.WriteString("----------------------------------------\n")
+	sb.WriteString(fmt.Sprintf("%d findings: %d high, %d medium, %d low. Fix the high ones first.\n",
+		len(p.findings), counts[postureHigh], counts[postureMedium], counts[postureLow]))
+	return sb.String()
+}
@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProfileOpener returns a client of the BIG-IP a profile describes, its
+// host and its default partition
+type ProfileOpener func(name string) (client BigIPClient, host, partition string, err error)
+
+// SetProfiles lets /device switch the session between the named profiles;
+// current is the one in use, "" if none
+func (i *Interface) SetProfiles(names []string, current string, open ProfileOpener) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.profiles, i.profile, i.openProfile = names, current, open
+}
+
+func (i *Interface) profileNames() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.profiles
+}
+
+// deviceCommand handles "/device", which shows the profile in use and the
+// others, and "/device NAME", which switches to another
+func (i *Interface) deviceCommand(query string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "/device")
+	if !ok || rest != "" && rest[0] != ' ' {
+		return "", false
+	}
+	name := strings.TrimSpace(rest)
+	i.mu.Lock()
+	names, current, open := i.profiles, i.profile, i.openProfile
+	i.mu.Unlock()
+	if len(names) == 0 {
+		return "No profiles are configured. Add them under \"profiles:\" in ~/.chatf5/config.yaml, each with its host and credentials, to switch between BIG-IPs with /device NAME.", true
+	}
+	list := strings.Join(names, ", ")
+	if name == "" {
+		if current == "" {
+			return fmt.Sprintf("Not using a profile. Profiles: %s. Use /device NAME to switch.", list), true
+		}
+		return fmt.Sprintf("Using profile %s. Profiles: %s. Use /device NAME to switch.", current, list), true
+	}
+	found := false
+	for _, n := range names {
+		found = found || n == name
+	}
+	if !found {
+		return fmt.Sprintf("There's no profile %s. Profiles: %s.", name, list), true
+	}
+
+	client, host, partition, err := open(name)
+	if err != nil {
+		return fmt.Sprintf("Couldn't switch to profile %s, so queries still go to the same BIG-IP: %v", name, err), true
+	}
+	i.ResetHistory()
+	i.mu.Lock()
+	i.bigipClient = client
+	i.profile = name
+	i.partition = partition
+	i.notifierDevice = host
+	i.pending, i.pendingAS3, i.pendingList = nil, nil, nil
+	i.lastOperations = nil
+	i.mu.Unlock()
+	i.warmSoon()
+
+	response := fmt.Sprintf("Switched to profile %s (%s); the conversation starts afresh.", name, host)
+	if partition != "" {
+		response += fmt.Sprintf(" Queries are scoped to partition %s; use /partition off to look at every partition.", partition)
+	}
+	return response, true
+}
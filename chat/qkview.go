@@ -0,0 +1,175 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"f5chat/ihealth"
+	"f5chat/llm"
+)
+
+// SetIHealth has qkviews uploaded to iHealth through c for analysis
+func (i *Interface) SetIHealth(c *ihealth.Client) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.iHealth = c
+}
+
+// generateQkview has the device collect a qkview, saves it to the file the
+// call names, or to one named after the device and the time, and removes
+// it from the device. With upload set it goes on to iHealth too.
+func (i *Interface) generateQkview(call *llm.ToolCall) (string, error) {
+	file := strings.Trim(call.Arg("file"), "\"'`")
+	if file == "" {
+		file = fmt.Sprintf("%s-%s.qkview", i.hostname(), time.Now().Format("20060102-150405"))
+	}
+	if !strings.HasSuffix(file, ".qkview") {
+		return fmt.Sprintf("iHealth only takes files ending in .qkview; try \"generate a qkview to %s.qkview\".", strings.TrimSuffix(file, ".")), nil
+	}
+	// Claimed first, so minutes of collecting aren't spent on a name in use
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("%s already exists; give another file name, e.g. \"generate a qkview to support-2.qkview\"", file)
+	}
+	if err != nil {
+		return "", fmt.Errorf("couldn't write %s: %w", file, err)
+	}
+
+	name := filepath.Base(file)
+	started := time.Now()
+	id, err := i.bigipClient.CreateQkview(name)
+	var size int64
+	if err == nil {
+		size, err = i.bigipClient.DownloadQkview(name, f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file)
+		return "", err
+	}
+	if err := i.bigipClient.DeleteQkview(id); err != nil {
+		slog.Warn("Leaving the qkview on the device", "name", name, "err", err)
+	}
+	i.mu.Lock()
+	i.lastQkview = file
+	c := i.iHealth
+	i.mu.Unlock()
+
+	saved := fmt.Sprintf("Generated a qkview in %s and saved it to %s (%s).", time.Since(started).Round(time.Second), file, fileSize(size))
+	switch {
+	case call.Arg("upload") != "":
+		report, err := i.sendToIHealth(file, call.Arg("case"))
+		if err != nil {
+			return "", fmt.Errorf("%s %w", saved, err)
+		}
+		return saved + "\n\n" + report, nil
+	case c != nil:
+		return saved + " Ask \"upload the qkview to iHealth\" to have iHealth analyze it.", nil
+	}
+	return saved + " Upload it at https://ihealth2.f5.com for analysis, or set IHEALTH_CLIENT_ID and IHEALTH_CLIENT_SECRET to have chatf5 upload it.", nil
+}
+
+// uploadQkview sends the qkview the call names, or the one generated last,
+// to iHealth
+func (i *Interface) uploadQkview(call *llm.ToolCall) (string, error) {
+	file := strings.Trim(call.Arg("file"), "\"'`")
+	if file == "" {
+		i.mu.Lock()
+		file = i.lastQkview
+		i.mu.Unlock()
+	}
+	if file == "" {
+		return "Which qkview? Generate one first with \"generate a qkview\", or name the file, e.g. \"upload bigip1.qkview to iHealth\".", nil
+	}
+	return i.sendToIHealth(file, call.Arg("case"))
+}
+
+// sendToIHealth uploads a qkview, waits for iHealth's analysis and lists
+// the diagnostics it found, most important first
+func (i *Interface) sendToIHealth(file, supportCase string) (string, error) {
+	i.mu.Lock()
+	c := i.iHealth
+	i.mu.Unlock()
+	if c == nil {
+		return fmt.Sprintf("Uploading to iHealth needs an iHealth API key: set IHEALTH_CLIENT_ID and IHEALTH_CLIENT_SECRET. Meanwhile %s can be uploaded at https://ihealth2.f5.com.", file), nil
+	}
+	if _, err := os.Stat(file); err != nil {
+		return "", fmt.Errorf("couldn't read %s: %w", file, err)
+	}
+
+	ctx := context.Background()
+	slog.Info("Uploading qkview to iHealth", "file", file, "case", supportCase)
+	id, err := c.Upload(ctx, file, supportCase)
+	if err != nil {
+		return "", err
+	}
+	report, err := c.Analyze(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("uploaded %s to iHealth as qkview %s, but %w", file, id, err)
+	}
+	slog.Info("iHealth analyzed qkview", "file", file, "id", id, "diagnostics", len(report.Diagnostics))
+
+	var sb strings.Builder
+	title := report.Hostname
+	if title == "" {
+		title = filepath.Base(file)
+	}
+	fmt.Fprintf(&sb, "=== iHealth: %s (qkview %s) ===\n", title, report.ID)
+	counts := make(map[string]int)
+	var order []string
+	for _, d := range report.Diagnostics {
+		if counts[d.Importance] == 0 {
+			order = append(order, d.Importance)
+		}
+		counts[d.Importance]++
+		fmt.Fprintf(&sb, "%-8s  %s  %s\n", d.Importance, d.Name, d.Header)
+		if d.Summary != "" {
+			fmt.Fprintf(&sb, "          %s\n", d.Summary)
+		}
+		if d.Action != "" {
+			fmt.Fprintf(&sb, "          Action: %s\n", d.Action)
+		}
+		for _, link := range d.Solutions {
+			fmt.Fprintf(&sb, "          %s\n", link)
+		}
+	}
+	if len(report.Diagnostics) == 0 {
+		sb.WriteString("iHealth's heuristics found no known issues.")
+	} else {
+		var parts []string
+		for _, importance := range order {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[importance], strings.ToLower(importance)))
+		}
+		what := strings.Join(parts, ", ")
+		if n := strings.LastIndex(what, ", "); n >= 0 {
+			what = what[:n] + " and " + what[n+2:]
+		}
+		fmt.Fprintf(&sb, "\n%d %s matched: %s.", len(report.Diagnostics), plural("diagnostic", len(report.Diagnostics)), what)
+	}
+	if supportCase != "" {
+		fmt.Fprintf(&sb, " The qkview is attached to support case %s.", supportCase)
+	}
+	fmt.Fprintf(&sb, " The full analysis is at %s", report.URL)
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// fileSize describes a size in bytes the way a file listing would
+func fileSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
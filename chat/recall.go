@@ -0,0 +1,101 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"f5chat/history"
+)
+
+// defaultHistoryListed is how many queries /history shows without a count
+const defaultHistoryListed = 20
+
+var (
+	// historyCommand matches "/history" and "/history 50"
+	historyCommand = regexp.MustCompile(`^/history(?:\s+(\d+|all))?$`)
+	// rerunQuery matches requests to run an earlier query again: "!12",
+	// "!!", "run #12 again", "rerun 12", "repeat the last query"
+	rerunQuery = regexp.MustCompile(`(?i)^(?:!(\d+|!)|(?:re-?run|repeat|run)\s+(?:query\s+)?#(\d+)(?:\s+again)?|(?:re-?run|repeat)\s+(?:query\s+)?(\d+)|run\s+(?:query\s+)?(\d+)\s+again|(?:re-?run|repeat|run)\s+(?:the\s+)?(last)\s+(?:query|one|command)(?:\s+again)?)\s*[.!]?$`)
+)
+
+// SetQueryHistory records the queries asked from now on in h, for /history
+// and "run #12 again"; nil stops recording
+func (i *Interface) SetQueryHistory(h *history.Store) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.queries = h
+}
+
+func (i *Interface) queryHistory() *history.Store {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.queries
+}
+
+// fromHistory handles /history and requests to run an earlier query
+// again. A rerun query is processed, and recorded, as if it had been typed.
+func (i *Interface) fromHistory(query string) (string, bool, error) {
+	query = strings.TrimSpace(query)
+	queries := i.queryHistory()
+	if m := historyCommand.FindStringSubmatch(query); m != nil {
+		return listHistory(queries, m[1]), true, nil
+	}
+	m := rerunQuery.FindStringSubmatch(query)
+	if m == nil {
+		return "", false, nil
+	}
+	if queries == nil {
+		return "Query history is off (QUERY_HISTORY_SIZE=0), so there is nothing to run again.", true, nil
+	}
+
+	number := len(queries.Queries())
+	for _, group := range m[1:] {
+		if n, err := strconv.Atoi(group); err == nil {
+			number = n
+		}
+	}
+	earlier, ok := queries.Get(number)
+	if !ok {
+		if number == 0 {
+			return "There are no earlier queries to run again.", true, nil
+		}
+		return fmt.Sprintf("There is no query #%d; /history lists the earlier ones.", number), true, nil
+	}
+	response, err := i.ProcessQuery(earlier)
+	if err != nil {
+		return "", true, err
+	}
+	return fmt.Sprintf("Running #%d: %s\n\n%s", number, earlier, strings.TrimLeft(response, "\n")), true, nil
+}
+
+// listHistory numbers the latest queries, or as many as count asks for
+func listHistory(queries *history.Store, count string) string {
+	if queries == nil {
+		return "Query history is off; set QUERY_HISTORY_SIZE to keep it."
+	}
+	all := queries.Queries()
+	if len(all) == 0 {
+		return "No queries yet."
+	}
+	shown := defaultHistoryListed
+	if count == "all" {
+		shown = len(all)
+	} else if n, err := strconv.Atoi(count); err == nil && n > 0 {
+		shown = n
+	}
+	first := 0
+	if len(all) > shown {
+		first = len(all) - shown
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Earlier queries:\n")
+	width := len(strconv.Itoa(len(all)))
+	for n := first; n < len(all); n++ {
+		sb.WriteString(fmt.Sprintf("  %*d  %s\n", width, n+1, all[n]))
+	}
+	sb.WriteString(fmt.Sprintf("\nRun one again with \"run #%d again\" or !%d; the up arrow recalls them at the prompt.", len(all), len(all)))
+	return sb.String()
+}
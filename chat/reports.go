@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// inventoryListings are the listings an inventory report is made of
+var inventoryListings = []string{llm.ToolListVirtualServers, llm.ToolListPools, llm.ToolListNodes}
+
+// InventoryReport lists the virtual servers, pools and nodes, as the
+// listings "show virtual servers" and the like give
+func (i *Interface) InventoryReport() (string, error) {
+	var sb strings.Builder
+	for _, tool := range inventoryListings {
+		listing, err := i.executeTool(&llm.ToolCall{Name: tool})
+		i.takeOperations()
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(strings.TrimRight(listing, "\n") + "\n")
+	}
+	return sb.String(), nil
+}
+
+// DownReport lists what isn't serving traffic: enabled virtual servers and
+// pools that are offline, and nodes and pool members that are down
+func (i *Interface) DownReport() (string, error) {
+	var down []utils.DownObject
+
+	// The listings are read at once, so the report waits for the slowest
+	// rather than each in turn
+	var virtuals []bigip.VirtualServer
+	var pools []bigip.Pool
+	var poolMembers map[string][]bigip.PoolMember
+	var nodes []bigip.Node
+	var vsStats, poolStats map[string]bigip.ObjectStats
+	var g errgroup.Group
+	g.Go(func() (err error) {
+		virtuals, err = i.bigipClient.GetVirtualServers()
+		return err
+	})
+	g.Go(func() (err error) {
+		pools, poolMembers, err = i.bigipClient.GetPools()
+		return err
+	})
+	g.Go(func() (err error) {
+		nodes, err = i.bigipClient.GetNodes()
+		return err
+	})
+	g.Go(func() (err error) {
+		vsStats, err = i.bigipClient.GetStats("virtual")
+		return err
+	})
+	g.Go(func() (err error) {
+		poolStats, err = i.bigipClient.GetStats("pool")
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return "", err
+	}
+
+	for _, v := range virtuals {
+		if !v.Disabled && vsStats[v.FullPath].Availability == "offline" {
+			down = append(down, utils.DownObject{Kind: "virtual server", Name: v.FullPath, Status: "offline"})
+		}
+	}
+	for _, p := range pools {
+		if poolStats[p.FullPath].Availability == "offline" {
+			down = append(down, utils.DownObject{Kind: "pool", Name: p.FullPath, Status: "offline"})
+		}
+		members, err := i.membersOf(p, poolMembers)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the members of %s: %w", p.FullPath, err)
+		}
+		for _, m := range members {
+			if m.State == "down" || m.State == "user-down" {
+				down = append(down, utils.DownObject{Kind: "pool member", Name: p.FullPath + " " + m.FullPath, Status: m.State})
+			}
+		}
+	}
+	for _, n := range nodes {
+		if n.State == "down" || n.State == "user-down" {
+			down = append(down, utils.DownObject{Kind: "node", Name: n.FullPath, Status: n.State})
+		}
+	}
+	return utils.FormatDownObjects(down), nil
+}
@@ -0,0 +1,37 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// saveAnswer matches "save that to vips.txt" and "write the last answer
+// to report.md", which write the last answer to a file. "save this as
+// NAME" saves the query instead (see saveThis), and files ending in .csv
+// are exported as CSV (see exportCSV).
+var saveAnswer = regexp.MustCompile(`(?i)^\s*(?:please\s+)?(?:save|write)\s+(?:this|that|it|the\s+(?:last\s+)?(?:answer|output|response|results?))\s+(?:to|in|into)\s+(?:(?:a\s+)?file\s+)?["'` + "`" + `]?([^\s"'` + "`" + `]+)["'` + "`" + `]?[\s.!]*$`)
+
+// saveAnswerCommand handles "save that to FILE"
+func (i *Interface) saveAnswerCommand(query string) (string, bool, error) {
+	m := saveAnswer.FindStringSubmatch(query)
+	if m == nil {
+		return "", false, nil
+	}
+	i.mu.Lock()
+	dryRun, answer := i.dryRun, i.lastAnswer
+	i.mu.Unlock()
+	if dryRun {
+		return "No request to the device: the last answer is written to a file.", true, nil
+	}
+	if answer == "" {
+		return "There's no answer to save yet. Ask something first, e.g. \"show virtual servers\", then \"save that to vips.txt\".", true, nil
+	}
+	if !strings.HasSuffix(answer, "\n") {
+		answer += "\n"
+	}
+	if err := writeNewFile(m[1], answer, "save that to vips-2.txt"); err != nil {
+		return "", true, err
+	}
+	return fmt.Sprintf("Wrote the last answer to %s (%s).", m[1], i.OutputFormat()), true, nil
+}
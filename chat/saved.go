@@ -0,0 +1,171 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"f5chat/history"
+)
+
+var (
+	// saveThis matches "save this as morning-check" and "save the last
+	// query as morning-check", which save the query before it
+	saveThis = regexp.MustCompile(`(?i)^save\s+(?:this|that|it|the\s+last\s+(?:query|one|command))\s+as\s+["'` + "`" + `]?([^\s"'` + "`" + `]+?)["'` + "`" + `]?\s*[.!]?$`)
+	// saveCommand matches "/save NAME" and "/save NAME show pools; which
+	// nodes are down?"
+	saveCommand = regexp.MustCompile(`^/save\s+(\S+)(?:\s+(.+))?$`)
+	// runCommand matches "/run NAME"
+	runCommand = regexp.MustCompile(`^/run\s+(\S+)$`)
+	// unsaveCommand matches "/unsave NAME"
+	unsaveCommand = regexp.MustCompile(`^/unsave\s+(\S+)$`)
+)
+
+// SetSavedQueries keeps the queries saved with "save this as NAME" in s;
+// nil turns saving off
+func (i *Interface) SetSavedQueries(s *history.Saved) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.saved = s
+}
+
+func (i *Interface) savedQueries() *history.Saved {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.saved
+}
+
+// recordQuery notes a query the user asked, for "save this as" and the
+// query history
+func (i *Interface) recordQuery(query string) {
+	i.mu.Lock()
+	i.lastQuery = strings.TrimSpace(query)
+	queries := i.queries
+	i.mu.Unlock()
+	queries.Add(query)
+}
+
+// manageSaved handles saving, listing and deleting named queries. These
+// aren't queries themselves, so they aren't recorded.
+func (i *Interface) manageSaved(query string) (string, bool, error) {
+	query = strings.TrimSpace(query)
+	var name string
+	var queries []string
+	switch {
+	case query == "/saved":
+		return i.listSaved(), true, nil
+	case unsaveCommand.MatchString(query):
+		return i.unsave(unsaveCommand.FindStringSubmatch(query)[1]), true, nil
+	case saveThis.MatchString(query):
+		name = saveThis.FindStringSubmatch(query)[1]
+	case saveCommand.MatchString(query):
+		m := saveCommand.FindStringSubmatch(query)
+		name = m[1]
+		// Several queries separated by semicolons make a runbook
+		for _, q := range strings.Split(m[2], ";") {
+			if q = strings.TrimSpace(q); q != "" {
+				queries = append(queries, q)
+			}
+		}
+	default:
+		return "", false, nil
+	}
+
+	saved := i.savedQueries()
+	if saved == nil {
+		return "Saved queries are unavailable in this session.", true, nil
+	}
+	if len(queries) == 0 {
+		i.mu.Lock()
+		last := i.lastQuery
+		i.mu.Unlock()
+		if last == "" {
+			return "There is no earlier query to save yet; ask it first, or use /save NAME followed by the query.", true, nil
+		}
+		queries = []string{last}
+	}
+	for _, q := range queries {
+		if runCommand.MatchString(q) || strings.HasPrefix(q, "/save") {
+			return fmt.Sprintf("'%s' runs or saves other queries, so it can't be saved itself; save the queries it runs instead.", q), true, nil
+		}
+	}
+	replaced, err := saved.Save(name, queries)
+	if err != nil {
+		return "", true, err
+	}
+	verb := "Saved"
+	if replaced {
+		verb = "Replaced"
+	}
+	return fmt.Sprintf("%s %s: %s\nRun it with /run %s.", verb, name, strings.Join(queries, "; "), name), true, nil
+}
+
+func (i *Interface) listSaved() string {
+	saved := i.savedQueries()
+	if saved == nil {
+		return "Saved queries are unavailable in this session."
+	}
+	names := saved.Names()
+	if len(names) == 0 {
+		return "No saved queries yet. After asking something, type \"save this as NAME\" to keep it."
+	}
+	var sb strings.Builder
+	sb.WriteString("Saved queries:\n")
+	for _, name := range names {
+		queries, _ := saved.Get(name)
+		sb.WriteString(fmt.Sprintf("  %-20s %s\n", name, strings.Join(queries, "; ")))
+	}
+	sb.WriteString("\nRun one with /run NAME; /unsave NAME deletes it.")
+	return sb.String()
+}
+
+func (i *Interface) unsave(name string) string {
+	saved := i.savedQueries()
+	if saved == nil {
+		return "Saved queries are unavailable in this session."
+	}
+	deleted, err := saved.Delete(name)
+	if err != nil {
+		return fmt.Sprintf("Deleted %s, but couldn't update the saved queries file: %v", name, err)
+	}
+	if !deleted {
+		return fmt.Sprintf("There is no saved query named %s.", name)
+	}
+	return fmt.Sprintf("Deleted %s.", name)
+}
+
+// runSaved runs the queries saved under name in order. A query that fails
+// is reported and the rest still run, so one unreachable object doesn't
+// stop a morning check.
+func (i *Interface) runSaved(name string) (string, error) {
+	saved := i.savedQueries()
+	if saved == nil {
+		return "Saved queries are unavailable in this session.", nil
+	}
+	queries, ok := saved.Get(name)
+	if !ok {
+		if names := saved.Names(); len(names) > 0 {
+			return fmt.Sprintf("There is no saved query named %s. Saved: %s.", name, strings.Join(names, ", ")), nil
+		}
+		return fmt.Sprintf("There is no saved query named %s.", name), nil
+	}
+	if len(queries) == 1 {
+		response, err := i.process(queries[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Running %s: %s\n\n%s", name, queries[0], strings.TrimLeft(response, "\n")), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Running %s (%d queries)\n", name, len(queries)))
+	for n, q := range queries {
+		sb.WriteString(fmt.Sprintf("\n=== %d/%d: %s ===\n", n+1, len(queries), q))
+		response, err := i.process(q)
+		if err != nil {
+			response = "Error: " + err.Error()
+		}
+		sb.WriteString(strings.TrimLeft(response, "\n") + "\n")
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,85 @@
+package chat
+
+import (
+	"fmt"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// selfTestQueries are the queries SelfTest asks and the operation each
+// must be answered with
+var selfTestQueries = []struct {
+	name, query, operation string
+}{
+	{"Virtual servers", "show virtual servers", llm.ToolListVirtualServers},
+	{"Pools", "show pools", llm.ToolListPools},
+	{"Nodes", "show nodes", llm.ToolListNodes},
+	{"WAF policies", "list the WAF policies and the virtual servers they are applied to", llm.ToolListWAFPolicies},
+}
+
+// SelfTest asks a fixed set of queries end to end, from understanding the
+// query to formatting the answer, and checks each was answered with the
+// operation expected. The details of the first WAF policy are asked too.
+// It returns the formatted report and whether every query passed; the
+// conversation is forgotten afterwards.
+func (i *Interface) SelfTest() (string, bool) {
+	defer i.ResetHistory()
+
+	var checks []bigip.HealthCheck
+	var policies []*bigip.WAFPolicy
+	for _, q := range selfTestQueries {
+		check, ops := i.selfTestQuery(q.name, q.query, q.operation)
+		checks = append(checks, check)
+		for _, op := range ops {
+			if p, ok := op.Data.([]*bigip.WAFPolicy); ok && op.Name == llm.ToolListWAFPolicies {
+				policies = p
+			}
+		}
+	}
+	if len(policies) > 0 {
+		check, _ := i.selfTestQuery("WAF details", "show policy details "+policies[0].Name, llm.ToolGetWAFPolicy)
+		checks = append(checks, check)
+	} else {
+		checks = append(checks, bigip.HealthCheck{Name: "WAF details", Passed: true, Detail: "Skipped: there are no WAF policies to show"})
+	}
+
+	healthy := true
+	for _, c := range checks {
+		healthy = healthy && c.Passed
+	}
+	return utils.FormatSelfTest(checks), healthy
+}
+
+// selfTestQuery asks one self-test query, returning its check and the
+// operations run for it
+func (i *Interface) selfTestQuery(name, query, operation string) (bigip.HealthCheck, []operation) {
+	check := bigip.HealthCheck{Name: name}
+	i.takeOperations()
+	start := time.Now()
+	_, err := i.answer(query)
+	ops := i.takeOperations()
+	took := time.Since(start).Round(time.Millisecond)
+	switch {
+	case err != nil:
+		check.Detail = fmt.Sprintf("%q failed: %v", query, err)
+	case !ranOperation(ops, operation):
+		check.Detail = fmt.Sprintf("%q wasn't answered with %s", query, operation)
+	default:
+		check.Passed = true
+		check.Detail = fmt.Sprintf("%q answered in %s", query, took)
+	}
+	return check, ops
+}
+
+// ranOperation reports whether name is among ops
+func ranOperation(ops []operation, name string) bool {
+	for _, op := range ops {
+		if op.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/snapshot"
+	"f5chat/utils"
+)
+
+// snapshotKinds are the kinds of object a snapshot keeps, in the order
+// changes are reported
+var snapshotKinds = []string{llm.CompareVirtualServer, llm.ComparePool, llm.CompareWAFPolicy}
+
+// snapshotListings are the listings a snapshot reads, for its plan
+var snapshotListings = []string{llm.ToolListVirtualServers, llm.ToolListPools, llm.ToolListWAFPolicies}
+
+// takeSnapshot matches "take a snapshot" and "snapshot the config as
+// before-upgrade", as well as "/snapshot NAME"
+var takeSnapshot = regexp.MustCompile(`(?i)^\s*(?:please\s+)?(?:(?:take|make|save|create)\s+(?:a\s+|another\s+)?snapshot|snapshot\s+(?:the\s+)?(?:config(?:uration)?|device|big-?ip)|/snapshot)(?:\s+(?:called|named|as))?(?:\s+["'` + "`" + `]?([^\s"'` + "`" + `]+?)["'` + "`" + `]?)?[\s.!]*$`)
+
+// maxChangedFields is how many changed fields are shown per object
+const maxChangedFields = 8
+
+// maxChangeValue caps each value in a changed field
+const maxChangeValue = 60
+
+// SetSnapshots keeps snapshots taken with /snapshot in store, for "what
+// changed since" questions
+func (i *Interface) SetSnapshots(store *snapshot.Store) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.snapshots = store
+}
+
+// snapshotCommand handles "/snapshots", which lists the snapshots, and
+// "/snapshot [NAME]" or "take a snapshot", which takes one
+func (i *Interface) snapshotCommand(query string) (string, bool, error) {
+	i.mu.Lock()
+	store, dryRun := i.snapshots, i.dryRun
+	i.mu.Unlock()
+	if strings.TrimSpace(query) == "/snapshots" {
+		if store == nil {
+			return "Snapshots aren't kept in this session.", true, nil
+		}
+		return listSnapshots(store.List()), true, nil
+	}
+	m := takeSnapshot.FindStringSubmatch(query)
+	if m == nil {
+		return "", false, nil
+	}
+	if store == nil {
+		return "Snapshots aren't kept in this session.", true, nil
+	}
+	name := m[1]
+	if name != "" && !snapshot.ValidName(name) {
+		return fmt.Sprintf("'%s' can't be used as a snapshot name; use letters, digits, dots, dashes and underscores.", name), true, nil
+	}
+	if dryRun {
+		return snapshotPlan() + "\nNothing was run; ask again without /plan to take the snapshot.", true, nil
+	}
+
+	snap, err := i.readSnapshot()
+	if err != nil {
+		return "", true, fmt.Errorf("couldn't take a snapshot: %w", err)
+	}
+	snap.Name = name
+	if err := store.Add(snap); err != nil {
+		return "", true, err
+	}
+	took := "a snapshot"
+	if name != "" {
+		took = "snapshot " + name
+	}
+	response := fmt.Sprintf("Took %s at %s: %s.\nAsk \"what changed since the last snapshot\" (or since this morning, 9am, ...) later to see what's different.",
+		took, snap.Taken.Format("Mon 2 Jan 15:04"), snapshotCounts(snap))
+	i.remember(query, response)
+	return response, true, nil
+}
+
+// readSnapshot reads the object lists afresh from the device. Without ASM
+// there are no WAF policies to keep.
+func (i *Interface) readSnapshot() (snapshot.Snapshot, error) {
+	i.bigipClient.ClearCache()
+	snap := snapshot.Snapshot{Taken: time.Now(), Kinds: make(map[string]snapshot.Objects)}
+	for _, kind := range snapshotKinds {
+		objects, err := i.comparables(kind, "")
+		if kind == llm.CompareWAFPolicy && errors.As(err, new(*bigip.ModuleNotProvisionedError)) {
+			continue
+		}
+		if err != nil {
+			return snapshot.Snapshot{}, fmt.Errorf("failed to read %s: %w", compareKindNames[kind], err)
+		}
+		kept := make(snapshot.Objects, len(objects))
+		for _, o := range objects {
+			fields, err := utils.Flatten(o.value)
+			if err != nil {
+				return snapshot.Snapshot{}, fmt.Errorf("failed to read %s: %w", compareKindNames[kind], err)
+			}
+			kept[o.fullPath] = fields
+		}
+		snap.Kinds[kind] = kept
+	}
+	return snap, nil
+}
+
+// answerChanges compares the device as it is now with a snapshot taken
+// earlier and lists the objects added, removed and modified since
+func (i *Interface) answerChanges(query string, since llm.ChangesSince) (string, error) {
+	i.mu.Lock()
+	store, dryRun := i.snapshots, i.dryRun
+	i.mu.Unlock()
+	if store == nil {
+		return "Snapshots aren't kept in this session, so there's nothing to compare with.", nil
+	}
+	if !since.Understood() {
+		return fmt.Sprintf("I can't tell when '%s' was. Try e.g. \"what changed since this morning\", \"... since 2 hours ago\", \"... since 9am\" or \"... since snapshot before-upgrade\".", since.Phrase), nil
+	}
+
+	var (
+		then snapshot.Snapshot
+		ok   bool
+		note string
+	)
+	switch {
+	case since.Name != "":
+		if then, ok = store.Named(since.Name); !ok {
+			return fmt.Sprintf("There's no snapshot called %s. /snapshots lists the ones taken.", since.Name), nil
+		}
+	case since.Latest:
+		then, ok = store.Latest()
+	default:
+		var after bool
+		then, after, ok = store.Since(since.Time)
+		if ok && !after {
+			note = fmt.Sprintf("No snapshot was taken since %s, so this compares with the last one before then.\n",
+				since.Time.Format("Mon 2 Jan 15:04"))
+		}
+	}
+	if !ok {
+		return "There are no snapshots to compare with yet. Take one with /snapshot (or /snapshot before-upgrade to name it), then ask again once things may have changed.", nil
+	}
+	if dryRun {
+		return snapshotPlan() + fmt.Sprintf("  then compare with %s\n\nNothing was run; ask again without /plan to run it.", then.Label()), nil
+	}
+
+	now, err := i.readSnapshot()
+	if err != nil {
+		return "", fmt.Errorf("couldn't read the device to compare with %s: %w", then.Label(), err)
+	}
+	response := note + formatChanges(then, now)
+	i.remember(query, response)
+	return response, nil
+}
+
+// formatChanges lists what was added, removed and modified between two
+// snapshots, kind by kind
+func formatChanges(then, now snapshot.Snapshot) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Changes since %s:\n", then.Label())
+	added, removed, modified, compared := 0, 0, 0, 0
+	for _, kind := range snapshotKinds {
+		title := capitalize(compareKindNames[kind])
+		before, wasKept := then.Kinds[kind]
+		after, isKept := now.Kinds[kind]
+		if !wasKept || !isKept {
+			when := "then"
+			if wasKept {
+				when = "now"
+			}
+			fmt.Fprintf(&sb, "\n%s: not compared; they couldn't be read %s.\n", title, when)
+			continue
+		}
+
+		paths := make(map[string]bool)
+		for p := range before {
+			paths[p] = true
+		}
+		for p := range after {
+			paths[p] = true
+		}
+		sorted := make([]string, 0, len(paths))
+		for p := range paths {
+			sorted = append(sorted, p)
+		}
+		sort.Strings(sorted)
+
+		var lines []string
+		for _, p := range sorted {
+			old, inBefore := before[p]
+			cur, inAfter := after[p]
+			switch {
+			case !inBefore:
+				added++
+				lines = append(lines, "  + "+p+"  (added)")
+			case !inAfter:
+				removed++
+				lines = append(lines, "  - "+p+"  (removed)")
+			default:
+				diffs, _, err := utils.Diff(old, cur)
+				if err != nil || len(diffs) == 0 {
+					continue
+				}
+				modified++
+				lines = append(lines, "  ~ "+p+"  (modified)")
+				var changes []utils.DiffLine
+				for n, d := range diffs {
+					if n == maxChangedFields {
+						break
+					}
+					changes = append(changes,
+						utils.DiffLine{Op: '-', Text: d.Field + ": " + changeValue(d.Left)},
+						utils.DiffLine{Op: '+', Text: d.Field + ": " + changeValue(d.Right)})
+				}
+				lines = append(lines, strings.Split(strings.TrimSuffix(utils.FormatDiffLines(changes, "      "), "\n"), "\n")...)
+				if len(diffs) > maxChangedFields {
+					lines = append(lines, fmt.Sprintf("      ... and %d more field(s)", len(diffs)-maxChangedFields))
+				}
+			}
+		}
+		compared += len(paths)
+		if len(lines) == 0 {
+			fmt.Fprintf(&sb, "\n%s: no changes (%d compared).\n", title, len(paths))
+			continue
+		}
+		fmt.Fprintf(&sb, "\n%s:\n%s\n", title, strings.Join(lines, "\n"))
+	}
+
+	if added+removed+modified == 0 {
+		fmt.Fprintf(&sb, "\nNothing changed in the %d %s compared.", compared, plural("object", compared))
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "\n%d added, %d removed, %d modified.", added, removed, modified)
+	return sb.String()
+}
+
+// changeValue shows a field's value in a change, shortened if long
+func changeValue(v string) string {
+	if v == "" {
+		return "(not set)"
+	}
+	if len(v) > maxChangeValue {
+		return v[:maxChangeValue-3] + "..."
+	}
+	return v
+}
+
+// snapshotCounts says how many objects of each kind a snapshot has
+func snapshotCounts(snap snapshot.Snapshot) string {
+	var parts []string
+	for _, kind := range snapshotKinds {
+		if objects, ok := snap.Kinds[kind]; ok {
+			name := compareKindName[kind]
+			if len(objects) != 1 {
+				name = compareKindNames[kind]
+			}
+			parts = append(parts, fmt.Sprintf("%d %s", len(objects), name))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// listSnapshots answers /snapshots
+func listSnapshots(snaps []snapshot.Snapshot) string {
+	if len(snaps) == 0 {
+		return "No snapshots yet. Take one with /snapshot, or /snapshot NAME to name it."
+	}
+	var sb strings.Builder
+	sb.WriteString("Snapshots, oldest first:\n")
+	for n, s := range snaps {
+		fmt.Fprintf(&sb, "  %d. %s: %s\n", n+1, s.Label(), snapshotCounts(s))
+	}
+	sb.WriteString("Ask e.g. \"what changed since the last snapshot\" to compare one with the device now.")
+	return sb.String()
+}
+
+// snapshotPlan shows the listings a snapshot reads
+func snapshotPlan() string {
+	var sb strings.Builder
+	for _, tool := range snapshotListings {
+		sb.WriteString(formatPlan(&llm.ToolCall{Name: tool}))
+	}
+	return sb.String()
+}
@@ -0,0 +1,90 @@
+package chat
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/notify"
+)
+
+// splunkGroup is a violation group as forwarded to Splunk
+type splunkGroup struct {
+	Attack     string   `json:"attack"`
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	Status     string   `json:"status"`
+	Requests   int      `json:"requests"`
+	Clients    int      `json:"clients"`
+	TopClient  string   `json:"top_client"`
+	Violations []string `json:"violations,omitempty"`
+	Rating     int      `json:"rating"`
+}
+
+// splunk is the sink SPLUNK_HEC_URL registered with the notifier, nil if
+// there's none, and the device what it's sent is from
+func (i *Interface) splunk() (*notify.SplunkSink, string) {
+	i.mu.Lock()
+	router, device := i.notifier, i.notifierDevice
+	i.mu.Unlock()
+	if router == nil {
+		return nil, ""
+	}
+	sink, _ := router.Sink("splunk").(*notify.SplunkSink)
+	return sink, device
+}
+
+// forwardViolations sends a WAF triage to Splunk, if it's configured: the
+// groups of requests, the clients behind them and the LLM's summary, when
+// there is one. A failure is logged; the triage is shown all the same.
+func (i *Interface) forwardViolations(violations []bigip.Violation, groups []*violationGroup, hours int, policy, summary string) {
+	sink, device := i.splunk()
+	if sink == nil {
+		return
+	}
+	first, last := violations[0].Time, violations[0].Time
+	clients := map[string]bool{}
+	for _, v := range violations {
+		if v.Time.Before(first) {
+			first = v.Time
+		}
+		if v.Time.After(last) {
+			last = v.Time
+		}
+		clients[v.ClientIP] = true
+	}
+	forwarded := make([]splunkGroup, len(groups))
+	for n, g := range groups {
+		top := ""
+		for ip, count := range g.sources {
+			if top == "" || count > g.sources[top] || count == g.sources[top] && ip < top {
+				top = ip
+			}
+		}
+		forwarded[n] = splunkGroup{
+			Attack: g.attack, Method: g.method, Path: g.path, Status: g.status,
+			Requests: g.count, Clients: len(g.sources), TopClient: top,
+			Violations: g.violations, Rating: g.rating,
+		}
+	}
+	event := map[string]interface{}{
+		"requests": len(violations),
+		"clients":  len(clients),
+		"first":    first.UTC().Format(time.RFC3339),
+		"last":     last.UTC().Format(time.RFC3339),
+		"groups":   forwarded,
+	}
+	if hours > 0 {
+		event["hours"] = hours
+	}
+	if policy != "" {
+		event["policy"] = policy
+	}
+	if summary != "" {
+		event["summary"] = summary
+	}
+	if err := sink.Forward(context.Background(), notify.SplunkWAF, device, time.Now(), event); err != nil {
+		slog.Warn("Failed to forward WAF violations to Splunk", "violations", len(violations), "err", err)
+	}
+}
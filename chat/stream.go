@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"slices"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/utils"
+)
+
+// pager is a BigIPClient that can read the long listings a page at a time
+type pager interface {
+	VirtualServerPages(each func(page []bigip.VirtualServer, total int) bool) error
+	PoolPages(each func(page []bigip.Pool, members map[string][]bigip.PoolMember, total int) bool) error
+	NodePages(each func(page []bigip.Node, total int) bool) error
+}
+
+// SetStream shows listings of virtual servers, pools and nodes too long
+// for one page as their pages are read, handing each page to show, rather
+// than all at once when the last page is in. Only text answers to
+// ProcessQuery are streamed, and only plain listings: not ones filtered,
+// sorted, counted, in detail or laid out with a template.
+func (i *Interface) SetStream(show func(string)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stream = show
+}
+
+// openStream lets the answer ProcessQuery is about to give be streamed
+// when the output format is text; closeStream ends that
+func (i *Interface) openStream() {
+	text := i.OutputFormat() == FormatText
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if text {
+		i.streaming = i.stream
+	}
+}
+
+func (i *Interface) closeStream() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.streaming, i.streamCall, i.streamed = nil, nil, false
+}
+
+// markStreamCall marks call as the query's one operation, whose listing
+// may be streamed
+func (i *Interface) markStreamCall(call *llm.ToolCall) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.streamCall = call
+}
+
+// unmarkStreamCall unmarks the operation markStreamCall marked, and reports
+// whether its listing was streamed. A streamed listing isn't kept, so
+// nothing more is read from it.
+func (i *Interface) unmarkStreamCall() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	streamed := i.streamed
+	i.streamCall, i.streamed = nil, false
+	return streamed
+}
+
+// streamListing shows the listing call asks for, unfiltered and unsorted,
+// a page at a time as the pages are read, and answers with how many objects
+// it had. It isn't handled when the listing can't be streamed or fits on
+// one page, which is then left cached for the listing to be shown as usual.
+func (i *Interface) streamListing(call *llm.ToolCall, template string) (string, bool, error) {
+	i.mu.Lock()
+	show, marked, templates := i.streaming, i.streamCall == call, i.templates
+	i.mu.Unlock()
+	if show == nil || !marked || i.detailed(call) || slices.Contains(templates.Names(), template) {
+		return "", false, nil
+	}
+	p, ok := i.bigipClient.(pager)
+	if !ok {
+		return "", false, nil
+	}
+
+	s := utils.NewListingStream(show)
+	whole := false
+	// The first page being the whole listing stops reading, to show it as usual
+	first := func(n, total int) bool {
+		whole = s.Shown() == 0 && n >= total
+		return !whole
+	}
+	var err error
+	switch call.Name {
+	case llm.ToolListVirtualServers:
+		err = p.VirtualServerPages(func(page []bigip.VirtualServer, total int) bool {
+			if !first(len(page), total) {
+				return false
+			}
+			s.VirtualServers(page, total)
+			return true
+		})
+	case llm.ToolListPools:
+		err = p.PoolPages(func(page []bigip.Pool, members map[string][]bigip.PoolMember, total int) bool {
+			if !first(len(page), total) {
+				return false
+			}
+			s.Pools(page, members, total)
+			return true
+		})
+	case llm.ToolListNodes:
+		err = p.NodePages(func(page []bigip.Node, total int) bool {
+			if !first(len(page), total) {
+				return false
+			}
+			s.Nodes(page, total)
+			return true
+		})
+	default:
+		return "", false, nil
+	}
+	if err != nil {
+		return "", true, err
+	}
+	if whole {
+		return "", false, nil
+	}
+	i.mu.Lock()
+	i.streamed = true
+	i.mu.Unlock()
+	return s.Done(), true, nil
+}
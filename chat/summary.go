@@ -0,0 +1,381 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+const (
+	// summaryLogLines is how much of the LTM log the health summary scans
+	summaryLogLines = 200
+	// summaryItems is how many objects each line of the summary names
+	summaryItems = 5
+	// cpuWarn and cpuFail are the CPU usage, in percent, at which the
+	// device is busy enough to look at, and to act on
+	cpuWarn = 75
+	cpuFail = 90
+)
+
+// logSeverities are the syslog levels the summary counts in the LTM log,
+// and the result each gives
+var logSeverities = map[string]string{
+	"emerg": "FAIL", "alert": "FAIL", "crit": "FAIL",
+	"err": "WARN", "warning": "WARN",
+}
+
+// summaryCheck is one line of the health summary: an area of the device,
+// how it is, and the objects behind a warning or failure
+type summaryCheck struct {
+	status string
+	area   string
+	detail string
+	items  []string
+}
+
+// worse returns the more urgent of two results
+func worse(a, b string) string {
+	rank := map[string]int{"OK": 0, "SKIP": 1, "WARN": 2, "FAIL": 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// skipped is the line of an area that couldn't be read
+func skipped(area string, err error) summaryCheck {
+	return summaryCheck{status: "SKIP", area: area, detail: fmt.Sprintf("couldn't be read: %v", err)}
+}
+
+// healthSummary answers "give me a health summary" with the dashboard
+func (i *Interface) healthSummary(call *llm.ToolCall) (string, error) {
+	summary, _ := i.HealthSummary()
+	return summary, nil
+}
+
+// HealthSummary reads the device's state into a one-screen dashboard, the
+// first thing to look at when on call: the device and its CPU, failover
+// and config sync, virtual servers and pool members that are down, errors
+// in the LTM log and expiring certificates. An area that can't be read is
+// skipped rather than failing the summary. The areas are read at once.
+// healthy is false when any area failed.
+func (i *Interface) HealthSummary() (summary string, healthy bool) {
+	now := time.Now()
+	areas := []func() summaryCheck{
+		i.summarizeDevice,
+		i.summarizeHA,
+		i.summarizeVirtualServers,
+		i.summarizePoolMembers,
+		i.summarizeLog,
+		func() summaryCheck { return i.summarizeCertificates(now) },
+	}
+	// An area that can't be read is skipped rather than failing the others,
+	// so nothing fails the group
+	checks := make([]summaryCheck, len(areas))
+	var g errgroup.Group
+	for n, area := range areas {
+		n, area := n, area
+		g.Go(func() error {
+			checks[n] = area()
+			return nil
+		})
+	}
+	g.Wait()
+	failed := slices.ContainsFunc(checks, func(c summaryCheck) bool { return c.status == "FAIL" })
+	return formatSummary(checks, now), !failed
+}
+
+// summarizeDevice names the device, its platform and software, and how
+// busy its CPUs are
+func (i *Interface) summarizeDevice() summaryCheck {
+	check := summaryCheck{status: "OK", area: "Device"}
+	var parts []string
+	if devices, err := i.bigipClient.GetDevices(); err != nil {
+		parts = append(parts, fmt.Sprintf("unknown (%v)", err))
+		check.status = "SKIP"
+	} else {
+		self := devices[0]
+		parts = append(parts, fmt.Sprintf("%s, %s %s build %s", self.Hostname, self.Platform, self.Version, self.Build))
+	}
+	if usage, err := i.bigipClient.GetCPUUsage(); err != nil {
+		parts = append(parts, fmt.Sprintf("CPU unknown (%v)", err))
+	} else {
+		parts = append(parts, fmt.Sprintf("CPU %.0f%%", usage))
+		switch {
+		case usage >= cpuFail:
+			check.status = "FAIL"
+		case usage >= cpuWarn:
+			check.status = worse(check.status, "WARN")
+		}
+	}
+	check.detail = strings.Join(parts, "; ")
+	return check
+}
+
+// summarizeHA reports the device's failover state, its peers' and whether
+// their configuration is in sync
+func (i *Interface) summarizeHA() summaryCheck {
+	check := summaryCheck{status: "OK", area: "High availability"}
+	var parts []string
+	devices, err := i.bigipClient.GetDevices()
+	if err != nil {
+		return skipped(check.area, err)
+	}
+	self := devices[0]
+	if len(devices) == 1 {
+		parts = append(parts, "standalone")
+	} else {
+		parts = append(parts, self.FailoverState)
+	}
+	if self.FailoverState == "offline" || self.FailoverState == "forced-offline" {
+		check.status = "FAIL"
+	}
+	active := self.FailoverState == "active"
+	for _, peer := range devices[1:] {
+		parts = append(parts, fmt.Sprintf("peer %s %s", peer.Hostname, peer.FailoverState))
+		switch peer.FailoverState {
+		case "active":
+			if active {
+				check.status = "FAIL"
+				check.items = append(check.items, "more than one device is active for the same traffic groups")
+			}
+			active = true
+		case "offline", "forced-offline":
+			check.status = worse(check.status, "WARN")
+			check.items = append(check.items, peer.Hostname+" can't take over if this device fails")
+		}
+	}
+
+	sync, err := i.bigipClient.GetSyncStatus()
+	var notFound *bigip.NotFoundError
+	switch {
+	case errors.As(err, &notFound):
+	case err != nil:
+		parts = append(parts, fmt.Sprintf("sync unknown (%v)", err))
+	case sync.Status == "Standalone":
+	case sync.InSync():
+		parts = append(parts, "config "+sync.Status)
+	default:
+		parts = append(parts, "config "+sync.Status)
+		if sync.Color == "red" {
+			check.status = "FAIL"
+		} else {
+			check.status = worse(check.status, "WARN")
+		}
+		if sync.Summary != "" {
+			check.items = append(check.items, sync.Summary)
+		}
+	}
+	check.detail = strings.Join(parts, "; ")
+	return check
+}
+
+// summarizeVirtualServers counts the enabled virtual servers by
+// availability, naming those offline
+func (i *Interface) summarizeVirtualServers() summaryCheck {
+	check := summaryCheck{status: "OK", area: "Virtual servers"}
+	vs, err := i.bigipClient.GetVirtualServers()
+	if err != nil {
+		return skipped(check.area, err)
+	}
+	stats, err := i.bigipClient.GetStats("virtual")
+	if err != nil {
+		return skipped(check.area, err)
+	}
+	enabled, available, unknown := 0, 0, 0
+	for _, v := range vs {
+		if v.Disabled {
+			continue
+		}
+		enabled++
+		switch stats[v.FullPath].Availability {
+		case "available":
+			available++
+		case "offline":
+			check.status = "FAIL"
+			check.items = append(check.items, fmt.Sprintf("%s (%s) offline", v.FullPath, v.Destination))
+		default:
+			unknown++
+		}
+	}
+	check.detail = fmt.Sprintf("%d of %d enabled available", available, enabled)
+	if n := len(check.items); n > 0 {
+		check.detail += fmt.Sprintf(", %d offline", n)
+	}
+	if unknown > 0 {
+		check.detail += fmt.Sprintf(", %d unmonitored", unknown)
+	}
+	if disabled := len(vs) - enabled; disabled > 0 {
+		check.detail += fmt.Sprintf("; %d disabled", disabled)
+	}
+	return check
+}
+
+// summarizePoolMembers counts the pool members that are down, failing when
+// a pool has none left up
+func (i *Interface) summarizePoolMembers() summaryCheck {
+	check := summaryCheck{status: "OK", area: "Pool members"}
+	pools, poolMembers, err := i.bigipClient.GetPools()
+	if err != nil {
+		return skipped(check.area, err)
+	}
+	total, down, disabled := 0, 0, 0
+	for _, p := range pools {
+		members, err := i.membersOf(p, poolMembers)
+		if err != nil {
+			return skipped(check.area, fmt.Errorf("the members of %s: %w", p.FullPath, err))
+		}
+		up := 0
+		for _, m := range members {
+			total++
+			switch m.State {
+			case "up":
+				up++
+			case "user-down":
+				disabled++
+			default:
+				down++
+				check.status = worse(check.status, "WARN")
+				check.items = append(check.items, fmt.Sprintf("%s %s %s", p.FullPath, m.FullPath, m.State))
+			}
+		}
+		if len(members) > 0 && up == 0 {
+			check.status = "FAIL"
+			check.items = append(check.items, p.FullPath+" has no members up")
+		}
+	}
+	check.detail = fmt.Sprintf("%d of %d up in %d %s", total-down-disabled, total, len(pools), plural("pool", len(pools)))
+	if down > 0 {
+		check.detail += fmt.Sprintf(", %d down", down)
+	}
+	if disabled > 0 {
+		check.detail += fmt.Sprintf(", %d disabled", disabled)
+	}
+	return check
+}
+
+// summarizeLog counts the errors and warnings among the latest lines of the
+// LTM log, showing the latest of them
+func (i *Interface) summarizeLog() summaryCheck {
+	check := summaryCheck{status: "OK", area: "LTM log"}
+	lines, err := i.bigipClient.GetLogLines(summaryLogLines)
+	if err != nil {
+		return skipped(check.area, err)
+	}
+	counts := map[string]int{}
+	var flagged []string
+	for _, line := range lines {
+		// "Oct 17 09:20:44 bigip1 err tmm1[11304]: ..."
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		status, ok := logSeverities[fields[4]]
+		if !ok {
+			continue
+		}
+		counts[fields[4]]++
+		check.status = worse(check.status, status)
+		flagged = append(flagged, line)
+	}
+	if len(flagged) == 0 {
+		check.detail = fmt.Sprintf("no errors or warnings in the last %d lines", len(lines))
+		return check
+	}
+	var parts []string
+	for _, level := range []string{"emerg", "alert", "crit", "err", "warning"} {
+		if counts[level] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[level], level))
+		}
+	}
+	check.detail = fmt.Sprintf("%s in the last %d lines", strings.Join(parts, ", "), len(lines))
+	// Latest first
+	for n := len(flagged) - 1; n >= 0; n-- {
+		check.items = append(check.items, flagged[n])
+	}
+	return check
+}
+
+// summarizeCertificates counts the certificates expired and expiring within
+// certWarnDays
+func (i *Interface) summarizeCertificates(now time.Time) summaryCheck {
+	check := summaryCheck{status: "OK", area: "Certificates"}
+	certs, err := i.bigipClient.GetCertificates()
+	if err != nil {
+		return skipped(check.area, err)
+	}
+	// Expired ones first
+	var expired, expiring []string
+	for _, c := range certs {
+		expires := c.Expires()
+		if expires.IsZero() {
+			continue
+		}
+		days := int(math.Round(expires.Sub(now).Hours() / 24))
+		switch {
+		case !expires.After(now):
+			check.status = "FAIL"
+			expired = append(expired, fmt.Sprintf("%s expired %d days ago", c.FullPath, -days))
+		case days < certWarnDays:
+			check.status = worse(check.status, "WARN")
+			expiring = append(expiring, fmt.Sprintf("%s expires in %d days", c.FullPath, days))
+		}
+	}
+	check.items = append(expired, expiring...)
+	switch {
+	case len(check.items) == 0:
+		check.detail = fmt.Sprintf("%d %s, none expiring within %d days", len(certs), plural("certificate", len(certs)), certWarnDays)
+	default:
+		check.detail = fmt.Sprintf("%d expired, %d expiring within %d days, of %d", len(expired), len(expiring), certWarnDays, len(certs))
+	}
+	return check
+}
+
+// formatSummary lays the checks out as a dashboard, one line per area
+// with the objects behind it below, and an overall verdict
+func formatSummary(checks []summaryCheck, now time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n=== Health Summary (%s) ===\n", now.Format("2006-01-02 15:04")))
+	sb.WriteString("----------------------------------------\n")
+	width := 0
+	for _, c := range checks {
+		width = max(width, len(c.area)+1)
+	}
+	counts := map[string]int{}
+	for _, c := range checks {
+		counts[c.status]++
+		sb.WriteString(fmt.Sprintf("%-6s %-*s %s\n", "["+c.status+"]", width, c.area, c.detail))
+		for n, item := range c.items {
+			if n == summaryItems {
+				sb.WriteString(fmt.Sprintf("%-6s %-*s and %d more\n", "", width, "", len(c.items)-n))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("%-6s %-*s - %s\n", "", width, "", item))
+		}
+	}
+	sb.WriteString("----------------------------------------\n")
+	switch {
+	case counts["FAIL"] > 0:
+		sb.WriteString(fmt.Sprintf("Overall: [FAIL] %d %s to act on now", counts["FAIL"], plural("area", counts["FAIL"])))
+		if counts["WARN"] > 0 {
+			sb.WriteString(fmt.Sprintf(", %d to look at", counts["WARN"]))
+		}
+		sb.WriteString(".\n")
+	case counts["WARN"] > 0:
+		sb.WriteString(fmt.Sprintf("Overall: [WARN] nothing down, but %d %s to look at.\n", counts["WARN"], plural("area", counts["WARN"])))
+	default:
+		sb.WriteString("Overall: [OK] all is well.\n")
+	}
+	if counts["SKIP"] > 0 {
+		sb.WriteString(fmt.Sprintf("%d %s couldn't be read; see above.\n", counts["SKIP"], plural("area", counts["SKIP"])))
+	}
+	return sb.String()
+}
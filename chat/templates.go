@@ -0,0 +1,24 @@
+package chat
+
+import "f5chat/utils"
+
+// SetTemplates lays out answers with the user's output templates where
+// there is one for the kind of object
+func (i *Interface) SetTemplates(templates *utils.Templates) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.templates = templates
+}
+
+// render writes objects with the user's template called name, or with the
+// built-in layout when there isn't one
+func (i *Interface) render(name string, data interface{}, builtin func() string) (string, error) {
+	i.mu.Lock()
+	templates := i.templates
+	i.mu.Unlock()
+	out, ok, err := templates.Render(name, data)
+	if !ok {
+		return builtin(), nil
+	}
+	return out, err
+}
@@ -0,0 +1,268 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"f5chat/llm"
+)
+
+// terraformProvider pins the resources to F5's provider
+const terraformProvider = `terraform {
+  required_providers {
+    bigip = {
+      source = "F5Networks/bigip"
+    }
+  }
+}
+`
+
+// tfInvalid matches what can't be part of a Terraform resource name
+var tfInvalid = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// hclAttr is an attribute of a block, its value already written as HCL
+type hclAttr struct {
+	key, value string
+}
+
+// terraform is what an export writes: the objects read, and the import
+// blocks that adopt them
+type terraform struct {
+	*liveObjects
+	// resources maps the full paths of the pools and nodes exported to
+	// their resource names, so what uses them refers to the resource
+	resources map[string]string
+	imports   []hclAttr
+}
+
+// exportTerraform writes a virtual server, with its pool, the pool's
+// members and their nodes, as resources of F5's Terraform provider, with
+// import blocks that bring the existing objects under Terraform rather
+// than creating them again. A pool name exports the pool alone, and no
+// name every virtual server. With a file named, the Terraform is written
+// to it rather than shown.
+func (i *Interface) exportTerraform(call *llm.ToolCall) (string, error) {
+	file := strings.Trim(call.Arg("file"), "\"'`")
+	if file != "" && !strings.HasSuffix(file, ".tf") {
+		return fmt.Sprintf("Terraform only reads files ending in .tf; try \"export vs_app1 as Terraform to %s.tf\".", strings.TrimSuffix(file, ".")), nil
+	}
+	objects, reply, err := i.readLiveObjects(call)
+	if objects == nil {
+		return reply, err
+	}
+	tf := &terraform{liveObjects: objects, resources: map[string]string{}}
+	for _, p := range objects.pools {
+		tf.resources[p.FullPath] = tfResourceName(p.FullPath)
+	}
+	for _, n := range objects.nodes {
+		tf.resources[n.FullPath] = tfResourceName(n.FullPath)
+	}
+	hcl := tf.write()
+
+	notes := "The import blocks adopt the existing objects on the first terraform apply (Terraform 1.5 or later) instead of creating them again; run terraform plan first to check that nothing else would change. " +
+		"Profiles, iRules, monitors and persistence profiles are referred to by name rather than exported, so custom ones have to exist on the device or be added to the configuration."
+	if file != "" {
+		if err := writeNewFile(file, hcl, "export "+call.Arg("name")+" as Terraform to main-2.tf"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Wrote the Terraform for %s to %s: %s.\n\n%s", objects.title, file, objects.counts(), notes), nil
+	}
+	return fmt.Sprintf("=== Terraform: %s ===\n%s\n\n%s, as resources of F5's bigip provider. %s Add \"to main.tf\" to write it to a file.",
+		objects.title, strings.TrimRight(hcl, "\n"), capitalize(objects.counts()), notes), nil
+}
+
+// write lays the export out as HCL, formatted as terraform fmt would
+func (tf *terraform) write() string {
+	var sb strings.Builder
+	sb.WriteString(terraformProvider)
+
+	for _, n := range tf.nodes {
+		resource := tf.resources[n.FullPath]
+		address := n.Address
+		if n.FQDN.Name != "" {
+			address = n.FQDN.Name
+		}
+		attrs := []hclAttr{{"name", hclString(n.FullPath)}, {"address", hclString(address)}}
+		if n.Description != "" {
+			attrs = append(attrs, hclAttr{"description", hclString(n.Description)})
+		}
+		if n.ConnectionLimit > 0 {
+			attrs = append(attrs, hclAttr{"connection_limit", strconv.Itoa(n.ConnectionLimit)})
+		}
+		if n.Monitor != "" && n.Monitor != "default" {
+			attrs = append(attrs, hclAttr{"monitor", hclString(n.Monitor)})
+		}
+		if n.Session == "user-disabled" {
+			attrs = append(attrs, hclAttr{"session", hclString(n.Session)})
+		}
+		writeBlock(&sb, `resource "bigip_ltm_node" "`+resource+`"`, attrs)
+		tf.adopt("bigip_ltm_node."+resource, n.FullPath)
+	}
+
+	for _, p := range tf.pools {
+		resource := tf.resources[p.FullPath]
+		attrs := []hclAttr{{"name", hclString(p.FullPath)}}
+		if p.LoadBalancingMode != "" {
+			attrs = append(attrs, hclAttr{"load_balancing_mode", hclString(p.LoadBalancingMode)})
+		}
+		if monitors := monitorPaths(p.Monitor); len(monitors) > 0 {
+			attrs = append(attrs, hclAttr{"monitors", hclList(monitors)})
+		}
+		if p.MinActiveMembers > 0 {
+			attrs = append(attrs, hclAttr{"minimum_active_members", strconv.Itoa(p.MinActiveMembers)})
+		}
+		if p.Description != "" {
+			attrs = append(attrs, hclAttr{"description", hclString(p.Description)})
+		}
+		writeBlock(&sb, `resource "bigip_ltm_pool" "`+resource+`"`, attrs)
+		tf.adopt("bigip_ltm_pool."+resource, p.FullPath)
+
+		for _, m := range tf.members[p.FullPath] {
+			nodePath, separator, port := splitMember(m.FullPath)
+			node := hclString(m.FullPath)
+			if nodeResource, ok := tf.resources[nodePath]; ok {
+				node = `"${bigip_ltm_node.` + nodeResource + `.name}` + separator + port + `"`
+			}
+			attachment := resource + "_" + tfResourceName(m.Name)
+			attrs := []hclAttr{{"pool", "bigip_ltm_pool." + resource + ".name"}, {"node", node}}
+			if m.Ratio > 1 {
+				attrs = append(attrs, hclAttr{"ratio", strconv.Itoa(m.Ratio)})
+			}
+			if m.PriorityGroup > 0 {
+				attrs = append(attrs, hclAttr{"priority_group", strconv.Itoa(m.PriorityGroup)})
+			}
+			if m.ConnectionLimit > 0 {
+				attrs = append(attrs, hclAttr{"connection_limit", strconv.Itoa(m.ConnectionLimit)})
+			}
+			writeBlock(&sb, `resource "bigip_ltm_pool_attachment" "`+attachment+`"`, attrs)
+			id, _ := json.Marshal(map[string]string{"pool": p.FullPath, "node": m.FullPath})
+			tf.adopt("bigip_ltm_pool_attachment."+attachment, string(id))
+		}
+	}
+
+	for _, v := range tf.virtualServers {
+		resource := tfResourceName(v.FullPath)
+		destination, port := splitDestination(v.Destination)
+		attrs := []hclAttr{{"name", hclString(v.FullPath)}, {"destination", hclString(destination)}, {"port", port}}
+		if v.Source != "" && v.Source != "0.0.0.0/0" && v.Source != "::/0" {
+			attrs = append(attrs, hclAttr{"source", hclString(v.Source)})
+		}
+		if v.Mask != "" && v.Mask != "255.255.255.255" {
+			attrs = append(attrs, hclAttr{"mask", hclString(v.Mask)})
+		}
+		if v.IPProtocol != "" {
+			attrs = append(attrs, hclAttr{"ip_protocol", hclString(v.IPProtocol)})
+		}
+		if resource, ok := tf.resources[v.Pool]; ok {
+			attrs = append(attrs, hclAttr{"pool", "bigip_ltm_pool." + resource + ".name"})
+		} else if v.Pool != "" {
+			attrs = append(attrs, hclAttr{"pool", hclString(v.Pool)})
+		}
+		var all, client, server []string
+		for _, p := range tf.profiles[v.FullPath] {
+			switch p.Context {
+			case "clientside":
+				client = append(client, p.FullPath)
+			case "serverside":
+				server = append(server, p.FullPath)
+			default:
+				all = append(all, p.FullPath)
+			}
+		}
+		for _, list := range []struct {
+			key   string
+			paths []string
+		}{{"profiles", all}, {"client_profiles", client}, {"server_profiles", server}, {"irules", v.Rules}, {"policies", v.Policies}} {
+			if len(list.paths) > 0 {
+				attrs = append(attrs, hclAttr{list.key, hclList(list.paths)})
+			}
+		}
+		var persistence []string
+		for _, p := range v.PersistenceProfiles {
+			persistence = append(persistence, "/"+partitionOf(p.Partition, p.FullPath)+"/"+p.Name)
+		}
+		if len(persistence) > 0 {
+			attrs = append(attrs, hclAttr{"persistence_profiles", hclList(persistence)})
+		}
+		if v.FallbackPersistenceProfile != "" {
+			attrs = append(attrs, hclAttr{"fallback_persistence_profile", hclString(v.FallbackPersistenceProfile)})
+		}
+		if snat := v.SourceAddressTranslation; snat.Type != "" {
+			attrs = append(attrs, hclAttr{"source_address_translation", hclString(snat.Type)})
+			if snat.Pool != "" {
+				attrs = append(attrs, hclAttr{"snatpool", hclString(snat.Pool)})
+			}
+		}
+		if v.TranslateAddress != "" {
+			attrs = append(attrs, hclAttr{"translate_address", hclString(v.TranslateAddress)})
+		}
+		if v.TranslatePort != "" {
+			attrs = append(attrs, hclAttr{"translate_port", hclString(v.TranslatePort)})
+		}
+		if v.VlansEnabled && len(v.Vlans) > 0 {
+			attrs = append(attrs, hclAttr{"vlans_enabled", "true"}, hclAttr{"vlans", hclList(v.Vlans)})
+		}
+		if v.Description != "" {
+			attrs = append(attrs, hclAttr{"description", hclString(v.Description)})
+		}
+		if v.Disabled {
+			attrs = append(attrs, hclAttr{"state", hclString("disabled")})
+		}
+		writeBlock(&sb, `resource "bigip_ltm_virtual_server" "`+resource+`"`, attrs)
+		tf.adopt("bigip_ltm_virtual_server."+resource, v.FullPath)
+	}
+
+	for _, imp := range tf.imports {
+		writeBlock(&sb, "import", []hclAttr{{"to", imp.key}, {"id", hclString(imp.value)}})
+	}
+	return sb.String()
+}
+
+// adopt adds an import block bringing the object with id under resource
+func (tf *terraform) adopt(resource, id string) {
+	tf.imports = append(tf.imports, hclAttr{resource, id})
+}
+
+// writeBlock writes a block of attributes, with their equals signs lined
+// up, after a blank line
+func writeBlock(sb *strings.Builder, header string, attrs []hclAttr) {
+	width := 0
+	for _, a := range attrs {
+		width = max(width, len(a.key))
+	}
+	fmt.Fprintf(sb, "\n%s {\n", header)
+	for _, a := range attrs {
+		fmt.Fprintf(sb, "  %-*s = %s\n", width, a.key, a.value)
+	}
+	sb.WriteString("}\n")
+}
+
+// tfResourceName makes a resource name of a full path: the partition is
+// left out for /Common, and what HCL doesn't allow becomes underscores, so
+// /Common/vs_app1 is vs_app1 and /Tenant/app/10.0.0.1 Tenant_app_10_0_0_1
+func tfResourceName(fullPath string) string {
+	name := tfInvalid.ReplaceAllString(strings.TrimPrefix(fullPath, "/Common/"), "_")
+	name = strings.Trim(name, "_")
+	if name == "" || name[0] == '-' || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// hclString quotes s as an HCL string, escaping template sequences too
+func hclString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{").Replace(s)
+	return `"` + s + `"`
+}
+
+// hclList writes strings as an HCL list on one line
+func hclList(items []string) string {
+	quoted := make([]string, len(items))
+	for n, item := range items {
+		quoted[n] = hclString(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,334 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"f5chat/llm"
+	"f5chat/prompt"
+)
+
+// tmshCommand is a tmsh command pasted into the chat
+type tmshCommand struct {
+	raw       string
+	verb      string
+	component string
+	name      string
+}
+
+// tmshVerbs are the tmsh commands recognised at the start of a query, and
+// tmshModules the modules that must follow them, so "show pool web_pool"
+// stays a question
+var (
+	tmshVerbs   = map[string]bool{"list": true, "show": true, "create": true, "modify": true, "delete": true, "reset-stats": true, "run": true, "save": true, "load": true}
+	tmshModules = map[string]bool{"ltm": true, "net": true, "sys": true, "asm": true, "gtm": true, "security": true, "auth": true, "apm": true, "cm": true, "util": true, "cli": true}
+)
+
+// tmshComponent ties a tmsh component to the chat tools that read it and
+// its iControl REST collection
+type tmshComponent struct {
+	path     string
+	listTool string
+	getTool  string
+	endpoint string
+}
+
+var tmshComponents = []tmshComponent{
+	{path: "ltm virtual", listTool: llm.ToolListVirtualServers, endpoint: "/mgmt/tm/ltm/virtual"},
+	{path: "ltm pool", listTool: llm.ToolListPools, getTool: llm.ToolGetPool, endpoint: "/mgmt/tm/ltm/pool"},
+	{path: "ltm node", listTool: llm.ToolListNodes, endpoint: "/mgmt/tm/ltm/node"},
+	{path: "ltm rule", getTool: llm.ToolExplainIRule, endpoint: "/mgmt/tm/ltm/rule"},
+	{path: "asm policy", listTool: llm.ToolListWAFPolicies, getTool: llm.ToolGetWAFPolicy, endpoint: "/mgmt/tm/asm/policies"},
+}
+
+// compareComponents maps the kinds compare_objects accepts onto tmsh
+// components; profiles add their type
+var compareComponents = map[string]string{
+	llm.CompareVirtualServer: "ltm virtual",
+	llm.ComparePool:          "ltm pool",
+	llm.CompareNode:          "ltm node",
+	llm.CompareWAFPolicy:     "asm policy",
+	llm.CompareProfile:       "ltm profile",
+}
+
+// tmshOptions are words after a component that aren't an object name
+var tmshOptions = map[string]bool{
+	"members": true, "all-properties": true, "one-line": true, "recursive": true,
+	"field-fmt": true, "detail": true, "raw": true, "{": true,
+}
+
+// tmshQuestion matches requests for the tmsh behind the previous answer
+var tmshQuestion = regexp.MustCompile(`(?i)\btmsh\b.*\b(command|equivalent|do|does|this|that|same)\b|\b(command|equivalent)\b.*\btmsh\b`)
+
+// parseTmsh recognises a pasted tmsh command, with or without the leading
+// "tmsh" or "tmsh -c"
+func parseTmsh(query string) (*tmshCommand, bool) {
+	raw := strings.TrimSpace(query)
+	raw = strings.Trim(raw, "`")
+	raw = strings.TrimPrefix(raw, "# ")
+	if strings.HasPrefix(raw, "tmsh ") {
+		raw = strings.TrimSpace(strings.TrimPrefix(raw, "tmsh "))
+		raw = strings.Trim(strings.TrimSpace(strings.TrimPrefix(raw, "-c ")), `"'`)
+	}
+	fields := strings.Fields(raw)
+	if len(fields) < 2 || !tmshVerbs[fields[0]] || !tmshModules[fields[1]] {
+		return nil, false
+	}
+
+	cmd := &tmshCommand{raw: raw, verb: fields[0]}
+	rest := strings.Join(fields[1:], " ")
+	for _, c := range tmshComponents {
+		if rest == c.path || strings.HasPrefix(rest, c.path+" ") {
+			cmd.component = c.path
+			if after := strings.Fields(strings.TrimPrefix(rest, c.path)); len(after) > 0 && !tmshOptions[after[0]] {
+				cmd.name = after[0]
+			}
+			break
+		}
+	}
+	return cmd, true
+}
+
+// knownComponent returns the known component the command works on, if any
+func (c *tmshCommand) knownComponent() (tmshComponent, bool) {
+	for _, tc := range tmshComponents {
+		if tc.path == c.component {
+			return tc, true
+		}
+	}
+	return tmshComponent{}, false
+}
+
+// restPath returns the iControl REST path of an object (~Partition~name)
+func restPath(endpoint, name string) string {
+	if name == "" {
+		return endpoint
+	}
+	return endpoint + "/" + strings.ReplaceAll(name, "/", "~")
+}
+
+// tmshCall maps a read-only tmsh command onto the chat tool that answers
+// it, with the equivalent REST call
+func tmshCall(cmd *tmshCommand) (*llm.ToolCall, string, bool) {
+	c, ok := cmd.knownComponent()
+	if !ok || (cmd.verb != "list" && cmd.verb != "show") {
+		return nil, "", false
+	}
+	if cmd.name != "" && c.getTool != "" {
+		rest := "GET " + restPath(c.endpoint, cmd.name)
+		if c.path == "asm policy" {
+			// ASM policies are addressed by ID, so look them up by name
+			rest = "GET " + c.endpoint + "?$filter=" + url.QueryEscape("name eq '"+cmd.name+"'")
+		}
+		return &llm.ToolCall{Name: c.getTool, Args: map[string]string{"name": cmd.name}}, rest, true
+	}
+	if cmd.name == "" && c.listTool != "" {
+		return &llm.ToolCall{Name: c.listTool, Args: map[string]string{}}, "GET " + c.endpoint, true
+	}
+	return nil, "", false
+}
+
+// explainTmsh describes a tmsh command that isn't run: changes, and reads
+// the chat has no operation for
+func (i *Interface) explainTmsh(cmd *tmshCommand) string {
+	var sb strings.Builder
+	if cmd.verb == "list" || cmd.verb == "show" {
+		fmt.Fprintf(&sb, "I can't run `%s` here, but here is what it does.\n", cmd.raw)
+	} else {
+		fmt.Fprintf(&sb, "I don't run tmsh changes; `%s` was not executed.\n", cmd.raw)
+	}
+	if c, ok := cmd.knownComponent(); ok {
+		method := map[string]string{"list": "GET", "show": "GET", "create": "POST", "modify": "PATCH", "delete": "DELETE"}[cmd.verb]
+		if method != "" {
+			path := restPath(c.endpoint, cmd.name)
+			if cmd.verb == "create" {
+				path = c.endpoint
+			}
+			if cmd.verb == "show" {
+				path += "/stats"
+			}
+			fmt.Fprintf(&sb, "Equivalent REST call: %s %s\n", method, path)
+		}
+	}
+
+	explanation, err := i.llmClient.RunTask(prompt.Tmsh, cmd.raw)
+	switch {
+	case errors.Is(err, llm.ErrNoLLM):
+		sb.WriteString("\n(Explanations of tmsh commands need an LLM; run without -no-llm to get one.)")
+	case err != nil:
+		slog.Warn("Failed to explain tmsh command", "command", cmd.raw, "err", err)
+		fmt.Fprintf(&sb, "\nI couldn't get an explanation right now (%v).", err)
+	default:
+		sb.WriteString("\n" + strings.TrimSpace(explanation))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// tmshFor returns the tmsh commands and REST calls equivalent to a tool call
+func tmshFor(call *llm.ToolCall) (tmsh []string, rest []string) {
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	switch call.Name {
+	case llm.ToolListVirtualServers:
+		return []string{"tmsh list ltm virtual", "tmsh show ltm virtual   (status)"}, []string{"GET /mgmt/tm/ltm/virtual?expandSubcollections=true"}
+	case llm.ToolListPools:
+		return []string{"tmsh list ltm pool", "tmsh show ltm pool members   (status)"},
+			[]string{"GET /mgmt/tm/ltm/pool?expandSubcollections=true"}
+	case llm.ToolGetPool:
+		return []string{"tmsh list ltm pool " + name, "tmsh show ltm pool " + name + " members   (status)"},
+			[]string{"GET " + restPath("/mgmt/tm/ltm/pool", name) + "/members"}
+	case llm.ToolListNodes:
+		return []string{"tmsh list ltm node", "tmsh show ltm node   (status)"}, []string{"GET /mgmt/tm/ltm/node"}
+	case llm.ToolListWAFPolicies:
+		return []string{"tmsh list asm policy"}, []string{"GET /mgmt/tm/asm/policies"}
+	case llm.ToolGetWAFPolicy:
+		return []string{"tmsh list asm policy " + name}, []string{"GET /mgmt/tm/asm/policies?$filter=" + url.QueryEscape("name eq '"+name+"'")}
+	case llm.ToolExplainIRule:
+		return []string{"tmsh list ltm rule " + name}, []string{"GET " + restPath("/mgmt/tm/ltm/rule", name)}
+	case llm.ToolCompare:
+		component, ok := compareComponents[call.Arg("kind")]
+		if !ok {
+			return nil, nil
+		}
+		endpoint := "/mgmt/tm/ltm/profile/" + call.Arg("profile_type")
+		if component == "ltm profile" {
+			component += " " + call.Arg("profile_type")
+		}
+		for _, c := range tmshComponents {
+			if c.path == component {
+				endpoint = c.endpoint
+			}
+		}
+		first, second := call.Arg("first"), call.Arg("second")
+		return []string{"tmsh list " + component + " " + first, "tmsh list " + component + " " + second},
+			[]string{"GET " + restPath(endpoint, first), "GET " + restPath(endpoint, second)}
+	case llm.ToolTroubleshoot:
+		return []string{"tmsh show ltm virtual", "tmsh show ltm pool members", "tmsh show ltm node", "tmsh show sys log ltm lines 200"},
+			[]string{"GET /mgmt/tm/ltm/virtual?expandSubcollections=true", "GET /mgmt/tm/ltm/virtual/stats", "GET /mgmt/tm/ltm/pool?expandSubcollections=true", "GET /mgmt/tm/ltm/node", "GET /mgmt/tm/sys/log/ltm/stats?options=lines,200"}
+	case llm.ToolSecurityPosture:
+		return []string{"tmsh list ltm virtual profiles rules", "tmsh list asm policy", "tmsh list ltm profile client-ssl options ciphers", "tmsh list security dos profile", "tmsh list security bot-defense profile"},
+			[]string{"GET /mgmt/tm/ltm/virtual?expandSubcollections=true", "GET /mgmt/tm/asm/policies", "GET /mgmt/tm/ltm/profile/client-ssl", "GET /mgmt/tm/ltm/profile/http", "GET /mgmt/tm/security/dos/profile", "GET /mgmt/tm/security/bot-defense/profile"}
+	case llm.ToolWAFViolations:
+		// The ASM request log is read in the GUI or over REST, not in tmsh
+		return nil, []string{"GET /mgmt/tm/asm/events/requests?$top=500&$filter=requestStatus ne 'passed'"}
+	case llm.ToolGTMTopology:
+		return []string{"tmsh list gtm topology", "tmsh list gtm region", "tmsh list gtm global-settings load-balancing topology-longest-match"},
+			[]string{"GET /mgmt/tm/gtm/topology", "GET /mgmt/tm/gtm/region", "GET /mgmt/tm/gtm/global-settings/load-balancing"}
+	case llm.ToolGetAddressList, llm.ToolGetPortList:
+		kind := "address-list"
+		if call.Name == llm.ToolGetPortList {
+			kind = "port-list"
+		}
+		return []string{strings.TrimSpace("tmsh list security firewall " + kind + " " + name)},
+			[]string{"GET /mgmt/tm/security/firewall/" + kind}
+	case llm.ToolAddToFirewallList:
+		// Only shows the entries; nothing is read but the lists
+		return []string{"tmsh list security firewall address-list", "tmsh list security firewall port-list"},
+			[]string{"GET /mgmt/tm/security/firewall/address-list", "GET /mgmt/tm/security/firewall/port-list"}
+	case llm.ToolOWASPCompliance:
+		// The OWASP Compliance Dashboard has no tmsh counterpart
+		return nil, []string{"GET /mgmt/tm/asm/policies", "GET /mgmt/tm/asm/policies/<policy ID>/owasp-compliance"}
+	case llm.ToolHealthSummary:
+		return []string{"tmsh list cm device hostname version build marketing-name failover-state", "tmsh show cm sync-status", "tmsh show sys cpu",
+				"tmsh show ltm virtual", "tmsh show ltm pool members", "tmsh show sys log ltm lines 200", "tmsh list sys file ssl-cert expiration-string"},
+			[]string{"GET /mgmt/tm/cm/device", "GET /mgmt/tm/cm/sync-status", "GET /mgmt/tm/sys/cpu", "GET /mgmt/tm/ltm/virtual?expandSubcollections=true", "GET /mgmt/tm/ltm/virtual/stats",
+				"GET /mgmt/tm/ltm/pool?expandSubcollections=true", "GET /mgmt/tm/sys/log/ltm/stats?options=lines,200", "GET /mgmt/tm/sys/file/ssl-cert"}
+	case llm.ToolExportTerraform, llm.ToolGenerateAnsible:
+		if call.Arg("description") != "" {
+			// Written by the LLM; nothing is read from the device
+			return nil, nil
+		}
+		return []string{strings.TrimSpace("tmsh list ltm virtual " + name), "tmsh list ltm pool <pool> members", "tmsh list ltm node"},
+			[]string{"GET /mgmt/tm/ltm/virtual?expandSubcollections=true", "GET /mgmt/tm/ltm/pool?expandSubcollections=true", "GET /mgmt/tm/ltm/node"}
+	case llm.ToolGenerateQkview:
+		return []string{"tmsh run util qkview"},
+			[]string{"POST /mgmt/cm/autodeploy/qkview", "GET /mgmt/cm/autodeploy/qkview/<id>", "GET /mgmt/cm/autodeploy/qkview-download/<file>", "DELETE /mgmt/cm/autodeploy/qkview/<id>"}
+	case llm.ToolUploadIRule:
+		return []string{"tmsh create ltm rule " + name + " { <TCL> }"}, []string{"POST /mgmt/tm/ltm/rule"}
+	case llm.ToolUpdateFirewallList:
+		kind := call.Arg("kind") + "-list"
+		return []string{"tmsh modify security firewall " + kind + " " + name + " " + firewallModification(call.Arg("kind"), call.Arg("entries"))},
+			[]string{"GET " + restPath("/mgmt/tm/security/firewall/"+kind, name), "PATCH " + restPath("/mgmt/tm/security/firewall/"+kind, name)}
+	case llm.ToolDeployAS3:
+		// AS3 is a REST-only extension
+		return nil, []string{"POST /mgmt/shared/appsvcs/declare?async=true", "GET /mgmt/shared/appsvcs/task/<id>"}
+	}
+	return nil, nil
+}
+
+// firewallModification is the part of tmsh modify that adds entries, as
+// bigip.FirewallList holds them, to a list of a kind: "addresses add {
+// 10.0.0.1 }"
+func firewallModification(kind, entries string) string {
+	fields := []struct{ prefix, property string }{
+		{"fqdn ", "fqdns"}, {"geo ", "geo"}, {"address-list ", "address-lists"}, {"port-list ", "port-lists"},
+	}
+	var order []string
+	added := map[string][]string{}
+	for _, entry := range strings.Split(entries, ",") {
+		property, value := "", strings.TrimSpace(entry)
+		for _, f := range fields {
+			if rest, ok := strings.CutPrefix(value, f.prefix); ok {
+				property, value = f.property, rest
+			}
+		}
+		if property == "" {
+			property = "addresses"
+			if kind == "port" {
+				property = "ports"
+			}
+		}
+		if _, ok := added[property]; !ok {
+			order = append(order, property)
+		}
+		added[property] = append(added[property], value)
+	}
+	var parts []string
+	for _, property := range order {
+		parts = append(parts, property+" add { "+strings.Join(added[property], " ")+" }")
+	}
+	return strings.Join(parts, " ")
+}
+
+// tmshForLast answers "what tmsh command does this?" for the previous query
+func (i *Interface) tmshForLast() string {
+	i.mu.Lock()
+	calls := i.lastCalls
+	i.mu.Unlock()
+	if len(calls) == 0 {
+		return "Ask something about the BIG-IP first, e.g. 'show pool web_pool', then ask for the tmsh equivalent."
+	}
+	var tmsh, rest []string
+	for _, call := range calls {
+		t, r := tmshFor(call)
+		tmsh, rest = append(tmsh, t...), append(rest, r...)
+	}
+	if len(tmsh) == 0 && len(rest) == 0 {
+		return "The last answer didn't read anything from the device, so there is no tmsh equivalent."
+	}
+
+	var sb strings.Builder
+	if len(tmsh) == 0 {
+		sb.WriteString("There is no tmsh equivalent; AS3 is only available through iControl REST.\n")
+	} else {
+		sb.WriteString("tmsh:\n")
+		for _, t := range tmsh {
+			fmt.Fprintf(&sb, "  %s\n", t)
+		}
+	}
+	sb.WriteString("iControl REST:\n")
+	for _, r := range rest {
+		fmt.Fprintf(&sb, "  %s\n", r)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// setLastCall records the operations behind the latest answer
+func (i *Interface) setLastCall(calls ...*llm.ToolCall) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.lastCalls = calls
+}
@@ -0,0 +1,308 @@
+package chat
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+// gtmClient is where the clients a topology question asks about are. Only
+// what the place pins down is set: clients in a continent have no country,
+// and a client address has no continent, as it isn't geolocated.
+type gtmClient struct {
+	// who says who the clients are, e.g. "Clients in continent EU"
+	who                string
+	continent, country string
+	region             string
+	ip                 net.IP
+}
+
+// topologyMatch is a record clients match, or might match depending on
+// where exactly they are
+type topologyMatch struct {
+	record bigip.TopologyRecord
+	// scored is whether the record gives its destination its score, being
+	// the first the clients match for it
+	scored bool
+}
+
+// gtmTopology lists the GTM topology records and regions, and when the
+// query says where clients are, works out which records they match and
+// where they resolve to
+func (i *Interface) gtmTopology(call *llm.ToolCall) (string, error) {
+	topology, err := i.bigipClient.GetTopology()
+	if unprovisioned(err) {
+		return "GTM (BIG-IP DNS) isn't provisioned on this device, so there are no topology records steering clients.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	records := evaluationOrder(topology)
+	i.setData(records)
+
+	location := strings.Trim(strings.TrimSpace(call.Arg("location")), "\"'`")
+	if location == "" {
+		out := formatTopology(topology, records)
+		if len(records) > 0 {
+			out += "\nAsk e.g. \"why do EU clients resolve to DC2?\" to see which records clients in a place match.\n"
+		}
+		return out, nil
+	}
+	client, ok := locateClients(location, topology.Regions)
+	if !ok {
+		return fmt.Sprintf("I don't know where '%s' is. Name a continent, a country, a GTM region or a client IP address, e.g. \"why do EU clients resolve to DC2?\"", location), nil
+	}
+	if len(records) == 0 {
+		return "There are no GTM topology records, so where clients are doesn't steer them.", nil
+	}
+	return formatTopology(topology, records) + explainTopology(client, records, topology.Regions), nil
+}
+
+// locateClients works out where a place the user named is: a GTM region, a
+// client address, a continent or a country
+func locateClients(place string, regions []bigip.Region) (gtmClient, bool) {
+	for _, r := range regions {
+		if strings.EqualFold(place, r.Name) || strings.EqualFold(place, r.FullPath) {
+			return gtmClient{who: "Clients in region " + r.FullPath, region: r.FullPath}, true
+		}
+	}
+	// Topology matches the address of the clients' local DNS server
+	if ip := net.ParseIP(place); ip != nil {
+		return gtmClient{who: "Clients of the local DNS server at " + ip.String(), ip: ip}, true
+	}
+	if code := lookupContinent(place); code != "" {
+		return gtmClient{who: "Clients in continent " + code, continent: code}, true
+	}
+	if c, ok := lookupCountry(place); ok {
+		return gtmClient{who: "Clients in country " + c.code, continent: c.continent, country: c.code}, true
+	}
+	return gtmClient{}, false
+}
+
+// evaluationOrder sorts the records the way GTM takes them. With longest
+// match, the most specific client side comes first, the longest subnet
+// before shorter ones, then the highest weight; without it, records are
+// taken in their order.
+func evaluationOrder(topology *bigip.Topology) []bigip.TopologyRecord {
+	records := append([]bigip.TopologyRecord(nil), topology.Records...)
+	if !topology.LongestMatch {
+		sort.SliceStable(records, func(a, b int) bool { return records[a].Order < records[b].Order })
+		return records
+	}
+	sort.SliceStable(records, func(a, b int) bool {
+		ra, rb := specificity(records[a].LDNS), specificity(records[b].LDNS)
+		if ra != rb {
+			return ra < rb
+		}
+		if pa, pb := prefixLength(records[a].LDNS), prefixLength(records[b].LDNS); pa != pb {
+			return pa > pb
+		}
+		return records[a].Weight > records[b].Weight
+	})
+	return records
+}
+
+// specificity ranks the kinds of client side, most specific first
+func specificity(ldns string) int {
+	kind, _ := splitLocation(ldns)
+	switch kind {
+	case "subnet":
+		return 0
+	case "region":
+		return 1
+	case "isp", "geoip-isp":
+		return 2
+	case "state":
+		return 3
+	case "country":
+		return 4
+	case "continent":
+		return 5
+	}
+	return 6
+}
+
+// prefixLength is a subnet's prefix length, 0 for other kinds of location
+func prefixLength(ldns string) int {
+	kind, value := splitLocation(ldns)
+	if kind != "subnet" {
+		return 0
+	}
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		ones, _ := network.Mask.Size()
+		return ones
+	}
+	return 0
+}
+
+// splitLocation splits "continent EU" into its kind and value, dropping a
+// leading "not"
+func splitLocation(location string) (kind, value string) {
+	location = strings.TrimPrefix(strings.TrimSpace(location), "not ")
+	kind, value, _ = strings.Cut(location, " ")
+	return kind, strings.TrimSpace(value)
+}
+
+// matchLocation reports whether the clients are in a location, a record's
+// client side or a region member. known is false when where the clients
+// are doesn't say, such as a country for clients in a continent.
+func matchLocation(location string, client gtmClient, regions []bigip.Region, depth int) (matched, known bool) {
+	location = strings.TrimSpace(location)
+	if inner, negated := strings.CutPrefix(location, "not "); negated {
+		matched, known = matchLocation(inner, client, regions, depth)
+		return !matched, known
+	}
+	kind, value := splitLocation(location)
+	switch kind {
+	case "continent":
+		if client.continent == "" {
+			return false, false
+		}
+		return strings.EqualFold(value, client.continent), true
+	case "country":
+		if client.country != "" {
+			return strings.EqualFold(value, client.country), true
+		}
+		// A country on another continent is out either way
+		if client.continent != "" && continentOf(value) != "" && continentOf(value) != client.continent {
+			return false, true
+		}
+		return false, false
+	case "subnet":
+		_, network, err := net.ParseCIDR(value)
+		if client.ip == nil || err != nil {
+			return false, false
+		}
+		return network.Contains(client.ip), true
+	case "region":
+		if strings.EqualFold(value, client.region) {
+			return true, true
+		}
+		if depth > 4 {
+			return false, false
+		}
+		for _, r := range regions {
+			if !strings.EqualFold(value, r.FullPath) && !strings.EqualFold(value, r.Name) {
+				continue
+			}
+			known = true
+			for _, member := range r.Members {
+				m, k := matchLocation(member, client, regions, depth+1)
+				if m && k {
+					return true, true
+				}
+				known = known && k
+			}
+			return false, known
+		}
+	}
+	return false, false
+}
+
+// explainTopology lists the records the clients match, in the order they
+// are taken, and says where they resolve to: each destination is scored by
+// the first record the clients match for it, and the highest score wins
+func explainTopology(client gtmClient, records []bigip.TopologyRecord, regions []bigip.Region) string {
+	var matched, unsure []topologyMatch
+	scores := map[string]int{}
+	var destinations []string
+	for _, r := range records {
+		m, known := matchLocation(r.LDNS, client, regions, 0)
+		switch {
+		case !known:
+			unsure = append(unsure, topologyMatch{record: r})
+		case m:
+			_, seen := scores[r.Server]
+			if !seen {
+				scores[r.Server] = r.Weight
+				destinations = append(destinations, r.Server)
+			}
+			matched = append(matched, topologyMatch{record: r, scored: !seen})
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("----------------------------------------\n")
+	sb.WriteString(client.who + ":\n")
+	for _, m := range matched {
+		note := ""
+		if !m.scored {
+			note = "  (" + m.record.Server + " is already scored)"
+		}
+		sb.WriteString(fmt.Sprintf("  match   %s -> %s, weight %d%s\n", m.record.LDNS, m.record.Server, m.record.Weight, note))
+	}
+	if len(matched) == 0 {
+		sb.WriteString("  No record matches them, so topology doesn't pick a destination; GTM falls back to the pool's next load balancing method.\n")
+	} else {
+		sort.SliceStable(destinations, func(a, b int) bool { return scores[destinations[a]] > scores[destinations[b]] })
+		best := destinations[0]
+		var others []string
+		for _, d := range destinations[1:] {
+			others = append(others, fmt.Sprintf("%s: %d", d, scores[d]))
+		}
+		switch {
+		case len(destinations) > 1 && scores[destinations[1]] == scores[best]:
+			sb.WriteString(fmt.Sprintf("They tie at weight %d between %s and %s, so the pool's next load balancing method picks one.\n", scores[best], best, destinations[1]))
+		case len(others) > 0:
+			sb.WriteString(fmt.Sprintf("They resolve to %s: %s scores it %d, more than any other destination gets (%s).\n",
+				best, recordFor(matched, best), scores[best], strings.Join(others, ", ")))
+		default:
+			sb.WriteString(fmt.Sprintf("They resolve to %s: %s scores it %d, and no record sends them anywhere else.\n", best, recordFor(matched, best), scores[best]))
+		}
+	}
+	if len(unsure) > 0 {
+		sb.WriteString("These also apply to some of them, depending on where exactly they are:\n")
+		for _, m := range unsure {
+			sb.WriteString(fmt.Sprintf("  maybe   %s -> %s, weight %d\n", m.record.LDNS, m.record.Server, m.record.Weight))
+		}
+	}
+	return sb.String()
+}
+
+// recordFor is the client side of the record that scored a destination
+func recordFor(matched []topologyMatch, destination string) string {
+	for _, m := range matched {
+		if m.scored && m.record.Server == destination {
+			return m.record.LDNS
+		}
+	}
+	return ""
+}
+
+// formatTopology lays out the records in the order they are taken, and the
+// regions they refer to
+func formatTopology(topology *bigip.Topology, records []bigip.TopologyRecord) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== GTM Topology ===\n")
+	sb.WriteString("----------------------------------------\n")
+	order := "off: records are taken in their order"
+	if topology.LongestMatch {
+		order = "on: the most specific client side is taken first"
+	}
+	sb.WriteString(fmt.Sprintf("%-16s %s\n", "Longest match", order))
+	sb.WriteString(fmt.Sprintf("%-16s %d\n", "Records", len(records)))
+	sb.WriteString(fmt.Sprintf("%-16s %d\n", "Regions", len(topology.Regions)))
+	if len(records) > 0 {
+		sb.WriteString("----------------------------------------\n")
+		sb.WriteString("Records, in the order they're taken:\n")
+		width := 0
+		for _, r := range records {
+			width = max(width, len(r.LDNS))
+		}
+		for n, r := range records {
+			sb.WriteString(fmt.Sprintf("  %-3s %-*s -> %s, weight %d\n", strconv.Itoa(n+1), width, r.LDNS, r.Server, r.Weight))
+		}
+	}
+	if len(topology.Regions) > 0 {
+		sb.WriteString("Regions:\n")
+		for _, r := range topology.Regions {
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", r.FullPath, strings.Join(r.Members, ", ")))
+		}
+	}
+	return sb.String()
+}
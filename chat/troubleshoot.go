@@ -0,0 +1,346 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+)
+
+const (
+	// troubleshootLogLines is how much of the LTM log is read, and
+	// maxLogFindings how many of the lines about the objects are shown
+	troubleshootLogLines = 200
+	maxLogFindings       = 5
+)
+
+// finding is the result of one check along a virtual server's dependency
+// chain. cause explains a problem in a sentence for the summary.
+type finding struct {
+	status string // PASS, WARN or FAIL
+	check  string
+	detail string
+	cause  string
+}
+
+// walk is what troubleshooting found for one virtual server
+type walk struct {
+	vs       bigip.VirtualServer
+	findings []finding
+	// objects are the full paths along the chain, for picking log lines
+	objects []string
+}
+
+func (w *walk) add(status, check, detail, cause string) {
+	w.findings = append(w.findings, finding{status: status, check: check, detail: detail, cause: cause})
+}
+
+// rootCause is the first failure's cause, or the warnings' when nothing
+// failed; it is empty when every check passed
+func (w *walk) rootCause() (string, bool) {
+	var warnings []string
+	for _, f := range w.findings {
+		if f.status == "FAIL" {
+			return f.cause, true
+		}
+		if f.status == "WARN" && f.cause != "" {
+			warnings = append(warnings, f.cause)
+		}
+	}
+	return strings.Join(warnings, " "), false
+}
+
+// fillTarget names the virtual server or pool a troubleshooting, Terraform
+// or Ansible export request is about when the call doesn't, from the
+// objects the query mentions by name, so "why is vs_app1 down?" checks
+// vs_app1 however the tool was picked
+func (i *Interface) fillTarget(query string, call *llm.ToolCall) {
+	switch {
+	case call.Arg("name") != "", call.Arg("description") != "":
+		return
+	case call.Name != llm.ToolTroubleshoot && call.Name != llm.ToolExportTerraform && call.Name != llm.ToolGenerateAnsible:
+		return
+	}
+	var names []string
+	if vs, err := i.bigipClient.GetVirtualServers(); err == nil {
+		for _, v := range vs {
+			names = append(names, v.Name, v.FullPath)
+		}
+	}
+	if pools, _, err := i.bigipClient.GetPools(); err == nil {
+		for _, p := range pools {
+			names = append(names, p.Name, p.FullPath)
+		}
+	}
+	for _, name := range names {
+		if mentions(query, name) {
+			if call.Args == nil {
+				call.Args = map[string]string{}
+			}
+			call.Args["name"] = name
+			return
+		}
+	}
+}
+
+// troubleshoot walks the chain from a virtual server to its pool, the pool
+// members and their monitor, the members' nodes and the LTM log, and names
+// the root cause. Without a name every virtual server is checked and only
+// the ones with problems are shown.
+func (i *Interface) troubleshoot(call *llm.ToolCall) (string, error) {
+	name := strings.Trim(call.Arg("name"), "\"'`")
+	vs, err := i.bigipClient.GetVirtualServers()
+	if err != nil {
+		return "", err
+	}
+	pools, members, err := i.bigipClient.GetPools()
+	if err != nil {
+		return "", err
+	}
+	nodes, err := i.bigipClient.GetNodes()
+	if err != nil {
+		return "", err
+	}
+	// Availability is a refinement; the configuration alone still tells a lot
+	stats, err := i.bigipClient.GetStats("virtual")
+	if err != nil {
+		slog.Warn("Troubleshooting without virtual server statistics", "err", err)
+	}
+
+	targets := vs
+	if name != "" {
+		objects := make([]named, len(vs))
+		for n, v := range vs {
+			objects[n] = named{name: v.Name, fullPath: v.FullPath}
+		}
+		matches := matchNames(name, objects)
+		if len(matches) == 0 {
+			// A pool name troubleshoots the virtual servers in front of it
+			poolObjects := make([]named, len(pools))
+			for n, p := range pools {
+				poolObjects[n] = named{name: p.Name, fullPath: p.FullPath}
+			}
+			if poolMatches := matchNames(name, poolObjects); len(poolMatches) == 1 {
+				for _, v := range vs {
+					if v.Pool == poolMatches[0] {
+						matches = append(matches, v.FullPath)
+					}
+				}
+				if len(matches) == 0 {
+					return fmt.Sprintf("No virtual server uses pool %s, so no traffic reaches it. Attach it to a virtual server, or ask about the virtual server that should use it.", poolMatches[0]), nil
+				}
+			}
+		}
+		if len(matches) == 0 {
+			return "", fmt.Errorf("no virtual server or pool named '%s'", name)
+		}
+		if len(matches) > 1 {
+			return i.askChoice(call.Name, "virtual servers", name, matches), nil
+		}
+		targets = nil
+		for _, v := range vs {
+			if v.FullPath == matches[0] {
+				targets = append(targets, v)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return "There are no virtual servers to troubleshoot.", nil
+	}
+
+	var logs []string
+	if lines, err := i.bigipClient.GetLogLines(troubleshootLogLines); err == nil {
+		logs = lines
+	} else {
+		slog.Warn("Troubleshooting without the LTM log", "err", err)
+	}
+
+	var walks []*walk
+	for _, v := range targets {
+		walks = append(walks, i.walkVirtualServer(v, pools, members, nodes, stats))
+	}
+	if len(walks) == 1 {
+		return formatWalk(walks[0], logs), nil
+	}
+
+	var sb strings.Builder
+	var healthy []string
+	for _, w := range walks {
+		if cause, _ := w.rootCause(); cause == "" {
+			healthy = append(healthy, w.vs.FullPath)
+			continue
+		}
+		sb.WriteString(formatWalk(w, logs))
+	}
+	if len(healthy) == len(walks) {
+		return fmt.Sprintf("No problem found on any of the %d virtual servers, from the virtual server down to the nodes. Name the application's virtual server to see each check.", len(walks)), nil
+	}
+	if len(healthy) > 0 {
+		sb.WriteString(fmt.Sprintf("\nNo problem found on: %s.\n", strings.Join(healthy, ", ")))
+	}
+	return sb.String(), nil
+}
+
+// walkVirtualServer checks each link from a virtual server to its nodes.
+// Every link is checked even after a failure, so the report shows all of
+// them; the first failure is the root cause.
+func (i *Interface) walkVirtualServer(v bigip.VirtualServer, pools []bigip.Pool, poolMembers map[string][]bigip.PoolMember,
+	nodes []bigip.Node, stats map[string]bigip.ObjectStats) *walk {
+	w := &walk{vs: v, objects: []string{v.FullPath}}
+
+	availability := stats[v.FullPath].Availability
+	switch {
+	case !v.Enabled:
+		w.add("FAIL", "Virtual server", v.FullPath+" is disabled",
+			fmt.Sprintf("%s is disabled, so it doesn't accept connections. Enable it with: tmsh modify ltm virtual %s enabled.", v.FullPath, v.FullPath))
+	case availability == "offline":
+		// Explained by the pool checks below
+		w.add("WARN", "Virtual server", v.FullPath+" is enabled but offline", "")
+	default:
+		detail := v.FullPath + " is enabled"
+		if availability != "" {
+			detail += " and " + availability
+		}
+		w.add("PASS", "Virtual server", detail, "")
+	}
+
+	if v.Pool == "" {
+		w.add("FAIL", "Pool", "no default pool",
+			fmt.Sprintf("%s has no default pool, so traffic only reaches servers if an iRule or policy picks a pool.", v.FullPath))
+		return w
+	}
+	var pool *bigip.Pool
+	for n := range pools {
+		if pools[n].FullPath == v.Pool || pools[n].Name == v.Pool {
+			pool = &pools[n]
+		}
+	}
+	if pool == nil {
+		w.add("FAIL", "Pool", v.Pool+" doesn't exist",
+			fmt.Sprintf("%s points at pool %s, which doesn't exist.", v.FullPath, v.Pool))
+		return w
+	}
+	w.objects = append(w.objects, pool.FullPath)
+	monitor := strings.TrimSpace(pool.Monitor)
+
+	members, err := i.membersOf(*pool, poolMembers)
+	if err != nil {
+		w.add("WARN", "Pool", fmt.Sprintf("%s: couldn't read its members (%v)", pool.FullPath, err),
+			fmt.Sprintf("the members of pool %s couldn't be read, so nothing past the pool was checked.", pool.FullPath))
+		return w
+	}
+	if len(members) == 0 {
+		w.add("FAIL", "Pool", pool.FullPath+" has no members",
+			fmt.Sprintf("pool %s has no members, so there is no server to send traffic to.", pool.FullPath))
+		return w
+	}
+
+	var down []bigip.PoolMember
+	for _, m := range members {
+		w.objects = append(w.objects, m.FullPath)
+		if m.Session == "user-disabled" || m.State == "down" || m.State == "user-down" {
+			down = append(down, m)
+		}
+	}
+	// Each member that is down, explained by its own state and its node's
+	var reasons []string
+	for _, m := range down {
+		nodePath := m.FullPath
+		if n := strings.LastIndex(nodePath, ":"); n >= 0 {
+			nodePath = nodePath[:n]
+		}
+		w.objects = append(w.objects, nodePath)
+		var node *bigip.Node
+		for n := range nodes {
+			if nodes[n].FullPath == nodePath {
+				node = &nodes[n]
+			}
+		}
+		switch {
+		case m.Session == "user-disabled":
+			reasons = append(reasons, fmt.Sprintf("member %s was disabled by an administrator", m.FullPath))
+		case node != nil && node.Session == "user-disabled":
+			reasons = append(reasons, fmt.Sprintf("node %s (%s) was disabled by an administrator", node.FullPath, node.Address))
+		case node != nil && node.State == "down":
+			reasons = append(reasons, fmt.Sprintf("node %s (%s) is down: its own monitor fails too, so the server isn't reachable", node.FullPath, node.Address))
+		case monitor != "":
+			reasons = append(reasons, fmt.Sprintf("member %s fails the pool's monitor %s while its node answers, so the service on that port isn't responding", m.FullPath, monitor))
+		default:
+			reasons = append(reasons, fmt.Sprintf("member %s is marked down", m.FullPath))
+		}
+	}
+
+	monitored := "no monitor"
+	if monitor != "" {
+		monitored = "monitor " + monitor
+	}
+	up := len(members) - len(down)
+	detail := fmt.Sprintf("%s: %d of %d members available (%s)", pool.FullPath, up, len(members), monitored)
+	switch {
+	case len(down) == 0:
+		w.add("PASS", "Pool", detail, "")
+	case up == 0:
+		w.add("FAIL", "Pool", detail,
+			fmt.Sprintf("every member of pool %s is down: %s.", pool.FullPath, strings.Join(reasons, "; ")))
+	default:
+		w.add("WARN", "Pool", detail,
+			fmt.Sprintf("%d of %d members of pool %s are down: %s.", len(down), len(members), pool.FullPath, strings.Join(reasons, "; ")))
+	}
+	status := "WARN"
+	if up == 0 {
+		status = "FAIL"
+	}
+	for _, reason := range reasons {
+		w.add(status, "Member", reason, "")
+	}
+	return w
+}
+
+// formatWalk renders the checks in the style of the health report, with the
+// log lines about the objects checked and the root cause
+func formatWalk(w *walk, logs []string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n=== Troubleshooting %s ===\n", w.vs.FullPath))
+	sb.WriteString("----------------------------------------\n")
+	for _, f := range w.findings {
+		sb.WriteString(fmt.Sprintf("[%-4s] %-14s %s\n", f.status, f.check, f.detail))
+	}
+
+	var relevant []string
+	for _, line := range logs {
+		for _, object := range w.objects {
+			if strings.Contains(line, object+" ") || strings.HasSuffix(line, object) {
+				relevant = append(relevant, line)
+				break
+			}
+		}
+	}
+	if len(relevant) > maxLogFindings {
+		relevant = relevant[len(relevant)-maxLogFindings:]
+	}
+	if len(relevant) > 0 {
+		sb.WriteString("Recent log lines:\n")
+		for _, line := range relevant {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	sb.WriteString("----------------------------------------\n")
+
+	switch cause, failed := w.rootCause(); {
+	case failed:
+		sb.WriteString("Root cause: " + capitalize(cause) + "\n")
+		// Later failures need fixing too once the first is
+		for _, f := range w.findings {
+			if f.status == "FAIL" && f.cause != "" && f.cause != cause {
+				sb.WriteString("Also: " + capitalize(f.cause) + "\n")
+			}
+		}
+	case cause != "":
+		sb.WriteString("Degraded: " + capitalize(cause) + "\n")
+	default:
+		sb.WriteString(fmt.Sprintf("No problem found from %s down to its nodes. If clients still can't connect, check the network path to %s and any iRules or policies on the virtual server.\n", w.vs.FullPath, w.vs.Destination))
+	}
+	return sb.String()
+}
@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"strings"
+
+	"f5chat/llm"
+)
+
+// SetVerbose shows every field of each object in listings by default,
+// rather than one line per object
+func (i *Interface) SetVerbose(verbose bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.verbose = verbose
+}
+
+// detailed reports whether a listing shows every field of each object:
+// when asked "in detail" or with /verbose on
+func (i *Interface) detailed(call *llm.ToolCall) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.verbose || call.Arg(llm.Detail) == "true"
+}
+
+// verboseCommand handles "/verbose", "/verbose on" and "/verbose off"
+func (i *Interface) verboseCommand(query string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "/verbose")
+	if !ok || rest != "" && rest[0] != ' ' {
+		return "", false
+	}
+	switch strings.ToLower(strings.TrimSpace(rest)) {
+	case "":
+		i.mu.Lock()
+		verbose := i.verbose
+		i.mu.Unlock()
+		if verbose {
+			return "Listings show every field of each object. /verbose off lists one line per object.", true
+		}
+		return "Listings show one line per object; ask for them \"in detail\" for every field, or use /verbose on.", true
+	case "on", "true", "yes":
+		i.SetVerbose(true)
+		return "Listings now show every field of each object.", true
+	case "off", "false", "no":
+		i.SetVerbose(false)
+		return "Listings now show one line per object; ask for them \"in detail\" for every field.", true
+	}
+	return "Use /verbose on or /verbose off.", true
+}
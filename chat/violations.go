@@ -0,0 +1,218 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/llm"
+	"f5chat/prompt"
+)
+
+// violationLimit is how many of the latest logged violations are triaged
+const violationLimit = 500
+
+// violationGroup is the requests of one attack on one URL that the WAF
+// handled the same way
+type violationGroup struct {
+	attack, method, path, status string
+	count                        int
+	// sources counts the requests from each client address
+	sources    map[string]int
+	violations []string
+	rating     int
+}
+
+// wafViolations triages the requests the WAF recently blocked or alerted
+// on. They are grouped here, so the digest is the same with or without an
+// LLM; the LLM then summarizes the groups and recommends what to do.
+func (i *Interface) wafViolations(call *llm.ToolCall) (string, error) {
+	hours := 0
+	if h := strings.TrimSpace(call.Arg("hours")); h != "" {
+		n, err := strconv.Atoi(h)
+		if err != nil || n <= 0 {
+			return "How far back should I look? Give a number of hours, e.g. 'WAF violations in the last 24 hours'.", nil
+		}
+		hours = n
+	}
+	policy := strings.Trim(call.Arg("policy"), "\"'`")
+
+	logged, err := i.bigipClient.GetViolations(violationLimit)
+	if unprovisioned(err) {
+		return "ASM isn't provisioned on this device, so there are no WAF violations to triage.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var since time.Time
+	if hours > 0 {
+		since = time.Now().Add(-time.Duration(hours) * time.Hour)
+	}
+	var violations []bigip.Violation
+	for _, v := range logged {
+		if hours > 0 && v.Time.Before(since) {
+			continue
+		}
+		if policy != "" && !strings.EqualFold(v.Policy, policy) && !strings.EqualFold(v.Policy[strings.LastIndex(v.Policy, "/")+1:], policy) {
+			continue
+		}
+		violations = append(violations, v)
+	}
+
+	scope := ""
+	if hours > 0 {
+		scope += " in the " + lastHours(hours)
+	}
+	if policy != "" {
+		scope += " by policy " + policy
+	}
+	if len(violations) == 0 {
+		return "The WAF hasn't blocked or alerted on any requests" + scope + ".", nil
+	}
+	i.setData(violations)
+
+	groups := groupViolations(violations)
+	digest := formatViolations(violations, groups, hours, len(logged) == violationLimit)
+	summary, err := i.llmClient.RunTask(prompt.WAFViolations, digest)
+	if errors.Is(err, llm.ErrNoLLM) {
+		i.forwardViolations(violations, groups, hours, policy, "")
+		return digest + "\n(A summary with recommended actions needs an LLM; run without -no-llm to get one.)", nil
+	}
+	if err != nil {
+		slog.Warn("Failed to summarize WAF violations", "violations", len(violations), "err", err)
+		i.forwardViolations(violations, groups, hours, policy, "")
+		return digest + fmt.Sprintf("\nI couldn't summarize them right now (%v).", err), nil
+	}
+	summary = strings.TrimSpace(summary)
+	i.forwardViolations(violations, groups, hours, policy, summary)
+	return digest + "\n" + summary, nil
+}
+
+// lastHours is "last hour" or "last 6 hours"
+func lastHours(hours int) string {
+	if hours == 1 {
+		return "last hour"
+	}
+	return fmt.Sprintf("last %d hours", hours)
+}
+
+// groupViolations groups the requests by attack, method, path and what the
+// WAF did, largest group first. The query string is left out of the path,
+// so a probe with changing parameters is one group.
+func groupViolations(violations []bigip.Violation) []*violationGroup {
+	byKey := map[string]*violationGroup{}
+	var groups []*violationGroup
+	for _, v := range violations {
+		attack := "Other"
+		switch {
+		case len(v.AttackTypes) > 0:
+			attack = v.AttackTypes[0]
+		case len(v.Violations) > 0:
+			attack = v.Violations[0]
+		}
+		path, _, _ := strings.Cut(v.URL, "?")
+		key := strings.Join([]string{attack, v.Method, path, v.Status}, "\x00")
+		g := byKey[key]
+		if g == nil {
+			g = &violationGroup{attack: attack, method: v.Method, path: path, status: v.Status, sources: map[string]int{}}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.count++
+		g.sources[v.ClientIP]++
+		g.violations = appendNew(g.violations, v.Violations...)
+		g.rating = max(g.rating, v.Rating)
+	}
+	sort.SliceStable(groups, func(a, b int) bool { return groups[a].count > groups[b].count })
+	return groups
+}
+
+// appendNew appends the values not already in list
+func appendNew(list []string, values ...string) []string {
+	for _, v := range values {
+		if v != "" && !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// formatViolations lays out the digest of the violations: when they
+// happened, how many were blocked, and each group with its main sources
+func formatViolations(violations []bigip.Violation, groups []*violationGroup, hours int, truncated bool) string {
+	blocked, sources := 0, map[string]bool{}
+	var policies []string
+	oldest, newest := violations[0].Time, violations[0].Time
+	for _, v := range violations {
+		if v.Status == "blocked" {
+			blocked++
+		}
+		sources[v.ClientIP] = true
+		policies = appendNew(policies, v.Policy)
+		if v.Time.Before(oldest) {
+			oldest = v.Time
+		}
+		if v.Time.After(newest) {
+			newest = v.Time
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n=== WAF Violations ===\n")
+	sb.WriteString("----------------------------------------\n")
+	period := fmt.Sprintf("%s to %s UTC", oldest.UTC().Format("2006-01-02 15:04"), newest.UTC().Format("2006-01-02 15:04"))
+	switch {
+	case hours > 0:
+		period = lastHours(hours) + ", " + period
+	case truncated:
+		period = fmt.Sprintf("latest %d requests logged, %s", len(violations), period)
+	}
+	sb.WriteString(fmt.Sprintf("%-16s %s\n", "Period", period))
+	sb.WriteString(fmt.Sprintf("%-16s %d flagged: %d blocked, %d alerted\n", "Requests", len(violations), blocked, len(violations)-blocked))
+	sb.WriteString(fmt.Sprintf("%-16s %d %s\n", "Sources", len(sources), plural("client IP", len(sources))))
+	sb.WriteString(fmt.Sprintf("%-16s %s\n", "Policies", strings.Join(policies, ", ")))
+	sb.WriteString("----------------------------------------\n")
+	sb.WriteString("Groups, largest first:\n")
+	for _, g := range groups {
+		share := ""
+		switch {
+		case g.status == "blocked" && blocked > 0:
+			share = fmt.Sprintf(" (%d%% of blocks)", 100*g.count/blocked)
+		case g.status != "blocked" && len(violations) > blocked:
+			share = fmt.Sprintf(" (%d%% of alerts)", 100*g.count/(len(violations)-blocked))
+		}
+		sb.WriteString(fmt.Sprintf("%-9s %d%s  %s  %s %s\n", "["+strings.ToUpper(g.status)+"]", g.count, share, g.attack, g.method, g.path))
+		sb.WriteString(fmt.Sprintf("%-9s from %s; rating %d; %s\n", "", topSources(g.sources, 3), g.rating, strings.Join(g.violations, ", ")))
+	}
+	return sb.String()
+}
+
+// topSources names the n addresses behind the most requests, with their
+// counts, and how many others there were
+func topSources(sources map[string]int, n int) string {
+	ips := make([]string, 0, len(sources))
+	for ip := range sources {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(a, b int) bool {
+		if sources[ips[a]] != sources[ips[b]] {
+			return sources[ips[a]] > sources[ips[b]]
+		}
+		return ips[a] < ips[b]
+	})
+	var parts []string
+	for _, ip := range ips[:min(n, len(ips))] {
+		parts = append(parts, fmt.Sprintf("%s (%d)", ip, sources[ip]))
+	}
+	out := fmt.Sprintf("%d %s: %s", len(ips), plural("IP", len(ips)), strings.Join(parts, ", "))
+	if len(ips) > n {
+		out += fmt.Sprintf(" and %d more", len(ips)-n)
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package chat
+
+import (
+	"log/slog"
+	"time"
+)
+
+// warmer is a BigIPClient that can fill its response cache ahead of the
+// queries that read it
+type warmer interface {
+	Warm() error
+}
+
+// WarmCache reads the virtual servers, pools, nodes and WAF policies into
+// the BIG-IP client's response cache in the background, now and then a
+// little before each ttl runs out until stop is closed, so Tab completion,
+// names matched in queries and "how many" answers don't wait on the
+// device. Switching to another profile warms its client at once.
+func (i *Interface) WarmCache(ttl time.Duration, stop <-chan struct{}) {
+	if ttl <= 0 {
+		slog.Warn("Not warming the response cache: caching is off (BIGIP_CACHE_TTL=0)")
+		return
+	}
+	wake := make(chan struct{}, 1)
+	i.mu.Lock()
+	i.warmNow = wake
+	i.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(ttl - ttl/10)
+		defer ticker.Stop()
+		for {
+			i.mu.Lock()
+			client := i.bigipClient
+			i.mu.Unlock()
+			if w, ok := client.(warmer); ok {
+				if err := w.Warm(); err != nil {
+					slog.Warn("Failed to warm the response cache", "err", err)
+				}
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// warmSoon has WarmCache warm the client in use now rather than at its next
+// turn, if it's warming at all
+func (i *Interface) warmSoon() {
+	i.mu.Lock()
+	wake := i.warmNow
+	i.mu.Unlock()
+	if wake == nil {
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,158 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"f5chat/anomaly"
+	"f5chat/utils"
+)
+
+// DefaultWatchInterval is how often "watch QUERY" asks when no interval
+// is given
+const DefaultWatchInterval = 30 * time.Second
+
+// minWatchInterval keeps a watch from hammering the device
+const minWatchInterval = 2 * time.Second
+
+// watchQuery matches "watch pool web_pool" and "watch the nodes every 10s"
+var watchQuery = regexp.MustCompile(`(?i)^\s*watch\s+(.+?)(?:\s+every\s+(\d+)\s*(s|secs?|seconds?|m|mins?|minutes?))?[\s.!]*$`)
+
+// ParseWatch recognises "watch QUERY [every N seconds|minutes]", returning
+// the query to watch and how often to ask it
+func ParseWatch(query string) (string, time.Duration, bool) {
+	m := watchQuery.FindStringSubmatch(query)
+	if m == nil {
+		return "", 0, false
+	}
+	every := DefaultWatchInterval
+	if m[2] != "" {
+		n, _ := strconv.Atoi(m[2])
+		unit := time.Second
+		if strings.HasPrefix(strings.ToLower(m[3]), "m") {
+			unit = time.Minute
+		}
+		every = time.Duration(n) * unit
+	}
+	return m[1], every, true
+}
+
+// Watch asks query every interval until stop is closed, passing show the
+// first answer in full and after that only what changed: a unified diff
+// between the two latest answers, or a line saying nothing did. Device
+// responses aren't served from the cache, and failures are shown and
+// watching carries on, since a watch is often kept through an outage. With
+// a notifier set, each check also sends any alerts the device has, and
+// with anomaly detection on, unusual connection rates and CPU usage are
+// shown and sent too. A change in the device's failover state is shown,
+// sent and marked on Grafana's graphs.
+func (i *Interface) Watch(query string, every time.Duration, stop <-chan struct{}, show func(string)) {
+	every = max(every, minWatchInterval)
+	i.recordQuery("watch " + query)
+
+	var last []string
+	var lastAt time.Time
+	ask := func() {
+		i.bigipClient.ClearCache()
+		at := time.Now()
+		i.watchAlerts()
+		unusual := i.watchAnomalies(at)
+		failover := i.watchFailover(at)
+		response, err := i.process(query)
+		i.takeOperations()
+		defer func() {
+			if failover != "" {
+				show(fmt.Sprintf("%s  Failover: %s", at.Format("15:04:05"), failover))
+			}
+			for _, a := range unusual {
+				show(fmt.Sprintf("%s  Unusual: %s", at.Format("15:04:05"), a))
+			}
+		}()
+		if err != nil {
+			show(fmt.Sprintf("%s  Error: %v", at.Format("15:04:05"), err))
+			return
+		}
+		// Suggestions aren't part of what's watched
+		response, _, _ = strings.Cut(response, followUpsHeading)
+		lines := strings.Split(strings.TrimSpace(response), "\n")
+		switch {
+		case last == nil:
+			show(fmt.Sprintf("=== Watching %q every %s (%s) ===\n\n%s", query, every, at.Format("15:04:05"), strings.Join(lines, "\n")))
+		default:
+			diff := utils.FormatUnified(lastAt.Format("15:04:05"), at.Format("15:04:05"), last, lines, 1)
+			if diff == "" {
+				show(fmt.Sprintf("%s  No changes.", at.Format("15:04:05")))
+				lastAt = at
+				return
+			}
+			show(fmt.Sprintf("%s  Changed since %s:\n%s", at.Format("15:04:05"), lastAt.Format("15:04:05"), strings.TrimRight(diff, "\n")))
+		}
+		last, lastAt = lines, at
+	}
+
+	ask()
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ask()
+		}
+	}
+}
+
+// watchAlerts sends the device's alerts during a watch, if there's a
+// notifier to send them through; the router drops repeats
+func (i *Interface) watchAlerts() {
+	i.mu.Lock()
+	router := i.notifier
+	i.mu.Unlock()
+	if router == nil {
+		return
+	}
+	if _, err := i.sendAlerts("watch"); err != nil {
+		slog.Warn("Alert checks failed while watching", "err", err)
+	}
+}
+
+// SetAnomalies has watch mode compare each round's connection rates and
+// CPU usage with what they usually are at that hour, using d's baselines
+func (i *Interface) SetAnomalies(d *anomaly.Detector) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.anomalies = d
+}
+
+// watchAnomalies checks the device for unusual readings during a watch,
+// sending them through the notifier if there is one, and returns them to
+// be shown
+func (i *Interface) watchAnomalies(at time.Time) []anomaly.Anomaly {
+	i.mu.Lock()
+	d, router, device, client := i.anomalies, i.notifier, i.notifierDevice, i.bigipClient
+	i.mu.Unlock()
+	if d == nil {
+		return nil
+	}
+	found, err := d.Check(device, client, at)
+	if err != nil {
+		slog.Warn("Anomaly checks failed while watching", "err", err)
+	}
+	if router == nil {
+		return found
+	}
+	for _, a := range found {
+		event := a.Event()
+		event.Source, event.Device, event.Time = "watch", device, at
+		if err := router.Notify(context.Background(), event); err != nil {
+			slog.Warn("Failed to send an anomaly", "key", event.Key, "err", err)
+		}
+	}
+	return found
+}
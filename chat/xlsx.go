@@ -0,0 +1,197 @@
+package chat
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sheet is a table as a worksheet of a workbook
+type sheet struct {
+	name string
+	*table
+}
+
+// xlsxNumber matches cells written as numbers rather than text, so they sort
+// and sum: integers short enough for Excel not to round
+var xlsxNumber = regexp.MustCompile(`^-?(?:0|[1-9][0-9]{0,14})$`)
+
+// xlsxMaxWidth caps a column's width, in characters
+const xlsxMaxWidth = 60
+
+// The parts of a workbook that don't depend on its sheets. The one style
+// besides the default is the header row's bold.
+const (
+	xlsxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`
+)
+
+// writeXLSX writes sheets as an Office Open XML workbook, one worksheet
+// each, with the header row in bold, frozen and filterable, and columns as
+// wide as what's in them
+func writeXLSX(sheets []sheet) ([]byte, error) {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes(len(sheets))},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(sheets))},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for n, s := range sheets {
+		parts = append(parts, struct{ name, content string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", n+1), xlsxSheet(s.table)})
+	}
+	for _, part := range parts {
+		w, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func xlsxContentTypes(sheets int) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for n := 1; n <= sheets; n++ {
+		fmt.Fprintf(&sb, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+	}
+	sb.WriteString(`</Types>`)
+	return sb.String()
+}
+
+func xlsxWorkbook(sheets []sheet) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for n, s := range sheets {
+		fmt.Fprintf(&sb, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(s.name), n+1, n+1)
+	}
+	// Excel keeps each sheet's filtered range as a hidden name
+	sb.WriteString(`</sheets><definedNames>`)
+	for n, s := range sheets {
+		fmt.Fprintf(&sb, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">%s!%s</definedName>`,
+			n, xmlEscape("'"+strings.ReplaceAll(s.name, "'", "''")+"'"), xlsxRange(s.table, true))
+	}
+	sb.WriteString(`</definedNames></workbook>`)
+	return sb.String()
+}
+
+func xlsxWorkbookRels(sheets int) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for n := 1; n <= sheets; n++ {
+		fmt.Fprintf(&sb, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	// The styles come after the sheets, so the sheets' IDs match their numbers
+	fmt.Fprintf(&sb, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	sb.WriteString(`</Relationships>`)
+	return sb.String()
+}
+
+// xlsxSheet writes a table as a worksheet, its text inline rather than in
+// a shared strings part
+func xlsxSheet(t *table) string {
+	widths := make([]int, len(t.header))
+	for n, h := range t.header {
+		widths[n] = len(h)
+	}
+	for _, row := range t.rows {
+		for n, cell := range row {
+			if n < len(widths) {
+				widths[n] = max(widths[n], min(len(cell), xlsxMaxWidth))
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	sb.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	sb.WriteString(`<cols>`)
+	for n, w := range widths {
+		fmt.Fprintf(&sb, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, n+1, n+1, w+2)
+	}
+	sb.WriteString(`</cols><sheetData>`)
+	writeRow := func(r int, cells []string, style int) {
+		fmt.Fprintf(&sb, `<row r="%d">`, r)
+		for n, cell := range cells {
+			ref := xlsxColumn(n) + strconv.Itoa(r)
+			switch {
+			case cell == "":
+			case style == 0 && xlsxNumber.MatchString(cell):
+				fmt.Fprintf(&sb, `<c r="%s"><v>%s</v></c>`, ref, cell)
+			default:
+				fmt.Fprintf(&sb, `<c r="%s" t="inlineStr"`, ref)
+				if style != 0 {
+					fmt.Fprintf(&sb, ` s="%d"`, style)
+				}
+				fmt.Fprintf(&sb, `><is><t xml:space="preserve">%s</t></is></c>`, xmlEscape(cell))
+			}
+		}
+		sb.WriteString(`</row>`)
+	}
+	writeRow(1, t.header, 1)
+	for n, row := range t.rows {
+		writeRow(n+2, row, 0)
+	}
+	sb.WriteString(`</sheetData>`)
+	fmt.Fprintf(&sb, `<autoFilter ref="%s"/>`, xlsxRange(t, false))
+	sb.WriteString(`</worksheet>`)
+	return sb.String()
+}
+
+// xlsxRange is the cells a table fills, "A1:G4", or with absolute set
+// "$A$1:$G$4"
+func xlsxRange(t *table, absolute bool) string {
+	last, rows := xlsxColumn(max(len(t.header), 1)-1), len(t.rows)+1
+	if absolute {
+		return fmt.Sprintf("$A$1:$%s$%d", last, rows)
+	}
+	return fmt.Sprintf("A1:%s%d", last, rows)
+}
+
+// xlsxColumn is the letters of the nth column, counting from 0: A, ..., Z,
+// AA, AB and so on
+func xlsxColumn(n int) string {
+	name := ""
+	for n++; n > 0; n = (n - 1) / 26 {
+		name = string(rune('A'+(n-1)%26)) + name
+	}
+	return name
+}
+
+// xmlEscape escapes text for XML, dropping the control characters XML 1.0
+// can't hold
+func xmlEscape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"f5chat/chat"
+	"f5chat/config"
+	"f5chat/history"
+	"f5chat/lineedit"
+	"f5chat/logging"
+	"f5chat/prompt"
+	"f5chat/snapshot"
+	"f5chat/utils"
+)
+
+// chatCommand starts an interactive chat, or runs the setup checks or self
+// test and exits
+type chatCommand struct {
+	base
+	check, selfTest bool
+	exportPrompts   string
+}
+
+func newChatCommand() command {
+	c := &chatCommand{base: newBase("chat")}
+	c.flags.BoolVar(&c.check, "check", false, "run connectivity, credential, ASM and LLM checks, then exit")
+	c.flags.BoolVar(&c.selfTest, "selftest", false, "ask a set of verification queries, report which passed, then exit")
+	c.flags.StringVar(&c.exportPrompts, "export-prompts", "", "write the built-in prompts to this directory for editing, then exit")
+	return c
+}
+
+func (c *chatCommand) run(args []string) int {
+	if c.exportPrompts != "" {
+		written, err := prompt.WriteDefaults(c.exportPrompts)
+		if err != nil {
+			fatal("Failed to export prompts: %v", err)
+		}
+		for _, path := range written {
+			fmt.Println("Wrote", path)
+		}
+		fmt.Printf("Edit the files, then run with -prompts %s or PROMPT_DIR=%s\n", c.exportPrompts, c.exportPrompts)
+		return 0
+	}
+	s := openSession(c.shared)
+	code := 0
+	switch {
+	case c.check:
+		code = runReport(s.chat, []string{"health"}, s.answers, s.colorAnswers)
+	case c.selfTest:
+		code = runReport(s.chat, []string{"selftest"}, s.answers, s.colorAnswers)
+	default:
+		runChat(s.chat, s.cfg, s.color, s.saved)
+	}
+	return s.close(code)
+}
+
+// runChat runs the interactive session until the user exits. Answers are
+// copied to saved, if set, as well as shown.
+func runChat(chatInterface *chat.Interface, cfg *config.Config, color bool, saved io.Writer) {
+	// JSON and CSV answers have stdout to themselves, so they can be piped
+	// into jq or a file; everything else goes to stderr
+	rawOutput := chatInterface.OutputFormat() != chat.FormatText
+	console := io.Writer(os.Stdout)
+	if rawOutput {
+		console = os.Stderr
+	}
+	if !cfg.Quiet {
+		fmt.Fprintln(console, "Welcome to F5 BIG-IP Chat Interface!")
+		fmt.Fprintln(console, "Type 'exit' to quit, '/health' to check your setup, '/reset' to start a new conversation, '/history' to see earlier queries")
+		if cfg.LogFile != logging.Stderr {
+			fmt.Fprintf(console, "Diagnostics are logged to %s\n", cfg.LogFile)
+		}
+		fmt.Fprintln(console, "----------------------------------------")
+	}
+
+	var queries *history.Store
+	if cfg.QueryHistorySize > 0 {
+		var err error
+		queries, err = history.Open(cfg.QueryHistoryFile, cfg.QueryHistorySize)
+		if err != nil {
+			slog.Warn("Query history unavailable", "file", cfg.QueryHistoryFile, "err", err)
+		} else {
+			chatInterface.SetQueryHistory(queries)
+		}
+	}
+	if saved, err := history.OpenSaved(cfg.SavedQueriesFile); err != nil {
+		slog.Warn("Saved queries unavailable", "file", cfg.SavedQueriesFile, "err", err)
+	} else {
+		chatInterface.SetSavedQueries(saved)
+	}
+	// Demo data never changes, so its snapshots aren't worth keeping
+	snapshotFile := cfg.SnapshotFile
+	if cfg.Demo {
+		snapshotFile = ""
+	}
+	if snapshots, err := snapshot.Open(snapshotFile, cfg.BigIPHost); err != nil {
+		slog.Warn("Snapshots unavailable", "file", snapshotFile, "err", err)
+	} else {
+		chatInterface.SetSnapshots(snapshots)
+	}
+	if cfg.CacheWarm {
+		stopWarming := make(chan struct{})
+		defer close(stopWarming)
+		chatInterface.WarmCache(cfg.CacheTTL, stopWarming)
+	}
+	// Long listings are shown as they're read, ahead of the answer
+	chatInterface.SetStream(func(page string) {
+		if saved != nil {
+			fmt.Fprint(saved, page)
+		}
+		if color {
+			page = utils.Colorize(page)
+		}
+		fmt.Print(page)
+	})
+	reader := lineedit.New(queries.Queries)
+	reader.SetOutput(console)
+	reader.SetCompleter(chatInterface.Complete)
+
+	// Then continue with the normal interactive loop
+	for {
+		fmt.Fprintln(console)
+		input, err := reader.ReadLine("You: ")
+		if errors.Is(err, io.EOF) || errors.Is(err, lineedit.ErrInterrupted) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(console, "Error reading input: %v\n", err)
+			continue
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "exit" {
+			break
+		}
+		if query, every, ok := chat.ParseWatch(input); ok {
+			fmt.Println()
+			runWatch(chatInterface, query, every, os.Stdout, color)
+			continue
+		}
+
+		response, err := chatInterface.ProcessQuery(input)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		printAnswer(os.Stdout, chatInterface, response, color, "BIG-IP: ")
+		if saved != nil {
+			printAnswer(saved, chatInterface, response, false, "")
+		}
+	}
+}
+
+// printAnswer writes an answer to w. JSON, CSV, Markdown and YAML are
+// written as they are; text follows label and is highlighted if color is set.
+func printAnswer(w io.Writer, chatInterface *chat.Interface, response string, color bool, label string) {
+	if format := chatInterface.OutputFormat(); format != chat.FormatText {
+		if format == chat.FormatMarkdown {
+			// A blank line keeps answers apart when they're saved together
+			response += "\n"
+		}
+		fmt.Fprintln(w, response)
+		return
+	}
+	if color {
+		response = utils.Colorize(response)
+	}
+	if label == "" {
+		fmt.Fprintln(w, strings.TrimSpace(response))
+		return
+	}
+	fmt.Fprintf(w, "\n%s%s\n", label, response)
+}
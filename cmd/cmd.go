@@ -0,0 +1,126 @@
+// Package cmd is chatf5's command line: each command is a type with its own
+// flags, run by Main with the arguments left once they're parsed.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"f5chat/chat"
+)
+
+// usage describes chatf5's commands; each has its own -h for its flags
+const usage = `Usage:
+  chatf5 [chat] [flags]          start an interactive chat (the default)
+  chatf5 query [flags] QUERY     answer one query and exit
+  chatf5 script [flags] [FILE]   answer the queries in FILE (or stdin) in turn and exit
+  chatf5 report [flags] REPORT   print a report and exit: alerts, certs, compliance, health, selftest or summary
+  chatf5 exporter [flags]        serve the device's metrics to Prometheus until stopped
+  chatf5 schedule [flags]        make the reports in REPORT_SCHEDULES when they're due, until stopped
+  chatf5 config validate         check the settings, and that the devices can be reached, then exit
+
+Run 'chatf5 COMMAND -h' for the command's flags.
+`
+
+// Exit codes of query and script, so automation wrapping chatf5 can branch
+// on why a query failed
+const (
+	exitFailed        = 1 // any failure not listed below
+	exitUsage         = 2 // missing or invalid arguments
+	exitAuth          = 3 // the device rejected the credentials
+	exitUnreachable   = 4 // the device couldn't be reached
+	exitNotUnderstood = 5 // the query didn't map onto an operation
+	exitNotFound      = 6 // a named object doesn't exist
+	exitLLM           = 7 // the LLM API is down or over a spend limit
+)
+
+// exitCodes maps the kinds of failure the chat reports to exit codes
+var exitCodes = map[string]int{
+	chat.FailureAuth:          exitAuth,
+	chat.FailureUnreachable:   exitUnreachable,
+	chat.FailureNotUnderstood: exitNotUnderstood,
+	chat.FailureNotFound:      exitNotFound,
+	chat.FailureLLM:           exitLLM,
+}
+
+// command is one of chatf5's commands
+type command interface {
+	// flagSet is the command's flags, the shared ones among them
+	flagSet() *flag.FlagSet
+	// run does the command with the arguments left once the flags are
+	// parsed, returning the exit code
+	run(args []string) int
+}
+
+// commands make each command by name
+var commands = map[string]func() command{
+	"chat":     newChatCommand,
+	"query":    newQueryCommand,
+	"script":   newScriptCommand,
+	"report":   newReportCommand,
+	"exporter": newExporterCommand,
+	"schedule": newScheduleCommand,
+	"config":   newConfigCommand,
+}
+
+// Main runs the command args name, the chat if they start with a flag or
+// are empty, and returns the exit code
+func Main(args []string) int {
+	// Without a command, flags alone start a chat as they always have
+	name := "chat"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Print(usage)
+		return 0
+	}
+	newCommand, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", name, usage)
+		return exitUsage
+	}
+	c := newCommand()
+	return c.run(parseFlags(c.flagSet(), args))
+}
+
+// base is what every command has: its flags, and the shared ones among them
+type base struct {
+	flags  *flag.FlagSet
+	shared *sharedFlags
+}
+
+// newBase makes the flags of the command called name, with the shared ones
+func newBase(name string) base {
+	flags := flag.NewFlagSet("chatf5 "+name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "%s\nFlags of %s:\n", usage, flags.Name())
+		flags.PrintDefaults()
+	}
+	return base{flags: flags, shared: newSharedFlags(flags)}
+}
+
+func (b base) flagSet() *flag.FlagSet { return b.flags }
+
+// parseFlags parses flags wherever they are among args, as in
+// "chatf5 report certs -demo", returning the other arguments
+func parseFlags(flags *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return rest
+		}
+		rest, args = append(rest, args[0]), args[1:]
+	}
+}
+
+// fatal reports a startup error on the terminal, where the user will see
+// it even when diagnostics go to the log file, then exits
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
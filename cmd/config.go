@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"f5chat/bigip"
+	"f5chat/chat"
+	"f5chat/compliance"
+	"f5chat/config"
+	"f5chat/grafana"
+	"f5chat/ihealth"
+	"f5chat/lineedit"
+	"f5chat/llm"
+	"f5chat/notify"
+	"f5chat/schedule"
+	"f5chat/telemetry"
+	"f5chat/utils"
+)
+
+// configCommand checks the settings, and that the devices can be reached
+type configCommand struct {
+	base
+	offline bool
+}
+
+func newConfigCommand() command {
+	c := &configCommand{base: newBase("config")}
+	c.flags.BoolVar(&c.offline, "offline", false, "don't try to connect to the devices")
+	return c
+}
+
+func (c *configCommand) run(args []string) int {
+	c.shared.apply()
+	return runConfig(args, c.shared.device, c.offline)
+}
+
+// runConfig runs "config validate", which checks the settings before a
+// session is started: those Validate checks, those the packages using them
+// check, and, unless offline, that each device answers on its management
+// port
+func runConfig(args []string, device string, offline bool) int {
+	if len(args) != 1 || args[0] != "validate" {
+		fmt.Fprintf(os.Stderr, "Usage: chatf5 config validate [flags]\n")
+		return exitUsage
+	}
+	checks, loaded := config.Validate()
+	if len(loaded) > 0 {
+		cfg := loaded[0]
+		if cfg.Profile == os.Getenv("CHATF5_PROFILE") {
+			useDevice(cfg, device)
+		}
+		if problems := checkOptions(cfg); len(problems) > 0 {
+			checks = append(checks, config.Check{Name: "Options", Status: "FAIL", Detail: strings.Join(problems, "; ")})
+		} else {
+			checks = append(checks, config.Check{Name: "Options", Status: "PASS", Detail: "LLM provider, output, templates, notifications and schedules are valid"})
+		}
+	}
+	switch {
+	case len(loaded) > 0 && loaded[0].Demo:
+		checks = append(checks, config.Check{Name: "Reachability", Status: "SKIP", Detail: "demo mode asks no device"})
+	case offline:
+		checks = append(checks, config.Check{Name: "Reachability", Status: "SKIP", Detail: "not tried (-offline)"})
+	default:
+		checks = append(checks, reachChecks(loaded)...)
+	}
+
+	report := utils.FormatConfigChecks(checks)
+	if os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && lineedit.IsTerminal(os.Stdout) {
+		report = utils.Colorize(report)
+	}
+	fmt.Print(report)
+	for _, c := range checks {
+		if c.Status == "FAIL" {
+			return exitFailed
+		}
+	}
+	return 0
+}
+
+// checkOptions returns what's wrong with the settings setup would refuse
+func checkOptions(cfg *config.Config) []string {
+	var problems []string
+	if _, err := llm.New(cfg); err != nil {
+		problems = append(problems, fmt.Sprintf("LLM provider: %v", err))
+	}
+	if _, err := llm.ParseRisk(cfg.GuardrailMaxRisk); err != nil {
+		problems = append(problems, fmt.Sprintf("GUARDRAIL_MAX_RISK: %v", err))
+	}
+	if err := chat.NewInterface(nil, nil).SetOutputFormat(cfg.OutputFormat); err != nil {
+		problems = append(problems, fmt.Sprintf("OUTPUT_FORMAT: %v", err))
+	}
+	if _, err := utils.LoadTemplates(cfg.TemplateDir); err != nil {
+		problems = append(problems, fmt.Sprintf("TEMPLATE_DIR: %v", err))
+	}
+	if _, err := notify.NewRouterFromConfig(cfg); err != nil {
+		problems = append(problems, fmt.Sprintf("notifications: %v", err))
+	}
+	if _, err := grafana.NewFromConfig(cfg); err != nil {
+		problems = append(problems, fmt.Sprintf("Grafana: %v", err))
+	}
+	if _, err := ihealth.NewFromConfig(cfg); err != nil {
+		problems = append(problems, fmt.Sprintf("iHealth: %v", err))
+	}
+	if err := telemetry.Check(cfg); err != nil {
+		problems = append(problems, fmt.Sprintf("tracing: %v", err))
+	}
+	if _, err := compliance.Load(cfg.ComplianceRules); err != nil {
+		problems = append(problems, fmt.Sprintf("COMPLIANCE_RULES: %v", err))
+	}
+	if _, err := openAnomalies(cfg); err != nil {
+		problems = append(problems, fmt.Sprintf("ANOMALY_BASELINES: %v", err))
+	}
+	if _, err := schedule.ParseJobs(cfg.ReportSchedules); err != nil {
+		problems = append(problems, fmt.Sprintf("REPORT_SCHEDULES: %v", err))
+	}
+	return problems
+}
+
+// reachChecks opens a TCP connection to each device the configurations
+// ask, all at once, as the health check's reachability check does
+func reachChecks(loaded []*config.Config) []config.Check {
+	type target struct {
+		name, host string
+		timeout    time.Duration
+	}
+	var targets []target
+	seen := map[string]bool{}
+	for _, cfg := range loaded {
+		timeout := cfg.BigIPDialTimeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+		name := "Reach " + cfg.BigIPHost
+		if cfg.Profile != "" {
+			name = "Reach " + cfg.Profile
+		}
+		devices := []target{{name, cfg.BigIPHost, timeout}}
+		for _, d := range cfg.Devices {
+			devices = append(devices, target{"Reach " + d.Name, d.Host, timeout})
+		}
+		for _, t := range devices {
+			if t.host != "" && !seen[t.host] {
+				seen[t.host] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+
+	checks := make([]config.Check, len(targets))
+	var wg sync.WaitGroup
+	for n, t := range targets {
+		wg.Add(1)
+		go func(n int, t target) {
+			defer wg.Done()
+			check := config.Check{Name: t.name, Status: "FAIL"}
+			host, port, err := bigip.ParseHostPort(t.host)
+			if err != nil {
+				check.Detail = err.Error()
+				checks[n] = check
+				return
+			}
+			addr := net.JoinHostPort(host, port)
+			conn, err := net.DialTimeout("tcp", addr, t.timeout)
+			if err != nil {
+				check.Detail = fmt.Sprintf("cannot open TCP connection to %s: %v. Check the host and port, and that the management interface can be reached from here", addr, err)
+				checks[n] = check
+				return
+			}
+			conn.Close()
+			checks[n] = config.Check{Name: t.name, Status: "PASS", Detail: fmt.Sprintf("TCP connection to %s succeeded", addr)}
+		}(n, t)
+	}
+	wg.Wait()
+	return checks
+}
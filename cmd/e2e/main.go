@@ -0,0 +1,50 @@
+// Command e2e runs the end-to-end chat scenarios against a fake iControl REST
+// server and a fake LLM, exiting non-zero when any scenario fails. go test
+// runs the same scenarios (see e2e.TestScenarios); this lists every result.
+//
+//	go run ./cmd/e2e
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"f5chat/e2e"
+	"f5chat/logging"
+)
+
+func main() {
+	verbose := flag.Bool("v", false, "show client logs while running scenarios")
+	flag.Parse()
+
+	if *verbose {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	} else {
+		logging.Discard()
+	}
+
+	results, err := e2e.Run(e2e.DefaultScenarios)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "e2e setup failed: %v\n", err)
+		os.Exit(2)
+	}
+
+	failed := 0
+	for _, r := range results {
+		status := "PASS"
+		if !r.Passed {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s  %-45s %v\n", status, r.Scenario, r.Duration.Round(1e6))
+		if r.Detail != "" {
+			fmt.Printf("      %s\n", r.Detail)
+		}
+	}
+	fmt.Printf("\n%d/%d scenarios passed\n", len(results)-failed, len(results))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
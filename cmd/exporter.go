@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"f5chat/anomaly"
+	"f5chat/config"
+	"f5chat/exporter"
+	"f5chat/metrics"
+	"f5chat/notify"
+)
+
+// exporterCommand serves the device's metrics to Prometheus until stopped
+type exporterCommand struct {
+	base
+	listen   string
+	interval time.Duration
+}
+
+func newExporterCommand() command {
+	c := &exporterCommand{base: newBase("exporter")}
+	c.flags.StringVar(&c.listen, "listen", "", "address to serve /metrics on (default METRICS_ADDR, or :9100)")
+	c.flags.DurationVar(&c.interval, "interval", 30*time.Second, "how often to scrape the device")
+	// Scraping never asks an LLM, so it needs no API key
+	c.flags.Set("no-llm", "true")
+	return c
+}
+
+func (c *exporterCommand) run(args []string) int {
+	c.shared.apply()
+	cfg, closeLog := loadConfig(c.shared.device)
+	code := runExporter(connect(cfg).(exporter.Device), cfg, c.listen, c.interval)
+	closeLog()
+	return code
+}
+
+// runExporter serves the device's metrics on listen, the address in
+// METRICS_ADDR or :9100 if empty, scraping them every interval until
+// Ctrl-C or SIGTERM
+func runExporter(device exporter.Device, cfg *config.Config, listen string, interval time.Duration) int {
+	if listen == "" {
+		listen = cfg.MetricsAddr
+	}
+	if listen == "" {
+		listen = ":9100"
+	}
+	if interval <= 0 {
+		fmt.Fprintf(os.Stderr, "The scrape interval must be positive, e.g. -interval 30s\n")
+		return exitUsage
+	}
+	if err := metrics.Listen(listen); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to serve metrics: %v\n", err)
+		return exitFailed
+	}
+	name := cfg.BigIPHost
+	if cfg.Demo {
+		name = "demo"
+	}
+
+	exp := exporter.New(device, name)
+	detector, err := openAnomalies(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load the anomaly baselines: %v\n", err)
+		return exitFailed
+	}
+	if detector != nil {
+		router, err := notify.NewRouterFromConfig(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid notification settings: %v\n", err)
+			return exitUsage
+		}
+		exp.SetAnomalies(detector, func(a anomaly.Anomaly) {
+			event := a.Event()
+			event.Source, event.Device, event.Time = "exporter", name, a.Time
+			if err := router.Notify(context.Background(), event); err != nil {
+				slog.Warn("Failed to send an anomaly", "key", event.Key, "err", err)
+			}
+		})
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	if !cfg.Quiet {
+		fmt.Fprintf(os.Stderr, "Exporting metrics of %s on %s/metrics every %s; Ctrl-C stops.\n", name, listen, interval)
+	}
+	exp.Run(interval, stop)
+	return 0
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"flag"
+	"os"
+)
+
+// sharedFlags are the flags every command takes. Most stand in for an
+// environment variable, which apply sets once they're parsed.
+type sharedFlags struct {
+	profile, configFile, device, out, logLevel, trace string
+	model, temperature, maxTokens, prompts, templates string
+	output                                            string
+
+	demo, quiet, noLLM, noColor, plan, agent bool
+	ignoreSpendLimits, allowDisruptive       bool
+}
+
+// newSharedFlags defines the shared flags among flags
+func newSharedFlags(flags *flag.FlagSet) *sharedFlags {
+	s := &sharedFlags{}
+	flags.StringVar(&s.profile, "profile", "", "ask the BIG-IP a profile in the configuration file describes, with its credentials and TLS settings (sets CHATF5_PROFILE)")
+	flags.StringVar(&s.configFile, "config", "", "read settings from this YAML file instead of ~/.chatf5/config.yaml; environment variables override it (sets CHATF5_CONFIG)")
+	flags.BoolVar(&s.demo, "demo", false, "use built-in demo data instead of connecting to a BIG-IP")
+	flags.StringVar(&s.device, "device", "", "BIG-IP to ask: a name from BIGIP_DEVICES or a host (overrides BIGIP_HOST)")
+	flags.StringVar(&s.out, "out", "", "write answers to this file, in the output format, instead of stdout")
+	flags.StringVar(&s.logLevel, "log-level", "", "log debug, info, warn or error messages and above (overrides LOG_LEVEL)")
+	flags.BoolVar(&s.quiet, "quiet", false, "show only prompts and answers: no greeting, and only errors when logging to the terminal (sets CHATF5_QUIET=true)")
+	flags.StringVar(&s.trace, "trace", "", "record every iControl REST request/response (credentials redacted) to this file")
+	flags.StringVar(&s.model, "model", "", "LLM model, e.g. gpt-4o (overrides LLM_MODEL)")
+	flags.StringVar(&s.temperature, "temperature", "", "LLM sampling temperature 0-2 (overrides LLM_TEMPERATURE)")
+	flags.StringVar(&s.maxTokens, "max-tokens", "", "maximum tokens per LLM response (overrides LLM_MAX_TOKENS)")
+	flags.StringVar(&s.prompts, "prompts", "", "directory of prompt files overriding the built-in prompts (overrides PROMPT_DIR)")
+	flags.BoolVar(&s.noLLM, "no-llm", false, "match common requests with fixed rules instead of an LLM; no API key needed")
+	flags.StringVar(&s.templates, "templates", "", "directory of .tmpl files laying out listings your way (overrides TEMPLATE_DIR)")
+	flags.StringVar(&s.output, "output", "", "write answers as text, json, csv, markdown or yaml (overrides OUTPUT_FORMAT)")
+	flags.BoolVar(&s.noColor, "no-color", false, "don't highlight statuses in color (sets NO_COLOR=1)")
+	flags.BoolVar(&s.plan, "plan", false, "show the iControl REST calls behind each answer and why (sets PLAN_PREVIEW=true)")
+	flags.BoolVar(&s.agent, "agent", false, "investigate open-ended questions with several read-only steps (sets AGENT_MODE=true)")
+	flags.BoolVar(&s.ignoreSpendLimits, "ignore-spend-limits", false, "keep calling the LLM after a spend limit is reached (sets LLM_IGNORE_SPEND_LIMITS=true)")
+	flags.BoolVar(&s.allowDisruptive, "allow-disruptive", false, "allow changes that can affect live traffic (sets GUARDRAIL_MAX_RISK=disruptive)")
+	return s
+}
+
+// apply sets the environment variables the flags given stand in for
+func (s *sharedFlags) apply() {
+	if s.configFile != "" {
+		os.Setenv("CHATF5_CONFIG", s.configFile)
+	}
+	if s.profile != "" {
+		os.Setenv("CHATF5_PROFILE", s.profile)
+	}
+	if s.demo {
+		os.Setenv("CHATF5_DEMO", "true")
+	}
+	if s.device != "" {
+		// A device name is swapped for its host once BIGIP_DEVICES is read
+		os.Setenv("BIGIP_HOST", s.device)
+	}
+	if s.logLevel != "" {
+		os.Setenv("LOG_LEVEL", s.logLevel)
+	}
+	if s.quiet {
+		os.Setenv("CHATF5_QUIET", "true")
+	}
+	if s.noLLM {
+		// Retrieval and the intent classifier need embeddings from an LLM API
+		os.Setenv("LLM_PROVIDER", "rules")
+		os.Setenv("LLM_FALLBACK_PROVIDER", "")
+		os.Setenv("RAG_ENABLED", "false")
+		os.Setenv("INTENT_CLASSIFIER", "false")
+	}
+	if s.allowDisruptive {
+		os.Setenv("GUARDRAIL_MAX_RISK", "disruptive")
+	}
+	if s.trace != "" {
+		os.Setenv("BIGIP_TRACE_FILE", s.trace)
+	}
+	if s.prompts != "" {
+		os.Setenv("PROMPT_DIR", s.prompts)
+	}
+	if s.templates != "" {
+		os.Setenv("TEMPLATE_DIR", s.templates)
+	}
+	if s.model != "" {
+		os.Setenv("LLM_MODEL", s.model)
+	}
+	if s.temperature != "" {
+		os.Setenv("LLM_TEMPERATURE", s.temperature)
+	}
+	if s.maxTokens != "" {
+		os.Setenv("LLM_MAX_TOKENS", s.maxTokens)
+	}
+	if s.agent {
+		os.Setenv("AGENT_MODE", "true")
+	}
+	if s.plan {
+		os.Setenv("PLAN_PREVIEW", "true")
+	}
+	if s.output != "" {
+		os.Setenv("OUTPUT_FORMAT", s.output)
+	}
+	if s.noColor {
+		os.Setenv("NO_COLOR", "1")
+	}
+	if s.ignoreSpendLimits {
+		os.Setenv("LLM_IGNORE_SPEND_LIMITS", "true")
+	}
+}
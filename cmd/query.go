@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"f5chat/chat"
+	"f5chat/utils"
+)
+
+// queryCommand answers one query and exits
+type queryCommand struct {
+	base
+	watch      time.Duration
+	jsonErrors bool
+}
+
+func newQueryCommand() command {
+	c := &queryCommand{base: newBase("query")}
+	c.flags.DurationVar(&c.watch, "watch", 0, "ask the query again at this interval, e.g. 30s, showing what changed, until Ctrl-C")
+	c.flags.BoolVar(&c.jsonErrors, "json-errors", false, "report failed queries on stderr as JSON objects naming the kind of failure")
+	return c
+}
+
+func (c *queryCommand) run(args []string) int {
+	s := openSession(c.shared)
+	return s.close(runQuery(s.chat, args, c.watch, c.jsonErrors, s.answers, s.colorAnswers))
+}
+
+// runQuery answers the query in args and reports in the exit code whether
+// it succeeded, and if not why
+func runQuery(chatInterface *chat.Interface, args []string, watch time.Duration, jsonErrors bool, w io.Writer, color bool) int {
+	query := strings.TrimSpace(strings.Join(args, " "))
+	if query == "" {
+		fmt.Fprintf(os.Stderr, "What should I ask? For example: chatf5 query \"show virtual servers\"\n")
+		return exitUsage
+	}
+	if watch > 0 {
+		runWatch(chatInterface, query, watch, w, color)
+		return 0
+	}
+	// Long listings are written as they're read, before the answer
+	chatInterface.SetStream(func(page string) {
+		if color {
+			page = utils.Colorize(page)
+		}
+		fmt.Fprint(w, strings.TrimPrefix(page, "\n"))
+	})
+	response, err := chatInterface.ProcessQuery(query)
+	code := failureCode(chatInterface, query, jsonErrors)
+	if err != nil {
+		if !jsonErrors {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return code
+	}
+	printAnswer(w, chatInterface, response, color, "")
+	return code
+}
+
+// failureCode returns the exit code for the query just answered, 0 if it
+// succeeded. With jsonErrors a failure is also reported on stderr as
+//
+//	{"error": {"code": "not_found", "exit_code": 6, "query": "...", "message": "..."}}
+func failureCode(chatInterface *chat.Interface, query string, jsonErrors bool) int {
+	kind, message := chatInterface.LastFailure()
+	if kind == "" {
+		return 0
+	}
+	code, ok := exitCodes[kind]
+	if !ok {
+		code = exitFailed
+	}
+	if jsonErrors {
+		var envelope struct {
+			Error struct {
+				Code     string `json:"code"`
+				ExitCode int    `json:"exit_code"`
+				Query    string `json:"query"`
+				Message  string `json:"message"`
+			} `json:"error"`
+		}
+		envelope.Error.Code, envelope.Error.ExitCode = kind, code
+		envelope.Error.Query, envelope.Error.Message = query, message
+		data, _ := json.Marshal(envelope)
+		fmt.Fprintln(os.Stderr, string(data))
+	}
+	return code
+}
+
+// runWatch asks query every interval, showing what changed each time,
+// until Ctrl-C
+func runWatch(chatInterface *chat.Interface, query string, every time.Duration, w io.Writer, color bool) {
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	fmt.Fprintln(os.Stderr, "Press Ctrl-C to stop watching.")
+	chatInterface.Watch(query, every, stop, func(update string) {
+		if color {
+			update = utils.Colorize(update)
+		}
+		fmt.Fprintf(w, "%s\n\n", update)
+	})
+}
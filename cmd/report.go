@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"f5chat/chat"
+	"f5chat/utils"
+)
+
+// reportCommand prints a report and exits
+type reportCommand struct {
+	base
+}
+
+func newReportCommand() command {
+	return &reportCommand{base: newBase("report")}
+}
+
+func (c *reportCommand) run(args []string) int {
+	s := openSession(c.shared)
+	return s.close(runReport(s.chat, args, s.answers, s.colorAnswers))
+}
+
+// runReport prints the report named in args: alerts, the conditions worth
+// notifying someone of, which are also sent to the notification routes;
+// certs, the SSL certificates by expiry; compliance, the configuration
+// against the compliance rules; health, the checks -check runs, or
+// selftest, the queries -selftest asks. The exit code is 1 when the report can't be made or a
+// check fails.
+func runReport(chatInterface *chat.Interface, args []string, w io.Writer, color bool) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Which report? Use: chatf5 report alerts|certs|compliance|health|selftest|summary\n")
+		return 2
+	}
+	show := func(report string) {
+		if color {
+			report = utils.Colorize(report)
+		}
+		fmt.Fprintln(w, strings.TrimSpace(report))
+	}
+	switch args[0] {
+	case "alerts":
+		report, ok := chatInterface.AlertReport()
+		show(report)
+		if !ok {
+			return 1
+		}
+	case "certs", "certificates":
+		report, err := chatInterface.CertificateReport()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		show(report)
+	case "compliance":
+		report, passed, err := chatInterface.ComplianceReport()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		show(report)
+		if !passed {
+			return 1
+		}
+	case "summary":
+		report, healthy := chatInterface.HealthSummary()
+		show(report)
+		if !healthy {
+			return 1
+		}
+	case "health":
+		report, healthy := chatInterface.HealthReport()
+		show(report)
+		if !healthy {
+			return 1
+		}
+	case "selftest":
+		report, passed := chatInterface.SelfTest()
+		show(report)
+		if !passed {
+			return 1
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown report %q; use alerts, certs, compliance, health, selftest or summary\n", args[0])
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"f5chat/chat"
+	"f5chat/config"
+	"f5chat/history"
+	"f5chat/notify"
+	"f5chat/schedule"
+)
+
+// scheduleCommand makes the scheduled reports when they're due, until
+// stopped
+type scheduleCommand struct {
+	base
+	once bool
+}
+
+func newScheduleCommand() command {
+	c := &scheduleCommand{base: newBase("schedule")}
+	c.flags.BoolVar(&c.once, "once", false, "make every scheduled report now, then exit")
+	return c
+}
+
+func (c *scheduleCommand) run(args []string) int {
+	s := openSession(c.shared)
+	return s.close(runSchedule(s.chat, s.cfg, c.once))
+}
+
+// runSchedule makes the reports in REPORT_SCHEDULES when they're due, until
+// Ctrl-C or SIGTERM, or all of them right away with once
+func runSchedule(chatInterface *chat.Interface, cfg *config.Config, once bool) int {
+	jobs, err := schedule.ParseJobs(cfg.ReportSchedules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid REPORT_SCHEDULES: %v\n", err)
+		return exitUsage
+	}
+	if len(jobs) == 0 {
+		fmt.Fprintf(os.Stderr, "No reports are scheduled; set REPORT_SCHEDULES, e.g. \"0 7 * * mon-fri certs > certs-{date}.txt\"\n")
+		return exitUsage
+	}
+	saved, err := history.OpenSaved(cfg.SavedQueriesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Saved queries unavailable: %v\n", err)
+		return exitFailed
+	}
+	chatInterface.SetSavedQueries(saved)
+	router := chatInterface.Notifier()
+	for _, job := range jobs {
+		if job.Report == "run" {
+			if _, ok := saved.Get(job.Saved); !ok {
+				fmt.Fprintf(os.Stderr, "Invalid REPORT_SCHEDULES: there is no saved query named %s\n", job.Saved)
+				return exitUsage
+			}
+		}
+		if name, ok := job.Webhook(); ok && !slices.Contains(router.Sinks(), name) {
+			fmt.Fprintf(os.Stderr, "Invalid REPORT_SCHEDULES: no webhook named %s in NOTIFY_WEBHOOKS\n", name)
+			return exitUsage
+		}
+		if list, ok := job.Email(); ok {
+			if _, err := emailSink(router, list); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid REPORT_SCHEDULES: %v\n", err)
+				return exitUsage
+			}
+		}
+	}
+
+	failed := 0
+	run := func(job schedule.Job, at time.Time) {
+		if err := makeReport(chatInterface, cfg, router, job, at); err != nil {
+			failed++
+			slog.Error("Scheduled report failed", "report", job.Name(), "err", err)
+			fmt.Fprintf(os.Stderr, "%s  %s failed: %v\n", at.Format("2006-01-02 15:04"), job.Name(), err)
+			return
+		}
+		slog.Info("Scheduled report made", "report", job.Name(), "target", job.Target)
+	}
+	if once {
+		for _, job := range jobs {
+			run(job, time.Now())
+		}
+		if failed > 0 {
+			return exitFailed
+		}
+		return 0
+	}
+
+	if !cfg.Quiet {
+		now := time.Now()
+		for _, job := range jobs {
+			target := job.Target
+			if target == "" {
+				target = "stdout"
+			}
+			next := "never"
+			if t := job.Cron.Next(now); !t.IsZero() {
+				next = t.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(os.Stderr, "%s (%s) to %s, next at %s\n", job.Name(), job.Spec, target, next)
+		}
+		fmt.Fprintln(os.Stderr, "Ctrl-C stops.")
+	}
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	schedule.Run(jobs, run, stop)
+	return 0
+}
+
+// makeReport makes a scheduled report and writes or sends it where the job
+// says
+func makeReport(chatInterface *chat.Interface, cfg *config.Config, router *notify.Router, job schedule.Job, at time.Time) error {
+	var report string
+	var err error
+	switch job.Report {
+	case "inventory":
+		report, err = chatInterface.InventoryReport()
+	case "certs":
+		report, err = chatInterface.CertificateReport()
+	case "down":
+		report, err = chatInterface.DownReport()
+	case "compliance":
+		report, _, err = chatInterface.ComplianceReport()
+	case "summary":
+		report, _ = chatInterface.HealthSummary()
+	case "alerts":
+		var ok bool
+		if report, ok = chatInterface.AlertReport(); !ok {
+			err = errors.New("some alert checks or notifications failed")
+		}
+	case "run":
+		report, err = chatInterface.ProcessQuery("/run " + job.Saved)
+	}
+	if err != nil && report == "" {
+		return err
+	}
+	report = fmt.Sprintf("Scheduled report: %s (%s)\n%s\n", job.Name(), at.Format("2006-01-02 15:04"), strings.TrimRight(report, "\n"))
+
+	event := notify.Event{
+		Key:     "report:" + job.Name(),
+		Title:   "Scheduled report: " + job.Name(),
+		Message: report,
+		Source:  "schedule",
+		Device:  cfg.BigIPHost,
+		Time:    at,
+	}
+	if name, ok := job.Webhook(); ok {
+		return errors.Join(err, router.SendTo(context.Background(), name, event))
+	}
+	if list, ok := job.Email(); ok {
+		sink, sinkErr := emailSink(router, list)
+		if sinkErr != nil {
+			return errors.Join(err, sinkErr)
+		}
+		return errors.Join(err, sink.Send(context.Background(), event))
+	}
+	if path := job.Path(at); path != "" {
+		if dir := filepath.Dir(path); dir != "." {
+			if mkErr := os.MkdirAll(dir, 0o755); mkErr != nil {
+				return errors.Join(err, mkErr)
+			}
+		}
+		return errors.Join(err, os.WriteFile(path, []byte(report), 0o644))
+	}
+	fmt.Println(report)
+	return err
+}
+
+// emailSink returns the email sink sending to the addresses in list, or to
+// NOTIFY_EMAIL_TO when list is empty
+func emailSink(router *notify.Router, list string) (*notify.EmailSink, error) {
+	sink, ok := router.Sink("email").(*notify.EmailSink)
+	if !ok {
+		return nil, errors.New("email isn't set up; set SMTP_HOST and SMTP_FROM")
+	}
+	to, err := notify.ParseEmailAddresses(list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email addresses: %v", err)
+	}
+	if len(to) > 0 {
+		sink = sink.WithRecipients(to)
+	}
+	if len(sink.Recipients()) == 0 {
+		return nil, errors.New("no one to email; set NOTIFY_EMAIL_TO or give addresses, e.g. email:ops@example.com")
+	}
+	return sink, nil
+}
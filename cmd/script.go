@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"f5chat/chat"
+	"f5chat/script"
+)
+
+// scriptCommand answers the queries of a script in turn and exits
+type scriptCommand struct {
+	base
+	jsonErrors bool
+}
+
+func newScriptCommand() command {
+	c := &scriptCommand{base: newBase("script")}
+	c.flags.BoolVar(&c.jsonErrors, "json-errors", false, "report failed queries on stderr as JSON objects naming the kind of failure")
+	return c
+}
+
+func (c *scriptCommand) run(args []string) int {
+	s := openSession(c.shared)
+	return s.close(runScript(s.chat, args, c.jsonErrors, s.answers, s.colorAnswers))
+}
+
+// runScript answers the queries of a script, read from the file in args
+// or from stdin, one after another, each labelled with its step. A failed
+// query doesn't stop the script, but the exit code is the first failure's.
+func runScript(chatInterface *chat.Interface, args []string, jsonErrors bool, w io.Writer, color bool) int {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Give one script file, or none to read stdin\n")
+		return exitUsage
+	}
+	var data []byte
+	var err error
+	if len(args) == 0 || args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read the script: %v\n", err)
+		return exitUsage
+	}
+	steps, err := script.Parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid script: %v\n", err)
+		return exitUsage
+	}
+
+	failed, firstCode := 0, 0
+	for n, step := range steps {
+		label := fmt.Sprintf("%d. %s", n+1, step.Label())
+		slog.Info("Running script step", "step", n+1, "of", len(steps), "query", step.Query)
+		response, err := chatInterface.ProcessQuery(step.Query)
+		if code := failureCode(chatInterface, step.Query, jsonErrors); code != 0 {
+			failed++
+			if firstCode == 0 {
+				firstCode = code
+			}
+		}
+		switch chatInterface.OutputFormat() {
+		case chat.FormatText:
+			fmt.Fprintf(w, "--- %s ---\n", label)
+		case chat.FormatMarkdown:
+			fmt.Fprintf(w, "### %s\n\n", label)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "Error: %v\n\n", err)
+			continue
+		}
+		printAnswer(w, chatInterface, response, color, "")
+		if chatInterface.OutputFormat() == chat.FormatText {
+			fmt.Fprintln(w)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d queries failed\n", failed, len(steps))
+		return firstCode
+	}
+	return 0
+}
@@ -0,0 +1,395 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"f5chat/anomaly"
+	"f5chat/audit"
+	"f5chat/bigip"
+	"f5chat/chat"
+	"f5chat/compliance"
+	"f5chat/config"
+	"f5chat/grafana"
+	"f5chat/ihealth"
+	"f5chat/journal"
+	"f5chat/lineedit"
+	"f5chat/llm"
+	"f5chat/logging"
+	"f5chat/metrics"
+	"f5chat/notify"
+	"f5chat/telemetry"
+	"f5chat/utils"
+)
+
+// session is a chat interface set up for a command that asks it, with
+// where the answers go
+type session struct {
+	chat *chat.Interface
+	cfg  *config.Config
+	// color is whether the terminal shows colors, and colorAnswers whether
+	// the answers are colored: not when they go to a file
+	color, colorAnswers bool
+	// answers is where answers are written, and saved the -out file the
+	// chat copies them to, if any
+	answers, saved io.Writer
+	out            string
+	outFile        *os.File
+	closeLog       func() error
+}
+
+// openSession applies the shared flags and sets up the chat interface,
+// with answers going to the -out file if one is given
+func openSession(shared *sharedFlags) *session {
+	shared.apply()
+	chatInterface, cfg, closeLog := setup(shared.device)
+	s := &session{chat: chatInterface, cfg: cfg, out: shared.out, closeLog: closeLog}
+	// Statuses are highlighted only on a terminal, so piped and redirected
+	// answers stay plain
+	s.color = !cfg.NoColor && os.Getenv("TERM") != "dumb" && lineedit.IsTerminal(os.Stdout)
+	// With -out, answers go to the file, uncolored; the chat shows them as
+	// well
+	s.answers = os.Stdout
+	if s.out != "" {
+		var err error
+		if s.outFile, err = os.Create(s.out); err != nil {
+			fatal("Failed to create the output file: %v", err)
+		}
+		s.answers, s.saved = s.outFile, s.outFile
+	}
+	s.colorAnswers = s.color && s.outFile == nil
+	return s
+}
+
+// close closes the -out file and the log, returning code, or 1 if the file
+// couldn't be written
+func (s *session) close(code int) int {
+	if s.outFile != nil {
+		if err := s.outFile.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", s.out, err)
+			code = 1
+		}
+	}
+	s.closeLog()
+	return code
+}
+
+// setup loads the configuration, starts logging and tracing and connects
+// the chat interface to the BIG-IP and the LLM. The returned func waits
+// for the last traces to be exported and closes the log.
+func setup(device string) (*chat.Interface, *config.Config, func() error) {
+	cfg, closeLog := loadConfig(device)
+	metrics.Serve(cfg.MetricsAddr)
+	flushTraces, err := telemetry.Setup(cfg)
+	if err != nil {
+		fatal("Invalid tracing settings: %v", err)
+	}
+	closeAll := func() error {
+		flushTraces()
+		return closeLog()
+	}
+	bigipClient := connect(cfg)
+
+	llmClient, err := llm.New(cfg)
+	if err != nil {
+		fatal("Failed to initialize LLM provider: %v", err)
+	}
+	slog.Debug("LLM provider initialized", "provider", llmClient.Name())
+
+	// Initialize chat interface
+	chatInterface := chat.NewInterface(bigipClient, llmClient)
+	chatInterface.SetHistoryTurns(cfg.ChatHistoryTurns)
+	maxRisk, err := llm.ParseRisk(cfg.GuardrailMaxRisk)
+	if err != nil {
+		fatal("Invalid GUARDRAIL_MAX_RISK: %v", err)
+	}
+	chatInterface.SetMaxRisk(maxRisk)
+	if cfg.RAGEnabled {
+		chatInterface.EnableDocumentation(cfg)
+	}
+	if cfg.IntentClassifier {
+		chatInterface.EnableIntentClassifier(cfg)
+	}
+	if cfg.AgentMode {
+		chatInterface.EnableAgent(cfg.AgentMaxSteps)
+	}
+	chatInterface.SetPlanPreview(cfg.PlanPreview)
+	chatInterface.SetFollowUps(cfg.FollowUps)
+	if err := chatInterface.SetOutputFormat(cfg.OutputFormat); err != nil {
+		fatal("Invalid OUTPUT_FORMAT: %v", err)
+	}
+	layouts, err := utils.LoadTemplates(cfg.TemplateDir)
+	if err != nil {
+		fatal("Invalid TEMPLATE_DIR: %v", err)
+	}
+	chatInterface.SetTemplates(layouts)
+	router, err := notify.NewRouterFromConfig(cfg)
+	if err != nil {
+		fatal("Invalid notification settings: %v", err)
+	}
+	chatInterface.SetNotifier(router, cfg.BigIPHost)
+	if detector, err := openAnomalies(cfg); err != nil {
+		slog.Warn("Anomaly detection unavailable", "file", cfg.AnomalyBaselines, "err", err)
+	} else if detector != nil {
+		chatInterface.SetAnomalies(detector)
+	}
+	if cfg.AuditLog != "" {
+		auditLog, err := audit.Open(cfg.AuditLog, cfg.AuditUser)
+		if err != nil {
+			fatal("Failed to open the audit log: %v", err)
+		}
+		if splunk, ok := router.Sink("splunk").(*notify.SplunkSink); ok {
+			auditLog.Forward(func(e audit.Entry) {
+				if err := splunk.Forward(context.Background(), notify.SplunkAudit, e.Device, e.Time, e); err != nil {
+					slog.Warn("Failed to forward an audit entry to Splunk", "query", e.Query, "err", err)
+				}
+			})
+		}
+		chatInterface.SetAudit(auditLog)
+	}
+	// Demo changes aren't real, so they're journaled for the session only
+	journalFile := cfg.ChangeJournal
+	if cfg.Demo {
+		journalFile = ""
+	}
+	changes, err := journal.Open(journalFile, cfg.AuditUser)
+	if err != nil {
+		slog.Warn("Change journal unavailable", "file", journalFile, "err", err)
+	} else {
+		chatInterface.SetJournal(changes)
+	}
+	// nor are they marked on real graphs
+	if annotations, err := grafana.NewFromConfig(cfg); err != nil {
+		fatal("Invalid Grafana settings: %v", err)
+	} else if annotations != nil && !cfg.Demo {
+		chatInterface.SetAnnotations(annotations)
+	}
+	// and a demo qkview has nothing for iHealth to analyze
+	if iHealth, err := ihealth.NewFromConfig(cfg); err != nil {
+		fatal("Invalid iHealth settings: %v", err)
+	} else if iHealth != nil && !cfg.Demo {
+		chatInterface.SetIHealth(iHealth)
+	}
+	rules, err := compliance.Load(cfg.ComplianceRules)
+	if err != nil {
+		fatal("Invalid COMPLIANCE_RULES: %v", err)
+	}
+	chatInterface.SetComplianceRules(rules)
+	chatInterface.SetPartition(cfg.Partition)
+	if len(cfg.Profiles) > 0 {
+		chatInterface.SetProfiles(cfg.ProfileNames(), cfg.Profile, openProfile(cfg))
+	}
+	addDevices(chatInterface, cfg, bigipClient)
+	return chatInterface, cfg, closeAll
+}
+
+// loadConfig loads the configuration, asking device, a name in
+// BIGIP_DEVICES or a host, if given, and starts logging. The returned func
+// closes the log.
+func loadConfig(device string) (*config.Config, func() error) {
+	cfg, err := config.LoadConfig()
+	var missing *config.MissingError
+	if errors.As(err, &missing) && lineedit.IsTerminal(os.Stdin) {
+		cfg, err = askSettings(missing)
+	}
+	if err != nil {
+		fatal("Failed to load configuration: %v", err)
+	}
+	useDevice(cfg, device)
+
+	closeLog, err := logging.Setup(cfg)
+	if err != nil {
+		fatal("Failed to set up logging: %v", err)
+	}
+	if cfg.File != "" {
+		slog.Debug("Configuration file read", "file", cfg.File)
+	}
+	return cfg, closeLog
+}
+
+// useDevice makes the configuration ask device, a name in BIGIP_DEVICES or
+// a host, if given: -device outranks a profile's host as well as BIGIP_HOST
+func useDevice(cfg *config.Config, device string) {
+	if device == "" {
+		return
+	}
+	cfg.BigIPHost = device
+	for _, d := range cfg.Devices {
+		if d.Name == device {
+			cfg.BigIPHost = d.Host
+		}
+	}
+}
+
+// connect returns a client of the configured BIG-IP, or of the built-in
+// demo data in demo mode
+func connect(cfg *config.Config) chat.BigIPClient {
+	if cfg.Demo {
+		slog.Info("Demo mode: using built-in mock BIG-IP data")
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		fatal("Failed to initialize BIG-IP client: %v", err)
+	}
+	return client
+}
+
+// settingPrompts ask for the settings chatf5 can't start without
+var settingPrompts = map[string]string{
+	"BIGIP_HOST":     "BIG-IP host (host[:port])",
+	"BIGIP_USERNAME": "BIG-IP username",
+	"BIGIP_PASSWORD": "BIG-IP password",
+	"OPENAI_API_KEY": "OpenAI API key",
+}
+
+// askSettings asks at the terminal for the settings missing, passwords and
+// keys without showing them, and loads the configuration with them. It
+// offers to save the others to the configuration file for next time, but
+// not passwords and keys, which the file would hold in plain text.
+func askSettings(missing *config.MissingError) (*config.Config, error) {
+	reader := lineedit.New(nil)
+	reader.SetOutput(os.Stderr)
+	fmt.Fprintf(os.Stderr, "Some settings aren't set (%s). Enter them, or press Ctrl-C to quit.\n", strings.Join(missing.Names, ", "))
+	entered := map[string]string{}
+	for _, name := range missing.Names {
+		prompt := settingPrompts[name] + ": "
+		for entered[name] == "" {
+			var value string
+			var err error
+			if config.IsSecret(name) {
+				value, err = reader.ReadPassword(prompt)
+			} else {
+				value, err = reader.ReadLine(prompt)
+			}
+			if err != nil {
+				return nil, missing
+			}
+			entered[name] = strings.TrimSpace(value)
+		}
+		os.Setenv(name, entered[name])
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	saveable := map[string]string{}
+	var secrets []string
+	for _, name := range missing.Names {
+		if config.IsSecret(name) {
+			secrets = append(secrets, name)
+		} else {
+			saveable[name] = entered[name]
+		}
+	}
+	if len(secrets) > 0 {
+		it := "it"
+		if len(secrets) > 1 {
+			it = "them"
+		}
+		fmt.Fprintf(os.Stderr, "%s won't be saved: the configuration file would hold %s in plain text. Set %s in the environment next time, e.g. from a secrets manager.\n", strings.Join(secrets, " and "), it, it)
+	}
+	path := cfg.File
+	if path == "" {
+		path = config.DefaultFile()
+	}
+	if path == "" || len(saveable) == 0 {
+		return cfg, nil
+	}
+	what := "them"
+	if len(saveable) == 1 {
+		what = "it"
+	}
+	answer, err := reader.ReadLine(fmt.Sprintf("Save %s to %s for next time? [y/N] ", what, path))
+	if err != nil || !strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y") {
+		return cfg, nil
+	}
+	if err := config.SaveSettings(path, cfg.Profile, saveable); err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't save %s: %v\n", what, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "Saved to %s, readable only by you.\n", path)
+		cfg.File = path
+	}
+	return cfg, nil
+}
+
+// newClient returns a client of the configured BIG-IP, or of the demo data.
+// The connection is made lazily on the first query, so the chat starts
+// right away even if the device is down.
+func newClient(cfg *config.Config) (chat.BigIPClient, error) {
+	if cfg.Demo {
+		return bigip.NewMockClient(), nil
+	}
+	return bigip.NewClient(cfg)
+}
+
+// openProfile returns a client of the device the profile named name
+// describes, for /device
+func openProfile(cfg *config.Config) chat.ProfileOpener {
+	return func(name string) (chat.BigIPClient, string, string, error) {
+		profiled, err := cfg.WithProfile(name)
+		if err != nil {
+			return nil, "", "", err
+		}
+		client, err := newClient(profiled)
+		if err != nil {
+			return nil, "", "", err
+		}
+		slog.Info("Switched device profile", "profile", name, "host", profiled.BigIPHost)
+		return client, profiled.BigIPHost, profiled.Partition, nil
+	}
+}
+
+// addDevices makes the BIG-IPs in BIGIP_DEVICES available to requests
+// across all devices. The one already connected to is reused, and asked
+// under its host name if it isn't listed.
+func addDevices(chatInterface *chat.Interface, cfg *config.Config, primary chat.BigIPClient) {
+	if len(cfg.Devices) == 0 {
+		return
+	}
+	listed := false
+	for _, d := range cfg.Devices {
+		listed = listed || d.Host == cfg.BigIPHost
+	}
+	if !listed {
+		chatInterface.AddDevice(cfg.BigIPHost, primary)
+	}
+	for _, d := range cfg.Devices {
+		var client chat.BigIPClient
+		switch {
+		case d.Host == cfg.BigIPHost:
+			client = primary
+		case cfg.Demo:
+			client = bigip.NewMockClient()
+		default:
+			deviceCfg := *cfg
+			deviceCfg.BigIPHost = d.Host
+			c, err := bigip.NewClient(&deviceCfg)
+			if err != nil {
+				fatal("Failed to initialize BIG-IP client for %s: %v", d.Name, err)
+			}
+			client = c
+		}
+		chatInterface.AddDevice(d.Name, client)
+	}
+	slog.Debug("Devices configured", "count", len(cfg.Devices))
+}
+
+// openAnomalies loads the baselines unusual readings are flagged against,
+// or returns nil if ANOMALY_THRESHOLD turns detection off
+func openAnomalies(cfg *config.Config) (*anomaly.Detector, error) {
+	if cfg.AnomalyThreshold <= 0 {
+		return nil, nil
+	}
+	// Demo readings aren't real, so they don't count towards baselines
+	baselines := cfg.AnomalyBaselines
+	if cfg.Demo {
+		baselines = ""
+	}
+	return anomaly.Open(baselines, cfg.AnomalyThreshold)
+}
@@ -0,0 +1,152 @@
+// Package compliance checks a BIG-IP's configuration against best-practice
+// rules, built in or the user's own, and scores how well it follows them.
+//
+// A rule applies to objects of one kind, those its "where" conditions pick,
+// and each of them must meet its "require" conditions:
+//
+//	rules:
+//	  - id: vs-monitored-pool
+//	    title: Virtual servers send traffic to health-monitored pools
+//	    severity: high
+//	    object: virtual
+//	    where: pool is set
+//	    require: monitor is set
+//	    fix: Assign a health monitor to the pool.
+package compliance
+
+import (
+	"math"
+	"sort"
+)
+
+// Kinds are the kinds of object rules can check
+var Kinds = []string{"virtual", "pool", "node", "client-ssl", "waf", "device"}
+
+// Fields are the fields of each kind of object
+var Fields = map[string][]string{
+	"virtual":    {"name", "partition", "destination", "pool", "monitor", "enabled", "rules", "policies"},
+	"pool":       {"name", "partition", "monitor", "load_balancing_mode", "members", "min_active_members"},
+	"node":       {"name", "partition", "address", "monitor", "state"},
+	"client-ssl": {"name", "partition", "defaults_from", "options", "ciphers", "cert"},
+	"waf":        {"name", "partition", "enforcement_mode", "active", "signature_staging", "days_unchanged", "virtual_servers"},
+	"device":     {"name", "host", "user", "auth_method"},
+}
+
+// Object is a piece of the device's configuration as rules see it: its
+// kind and fields, all of them text. Lists are separated by spaces, and
+// fields that aren't known are "".
+type Object struct {
+	Kind   string
+	Fields map[string]string
+}
+
+// Name is the object's full path, or the device's host
+func (o Object) Name() string {
+	return o.Fields["name"]
+}
+
+// Rule is a best practice the objects of a kind should follow
+type Rule struct {
+	ID       string
+	Title    string
+	Severity string
+	Object   string
+	Where    string
+	Require  string
+	Fix      string
+
+	where, require []condition
+}
+
+// severityWeights weigh each rule's part in the score
+var severityWeights = map[string]float64{"high": 3, "medium": 2, "low": 1}
+
+// Finding is an object that doesn't follow a rule, and why
+type Finding struct {
+	Object string
+	Reason string
+}
+
+// Result is how the objects a rule applies to fared
+type Result struct {
+	Rule Rule
+	// Checked is the number of objects the rule applied to
+	Checked  int
+	Findings []Finding
+}
+
+// Status is PASS, FAIL, or SKIP when no object was checked
+func (r Result) Status() string {
+	switch {
+	case r.Checked == 0:
+		return "SKIP"
+	case len(r.Findings) > 0:
+		return "FAIL"
+	}
+	return "PASS"
+}
+
+// Report is the result of each rule and the score they add up to
+type Report struct {
+	Results []Result
+	// Score is out of 100: each rule that checked anything counts by its
+	// severity, in proportion to the objects that follow it
+	Score int
+	// Unavailable names the kinds of object that couldn't be read, such as
+	// WAF policies without ASM
+	Unavailable []string
+}
+
+// Failed is the number of rules with findings
+func (r Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if result.Status() == "FAIL" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Evaluate checks each object against the rules of its kind
+func Evaluate(rules []Rule, objects []Object) Report {
+	var report Report
+	var score, weights float64
+	for _, rule := range rules {
+		result := Result{Rule: rule}
+		for _, object := range objects {
+			if object.Kind != rule.Object || !meets(rule.where, object) {
+				continue
+			}
+			result.Checked++
+			for _, c := range rule.require {
+				if !c.holds(object) {
+					result.Findings = append(result.Findings, Finding{Object: object.Name(), Reason: c.explain(object)})
+					break
+				}
+			}
+		}
+		sort.Slice(result.Findings, func(a, b int) bool { return result.Findings[a].Object < result.Findings[b].Object })
+		if result.Checked > 0 {
+			weight := severityWeights[rule.Severity]
+			score += weight * float64(result.Checked-len(result.Findings)) / float64(result.Checked)
+			weights += weight
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.Score = 100
+	if weights > 0 {
+		report.Score = int(math.Round(100 * score / weights))
+	}
+	return report
+}
+
+// meets reports whether the object meets all the conditions
+func meets(conditions []condition, object Object) bool {
+	for _, c := range conditions {
+		if !c.holds(object) {
+			return false
+		}
+	}
+	return true
+}
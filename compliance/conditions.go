@@ -0,0 +1,121 @@
+package compliance
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// condition is a test of one of an object's fields, written
+// "field OP value" or "field is set" / "field is empty". The operators are
+// == and != on text, <, <=, > and >= on numbers, contains and !contains on
+// text, and has and !has on a space-separated list's items. Conditions are
+// joined with " and ".
+type condition struct {
+	field, op, value string
+}
+
+// operators are tried longest first so ">=" isn't read as ">"
+var operators = []string{"!contains", "contains", "!has", "has", "==", "!=", ">=", "<=", ">", "<"}
+
+// parseConditions reads the conditions in s on the fields of kind
+func parseConditions(s, kind string) ([]condition, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var conditions []condition
+	for _, part := range strings.Split(s, " and ") {
+		c, err := parseCondition(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(Fields[kind], c.field) {
+			return nil, fmt.Errorf("%s objects have no field %q; they have %s", kind, c.field, strings.Join(Fields[kind], ", "))
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+func parseCondition(s string) (condition, error) {
+	field, rest, _ := strings.Cut(s, " ")
+	rest = strings.TrimSpace(rest)
+	switch rest {
+	case "is set", "is empty":
+		return condition{field: field, op: rest}, nil
+	}
+	for _, op := range operators {
+		value, ok := strings.CutPrefix(rest, op+" ")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch op {
+		case "<", "<=", ">", ">=":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return condition{}, fmt.Errorf("%q compares with a number, not %q", s, value)
+			}
+		}
+		return condition{field, op, value}, nil
+	}
+	return condition{}, fmt.Errorf("can't read %q: write \"field OP value\" with OP one of %s, or \"field is set\"", s, strings.Join(operators, " "))
+}
+
+// holds reports whether the object meets the condition. A number compared
+// with a field that isn't one, such as an unknown age, holds.
+func (c condition) holds(o Object) bool {
+	v := o.Fields[c.field]
+	switch c.op {
+	case "is set":
+		return v != ""
+	case "is empty":
+		return v == ""
+	case "==":
+		return strings.EqualFold(v, c.value)
+	case "!=":
+		return !strings.EqualFold(v, c.value)
+	case "contains":
+		return strings.Contains(strings.ToLower(v), strings.ToLower(c.value))
+	case "!contains":
+		return !strings.Contains(strings.ToLower(v), strings.ToLower(c.value))
+	case "has":
+		return slices.Contains(strings.Fields(v), c.value)
+	case "!has":
+		return !slices.Contains(strings.Fields(v), c.value)
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return true
+	}
+	limit, _ := strconv.ParseFloat(c.value, 64)
+	switch c.op {
+	case "<":
+		return n < limit
+	case "<=":
+		return n <= limit
+	case ">":
+		return n > limit
+	}
+	return n >= limit
+}
+
+// explain says why the object doesn't meet the condition
+func (c condition) explain(o Object) string {
+	v := o.Fields[c.field]
+	found := fmt.Sprintf("%s is %q", c.field, v)
+	if v == "" {
+		found = c.field + " is not set"
+	}
+	if c.op == "is set" {
+		return found
+	}
+	return fmt.Sprintf("%s; the rule requires %s", found, c)
+}
+
+func (c condition) String() string {
+	if c.op == "is set" || c.op == "is empty" {
+		return c.field + " " + c.op
+	}
+	return fmt.Sprintf("%s %s %s", c.field, c.op, c.value)
+}
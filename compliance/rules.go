@@ -0,0 +1,158 @@
+package compliance
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// builtinRules are the rules chatf5 checks unless the user's file replaces
+// or disables them
+//
+//go:embed builtin.yaml
+var builtinRules string
+
+// Builtin returns the built-in rules
+func Builtin() []Rule {
+	rules, err := Parse(builtinRules)
+	if err != nil {
+		panic("invalid built-in compliance rules: " + err.Error())
+	}
+	return rules
+}
+
+// Load returns the built-in rules with the rules in the file at path: a
+// rule with a built-in one's id replaces it, "disabled: true" drops it, and
+// the others are checked after the built-in ones. An empty path gives the
+// built-in rules alone.
+func Load(path string) ([]Rule, error) {
+	rules := Builtin()
+	if path == "" {
+		return rules, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the compliance rules: %w", err)
+	}
+	own, disabled, err := parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compliance rules %s: %v", path, err)
+	}
+	for _, rule := range own {
+		if n := slices.IndexFunc(rules, func(r Rule) bool { return r.ID == rule.ID }); n >= 0 {
+			rules[n] = rule
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	return slices.DeleteFunc(rules, func(r Rule) bool { return disabled[r.ID] }), nil
+}
+
+// Parse reads rules: a YAML list of them, optionally under "rules:", each a
+// mapping of id, title, severity, object, where, require and fix
+func Parse(data string) ([]Rule, error) {
+	rules, disabled, err := parse(data)
+	if err == nil && len(disabled) > 0 {
+		err = fmt.Errorf("only a built-in rule can be disabled")
+	}
+	return rules, err
+}
+
+// parse reads rules, returning apart the ids of those "disabled: true"
+func parse(data string) ([]Rule, map[string]bool, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, nil, err
+	}
+	disabled := map[string]bool{}
+	if len(doc.Content) == 0 {
+		return nil, disabled, nil
+	}
+	list := doc.Content[0]
+	if list.Kind == yaml.MappingNode {
+		if len(list.Content) != 2 || list.Content[0].Value != "rules" {
+			return nil, nil, fmt.Errorf("line %d: expected \"rules:\" and a list of rules", list.Line)
+		}
+		list = list.Content[1]
+	}
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		return nil, disabled, nil
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, nil, fmt.Errorf("line %d: expected a list of rules (\"- id: ...\")", list.Line)
+	}
+
+	var rules []Rule
+	for _, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			return nil, nil, fmt.Errorf("line %d: expected a rule (\"- id: ...\"), got %q", item.Line, item.Value)
+		}
+		rule := map[string]string{}
+		for n := 0; n+1 < len(item.Content); n += 2 {
+			key, value := item.Content[n], item.Content[n+1]
+			switch key.Value {
+			case "id", "title", "severity", "object", "where", "require", "fix", "disabled":
+			default:
+				return nil, nil, fmt.Errorf("line %d: unknown field %q; rules have an id, title, severity, object, where, require and fix", key.Line, key.Value)
+			}
+			if value.Kind != yaml.ScalarNode {
+				return nil, nil, fmt.Errorf("line %d: the %s is a list or mapping, not text", value.Line, key.Value)
+			}
+			rule[key.Value] = value.Value
+		}
+		if rule["id"] == "" {
+			return nil, nil, fmt.Errorf("line %d: the rule has no id", item.Line)
+		}
+		if off, _ := strconv.ParseBool(rule["disabled"]); off {
+			disabled[rule["id"]] = true
+			continue
+		}
+		r, err := newRule(rule)
+		if err != nil {
+			return nil, nil, fmt.Errorf("rule %s (line %d): %v", rule["id"], item.Line, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, disabled, nil
+}
+
+// newRule makes a rule of its fields, checking them
+func newRule(fields map[string]string) (Rule, error) {
+	rule := Rule{
+		ID:       fields["id"],
+		Title:    fields["title"],
+		Severity: strings.ToLower(fields["severity"]),
+		Object:   fields["object"],
+		Where:    fields["where"],
+		Require:  fields["require"],
+		Fix:      fields["fix"],
+	}
+	if rule.Title == "" {
+		rule.Title = rule.ID
+	}
+	if rule.Severity == "" {
+		rule.Severity = "medium"
+	}
+	if _, ok := severityWeights[rule.Severity]; !ok {
+		return rule, fmt.Errorf("severity is low, medium or high, not %q", rule.Severity)
+	}
+	if _, ok := Fields[rule.Object]; !ok {
+		return rule, fmt.Errorf("object is one of %s, not %q", strings.Join(Kinds, ", "), rule.Object)
+	}
+	if rule.Require == "" {
+		return rule, fmt.Errorf("the rule requires nothing; give \"require:\"")
+	}
+	var err error
+	if rule.where, err = parseConditions(rule.Where, rule.Object); err != nil {
+		return rule, fmt.Errorf("where: %v", err)
+	}
+	if rule.require, err = parseConditions(rule.Require, rule.Object); err != nil {
+		return rule, fmt.Errorf("require: %v", err)
+	}
+	return rule, nil
+}
@@ -2,33 +2,816 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
+	// File is the configuration file settings were read from, "" if none
+	File string
+	// Profiles are the named devices in the file; Profile is the one the
+	// settings below are for (see WithProfile), "" if none
+	Profiles []Profile
+	Profile  string
+
 	BigIPHost     string
 	BigIPUsername string
 	BigIPPassword string
-	
+	// Devices are the BIG-IPs, from BIGIP_DEVICES, that requests such as
+	// "show pools across all devices" ask. They share the credentials and
+	// other settings above; without BIGIP_HOST the first answers everything
+	// else.
+	Devices []Device
+
+	// BigIPAuthMethod is "basic" (default) or "token"; token auth logs in via
+	// /mgmt/shared/authn/login and renews the token when it expires
+	BigIPAuthMethod    string
+	BigIPLoginProvider string
+	// BigIPTLSVerify checks the device's certificate, which is off by
+	// default as BIG-IPs ship self-signed; setting BigIPCAFile, the CAs to
+	// check it against, turns it on too
+	BigIPTLSVerify bool
+	BigIPCAFile    string
+	// BigIPDialTimeout bounds connecting to the device and BigIPReadTimeout
+	// waiting for its response to a request; 0 uses the client's defaults
+	BigIPDialTimeout time.Duration
+	BigIPReadTimeout time.Duration
+	// Partition scopes queries that don't name one, as /partition does
+	Partition string
+
+	// CacheTTL controls how long BIG-IP responses are reused; 0 disables caching
+	CacheTTL time.Duration
+	// CacheWarm reads object listings into the cache in the background
+	// while the chat prompt is open, so they're there before they're asked for
+	CacheWarm bool
+
+	// Retry policy for BIG-IP API calls; zero values fall back to the client defaults
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryOn          []string
+
+	// Client-side rate limit for iControl REST calls; RateLimit 0 disables it
+	RateLimit float64
+	RateBurst int
+
+	// Circuit breaker: open after BreakerThreshold consecutive connectivity
+	// failures and retry after BreakerCooldown; a threshold of 0 disables it
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// LLMProvider selects a backend registered with the llm package (default
+	// "openai"); LLMFallbackProvider, if set, takes over while it is
+	// rate limited, out of quota or down
+	LLMProvider         string
+	LLMFallbackProvider string
+	// LLMModel, LLMTemperature and LLMMaxTokens tune completions; a max of 0
+	// leaves the response length to the provider
+	LLMModel       string
+	LLMTemperature float32
+	LLMMaxTokens   int
+	// LLMContextWindow is the model's context size in tokens, for trimming
+	// long conversations; 0 looks it up from the model name
+	LLMContextWindow int
+	// Spend limits on hosted LLM providers, in requests and tokens per
+	// session and per day; 0 is unlimited. Daily usage is kept in
+	// LLMUsageFile. IgnoreSpendLimits counts usage without refusing requests.
+	LLMSessionRequestLimit int
+	LLMSessionTokenLimit   int
+	LLMDailyRequestLimit   int
+	LLMDailyTokenLimit     int
+	LLMUsageFile           string
+	IgnoreSpendLimits      bool
+	// Retry policy for LLM API calls that hit rate limits, 5xx or network
+	// errors; zero values fall back to the llm package defaults
+	LLMRetryMaxAttempts int
+	LLMRetryBaseDelay   time.Duration
+	LLMRetryMaxDelay    time.Duration
+	// Ollama provider settings; the endpoint is Ollama's OpenAI-compatible API
+	OllamaBaseURL        string
+	OllamaModel          string
+	OllamaEmbeddingModel string
+	// GuardrailMaxRisk is the largest blast radius ("read-only", "low-risk"
+	// or "disruptive") a change may have to run without being refused
+	GuardrailMaxRisk string
+	// PromptDir holds <name>.txt files that replace the built-in system prompt
+	// and operation templates (see the prompt package)
+	PromptDir string
+
+	// LLMEmbeddingModel is used to embed documentation for retrieval
+	LLMEmbeddingModel string
+
+	// Documentation retrieval (see the rag package): RAGDocsDir adds local
+	// .md/.txt files to the built-in corpus and RAGIndexFile caches their
+	// embeddings between runs
+	RAGEnabled   bool
+	RAGDocsDir   string
+	RAGIndexFile string
+	RAGTopK      int
+	RAGMinScore  float64
+
+	// IntentClassifier matches common queries to operations by embedding
+	// similarity, skipping the chat completion when the best match scores at
+	// least IntentMinScore and leads the runner-up by IntentMinMargin
+	IntentClassifier bool
+	IntentMinScore   float64
+	IntentMinMargin  float64
+
+	// ChatHistoryTurns is how many earlier question/answer pairs are sent with
+	// each query so follow-ups resolve; 0 disables conversation memory
+	ChatHistoryTurns int
+	// QueryHistoryFile keeps the queries typed at the prompt across sessions
+	// for /history and recall; QueryHistorySize of 0 turns it off
+	QueryHistoryFile string
+	QueryHistorySize int
+	// SavedQueriesFile keeps the queries saved by name ("save this as
+	// morning-check") for /run
+	SavedQueriesFile string
+	// SnapshotFile keeps the object lists taken with /snapshot, for "what
+	// changed since" questions
+	SnapshotFile string
+	// ChangeJournal records each change chatf5 makes to a device, with the
+	// configuration before and after, for "what did chatf5 change" questions
+	ChangeJournal string
+	// PlanPreview starts each answer with the iControl REST calls made for it
+	PlanPreview bool
+	// FollowUps suggests questions to ask next after each answer
+	FollowUps bool
+	// OutputFormat is how answers are written: text, or json, csv,
+	// markdown or yaml for other tools
+	OutputFormat string
+	// TemplateDir holds <name>.tmpl files (text/template) that replace the
+	// built-in layout of listings (see utils.LoadTemplates)
+	TemplateDir string
+	// NoColor turns off highlighting statuses in color on a terminal; any
+	// NO_COLOR value sets it, as for other tools
+	NoColor bool
+
+	// AgentMode answers open-ended questions with a multi-step loop of
+	// read-only tool calls, at most AgentMaxSteps per question
+	AgentMode     bool
+	AgentMaxSteps int
+
+	// Redaction of data sent to hosted LLM providers (see llm.Redactor):
+	// credentials and hostnames are replaced with placeholders, and internal
+	// (RFC 1918, loopback, link-local) addresses too with RedactInternalIPs
+	RedactEnabled     bool
+	RedactInternalIPs bool
+
 	OpenAIKey     string
+	OpenAIBaseURL string
+
+	// Azure OpenAI: when AzureOpenAIEndpoint is set, requests go to the
+	// named deployment on that resource instead of api.openai.com
+	AzureOpenAIEndpoint   string
+	AzureOpenAIDeployment string
+	AzureOpenAIAPIVersion string
+	// AzureOpenAIEmbeddingDeployment serves embeddings; Azure needs a
+	// separate deployment for the embedding model
+	AzureOpenAIEmbeddingDeployment string
+
+	// Demo uses the built-in mock BIG-IP instead of a real device
+	Demo bool
+
+	// TraceFile, when set, records every iControl REST request/response
+	// (credentials redacted) to this file for troubleshooting
+	TraceFile string
+
+	// AuditLog, when set, is appended a JSON line for each query: who asked
+	// it, what it resolved to, the iControl REST calls made and whether they
+	// changed anything. AuditUser names who asked, the login user if unset.
+	AuditLog  string
+	AuditUser string
+
+	// ComplianceRules is a YAML file of compliance rules checked besides
+	// the built-in ones, or replacing them by id (see compliance.Load)
+	ComplianceRules string
+
+	// Logging: level is debug, info, warn or error; format is text or json.
+	// LogFile defaults to chatf5.log so the chat itself stays quiet; set it
+	// to "stderr" to log to the terminal.
+	LogLevel  string
+	LogFormat string
+	LogFile   string
+	// The log file is rotated once it reaches LogMaxSizeMB, keeping
+	// LogMaxFiles earlier files (chatf5.log.1 is the newest); 0 never rotates
+	LogMaxSizeMB int
+	LogMaxFiles  int
+
+	// Quiet leaves out the greeting and progress notes, so the terminal
+	// shows only prompts and answers, and limits logs on the terminal to
+	// errors
+	Quiet bool
+
+	// MetricsAddr, when set, serves Prometheus metrics on this address (e.g. ":9100")
+	MetricsAddr string
+
+	// OTelEndpoint, or OTelTracesEndpoint for traces alone, is an OTLP/HTTP
+	// collector each query's spans are exported to, with the headers
+	// OTelHeaders ("key=value,..."), as the service OTelServiceName.
+	// OTelProtocol can only be http/json.
+	OTelEndpoint       string
+	OTelTracesEndpoint string
+	OTelHeaders        string
+	OTelProtocol       string
+	OTelServiceName    string
+
+	// AnomalyBaselines keeps what each device's connection rates and CPU
+	// usage usually are at each hour, for watch mode and the exporter to
+	// flag unusual readings; AnomalyThreshold is the z-score past which a
+	// reading is unusual, 0 to not look for them
+	AnomalyBaselines string
+	AnomalyThreshold float64
+
+	// Notification routing (see the notify package)
+	NotifyRoutes      string
+	NotifyDedupWindow time.Duration
+	// NotifyWebhooks names the webhooks events can be routed to:
+	// "name=URL,...", or a URL on its own for one named "webhook"
+	NotifyWebhooks string
+	// Email goes out through the SMTP server at SMTPHost:SMTPPort, from
+	// SMTPFrom to NotifyEmailTo (comma-separated), as an HTML table or
+	// Markdown per EmailFormat. Port 465 uses TLS from the start, others
+	// STARTTLS when the server offers it.
+	SMTPHost      string
+	SMTPPort      int
+	SMTPUsername  string
+	SMTPPassword  string
+	SMTPFrom      string
+	NotifyEmailTo string
+	EmailFormat   string
+	// PagerDutyRoutingKey is the integration key of a PagerDuty Events
+	// API v2 integration, events routed to "pagerduty" trigger incidents
+	// on its service; PagerDutyURL overrides the endpoint, e.g. for the EU
+	PagerDutyRoutingKey string
+	PagerDutyURL        string
+	// SplunkHECURL is a Splunk HTTP Event Collector that events routed to
+	// "splunk", audit log entries and WAF violation summaries are
+	// forwarded to, with the HEC token SplunkHECToken, into SplunkIndex or
+	// the token's default index
+	SplunkHECURL   string
+	SplunkHECToken string
+	SplunkIndex    string
+
+	// GrafanaURL, when set, has the changes chatf5 makes and the failovers
+	// watch mode sees published as annotations to that Grafana, signing in
+	// with the service account token GrafanaToken, on the dashboard with
+	// GrafanaDashboardUID or, without one, across the organization
+	GrafanaURL          string
+	GrafanaToken        string
+	GrafanaDashboardUID string
+
+	// IHealthClientID and IHealthClientSecret are an iHealth API key, with
+	// which qkviews are uploaded to F5 iHealth for analysis; IHealthURL
+	// and IHealthTokenURL override its endpoints
+	IHealthClientID     string
+	IHealthClientSecret string
+	IHealthURL          string
+	IHealthTokenURL     string
+
+	// ReportSchedules lists the reports "chatf5 schedule" makes, each
+	// "CRON REPORT [> TARGET]" (see the schedule package)
+	ReportSchedules string
+}
+
+// Device is a named BIG-IP
+type Device struct {
+	Name string
+	Host string
 }
 
 func LoadConfig() (*Config, error) {
-	bigipHost := os.Getenv("BIGIP_HOST")
-	bigipUser := os.Getenv("BIGIP_USERNAME")
-	bigipPass := os.Getenv("BIGIP_PASSWORD")
-	
+	file, profiles, err := loadFile()
+	if err != nil {
+		return nil, err
+	}
+	profile, err := findProfile(profiles, os.Getenv("CHATF5_PROFILE"))
+	if err != nil {
+		return nil, err
+	}
+	var device Config
+	if err := device.setDevice(profile); err != nil {
+		return nil, err
+	}
+
+	bigipHost := device.BigIPHost
+	bigipUser := device.BigIPUsername
+	bigipPass := device.BigIPPassword
+	devices, err := parseDevices(os.Getenv("BIGIP_DEVICES"))
+	if err != nil {
+		return nil, err
+	}
+	if bigipHost == "" && len(devices) > 0 {
+		bigipHost = devices[0].Host
+	}
+
 	openaiKey := os.Getenv("OPENAI_API_KEY")
+	if azureKey := os.Getenv("AZURE_OPENAI_API_KEY"); azureKey != "" {
+		openaiKey = azureKey
+	}
+	azureEndpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
+	azureDeployment := os.Getenv("AZURE_OPENAI_DEPLOYMENT")
+
+	// Demo mode serves canned data, so no device credentials are needed
+	demo := boolEnv("CHATF5_DEMO")
+
+	logMaxSize, err := intEnv("LOG_MAX_SIZE_MB", 10)
+	if err != nil {
+		return nil, err
+	}
+	logMaxFiles, err := intEnv("LOG_MAX_FILES", 3)
+	if err != nil {
+		return nil, err
+	}
 
-	if bigipHost == "" || bigipUser == "" || bigipPass == "" || openaiKey == "" {
-		return nil, errors.New("missing required environment variables: BIGIP_HOST, BIGIP_USERNAME, BIGIP_PASSWORD, and OPENAI_API_KEY are required")
+	// A local Ollama and the rule-based router need no API key
+	llmProvider := stringEnv("LLM_PROVIDER", "openai")
+	llmFallback := os.Getenv("LLM_FALLBACK_PROVIDER")
+	needsKey := !keylessProvider(llmProvider) || llmFallback != "" && !keylessProvider(llmFallback)
+
+	missing := &MissingError{Profile: device.Profile, File: file}
+	for _, required := range []struct {
+		name, value string
+		needed      bool
+	}{
+		{"BIGIP_HOST", bigipHost, !demo},
+		{"BIGIP_USERNAME", bigipUser, !demo},
+		{"BIGIP_PASSWORD", bigipPass, !demo},
+		{"OPENAI_API_KEY", openaiKey, needsKey},
+	} {
+		if required.needed && required.value == "" {
+			missing.Names = append(missing.Names, required.name)
+		}
+	}
+	if len(missing.Names) > 0 {
+		return nil, missing
+	}
+	if llmFallback != "" && strings.EqualFold(llmFallback, llmProvider) {
+		return nil, fmt.Errorf("LLM_FALLBACK_PROVIDER must differ from LLM_PROVIDER (%s)", llmProvider)
+	}
+	if azureEndpoint != "" && azureDeployment == "" {
+		return nil, errors.New("AZURE_OPENAI_DEPLOYMENT is required when AZURE_OPENAI_ENDPOINT is set")
+	}
+
+	llmTemperature, err := floatEnv("LLM_TEMPERATURE", 0.7)
+	if err != nil {
+		return nil, err
+	}
+	if llmTemperature < 0 || llmTemperature > 2 {
+		return nil, fmt.Errorf("invalid LLM_TEMPERATURE %v: must be between 0 and 2", llmTemperature)
+	}
+	llmMaxTokens, err := intEnv("LLM_MAX_TOKENS", 0)
+	if err != nil {
+		return nil, err
+	}
+	llmContextWindow, err := intEnv("LLM_CONTEXT_WINDOW", 0)
+	if err != nil {
+		return nil, err
+	}
+	sessionRequests, err := intEnv("LLM_SESSION_REQUEST_LIMIT", 0)
+	if err != nil {
+		return nil, err
+	}
+	sessionTokens, err := intEnv("LLM_SESSION_TOKEN_LIMIT", 0)
+	if err != nil {
+		return nil, err
+	}
+	dailyRequests, err := intEnv("LLM_DAILY_REQUEST_LIMIT", 0)
+	if err != nil {
+		return nil, err
+	}
+	dailyTokens, err := intEnv("LLM_DAILY_TOKEN_LIMIT", 0)
+	if err != nil {
+		return nil, err
+	}
+	// Daily usage must outlive the session to be enforced
+	usageFile := os.Getenv("LLM_USAGE_FILE")
+	if usageFile == "" && (dailyRequests > 0 || dailyTokens > 0) {
+		if dir, err := os.UserCacheDir(); err == nil {
+			usageFile = filepath.Join(dir, "chatf5", "llm-usage.json")
+		}
+	}
+	guardrailMaxRisk := strings.ToLower(stringEnv("GUARDRAIL_MAX_RISK", "low-risk"))
+	switch guardrailMaxRisk {
+	case "read-only", "low-risk", "disruptive":
+	default:
+		return nil, fmt.Errorf("invalid GUARDRAIL_MAX_RISK %q: must be read-only, low-risk or disruptive", guardrailMaxRisk)
+	}
+	llmRetryAttempts, err := intEnv("LLM_RETRY_MAX_ATTEMPTS", 0)
+	if err != nil {
+		return nil, err
+	}
+	llmRetryBaseDelay, err := durationEnv("LLM_RETRY_BASE_DELAY", 0)
+	if err != nil {
+		return nil, err
+	}
+	llmRetryMaxDelay, err := durationEnv("LLM_RETRY_MAX_DELAY", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ragEnabled, err := boolEnvDefault("RAG_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	ragTopK, err := intEnv("RAG_TOP_K", 3)
+	if err != nil {
+		return nil, err
+	}
+	ragMinScore, err := floatEnv("RAG_MIN_SCORE", 0.3)
+	if err != nil {
+		return nil, err
+	}
+
+	intentMinScore, err := floatEnv("INTENT_MIN_SCORE", 0.75)
+	if err != nil {
+		return nil, err
+	}
+	intentMinMargin, err := floatEnv("INTENT_MIN_MARGIN", 0.05)
+	if err != nil {
+		return nil, err
+	}
+
+	historyTurns, err := intEnv("CHAT_HISTORY_TURNS", 10)
+	if err != nil {
+		return nil, err
+	}
+	queryHistorySize, err := intEnv("QUERY_HISTORY_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+	// Each user keeps their own history
+	queryHistoryFile := os.Getenv("QUERY_HISTORY_FILE")
+	if queryHistoryFile == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			queryHistoryFile = filepath.Join(dir, "chatf5", "history")
+		}
+	}
+	savedQueriesFile := os.Getenv("SAVED_QUERIES_FILE")
+	if savedQueriesFile == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			savedQueriesFile = filepath.Join(dir, "chatf5", "saved-queries.json")
+		}
+	}
+	snapshotFile := os.Getenv("SNAPSHOT_FILE")
+	if snapshotFile == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			snapshotFile = filepath.Join(dir, "chatf5", "snapshots.json")
+		}
+	}
+	changeJournal := os.Getenv("CHANGE_JOURNAL")
+	if changeJournal == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			changeJournal = filepath.Join(dir, "chatf5", "changes.jsonl")
+		}
+	}
+
+	agentMaxSteps, err := intEnv("AGENT_MAX_STEPS", 6)
+	if err != nil {
+		return nil, err
+	}
+	if agentMaxSteps < 1 {
+		return nil, fmt.Errorf("invalid AGENT_MAX_STEPS %d: must be at least 1", agentMaxSteps)
+	}
+
+	redactEnabled, err := boolEnvDefault("REDACT_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	followUps, err := boolEnvDefault("SUGGEST_FOLLOW_UPS", true)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheTTL, err := durationEnv("BIGIP_CACHE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cacheWarm, err := boolEnvDefault("BIGIP_CACHE_WARM", false)
+	if err != nil {
+		return nil, err
+	}
+
+	retryBaseDelay, err := durationEnv("BIGIP_RETRY_BASE_DELAY", 0)
+	if err != nil {
+		return nil, err
+	}
+	retryMaxDelay, err := durationEnv("BIGIP_RETRY_MAX_DELAY", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimit, err := floatEnv("BIGIP_RATE_LIMIT", 10)
+	if err != nil {
+		return nil, err
+	}
+	rateBurst, err := intEnv("BIGIP_RATE_BURST", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	breakerThreshold, err := intEnv("BIGIP_BREAKER_THRESHOLD", 5)
+	if err != nil {
+		return nil, err
+	}
+	breakerCooldown, err := durationEnv("BIGIP_BREAKER_COOLDOWN", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	anomalyBaselines := os.Getenv("ANOMALY_BASELINES")
+	if anomalyBaselines == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			anomalyBaselines = filepath.Join(dir, "chatf5", "baselines.json")
+		}
+	}
+	anomalyThreshold, err := floatEnv("ANOMALY_THRESHOLD", 3)
+	if err != nil {
+		return nil, err
+	}
+	if anomalyThreshold < 0 {
+		return nil, fmt.Errorf("invalid ANOMALY_THRESHOLD %g: must be 0 or more", anomalyThreshold)
+	}
+
+	dedupWindow, err := durationEnv("NOTIFY_DEDUP_WINDOW", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	smtpPort, err := intEnv("SMTP_PORT", 587)
+	if err != nil {
+		return nil, err
+	}
+	emailFormat := strings.ToLower(stringEnv("EMAIL_FORMAT", "html"))
+	switch emailFormat {
+	case "html", "markdown":
+	default:
+		return nil, fmt.Errorf("invalid EMAIL_FORMAT %q: must be html or markdown", emailFormat)
 	}
 
 	return &Config{
+		File:     file,
+		Profiles: profiles,
+		Profile:  device.Profile,
+
 		BigIPHost:     bigipHost,
+		Devices:       devices,
 		BigIPUsername: bigipUser,
 		BigIPPassword: bigipPass,
-		
+		CacheTTL:      cacheTTL,
+		CacheWarm:     cacheWarm,
+
+		BigIPAuthMethod:    device.BigIPAuthMethod,
+		BigIPLoginProvider: device.BigIPLoginProvider,
+		BigIPTLSVerify:     device.BigIPTLSVerify,
+		BigIPCAFile:        device.BigIPCAFile,
+		BigIPDialTimeout:   device.BigIPDialTimeout,
+		BigIPReadTimeout:   device.BigIPReadTimeout,
+		Partition:          device.Partition,
+
+		RetryMaxAttempts: device.RetryMaxAttempts,
+		RetryBaseDelay:   retryBaseDelay,
+		RetryMaxDelay:    retryMaxDelay,
+		RetryOn:          listEnv("BIGIP_RETRY_ON"),
+
+		RateLimit: rateLimit,
+		RateBurst: rateBurst,
+
+		BreakerThreshold: breakerThreshold,
+		BreakerCooldown:  breakerCooldown,
+
+		LLMProvider:         llmProvider,
+		LLMFallbackProvider: llmFallback,
+
+		LLMModel:       stringEnv("LLM_MODEL", "gpt-3.5-turbo"),
+		LLMTemperature: float32(llmTemperature),
+		LLMMaxTokens:   llmMaxTokens,
+
+		LLMContextWindow: llmContextWindow,
+
+		LLMSessionRequestLimit: sessionRequests,
+		LLMSessionTokenLimit:   sessionTokens,
+		LLMDailyRequestLimit:   dailyRequests,
+		LLMDailyTokenLimit:     dailyTokens,
+		LLMUsageFile:           usageFile,
+		IgnoreSpendLimits:      boolEnv("LLM_IGNORE_SPEND_LIMITS"),
+
+		LLMRetryMaxAttempts: llmRetryAttempts,
+		LLMRetryBaseDelay:   llmRetryBaseDelay,
+		LLMRetryMaxDelay:    llmRetryMaxDelay,
+
+		GuardrailMaxRisk: guardrailMaxRisk,
+
+		OllamaBaseURL:        stringEnv("OLLAMA_BASE_URL", "http://localhost:11434/v1"),
+		OllamaModel:          stringEnv("OLLAMA_MODEL", "llama3.1"),
+		OllamaEmbeddingModel: stringEnv("OLLAMA_EMBEDDING_MODEL", "nomic-embed-text"),
+
+		PromptDir: os.Getenv("PROMPT_DIR"),
+
+		LLMEmbeddingModel: stringEnv("LLM_EMBEDDING_MODEL", "text-embedding-3-small"),
+
+		RAGEnabled:   ragEnabled,
+		RAGDocsDir:   os.Getenv("RAG_DOCS_DIR"),
+		RAGIndexFile: os.Getenv("RAG_INDEX_FILE"),
+		RAGTopK:      ragTopK,
+		RAGMinScore:  ragMinScore,
+
+		IntentClassifier: boolEnv("INTENT_CLASSIFIER"),
+		IntentMinScore:   intentMinScore,
+		IntentMinMargin:  intentMinMargin,
+
+		ChatHistoryTurns: historyTurns,
+		QueryHistoryFile: queryHistoryFile,
+		QueryHistorySize: queryHistorySize,
+		SavedQueriesFile: savedQueriesFile,
+		SnapshotFile:     snapshotFile,
+		ChangeJournal:    changeJournal,
+		PlanPreview:      boolEnv("PLAN_PREVIEW"),
+		FollowUps:        followUps,
+		OutputFormat:     strings.ToLower(stringEnv("OUTPUT_FORMAT", "text")),
+		TemplateDir:      os.Getenv("TEMPLATE_DIR"),
+		NoColor:          os.Getenv("NO_COLOR") != "",
+
+		AgentMode:     boolEnv("AGENT_MODE"),
+		AgentMaxSteps: agentMaxSteps,
+
+		RedactEnabled:     redactEnabled,
+		RedactInternalIPs: boolEnv("REDACT_INTERNAL_IPS"),
+
 		OpenAIKey:     openaiKey,
+		OpenAIBaseURL: os.Getenv("OPENAI_BASE_URL"),
+
+		AzureOpenAIEndpoint:   azureEndpoint,
+		AzureOpenAIDeployment: azureDeployment,
+		AzureOpenAIAPIVersion: stringEnv("AZURE_OPENAI_API_VERSION", "2024-06-01"),
+
+		AzureOpenAIEmbeddingDeployment: os.Getenv("AZURE_OPENAI_EMBEDDING_DEPLOYMENT"),
+
+		Demo: demo,
+
+		TraceFile: os.Getenv("BIGIP_TRACE_FILE"),
+
+		AuditLog:  os.Getenv("AUDIT_LOG"),
+		AuditUser: os.Getenv("AUDIT_USER"),
+
+		ComplianceRules: os.Getenv("COMPLIANCE_RULES"),
+
+		LogLevel:  stringEnv("LOG_LEVEL", "info"),
+		LogFormat: stringEnv("LOG_FORMAT", "text"),
+		LogFile:   stringEnv("LOG_FILE", "chatf5.log"),
+
+		LogMaxSizeMB: logMaxSize,
+		LogMaxFiles:  logMaxFiles,
+
+		Quiet: boolEnv("CHATF5_QUIET"),
+
+		MetricsAddr: os.Getenv("METRICS_ADDR"),
+
+		OTelEndpoint:       os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTelTracesEndpoint: os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		OTelHeaders:        stringEnv("OTEL_EXPORTER_OTLP_TRACES_HEADERS", os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		OTelProtocol:       stringEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")),
+		OTelServiceName:    os.Getenv("OTEL_SERVICE_NAME"),
+
+		AnomalyBaselines: anomalyBaselines,
+		AnomalyThreshold: anomalyThreshold,
+
+		NotifyRoutes:      stringEnv("NOTIFY_ROUTES", "info=log"),
+		NotifyDedupWindow: dedupWindow,
+		NotifyWebhooks:    os.Getenv("NOTIFY_WEBHOOKS"),
+
+		SMTPHost:      os.Getenv("SMTP_HOST"),
+		SMTPPort:      smtpPort,
+		SMTPUsername:  os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:  os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:      os.Getenv("SMTP_FROM"),
+		NotifyEmailTo: os.Getenv("NOTIFY_EMAIL_TO"),
+		EmailFormat:   emailFormat,
+
+		PagerDutyRoutingKey: os.Getenv("PAGERDUTY_ROUTING_KEY"),
+		PagerDutyURL:        os.Getenv("PAGERDUTY_EVENTS_URL"),
+		SplunkHECURL:        os.Getenv("SPLUNK_HEC_URL"),
+		SplunkHECToken:      os.Getenv("SPLUNK_HEC_TOKEN"),
+		SplunkIndex:         os.Getenv("SPLUNK_INDEX"),
+
+		GrafanaURL:          os.Getenv("GRAFANA_URL"),
+		GrafanaToken:        os.Getenv("GRAFANA_TOKEN"),
+		GrafanaDashboardUID: os.Getenv("GRAFANA_DASHBOARD_UID"),
+
+		IHealthClientID:     os.Getenv("IHEALTH_CLIENT_ID"),
+		IHealthClientSecret: os.Getenv("IHEALTH_CLIENT_SECRET"),
+		IHealthURL:          os.Getenv("IHEALTH_API_URL"),
+		IHealthTokenURL:     os.Getenv("IHEALTH_TOKEN_URL"),
+
+		ReportSchedules: os.Getenv("REPORT_SCHEDULES"),
 	}, nil
 }
+
+// parseDevices reads BIGIP_DEVICES: comma-separated name=host[:port]
+// entries, e.g. "lab=10.1.1.245,prod=bigip.example.com:8443"
+func parseDevices(v string) ([]Device, error) {
+	var devices []Device
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, host, ok := strings.Cut(entry, "=")
+		name, host = strings.TrimSpace(name), strings.TrimSpace(host)
+		if !ok || name == "" || host == "" {
+			return nil, fmt.Errorf("invalid BIGIP_DEVICES entry %q: use name=host[:port]", entry)
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("invalid BIGIP_DEVICES: %s is named twice", name)
+		}
+		seen[strings.ToLower(name)] = true
+		devices = append(devices, Device{Name: name, Host: host})
+	}
+	return devices, nil
+}
+
+// stringEnv returns the environment variable or def when it is unset
+// keylessProvider reports whether an LLM provider works without OPENAI_API_KEY
+func keylessProvider(name string) bool {
+	return strings.EqualFold(name, "ollama") || strings.EqualFold(name, "rules")
+}
+
+func stringEnv(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// durationEnv parses a Go duration (e.g. "30s", "5m") from the environment
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, v, err)
+	}
+	return d, nil
+}
+
+// boolEnv reports whether the environment variable is set to a true value
+func boolEnv(name string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(name))
+	return v
+}
+
+// boolEnvDefault parses a boolean from the environment, returning def when unset
+func boolEnvDefault(name string, def bool) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %v", name, v, err)
+	}
+	return b, nil
+}
+
+// intEnv parses an integer from the environment
+func intEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, v, err)
+	}
+	return n, nil
+}
+
+// floatEnv parses a floating point number from the environment
+func floatEnv(name string, def float64) (float64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, v, err)
+	}
+	return f, nil
+}
+
+// listEnv splits a comma-separated environment variable, dropping empty entries
+func listEnv(name string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
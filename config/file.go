@@ -0,0 +1,319 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileSettings maps the settings of the configuration file to the
+// environment variables they stand in for
+var fileSettings = map[string]string{
+	"bigip.host":               "BIGIP_HOST",
+	"bigip.username":           "BIGIP_USERNAME",
+	"bigip.password":           "BIGIP_PASSWORD",
+	"bigip.auth_method":        "BIGIP_AUTH_METHOD",
+	"bigip.login_provider":     "BIGIP_LOGIN_PROVIDER",
+	"bigip.devices":            "BIGIP_DEVICES",
+	"bigip.tls.verify":         "BIGIP_TLS_VERIFY",
+	"bigip.tls.ca_file":        "BIGIP_CA_FILE",
+	"bigip.partition":          "BIGIP_PARTITION",
+	"bigip.timeouts.dial":      "BIGIP_DIAL_TIMEOUT",
+	"bigip.timeouts.read":      "BIGIP_READ_TIMEOUT",
+	"bigip.retry.max_attempts": "BIGIP_RETRY_MAX_ATTEMPTS",
+
+	"llm.provider":                   "LLM_PROVIDER",
+	"llm.model":                      "LLM_MODEL",
+	"llm.api_key":                    "OPENAI_API_KEY",
+	"llm.base_url":                   "OPENAI_BASE_URL",
+	"llm.temperature":                "LLM_TEMPERATURE",
+	"llm.max_tokens":                 "LLM_MAX_TOKENS",
+	"llm.fallback_provider":          "LLM_FALLBACK_PROVIDER",
+	"llm.embedding_model":            "LLM_EMBEDDING_MODEL",
+	"llm.azure.endpoint":             "AZURE_OPENAI_ENDPOINT",
+	"llm.azure.deployment":           "AZURE_OPENAI_DEPLOYMENT",
+	"llm.azure.api_key":              "AZURE_OPENAI_API_KEY",
+	"llm.azure.api_version":          "AZURE_OPENAI_API_VERSION",
+	"llm.azure.embedding_deployment": "AZURE_OPENAI_EMBEDDING_DEPLOYMENT",
+	"llm.ollama.base_url":            "OLLAMA_BASE_URL",
+	"llm.ollama.model":               "OLLAMA_MODEL",
+	"llm.ollama.embedding_model":     "OLLAMA_EMBEDDING_MODEL",
+
+	"output.format":    "OUTPUT_FORMAT",
+	"output.color":     "NO_COLOR",
+	"output.quiet":     "CHATF5_QUIET",
+	"output.templates": "TEMPLATE_DIR",
+
+	"logging.level":          "LOG_LEVEL",
+	"logging.format":         "LOG_FORMAT",
+	"logging.file":           "LOG_FILE",
+	"logging.max_size_mb":    "LOG_MAX_SIZE_MB",
+	"logging.max_files":      "LOG_MAX_FILES",
+	"logging.audit_log":      "AUDIT_LOG",
+	"logging.audit_user":     "AUDIT_USER",
+	"logging.change_journal": "CHANGE_JOURNAL",
+
+	"compliance.rules": "COMPLIANCE_RULES",
+
+	"anomaly.baselines": "ANOMALY_BASELINES",
+	"anomaly.threshold": "ANOMALY_THRESHOLD",
+}
+
+// IsSecret reports whether the environment variable name holds a password,
+// key or token, which the configuration file would hold in plain text
+func IsSecret(name string) bool {
+	for _, suffix := range []string{"_PASSWORD", "_API_KEY", "_TOKEN", "_SECRET"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// envName is the name of an environment variable set under "env:"
+var envName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// DefaultFile is the configuration file read when CHATF5_CONFIG doesn't
+// name one, "" if there's no home directory
+func DefaultFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".chatf5", "config.yaml")
+}
+
+// loadFile reads the configuration file CHATF5_CONFIG names, or else
+// DefaultFile if it exists, and sets the environment variables its settings
+// stand in for, except those already set: the environment overrides the
+// file. It returns the file read, "" if none, and its profiles.
+func loadFile() (string, []Profile, error) {
+	path, settings, profiles, err := readFile()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, s := range settings {
+		if _, set := os.LookupEnv(s.name); !set {
+			os.Setenv(s.name, s.value)
+		}
+	}
+	return path, profiles, nil
+}
+
+// readFile reads the configuration file loadFile would, without setting
+// anything
+func readFile() (string, []setting, []Profile, error) {
+	path := os.Getenv("CHATF5_CONFIG")
+	if path == "" {
+		path = DefaultFile()
+		if _, err := os.Stat(path); path == "" || errors.Is(err, os.ErrNotExist) {
+			return "", nil, nil, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+	settings, profiles, err := parseFile(string(data))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid configuration file %s: %v", path, err)
+	}
+	return path, settings, profiles, nil
+}
+
+// setting is an environment variable the configuration file sets
+type setting struct {
+	name, value string
+}
+
+// parseFile reads the configuration file: YAML mappings of settings, such as
+//
+//	bigip:
+//	  host: 10.1.1.245
+//	  username: admin
+//	  devices:
+//	    lab: 10.1.1.245
+//	    prod: bigip.example.com:8443
+//	llm:
+//	  model: gpt-4o
+//	env:
+//	  NOTIFY_ROUTES: "info=log;critical=email"
+//	profiles:
+//	  staging:
+//	    host: 10.1.1.245
+//	    partition: Staging
+//
+// Lists of values are joined with commas. "env:" sets any environment
+// variable by name. Profiles take the device's own settings under "bigip:".
+func parseFile(data string) ([]setting, []Profile, error) {
+	var settings []setting
+	var profiles []Profile
+	profile := func(name string) *Profile {
+		for n := range profiles {
+			if profiles[n].Name == name {
+				return &profiles[n]
+			}
+		}
+		profiles = append(profiles, Profile{Name: name, settings: map[string]string{}})
+		return &profiles[len(profiles)-1]
+	}
+	index := map[string]int{}
+	add := func(name, value string, list bool) {
+		if n, ok := index[name]; ok && list {
+			settings[n].value += "," + value
+			return
+		}
+		if n, ok := index[name]; ok {
+			settings[n].value = value
+			return
+		}
+		index[name] = len(settings)
+		settings = append(settings, setting{name, value})
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("line %d: expected a mapping of settings", root.Line)
+	}
+
+	// walk reads the settings of a mapping whose keys' parent is parent
+	var walk func(parent string, node *yaml.Node) error
+	walk = func(parent string, node *yaml.Node) error {
+		for n := 0; n+1 < len(node.Content); n += 2 {
+			key, child := node.Content[n], node.Content[n+1]
+			path := key.Value
+			if parent != "" {
+				path = parent + "." + key.Value
+			}
+			if name, ok := strings.CutPrefix(path, "profiles."); ok && !strings.Contains(name, ".") {
+				profile(name)
+			}
+			switch child.Kind {
+			case yaml.MappingNode:
+				if err := walk(path, child); err != nil {
+					return err
+				}
+				continue
+			case yaml.SequenceNode:
+				name, err := settingName(path, key.Line)
+				if err != nil {
+					return err
+				}
+				for _, item := range child.Content {
+					if item.Kind != yaml.ScalarNode {
+						return fmt.Errorf("line %d: expected a value, got a %s", item.Line, kindName(item))
+					}
+					add(name, item.Value, true)
+				}
+				continue
+			case yaml.ScalarNode:
+				if child.Tag == "!!null" {
+					// An empty section: "profiles:" with none yet
+					continue
+				}
+			default:
+				return fmt.Errorf("line %d: expected a value, got a %s", child.Line, kindName(child))
+			}
+			value := child.Value
+			// A device is named by its key: "lab: 10.1.1.245"
+			if parent == "bigip.devices" {
+				add("BIGIP_DEVICES", key.Value+"="+value, true)
+				continue
+			}
+			if rest, ok := strings.CutPrefix(path, "profiles."); ok {
+				profileName, field, _ := strings.Cut(rest, ".")
+				name, err := settingName("bigip."+field, key.Line)
+				if err != nil || !profileSettings[name] {
+					return fmt.Errorf("line %d: unknown setting %q; profiles have the settings under \"bigip:\" other than devices", key.Line, path)
+				}
+				profile(profileName).settings[name] = value
+				continue
+			}
+			name, err := settingName(path, key.Line)
+			if err != nil {
+				return err
+			}
+			if name == "NO_COLOR" {
+				// NO_COLOR turns color off whatever its value
+				color, err := strconv.ParseBool(value)
+				if err != nil {
+					return fmt.Errorf("line %d: output.color is true or false, not %q", key.Line, value)
+				}
+				if color {
+					continue
+				}
+				value = "1"
+			}
+			if strings.HasPrefix(value, "~/") && (name == "LOG_FILE" || name == "TEMPLATE_DIR" || name == "AUDIT_LOG" || name == "CHANGE_JOURNAL" || name == "COMPLIANCE_RULES" || name == "ANOMALY_BASELINES") {
+				if home, err := os.UserHomeDir(); err == nil {
+					value = filepath.Join(home, value[2:])
+				}
+			}
+			add(name, value, false)
+		}
+		return nil
+	}
+	if err := walk("", root); err != nil {
+		return nil, nil, err
+	}
+	return settings, profiles, nil
+}
+
+// kindName names the kind of a YAML node in errors
+func kindName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.AliasNode:
+		return "alias"
+	}
+	return "value"
+}
+
+// settingName returns the environment variable a setting stands in for
+func settingName(path string, line int) (string, error) {
+	if name, ok := strings.CutPrefix(path, "env."); ok {
+		if !envName.MatchString(name) {
+			return "", fmt.Errorf("line %d: %q isn't an environment variable's name", line, name)
+		}
+		return name, nil
+	}
+	if name, ok := fileSettings[path]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("line %d: unknown setting %q", line, path)
+}
+
+// stripComment drops a comment, which starts with a "#" at the start of the
+// line or after a space, outside quoted values
+func stripComment(line string) string {
+	var quote rune
+	for n, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && (n == 0 || line[n-1] == ' '):
+			quote = r
+		case r == '#' && (n == 0 || line[n-1] == ' ' || line[n-1] == '\t'):
+			return strings.TrimRight(line[:n], " \t")
+		}
+	}
+	return line
+}
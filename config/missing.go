@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MissingError is returned by LoadConfig when settings it needs, such as
+// the BIG-IP's credentials, aren't set
+type MissingError struct {
+	// Names are the environment variables that aren't set
+	Names []string
+	// Profile is the profile in use and File the configuration file read,
+	// "" if none
+	Profile string
+	File    string
+}
+
+func (e *MissingError) Error() string {
+	file := e.File
+	if file == "" {
+		file = "~/.chatf5/config.yaml"
+	}
+	return fmt.Sprintf("missing required settings: %s must be set in the environment or in %s", strings.Join(e.Names, ", "), file)
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile is a named BIG-IP from the configuration file's "profiles:", with
+// its own host, credentials, TLS settings, timeouts, retries and default
+// partition. Settings a profile leaves out come from the BIGIP_ variables.
+type Profile struct {
+	Name string
+	// settings are the BIGIP_ variables the profile gives, by name
+	settings map[string]string
+}
+
+// Host is the profile's management address, "" if it doesn't give one
+func (p Profile) Host() string {
+	return p.settings["BIGIP_HOST"]
+}
+
+// value returns the variable name as the profile gives it, or else as the
+// environment does, def if neither does; p may be nil
+func (p *Profile) value(name, def string) string {
+	if p != nil {
+		if v, ok := p.settings[name]; ok {
+			return v
+		}
+	}
+	return stringEnv(name, def)
+}
+
+// profileSettings are the variables a profile can give: the device's own
+// settings, written under "bigip:" at the top level
+var profileSettings = map[string]bool{
+	"BIGIP_HOST":           true,
+	"BIGIP_USERNAME":       true,
+	"BIGIP_PASSWORD":       true,
+	"BIGIP_AUTH_METHOD":    true,
+	"BIGIP_LOGIN_PROVIDER": true,
+	"BIGIP_TLS_VERIFY":     true,
+	"BIGIP_CA_FILE":        true,
+	"BIGIP_PARTITION":      true,
+
+	"BIGIP_DIAL_TIMEOUT":       true,
+	"BIGIP_READ_TIMEOUT":       true,
+	"BIGIP_RETRY_MAX_ATTEMPTS": true,
+}
+
+// findProfile returns the profile named name, nil if name is ""
+func findProfile(profiles []Profile, name string) (*Profile, error) {
+	if name == "" {
+		return nil, nil
+	}
+	var names []string
+	for n := range profiles {
+		if profiles[n].Name == name {
+			return &profiles[n], nil
+		}
+		names = append(names, profiles[n].Name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no profile named %s: the configuration file has none", name)
+	}
+	return nil, fmt.Errorf("no profile named %s (profiles: %s)", name, strings.Join(names, ", "))
+}
+
+// setDevice sets the device's host, credentials, TLS settings, timeouts,
+// retries and default partition from the profile, if any, and the rest from
+// the environment
+func (c *Config) setDevice(p *Profile) error {
+	get := p.value
+	c.BigIPHost = get("BIGIP_HOST", "")
+	c.BigIPUsername = get("BIGIP_USERNAME", "")
+	c.BigIPPassword = get("BIGIP_PASSWORD", "")
+	c.BigIPAuthMethod = get("BIGIP_AUTH_METHOD", "basic")
+	c.BigIPLoginProvider = get("BIGIP_LOGIN_PROVIDER", "tmos")
+	verify, err := strconv.ParseBool(get("BIGIP_TLS_VERIFY", "false"))
+	if err != nil {
+		return fmt.Errorf("invalid BIGIP_TLS_VERIFY %q: %v", get("BIGIP_TLS_VERIFY", ""), err)
+	}
+	c.BigIPTLSVerify = verify
+	c.BigIPCAFile = get("BIGIP_CA_FILE", "")
+	for _, timeout := range []struct {
+		name  string
+		field *time.Duration
+	}{
+		{"BIGIP_DIAL_TIMEOUT", &c.BigIPDialTimeout},
+		{"BIGIP_READ_TIMEOUT", &c.BigIPReadTimeout},
+	} {
+		*timeout.field = 0
+		if v := get(timeout.name, ""); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid %s %q: use a duration such as 10s", timeout.name, v)
+			}
+			*timeout.field = d
+		}
+	}
+	c.RetryMaxAttempts = 0
+	if v := get("BIGIP_RETRY_MAX_ATTEMPTS", ""); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid BIGIP_RETRY_MAX_ATTEMPTS %q: %v", v, err)
+		}
+		c.RetryMaxAttempts = n
+	}
+	c.Partition = strings.Trim(get("BIGIP_PARTITION", ""), "/")
+	c.Profile = ""
+	if p != nil {
+		c.Profile = p.Name
+		if c.BigIPHost == "" {
+			return fmt.Errorf("profile %s has no host and BIGIP_HOST isn't set", p.Name)
+		}
+	}
+	return nil
+}
+
+// WithProfile returns a copy of the configuration for the device the
+// profile named name describes, as -profile would give
+func (c *Config) WithProfile(name string) (*Config, error) {
+	p, err := findProfile(c.Profiles, name)
+	if err != nil {
+		return nil, err
+	}
+	profiled := *c
+	if err := profiled.setDevice(p); err != nil {
+		return nil, err
+	}
+	return &profiled, nil
+}
+
+// ProfileNames are the names of the profiles, in the order the file gives
+func (c *Config) ProfileNames() []string {
+	names := make([]string, len(c.Profiles))
+	for n, p := range c.Profiles {
+		names[n] = p.Name
+	}
+	return names
+}
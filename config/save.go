@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SaveSettings writes settings, environment variables by name, to the
+// configuration file at path, creating it if need be; those of the device
+// go in the profile named profile, if any. Other lines of the file, comments
+// included, are kept. The file holds credentials, so it is made readable
+// only by its owner.
+func SaveSettings(path, profile string, settings map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var lines []string
+	if text := strings.TrimRight(string(data), "\n"); text != "" {
+		lines = strings.Split(text, "\n")
+	}
+
+	// Write them in a fixed order, so the file reads the same each time
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key, ok := fileKey(name)
+		if !ok {
+			key = "env." + name
+		}
+		if device, ok := strings.CutPrefix(key, "bigip."); ok && profile != "" && profileSettings[name] {
+			key = "profiles." + profile + "." + device
+		}
+		lines = setSetting(lines, strings.Split(key, "."), settings[name])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0o600)
+}
+
+// fileKey returns the file's setting for an environment variable
+func fileKey(name string) (string, bool) {
+	for key, n := range fileSettings {
+		if n == name && key != "output.color" {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// setSetting sets the value at path, such as bigip.password, in a file's
+// lines: in place if it's there, or else as the last entry of the deepest
+// mapping of path that is, adding the mappings missing
+func setSetting(lines []string, path []string, value string) []string {
+	// header is the line of the mapping found so far, -1 for the top level,
+	// and end the line its block ends before
+	header, end := -1, len(lines)
+	childIndent := 0
+	depth := 0
+	for ; depth < len(path); depth++ {
+		found := false
+		indent := -1
+		for n := header + 1; n < end; n++ {
+			trimmed := strings.TrimSpace(stripComment(lines[n]))
+			if trimmed == "" {
+				continue
+			}
+			lineIndent := len(lines[n]) - len(strings.TrimLeft(lines[n], " "))
+			if indent < 0 {
+				indent = lineIndent
+			}
+			if lineIndent != indent {
+				continue
+			}
+			key, _, ok := strings.Cut(trimmed, ":")
+			if !ok || key != path[depth] {
+				continue
+			}
+			if depth == len(path)-1 {
+				lines[n] = strings.Repeat(" ", lineIndent) + key + ": " + strconv.Quote(value)
+				return lines
+			}
+			header, end, found = n, blockEnd(lines, n, lineIndent), true
+			childIndent = lineIndent + 2
+			break
+		}
+		if !found {
+			if indent >= 0 {
+				childIndent = indent
+			}
+			break
+		}
+	}
+
+	var added []string
+	for d := depth; d < len(path); d++ {
+		line := strings.Repeat(" ", childIndent+2*(d-depth)) + path[d] + ":"
+		if d == len(path)-1 {
+			line += " " + strconv.Quote(value)
+		}
+		added = append(added, line)
+	}
+	// They go at the end of the mapping, before any blank lines
+	at := end
+	for at > header+1 && strings.TrimSpace(lines[at-1]) == "" {
+		at--
+	}
+	return append(lines[:at], append(added, lines[at:]...)...)
+}
+
+// blockEnd returns the line after the last one nested in line n
+func blockEnd(lines []string, n, indent int) int {
+	for m := n + 1; m < len(lines); m++ {
+		if strings.TrimSpace(stripComment(lines[m])) == "" {
+			continue
+		}
+		if len(lines[m])-len(strings.TrimLeft(lines[m], " ")) <= indent {
+			return m
+		}
+	}
+	return len(lines)
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Check is one thing Validate looked at, reported as a health check is
+type Check struct {
+	Name string
+	// Status is PASS, WARN, SKIP or FAIL
+	Status string
+	// Detail is what was found and, unless it passed, what to do about it
+	Detail string
+}
+
+// Validate checks the settings chatf5 would start with, without connecting
+// to anything: that the configuration file reads, that the device in use
+// and each profile's have a host and credentials, and that no options
+// conflict. It returns the checks and the configurations that loaded, the
+// one in use first, so the caller can check what only it knows how to, such
+// as whether the devices can be reached.
+func Validate() ([]Check, []*Config) {
+	var checks []Check
+	add := func(name, status, detail string) {
+		checks = append(checks, Check{name, status, detail})
+	}
+
+	path, settings, profiles, err := readFile()
+	switch {
+	case err != nil:
+		add("Configuration file", "FAIL", err.Error())
+		return checks, nil
+	case path == "":
+		add("Configuration file", "SKIP", fmt.Sprintf("none at %s; settings come from the environment", DefaultFile()))
+	default:
+		detail := fmt.Sprintf("%s: %d settings", path, len(settings))
+		switch len(profiles) {
+		case 0:
+		case 1:
+			detail += ", 1 profile"
+		default:
+			detail += fmt.Sprintf(", %d profiles", len(profiles))
+		}
+		add("Configuration file", "PASS", detail)
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0o077 != 0 {
+			add("File permissions", "WARN", fmt.Sprintf("others can read %s, which may hold credentials: run chmod 600 %s", path, path))
+		}
+		var secrets []string
+		for _, s := range settings {
+			if IsSecret(s.name) {
+				secrets = append(secrets, s.name)
+			}
+		}
+		for _, p := range profiles {
+			for name := range p.settings {
+				if IsSecret(name) {
+					secrets = append(secrets, "profile "+p.Name+" "+name)
+				}
+			}
+		}
+		if len(secrets) > 0 {
+			sort.Strings(secrets)
+			add("Secrets", "WARN", fmt.Sprintf("stored in plain text in %s: %s; set them in the environment instead, e.g. from a secrets manager", path, strings.Join(secrets, ", ")))
+		}
+	}
+	// This must come before LoadConfig sets the file's settings
+	var overridden []string
+	for _, s := range settings {
+		if v, set := os.LookupEnv(s.name); set && v != s.value {
+			overridden = append(overridden, s.name)
+		}
+	}
+	if len(overridden) > 0 {
+		add("Overrides", "WARN", fmt.Sprintf("set in the environment or by a flag, so the file's setting isn't used: %s", strings.Join(overridden, ", ")))
+	}
+
+	// The device in use, then each other profile's
+	current := os.Getenv("CHATF5_PROFILE")
+	defer os.Setenv("CHATF5_PROFILE", current)
+	names := []string{current}
+	for _, p := range profiles {
+		if p.Name != current {
+			names = append(names, p.Name)
+		}
+	}
+	var loaded []*Config
+	for n, name := range names {
+		os.Setenv("CHATF5_PROFILE", name)
+		label := "Settings"
+		if name != "" {
+			label = "Profile " + name
+		}
+		cfg, err := LoadConfig()
+		var missing *MissingError
+		switch {
+		case errors.As(err, &missing) && name == "" && len(profiles) > 0 && deviceOnly(missing.Names):
+			// Each profile gives its own device
+			add(label, "WARN", fixMissing(missing)+", or choose a profile with -profile NAME")
+			continue
+		case errors.As(err, &missing):
+			add(label, "FAIL", fixMissing(missing))
+			continue
+		case err != nil:
+			add(label, "FAIL", err.Error())
+			continue
+		}
+		loaded = append(loaded, cfg)
+
+		p, _ := findProfile(profiles, name)
+		fails, warnings := cfg.deviceProblems(p)
+		switch {
+		case len(fails) > 0:
+			add(label, "FAIL", strings.Join(append(fails, warnings...), "; "))
+		case len(warnings) > 0:
+			add(label, "WARN", strings.Join(warnings, "; "))
+		case cfg.Demo:
+			add(label, "PASS", "demo mode, so no device is needed")
+		default:
+			add(label, "PASS", cfg.describeDevice())
+		}
+		if n == 0 {
+			if conflicts := cfg.conflicts(); len(conflicts) > 0 {
+				add("Conflicts", "WARN", strings.Join(conflicts, "; "))
+			} else {
+				add("Conflicts", "PASS", "none found")
+			}
+		}
+	}
+	return checks, loaded
+}
+
+// deviceOnly reports whether names are all settings a profile can give
+func deviceOnly(names []string) bool {
+	for _, name := range names {
+		if !profileSettings[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// fixMissing says where the settings a MissingError names can be set
+func fixMissing(m *MissingError) string {
+	file := m.File
+	if file == "" {
+		file = DefaultFile()
+	}
+	var where []string
+	for _, name := range m.Names {
+		key, _ := fileKey(name)
+		if m.Profile != "" && profileSettings[name] {
+			key = "profiles." + m.Profile + "." + strings.TrimPrefix(key, "bigip.")
+		}
+		where = append(where, fmt.Sprintf("%s (%s)", name, key))
+	}
+	return fmt.Sprintf("%s not set: set them in the environment or in %s", strings.Join(where, ", "), file)
+}
+
+// describeDevice says which device the configuration asks, and how
+func (c *Config) describeDevice() string {
+	detail := fmt.Sprintf("%s as %s, %s auth", c.BigIPHost, c.BigIPUsername, c.BigIPAuthMethod)
+	if c.Partition != "" {
+		detail += ", partition " + c.Partition
+	}
+	if len(c.Devices) > 0 {
+		detail += fmt.Sprintf(", %d devices in BIGIP_DEVICES", len(c.Devices))
+	}
+	return detail
+}
+
+// deviceProblems returns what stops the device's settings, as the profile p
+// gives them, from working, and what works otherwise than it may seem to
+func (c *Config) deviceProblems(p *Profile) (fails, warnings []string) {
+	switch c.BigIPAuthMethod {
+	case "basic", "token":
+	default:
+		fails = append(fails, fmt.Sprintf("BIGIP_AUTH_METHOD is basic or token, not %q", c.BigIPAuthMethod))
+	}
+	if c.BigIPCAFile != "" {
+		pem, err := os.ReadFile(c.BigIPCAFile)
+		switch {
+		case err != nil:
+			fails = append(fails, fmt.Sprintf("can't read BIGIP_CA_FILE: %v", err))
+		case !x509.NewCertPool().AppendCertsFromPEM(pem):
+			fails = append(fails, fmt.Sprintf("no PEM certificates in BIGIP_CA_FILE %s", c.BigIPCAFile))
+		}
+		if p.value("BIGIP_TLS_VERIFY", "") != "" && !c.BigIPTLSVerify {
+			warnings = append(warnings, "BIGIP_TLS_VERIFY is false, but giving BIGIP_CA_FILE turns certificate checking on")
+		}
+	}
+	if c.BigIPAuthMethod != "token" && p.value("BIGIP_LOGIN_PROVIDER", "tmos") != "tmos" {
+		warnings = append(warnings, "BIGIP_LOGIN_PROVIDER is used only with BIGIP_AUTH_METHOD=token")
+	}
+	if c.RetryMaxAttempts < 0 {
+		fails = append(fails, fmt.Sprintf("BIGIP_RETRY_MAX_ATTEMPTS can't be negative (%d)", c.RetryMaxAttempts))
+	}
+	return fails, warnings
+}
+
+// conflicts returns the options set that another option makes no difference
+// to, or that make none
+func (c *Config) conflicts() []string {
+	var conflicts []string
+	if os.Getenv("OPENAI_API_KEY") != "" && os.Getenv("AZURE_OPENAI_API_KEY") != "" {
+		conflicts = append(conflicts, "both OPENAI_API_KEY and AZURE_OPENAI_API_KEY are set, and the Azure key is used")
+	}
+	if c.AzureOpenAIEndpoint != "" && keylessProvider(c.LLMProvider) {
+		conflicts = append(conflicts, fmt.Sprintf("AZURE_OPENAI_ENDPOINT is set, but LLM_PROVIDER=%s doesn't use it", c.LLMProvider))
+	}
+	if c.AzureOpenAIEndpoint != "" && c.OpenAIBaseURL != "" {
+		conflicts = append(conflicts, "OPENAI_BASE_URL is ignored when AZURE_OPENAI_ENDPOINT is set")
+	}
+	if c.NotifyEmailTo != "" && c.SMTPHost == "" {
+		conflicts = append(conflicts, "NOTIFY_EMAIL_TO is set, but no email is sent without SMTP_HOST")
+	}
+	if c.PagerDutyURL != "" && c.PagerDutyRoutingKey == "" {
+		conflicts = append(conflicts, "PAGERDUTY_EVENTS_URL is set, but nothing is sent to PagerDuty without PAGERDUTY_ROUTING_KEY")
+	}
+	if (c.SplunkHECToken != "" || c.SplunkIndex != "") && c.SplunkHECURL == "" {
+		conflicts = append(conflicts, "SPLUNK_HEC_TOKEN or SPLUNK_INDEX is set, but nothing is forwarded to Splunk without SPLUNK_HEC_URL")
+	}
+	if c.GrafanaDashboardUID != "" && c.GrafanaURL == "" {
+		conflicts = append(conflicts, "GRAFANA_DASHBOARD_UID is set, but no annotations are published without GRAFANA_URL")
+	}
+	if (c.IHealthURL != "" || c.IHealthTokenURL != "") && c.IHealthClientID == "" {
+		conflicts = append(conflicts, "IHEALTH_API_URL or IHEALTH_TOKEN_URL is set, but nothing is uploaded to iHealth without IHEALTH_CLIENT_ID")
+	}
+	if c.AgentMode && strings.EqualFold(c.LLMProvider, "rules") {
+		conflicts = append(conflicts, "AGENT_MODE needs an LLM, so it does nothing with LLM_PROVIDER=rules")
+	}
+	return conflicts
+}
@@ -0,0 +1,33 @@
+package e2e
+
+import (
+	"testing"
+
+	"f5chat/logging"
+)
+
+// TestScenarios runs DefaultScenarios as one session, as cmd/e2e does, and
+// reports each as a subtest. The scenarios build on each other, so they
+// can't be run one at a time; -run only picks which results are reported.
+func TestScenarios(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end scenarios skipped in -short mode")
+	}
+	if !testing.Verbose() {
+		logging.Discard()
+	}
+	results, err := Run(DefaultScenarios)
+	if err != nil {
+		t.Fatalf("e2e setup failed: %v", err)
+	}
+	if len(results) != len(DefaultScenarios) {
+		t.Errorf("ran %d of %d scenarios", len(results), len(DefaultScenarios))
+	}
+	for _, r := range results {
+		t.Run(r.Scenario, func(t *testing.T) {
+			if !r.Passed {
+				t.Error(r.Detail)
+			}
+		})
+	}
+}
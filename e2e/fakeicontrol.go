@@ -0,0 +1,595 @@
+package e2e
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"f5chat/bigip"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// routes maps iControl REST paths to recorded fixture files
+var routes = map[string]string{
+	"/mgmt/tm/ltm/virtual":                                          "fixtures/ltm_virtual.json",
+	"/mgmt/tm/ltm/virtual/stats":                                    "fixtures/ltm_virtual_stats.json",
+	"/mgmt/tm/ltm/pool":                                             "fixtures/ltm_pool.json",
+	"/mgmt/tm/ltm/pool/stats":                                       "fixtures/ltm_pool_stats.json",
+	"/mgmt/tm/ltm/pool/web_pool/members":                            "fixtures/ltm_pool_web_pool_members.json",
+	"/mgmt/tm/ltm/pool/api_pool/members":                            "fixtures/ltm_pool_api_pool_members.json",
+	"/mgmt/tm/ltm/pool/~Common~web_pool/members":                    "fixtures/ltm_pool_web_pool_members.json",
+	"/mgmt/tm/ltm/pool/~Common~api_pool/members":                    "fixtures/ltm_pool_api_pool_members.json",
+	"/mgmt/tm/ltm/pool/~Tenant_A~web_pool/members":                  "fixtures/ltm_pool_tenant_a_web_pool_members.json",
+	"/mgmt/tm/ltm/node":                                             "fixtures/ltm_node.json",
+	"/mgmt/tm/asm/policies":                                         "fixtures/asm_policies.json",
+	"/mgmt/tm/asm/events/requests":                                  "fixtures/asm_events_requests.json",
+	"/mgmt/tm/asm/policies/Vq4sZ1Yw0lHk2W8nA7sYfQ/owasp-compliance": "fixtures/asm_policies_vs_waf_owasp_compliance.json",
+	"/mgmt/tm/asm/policies/mZ3kT8rQ1pLx9cVb2nW4eA/owasp-compliance": "fixtures/asm_policies_portal_policy_owasp_compliance.json",
+	"/mgmt/tm/ltm/profile/http":                                     "fixtures/ltm_profile_http.json",
+	"/mgmt/tm/ltm/profile/client-ssl":                               "fixtures/ltm_profile_client_ssl.json",
+	"/mgmt/tm/ltm/virtual/~Common~vs_app1/profiles":                 "fixtures/ltm_virtual_vs_app1_profiles.json",
+	"/mgmt/tm/security/dos/profile":                                 "fixtures/security_dos_profile.json",
+	"/mgmt/tm/sys/version":                                          "fixtures/sys_version.json",
+	"/mgmt/tm/sys/log/ltm/stats":                                    "fixtures/sys_log_ltm_stats.json",
+	"/mgmt/tm/sys/cpu":                                              "fixtures/sys_cpu.json",
+	"/mgmt/tm/cm/device":                                            "fixtures/cm_device.json",
+	"/mgmt/tm/gtm/topology":                                         "fixtures/gtm_topology.json",
+	"/mgmt/tm/gtm/region":                                           "fixtures/gtm_region.json",
+	"/mgmt/tm/gtm/global-settings/load-balancing":                   "fixtures/gtm_global_settings_load_balancing.json",
+	"/mgmt/tm/cm/sync-status":                                       "fixtures/cm_sync_status.json",
+}
+
+// FakeIControl emulates the subset of the BIG-IP iControl REST API used by
+// the chat client, serving recorded fixtures over TLS with basic auth
+type FakeIControl struct {
+	*httptest.Server
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	failures  map[string][]int
+	requests  map[string]int
+	tokens    map[string]bool
+	nextToken int
+	// rules holds the iRules by full path, seeded from the fixture and
+	// added to by POST /mgmt/tm/ltm/rule
+	rules map[string]map[string]interface{}
+	// declarations holds the AS3 declaration deployed for each tenant, and
+	// tasks the results of each async AS3 request
+	declarations map[string]string
+	tasks        map[string][]map[string]interface{}
+	// edits change the objects a fixture collection serves (see EditItems)
+	edits map[string][]func([]interface{}) []interface{}
+	// qkviews holds the name of each qkview generated and not yet deleted,
+	// by task ID
+	qkviews map[string]string
+	// firewallLists holds the AFM lists by collection ("address-list",
+	// "port-list"), seeded from the fixtures and changed by PATCH
+	firewallLists map[string][]map[string]interface{}
+}
+
+// QkviewContent is what every qkview the fake device generates holds:
+// enough to take several chunks to download
+var QkviewContent = bytes.Repeat([]byte("qkview "), 400000)
+
+// NewFakeIControl starts a fake BIG-IP management endpoint
+func NewFakeIControl(username, password string) *FakeIControl {
+	f := &FakeIControl{
+		Username: username,
+		Password: password,
+		failures: make(map[string][]int),
+		requests: make(map[string]int),
+		tokens:   make(map[string]bool),
+		rules:    make(map[string]map[string]interface{}),
+
+		declarations: make(map[string]string),
+		tasks:        make(map[string][]map[string]interface{}),
+		edits:        make(map[string][]func([]interface{}) []interface{}),
+		qkviews:      make(map[string]string),
+
+		firewallLists: make(map[string][]map[string]interface{}),
+	}
+	if data, err := fixtures.ReadFile("fixtures/ltm_rule.json"); err == nil {
+		var collection struct {
+			Items []map[string]interface{} `json:"items"`
+		}
+		if json.Unmarshal(data, &collection) == nil {
+			for _, rule := range collection.Items {
+				f.rules[fmt.Sprint(rule["fullPath"])] = rule
+			}
+		}
+	}
+	for _, kind := range []string{"address-list", "port-list"} {
+		data, err := fixtures.ReadFile("fixtures/security_firewall_" + strings.ReplaceAll(kind, "-", "_") + ".json")
+		if err != nil {
+			continue
+		}
+		var collection struct {
+			Items []map[string]interface{} `json:"items"`
+		}
+		if json.Unmarshal(data, &collection) == nil {
+			f.firewallLists[kind] = collection.Items
+		}
+	}
+	f.Server = httptest.NewTLSServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// EditItems changes the objects served for a fixture collection from now
+// on, as if they had been changed on the device; edits to a path apply in
+// the order they were made
+func (f *FakeIControl) EditItems(path string, edit func(items []interface{}) []interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.edits[path] = append(f.edits[path], edit)
+}
+
+// Host returns the host:port to use as BIGIP_HOST
+func (f *FakeIControl) Host() string {
+	return strings.TrimPrefix(f.URL, "https://")
+}
+
+// FailNext makes the next requests to path fail with the given status codes,
+// one status per request, to exercise client retry handling
+func (f *FakeIControl) FailNext(path string, statuses ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[path] = append(f.failures[path], statuses...)
+}
+
+// Requests returns how many requests were made to path
+func (f *FakeIControl) Requests(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+// ExpireTokens invalidates every issued auth token, as happens when a
+// session sits idle past the token timeout
+func (f *FakeIControl) ExpireTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = make(map[string]bool)
+}
+
+// login implements POST /mgmt/shared/authn/login
+func (f *FakeIControl) login(w http.ResponseWriter, r *http.Request) {
+	var creds struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil || creds.Username != f.Username || creds.Password != f.Password {
+		writeError(w, http.StatusUnauthorized, "Authentication failed.")
+		return
+	}
+
+	f.mu.Lock()
+	f.requests[r.URL.Path]++
+	f.nextToken++
+	token := fmt.Sprintf("FAKETOKEN%04d", f.nextToken)
+	f.tokens[token] = true
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"username": creds.Username,
+		"token":    map[string]interface{}{"token": token, "timeout": 1200},
+	})
+}
+
+func (f *FakeIControl) authorized(r *http.Request) bool {
+	if token := r.Header.Get("X-F5-Auth-Token"); token != "" {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.tokens[token]
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok && user == f.Username && pass == f.Password
+}
+
+func (f *FakeIControl) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/mgmt/shared/authn/login" && r.Method == http.MethodPost {
+		f.login(w, r)
+		return
+	}
+	if !f.authorized(r) {
+		if r.Header.Get("X-F5-Auth-Token") != "" {
+			writeError(w, http.StatusUnauthorized, "X-F5-Auth-Token does not exist.")
+			return
+		}
+		writeError(w, http.StatusUnauthorized, "Authentication failed.")
+		return
+	}
+
+	f.mu.Lock()
+	f.requests[r.URL.Path]++
+	var injected int
+	if pending := f.failures[r.URL.Path]; len(pending) > 0 {
+		injected, f.failures[r.URL.Path] = pending[0], pending[1:]
+	}
+	f.mu.Unlock()
+
+	if injected != 0 {
+		slog.Debug("Fake iControl: injecting failure", "status", injected, "path", r.URL.Path)
+		message := fmt.Sprintf("injected failure for %s: %s", r.URL.Path, http.StatusText(injected))
+		if injected == http.StatusNotFound {
+			message = fmt.Sprintf("The requested URI (%s) was not found.", r.URL.Path)
+		}
+		writeError(w, injected, message)
+		return
+	}
+
+	if r.URL.Path == "/mgmt/tm/ltm/rule" || strings.HasPrefix(r.URL.Path, "/mgmt/tm/ltm/rule/") {
+		f.handleRules(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/mgmt/shared/appsvcs/") || strings.HasPrefix(r.URL.Path, "/mgmt/tm/auth/partition/") {
+		f.handleAS3(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/mgmt/cm/autodeploy/") {
+		f.handleQkview(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/mgmt/tm/security/firewall/") {
+		f.handleFirewallLists(w, r)
+		return
+	}
+
+	fixture, ok := routes[r.URL.Path]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The requested URI (%s) was not found.", r.URL.Path))
+		return
+	}
+	data, err := fixtures.ReadFile(fixture)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var collection map[string]interface{}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if items, ok := collection["items"].([]interface{}); ok {
+		f.mu.Lock()
+		for _, edit := range f.edits[r.URL.Path] {
+			items = edit(items)
+		}
+		f.mu.Unlock()
+		items = applyFilter(items, r.URL.Query().Get("$filter"))
+		collection["items"] = paginate(r, items, collection)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(collection)
+}
+
+// handleRules implements listing, fetching and creating iRules
+func (f *FakeIControl) handleRules(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/mgmt/tm/ltm/rule"), "/")
+	fullPath := "/Common/" + name
+	if strings.HasPrefix(name, "~") {
+		fullPath = strings.ReplaceAll(name, "~", "/")
+	}
+
+	switch {
+	case r.Method == http.MethodPost && name == "":
+		var rule map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil || rule["name"] == nil {
+			writeError(w, http.StatusBadRequest, "invalid iRule")
+			return
+		}
+		fullPath = "/Common/" + fmt.Sprint(rule["name"])
+		if _, exists := f.rules[fullPath]; exists {
+			writeError(w, http.StatusConflict, fmt.Sprintf("01020066:3: The requested iRule (%s) already exists in partition Common.", fullPath))
+			return
+		}
+		rule["kind"], rule["partition"], rule["fullPath"] = "tm:ltm:rule:rulestate", "Common", fullPath
+		f.rules[fullPath] = rule
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		json.NewEncoder(w).Encode(rule)
+	case r.Method == http.MethodGet && name == "":
+		items := make([]interface{}, 0, len(f.rules))
+		for _, rule := range f.rules {
+			items = append(items, rule)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{"kind": "tm:ltm:rule:rulecollectionstate", "items": items})
+	case r.Method == http.MethodGet:
+		rule, ok := f.rules[fullPath]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("01020036:3: The requested rule (%s) was not found.", fullPath))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		json.NewEncoder(w).Encode(rule)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Rule returns the TCL of an iRule on the fake device
+func (f *FakeIControl) Rule(fullPath string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rule, ok := f.rules[fullPath]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(rule["apiAnonymous"]), true
+}
+
+// handleFirewallLists implements listing, fetching and patching AFM
+// address and port lists
+func (f *FakeIControl) handleFirewallLists(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	kind, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/mgmt/tm/security/firewall/"), "/")
+	lists, ok := f.firewallLists[kind]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The requested URI (%s) was not found.", r.URL.Path))
+		return
+	}
+	if r.Method == http.MethodGet && name == "" {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{"kind": "tm:security:firewall:" + kind + ":" + kind + "collectionstate", "items": lists})
+		return
+	}
+	fullPath := "/Common/" + name
+	if strings.HasPrefix(name, "~") {
+		fullPath = strings.ReplaceAll(name, "~", "/")
+	}
+	var list map[string]interface{}
+	for _, l := range lists {
+		if l["fullPath"] == fullPath {
+			list = l
+		}
+	}
+	if list == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("01020036:3: The requested %s (%s) was not found.", strings.ReplaceAll(kind, "-", " "), fullPath))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var fields map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid "+kind)
+			return
+		}
+		for field, value := range fields {
+			list[field] = value
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(list)
+}
+
+// FirewallList returns the entries of one array of an AFM list on the fake
+// device, e.g. the "addresses" of address-list /Common/blocked_ips
+func (f *FakeIControl) FirewallList(kind, fullPath, field string) []string {
+	var names []string
+	for _, entry := range f.FirewallEntries(kind, fullPath, field) {
+		names = append(names, fmt.Sprint(entry["name"]))
+	}
+	return names
+}
+
+// FirewallEntries is FirewallList with each entry's fields, not only its name
+func (f *FakeIControl) FirewallEntries(kind, fullPath, field string) []map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []map[string]interface{}
+	for _, l := range f.firewallLists[kind] {
+		if l["fullPath"] != fullPath {
+			continue
+		}
+		entries, _ := l[field].([]interface{})
+		for _, e := range entries {
+			if entry, ok := e.(map[string]interface{}); ok {
+				found = append(found, entry)
+			}
+		}
+	}
+	return found
+}
+
+// handleAS3 implements async AS3 declarations, their tasks and the
+// partition lookup used to tell new tenants from existing ones
+func (f *FakeIControl) handleAS3(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/mgmt/shared/appsvcs/declare":
+		body, _ := io.ReadAll(r.Body)
+		tenants, err := bigip.AS3Tenants(string(body))
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "declaration is invalid: "+err.Error())
+			return
+		}
+		id := fmt.Sprintf("task-%d", len(f.tasks)+1)
+		var results []map[string]interface{}
+		for _, t := range tenants {
+			f.declarations[t] = string(body)
+			results = append(results, map[string]interface{}{"code": 200, "message": "success", "tenant": t})
+		}
+		f.tasks[id] = results
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id,
+			"results": []map[string]interface{}{{"code": 0, "message": "Declaration successfully submitted", "tenant": ""}}})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/mgmt/shared/appsvcs/declare/"):
+		declaration, ok := f.declarations[strings.TrimPrefix(r.URL.Path, "/mgmt/shared/appsvcs/declare/")]
+		if !ok {
+			// AS3 answers 204 for a tenant it doesn't manage
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		io.WriteString(w, declaration)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/mgmt/shared/appsvcs/task/"):
+		id := strings.TrimPrefix(r.URL.Path, "/mgmt/shared/appsvcs/task/")
+		results, ok := f.tasks[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("task %s not found", id))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "results": results})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/mgmt/tm/auth/partition/"):
+		name := strings.TrimPrefix(r.URL.Path, "/mgmt/tm/auth/partition/")
+		if _, ok := f.declarations[name]; !ok && name != "Common" {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("01020036:3: The requested partition (%s) was not found.", name))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"kind": "tm:auth:partition:partitionstate", "name": name, "fullPath": name})
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The requested URI (%s) was not found.", r.URL.Path))
+	}
+}
+
+// Declaration returns the AS3 declaration deployed for a tenant on the fake device
+func (f *FakeIControl) Declaration(tenant string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.declarations[tenant]
+	return d, ok
+}
+
+// handleQkview implements generating qkviews, which finish at once, their
+// chunked download and their deletion
+func (f *FakeIControl) handleQkview(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const tasks, downloads = "/mgmt/cm/autodeploy/qkview", "/mgmt/cm/autodeploy/qkview-download/"
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == tasks:
+		var request struct {
+			Name string `json:"name"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		id := fmt.Sprintf("qkview-%d", len(f.qkviews)+1)
+		f.qkviews[id] = request.Name
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "name": request.Name, "status": "SUCCEEDED"})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, tasks+"/"):
+		delete(f.qkviews, strings.TrimPrefix(r.URL.Path, tasks+"/"))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, downloads):
+		name := strings.TrimPrefix(r.URL.Path, downloads)
+		found := false
+		for _, n := range f.qkviews {
+			found = found || n == name
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("File %s not found", name))
+			return
+		}
+		// Content-Range: start-end/size, of which only start is used
+		var start, end, size int
+		fmt.Sscanf(r.Header.Get("Content-Range"), "%d-%d/%d", &start, &end, &size)
+		start = min(start, len(QkviewContent))
+		end = min(start+1<<20, len(QkviewContent))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Range", fmt.Sprintf("%d-%d/%d", start, end-1, len(QkviewContent)))
+		w.Write(QkviewContent[start:end])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The requested URI (%s) was not found.", r.URL.Path))
+	}
+}
+
+// Qkviews returns how many generated qkviews are still on the fake device
+func (f *FakeIControl) Qkviews() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.qkviews)
+}
+
+// filterExpr matches OData equality filters such as name eq 'VS_WAF'
+var filterExpr = regexp.MustCompile(`^(\w+) eq (?:'((?:[^']|'')*)'|(\S+))$`)
+
+// applyFilter supports "name eq X" and "fullPath eq '/Common/X'" OData filters
+func applyFilter(items []interface{}, filter string) []interface{} {
+	if filter == "" {
+		return items
+	}
+	m := filterExpr.FindStringSubmatch(strings.TrimSpace(filter))
+	if m == nil {
+		return items
+	}
+	field, want := m[1], m[3]
+	if want == "" {
+		want = strings.ReplaceAll(m[2], "''", "'")
+	}
+	var out []interface{}
+	for _, item := range items {
+		if obj, ok := item.(map[string]interface{}); ok && obj[field] == want {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// paginate honours $top/$skip the way iControl REST does, adding nextLink
+// and paging metadata to the collection when a page is requested
+func paginate(r *http.Request, items []interface{}, collection map[string]interface{}) []interface{} {
+	q := r.URL.Query()
+	top, err := strconv.Atoi(q.Get("$top"))
+	if err != nil || top <= 0 {
+		return items
+	}
+	skip, _ := strconv.Atoi(q.Get("$skip"))
+	if skip > len(items) {
+		skip = len(items)
+	}
+	end := skip + top
+	if end > len(items) {
+		end = len(items)
+	}
+	collection["currentItemCount"] = end - skip
+	collection["itemsPerPage"] = top
+	collection["totalItems"] = len(items)
+	collection["startIndex"] = skip + 1
+	if end < len(items) {
+		next := *r.URL
+		q.Set("$skip", strconv.Itoa(end))
+		next.RawQuery = q.Encode()
+		collection["nextLink"] = "https://localhost" + next.RequestURI()
+	}
+	return items[skip:end]
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":       status,
+		"message":    message,
+		"errorStack": []string{},
+	})
+}
@@ -0,0 +1,98 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// FakeIHealth emulates the iHealth API and its sign-in: uploads of the
+// fake device's qkviews are analyzed at once, matching the same two
+// heuristics each time
+type FakeIHealth struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu      sync.Mutex
+	uploads int
+}
+
+// fakeIHealthToken is the access token the fake hands out
+const fakeIHealthToken = "e2e-ihealth-token"
+
+// NewFakeIHealth starts a fake iHealth
+func NewFakeIHealth(clientID, clientSecret string) *FakeIHealth {
+	f := &FakeIHealth{ClientID: clientID, ClientSecret: clientSecret}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// APIURL and TokenURL are the endpoints to configure the client with
+func (f *FakeIHealth) APIURL() string   { return f.URL + "/qkview-analyzer/api" }
+func (f *FakeIHealth) TokenURL() string { return f.URL + "/oauth2/v1/token" }
+
+func (f *FakeIHealth) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/oauth2/v1/token" {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != f.ClientID || secret != f.ClientSecret || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error": "invalid_client", "error_description": "The client secret supplied for a confidential client is invalid."}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"token_type": "Bearer", "expires_in": 1800, "access_token": fakeIHealthToken})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+fakeIHealthToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"message": "Unauthorized"}`)
+		return
+	}
+
+	const qkviews = "/qkview-analyzer/api/qkviews"
+	w.Header().Set("Content-Type", "application/vnd.f5.ihealth.api+json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == qkviews:
+		file, _, err := r.FormFile("qkview")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"message": "no qkview in the upload"}`)
+			return
+		}
+		// Only the fake device's qkviews are taken, and whole
+		content, _ := io.ReadAll(file)
+		if string(content) != string(QkviewContent) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"message": "the qkview is corrupt (%d bytes)"}`, len(content))
+			return
+		}
+		f.mu.Lock()
+		f.uploads++
+		id := 24681356 + f.uploads
+		f.mu.Unlock()
+		w.Header().Set("Location", fmt.Sprintf("%s%s/%d", f.URL, qkviews, id))
+		w.WriteHeader(http.StatusSeeOther)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/diagnostics"):
+		if r.URL.Query().Get("set") != "hit" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"diagnostics": [
+  {"name": "H729021", "results": {"h_importance": "HIGH", "h_header": "The HTTP profile allows oversized headers",
+    "h_summary": "Large headers can exhaust TMM memory under attack.", "h_action": "Lower max-header-size on /Common/http.",
+    "h_sols": ["https://my.f5.com/manage/s/article/K41104424"]}},
+  {"name": "H701182", "results": {"h_importance": "CRITICAL", "h_header": "The BIG-IP version is affected by a known vulnerability",
+    "h_summary": "17.1.1.3 is affected by CVE-2024-21793.", "h_action": "Upgrade to 17.1.1.4 or later.",
+    "h_sols": [{"value": "https://my.f5.com/manage/s/article/K000138733"}]}}
+]}`)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, qkviews+"/"):
+		io.WriteString(w, `{"hostname": "bigip1.example.com", "processing_status": "COMPLETE"}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message": "Not found"}`)
+	}
+}
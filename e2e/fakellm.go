@@ -0,0 +1,566 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"f5chat/llm"
+)
+
+// FakeLLM is an OpenAI-compatible chat completions endpoint. When tools are
+// offered it stands in for the model's choice with simple keyword rules;
+// otherwise it echoes the user's message back.
+type FakeLLM struct {
+	*httptest.Server
+
+	completions atomic.Int64
+
+	mu       sync.Mutex
+	failures []int
+	bodies   []string
+	// charge is added to the token usage of the next completion
+	charge int
+}
+
+// NewFakeLLM starts a fake chat completions server
+func NewFakeLLM() *FakeLLM {
+	f := &FakeLLM{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		f.completions.Add(1)
+		if status := f.nextFailure(); status != 0 {
+			// Ask for an immediate retry so scenarios stay fast
+			w.Header().Set("Retry-After", "0")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]string{"message": http.StatusText(status), "type": "fake_error"},
+			})
+			return
+		}
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+			Tools      []json.RawMessage `json:"tools"`
+			ToolChoice json.RawMessage   `json:"tool_choice"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.record(body)
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var reply string
+		var earlier []string
+		for _, m := range req.Messages {
+			if m.Role == "user" {
+				if reply != "" {
+					earlier = append(earlier, reply)
+				}
+				reply = m.Content
+			}
+		}
+
+		message := map[string]interface{}{"role": "assistant", "content": reply}
+		finishReason := "stop"
+		callTool := func(name string, args map[string]string) {
+			arguments, _ := json.Marshal(args)
+			message = map[string]interface{}{
+				"role": "assistant",
+				"tool_calls": []map[string]interface{}{{
+					"id":       "call_fake",
+					"type":     "function",
+					"function": map[string]string{"name": name, "arguments": string(arguments)},
+				}},
+			}
+			finishReason = "tool_calls"
+		}
+		var (
+			agent       bool
+			toolResults []string
+		)
+		for _, m := range req.Messages {
+			if m.Role == "system" && strings.HasPrefix(m.Content, "You are troubleshooting") {
+				agent = true
+			}
+			if m.Role == "tool" {
+				toolResults = append(toolResults, m.Content)
+			}
+			if m.Role == "system" && strings.HasPrefix(m.Content, "You write F5 BIG-IP iRules") {
+				message["content"] = writeIRule(reply)
+			}
+			if m.Role == "system" && strings.HasPrefix(m.Content, "You explain F5 BIG-IP iRules") {
+				message["content"] = explainIRule(reply)
+			}
+			if m.Role == "system" && strings.HasPrefix(m.Content, "You write F5 AS3") {
+				message["content"] = writeAS3(reply)
+			}
+			if m.Role == "system" && strings.HasPrefix(m.Content, "You write Ansible playbooks for F5") {
+				message["content"] = writeAnsible(reply)
+			}
+			if m.Role == "system" && strings.HasPrefix(m.Content, "You triage F5 BIG-IP WAF") {
+				message["content"] = triageViolations(reply)
+			}
+			if m.Role == "system" && strings.HasPrefix(m.Content, "You translate F5 BIG-IP tmsh") {
+				message["content"] = "This command runs `" + reply + "` on the device.\n\nImpact: it changes the configuration; run \"save sys config\" to persist it."
+			}
+		}
+		if strings.Contains(string(req.ToolChoice), "label_blast_radius") {
+			// The guardrail's second pass
+			risk, reason := labelRisk(reply)
+			arguments, _ := json.Marshal(map[string]string{"risk": risk, "reason": reason})
+			message = map[string]interface{}{
+				"role": "assistant",
+				"tool_calls": []map[string]interface{}{{
+					"id":       "call_fake",
+					"type":     "function",
+					"function": map[string]string{"name": "label_blast_radius", "arguments": string(arguments)},
+				}},
+			}
+			finishReason = "tool_calls"
+		} else if agent {
+			if name, args := agentStep(reply, len(toolResults)); name != "" && len(req.Tools) > 0 {
+				callTool(name, args)
+			} else {
+				message["content"] = agentAnswer(toolResults)
+			}
+		} else if len(req.Tools) > 0 {
+			name, args := chooseTool(reply)
+			if name == "" && refersBack(reply) {
+				// Resolve follow-ups against the most recent earlier question
+				for j := len(earlier) - 1; j >= 0 && name == ""; j-- {
+					name, args = chooseTool(earlier[j])
+				}
+			}
+			if name != "" {
+				callTool(name, args)
+			}
+		}
+
+		// Roughly four bytes per token, as a real model reports
+		content, _ := json.Marshal(message)
+		promptTokens, completionTokens := len(body)/4+f.takeCharge(), len(content)/4
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-fake",
+			"object":  "chat.completion",
+			"created": time.Now().Unix(),
+			"model":   req.Model,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"finish_reason": finishReason,
+				"message":       message,
+			}},
+			"usage": map[string]int{
+				"prompt_tokens":     promptTokens,
+				"completion_tokens": completionTokens,
+				"total_tokens":      promptTokens + completionTokens,
+			},
+		})
+	})
+	mux.HandleFunc("/v1/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data := make([]map[string]interface{}, len(req.Input))
+		for i, text := range req.Input {
+			data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": embedWords(text)}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "model": req.Model, "data": data})
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"data":   []map[string]interface{}{{"id": "gpt-3.5-turbo", "object": "model", "owned_by": "openai"}},
+		})
+	})
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+// Completions returns how many chat completions have been requested
+func (f *FakeLLM) Completions() int {
+	return int(f.completions.Load())
+}
+
+func (f *FakeLLM) record(body []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bodies = append(f.bodies, string(body))
+}
+
+// Received reports whether any chat completion request body so far
+// contained s, and forgets the bodies
+func (f *FakeLLM) Received(s string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	found := false
+	for _, b := range f.bodies {
+		found = found || strings.Contains(b, s)
+	}
+	f.bodies = nil
+	return found
+}
+
+// FailNext makes the next chat completions fail with the given status
+// codes, one status per request, to exercise client retry handling
+func (f *FakeLLM) FailNext(statuses ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, statuses...)
+}
+
+// ChargeNext makes the next chat completion report tokens more usage, to
+// exercise spend limits
+func (f *FakeLLM) ChargeNext(tokens int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.charge += tokens
+}
+
+func (f *FakeLLM) takeCharge() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	charge := f.charge
+	f.charge = 0
+	return charge
+}
+
+func (f *FakeLLM) nextFailure() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.failures) == 0 {
+		return 0
+	}
+	status := f.failures[0]
+	f.failures = f.failures[1:]
+	return status
+}
+
+// chooseTool maps a query onto one of the chat tools the way the model
+// would, taking the name after "policy", "pool" or "irule" as the object
+func chooseTool(query string) (string, map[string]string) {
+	words := strings.Fields(query)
+	lower := strings.ToLower(query)
+	nameAfter := func(keyword string) string {
+		if name := llm.ExtractName(query, keyword); name != "" {
+			return name
+		}
+		// A redaction placeholder stands in for the name
+		for i, w := range words {
+			if strings.EqualFold(w, keyword) && i+1 < len(words) && strings.HasPrefix(words[i+1], "[REDACTED_") {
+				return words[i+1]
+			}
+		}
+		return ""
+	}
+
+	if call, ok := llm.ParseCompare(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseAddToFirewallList(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseFirewallList(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseGTMTopology(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseTroubleshoot(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseOWASPCompliance(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseSecurityPosture(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseWAFViolations(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseChangeJournal(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseHealthSummary(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseExportTerraform(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseAnsible(query); ok {
+		return call.Name, call.Args
+	}
+	if call, ok := llm.ParseQkview(query); ok {
+		return call.Name, call.Args
+	}
+	switch {
+	case strings.Contains(lower, "as3") || strings.Contains(lower, "https app") || strings.Contains(lower, "http app"):
+		return llm.ToolGenerateAS3, map[string]string{"description": query}
+	case strings.Contains(lower, "irule") && nameAfter("irule") != "" && !strings.Contains(lower, "write") && !strings.Contains(lower, "create"):
+		return llm.ToolExplainIRule, map[string]string{"name": nameAfter("irule")}
+	case strings.Contains(lower, "irule") && (strings.Contains(lower, "write") || strings.Contains(lower, "create")):
+		args := map[string]string{"requirement": query}
+		if strings.Contains(lower, "redirect") {
+			args["name"] = "redirect_old_path"
+		}
+		return llm.ToolGenerateIRule, args
+	case strings.Contains(lower, "waf") || strings.Contains(lower, "asm") || strings.Contains(lower, "polic"):
+		if name := nameAfter("policy"); name != "" {
+			return llm.ToolGetWAFPolicy, map[string]string{"name": name}
+		}
+		return llm.ToolListWAFPolicies, map[string]string{}
+	case strings.Contains(lower, "virtual"):
+		return llm.ToolListVirtualServers, map[string]string{}
+	case strings.Contains(lower, "pool"):
+		if name := nameAfter("pool"); name != "" {
+			return llm.ToolGetPool, map[string]string{"name": name}
+		}
+		return llm.ToolListPools, map[string]string{}
+	case strings.Contains(lower, "node"):
+		return llm.ToolListNodes, map[string]string{}
+	}
+	return "", nil
+}
+
+// agentStep stands in for the model troubleshooting step by step: it
+// follows the traffic path from the virtual servers to the nodes, and never
+// stops on its own when asked to check everything
+func agentStep(query string, done int) (string, map[string]string) {
+	if strings.Contains(strings.ToLower(query), "everything") {
+		return llm.ToolListNodes, map[string]string{}
+	}
+	plan := []struct {
+		name string
+		args map[string]string
+	}{
+		{llm.ToolListVirtualServers, map[string]string{}},
+		{llm.ToolGetPool, map[string]string{"name": "web_pool"}},
+		{llm.ToolListNodes, map[string]string{}},
+	}
+	if done >= len(plan) {
+		return "", nil
+	}
+	return plan[done].name, plan[done].args
+}
+
+// agentAnswer stands in for the model's conclusion from the tool results
+func agentAnswer(results []string) string {
+	for _, r := range results {
+		if strings.Contains(strings.ToLower(r), "down") || strings.Contains(strings.ToLower(r), "offline") {
+			return fmt.Sprintf("Likely cause: a pool member is down, found after %d checks.", len(results))
+		}
+	}
+	return fmt.Sprintf("No problem found after %d checks.", len(results))
+}
+
+// urlPaths matches paths such as /old-path in a requirement
+var urlPaths = regexp.MustCompile(`(?:^|\s)(/[\w./-]*)`)
+
+// writeIRule stands in for the model writing an iRule: a redirect between
+// the first two paths in the requirement
+func writeIRule(requirement string) string {
+	from, to := "/old-path", "/new-path"
+	if paths := urlPaths.FindAllStringSubmatch(requirement, 2); len(paths) == 2 {
+		from, to = paths[0][1], paths[1][1]
+	}
+	return "```tcl\nwhen HTTP_REQUEST {\n    if { [HTTP::path] eq \"" + from + "\" } {\n" +
+		"        HTTP::redirect \"" + to + "[expr {[HTTP::query] ne \"\" ? \"?[HTTP::query]\" : \"\"}]\"\n    }\n}\n```\n\n" +
+		"This iRule redirects requests for " + from + " to " + to + ", keeping the query string.\n\n" +
+		"Pitfalls:\n- The virtual server needs an HTTP profile."
+}
+
+// ipv4 matches the addresses in an application description
+var ipv4 = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+
+// writeAS3 stands in for the model writing an AS3 declaration: an HTTPS
+// service on the first address in the description, load balancing to the
+// others, in a tenant named after the virtual address
+func writeAS3(description string) string {
+	addresses := ipv4.FindAllString(description, -1)
+	if len(addresses) == 0 {
+		return "Which address should the application listen on?"
+	}
+	vip, members := addresses[0], addresses[1:]
+	tenant := "app_" + strings.ReplaceAll(vip, ".", "_")
+	serverAddresses, _ := json.Marshal(members)
+	return "```json\n" + `{
+  "class": "AS3",
+  "action": "deploy",
+  "persist": true,
+  "declaration": {
+    "class": "ADC",
+    "schemaVersion": "3.50.0",
+    "` + tenant + `": {
+      "class": "Tenant",
+      "app": {
+        "class": "Application",
+        "template": "generic",
+        "service": {"class": "Service_HTTPS", "virtualAddresses": ["` + vip + `"], "redirect80": true, "pool": "web_pool", "serverTLS": "tls"},
+        "web_pool": {"class": "Pool", "monitors": ["http"], "members": [{"servicePort": 80, "serverAddresses": ` + string(serverAddresses) + `}]},
+        "tls": {"class": "TLS_Server", "certificates": [{"certificate": "cert"}]},
+        "cert": {"class": "Certificate", "certificate": {"bigip": "/Common/default.crt"}, "privateKey": {"bigip": "/Common/default.key"}}
+      }
+    }
+  }
+}` + "\n```\n\n" +
+		"This creates tenant " + tenant + " with an HTTPS virtual server on " + vip + ":443 that redirects port 80 to HTTPS.\n\n" +
+		"Pitfalls:\n- It uses the self-signed default certificate."
+}
+
+// memberChange matches the member and pool in a described change: "adds
+// 10.1.20.13:80 to web_pool"
+var memberChange = regexp.MustCompile(`(\d{1,3}(?:\.\d{1,3}){3}):(\d+)\s+(?:to|in|into)\s+(?:pool\s+)?(\w+)`)
+
+// writeAnsible stands in for the model writing a playbook: a task adding
+// the member the description names to its pool
+func writeAnsible(description string) string {
+	m := memberChange.FindStringSubmatch(description)
+	if m == nil {
+		return "Which member and pool should the playbook change?"
+	}
+	address, port, pool := m[1], m[2], m[3]
+	return "```yaml\n" + `---
+- name: Add ` + address + `:` + port + ` to ` + pool + `
+  hosts: localhost
+  connection: local
+  gather_facts: false
+  vars:
+    provider:
+      server: "{{ bigip_server }}"
+      user: "{{ bigip_user }}"
+      password: "{{ bigip_password }}"
+      validate_certs: true
+  tasks:
+    - name: Add ` + address + `:` + port + ` to ` + pool + `
+      f5networks.f5_modules.bigip_pool_member:
+        provider: "{{ provider }}"
+        pool: ` + pool + `
+        partition: Common
+        address: "` + address + `"
+        port: ` + port + `
+        reuse_nodes: true
+        state: present` + "\n```\n\n" +
+		"This adds " + address + ":" + port + " to pool " + pool + ", creating its node if there isn't one.\n\n" +
+		"Pitfalls:\n- The pool has to exist already."
+}
+
+// iruleEvents matches the event handlers in an iRule
+var iruleEvents = regexp.MustCompile(`\bwhen\s+([A-Z_]+)`)
+
+// explainIRule stands in for the model explaining an iRule: it names the
+// events the rule handles
+func explainIRule(content string) string {
+	var events []string
+	for _, m := range iruleEvents.FindAllStringSubmatch(content, -1) {
+		events = append(events, m[1])
+	}
+	return "Summary: this iRule handles " + strings.Join(events, ", ") + ".\n\n" +
+		"Pitfalls:\n- HTTP events need an HTTP profile on the virtual server."
+}
+
+// violationGroup is a group line of the WAF violations digest: "[BLOCKED]
+// 32 (82% of blocks)  SQL-Injection  POST /login", then "from 3 IPs: ..."
+var violationGroup = regexp.MustCompile(`\[BLOCKED\] \d+ \((\d+%) of blocks\)  (.+?)  \S+ (\S+)\n\s+from (\d+ IPs?)`)
+
+// triageViolations summarizes the largest group of blocks in the digest
+func triageViolations(digest string) string {
+	m := violationGroup.FindStringSubmatch(digest)
+	if m == nil {
+		return "Summary: nothing was blocked."
+	}
+	return fmt.Sprintf("Summary: %s of blocks are %s attempts against %s from %s.\n\n"+
+		"Recommended actions:\n- Block or rate-limit the top sources.", m[1], m[2], m[3], m[4])
+}
+
+// labelRisk stands in for the guardrail model, judging only the "Request:"
+// line so the selected operation can't sway it
+func labelRisk(content string) (string, string) {
+	request := strings.ToLower(strings.SplitN(strings.TrimPrefix(content, "Request: "), "\n", 2)[0])
+	for _, w := range strings.Fields(request) {
+		switch strings.Trim(w, ".,!?") {
+		case "delete", "remove", "disable", "replace", "attach", "apply":
+			return "disruptive", "the request changes live configuration"
+		}
+	}
+	for _, w := range strings.Fields(request) {
+		switch strings.Trim(w, ".,!?") {
+		case "create", "add", "upload", "write":
+			return "low-risk", "the request creates a new object"
+		}
+	}
+	return "read-only", "the request only asks for information"
+}
+
+// refersBack reports whether a query points at an earlier answer ("its
+// members", "that one") rather than naming an object itself
+func refersBack(query string) bool {
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		switch strings.Trim(w, ".,!?") {
+		case "it", "its", "that", "those", "them", "one":
+			return true
+		}
+	}
+	return false
+}
+
+// embedDims is the size of the fake embedding vectors
+const embedDims = 256
+
+// embedWords is a stand-in for a real embedding model: a normalised bag of
+// hashed words, so texts sharing vocabulary score as similar
+func embedWords(text string) []float32 {
+	vec := make([]float32, embedDims)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) < 3 || stopWords[w] {
+			continue
+		}
+		h := fnv.New32a()
+		h.Write([]byte(w))
+		vec[h.Sum32()%embedDims]++
+	}
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		for i := range vec {
+			vec[i] /= float32(math.Sqrt(norm))
+		}
+	}
+	return vec
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "what": true, "does": true,
+	"this": true, "that": true, "with": true, "you": true, "can": true, "how": true,
+}
+
+// BaseURL returns the value to use as OPENAI_BASE_URL
+func (f *FakeLLM) BaseURL() string {
+	return f.URL + "/v1"
+}
@@ -0,0 +1,1394 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"f5chat/audit"
+	"f5chat/bigip"
+	"f5chat/chat"
+	"f5chat/config"
+	"f5chat/history"
+	"f5chat/ihealth"
+	"f5chat/journal"
+	"f5chat/llm"
+	"f5chat/snapshot"
+)
+
+// Scenario is a single chat turn run end to end against the fakes
+type Scenario struct {
+	Name  string
+	Query string
+	// Setup runs before the query, typically to inject failures
+	Setup func(f *FakeIControl)
+	// SetupLLM runs before the query to inject LLM API failures
+	SetupLLM func(f *FakeLLM)
+	// Expect lists substrings that must appear in the response (or the
+	// error message when ExpectError is set)
+	Expect      []string
+	ExpectError bool
+	// ExpectStreamed lists substrings that must appear in what the query
+	// showed ahead of its answer, as the pages of a long listing were read
+	ExpectStreamed []string
+	// Check runs after the query for assertions on server-side behaviour
+	Check func(f *FakeIControl) error
+	// CheckLLM is given the number of chat completions the query made
+	CheckLLM func(completions int) error
+	// NotSentToLLM lists substrings that must not appear in any chat
+	// completion request the query made
+	NotSentToLLM []string
+}
+
+// Result records the outcome of a scenario
+type Result struct {
+	Scenario string
+	Passed   bool
+	Detail   string
+	Duration time.Duration
+}
+
+// DefaultScenarios covers listing, formatting and retry behaviour for each
+// supported object type
+var DefaultScenarios = []Scenario{
+	{
+		Name:  "list virtual servers",
+		Query: "show virtual servers",
+		Expect: []string{"=== Virtual Servers (VIPs) ===", "NAME     DESTINATION             POOL              STATUS",
+			"vs_app1  /Common/10.1.10.80:443  /Common/web_pool  enabled", `Ask for them "in detail"`},
+	},
+	{
+		Name:   "health check",
+		Query:  "/health",
+		Expect: []string{"[PASS] Reachability", "[PASS] Credentials", "BIG-IP 16.1.3", "[PASS] ASM module", "[PASS] LLM API", "All checks passed"},
+	},
+	{
+		Name:   "common request routed by the intent classifier",
+		Query:  "list all virtual servers",
+		Expect: []string{"=== Virtual Servers (VIPs) ===", "vs_app1"},
+		CheckLLM: func(completions int) error {
+			if completions != 0 {
+				return fmt.Errorf("expected the classifier to skip the LLM, saw %d chat completion(s)", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "list pools with members",
+		Query:  "list all pools and their members in detail",
+		Expect: []string{"=== Server Pools ===", "web_pool", "/Common/web1:80", "/Common/web2:80", "least-connections-member", "No members configured"},
+	},
+	{
+		Name:   "single pool by name",
+		Query:  "show pool web_pool",
+		Expect: []string{"web_pool", "/Common/web1:80"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/ltm/pool/~Common~api_pool/members"); n != 1 {
+				return fmt.Errorf("expected the cached pool listing to be reused, saw %d member request(s) for api_pool", n)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "follow-up resolved from conversation history",
+		Query:  "what members does it have?",
+		Expect: []string{"web_pool", "/Common/web1:80"},
+	},
+	{
+		Name:   "general question answered without a tool",
+		Query:  "hello, what can you do?",
+		Expect: []string{"hello, what can you do?"},
+	},
+	{
+		Name:   "conceptual question grounded in documentation",
+		Query:  "explain snat automap",
+		Expect: []string{"explain snat automap", "Sources: snat.md"},
+	},
+	func() Scenario {
+		paths := []string{"/mgmt/tm/ltm/virtual", "/mgmt/tm/ltm/pool", "/mgmt/tm/ltm/node"}
+		before := make(map[string]int)
+		return Scenario{
+			Name:  "concept question answered without device calls",
+			Query: "what is the difference between a node and a pool member?",
+			Setup: func(f *FakeIControl) {
+				for _, path := range paths {
+					before[path] = f.Requests(path)
+				}
+			},
+			Expect: []string{"difference between a node and a pool member"},
+			Check: func(f *FakeIControl) error {
+				for _, path := range paths {
+					if n := f.Requests(path) - before[path]; n != 0 {
+						return fmt.Errorf("expected no request to %s, got %d", path, n)
+					}
+				}
+				return nil
+			},
+		}
+	}(),
+	{
+		Name:     "LLM rate limiting retried",
+		Query:    "which nodes are down?",
+		SetupLLM: func(f *FakeLLM) { f.FailNext(429, 503) },
+		Expect:   []string{"=== Backend Nodes ===", "10.1.20.12"},
+		CheckLLM: func(completions int) error {
+			if completions != 3 {
+				return fmt.Errorf("expected two retries after 429 and 503, saw %d chat completion(s)", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:     "LLM rate limiting outlasts retries",
+		Query:    "which nodes are down?",
+		SetupLLM: func(f *FakeLLM) { f.FailNext(429, 429, 429) },
+		Expect:   []string{"rate limiting requests right now"},
+	},
+	{
+		Name:         "hostnames redacted before reaching the LLM",
+		Query:        "show pool app.example.com",
+		ExpectError:  true,
+		Expect:       []string{"pool 'app.example.com' not found"},
+		NotSentToLLM: []string{"app.example.com"},
+	},
+	{
+		Name:   "redaction report",
+		Query:  "/redactions",
+		Expect: []string{"Data redacted before it was sent to the LLM", "0 credential(s)"},
+	},
+	{
+		Name:   "list nodes",
+		Query:  "display node status",
+		Expect: []string{"=== Backend Nodes ===", "web1", "10.1.20.12", "down"},
+	},
+	{
+		Name:   "list WAF policies",
+		Query:  "show WAF policies with their virtual servers in detail",
+		Expect: []string{"Found 2 WAF Policies", "VS_WAF", "/Common/VS_WAF", "Enforcement Mode: blocking", "Not currently applied to any Virtual Servers"},
+	},
+	{
+		Name:   "WAF policy details by name",
+		Query:  "get waf policy VS_WAF",
+		Expect: []string{"=== WAF Policy Details: VS_WAF ===", "Enforcement Mode: blocking", "Configuration Path: /Common/VS_WAF"},
+	},
+	{
+		Name:   "WAF policy details by full path",
+		Query:  "get waf policy /Common/portal_policy",
+		Expect: []string{"=== WAF Policy Details: portal_policy ===", "Enforcement Mode: transparent"},
+	},
+	{
+		Name:        "WAF policy name containing a quote",
+		Query:       "get waf policy o'brien",
+		ExpectError: true,
+		Expect:      []string{"WAF policy 'o'brien' not found"},
+	},
+	{
+		Name:   "repeated query served from cache",
+		Query:  "show pools again",
+		Expect: []string{"web_pool"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/ltm/pool"); n != 1 {
+				return fmt.Errorf("expected the pool listing to be cached, saw %d request(s)", n)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "refresh bypasses cache",
+		Query:  "refresh the pools",
+		Expect: []string{"web_pool"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/ltm/pool"); n != 2 {
+				return fmt.Errorf("expected refresh to refetch pools, saw %d request(s)", n)
+			}
+			return nil
+		},
+	},
+	func() Scenario {
+		var loginsBefore int
+		return Scenario{
+			Name:  "expired token renewed transparently",
+			Query: "refresh virtual servers",
+			Setup: func(f *FakeIControl) {
+				loginsBefore = f.Requests("/mgmt/shared/authn/login")
+				f.ExpireTokens()
+			},
+			Expect: []string{"vs_app1"},
+			Check: func(f *FakeIControl) error {
+				if n := f.Requests("/mgmt/shared/authn/login") - loginsBefore; n != 1 {
+					return fmt.Errorf("expected exactly one re-login after token expiry, saw %d", n)
+				}
+				return nil
+			},
+		}
+	}(),
+	func() Scenario {
+		var requestsBefore int
+		return Scenario{
+			Name:  "long listing streamed a page at a time",
+			Query: "refresh virtual servers",
+			Setup: func(f *FakeIControl) {
+				requestsBefore = f.Requests("/mgmt/tm/ltm/virtual")
+				f.EditItems("/mgmt/tm/ltm/virtual", func(items []interface{}) []interface{} {
+					for n := 0; n < 1200; n++ {
+						items = append(items, map[string]interface{}{
+							"kind":        "tm:ltm:virtual:virtualstate",
+							"name":        fmt.Sprintf("vs_bulk_%04d", n),
+							"partition":   "Common",
+							"fullPath":    fmt.Sprintf("/Common/vs_bulk_%04d", n),
+							"destination": fmt.Sprintf("/Common/10.9.%d.%d:80", n/250, n%250+1),
+							"enabled":     true,
+						})
+					}
+					return items
+				})
+			},
+			Expect: []string{"Listed 1,202 virtual servers, a page at a time as they were read."},
+			ExpectStreamed: []string{"=== Virtual Servers (VIPs) ===", "NAME          DESTINATION", "vs_app1       /Common/10.1.10.80:443",
+				"... 500 of 1,202 virtual servers\n", "vs_bulk_1199  /Common/10.9.4.200:80", "... 1,202 of 1,202 virtual servers\n"},
+			Check: func(f *FakeIControl) error {
+				// The device goes back to its two virtual servers
+				f.EditItems("/mgmt/tm/ltm/virtual", func(items []interface{}) []interface{} {
+					return items[:len(items)-1200]
+				})
+				if n := f.Requests("/mgmt/tm/ltm/virtual") - requestsBefore; n != 3 {
+					return fmt.Errorf("expected the listing to take 3 pages, saw %d request(s)", n)
+				}
+				return nil
+			},
+		}
+	}(),
+	{
+		Name:  "WAF policies retried after server error",
+		Query: "refresh WAF policies",
+		Setup: func(f *FakeIControl) {
+			f.FailNext("/mgmt/tm/asm/policies", 500)
+		},
+		Expect: []string{"VS_WAF         /Common/VS_WAF   active    blocking"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/asm/policies"); n < 2 {
+				return fmt.Errorf("expected the policy request to be retried, saw %d request(s)", n)
+			}
+			return nil
+		},
+	},
+	{
+		Name:  "ASM not provisioned",
+		Query: "refresh WAF policies",
+		Setup: func(f *FakeIControl) {
+			f.FailNext("/mgmt/tm/asm/policies", 404)
+		},
+		ExpectError: true,
+		Expect:      []string{"ASM module is provisioned"},
+	},
+	// Uploads clear the response cache, so these run after the cache checks
+	{
+		Name:   "iRule generated from a description",
+		Query:  "write an iRule that redirects /old-path to /new-path",
+		Expect: []string{"=== Generated iRule: redirect_old_path ===", `HTTP::redirect "/new-path`, "keeping the query string", "Reply 'upload'"},
+		Check: func(f *FakeIControl) error {
+			if _, ok := f.Rule("/Common/redirect_old_path"); ok {
+				return fmt.Errorf("the iRule was uploaded before it was confirmed")
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "generated iRule uploaded after confirmation",
+		Query:  "upload",
+		Expect: []string{"Uploaded iRule /Common/redirect_old_path", "isn't attached"},
+		Check: func(f *FakeIControl) error {
+			if rule, ok := f.Rule("/Common/redirect_old_path"); !ok || !strings.Contains(rule, "/old-path") {
+				return fmt.Errorf("expected the generated iRule on the device, got %q", rule)
+			}
+			if entry, err := lastAuditEntry(); err != nil || !entry.Modified {
+				return fmt.Errorf("expected the upload audited as a change, got %+v (%v)", entry, err)
+			}
+			return nil
+		},
+	},
+	{
+		Name:  "existing iRule not overwritten",
+		Query: "write an iRule that redirects /a to /b",
+		Expect: []string{"=== Generated iRule: redirect_old_path ===", "/Common/redirect_old_path already exists",
+			"--- /Common/redirect_old_path\n+++ generated\n@@ -1,5 +1,5 @@", `-    if { [HTTP::path] eq "/old-path" } {`, `+    if { [HTTP::path] eq "/a" } {`},
+	},
+	{
+		Name:   "pending iRule given as an Ansible task",
+		Query:  "give me that as an Ansible task",
+		Expect: []string{"=== Ansible: iRule /Common/redirect_old_path ===", "f5networks.f5_modules.bigip_irule:", "content: |-\n          when HTTP_REQUEST", "reply 'upload'"},
+	},
+	{
+		Name:   "upload refused when the name is taken",
+		Query:  "upload",
+		Expect: []string{"already exists", "upload as <name>"},
+		Check: func(f *FakeIControl) error {
+			if rule, _ := f.Rule("/Common/redirect_old_path"); strings.Contains(rule, `"/a"`) {
+				return fmt.Errorf("the existing iRule was overwritten")
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "upload under another name",
+		Query:  "upload as redirect_a_to_b",
+		Expect: []string{"Uploaded iRule /Common/redirect_a_to_b"},
+	},
+	{
+		Name:   "existing iRule explained",
+		Query:  "explain iRule maintenance_page",
+		Expect: []string{"=== iRule: /Common/maintenance_page ===", "HTTP::respond 503", "handles HTTP_REQUEST", "Pitfalls"},
+	},
+	{
+		Name:        "explaining a missing iRule",
+		Query:       "explain iRule no_such_rule",
+		ExpectError: true,
+		Expect:      []string{"iRule 'no_such_rule' not found"},
+	},
+	{
+		Name:        "explaining an iRule whose name is a plain word",
+		Query:       "explain iRule redirect",
+		ExpectError: true,
+		Expect:      []string{"iRule 'redirect' not found"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/ltm/rule/redirect"); n != 1 {
+				return fmt.Errorf("the iRule was fetched %d times, want 1", n)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "describing a pool whose name is a plain word",
+		Query:  "describe pool web",
+		Expect: []string{"=== Server Pools ===", "web_pool", "/Common/web1:80"},
+	},
+	{
+		Name:   "AS3 declaration generated from a description",
+		Query:  "Create an HTTPS app on 10.0.0.80 with members 10.0.1.10 and 10.0.1.11 and a redirect",
+		Expect: []string{"=== Generated AS3 declaration (tenant app_10_0_0_80) ===", `"redirect80": true`, "Reply 'deploy'"},
+		Check: func(f *FakeIControl) error {
+			if _, ok := f.Declaration("app_10_0_0_80"); ok {
+				return fmt.Errorf("the declaration was deployed before it was confirmed")
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "AS3 declaration deployed after confirmation",
+		Query:  "deploy",
+		Expect: []string{"Deployed the AS3 declaration", "app_10_0_0_80: success"},
+		Check: func(f *FakeIControl) error {
+			if d, ok := f.Declaration("app_10_0_0_80"); !ok || !strings.Contains(d, "10.0.1.11") {
+				return fmt.Errorf("expected the declaration on the device, got %q", d)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "AS3 declaration for an existing tenant",
+		Query:  "Create an HTTPS app on 10.0.0.80 with member 10.0.1.12",
+		Expect: []string{"tenant app_10_0_0_80"},
+	},
+	{
+		Name:   "replacing an AS3 tenant refused by the guardrail",
+		Query:  "deploy",
+		Expect: []string{"disruptive change", "Nothing was changed"},
+		Check: func(f *FakeIControl) error {
+			if d, _ := f.Declaration("app_10_0_0_80"); strings.Contains(d, "10.0.1.12") {
+				return fmt.Errorf("the existing tenant was replaced")
+			}
+			return nil
+		},
+	},
+	{
+		// The refused replacement above was never tried, so isn't journaled
+		Name:  "change journal lists what chatf5 changed",
+		Query: "what did this tool change today?",
+		Expect: []string{"=== Changes made with chatf5 since", "applied  e2e          upload_irule   /Common/redirect_old_path",
+			"failed   e2e          upload_irule   /Common/redirect_old_path", "already exists",
+			"applied  e2e          deploy_as3     tenant app_10_0_0_80", "Asked for: Create an HTTPS app on 10.0.0.80 with members 10.0.1.10 and 10.0.1.11",
+			"4 changes: 3 applied, 1 failed."},
+		Check: func(f *FakeIControl) error {
+			entries, err := changes().Entries(journal.Filter{})
+			if err != nil {
+				return err
+			}
+			last := entries[len(entries)-1]
+			if last.Before != "" || !strings.Contains(last.After, "10.0.1.11") || last.Device != f.Host() {
+				return fmt.Errorf("expected the deployed declaration journaled for %s, got %+v", f.Host(), last)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "change journal of another user",
+		Query:  "what did user jsmith change last week?",
+		Expect: []string{"chatf5 hasn't changed anything by jsmith since"},
+	},
+	{
+		Name:  "health summary puts the device on one screen",
+		Query: "give me a health summary",
+		Expect: []string{"=== Health Summary", "[OK]   Device             bigip1.example.com, BIG-IP Virtual Edition 16.1.3 build 0.0.7; CPU 18%",
+			"[WARN] High availability  active; peer bigip2.example.com standby; config Changes Pending", "possible change conflict",
+			"[WARN] Pool members       1 of 2 up in 2 pools, 1 down", "/Common/web_pool /Common/web2:80 down",
+			"[SKIP] Certificates       couldn't be read", "Overall: [WARN] nothing down, but 2 areas to look at."},
+	},
+	{
+		Name:  "virtual server exported as Terraform",
+		Query: "export vs_app1 as Terraform",
+		Expect: []string{"=== Terraform: /Common/vs_app1 ===", `resource "bigip_ltm_pool" "web_pool"`, `node = "${bigip_ltm_node.web2.name}:80"`,
+			`client_profiles = ["/Common/api_clientssl"]`, "to = bigip_ltm_virtual_server.vs_app1", "1 virtual server, 1 pool, 2 pool members and 2 nodes"},
+	},
+	{
+		Name:  "virtual server exported as an Ansible playbook",
+		Query: "export vs_app1 as an Ansible playbook",
+		Expect: []string{"=== Ansible: /Common/vs_app1 ===", "f5networks.f5_modules.bigip_pool_member:", "reuse_nodes: true",
+			"{name: /Common/api_clientssl, context: client-side}", "1 virtual server, 1 pool, 2 pool members and 2 nodes"},
+	},
+	{
+		Name:   "playbook written for a described change",
+		Query:  "write a playbook that adds 10.1.20.13:80 to web_pool",
+		Expect: []string{"=== Generated Ansible playbook ===", "pool: web_pool", `address: "10.1.20.13"`, "Nothing has been changed on the BIG-IP"},
+	},
+	{
+		Name:   "qkview generated and downloaded",
+		Query:  "generate a qkview to " + qkviewFile,
+		Setup:  func(f *FakeIControl) { os.Remove(qkviewFile) },
+		Expect: []string{"saved it to " + qkviewFile + " (2.7 MB)", `Ask "upload the qkview to iHealth"`},
+		Check: func(f *FakeIControl) error {
+			data, err := os.ReadFile(qkviewFile)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(data, QkviewContent) {
+				return fmt.Errorf("the qkview saved is %d bytes, not the %d generated", len(data), len(QkviewContent))
+			}
+			if n := f.Qkviews(); n != 0 {
+				return fmt.Errorf("%d qkviews left on the device after downloading", n)
+			}
+			return nil
+		},
+	},
+	{
+		Name:  "qkview uploaded to iHealth",
+		Query: "upload the qkview to iHealth for case 00123456",
+		Expect: []string{"=== iHealth: bigip1.example.com (qkview 24681357) ===", "CRITICAL  H701182  The BIG-IP version is affected",
+			"Action: Upgrade to 17.1.1.4", "https://my.f5.com/manage/s/article/K000138733", "2 diagnostics matched: 1 critical and 1 high.",
+			"attached to support case 00123456", "https://ihealth2.f5.com/qkview-analyzer/qv/24681357"},
+		Check: func(f *FakeIControl) error {
+			os.Remove(qkviewFile)
+			return nil
+		},
+	},
+	{
+		Name:   "agent investigates step by step",
+		Query:  "/agent why is vs_app1 not serving traffic?",
+		Expect: []string{"Likely cause", "Steps taken:", "1. list_virtual_servers", "2. get_pool(name=web_pool)", "3. list_nodes"},
+		CheckLLM: func(completions int) error {
+			if completions != 4 {
+				return fmt.Errorf("expected 3 tool steps and an answer, got %d completions", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "agent stops at the step limit",
+		Query:  "/agent check everything",
+		Expect: []string{"6. list_nodes", "Stopped after 6 steps"},
+	},
+	{
+		Name:   "pasted tmsh read runs as a query",
+		Query:  "tmsh list ltm pool web_pool",
+		Expect: []string{"Running `list ltm pool web_pool` as GET /mgmt/tm/ltm/pool/web_pool", "web_pool"},
+	},
+	{
+		Name:   "tmsh equivalent of the previous answer",
+		Query:  "what tmsh command does this?",
+		Expect: []string{"tmsh list ltm pool web_pool", "GET /mgmt/tm/ltm/pool/web_pool/members"},
+	},
+	{
+		Name:   "pasted tmsh change explained, not run",
+		Query:  "tmsh delete ltm pool web_pool",
+		Expect: []string{"was not executed", "DELETE /mgmt/tm/ltm/pool/web_pool", "Impact:"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/ltm/pool/web_pool"); n != 0 {
+				return fmt.Errorf("expected no request for the pool, got %d", n)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "policy reference resolved from earlier answers",
+		Query:  "show the details of that policy",
+		Expect: []string{"=== WAF Policy Details: portal_policy ==="},
+		CheckLLM: func(completions int) error {
+			if completions != 0 {
+				return fmt.Errorf("expected the reference to resolve without the LLM, got %d chat completion(s)", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "virtual server named in a listing query",
+		Query:  "show virtual server vs_app1",
+		Expect: []string{"vs_app1"},
+	},
+	{
+		Name:   "pronoun resolved to the virtual server's pool",
+		Query:  "show its members",
+		Expect: []string{"web_pool", "/Common/web1:80"},
+		CheckLLM: func(completions int) error {
+			if completions != 0 {
+				return fmt.Errorf("expected the reference to resolve without the LLM, got %d chat completion(s)", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "several pools match a partial name",
+		Query:  "show pool _pool",
+		Expect: []string{"Several pools match '_pool'", "1. /Common/web_pool", "2. /Common/api_pool", "Reply with its number"},
+	},
+	{
+		Name:   "pool picked from the list by number",
+		Query:  "2",
+		Expect: []string{"Name:         api_pool", "least-connections-member"},
+		CheckLLM: func(completions int) error {
+			if completions != 0 {
+				return fmt.Errorf("expected the pick to run without the LLM, got %d chat completion(s)", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "WAF policy found by part of its name",
+		Query:  "get waf policy portal",
+		Expect: []string{"=== WAF Policy Details: portal_policy ==="},
+	},
+	{
+		Name:   "quoted policy name with a virtual server mentioned",
+		Query:  "details for policy 'VS_WAF' on VS vs_app1",
+		Expect: []string{"=== WAF Policy Details: VS_WAF ===", "Configuration Path: /Common/VS_WAF"},
+	},
+	{
+		Name:   "pool named by partition path before the noun",
+		Query:  "show the /Common/web_pool pool",
+		Expect: []string{"Name:         web_pool", "/Common/web1:80"},
+	},
+	{
+		Name:   "virtual servers compared field by field",
+		Query:  "compare vs_app1 with VS_WAF",
+		Expect: []string{"=== Comparing virtual servers /Common/vs_app1 and /Common/VS_WAF ===", "destination", "/Common/10.1.10.80:443", "/Common/10.1.10.90:443", "pool", "field(s) differ"},
+	},
+	{
+		Name:   "pools compared including members",
+		Query:  "compare pool web_pool and pool api_pool",
+		Expect: []string{"=== Comparing pools /Common/web_pool and /Common/api_pool ===", "loadBalancingMode", "members", "/Common/web1:80", "(not set)"},
+	},
+	{
+		Name:   "HTTP profiles compared",
+		Query:  "compare http profiles http and http_xff",
+		Expect: []string{"=== Comparing profiles /Common/http and /Common/http_xff ===", "enforcement.maxHeaderSize", "65536", "insertXforwardedFor"},
+	},
+	{
+		Name:  "virtual servers compared as a unified diff",
+		Query: "compare vs_app1 with VS_WAF as a unified diff",
+		Expect: []string{"--- /Common/vs_app1\n+++ /Common/VS_WAF\n@@ -1,8 +1,7 @@", "-pool: /Common/web_pool\n+pool: /Common/api_pool\n source: 0.0.0.0/0",
+			" ipProtocol: tcp", "5 field(s) differ, 4 match."},
+	},
+	{
+		Name:        "comparing with a missing object",
+		Query:       "compare pool web_pool with pool missing_pool",
+		ExpectError: true,
+		Expect:      []string{"no pool named 'missing_pool'"},
+	},
+	{
+		Name:   "only disabled virtual servers",
+		Query:  "show only disabled virtual servers",
+		Expect: []string{"VS_WAF", "Showing 1 of 2 virtual servers (disabled)"},
+	},
+	{
+		Name:   "pools without members",
+		Query:  "list pools without members",
+		Expect: []string{"api_pool", "Showing 1 of 2 pools (without members)"},
+	},
+	{
+		Name:   "nodes down in a network",
+		Query:  "which nodes in 10.1.20.0/24 are down?",
+		Expect: []string{"web2", "10.1.20.12", "Showing 1 of 2 nodes (down, in 10.1.20.0/24)"},
+	},
+	{
+		Name:   "filter matching nothing",
+		Query:  "list nodes in 10.2.0.0/16",
+		Expect: []string{"None of the 2 nodes match: in 10.2.0.0/16."},
+	},
+	{
+		Name:   "virtual servers sorted by connection count",
+		Query:  "list virtual servers sorted by connection count",
+		Expect: []string{"Sorted by current connections, most first: /Common/VS_WAF (57), /Common/vs_app1 (12)."},
+	},
+	{
+		Name:   "largest pools first",
+		Query:  "show the largest pools first",
+		Expect: []string{"MEMBERS\nweb_pool  ", "Sorted by number of members, most first: /Common/web_pool (2), /Common/api_pool (0)."},
+	},
+	{
+		Name:   "nodes sorted by name in reverse",
+		Query:  "list nodes sorted by name in reverse",
+		Expect: []string{"STATE\nweb2  10.1.20.12  down\nweb1", "Sorted by name, Z to A."},
+	},
+	{
+		Name:   "virtual servers counted per partition",
+		Query:  "how many virtual servers are there per partition",
+		Expect: []string{"Virtual servers per partition:\n  Common: 2\nTotal: 2 virtual servers."},
+	},
+	{
+		Name:   "pools with fewer than 2 members counted",
+		Query:  "how many pools have fewer than 2 members",
+		Expect: []string{"1 of the 2 pools match: fewer than 2 members."},
+	},
+	{
+		Name:   "nodes counted per status",
+		Query:  "how many nodes are there per status",
+		Expect: []string{"Nodes per status:\n  down: 1\n  up: 1\nTotal: 2 nodes."},
+	},
+	{
+		Name:  "troubleshoot a degraded application",
+		Query: "why is vs_app1 down?",
+		Expect: []string{"=== Troubleshooting /Common/vs_app1 ===", "[WARN] Pool           /Common/web_pool: 1 of 2 members available (monitor /Common/http)",
+			"Node /Common/web2 address 10.1.20.12 session status forced disabled.",
+			"Degraded: 1 of 2 members of pool /Common/web_pool are down: node /Common/web2 (10.1.20.12) was disabled by an administrator."},
+	},
+	{
+		Name:  "troubleshoot every virtual server",
+		Query: "why is my app down?",
+		Expect: []string{"=== Troubleshooting /Common/vs_app1 ===", "=== Troubleshooting /Common/VS_WAF ===",
+			"Root cause: /Common/VS_WAF is disabled", "Also: Pool /Common/api_pool has no members"},
+	},
+	{
+		Name:   "earlier queries listed",
+		Query:  "/history 100",
+		Expect: []string{" 1  show virtual servers\n", " 2  /health\n", "Run one again with"},
+	},
+	{
+		Name:   "earlier query run again by number",
+		Query:  "run #1 again",
+		Expect: []string{"Running #1: show virtual servers", "=== Virtual Servers (VIPs) ===", "vs_app1"},
+	},
+	{
+		Name:   "last query saved by name",
+		Query:  "save this as start-check",
+		Expect: []string{"Saved start-check: show virtual servers", "/run start-check"},
+	},
+	{
+		Name:   "runbook of saved queries",
+		Query:  "/save morning-check show pools; which nodes are down?",
+		Expect: []string{"Saved morning-check: show pools; which nodes are down?"},
+	},
+	{
+		Name:   "saved runbook run",
+		Query:  "/run morning-check",
+		Expect: []string{"Running morning-check (2 queries)", "=== 1/2: show pools ===", "=== Server Pools ===", "=== 2/2: which nodes are down? ===", "10.1.20.12"},
+	},
+	func() Scenario {
+		var before int
+		return Scenario{
+			Name:  "plan shown without running the query",
+			Query: "/plan show pool web_pool",
+			Setup: func(f *FakeIControl) { before = f.Requests("/mgmt/tm/ltm/pool/web_pool/members") },
+			Expect: []string{"Plan: get_pool (name=web_pool)", "GET /mgmt/tm/ltm/pool/web_pool/members  pool members and their state",
+				"Nothing was run"},
+			Check: func(f *FakeIControl) error {
+				if n := f.Requests("/mgmt/tm/ltm/pool/web_pool/members") - before; n != 0 {
+					return fmt.Errorf("expected no request for the pool's members, got %d", n)
+				}
+				return nil
+			},
+		}
+	}(),
+	{
+		Name:   "plan preview turned on",
+		Query:  "/plan on",
+		Expect: []string{"Plan preview is on"},
+	},
+	{
+		Name:  "plan shown ahead of the answer",
+		Query: "list virtual servers sorted by connections",
+		Expect: []string{"Plan: list_virtual_servers (sort_by=connections)", "GET /mgmt/tm/ltm/virtual?expandSubcollections=true  virtual server configuration",
+			"GET /mgmt/tm/ltm/virtual/stats                      availability and connection counts", "=== Virtual Servers (VIPs) ==="},
+	},
+	{
+		Name:   "plan preview turned off",
+		Query:  "/plan off",
+		Expect: []string{"Plan preview is off"},
+	},
+	{
+		Name:   "follow-up questions suggested",
+		Query:  "show pool web_pool",
+		Expect: []string{"You could also ask:\n- Troubleshoot vs_app1\n- Which nodes are down?\n- What tmsh command does this?"},
+	},
+	{
+		Name:  "several kinds of object in one request",
+		Query: "show virtual servers and their pools and any WAF policies attached",
+		Expect: []string{"This answers 3 requests", "--- 1. show virtual servers ---", "=== Virtual Servers (VIPs) ===",
+			"--- 2. their pools ---", "=== Server Pools ===", "--- 3. any WAF policies attached ---",
+			"--- How they fit together ---"},
+		CheckLLM: func(completions int) error {
+			if completions != 0 {
+				return fmt.Errorf("expected the request to be split without the LLM, saw %d chat completion(s)", completions)
+			}
+			return nil
+		},
+	},
+	func() Scenario {
+		var requestsBefore int
+		return Scenario{
+			Name:  "refresh applies to a split request",
+			Query: "refresh the virtual servers and their pools",
+			Setup: func(f *FakeIControl) {
+				requestsBefore = f.Requests("/mgmt/tm/ltm/pool")
+			},
+			Expect: []string{"This answers 2 requests", "=== Server Pools ==="},
+			Check: func(f *FakeIControl) error {
+				if n := f.Requests("/mgmt/tm/ltm/pool") - requestsBefore; n != 1 {
+					return fmt.Errorf("expected refresh to refetch pools, saw %d request(s)", n)
+				}
+				return nil
+			},
+		}
+	}(),
+	{
+		Name:   "which device has an object",
+		Query:  "which device has pool legacy_pool",
+		Expect: []string{"Pool legacy_pool is on 1 of 2 devices:", "dr", "pool /Common/legacy_pool", "Not on: primary."},
+	},
+	{
+		Name:  "read across all devices",
+		Query: "show virtual servers named vs_app1 across all devices",
+		Expect: []string{"Asked 2 devices: primary, dr.", "##### Device: primary #####", "##### Device: dr #####",
+			"vs_app1"},
+		CheckLLM: func(completions int) error {
+			if completions != 0 {
+				return fmt.Errorf("expected the request to run without the LLM, saw %d chat completion(s)", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "partition that doesn't exist refused",
+		Query:  "/partition TenantZ",
+		Expect: []string{"There's no partition TenantZ on the BIG-IP"},
+	},
+	{
+		Name:   "queries scoped to a partition",
+		Query:  "/partition app_10_0_0_80",
+		Expect: []string{"Queries are now scoped to partition app_10_0_0_80"},
+	},
+	{
+		Name:   "listing limited to the session's partition",
+		Query:  "show virtual servers",
+		Expect: []string{"None of the 2 virtual servers match: in partition app_10_0_0_80."},
+	},
+	{
+		Name:   "partition overridden for one query",
+		Query:  "show pools in Common",
+		Expect: []string{"=== Server Pools ===", "Showing 2 of 2 pools (in partition Common)."},
+	},
+	{
+		Name:   "partition scope turned off",
+		Query:  "/partition off",
+		Expect: []string{"Queries cover every partition again."},
+	},
+	{
+		Name:   "device switching needs profiles",
+		Query:  "/device staging",
+		Expect: []string{"No profiles are configured."},
+	},
+	{
+		Name:   "changes asked for before any snapshot",
+		Query:  "what changed since this morning?",
+		Expect: []string{"There are no snapshots to compare with yet"},
+	},
+	{
+		Name:   "snapshot taken",
+		Query:  "/snapshot before-change",
+		Expect: []string{"Took snapshot before-change", "2 virtual servers, 2 pools, 2 WAF policies."},
+	},
+	{
+		Name:  "changes since the snapshot",
+		Query: "what changed since this morning?",
+		Setup: func(f *FakeIControl) {
+			f.EditItems("/mgmt/tm/ltm/virtual", func(items []interface{}) []interface{} {
+				for _, item := range items {
+					if vs := item.(map[string]interface{}); vs["name"] == "vs_app1" {
+						vs["description"] = "Customer portal v2"
+					}
+				}
+				return items
+			})
+			f.EditItems("/mgmt/tm/ltm/pool", func(items []interface{}) []interface{} {
+				var kept []interface{}
+				for _, item := range items {
+					if item.(map[string]interface{})["name"] != "api_pool" {
+						kept = append(kept, item)
+					}
+				}
+				return kept
+			})
+		},
+		Expect: []string{"Changes since snapshot before-change", "Virtual servers:\n  ~ /Common/vs_app1  (modified)",
+			"      - description: Customer portal\n      + description: Customer portal v2", "Pools:\n  - /Common/api_pool  (removed)",
+			"WAF policies: no changes (2 compared).", "0 added, 1 removed, 1 modified."},
+	},
+	{
+		Name:   "JSON output turned on",
+		Query:  "/format json",
+		Expect: []string{`"query": "/format json"`, "Answers are now JSON objects"},
+	},
+	{
+		Name:  "JSON answer with the objects read",
+		Query: "show pools named web_pool",
+		Expect: []string{`"operation": "list_pools"`, `"name": "web_pool"`, `"fullPath": "/Common/web_pool"`,
+			`"members": [`, `"text": "`, "Showing 1 of"},
+	},
+	{
+		Name:   "JSON answer reports errors in place",
+		Query:  "show pool no_such_pool",
+		Expect: []string{`"operation": "get_pool"`, `"error": "`, "no_such_pool"},
+	},
+	{
+		Name:   "JSON output turned off",
+		Query:  "/format text",
+		Expect: []string{"Answers are now written as text."},
+	},
+	{
+		Name:   "CSV output turned on",
+		Query:  "/format csv",
+		Expect: []string{"Listings are now written as CSV"},
+	},
+	{
+		Name:  "CSV answer to a listing",
+		Query: "show virtual servers",
+		Expect: []string{"Name,Partition,Full Path,Destination,Pool,Enabled,Description",
+			"vs_app1,Common,/Common/vs_app1,"},
+	},
+	{
+		Name:   "CSV output turned off",
+		Query:  "/format text",
+		Expect: []string{"Answers are now written as text."},
+	},
+	{
+		Name:   "last listing exported as CSV",
+		Query:  "export this as CSV to " + exportFile,
+		Setup:  func(f *FakeIControl) { os.Remove(exportFile) },
+		Expect: []string{"Wrote 2 rows to " + exportFile + "."},
+		Check: func(f *FakeIControl) error {
+			defer os.Remove(exportFile)
+			data, err := os.ReadFile(exportFile)
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(string(data), "Name,Partition,Full Path,") || !strings.Contains(string(data), "/Common/VS_WAF") {
+				return fmt.Errorf("unexpected export:\n%s", data)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "Markdown output turned on",
+		Query:  "/format markdown",
+		Expect: []string{"Answers are now written as Markdown"},
+	},
+	{
+		Name:   "Markdown table for a listing",
+		Query:  "show pools",
+		Expect: []string{"## Pools\n\n| Name | Partition | Full Path |", "| web_pool | Common | /Common/web_pool | round-robin |"},
+	},
+	{
+		Name:   "Markdown headings and fields",
+		Query:  "get waf policy VS_WAF",
+		Expect: []string{"## WAF Policy Details: VS_WAF\n\n- **Name:** VS_WAF", "- **Enforcement Mode:** blocking", "Associated Virtual Servers:\n- /Common/VS_WAF"},
+	},
+	{
+		Name:   "Markdown code block",
+		Query:  "what tmsh command does this?",
+		Expect: []string{"tmsh:\n\n```\n  tmsh list asm policy VS_WAF\n```"},
+	},
+	{
+		Name:   "Markdown output turned off",
+		Query:  "/format text",
+		Expect: []string{"Answers are now written as text."},
+	},
+	{
+		Name:   "YAML output turned on",
+		Query:  "/format yaml",
+		Expect: []string{"---\nquery: /format yaml\noperations: []\ntext: Answers are now YAML documents"},
+	},
+	{
+		Name:  "YAML answer with the objects read",
+		Query: "show pools named web_pool",
+		Expect: []string{"  - operation: list_pools\n    args:\n      name: web_pool\n    data:\n      - fullPath: /Common/web_pool",
+			"        members:\n          - /Common/web1:80", "        name: web_pool", "text: |"},
+	},
+	{
+		Name:   "YAML output turned off",
+		Query:  "/format text",
+		Expect: []string{"Answers are now written as text."},
+	},
+	{
+		Name:   "listing asked for in detail",
+		Query:  "show nodes in detail",
+		Expect: []string{"[1] Node Details:\n----------------------------------------\nName:    web1", "State:   up"},
+	},
+	{
+		Name:   "verbose listings turned on",
+		Query:  "/verbose on",
+		Expect: []string{"Listings now show every field of each object."},
+	},
+	{
+		Name:   "listing in detail while verbose",
+		Query:  "show virtual servers",
+		Expect: []string{"[1] Virtual Server Details:", "Description: Customer portal"},
+	},
+	{
+		Name:   "verbose listings turned off",
+		Query:  "/verbose off",
+		Expect: []string{"Listings now show one line per object"},
+	},
+	{
+		Name:   "last answer saved to a file",
+		Query:  "save that to " + answerFile,
+		Setup:  func(f *FakeIControl) { os.Remove(answerFile) },
+		Expect: []string{"Wrote the last answer to " + answerFile + " (text)."},
+		Check: func(f *FakeIControl) error {
+			data, err := os.ReadFile(answerFile)
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(string(data), "Listings now show one line per object") {
+				return fmt.Errorf("unexpected answer saved:\n%s", data)
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "saving over an existing file refused",
+		Query:       "save that to " + answerFile,
+		ExpectError: true,
+		Expect:      []string{answerFile + " already exists"},
+		Check: func(f *FakeIControl) error {
+			return os.Remove(answerFile)
+		},
+	},
+	{
+		Name:   "watching needs the chat prompt",
+		Query:  "watch pool web_pool every 10s",
+		Expect: []string{"until Ctrl-C", "chatf5 query -watch 30s"},
+	},
+	{
+		Name:   "audit log records the query and its REST calls",
+		Query:  "refresh the nodes",
+		Expect: []string{"=== Backend Nodes ==="},
+		Check: func(f *FakeIControl) error {
+			entry, err := lastAuditEntry()
+			if err != nil {
+				return err
+			}
+			if entry.Query != "refresh the nodes" || entry.User == "" || entry.Modified {
+				return fmt.Errorf("unexpected audit entry %+v", entry)
+			}
+			if len(entry.Intent) != 1 || entry.Intent[0].Operation != "list_nodes" {
+				return fmt.Errorf("expected the list_nodes intent audited, got %+v", entry.Intent)
+			}
+			for _, call := range entry.Calls {
+				if call.Method == "GET" && strings.HasPrefix(call.Path, "/mgmt/tm/ltm/node") && call.Status == 200 {
+					return nil
+				}
+			}
+			return fmt.Errorf("expected GET /mgmt/tm/ltm/node audited, got %+v", entry.Calls)
+		},
+	},
+	{
+		// api_pool was removed above, so VS_WAF's pool has no monitor
+		Name:  "compliance report scores the device against the built-in rules",
+		Query: "/compliance",
+		Expect: []string{"=== Compliance Report ===", "Score: 27/100",
+			"[FAIL] high   vs-monitored-pool", "/Common/VS_WAF: monitor is not set", "[FAIL] high   client-ssl-no-tls10",
+			"/Common/clientssl: options is \"dont-insert-empty-fragments no-tlsv1.3\"",
+			"[FAIL] medium waf-transparent-too-long", "/Common/portal_policy: days_unchanged is",
+			"[FAIL] high   no-default-admin", "user is \"admin\"", "4 of 4 rules failed"},
+	},
+	{
+		Name:  "security posture report ranks the findings",
+		Query: "what is our security posture?",
+		Expect: []string{"=== Security Posture ===", "1 disabled one isn't exposed",
+			"0 of 1 client SSL profiles in use have weaknesses", "Bot defense      not provisioned",
+			"[HIGH]   WAF        /Common/vs_app1: no WAF policy protects it", "[MEDIUM] DoS        /Common/vs_app1",
+			"3 findings: 1 high, 1 medium, 1 low."},
+	},
+	{
+		// The passed request in the log is left out
+		Name:  "WAF violations are grouped and summarized",
+		Query: "summarize the WAF violations",
+		Expect: []string{"=== WAF Violations ===", "25 flagged: 23 blocked, 2 alerted", "5 client IPs",
+			"[BLOCKED] 20 (86% of blocks)  SQL-Injection  POST /login", "from 3 IPs: 203.0.113.7 (9), 203.0.113.8 (6), 198.51.100.23 (5); rating 5",
+			"[ALERTED] 2 (100% of alerts)  Cross Site Scripting (XSS)  GET /search",
+			"Summary: 86% of blocks are SQL-Injection attempts against /login from 3 IPs."},
+	},
+	{
+		Name:   "WAF violations of one policy",
+		Query:  "triage the violations of the portal_policy policy",
+		Expect: []string{"The WAF hasn't blocked or alerted on any requests by policy portal_policy."},
+	},
+	{
+		Name:  "OWASP compliance on a version without the dashboard",
+		Query: "how compliant is policy VS_WAF with OWASP?",
+		Setup: func(f *FakeIControl) {
+			f.FailNext("/mgmt/tm/asm/policies/Vq4sZ1Yw0lHk2W8nA7sYfQ/owasp-compliance", 404)
+		},
+		Expect: []string{"This BIG-IP version doesn't report OWASP Top 10 compliance over iControl REST"},
+	},
+	{
+		Name:  "OWASP compliance of one WAF policy",
+		Query: "how compliant is policy VS_WAF with OWASP?",
+		Expect: []string{"=== OWASP Top 10 Compliance ===", "/Common/VS_WAF  78% compliant (18 of 23 security controls)",
+			"[PASS]  A03:2021  Injection", "[WARN]  A05:2021  Security Misconfiguration                   2/4",
+			"[FAIL]  A10:2021  Server-Side Request Forgery                 0/1"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/asm/policies/mZ3kT8rQ1pLx9cVb2nW4eA/owasp-compliance"); n != 0 {
+				return fmt.Errorf("expected only VS_WAF scored, saw %d request(s) for portal_policy", n)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "OWASP compliance of every WAF policy",
+		Query:  "show the OWASP compliance scores",
+		Expect: []string{"/Common/VS_WAF  78% compliant", "/Common/portal_policy  30% compliant (7 of 23 security controls)"},
+	},
+	{
+		Name:  "GTM not provisioned",
+		Query: "show the GTM topology records",
+		Setup: func(f *FakeIControl) {
+			f.FailNext("/mgmt/tm/gtm/topology", 404)
+		},
+		Expect: []string{"GTM (BIG-IP DNS) isn't provisioned on this device"},
+	},
+	{
+		Name:  "GTM topology records listed in the order they're taken",
+		Query: "show the GTM topology records",
+		Expect: []string{"=== GTM Topology ===", "Longest match    on: the most specific client side is taken first",
+			"1   region /Common/emea -> datacenter /Common/DC2, weight 200", "2   country GB          -> datacenter /Common/DC1, weight 250",
+			"4   continent EU        -> datacenter /Common/DC1, weight 100", "/Common/emea: continent EU, continent AF"},
+	},
+	{
+		Name:  "GTM topology explains where clients resolve",
+		Query: "why do EU clients resolve to DC2?",
+		Expect: []string{"Clients in continent EU:", "match   region /Common/emea -> datacenter /Common/DC2, weight 200",
+			"They resolve to datacenter /Common/DC2: region /Common/emea scores it 200, more than any other destination gets (datacenter /Common/DC1: 100).",
+			"maybe   country GB -> datacenter /Common/DC1, weight 250"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/gtm/region"); n != 1 {
+				return fmt.Errorf("expected the cached topology to be reused, saw %d region request(s)", n)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "GTM topology for clients in one country",
+		Query:  "where do clients in the United Kingdom resolve to?",
+		Expect: []string{"Clients in country GB:", "They resolve to datacenter /Common/DC1: country GB scores it 250"},
+	},
+	{
+		Name:  "AFM not provisioned",
+		Query: "show the address lists",
+		Setup: func(f *FakeIControl) {
+			f.FailNext("/mgmt/tm/security/firewall/address-list", 404)
+		},
+		Expect: []string{"AFM isn't provisioned on this device, so there are no firewall address lists."},
+	},
+	{
+		Name:  "firewall address list entries",
+		Query: "what's in address-list blocked_countries?",
+		Expect: []string{"=== Address List: /Common/blocked_countries ===", "Description   Embargoed countries",
+			"Entries       4", "  geo CU\n  geo IR\n  geo KP\n  geo SY"},
+	},
+	{
+		Name:   "firewall port lists listed",
+		Query:  "show the port lists",
+		Expect: []string{"=== Port Lists (2) ===", "/Common/admin_ports 2 entries", "/Common/web_ports   3 entries  (Published web services)"},
+	},
+	{
+		Name:  "entries for an address list shown before they're added",
+		Query: "add 203.0.113.9 and 203.0.113.7 to address-list blocked_ips",
+		Expect: []string{"=== Add to Address List: /Common/blocked_ips ===", "  + 203.0.113.9", "Already in the list: 203.0.113.7",
+			"Reply 'yes' to add it."},
+		Check: func(f *FakeIControl) error {
+			if entries := f.FirewallList("address-list", "/Common/blocked_ips", "addresses"); len(entries) != 2 {
+				return fmt.Errorf("the address list was changed before it was confirmed: %v", entries)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "entries added to an address list after confirmation",
+		Query:  "yes",
+		Expect: []string{"Added 1 entry to address list /Common/blocked_ips (203.0.113.9); it now holds 3."},
+		Check: func(f *FakeIControl) error {
+			entries := f.FirewallList("address-list", "/Common/blocked_ips", "addresses")
+			if strings.Join(entries, " ") != "203.0.113.7 203.0.113.8 203.0.113.9" {
+				return fmt.Errorf("expected 203.0.113.9 appended to the address list, got %v", entries)
+			}
+			if first := f.FirewallEntries("address-list", "/Common/blocked_ips", "addresses")[0]; first["description"] != "Port scanner" {
+				return fmt.Errorf("the existing entries weren't written back as they were: %v", first)
+			}
+			if entry, err := lastAuditEntry(); err != nil || !entry.Modified {
+				return fmt.Errorf("expected the change audited as a change, got %+v (%v)", entry, err)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "address list read afresh after the change",
+		Query:  "show the blocked_ips address list",
+		Expect: []string{"Entries       3", "  203.0.113.9"},
+	},
+	{
+		Name:   "invalid port refused before anything is shown",
+		Query:  "add 70000 to port-list web_ports",
+		Expect: []string{"Nothing was added to port list /Common/web_ports: '70000' isn't something a port list can hold."},
+	},
+	{
+		Name:  "pools sharing a name in two partitions keep their own members",
+		Query: "refresh the pools",
+		Setup: func(f *FakeIControl) {
+			f.EditItems("/mgmt/tm/ltm/pool", func(items []interface{}) []interface{} {
+				return append(items, map[string]interface{}{
+					"kind": "tm:ltm:pool:poolstate", "name": "web_pool", "partition": "Tenant_A", "fullPath": "/Tenant_A/web_pool",
+					"loadBalancingMode": "round-robin", "monitor": "/Common/http",
+					"membersReference": map[string]interface{}{"link": "https://localhost/mgmt/tm/ltm/pool/~Tenant_A~web_pool/members?ver=16.1.3", "isSubcollection": true},
+				})
+			})
+		},
+		Expect: []string{"web_pool  round-robin     /Common/http  2\nweb_pool  round-robin     /Common/http  1\n"},
+		Check: func(f *FakeIControl) error {
+			if n := f.Requests("/mgmt/tm/ltm/pool/~Tenant_A~web_pool/members"); n != 1 {
+				return fmt.Errorf("expected the members of /Tenant_A/web_pool read by full path, saw %d request(s)", n)
+			}
+			return nil
+		},
+	},
+	// These exhaust the session's token limit, so they must stay last
+	{
+		Name:     "spend recorded from completion usage",
+		Query:    "what is a monitor in BIG-IP?",
+		SetupLLM: func(f *FakeLLM) { f.ChargeNext(spendScenarioLimit) },
+		CheckLLM: func(completions int) error {
+			if completions != 1 {
+				return fmt.Errorf("expected one chat completion, got %d", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "spend limit stops LLM requests",
+		Query:  "what is a profile in BIG-IP?",
+		Expect: []string{"session LLM token limit has been reached", "LLM_SESSION_TOKEN_LIMIT", "-ignore-spend-limits"},
+		CheckLLM: func(completions int) error {
+			if completions != 0 {
+				return fmt.Errorf("expected no chat completions once the limit is reached, got %d", completions)
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "usage report",
+		Query:  "/usage",
+		Expect: []string{"This session:", "limit 1000000", "Today:"},
+	},
+}
+
+// spendScenarioLimit is the session token limit the e2e client runs with
+const spendScenarioLimit = 1000000
+
+// exportFile is where the export scenario writes its CSV; it's removed after
+var exportFile = filepath.Join(os.TempDir(), "chatf5-e2e-export.csv")
+
+// answerFile is where the save scenarios write an answer; it's removed after
+var answerFile = filepath.Join(os.TempDir(), "chatf5-e2e-answer.txt")
+
+// qkviewFile is where the qkview scenarios save the qkview; it's removed
+// after
+var qkviewFile = filepath.Join(os.TempDir(), "chatf5-e2e.qkview")
+
+// auditFile is the audit log the scenarios are recorded in; it's removed
+// after
+var auditFile = filepath.Join(os.TempDir(), "chatf5-e2e-audit.jsonl")
+
+// journalFile is the change journal the scenarios' changes are recorded
+// in; it's removed after
+var journalFile = filepath.Join(os.TempDir(), "chatf5-e2e-changes.jsonl")
+
+// changes opens the scenarios' change journal
+func changes() *journal.Journal {
+	j, _ := journal.Open(journalFile, "e2e")
+	return j
+}
+
+// lastAuditEntry reads the latest entry of the audit log
+func lastAuditEntry() (audit.Entry, error) {
+	var entry audit.Entry
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		return entry, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	err = json.Unmarshal([]byte(lines[len(lines)-1]), &entry)
+	return entry, err
+}
+
+// Run starts the fake iControl and LLM servers, connects the real clients to
+// them and runs each scenario through chat.Interface
+func Run(scenarios []Scenario) ([]Result, error) {
+	const username, password = "admin", "e2e-password"
+
+	icontrol := NewFakeIControl(username, password)
+	defer icontrol.Close()
+	fakeLLM := NewFakeLLM()
+	defer fakeLLM.Close()
+	fakeIHealth := NewFakeIHealth("e2e-client", "e2e-secret")
+	defer fakeIHealth.Close()
+
+	cfg := &config.Config{
+		BigIPHost:     icontrol.Host(),
+		BigIPUsername: username,
+		BigIPPassword: password,
+		OpenAIKey:     "e2e-key",
+		OpenAIBaseURL: fakeLLM.BaseURL(),
+		CacheTTL:      time.Minute,
+		// Token auth exercises login and re-authentication
+		BigIPAuthMethod:    "token",
+		BigIPLoginProvider: "tmos",
+		TraceFile:          os.Getenv("E2E_TRACE_FILE"),
+		// Keep retry scenarios fast
+		RetryBaseDelay: 10 * time.Millisecond,
+		RetryMaxDelay:  50 * time.Millisecond,
+
+		LLMRetryMaxAttempts: 3,
+		LLMRetryBaseDelay:   10 * time.Millisecond,
+		LLMRetryMaxDelay:    50 * time.Millisecond,
+		// Bag-of-words fake embeddings score lower than a real model
+		RAGTopK:     2,
+		RAGMinScore: 0.2,
+		// Only near-verbatim seed queries skip the fake LLM
+		IntentMinScore:  0.9,
+		IntentMinMargin: 0.05,
+		RedactEnabled:   true,
+		// Only reached when a scenario charges for it
+		LLMSessionTokenLimit: spendScenarioLimit,
+		AuditLog:             auditFile,
+	}
+	os.Remove(auditFile)
+	defer os.Remove(auditFile)
+
+	bigipClient, err := bigip.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to fake iControl: %v", err)
+	}
+	llmClient, err := llm.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize LLM client: %v", err)
+	}
+	chatInterface := chat.NewInterface(bigipClient, llmClient)
+	auditLog, err := audit.Open(auditFile, "e2e")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit log: %v", err)
+	}
+	chatInterface.SetAudit(auditLog)
+	os.Remove(journalFile)
+	defer os.Remove(journalFile)
+	changes, err := journal.Open(journalFile, "e2e")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the change journal: %v", err)
+	}
+	chatInterface.SetJournal(changes)
+	chatInterface.SetIHealth(ihealth.New(fakeIHealth.APIURL(), fakeIHealth.TokenURL(), fakeIHealth.ClientID, fakeIHealth.ClientSecret))
+	chatInterface.EnableDocumentation(cfg)
+	chatInterface.EnableIntentClassifier(cfg)
+	queries, _ := history.Open("", 0)
+	chatInterface.SetQueryHistory(queries)
+	saved, _ := history.OpenSaved("")
+	chatInterface.SetSavedQueries(saved)
+	snapshots, _ := snapshot.Open("", icontrol.Host())
+	chatInterface.SetSnapshots(snapshots)
+	// A second device with the built-in demo data, which has legacy_pool
+	chatInterface.AddDevice("primary", bigipClient)
+	chatInterface.AddDevice("dr", bigip.NewMockClient())
+
+	var streamed strings.Builder
+	chatInterface.SetStream(func(page string) { streamed.WriteString(page) })
+
+	var results []Result
+	for _, sc := range scenarios {
+		slog.Info("Running e2e scenario", "scenario", sc.Name)
+		if sc.Setup != nil {
+			sc.Setup(icontrol)
+		}
+		if sc.SetupLLM != nil {
+			sc.SetupLLM(fakeLLM)
+		}
+
+		fakeLLM.Received("")
+		streamed.Reset()
+		completionsBefore := fakeLLM.Completions()
+		start := time.Now()
+		response, err := chatInterface.ProcessQuery(sc.Query)
+		result := Result{Scenario: sc.Name, Passed: true, Duration: time.Since(start)}
+
+		output := response
+		switch {
+		case sc.ExpectError && err == nil:
+			result.Passed, result.Detail = false, "expected an error but the query succeeded"
+		case !sc.ExpectError && err != nil:
+			result.Passed, result.Detail = false, fmt.Sprintf("unexpected error: %v", err)
+		case err != nil:
+			output = err.Error()
+		}
+
+		if result.Passed {
+			for _, want := range sc.Expect {
+				if !strings.Contains(output, want) {
+					result.Passed, result.Detail = false, fmt.Sprintf("missing %q in output:\n%s", want, output)
+					break
+				}
+			}
+		}
+		for _, want := range sc.ExpectStreamed {
+			if result.Passed && !strings.Contains(streamed.String(), want) {
+				result.Passed, result.Detail = false, fmt.Sprintf("missing %q in what was streamed:\n%s", want, streamed.String())
+			}
+		}
+		if result.Passed && sc.Check != nil {
+			if err := sc.Check(icontrol); err != nil {
+				result.Passed, result.Detail = false, err.Error()
+			}
+		}
+		if result.Passed && sc.CheckLLM != nil {
+			if err := sc.CheckLLM(fakeLLM.Completions() - completionsBefore); err != nil {
+				result.Passed, result.Detail = false, err.Error()
+			}
+		}
+		for _, secret := range sc.NotSentToLLM {
+			if result.Passed && fakeLLM.Received(secret) {
+				result.Passed, result.Detail = false, fmt.Sprintf("%q was sent to the LLM", secret)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
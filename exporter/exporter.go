@@ -0,0 +1,228 @@
+// Package exporter scrapes a BIG-IP's health on an interval and exposes it
+// as Prometheus metrics, alongside chatf5's own on /metrics: virtual server
+// availability, pool member status, CPU usage and days until each
+// certificate expires. With anomaly detection on, connection rates and CPU
+// usage far from their usual for the hour are exported and reported too.
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"f5chat/anomaly"
+	"f5chat/bigip"
+)
+
+// Device is what the exporter reads from a BIG-IP; the client and the mock
+// the chat uses both are one
+type Device interface {
+	GetVirtualServers() ([]bigip.VirtualServer, error)
+	GetStats(kind string) (map[string]bigip.ObjectStats, error)
+	GetPools() ([]bigip.Pool, map[string][]bigip.PoolMember, error)
+	GetPoolMembers(pool string) ([]bigip.PoolMember, error)
+	GetCertificates() ([]bigip.Certificate, error)
+	GetCPUUsage() (float64, error)
+	ClearCache()
+}
+
+var (
+	_ Device = (*bigip.Client)(nil)
+	_ Device = (*bigip.MockClient)(nil)
+)
+
+var (
+	virtualServerAvailable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "bigip",
+		Name:      "virtual_server_available",
+		Help:      "1 if the virtual server is available, 0 if it is offline, disabled or unknown.",
+	}, []string{"device", "virtual_server"})
+
+	poolMemberUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "bigip",
+		Name:      "pool_member_up",
+		Help:      "1 if the pool member's state is up, 0 otherwise.",
+	}, []string{"device", "pool", "member"})
+
+	cpuUsage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "bigip",
+		Name:      "cpu_usage_percent",
+		Help:      "CPU busy over the last minute, averaged across CPUs.",
+	}, []string{"device"})
+
+	certificateExpiryDays = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "bigip",
+		Name:      "certificate_expiry_days",
+		Help:      "Days until the certificate expires; negative once it has.",
+	}, []string{"device", "certificate"})
+
+	anomalyScore = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "bigip",
+		Name:      "anomaly_zscore",
+		Help:      "How many standard deviations a reading is from its usual for the hour, for readings unusual on the last scrape.",
+	}, []string{"device", "metric", "object"})
+
+	scrapeSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "bigip",
+		Name:      "scrape_success",
+		Help:      "1 if every part of the last scrape succeeded, 0 otherwise.",
+	}, []string{"device"})
+
+	scrapeDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "bigip",
+		Name:      "scrape_duration_seconds",
+		Help:      "How long the last scrape took.",
+	}, []string{"device"})
+)
+
+// Exporter scrapes one device into the metrics above, labelled with its name
+type Exporter struct {
+	device Device
+	name   string
+	// anomalies, when set, looks for unusual readings on each scrape and
+	// passes them to report
+	anomalies *anomaly.Detector
+	report    func(anomaly.Anomaly)
+}
+
+// New returns an exporter of device, whose metrics carry name as their
+// device label
+func New(device Device, name string) *Exporter {
+	return &Exporter{device: device, name: name}
+}
+
+// SetAnomalies has each scrape compare connection rates and CPU usage with
+// d's baselines, exporting the z-scores of unusual readings and passing
+// them to report
+func (e *Exporter) SetAnomalies(d *anomaly.Detector, report func(anomaly.Anomaly)) {
+	e.anomalies, e.report = d, report
+}
+
+// Run scrapes every interval until stop is closed, starting right away.
+// Failed scrapes are logged and leave the metrics they couldn't refresh as
+// they were.
+func (e *Exporter) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Scrape(); err != nil {
+			slog.Warn("Scrape failed", "device", e.name, "err", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scrape reads the device afresh and updates the metrics. Objects that have
+// gone from the device are dropped from them.
+func (e *Exporter) Scrape() error {
+	started := time.Now()
+	e.device.ClearCache()
+	errs := []error{e.scrapeVirtualServers(), e.scrapePoolMembers(), e.scrapeCPU(), e.scrapeCertificates(started), e.scrapeAnomalies(started)}
+	err := errors.Join(errs...)
+
+	success := 1.0
+	if err != nil {
+		success = 0
+	}
+	scrapeSuccess.WithLabelValues(e.name).Set(success)
+	scrapeDuration.WithLabelValues(e.name).Set(time.Since(started).Seconds())
+	return err
+}
+
+func (e *Exporter) scrapeVirtualServers() error {
+	vs, err := e.device.GetVirtualServers()
+	if err != nil {
+		return err
+	}
+	stats, err := e.device.GetStats("virtual")
+	if err != nil {
+		return err
+	}
+	virtualServerAvailable.DeletePartialMatch(prometheus.Labels{"device": e.name})
+	for _, v := range vs {
+		available := 0.0
+		if !v.Disabled && stats[v.FullPath].Availability == "available" {
+			available = 1
+		}
+		virtualServerAvailable.WithLabelValues(e.name, v.FullPath).Set(available)
+	}
+	return nil
+}
+
+func (e *Exporter) scrapePoolMembers() error {
+	pools, listed, err := e.device.GetPools()
+	if err != nil {
+		return err
+	}
+	// listed is shared with the client's cache, so it is only read
+	members := make(map[string][]bigip.PoolMember, len(pools))
+	for _, p := range pools {
+		m, ok := listed[p.FullPath]
+		if !ok {
+			// The listing couldn't read them; asking again says why
+			if m, err = e.device.GetPoolMembers(p.FullPath); err != nil {
+				return fmt.Errorf("pool %s: %w", p.FullPath, err)
+			}
+		}
+		members[p.FullPath] = m
+	}
+	poolMemberUp.DeletePartialMatch(prometheus.Labels{"device": e.name})
+	for pool, m := range members {
+		for _, member := range m {
+			up := 0.0
+			if member.State == "up" {
+				up = 1
+			}
+			poolMemberUp.WithLabelValues(e.name, pool, member.FullPath).Set(up)
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) scrapeCPU() error {
+	usage, err := e.device.GetCPUUsage()
+	if err != nil {
+		return err
+	}
+	cpuUsage.WithLabelValues(e.name).Set(usage)
+	return nil
+}
+
+func (e *Exporter) scrapeCertificates(now time.Time) error {
+	certs, err := e.device.GetCertificates()
+	if err != nil {
+		return err
+	}
+	certificateExpiryDays.DeletePartialMatch(prometheus.Labels{"device": e.name})
+	for _, c := range certs {
+		if expires := c.Expires(); !expires.IsZero() {
+			days := expires.Sub(now).Hours() / 24
+			certificateExpiryDays.WithLabelValues(e.name, c.FullPath).Set(math.Round(days*10) / 10)
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) scrapeAnomalies(now time.Time) error {
+	if e.anomalies == nil {
+		return nil
+	}
+	found, err := e.anomalies.Check(e.name, e.device, now)
+	anomalyScore.DeletePartialMatch(prometheus.Labels{"device": e.name})
+	for _, a := range found {
+		anomalyScore.WithLabelValues(e.name, a.Metric, a.Object).Set(math.Round(a.Z*10) / 10)
+		if e.report != nil {
+			e.report(a)
+		}
+	}
+	return err
+}
@@ -4,5 +4,20 @@ go 1.21
 
 require (
 	github.com/f5devcentral/go-bigip v0.0.0-20241021135443-33e2cde9829b
+	github.com/prometheus/client_golang v1.19.1
 	github.com/sashabaranov/go-openai v1.36.0
+	golang.org/x/sync v0.10.0
+	golang.org/x/sys v0.17.0
+	golang.org/x/time v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
 )
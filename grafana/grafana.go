@@ -0,0 +1,110 @@
+// Package grafana publishes annotations to Grafana, marking the changes
+// chatf5 makes and the failovers it sees on the traffic graphs, so a dip
+// or a spike can be lined up with what was done to the device at the time.
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"f5chat/config"
+)
+
+// timeout bounds each annotation, so a slow Grafana can't hold up a change
+// or a watch
+const timeout = 10 * time.Second
+
+// Annotation is an event marked on the graphs at Time, with Text shown on
+// hovering over it and Tags to filter annotations by
+type Annotation struct {
+	Time time.Time
+	Text string
+	Tags []string
+}
+
+// Client publishes annotations through Grafana's HTTP API, on the
+// dashboard with DashboardUID or, without one, across the organization
+type Client struct {
+	url          string
+	token        string
+	dashboardUID string
+	client       *http.Client
+}
+
+// New returns a client for the Grafana at baseURL, e.g.
+// "https://grafana.example.com", signing in with a service account token
+func New(baseURL, token, dashboardUID string) *Client {
+	return &Client{
+		url:          strings.TrimRight(baseURL, "/") + "/api/annotations",
+		token:        token,
+		dashboardUID: dashboardUID,
+		client:       &http.Client{Timeout: timeout},
+	}
+}
+
+// NewFromConfig returns the client GRAFANA_URL and GRAFANA_TOKEN
+// configure, nil if GRAFANA_URL isn't set
+func NewFromConfig(cfg *config.Config) (*Client, error) {
+	if cfg.GrafanaURL == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(cfg.GrafanaURL); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid GRAFANA_URL %q (expected https://host)", cfg.GrafanaURL)
+	}
+	if cfg.GrafanaToken == "" {
+		return nil, errors.New("GRAFANA_URL is set but GRAFANA_TOKEN isn't: annotations need a service account token with the annotations:write permission")
+	}
+	return New(cfg.GrafanaURL, cfg.GrafanaToken, cfg.GrafanaDashboardUID), nil
+}
+
+// Annotate publishes a, failing if Grafana doesn't accept it
+func (c *Client) Annotate(ctx context.Context, a Annotation) error {
+	request := map[string]interface{}{
+		"time": a.Time.UnixMilli(),
+		"text": a.Text,
+		"tags": a.Tags,
+	}
+	if c.dashboardUID != "" {
+		request["dashboardUID"] = c.dashboardUID
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", "chatf5")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("POST to %s failed: %v", c.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		// Grafana explains itself in {"message": "..."}
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 300))
+		var failure struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(reply, &failure) == nil && failure.Message != "" {
+			reply = []byte(failure.Message)
+		}
+		return fmt.Errorf("HTTP %d from %s: %s", resp.StatusCode, c.url, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
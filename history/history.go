@@ -0,0 +1,135 @@
+// Package history keeps the queries typed at the chat prompt, one per line
+// in a file of the user's, so they can be listed, recalled with the up
+// arrow and run again in later sessions, and the queries saved under a name
+// for running as a personal runbook.
+package history
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store is the list of earlier queries, oldest first. Numbers shown to the
+// user are positions in the list, starting at 1.
+type Store struct {
+	mu      sync.Mutex
+	file    string
+	size    int
+	queries []string
+}
+
+// Open loads the last size queries from file. An empty file name keeps the
+// history in memory only; a missing file starts an empty one.
+func Open(file string, size int) (*Store, error) {
+	s := &Store{file: file, size: size}
+	if file == "" {
+		return s, nil
+	}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			s.queries = append(s.queries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	s.trim()
+	return s, nil
+}
+
+// trim drops the oldest queries beyond the size; callers hold s.mu or own s
+func (s *Store) trim() bool {
+	if s.size > 0 && len(s.queries) > s.size {
+		s.queries = append([]string(nil), s.queries[len(s.queries)-s.size:]...)
+		return true
+	}
+	return false
+}
+
+// Add records a query unless it repeats the one before it. The file is
+// appended to, and rewritten once it holds more than the size.
+func (s *Store) Add(query string) {
+	if s == nil {
+		return
+	}
+	query = strings.Join(strings.Fields(query), " ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if query == "" || len(s.queries) > 0 && s.queries[len(s.queries)-1] == query {
+		return
+	}
+	s.queries = append(s.queries, query)
+	if s.file == "" {
+		s.trim()
+		return
+	}
+
+	// History is a convenience; failing to save it mustn't stop the query
+	var err error
+	if s.trim() {
+		err = s.rewrite()
+	} else {
+		err = s.appendLine(query)
+	}
+	if err != nil {
+		slog.Warn("Failed to save query history", "file", s.file, "err", err)
+	}
+}
+
+func (s *Store) appendLine(query string) error {
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(query + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *Store) rewrite() error {
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.file, []byte(strings.Join(s.queries, "\n")+"\n"), 0o600)
+}
+
+// Queries returns a copy of the history, oldest first
+func (s *Store) Queries() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// Get returns query number n, counting from 1
+func (s *Store) Get(n int) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < 1 || n > len(s.queries) {
+		return "", false
+	}
+	return s.queries[n-1], true
+}
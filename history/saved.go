@@ -0,0 +1,106 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// savedName is what a saved query may be called: "morning-check", "vs.audit"
+var savedName = regexp.MustCompile(`^[A-Za-z0-9][\w.-]*$`)
+
+// Saved holds named queries, each a list of queries run in order, kept as
+// JSON in a file of the user's so they outlive the session
+type Saved struct {
+	mu      sync.Mutex
+	file    string
+	queries map[string][]string
+}
+
+// OpenSaved loads the saved queries from file. An empty file name keeps them
+// in memory only; a missing file starts with none.
+func OpenSaved(file string) (*Saved, error) {
+	s := &Saved{file: file, queries: make(map[string][]string)}
+	if file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.queries); err != nil {
+		return nil, fmt.Errorf("unreadable saved queries in %s: %w", file, err)
+	}
+	return s, nil
+}
+
+// ValidName reports whether name can be used for a saved query
+func ValidName(name string) bool {
+	return savedName.MatchString(name)
+}
+
+// Save stores queries under name, replacing any saved before. It reports
+// whether an earlier entry was replaced.
+func (s *Saved) Save(name string, queries []string) (bool, error) {
+	if !ValidName(name) {
+		return false, fmt.Errorf("'%s' can't be used as a name; use letters, digits, dots, dashes and underscores", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, replaced := s.queries[name]
+	s.queries[name] = append([]string(nil), queries...)
+	return replaced, s.write()
+}
+
+// Delete removes a saved query, reporting whether there was one
+func (s *Saved) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.queries[name]; !ok {
+		return false, nil
+	}
+	delete(s.queries, name)
+	return true, s.write()
+}
+
+// Get returns the queries saved under name
+func (s *Saved) Get(name string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries, ok := s.queries[name]
+	return append([]string(nil), queries...), ok
+}
+
+// Names returns the saved names in alphabetical order
+func (s *Saved) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.queries))
+	for name := range s.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// write saves the queries to the file; callers hold s.mu
+func (s *Saved) write() error {
+	if s.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.queries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.file, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,376 @@
+// Package ihealth uploads qkviews to F5 iHealth and reads back what its
+// heuristics found in them, so a support case can start from the
+// diagnostics rather than from uploading a file by hand.
+package ihealth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"f5chat/config"
+)
+
+const (
+	// DefaultURL is the iHealth API qkviews are uploaded to
+	DefaultURL = "https://ihealth2-api.f5.com/qkview-analyzer/api"
+	// DefaultTokenURL is where iHealth API credentials are exchanged for
+	// an access token
+	DefaultTokenURL = "https://identity.account.f5.com/oauth2/ausp95ykc80HOU7SQ357/v1/token"
+	// guiURL is where an uploaded qkview is opened in a browser
+	guiURL = "https://ihealth2.f5.com/qkview-analyzer/qv/"
+	// mediaType is what the API answers in
+	mediaType = "application/vnd.f5.ihealth.api+json"
+)
+
+// requestTimeout bounds each request but the upload, which is as slow as
+// the qkview is big
+const requestTimeout = 30 * time.Second
+
+// pollInterval is how often a qkview being analyzed is checked, and
+// analysisTimeout how long to wait for iHealth to finish with it
+var (
+	pollInterval    = 15 * time.Second
+	analysisTimeout = 15 * time.Minute
+)
+
+// importance orders diagnostics from the most to the least urgent
+var importance = map[string]int{"CRITICAL": 0, "HIGH": 1, "MEDIUM": 2, "LOW": 3}
+
+// Diagnostic is an iHealth heuristic that matched the qkview: Name is its
+// ID, e.g. H701182, and Solutions the support articles that explain it
+type Diagnostic struct {
+	Name       string
+	Importance string
+	Header     string
+	Summary    string
+	Action     string
+	Solutions  []string
+}
+
+// Report is what iHealth made of an uploaded qkview
+type Report struct {
+	// ID is the qkview's ID in iHealth, and URL where to open it
+	ID          string
+	URL         string
+	Hostname    string
+	Diagnostics []Diagnostic
+}
+
+// Client uploads qkviews through the iHealth API, signing in with the
+// client ID and secret of an iHealth API key
+type Client struct {
+	url          string
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// New returns a client for the iHealth API at apiURL, signing in at
+// tokenURL
+func New(apiURL, tokenURL, clientID, clientSecret string) *Client {
+	return &Client{
+		url:          strings.TrimRight(apiURL, "/"),
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client: &http.Client{
+			// The upload answers with a redirect to the new qkview, whose
+			// ID is all that's wanted from it
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// NewFromConfig returns the client IHEALTH_CLIENT_ID and
+// IHEALTH_CLIENT_SECRET configure, nil if IHEALTH_CLIENT_ID isn't set
+func NewFromConfig(cfg *config.Config) (*Client, error) {
+	if cfg.IHealthClientID == "" {
+		return nil, nil
+	}
+	if cfg.IHealthClientSecret == "" {
+		return nil, errors.New("IHEALTH_CLIENT_ID is set but IHEALTH_CLIENT_SECRET isn't: create an API key at ihealth2.f5.com under Settings")
+	}
+	apiURL, tokenURL := cfg.IHealthURL, cfg.IHealthTokenURL
+	if apiURL == "" {
+		apiURL = DefaultURL
+	}
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+	for name, value := range map[string]string{"IHEALTH_API_URL": apiURL, "IHEALTH_TOKEN_URL": tokenURL} {
+		if u, err := url.Parse(value); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s %q (expected https://host/path)", name, value)
+		}
+	}
+	return New(apiURL, tokenURL, cfg.IHealthClientID, cfg.IHealthClientSecret), nil
+}
+
+// Upload sends the qkview at file to iHealth, against the support case
+// numbered supportCase if it isn't empty, and returns its ID there
+func (c *Client) Upload(ctx context.Context, file, supportCase string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// The form is streamed, so a qkview of hundreds of megabytes isn't
+	// held in memory
+	body, form := io.Pipe()
+	// Closing the reading end stops the writer below whenever the request
+	// ends, including when it is never sent
+	defer body.Close()
+	writer := multipart.NewWriter(form)
+	go func() {
+		part, err := writer.CreateFormFile("qkview", filepath.Base(file))
+		if err == nil {
+			_, err = io.Copy(part, f)
+		}
+		if err == nil && supportCase != "" {
+			err = writer.WriteField("f5_support_case", supportCase)
+		}
+		if err == nil {
+			err = writer.WriteField("visible_in_gui", "True")
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		form.CloseWithError(err)
+	}()
+
+	resp, err := c.do(ctx, http.MethodPost, c.url+"/qkviews", body, writer.FormDataContentType())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := failure(resp); err != nil {
+		return "", fmt.Errorf("iHealth didn't accept %s: %w", filepath.Base(file), err)
+	}
+	// The new qkview is where the answer points
+	location := resp.Header.Get("Location")
+	if location == "" {
+		var created struct {
+			Location string `json:"location"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&created)
+		location = created.Location
+	}
+	id := path.Base(strings.TrimRight(location, "/"))
+	if location == "" || id == "qkviews" {
+		return "", errors.New("iHealth accepted the qkview but didn't say where it is")
+	}
+	return id, nil
+}
+
+// Analyze waits until iHealth has finished with the qkview with id and
+// returns the diagnostics that matched it, most important first
+func (c *Client) Analyze(ctx context.Context, id string) (*Report, error) {
+	qkview := c.url + "/qkviews/" + url.PathEscape(id)
+	report := &Report{ID: id, URL: guiURL + url.PathEscape(id)}
+	deadline := time.Now().Add(analysisTimeout)
+	for {
+		var status struct {
+			Hostname         string   `json:"hostname"`
+			ProcessingStatus string   `json:"processing_status"`
+			Messages         []string `json:"processing_messages"`
+		}
+		code, err := c.get(ctx, qkview, &status)
+		if err != nil {
+			return nil, err
+		}
+		report.Hostname = status.Hostname
+		if strings.EqualFold(status.ProcessingStatus, "ERROR") {
+			return nil, fmt.Errorf("iHealth couldn't analyze qkview %s: %s", id, strings.Join(status.Messages, "; "))
+		}
+		// 202 while the qkview is being analyzed
+		if code != http.StatusAccepted {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("iHealth is still analyzing qkview %s after %s; see %s", id, analysisTimeout, report.URL)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	var hits struct {
+		Diagnostics []struct {
+			Name    string `json:"name"`
+			Results struct {
+				Importance string            `json:"h_importance"`
+				Header     string            `json:"h_header"`
+				Summary    string            `json:"h_summary"`
+				Action     string            `json:"h_action"`
+				Solutions  []json.RawMessage `json:"h_sols"`
+			} `json:"results"`
+		} `json:"diagnostics"`
+	}
+	if _, err := c.get(ctx, qkview+"/diagnostics?set=hit", &hits); err != nil {
+		return nil, err
+	}
+	for _, d := range hits.Diagnostics {
+		diagnostic := Diagnostic{
+			Name:       d.Name,
+			Importance: strings.ToUpper(d.Results.Importance),
+			Header:     strings.TrimSpace(d.Results.Header),
+			Summary:    strings.TrimSpace(d.Results.Summary),
+			Action:     strings.TrimSpace(d.Results.Action),
+		}
+		for _, raw := range d.Results.Solutions {
+			// A link, or {"value": link}
+			var link string
+			var object struct {
+				Value string `json:"value"`
+			}
+			if json.Unmarshal(raw, &link) != nil && json.Unmarshal(raw, &object) == nil {
+				link = object.Value
+			}
+			if link != "" {
+				diagnostic.Solutions = append(diagnostic.Solutions, link)
+			}
+		}
+		report.Diagnostics = append(report.Diagnostics, diagnostic)
+	}
+	sort.SliceStable(report.Diagnostics, func(a, b int) bool {
+		return rank(report.Diagnostics[a].Importance) < rank(report.Diagnostics[b].Importance)
+	})
+	return report, nil
+}
+
+// rank places importances iHealth may add later after the known ones
+func rank(importanceName string) int {
+	if r, ok := importance[importanceName]; ok {
+		return r
+	}
+	return len(importance)
+}
+
+// get fetches target into v and returns the HTTP status
+func (c *Client) get(ctx context.Context, target string, v interface{}) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, target, nil, "")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := failure(resp); err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return resp.StatusCode, fmt.Errorf("unexpected answer from %s: %v", target, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// do sends a request with the access token, signing in first if the token
+// has expired
+func (c *Client) do(ctx context.Context, method, target string, body io.Reader, contentType string) (*http.Response, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("User-Agent", "chatf5")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%s %s failed: %v", method, target, err)
+	}
+	return resp, nil
+}
+
+// accessToken returns a token for the API, exchanging the client ID and
+// secret for a new one when the last has expired
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {"ihealth"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.clientID, c.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("signing in to iHealth failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := failure(resp); err != nil {
+		return "", fmt.Errorf("signing in to iHealth failed: %w", err)
+	}
+	var grant struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil || grant.AccessToken == "" {
+		return "", fmt.Errorf("signing in to iHealth returned no access token")
+	}
+	// Renewed a minute early, so it doesn't expire mid-request
+	c.token = grant.AccessToken
+	c.expires = time.Now().Add(time.Duration(grant.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// failure describes an error answer, nil for anything else
+func failure(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 300))
+	// Errors come as {"message": ...}, or as OAuth's error_description
+	var explained struct {
+		Message     string `json:"message"`
+		Description string `json:"error_description"`
+	}
+	if json.Unmarshal(reply, &explained) == nil {
+		if explained.Description != "" {
+			reply = []byte(explained.Description)
+		}
+		if explained.Message != "" {
+			reply = []byte(explained.Message)
+		}
+	}
+	return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(reply)))
+}
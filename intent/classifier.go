@@ -0,0 +1,135 @@
+// Package intent maps queries onto chat tools by embedding similarity to a
+// labelled seed set, so common requests skip the chat completion entirely.
+// Queries it isn't confident about are left to the LLM.
+package intent
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"f5chat/llm"
+	"f5chat/metrics"
+	"f5chat/rag"
+)
+
+// Classifier matches queries against the embedded seed examples
+type Classifier struct {
+	embedder  rag.Embedder
+	minScore  float64
+	minMargin float64
+
+	once     sync.Once
+	err      error
+	examples []example
+}
+
+type example struct {
+	tool   string
+	vector []float32
+}
+
+// NewClassifier creates a classifier. A query is classified only when its
+// best match scores at least minScore and beats the best match for any other
+// tool by minMargin.
+func NewClassifier(embedder rag.Embedder, minScore, minMargin float64) *Classifier {
+	return &Classifier{embedder: embedder, minScore: minScore, minMargin: minMargin}
+}
+
+// Classify returns the tool call for the query, or ok=false when the query
+// should go to the LLM
+func (c *Classifier) Classify(query string) (call *llm.ToolCall, score float64, ok bool) {
+	if refersBack(query) {
+		// Follow-ups depend on the conversation, which only the LLM sees
+		metrics.IntentClassifications.WithLabelValues("fallback").Inc()
+		return nil, 0, false
+	}
+
+	tool, score, margin, err := c.best(query)
+	if err != nil {
+		slog.Warn("Intent classification failed; using the LLM", "err", err)
+		metrics.IntentClassifications.WithLabelValues("error").Inc()
+		return nil, 0, false
+	}
+	if score < c.minScore || margin < c.minMargin {
+		slog.Debug("Intent classification not confident; using the LLM", "tool", tool, "score", score, "margin", margin)
+		metrics.IntentClassifications.WithLabelValues("fallback").Inc()
+		return nil, score, false
+	}
+	slog.Debug("Classified intent", "tool", tool, "score", score, "margin", margin)
+	metrics.IntentClassifications.WithLabelValues("hit").Inc()
+	return &llm.ToolCall{Name: tool, Args: map[string]string{}}, score, true
+}
+
+// best returns the closest tool with its score and its lead over the
+// runner-up tool
+func (c *Classifier) best(query string) (tool string, score, margin float64, err error) {
+	c.once.Do(c.embedSeeds)
+	if c.err != nil {
+		return "", 0, 0, c.err
+	}
+	vectors, err := c.embedder.Embed([]string{query})
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if len(vectors) == 0 {
+		return "", 0, 0, fmt.Errorf("no embedding returned for query")
+	}
+
+	scores := make(map[string]float64)
+	for _, ex := range c.examples {
+		if s := rag.Cosine(vectors[0], ex.vector); s > scores[ex.tool] {
+			scores[ex.tool] = s
+		}
+	}
+	ranked := make([]string, 0, len(scores))
+	for t := range scores {
+		ranked = append(ranked, t)
+	}
+	sort.Slice(ranked, func(i, j int) bool { return scores[ranked[i]] > scores[ranked[j]] })
+	if len(ranked) == 0 {
+		return "", 0, 0, nil
+	}
+	margin = scores[ranked[0]]
+	if len(ranked) > 1 {
+		margin -= scores[ranked[1]]
+	}
+	return ranked[0], scores[ranked[0]], margin, nil
+}
+
+// embedSeeds embeds every seed example once, on first use
+func (c *Classifier) embedSeeds() {
+	var texts, tools []string
+	for tool, queries := range seeds {
+		for _, q := range queries {
+			texts = append(texts, q)
+			tools = append(tools, tool)
+		}
+	}
+	vectors, err := c.embedder.Embed(texts)
+	if err != nil {
+		c.err = fmt.Errorf("failed to embed intent examples: %w", err)
+		return
+	}
+	if len(vectors) != len(texts) {
+		c.err = fmt.Errorf("failed to embed intent examples: got %d embeddings for %d examples", len(vectors), len(texts))
+		return
+	}
+	for i := range texts {
+		c.examples = append(c.examples, example{tool: tools[i], vector: vectors[i]})
+	}
+}
+
+// refersBack reports whether the query points at an earlier answer ("its
+// members", "the second one") rather than standing alone
+func refersBack(query string) bool {
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		switch strings.Trim(w, ".,!?'\"") {
+		case "it", "its", "that", "those", "them", "one", "these", "this":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package intent
+
+import "f5chat/llm"
+
+// seeds are labelled example queries for the operations that take no
+// arguments. Operations on a named object need the LLM to extract the name,
+// so they are never classified here.
+var seeds = map[string][]string{
+	llm.ToolListVirtualServers: {
+		"show virtual servers",
+		"list all virtual servers",
+		"list the VIPs",
+		"what virtual servers are configured?",
+		"display all virtual IPs",
+		"which VIPs do we have",
+	},
+	llm.ToolListPools: {
+		"list all pools",
+		"show me the server pools",
+		"list all pools and their members",
+		"what pools are configured?",
+		"display the load balancing pools",
+	},
+	llm.ToolListNodes: {
+		"display node status",
+		"list all backend nodes",
+		"show backend server status",
+		"what nodes are configured?",
+		"show all nodes",
+	},
+	llm.ToolListWAFPolicies: {
+		"show WAF policies",
+		"list all WAF policies",
+		"list the ASM security policies",
+		"which WAF policies are applied to virtual servers?",
+		"show web application firewall policies",
+	},
+	llm.ToolTroubleshoot: {
+		"why is my app down?",
+		"why is the application not working?",
+		"troubleshoot my application",
+		"the site isn't responding",
+		"why can't users reach the website?",
+	},
+	llm.ToolSecurityPosture: {
+		"what is our security posture?",
+		"how secure is the BIG-IP?",
+		"which VIPs lack a WAF policy?",
+		"give me a security report",
+		"are any services exposed over plain HTTP?",
+	},
+	llm.ToolWAFViolations: {
+		"summarize the WAF violations",
+		"what attacks is the WAF blocking?",
+		"triage the ASM events from the last day",
+		"who is attacking us?",
+		"show recent blocked requests",
+	},
+	llm.ToolGTMTopology: {
+		"show the GTM topology records",
+		"list the GTM regions",
+		"what topology records are configured?",
+		"display the DNS topology",
+		"how is global DNS steering set up?",
+	},
+	llm.ToolGetAddressList: {
+		"show the firewall address lists",
+		"list the AFM address lists",
+		"what address lists are configured?",
+		"which address lists does the firewall have?",
+	},
+	llm.ToolGetPortList: {
+		"show the firewall port lists",
+		"list the AFM port lists",
+		"what port lists are configured?",
+		"which port lists does the firewall have?",
+	},
+	llm.ToolOWASPCompliance: {
+		"show the OWASP Top 10 compliance scores",
+		"is our WAF OWASP compliant?",
+		"OWASP compliance dashboard for the WAF policies",
+		"how well do the WAF policies cover the OWASP Top 10?",
+		"score the ASM policies against OWASP",
+	},
+	llm.ToolChangeJournal: {
+		"what did this tool change last week?",
+		"show the change journal",
+		"what changes has chatf5 made?",
+		"what did you deploy yesterday?",
+		"list the changes made through chatf5",
+	},
+	llm.ToolHealthSummary: {
+		"give me a health summary",
+		"how is the BIG-IP doing?",
+		"is everything OK?",
+		"show the health dashboard",
+		"overall status of the device",
+	},
+	llm.ToolExportTerraform: {
+		"export vs_app1 as Terraform",
+		"generate Terraform for this virtual server and its pool",
+		"convert the config to HCL",
+		"write web_pool as terraform",
+		"export the virtual servers to main.tf",
+	},
+	llm.ToolGenerateAnsible: {
+		"export vs_app1 as an Ansible playbook",
+		"generate Ansible tasks for web_pool",
+		"write a playbook that adds a member to the pool",
+		"give me that as an Ansible task",
+		"convert this virtual server to ansible",
+	},
+	llm.ToolGenerateQkview: {
+		"generate a qkview",
+		"take a qkview and upload it to iHealth",
+		"collect a qkview for support case 00123456",
+		"create a new qkview of this device",
+		"run a qkview and analyze it in ihealth",
+	},
+	llm.ToolUploadQkview: {
+		"upload the qkview to iHealth",
+		"send that qkview to ihealth",
+		"upload bigip1.qkview to iHealth",
+		"analyze the qkview in iHealth",
+		"submit the qkview to ihealth for case 00123456",
+	},
+}
@@ -0,0 +1,174 @@
+// Package journal records the changes chatf5 makes to devices, one JSON
+// object per line in a file kept across sessions: who made each change,
+// on which device, what it was asked to do, and the configuration before
+// and after, so that "what did chatf5 change last week?" can be answered
+// and the changes exported.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"f5chat/audit"
+)
+
+// Results of a change
+const (
+	Applied = "applied"
+	Failed  = "failed"
+)
+
+// Entry is a change made, or tried, on a device
+type Entry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Device string    `json:"device"`
+	// Operation is the tool that made the change, e.g. "upload_irule", and
+	// Object what it changed, e.g. "/Common/maintenance_page"
+	Operation string `json:"operation"`
+	Object    string `json:"object"`
+	// Request is what the user asked for
+	Request string `json:"request,omitempty"`
+	// Before is the object's configuration before the change, "" if it
+	// didn't exist, and After what the change sent
+	Before string `json:"before,omitempty"`
+	After  string `json:"after"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Filter picks entries: those at or after Since, by User and on Device.
+// Fields left empty match every entry.
+type Filter struct {
+	Since  time.Time
+	User   string
+	Device string
+}
+
+func (f Filter) matches(e Entry) bool {
+	return !e.Time.Before(f.Since) &&
+		(f.User == "" || strings.EqualFold(e.User, f.User)) &&
+		(f.Device == "" || strings.EqualFold(e.Device, f.Device))
+}
+
+// Journal is the file changes are appended to
+type Journal struct {
+	mu   sync.Mutex
+	file string
+	user string
+	// entries holds the changes when there's no file
+	entries []Entry
+}
+
+// Open opens the journal at file, creating its directory readable only by
+// its owner if need be. An empty file name keeps the changes in memory for
+// the session only. user names who makes the changes; "" is the login user.
+func Open(file, user string) (*Journal, error) {
+	if file != "" {
+		if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	if user == "" {
+		user = audit.LoginUser()
+	}
+	return &Journal{file: file, user: user}, nil
+}
+
+// Record appends an entry, stamped with the time and the journal's user
+// unless it gives them. It is synced to disk before Record returns, so a
+// change is journaled even if chatf5 doesn't exit cleanly.
+func (j *Journal) Record(e Entry) error {
+	if j == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.User == "" {
+		e.User = j.user
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == "" {
+		j.entries = append(j.entries, e)
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns the changes the filter picks, oldest first. The file is
+// read afresh each time, so changes made from other sessions are included.
+func (j *Journal) Entries(filter Filter) ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	all := j.entries
+	if j.file != "" {
+		var err error
+		if all, err = readEntries(j.file); err != nil {
+			return nil, err
+		}
+	}
+	var out []Entry
+	for _, e := range all {
+		if filter.matches(e) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// File is where the journal is kept, "" when it's in memory
+func (j *Journal) File() string {
+	return j.file
+}
+
+// readEntries reads the journal file; a missing one has no entries
+func readEntries(file string) ([]Entry, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	// Payloads such as AS3 declarations make for long lines
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("unreadable change in %s, line %d: %w", file, n, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
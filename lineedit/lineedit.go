@@ -0,0 +1,320 @@
+// Package lineedit reads lines typed at a terminal with basic editing, the
+// up and down arrows stepping through earlier queries and Tab completing
+// words. When the input isn't a terminal (a pipe or a file) lines are read
+// as they come.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInterrupted is returned when Ctrl-C is pressed at the prompt
+var ErrInterrupted = errors.New("interrupted")
+
+// Reader reads lines from standard input
+type Reader struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  int
+	// history returns the earlier lines, oldest first, for recall
+	history func() []string
+	// complete returns the words Tab may complete the line before the
+	// cursor with (see SetCompleter)
+	complete func(head string) []string
+}
+
+// New returns a Reader of standard input. history is called at each prompt
+// for the lines the up arrow steps back through; it may be nil.
+func New(history func() []string) *Reader {
+	return &Reader{in: bufio.NewReader(os.Stdin), out: os.Stdout, fd: int(os.Stdin.Fd()), history: history}
+}
+
+// SetOutput sends the prompt and the line being edited to w instead of
+// standard output
+func (r *Reader) SetOutput(w io.Writer) {
+	r.out = w
+}
+
+// SetCompleter has Tab complete the word before the cursor. complete is
+// given the line up to the cursor and returns the words that could go in
+// place of its last word; those starting with what was typed, ignoring
+// case, are used. A second Tab lists them when there are several.
+func (r *Reader) SetCompleter(complete func(head string) []string) {
+	r.complete = complete
+}
+
+// maxListed is how many completions a second Tab lists
+const maxListed = 60
+
+// IsTerminal reports whether f is a terminal rather than a pipe or a file
+func IsTerminal(f *os.File) bool {
+	return isTerminal(int(f.Fd()))
+}
+
+// ReadLine shows the prompt and returns the line typed, without its line
+// ending. io.EOF is returned at the end of input or on Ctrl-D at an empty
+// line.
+func (r *Reader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		// Not a terminal: the terminal, if any, does the echoing
+		line, err := r.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	defer restore()
+	return r.edit(prompt)
+}
+
+// ReadPassword shows the prompt and returns the line typed without showing
+// it, for passwords. Ctrl-C returns ErrInterrupted. When the input isn't a
+// terminal, the line is read as it comes.
+func (r *Reader) ReadPassword(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		line, err := r.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	defer restore()
+	var line []rune
+	for {
+		c, _, err := r.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch c {
+		case '\r', '\n':
+			fmt.Fprint(r.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(r.out, "^C\r\n")
+			return "", ErrInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(r.out, "\r\n")
+				return "", io.EOF
+			}
+		case 127, 8: // Backspace
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case 21: // Ctrl-U
+			line = line[:0]
+		default:
+			if c >= ' ' {
+				line = append(line, c)
+			}
+		}
+	}
+}
+
+// edit runs the line editor in raw mode
+func (r *Reader) edit(prompt string) (string, error) {
+	var earlier []string
+	if r.history != nil {
+		earlier = r.history()
+	}
+	// recalled is the position in earlier being shown; draft keeps what was
+	// typed before stepping into the history
+	recalled := len(earlier)
+	var line, draft []rune
+	pos := 0
+
+	redraw := func() {
+		fmt.Fprintf(r.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(r.out, "\x1b[%dD", back)
+		}
+	}
+	show := func(n int) {
+		if n < 0 || n > len(earlier) || n == recalled {
+			return
+		}
+		if recalled == len(earlier) {
+			draft = line
+		}
+		recalled = n
+		if n == len(earlier) {
+			line = draft
+		} else {
+			line = []rune(earlier[n])
+		}
+		pos = len(line)
+		redraw()
+	}
+
+	// tabbed is set when the last key was a Tab that left the line as it was
+	tabbed := false
+	for {
+		c, _, err := r.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if c != '\t' {
+			tabbed = false
+		}
+		switch c {
+		case '\r', '\n':
+			fmt.Fprint(r.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(r.out, "^C\r\n")
+			return "", ErrInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(r.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 21: // Ctrl-U
+			line = append([]rune(nil), line[pos:]...)
+			pos = 0
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 16: // Ctrl-P
+			show(recalled - 1)
+			continue
+		case 14: // Ctrl-N
+			show(recalled + 1)
+			continue
+		case '\t':
+			if r.complete == nil {
+				continue
+			}
+			start := pos
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			word := string(line[start:pos])
+			matches := completions(r.complete(string(line[:pos])), word)
+			switch {
+			case len(matches) == 0:
+				fmt.Fprint(r.out, "\a")
+				continue
+			case len(matches) == 1:
+				word = matches[0] + " "
+			case len(commonPrefix(matches)) > len(word):
+				word = commonPrefix(matches)
+			case tabbed:
+				if len(matches) > maxListed {
+					fmt.Fprintf(r.out, "\r\n%s ... (%d more)", strings.Join(matches[:maxListed], "  "), len(matches)-maxListed)
+				} else {
+					fmt.Fprintf(r.out, "\r\n%s", strings.Join(matches, "  "))
+				}
+				fmt.Fprint(r.out, "\r\n")
+				tabbed = false
+				redraw()
+				continue
+			default:
+				tabbed = true
+				fmt.Fprint(r.out, "\a")
+				continue
+			}
+			completed := []rune(word)
+			line = append(append(append([]rune(nil), line[:start]...), completed...), line[pos:]...)
+			pos = start + len(completed)
+		case 27:
+			switch r.escape() {
+			case "A":
+				show(recalled - 1)
+				continue
+			case "B":
+				show(recalled + 1)
+				continue
+			case "C":
+				if pos < len(line) {
+					pos++
+				}
+			case "D":
+				if pos > 0 {
+					pos--
+				}
+			case "H", "1~", "7~":
+				pos = 0
+			case "F", "4~", "8~":
+				pos = len(line)
+			case "3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if c < ' ' {
+				continue
+			}
+			line = append(line[:pos], append([]rune{c}, line[pos:]...)...)
+			pos++
+		}
+		redraw()
+	}
+}
+
+// completions returns the candidates starting with word, ignoring case,
+// without repeats
+func completions(candidates []string, word string) []string {
+	var matches []string
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if !seen[c] && len(c) >= len(word) && strings.EqualFold(c[:len(word)], word) {
+			seen[c] = true
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+// commonPrefix returns the longest start all of words have
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		n := 0
+		for n < len(prefix) && n < len(w) && prefix[n] == w[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return prefix
+}
+
+// escape reads the rest of an escape sequence such as "\x1b[A" and returns
+// what follows the bracket: "A" for the up arrow, "3~" for Delete
+func (r *Reader) escape() string {
+	b, err := r.in.ReadByte()
+	if err != nil || b != '[' && b != 'O' {
+		return ""
+	}
+	var seq []byte
+	for {
+		b, err := r.in.ReadByte()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, b)
+		if b >= 0x40 && b <= 0x7e {
+			return string(seq)
+		}
+	}
+}
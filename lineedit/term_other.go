@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package lineedit
+
+import "errors"
+
+// makeRaw isn't supported here, so lines are read without editing
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("line editing is not supported on this platform")
+}
+
+// isTerminal can't tell here, so output is treated as going to a file
+func isTerminal(fd int) bool {
+	return false
+}
@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// firewallListQuery matches requests about AFM address and port lists:
+	// "what's in address-list blocked_countries", "show the port lists",
+	// "the trusted_admins address list"
+	firewallListQuery = regexp.MustCompile(`(?i)\b(address|port)[- ]lists?\b`)
+	// firewallListName names the list, "address-list blocked_ips", and
+	// firewallListNameBefore the same in "the blocked_ips address list"
+	firewallListName       = regexp.MustCompile(`(?i)\b(?:address|port)[- ]list\s+["'` + "`" + `]?([\w/.~-]+)`)
+	firewallListNameBefore = regexp.MustCompile(`(?i)([\w/.~-]+)["'` + "`" + `]?\s+(?:address|port)[- ]list\b`)
+	// firewallListAdd matches requests to add entries to a list: "add
+	// 203.0.113.9 to address-list blocked_ips", "append 8443 to the
+	// admin_ports port list"
+	firewallListAdd = regexp.MustCompile(`(?i)^\s*(?:please\s+)?(?:add|append|put|include)\s+(.+?)\s+(?:to|in|into)\s+(?:the\s+)?(?:(?:firewall|afm)\s+)?` +
+		`(?:(address|port)[- ]list\s+["'` + "`" + `]?([\w/.~-]+)["'` + "`" + `]?|["'` + "`" + `]?([\w/.~-]+)["'` + "`" + `]?\s+(address|port)[- ]list)\s*[.!]?\s*$`)
+)
+
+// firewallListWords are words the name patterns catch that aren't names
+var firewallListWords = map[string]bool{
+	"the": true, "a": true, "an": true, "this": true, "that": true, "each": true, "every": true, "all": true, "any": true,
+	"firewall": true, "afm": true, "is": true, "in": true, "of": true, "which": true, "what's": true, "whats": true, "to": true,
+	"for": true, "from": true, "my": true, "our": true, "show": true, "list": true,
+}
+
+// ParseFirewallList recognises a request for an AFM address list or port
+// list, with the list it is about when the query names one
+func ParseFirewallList(query string) (*ToolCall, bool) {
+	m := firewallListQuery.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false
+	}
+	call := &ToolCall{Name: ToolGetAddressList, Args: map[string]string{}}
+	if strings.EqualFold(m[1], "port") {
+		call.Name = ToolGetPortList
+	}
+	for _, pattern := range []*regexp.Regexp{firewallListName, firewallListNameBefore} {
+		if n := pattern.FindStringSubmatch(query); n != nil && !firewallListWords[strings.ToLower(n[1])] {
+			call.Args["name"] = n[1]
+			break
+		}
+	}
+	return call, true
+}
+
+// ParseAddToFirewallList recognises a request to add entries to a named
+// address list or port list
+func ParseAddToFirewallList(query string) (*ToolCall, bool) {
+	m := firewallListAdd.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false
+	}
+	kind, name := m[2], m[3]
+	if name == "" {
+		kind, name = m[5], m[4]
+	}
+	if firewallListWords[strings.ToLower(name)] {
+		return nil, false
+	}
+	return &ToolCall{Name: ToolAddToFirewallList, Args: map[string]string{
+		"kind": strings.ToLower(kind), "name": name, "entries": strings.TrimSpace(m[1]),
+	}}, true
+}
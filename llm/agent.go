@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"f5chat/prompt"
+	"github.com/sashabaranov/go-openai"
+)
+
+// AgentStep is one tool call made during an agent run and what it returned
+type AgentStep struct {
+	Call   *ToolCall
+	Result string
+}
+
+// AgentRequest is the state of an agent run: the question, the steps taken
+// so far and whether the model must now answer without calling more tools
+type AgentRequest struct {
+	History []Message
+	Query   string
+	Steps   []AgentStep
+	Final   bool
+}
+
+// Agent is implemented by providers that can run a multi-step tool loop:
+// each call returns either the next tool call or the final answer as text
+type Agent interface {
+	NextStep(req *AgentRequest) (*Reply, error)
+}
+
+// AgentTool reports whether the agent may call a tool. Only tools that read
+// the device are offered; writing iRules or declarations needs the user.
+func AgentTool(name string) bool {
+	switch name {
+	case ToolGenerateIRule, ToolGenerateAS3:
+		return false
+	}
+	return ToolRisk(name) == RiskReadOnly
+}
+
+func (o *OpenAIClient) NextStep(ar *AgentRequest) (*Reply, error) {
+	instructions := o.prompts.Template(prompt.Agent)
+	history := append([]Message{{Role: RoleSystem, Content: instructions}}, ar.History...)
+	req := o.newRequest(history, ar.Query)
+	for j, step := range ar.Steps {
+		id := fmt.Sprintf("call_%d", j+1)
+		arguments, _ := json.Marshal(step.Call.Args)
+		req.Messages = append(req.Messages,
+			openai.ChatCompletionMessage{
+				Role: openai.ChatMessageRoleAssistant,
+				ToolCalls: []openai.ToolCall{{
+					ID:       id,
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: step.Call.Name, Arguments: string(arguments)},
+				}},
+			},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, Content: step.Result, ToolCallID: id},
+		)
+	}
+	if ar.Final {
+		req.Messages = append(req.Messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "The step limit has been reached. Answer now from what you have found, and say what you would check next.",
+		})
+	} else {
+		for _, t := range o.tools {
+			if AgentTool(t.Function.Name) {
+				req.Tools = append(req.Tools, t)
+			}
+		}
+	}
+
+	resp, err := o.complete(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s API error: empty response", o.name)
+	}
+	msg := resp.Choices[0].Message
+	if len(msg.ToolCalls) == 0 || ar.Final {
+		return &Reply{Text: msg.Content}, nil
+	}
+	call, err := decodeToolCall(msg.ToolCalls[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Reply{ToolCall: call}, nil
+}
+
+func (f *Fallback) NextStep(ar *AgentRequest) (*Reply, error) {
+	var reply *Reply
+	err := f.call("agent", func(p Provider) error {
+		agent, ok := p.(Agent)
+		if !ok {
+			return fmt.Errorf("%s can't run agent mode", p.Name())
+		}
+		var err error
+		reply, err = agent.NextStep(ar)
+		return err
+	})
+	return reply, err
+}
+
+func (r *Redactor) NextStep(ar *AgentRequest) (*Reply, error) {
+	agent, ok := r.next.(Agent)
+	if !ok {
+		return nil, fmt.Errorf("%s can't run agent mode", r.next.Name())
+	}
+	x := newRedaction()
+	scrubbed := &AgentRequest{Query: r.scrub(x, ar.Query), Final: ar.Final}
+	for _, m := range ar.History {
+		scrubbed.History = append(scrubbed.History, Message{Role: m.Role, Content: r.scrub(x, m.Content)})
+	}
+	for _, s := range ar.Steps {
+		args := make(map[string]string, len(s.Call.Args))
+		for k, v := range s.Call.Args {
+			args[k] = r.scrub(x, v)
+		}
+		scrubbed.Steps = append(scrubbed.Steps, AgentStep{Call: &ToolCall{Name: s.Call.Name, Args: args}, Result: r.scrub(x, s.Result)})
+	}
+	r.record("agent", x)
+
+	reply, err := agent.NextStep(scrubbed)
+	if err != nil {
+		return nil, err
+	}
+	reply.Text = x.restore(reply.Text)
+	if reply.ToolCall != nil {
+		for k, v := range reply.ToolCall.Args {
+			reply.ToolCall.Args[k] = x.restore(v)
+		}
+	}
+	return reply, nil
+}
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Arguments the listing tools take to count their results instead of
+// listing them, as in "how many virtual servers are there per partition"
+const (
+	Aggregate = "aggregate"
+	GroupBy   = "group_by"
+)
+
+// Group keys for counting
+const (
+	GroupPartition = "partition"
+	GroupStatus    = "status"
+	GroupPool      = "pool"
+	GroupMonitor   = "monitor"
+	GroupLBMode    = "load_balancing"
+)
+
+// listGroupKeys are the keys each listing tool's counts can be grouped by
+var listGroupKeys = map[string][]string{
+	ToolListVirtualServers: {GroupPartition, GroupStatus, GroupPool},
+	ToolListPools:          {GroupPartition, GroupMonitor, GroupLBMode},
+	ToolListNodes:          {GroupPartition, GroupStatus},
+}
+
+// aggregateDefinitions describe a listing tool's counting arguments to the
+// model
+func aggregateDefinitions(tool string) map[string]jsonschema.Definition {
+	return map[string]jsonschema.Definition{
+		Aggregate: {Type: jsonschema.String, Enum: []string{"count"}, Description: "count when the user asks how many, rather than for the list"},
+		GroupBy:   {Type: jsonschema.String, Enum: listGroupKeys[tool], Description: "With count, count separately for each value of this, e.g. per partition"},
+	}
+}
+
+var (
+	// countWords asks how many, but not "sorted by connection count"
+	countWords = regexp.MustCompile(`(?i)\bhow\s+many\b|\b(count|number\s+of|total)\s+(?:the\s+|all\s+)?(?:virtual|vips?|vs|pools?|nodes?|backends?|servers?)\b`)
+	groupedBy  = regexp.MustCompile(`(?i)\b(?:per|for\s+each|in\s+each|each|grouped\s+by|broken\s+down\s+by|by)\s+(?:the\s+|their\s+)?([\w-]+(?:\s+[\w-]+){0,2})`)
+)
+
+// groupWords maps the word after "per" onto a group key
+var groupWords = map[string]string{
+	"partition": GroupPartition, "partitions": GroupPartition, "tenant": GroupPartition, "tenants": GroupPartition,
+	"status": GroupStatus, "state": GroupStatus, "availability": GroupStatus,
+	"pool": GroupPool, "pools": GroupPool,
+	"monitor": GroupMonitor, "monitors": GroupMonitor, "health monitor": GroupMonitor,
+	"load balancing": GroupLBMode, "load-balancing": GroupLBMode, "lb": GroupLBMode,
+	"method": GroupLBMode, "mode": GroupLBMode,
+}
+
+// ParseAggregate picks the counting a listing tool takes out of a query; it
+// is empty unless the query asks how many
+func ParseAggregate(tool, query string) map[string]string {
+	if _, ok := listGroupKeys[tool]; !ok || !countWords.MatchString(query) {
+		return map[string]string{}
+	}
+	out := map[string]string{Aggregate: "count"}
+	for _, m := range groupedBy.FindAllStringSubmatch(query, -1) {
+		if key := groupWord(strings.Fields(strings.ToLower(m[1]))); key != "" && slices.Contains(listGroupKeys[tool], key) {
+			out[GroupBy] = key
+			break
+		}
+	}
+	return out
+}
+
+// groupWord looks up the longest leading phrase of words that names a
+// group key, so "per load balancing mode" and "per partition please" work
+func groupWord(words []string) string {
+	for n := len(words); n > 0; n-- {
+		if key, ok := groupWords[strings.Join(words[:n], " ")]; ok {
+			return key
+		}
+	}
+	return ""
+}
+
+// GroupKeys returns the keys a listing tool's counts can be grouped by
+func GroupKeys(tool string) []string { return listGroupKeys[tool] }
@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// ansibleQuery matches requests for an Ansible playbook or tasks:
+	// "export vs_app1 as an Ansible playbook", "write a playbook that adds
+	// 10.1.20.13:80 to web_pool"
+	ansibleQuery = regexp.MustCompile(`(?i)\bansible\b|\bplaybooks?\b`)
+	// ansibleChange matches a described change rather than objects to
+	// export: "a playbook that drains web1", "ansible tasks to add a member"
+	ansibleChange = regexp.MustCompile(`(?i)\b(?:playbook|tasks?|ansible)\b.*?\b(?:that|to|which|for)\s+` +
+		`(?:add|remov|creat|delet|disabl|enabl|drain|chang|set|updat|put|tak|bring|attach|detach|replac|mov|deploy|configur|mak)\w*`)
+	// ansibleName is the object named: "export vs_app1 ...", "tasks for
+	// pool web_pool"
+	ansibleName = regexp.MustCompile(`(?i)\b(?:(?:export|convert|turn|migrate|dump)\s+(?:the\s+)?|(?:ansible|playbook|tasks?)\s+(?:for|of)\s+(?:the\s+)?)` +
+		`(?:(?:virtual\s+server|vs|vip|pool)\s+)?["'` + "`" + `]?([\w./-]+)`)
+	// ansibleFile is the playbook file to write: "to site.yml"
+	ansibleFile = regexp.MustCompile(`(?i)\b(?:to|into|in|as)\s+(?:(?:a\s+)?file\s+)?["'` + "`" + `]?([^\s"'` + "`" + `]+\.ya?ml)\b`)
+)
+
+// ansibleAll are the words naming no object in particular
+var ansibleAll = map[string]bool{
+	"ansible": true, "playbook": true, "playbooks": true, "tasks": true, "task": true, "a": true, "an": true,
+}
+
+// ParseAnsible recognises a request for an Ansible playbook: of the
+// virtual server or pool it names, of every virtual server, or making the
+// change it describes, with the file it names, if any
+func ParseAnsible(query string) (*ToolCall, bool) {
+	if !ansibleQuery.MatchString(query) {
+		return nil, false
+	}
+	args := map[string]string{}
+	if ansibleChange.MatchString(query) {
+		args["description"] = strings.TrimSpace(query)
+	} else if m := ansibleName.FindStringSubmatch(query); m != nil {
+		word := strings.ToLower(m[1])
+		if !terraformAll[word] && !ansibleAll[word] && !ansibleFile.MatchString(" to "+m[1]) {
+			args["name"] = m[1]
+		}
+	}
+	if m := ansibleFile.FindStringSubmatch(query); m != nil {
+		args["file"] = m[1]
+	}
+	return &ToolCall{Name: ToolGenerateAnsible, Args: args}, true
+}
@@ -0,0 +1,203 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultContextWindow is assumed for models not in contextWindows
+const defaultContextWindow = 8192
+
+// defaultReplyReserve is kept free for the answer when LLM_MAX_TOKENS is unset
+const defaultReplyReserve = 1024
+
+// keptTurns is how many recent question/answer pairs are never dropped;
+// they are truncated instead, since follow-ups refer to them
+const keptTurns = 2
+
+// messageOverhead is the per-message cost of the chat format (role, separators)
+const messageOverhead = 4
+
+// contextWindows maps model name prefixes to their context size in tokens;
+// the longest matching prefix wins
+var contextWindows = map[string]int{
+	"gpt-4o":        128000,
+	"gpt-4.1":       1000000,
+	"gpt-4-turbo":   128000,
+	"gpt-4-32k":     32768,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"o1":            128000,
+	"o3":            200000,
+	"o4-mini":       200000,
+	"llama3":        8192,
+	"llama3.1":      131072,
+	"llama3.2":      131072,
+	"mistral":       32768,
+	"qwen2.5":       32768,
+}
+
+// ContextWindow returns the context size of a model, in tokens
+func ContextWindow(model string) int {
+	best, window := 0, defaultContextWindow
+	for prefix, size := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best, window = len(prefix), size
+		}
+	}
+	return window
+}
+
+// EstimateTokens approximates the tokens in s. It counts about four
+// characters per token for prose and one per symbol for JSON and TCL,
+// erring high so the budget isn't exceeded.
+func EstimateTokens(s string) int {
+	letters, symbols := 0, 0
+	for _, r := range s {
+		switch {
+		case r == ' ' || r == '\n' || r == '\t':
+		case r < 128 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'):
+			letters++
+		default:
+			symbols++
+		}
+	}
+	return (letters+3)/4 + symbols
+}
+
+// estimateMessages counts the tokens of a conversation
+func estimateMessages(messages []openai.ChatCompletionMessage) int {
+	total := 0
+	for _, m := range messages {
+		total += messageOverhead + EstimateTokens(m.Content)
+	}
+	return total
+}
+
+// estimateTools counts the tokens the tool definitions add to each request
+func estimateTools(tools []openai.Tool) int {
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+	return EstimateTokens(string(data))
+}
+
+// fitContext trims messages to budget tokens. The system prompt and the
+// final user message are kept; the oldest turns go first and are replaced
+// by a one-line note of what was asked, then the longest messages
+// (documentation, device data, recent answers) are truncated.
+func fitContext(messages []openai.ChatCompletionMessage, budget int) []openai.ChatCompletionMessage {
+	total := estimateMessages(messages)
+	if total <= budget || len(messages) < 2 {
+		return messages
+	}
+	before := total
+
+	// Earlier turns sit between the system prompt and the final message;
+	// task instructions and documentation are system messages and stay
+	first, last := messages[0], messages[len(messages)-1]
+	middle := append([]openai.ChatCompletionMessage(nil), messages[1:len(messages)-1]...)
+	var dropped []string
+	for total > budget && countTurns(middle) > 2*keptTurns {
+		j := firstTurn(middle)
+		if middle[j].Role == openai.ChatMessageRoleUser {
+			dropped = append(dropped, firstLine(middle[j].Content))
+		}
+		total -= messageOverhead + EstimateTokens(middle[j].Content)
+		middle = append(middle[:j], middle[j+1:]...)
+	}
+
+	out := append([]openai.ChatCompletionMessage{first}, middle...)
+	if len(dropped) > 0 {
+		summary := openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "Earlier in this conversation, left out to fit the context window, the user asked: " + strings.Join(dropped, "; "),
+		}
+		total += messageOverhead + EstimateTokens(summary.Content)
+		out = append(out[:1], append([]openai.ChatCompletionMessage{summary}, out[1:]...)...)
+	}
+	out = append(out, last)
+
+	// Then cut the longest messages down, leaving the system prompt whole
+	for total > budget {
+		j := longest(out[1:]) + 1
+		excess := total - budget
+		cut := truncateTokens(out[j].Content, EstimateTokens(out[j].Content)-excess-32)
+		if cut == out[j].Content {
+			break
+		}
+		total += EstimateTokens(cut) - EstimateTokens(out[j].Content)
+		out[j].Content = cut
+	}
+
+	slog.Warn("Trimmed LLM request to fit the context window",
+		"budget", budget, "estimated_before", before, "estimated_after", total, "dropped_turns", len(dropped))
+	return out
+}
+
+// firstTurn returns the index of the oldest user or assistant message
+func firstTurn(messages []openai.ChatCompletionMessage) int {
+	for j, m := range messages {
+		if m.Role == openai.ChatMessageRoleUser || m.Role == openai.ChatMessageRoleAssistant {
+			return j
+		}
+	}
+	return -1
+}
+
+// countTurns counts the user and assistant messages
+func countTurns(messages []openai.ChatCompletionMessage) int {
+	n := 0
+	for _, m := range messages {
+		if m.Role == openai.ChatMessageRoleUser || m.Role == openai.ChatMessageRoleAssistant {
+			n++
+		}
+	}
+	return n
+}
+
+// longest returns the index of the message with the most tokens
+func longest(messages []openai.ChatCompletionMessage) int {
+	best, size := 0, -1
+	for j, m := range messages {
+		if n := EstimateTokens(m.Content); n > size {
+			best, size = j, n
+		}
+	}
+	return best
+}
+
+// truncateTokens shortens s to roughly tokens tokens, marking the cut
+func truncateTokens(s string, tokens int) string {
+	if tokens < 64 {
+		tokens = 64
+	}
+	if EstimateTokens(s) <= tokens {
+		return s
+	}
+	// Binary search on the rune count that fits
+	runes := []rune(s)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if EstimateTokens(string(runes[:mid])) <= tokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo]) + fmt.Sprintf("\n[... %d characters truncated to fit the context window]", len(runes)-lo)
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	if len(s) > 120 {
+		s = s[:120] + "..."
+	}
+	return s
+}
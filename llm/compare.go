@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of object compare_objects accepts
+const (
+	CompareVirtualServer = "virtual_server"
+	ComparePool          = "pool"
+	CompareNode          = "node"
+	CompareWAFPolicy     = "waf_policy"
+	CompareProfile       = "profile"
+)
+
+// CompareStyle is compare_objects' layout argument; CompareUnified shows
+// the objects as a unified diff rather than a table of differing fields
+const (
+	CompareStyle   = "style"
+	CompareUnified = "unified"
+)
+
+// CompareKinds lists the kinds compare_objects accepts
+var CompareKinds = []string{CompareVirtualServer, ComparePool, CompareNode, CompareWAFPolicy, CompareProfile}
+
+// compareQuery matches "compare A with B", "diff A and B" and the like
+var compareQuery = regexp.MustCompile(`(?i)^\s*(?:please\s+)?(?:compare|diff)\s+(.+?)\s+(?:with|and|to|against|vs\.?|versus)\s+(.+?)[\s?.!]*$`)
+
+// unifiedStyle asks for a comparison as a unified diff: "... as a unified
+// diff", "... in diff format", "... as a patch"
+var unifiedStyle = regexp.MustCompile(`(?i)\s+(?:as\s+an?\s+|in\s+(?:an?\s+)?)?(?:unified(?:\s+diff)?|diff\s+format|patch)(?:\s+format)?[\s?.!]*$`)
+
+// compareKindWords recognise what is being compared
+var compareKindWords = []struct {
+	pattern *regexp.Regexp
+	kind    string
+}{
+	{regexp.MustCompile(`(?i)\bprofiles?\b`), CompareProfile},
+	{regexp.MustCompile(`(?i)\b(virtual[\s-]*servers?|vips?|vs)\b`), CompareVirtualServer},
+	{regexp.MustCompile(`(?i)\bpools?\b`), ComparePool},
+	{regexp.MustCompile(`(?i)\bnodes?\b`), CompareNode},
+	{regexp.MustCompile(`(?i)\b(waf|asm|polic(y|ies))\b`), CompareWAFPolicy},
+}
+
+// profileTypeWord is the word before "profile", as in "http profiles"
+var profileTypeWord = regexp.MustCompile(`(?i)\b([a-z0-9-]+)\s+profiles?\b`)
+
+// ParseCompare recognises a comparison of two objects, e.g. "compare
+// vs_app1 with vs_app2" or "compare http profiles http and http_xff",
+// optionally "as a unified diff". The kind is left empty when the query
+// doesn't say.
+func ParseCompare(query string) (*ToolCall, bool) {
+	unified := unifiedStyle.MatchString(query)
+	query = unifiedStyle.ReplaceAllString(query, "")
+	m := compareQuery.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false
+	}
+	first, second := lastName(m[1]), lastName(m[2])
+	if first == "" || second == "" {
+		return nil, false
+	}
+	args := map[string]string{"first": first, "second": second}
+	if unified {
+		args[CompareStyle] = CompareUnified
+	}
+	for _, k := range compareKindWords {
+		if k.pattern.MatchString(query) {
+			args["kind"] = k.kind
+			break
+		}
+	}
+	if args["kind"] == CompareProfile {
+		if t := profileTypeWord.FindStringSubmatch(query); t != nil && !nameFillers[strings.ToLower(t[1])] {
+			args["profile_type"] = strings.ToLower(t[1])
+		}
+	}
+	return &ToolCall{Name: ToolCompare, Args: args}, true
+}
+
+// lastName returns the last word of a phrase that can be an object name,
+// skipping kind words: "pool web_pool" names web_pool
+func lastName(phrase string) string {
+	tokens := nameTokens.FindAllString(phrase, -1)
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if !quoted(tokens[i]) && isKindWord(tokens[i]) {
+			continue
+		}
+		if name, ok := cleanName(tokens[i]); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// isKindWord reports whether a token only says what kind of object follows
+func isKindWord(token string) bool {
+	for _, k := range compareKindWords {
+		if k.pattern.MatchString(token) && k.pattern.FindString(token) == token {
+			return true
+		}
+	}
+	return false
+}
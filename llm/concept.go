@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// conceptQueries match general questions about how BIG-IP works: "what is
+// SNAT automap", "difference between node and pool member", "how does
+// least connections work"
+var conceptQueries = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bdifferences?\s+between\b`),
+	regexp.MustCompile(`(?i)^\s*(?:what\s+(?:is|are)|what'?s|explain|define|describe)\s+\w+`),
+	regexp.MustCompile(`(?i)^\s*how\s+(?:does|do)\s+\w+`),
+	regexp.MustCompile(`(?i)^\s*when\s+(?:should|would|do)\s+(?:i|you|we)\s+use\b`),
+	regexp.MustCompile(`(?i)^\s*(?:tell\s+me\s+about|what\s+does\s+\S+\s+mean)\b`),
+}
+
+// ownConfig matches questions about the user's own device, which need its
+// data even when phrased like a concept question: "what is the status of
+// web_pool", "how do my pools look"
+var ownConfig = regexp.MustCompile(`(?i)\b(?:my|our|mine|ours|configured|current(?:ly)?|status|state|show|list|display|which|how\s+many|down|up|enabled|disabled|on\s+(?:the|this)\s+(?:device|box|big-?ip))\b`)
+
+// definiteObject matches "what are the pools?" and "explain this virtual
+// server", which ask about particular objects unless what follows is
+// "difference"
+var definiteObject = regexp.MustCompile(`(?i)^\s*(?:what\s+(?:is|are)|what'?s|explain|describe)\s+(?:the|all|any|this|that|these|those)\s+(\w+)`)
+
+// namedObject matches a question about an object named after its kind:
+// "explain iRule redirect", "describe pool web"; the name is captured
+var namedObject = regexp.MustCompile(`(?i)^\s*(?:what\s+(?:is|are)|what'?s|explain|define|describe|tell\s+me\s+about)\s+` +
+	`(?:irules?|pools?|polic(?:y|ies)|virtual(?:\s+servers?)?|vs|nodes?|profiles?)\s+["'` + "`" + `]?([\w/.~:-]+)`)
+
+// conceptWords follow an object's kind in concept questions, where they
+// aren't its name: "explain pool members", "how does a virtual server work"
+var conceptWords = map[string]bool{
+	"member": true, "members": true, "type": true, "types": true, "and": true, "or": true, "vs": true, "versus": true,
+	"work": true, "works": true, "mean": true, "means": true, "in": true, "on": true, "with": true, "for": true, "of": true,
+	"is": true, "are": true, "do": true, "does": true, "persistence": true, "events": true, "event": true, "commands": true,
+}
+
+// objectLike matches words that are object names or addresses rather than
+// concepts: vs_app1, /Common/web_pool, 10.1.10.80, web2:80
+var objectLike = regexp.MustCompile(`_|^/[\w.-]+/|^\d+\.\d+\.\d+\.\d+|:\d+$`)
+
+// ConceptQuestion reports whether a query asks how BIG-IP works in general,
+// so it can be answered from the model and documentation without reading
+// the device
+func ConceptQuestion(query string) bool {
+	if ownConfig.MatchString(query) {
+		return false
+	}
+	if m := definiteObject.FindStringSubmatch(query); m != nil && !strings.HasPrefix(strings.ToLower(m[1]), "difference") {
+		return false
+	}
+	if m := namedObject.FindStringSubmatch(query); m != nil && !conceptWords[strings.ToLower(m[1])] {
+		return false
+	}
+	for _, word := range strings.Fields(query) {
+		if objectLike.MatchString(strings.Trim(word, ".,!?;:'\"()")) {
+			return false
+		}
+	}
+	for _, q := range conceptQueries {
+		if q.MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
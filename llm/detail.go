@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"regexp"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Detail is the argument listing tools take to show every field of each
+// object rather than one line per object
+const Detail = "detail"
+
+// detailTools are the listings with a compact and a detailed layout
+var detailTools = map[string]bool{
+	ToolListVirtualServers: true,
+	ToolListPools:          true,
+	ToolListNodes:          true,
+	ToolListWAFPolicies:    true,
+}
+
+// detailWords ask for the full layout: "in detail", "with all details",
+// "verbose"
+var detailWords = regexp.MustCompile(`(?i)\b(?:in\s+(?:full\s+|more\s+)?detail|detailed|in\s+full|(?:with\s+)?(?:all\s+(?:the\s+|their\s+)?|full\s+)details?|verbose(?:ly)?|every\s+field)\b`)
+
+// detailDefinition describes Detail to the model
+var detailDefinition = jsonschema.Definition{Type: jsonschema.String, Enum: []string{"true"},
+	Description: "true when the user asks for the list in detail, with every field; leave out for one line per object"}
+
+// ParseDetail picks whether a query asks a listing tool for every field of
+// each object; it is empty otherwise
+func ParseDetail(tool, query string) map[string]string {
+	if !detailTools[tool] || !detailWords.MatchString(query) {
+		return map[string]string{}
+	}
+	return map[string]string{Detail: "true"}
+}
@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// fallbackCooldown is how long queries go straight to the fallback after
+// the primary was unavailable, so each one doesn't wait out the primary's
+// retries first
+const fallbackCooldown = time.Minute
+
+// Fallback sends requests to a primary provider and switches to a secondary
+// one while the primary is rate limited, out of quota or down. Errors that
+// aren't about availability, such as a rejected request, are returned as is.
+type Fallback struct {
+	primary   Provider
+	secondary Provider
+
+	mu        sync.Mutex
+	skipUntil time.Time
+}
+
+var _ Provider = (*Fallback)(nil)
+
+// NewFallback chains primary and secondary
+func NewFallback(primary, secondary Provider) *Fallback {
+	return &Fallback{primary: primary, secondary: secondary}
+}
+
+// Primary returns the provider tried first
+func (f *Fallback) Primary() Provider { return f.primary }
+
+// Secondary returns the provider used when the primary is unavailable
+func (f *Fallback) Secondary() Provider { return f.secondary }
+
+func (f *Fallback) Name() string {
+	return fmt.Sprintf("%s (fallback: %s)", f.primary.Name(), f.secondary.Name())
+}
+
+// call runs fn against the primary unless it recently failed, and against
+// the secondary when the primary is unavailable
+func (f *Fallback) call(operation string, fn func(p Provider) error) error {
+	f.mu.Lock()
+	skip := time.Now().Before(f.skipUntil)
+	f.mu.Unlock()
+
+	if !skip {
+		err := fn(f.primary)
+		var unavailable *UnavailableError
+		if !errors.As(err, &unavailable) {
+			return err
+		}
+		slog.Warn("Primary LLM provider unavailable; switching to fallback",
+			"operation", operation, "primary", f.primary.Name(), "fallback", f.secondary.Name(), "cooldown", fallbackCooldown, "err", err)
+		f.mu.Lock()
+		f.skipUntil = time.Now().Add(fallbackCooldown)
+		f.mu.Unlock()
+	}
+
+	slog.Debug("Using fallback LLM provider", "operation", operation, "provider", f.secondary.Name())
+	return fn(f.secondary)
+}
+
+func (f *Fallback) ProcessPrompt(prompt string) (string, error) {
+	var text string
+	err := f.call("prompt", func(p Provider) (err error) {
+		text, err = p.ProcessPrompt(prompt)
+		return err
+	})
+	return text, err
+}
+
+func (f *Fallback) ProcessWithTools(history []Message, query string) (*Reply, error) {
+	var reply *Reply
+	err := f.call("tools", func(p Provider) (err error) {
+		reply, err = p.ProcessWithTools(history, query)
+		return err
+	})
+	return reply, err
+}
+
+func (f *Fallback) RunTask(task, input string) (string, error) {
+	var text string
+	err := f.call(task, func(p Provider) (err error) {
+		text, err = p.RunTask(task, input)
+		return err
+	})
+	return text, err
+}
+
+// Stream falls back only if the primary fails before sending anything, so
+// the caller never sees two partial answers
+func (f *Fallback) Stream(prompt string, onDelta func(string)) (string, error) {
+	var text string
+	err := f.call("stream", func(p Provider) (err error) {
+		text, err = p.Stream(prompt, onDelta)
+		if err != nil && text != "" {
+			return fmt.Errorf("stream interrupted: %v", err)
+		}
+		return err
+	})
+	return text, err
+}
+
+// Embed always uses the primary: vectors from different embedding models
+// can't be compared, so the fallback can't stand in for a cached index
+func (f *Fallback) Embed(texts []string) ([][]float32, error) {
+	embedder, ok := f.primary.(interface {
+		Embed(texts []string) ([][]float32, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("%s does not support embeddings", f.primary.Name())
+	}
+	return embedder.Embed(texts)
+}
+
+// Ping succeeds if either provider is reachable
+func (f *Fallback) Ping() error {
+	err := f.primary.Ping()
+	if err == nil {
+		return nil
+	}
+	if fallbackErr := f.secondary.Ping(); fallbackErr != nil {
+		return fmt.Errorf("%v; fallback: %v", err, fallbackErr)
+	}
+	return nil
+}
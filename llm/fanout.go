@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// allDevices matches the words that ask every configured BIG-IP: "across
+	// all devices", "on every BIG-IP"
+	allDevices = regexp.MustCompile(`(?i)\s*\b(?:across|on|in|from|for)\s+(?:all|every|each)\s+(?:of\s+)?(?:the\s+|my\s+|our\s+)?(?:devices?|big-?ips?|boxes|units)\b|\s*\bacross\s+(?:the\s+|my\s+|our\s+)?(?:devices|big-?ips|boxes|units)\b`)
+	// whichDevice matches "which device has pool payments_pool"
+	whichDevice = regexp.MustCompile(`(?i)^\s*(?:which|what)\s+(?:devices?|big-?ips?|boxes|units)\s+(?:has|have|hosts?|contains?|holds?|runs?|owns?)\s+(?:got\s+)?(.+?)\s*[?.!]?\s*$`)
+	// locateObject picks the kind and name out of what is looked for:
+	// "pool payments_pool", "a virtual server called vs_app1", "web_pool"
+	locateObject = regexp.MustCompile(`(?i)^(?:the\s+|a\s+|an\s+)?(?:(virtual[\s-]*servers?|vips?|vs|pools?|nodes?|waf\s+polic(?:y|ies)|asm\s+polic(?:y|ies)|polic(?:y|ies)|irules?)\s+)?(?:named\s+|called\s+)?["'` + "`" + `]?([\w.*/:-]+?)["'` + "`" + `]?$`)
+)
+
+// Kinds of object a device can be searched for; an empty kind means any
+const (
+	LocateVirtualServer = "virtual server"
+	LocatePool          = "pool"
+	LocateNode          = "node"
+	LocateWAFPolicy     = "WAF policy"
+	LocateIRule         = "iRule"
+)
+
+// FanOut is a request to run on every configured BIG-IP. Either Call is
+// the read to run on each device, or Name (and Kind) is an object to look
+// for on each.
+type FanOut struct {
+	Text string
+	Call *ToolCall
+	Kind string
+	Name string
+}
+
+// ParseFanOut recognises requests for every configured BIG-IP: a read
+// with "across all devices" ("show virtual servers named vs_app1 across
+// all devices"), or a question about where an object is ("which device
+// has pool payments_pool"). Only reads can be fanned out.
+func ParseFanOut(query string) (*FanOut, bool) {
+	if m := whichDevice.FindStringSubmatch(query); m != nil {
+		o := locateObject.FindStringSubmatch(strings.TrimSpace(m[1]))
+		if o == nil {
+			return nil, false
+		}
+		return &FanOut{Text: query, Kind: locateKind(o[1]), Name: o[2]}, true
+	}
+	if !allDevices.MatchString(query) {
+		return nil, false
+	}
+	text := strings.TrimSpace(allDevices.ReplaceAllString(query, ""))
+	call := route(text)
+	if call == nil || ToolRisk(call.Name) != RiskReadOnly {
+		return &FanOut{Text: text}, true
+	}
+	return &FanOut{Text: text, Call: call}, true
+}
+
+// locateKind maps the word before a name onto a kind to look for
+func locateKind(word string) string {
+	word = strings.ToLower(word)
+	switch {
+	case word == "":
+		return ""
+	case strings.HasPrefix(word, "virtual"), strings.HasPrefix(word, "vip"), word == "vs":
+		return LocateVirtualServer
+	case strings.HasPrefix(word, "pool"):
+		return LocatePool
+	case strings.HasPrefix(word, "node"):
+		return LocateNode
+	case strings.HasPrefix(word, "irule"):
+		return LocateIRule
+	}
+	return LocateWAFPolicy
+}
@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Qualifiers the listing tools take to narrow their results, as in "only
+// disabled virtual servers", "pools without members" or "nodes in
+// 10.2.0.0/16"
+const (
+	FilterStatus  = "status"
+	FilterMembers = "members"
+	FilterAddress = "address"
+	// FilterMemberCount compares a pool's member count, e.g. "<2" or ">=3"
+	FilterMemberCount = "member_count"
+	// FilterName is a name or /Partition/name, with * matching anything
+	FilterName = "name"
+	// FilterPartition is the partition objects are in, e.g. Common
+	FilterPartition = "partition"
+)
+
+// FilterStatuses are the values of the status qualifier: enabled and
+// disabled are the administrative state, up and down the monitored one
+var FilterStatuses = []string{"enabled", "disabled", "up", "down"}
+
+// listFilters are the qualifiers each listing tool takes
+var listFilters = map[string][]string{
+	ToolListVirtualServers: {FilterStatus, FilterAddress, FilterName, FilterPartition},
+	ToolListPools:          {FilterMembers, FilterMemberCount, FilterName, FilterPartition},
+	ToolListNodes:          {FilterStatus, FilterAddress, FilterName, FilterPartition},
+}
+
+// filterDefinitions describe each qualifier to the model
+var filterDefinitions = map[string]jsonschema.Definition{
+	FilterStatus:      {Type: jsonschema.String, Enum: FilterStatuses, Description: "Only objects in this state, when the user asks for e.g. only disabled or down ones"},
+	FilterMembers:     {Type: jsonschema.String, Enum: []string{"with", "without"}, Description: "Only pools with or without members, when the user asks"},
+	FilterAddress:     {Type: jsonschema.String, Description: "Only objects whose address is in this network (CIDR, e.g. 10.2.0.0/16) or equals this IP address"},
+	FilterMemberCount: {Type: jsonschema.String, Description: "Only pools whose number of members compares like this: <, <=, =, >= or > and a number, e.g. <2 for fewer than 2 members"},
+	FilterName:        {Type: jsonschema.String, Description: "Only objects with this name or /Partition/name, when the user asks for ones named something; * matches any characters, e.g. vs_app*"},
+	FilterPartition:   {Type: jsonschema.String, Description: "Only objects in this partition (tenant), when the user asks for e.g. the ones in Common"},
+}
+
+// listParams is the schema for a listing tool's optional qualifiers,
+// ordering, counting and layout
+func listParams(tool string) jsonschema.Definition {
+	props := sortDefinitions(tool)
+	for key, d := range aggregateDefinitions(tool) {
+		props[key] = d
+	}
+	for _, f := range listFilters[tool] {
+		props[f] = filterDefinitions[f]
+	}
+	props[Detail] = detailDefinition
+	return jsonschema.Definition{Type: jsonschema.Object, Properties: props}
+}
+
+var (
+	statusWord  = regexp.MustCompile(`(?i)\b(disabled|enabled|offline|down|unavailable|online|up|available)\b`)
+	noMembers   = regexp.MustCompile(`(?i)\b(without|with\s+no|having\s+no|no|zero)\s+(pool\s+)?members\b|\bempty\s+pools?\b`)
+	withMembers = regexp.MustCompile(`(?i)\b(with|having|that\s+have)\s+(pool\s+)?members\b`)
+	namedWord   = regexp.MustCompile(`(?i)\b(?:named|called)\s+["'` + "`" + `]?([\w.*/-]+)`)
+	// partitionWord matches "in partition TenantA", "in the TenantA
+	// partition" and "in Common"
+	partitionWord = regexp.MustCompile(`(?i)\b(?:in|from|under)\s+(?:the\s+)?(?:(?:partition|tenant)\s+/?([\w.~-]+)|/?([\w.~-]+)\s+(?:partition|tenant)\b|/?(common)\b)`)
+	memberCount   = regexp.MustCompile(`(?i)\b(fewer\s+than|less\s+than|under|more\s+than|over|greater\s+than|at\s+least|at\s+most|no\s+more\s+than|no\s+fewer\s+than|exactly|with|having|have|has)\s+(\d+)\s+(or\s+(?:more|fewer|less)\s+)?(?:pool\s+)?members?\b`)
+)
+
+// memberComparisons maps the words before a member count onto an operator
+var memberComparisons = map[string]string{
+	"fewer than": "<", "less than": "<", "under": "<",
+	"more than": ">", "over": ">", "greater than": ">",
+	"at least": ">=", "no fewer than": ">=",
+	"at most": "<=", "no more than": "<=",
+}
+
+// statusAliases maps the words people use onto FilterStatuses
+var statusAliases = map[string]string{
+	"offline": "down", "unavailable": "down",
+	"online": "up", "available": "up",
+}
+
+// ParseFilters picks the qualifiers a listing tool takes out of a query;
+// it is empty when the query asks for everything or the tool takes none
+func ParseFilters(tool, query string) map[string]string {
+	out := map[string]string{}
+	for _, f := range listFilters[tool] {
+		switch f {
+		case FilterStatus:
+			if m := statusWord.FindStringSubmatch(query); m != nil {
+				status := strings.ToLower(m[1])
+				if alias, ok := statusAliases[status]; ok {
+					status = alias
+				}
+				out[f] = status
+			}
+		case FilterMemberCount:
+			if m := memberCount.FindStringSubmatch(query); m != nil {
+				words := strings.Join(strings.Fields(strings.ToLower(m[1])), " ")
+				op, ok := memberComparisons[words]
+				if !ok {
+					// "with 2 members", "with 2 or more members"
+					op = "="
+				}
+				switch or := strings.ToLower(m[3]); {
+				case strings.Contains(or, "more"):
+					op = ">="
+				case or != "":
+					op = "<="
+				}
+				out[f] = op + m[2]
+			}
+		case FilterMembers:
+			if noMembers.MatchString(query) {
+				out[f] = "without"
+			} else if withMembers.MatchString(query) {
+				out[f] = "with"
+			}
+		case FilterAddress:
+			if network := findNetwork(query); network != "" {
+				out[f] = network
+			}
+		case FilterName:
+			if m := namedWord.FindStringSubmatch(query); m != nil {
+				out[f] = m[1]
+			}
+		case FilterPartition:
+			if partition := ParsePartition(query); partition != "" {
+				out[f] = partition
+			}
+		}
+	}
+	return out
+}
+
+// notPartitions are words that can follow "in the" without naming a
+// partition: "in all partitions", "in every tenant"
+var notPartitions = map[string]bool{"all": true, "every": true, "each": true, "any": true, "which": true, "what": true, "this": true, "that": true}
+
+// ParsePartition returns the partition a query asks about, as in "show
+// pools in Common" or "in partition TenantA"; it is "" when none is named
+func ParsePartition(query string) string {
+	m := partitionWord.FindStringSubmatch(query)
+	if m == nil {
+		return ""
+	}
+	switch {
+	case m[3] != "":
+		return "Common"
+	case m[1] != "" && !notPartitions[strings.ToLower(m[1])]:
+		return m[1]
+	case m[2] != "" && !notPartitions[strings.ToLower(m[2])]:
+		return m[2]
+	}
+	return ""
+}
+
+// findNetwork returns the first CIDR network or IP address in a query
+func findNetwork(query string) string {
+	for _, word := range strings.Fields(query) {
+		word = strings.Trim(word, ".,!?;:()'\"")
+		if _, _, err := net.ParseCIDR(word); err == nil {
+			return word
+		}
+		if net.ParseIP(word) != nil {
+			return word
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// gtmQuery matches requests about GTM topology steering: "show the GTM
+	// topology records", "list the GTM regions", "why do EU clients resolve
+	// to DC2?", "which data center do clients in Germany go to?"
+	gtmQuery = regexp.MustCompile(`(?i)\btopology\b|\b(?:gtm|dns)\s+regions?\b|` +
+		`\b(?:clients?|users?|visitors?|resolvers?|queries|requests)\b.*\b(?:resolve[sd]?|resolving|steered)\b|` +
+		`\b(?:why|where)\s+(?:do|does)\s+\S+\s+(?:resolve|get\s+resolved)\b|` +
+		`\b(?:clients?|users?|visitors?)\b.*\b(?:go(?:es)?|sent|routed|directed)\s+to\s+(?:the\s+)?(?:dc\d*|data\s*cent(?:er|re)s?)\b|` +
+		`\bwhich\s+(?:dc|data\s*cent(?:er|re)|site)\b.*\b(?:clients?|users?|visitors?)\b`)
+	// gtmLocation finds where the clients are: "EU clients", "clients in
+	// Germany", "why does 10.1.2.3 resolve"
+	gtmLocation = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(?:clients?|users?|visitors?|resolvers?|queries|requests)\s+(?:in|from|at)\s+(?:the\s+)?(.+?)(?:\s+(?:resolve[sd]?|resolving|get|go(?:es)?|are|land|end|use|sent|routed|steered)\b|\s*[?.!]*$)`),
+		regexp.MustCompile(`(?i)\b(?:why|where|do|does|(?:which|what)\s+\w+(?:\s+\w+)?)\s+(?:do|does|are|is|would|will)?\s*(?:the\s+)?(.+?)\s+(?:clients?|users?|visitors?|resolvers?|queries|requests)\b`),
+		regexp.MustCompile(`(?i)\b(?:why|where)\s+(?:do|does)\s+(\S+)\s+(?:resolve|get\s+resolved)\b`),
+	}
+)
+
+// ParseGTMTopology recognises a request for the GTM topology records and
+// regions, with where the clients it asks about are when it names a place
+func ParseGTMTopology(query string) (*ToolCall, bool) {
+	if !gtmQuery.MatchString(query) {
+		return nil, false
+	}
+	args := map[string]string{}
+	for _, pattern := range gtmLocation {
+		m := pattern.FindStringSubmatch(query)
+		if m == nil {
+			continue
+		}
+		location := strings.Trim(strings.TrimSpace(m[1]), "\"'`")
+		switch strings.ToLower(location) {
+		case "", "my", "our", "all", "the", "some", "these", "those", "many", "most", "dns", "gtm", "do", "does":
+			continue
+		}
+		args["location"] = location
+		break
+	}
+	return &ToolCall{Name: ToolGTMTopology, Args: args}, true
+}
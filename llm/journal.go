@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// journalQuery matches questions about the changes chatf5 itself made:
+	// "what did this tool change last week?", "show the change journal",
+	// "changes made by chatf5 since yesterday". "what changed since this
+	// morning" compares snapshots of the device instead.
+	journalQuery = regexp.MustCompile(`(?i)\b(?:change\s+)?journal\b|` +
+		`\bwhat\s+(?:did|have|has)\s+(?:you|this\s+tool|the\s+tool|chatf5|f5chat|the\s+(?:bot|assistant)|user\s+\S+)\s+(?:changed?|deployed?|uploaded?|done)\b|` +
+		`\bchanges?\s+(?:were\s+)?(?:made\s+)?(?:by|through|with|from|via)\s+(?:you|this\s+tool|the\s+tool|chatf5|f5chat|the\s+(?:bot|assistant))\b`)
+	// journalSince is the period asked about: "last week", "since
+	// yesterday", "in the past 3 days", "today"
+	journalSince = regexp.MustCompile(`(?i)\bsince\s+(.+?)[\s?.!]*$|` +
+		`\b((?:in\s+)?(?:the\s+)?(?:last|past)\s+(?:\d+\s+)?(?:minute|hour|day|week|month)s?|today|yesterday|this\s+week)\b`)
+	// journalUser names whose changes: "by user jsmith", "user jsmith"
+	journalUser = regexp.MustCompile(`(?i)\buser\s+["'` + "`" + `]?([\w.@-]+)`)
+)
+
+// ParseChangeJournal recognises a question about the changes chatf5 made,
+// with the period and the user it asks about when it names them
+func ParseChangeJournal(query string) (*ToolCall, bool) {
+	if !journalQuery.MatchString(query) {
+		return nil, false
+	}
+	args := map[string]string{}
+	if m := journalSince.FindStringSubmatch(query); m != nil {
+		since := m[1] + m[2]
+		if len(since) > 3 && strings.EqualFold(since[:3], "in ") {
+			since = since[3:]
+		}
+		args["since"] = since
+	}
+	if m := journalUser.FindStringSubmatch(query); m != nil {
+		args["user"] = m[1]
+	}
+	return &ToolCall{Name: ToolChangeJournal, Args: args}, true
+}
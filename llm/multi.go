@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// conjunction splits a request into the clauses it joins: "show virtual
+// servers and their pools, plus any WAF policies attached"
+var conjunction = regexp.MustCompile(`(?i)\s*(?:;|,\s*(?:and|plus)?|\band\s+also\b|\band\b|\bplus\b|\bas\s+well\s+as\b|\balong\s+with\b)\s*`)
+
+// multiTools are the operations a combined request can be made of: reads
+// that need nothing from each other
+var multiTools = map[string]bool{
+	ToolListVirtualServers: true,
+	ToolListPools:          true,
+	ToolGetPool:            true,
+	ToolListNodes:          true,
+	ToolListWAFPolicies:    true,
+	ToolGetWAFPolicy:       true,
+}
+
+// Clause is one part of a combined request and the operation answering it
+type Clause struct {
+	Text string
+	Call *ToolCall
+}
+
+// ParseMultiple splits a request for several kinds of object into one
+// operation per clause, in the order they were asked for. It reports false
+// unless at least two different reads were asked for, so "virtual servers
+// that use web_pool and are enabled" stays a single filtered listing.
+func ParseMultiple(query string) ([]Clause, bool) {
+	if _, ok := ParseCompare(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseAddToFirewallList(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseFirewallList(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseGTMTopology(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseTroubleshoot(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseOWASPCompliance(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseSecurityPosture(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseWAFViolations(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseChangeJournal(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseHealthSummary(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseExportTerraform(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseAnsible(query); ok {
+		return nil, false
+	}
+	if _, ok := ParseQkview(query); ok {
+		return nil, false
+	}
+	var clauses []Clause
+	seen := make(map[string]bool)
+	for _, text := range conjunction.Split(query, -1) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		call := route(text)
+		if call == nil {
+			// "their members", "are enabled": part of the clause before
+			if len(clauses) > 0 {
+				clauses[len(clauses)-1].Text += " and " + text
+			}
+			continue
+		}
+		if !multiTools[call.Name] {
+			return nil, false
+		}
+		key := call.Name + " " + call.Arg("name")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		clauses = append(clauses, Clause{Text: text, Call: call})
+	}
+	if len(clauses) < 2 {
+		return nil, false
+	}
+	return clauses, true
+}
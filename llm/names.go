@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// nameTokens splits a query into words, keeping quoted names whole so
+// "show policy 'my policy'" names "my policy". A quote inside a word, as in
+// o'brien, doesn't start a quoted name.
+var nameTokens = regexp.MustCompile("\"[^\"]+\"|'[^']+'|`[^`]+`|\\S+")
+
+// partitionPath matches /Partition/name and /Partition/folder/name paths
+var partitionPath = regexp.MustCompile(`^/[\w.~-]+(/[\w.~-]+)+$`)
+
+// objectName matches a plain object name: letters, digits, _ . - ~ and an
+// apostrophe within the word
+var objectName = regexp.MustCompile(`^[\w.~-][\w.~'-]*$`)
+
+// nameFillers may sit between a noun and the name it introduces, as in
+// "policy details VS_WAF" or "pool named web_pool"
+var nameFillers = map[string]bool{
+	"named": true, "called": true, "the": true, "a": true, "an": true,
+	"details": true, "detail": true, "info": true, "information": true,
+	"config": true, "configuration": true, "settings": true, "status": true,
+	"health": true, "stats": true, "members": true, "member": true,
+	"name": true, "id": true,
+}
+
+// nameStops end the search: "pool members and their status", "policy on
+// VS vs_app1" and "policies that ..." name no object after the noun
+var nameStops = map[string]bool{
+	"and": true, "with": true, "for": true, "of": true, "on": true, "in": true,
+	"is": true, "are": true, "list": true, "names": true, "applied": true,
+	"that": true, "which": true, "to": true, "from": true, "by": true,
+	"attached": true, "assigned": true, "used": true, "using": true,
+	"without": true, "only": true,
+}
+
+// ExtractName finds the object a query names after one of nouns (e.g.
+// "pool"), as in "show pool web_pool", "details for policy 'my policy' on
+// VS vs_app1" or "get policy /Tenant_A/app/waf.v2". A quoted name or
+// /Partition/name path just before the noun counts too. It returns "" when
+// the query names no object.
+func ExtractName(query string, nouns ...string) string {
+	tokens := nameTokens.FindAllString(query, -1)
+	for i, t := range tokens {
+		if !isNoun(t, nouns) {
+			continue
+		}
+		for _, next := range tokens[i+1:] {
+			word := strings.ToLower(strings.TrimRight(next, ".,!?;:"))
+			if nameFillers[word] {
+				continue
+			}
+			if nameStops[word] {
+				break
+			}
+			if name, ok := cleanName(next); ok {
+				return name
+			}
+			break
+		}
+		// "the /Common/web_pool pool", "the 'web pool' pool"
+		if i > 0 {
+			if prev := tokens[i-1]; quoted(prev) || partitionPath.MatchString(prev) {
+				if name, ok := cleanName(prev); ok {
+					return name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// isNoun reports whether a token is one of nouns, ignoring case and
+// trailing punctuation
+func isNoun(token string, nouns []string) bool {
+	token = strings.TrimRight(token, ".,!?;:")
+	for _, n := range nouns {
+		if strings.EqualFold(token, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// quoted reports whether a token is a quoted name
+func quoted(token string) bool {
+	return len(token) >= 2 && strings.ContainsRune("\"'`", rune(token[0])) && token[len(token)-1] == token[0]
+}
+
+// cleanName strips quotes and sentence punctuation from a token and
+// reports whether what remains can be an object name
+func cleanName(token string) (string, bool) {
+	if quoted(token) {
+		name := strings.TrimSpace(token[1 : len(token)-1])
+		return name, name != ""
+	}
+	name := strings.TrimSuffix(strings.TrimRight(token, ".,!?;:"), "'s")
+	if partitionPath.MatchString(name) || objectName.MatchString(name) {
+		return name, name != ""
+	}
+	return "", false
+}
@@ -0,0 +1,29 @@
+package llm
+
+import (
+	"f5chat/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+func init() {
+	Register("ollama", func(cfg *config.Config) (Provider, error) { return NewOllamaClient(cfg) })
+}
+
+// ollamaBaseURL is where Ollama listens unless OLLAMA_BASE_URL says otherwise
+const ollamaBaseURL = "http://localhost:11434/v1"
+
+// NewOllamaClient talks to a local Ollama server through its
+// OpenAI-compatible API. Ollama ignores the API key, but the client must
+// send one. Tool selection needs a model with tool support, such as llama3.1.
+func NewOllamaClient(cfg *config.Config) (*OpenAIClient, error) {
+	clientConfig := openai.DefaultConfig("ollama")
+	clientConfig.BaseURL = cfg.OllamaBaseURL
+	if clientConfig.BaseURL == "" {
+		clientConfig.BaseURL = ollamaBaseURL
+	}
+	model := cfg.OllamaModel
+	if model == "" {
+		model = "llama3.1"
+	}
+	return newCompatibleClient(cfg, clientConfig, "Ollama", model, cfg.OllamaEmbeddingModel)
+}
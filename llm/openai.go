@@ -2,77 +2,265 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
 
 	"github.com/sashabaranov/go-openai"
 	"f5chat/config"
+	"f5chat/prompt"
+	"f5chat/retry"
+	"f5chat/telemetry"
 )
 
+func init() {
+	Register("openai", func(cfg *config.Config) (Provider, error) { return NewOpenAIClient(cfg) })
+	Register("azure", func(cfg *config.Config) (Provider, error) {
+		if cfg.AzureOpenAIEndpoint == "" {
+			return nil, fmt.Errorf("the azure LLM provider requires AZURE_OPENAI_ENDPOINT")
+		}
+		return NewOpenAIClient(cfg)
+	})
+}
+
+// OpenAIClient is the Provider for OpenAI, Azure OpenAI and OpenAI-compatible gateways
 type OpenAIClient struct {
 	client *openai.Client
+	name   string
+
+	model          string
+	temperature    float32
+	maxTokens      int
+	embeddingModel string
+	// contextWindow is the model's context size; requests are trimmed to
+	// fit it alongside the tool definitions and the reply
+	contextWindow int
+	toolTokens    int
+	// spend enforces request and token limits; nil for local models
+	spend *Spend
+
+	prompts *prompt.Set
+	tools   []openai.Tool
 }
 
+var _ Provider = (*OpenAIClient)(nil)
+
 func NewOpenAIClient(cfg *config.Config) (*OpenAIClient, error) {
-	client := openai.NewClient(cfg.OpenAIKey)
-	return &OpenAIClient{client: client}, nil
+	clientConfig := openai.DefaultConfig(cfg.OpenAIKey)
+	name := "OpenAI"
+	if cfg.AzureOpenAIEndpoint != "" {
+		clientConfig, name = azureConfig(cfg), "Azure OpenAI"
+	} else if cfg.OpenAIBaseURL != "" {
+		// Allows OpenAI-compatible gateways and the e2e fake LLM
+		clientConfig.BaseURL = cfg.OpenAIBaseURL
+	}
+	return newCompatibleClient(cfg, clientConfig, name, cfg.LLMModel, cfg.LLMEmbeddingModel)
 }
 
-func (o *OpenAIClient) ProcessPrompt(prompt string) (string, error) {
-	resp, err := o.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT3Dot5Turbo,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: systemPrompt,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			Temperature: 0.7,
-		},
-	)
+// newCompatibleClient builds a client for any endpoint speaking the OpenAI
+// API, with the configured retry policy, prompts and sampling settings
+func newCompatibleClient(cfg *config.Config, clientConfig openai.ClientConfig, name, model, embeddingModel string) (*OpenAIClient, error) {
+	clientConfig.HTTPClient = &retryingDoer{
+		client:   &http.Client{Transport: telemetry.Transport(http.DefaultTransport, name)},
+		policy:   retry.LLMFromConfig(cfg),
+		provider: name,
+	}
+	client := openai.NewClientWithConfig(clientConfig)
 
+	prompts, err := prompt.Load(cfg.PromptDir)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI API error: %v", err)
+		return nil, err
 	}
 
+	if model == "" {
+		model = openai.GPT3Dot5Turbo
+	}
+	contextWindow := cfg.LLMContextWindow
+	if contextWindow <= 0 {
+		contextWindow = ContextWindow(model)
+	}
+	tools := buildTools(prompts)
+	return &OpenAIClient{
+		client:      client,
+		name:        name,
+		model:       model,
+		temperature: cfg.LLMTemperature,
+		maxTokens:   cfg.LLMMaxTokens,
+
+		embeddingModel: embeddingModel,
+		contextWindow:  contextWindow,
+		toolTokens:     estimateTools(tools),
+
+		prompts: prompts,
+		tools:   tools,
+	}, nil
+}
+
+// Name reports which service requests go to (OpenAI, Azure OpenAI or Ollama)
+func (o *OpenAIClient) Name() string { return o.name }
+
+// azureConfig targets an Azure OpenAI resource. Azure addresses models by
+// deployment name, so every request is mapped onto the configured deployment.
+func azureConfig(cfg *config.Config) openai.ClientConfig {
+	clientConfig := openai.DefaultAzureConfig(cfg.OpenAIKey, strings.TrimSuffix(cfg.AzureOpenAIEndpoint, "/"))
+	if cfg.AzureOpenAIAPIVersion != "" {
+		clientConfig.APIVersion = cfg.AzureOpenAIAPIVersion
+	}
+	deployment, embeddingDeployment := cfg.AzureOpenAIDeployment, cfg.AzureOpenAIEmbeddingDeployment
+	embeddingModel := cfg.LLMEmbeddingModel
+	clientConfig.AzureModelMapperFunc = func(model string) string {
+		if model == embeddingModel && embeddingDeployment != "" {
+			return embeddingDeployment
+		}
+		return deployment
+	}
+	return clientConfig
+}
+
+// newRequest builds a chat completion request for the user content, after
+// any earlier turns, with the configured model, temperature and token limit.
+// The messages are trimmed to fit the context window.
+func (o *OpenAIClient) newRequest(history []Message, content string) openai.ChatCompletionRequest {
+	temperature := o.temperature
+	if temperature == 0 {
+		// go-openai omits a zero temperature, which the API treats as 1
+		temperature = math.SmallestNonzeroFloat32
+	}
+	messages := []openai.ChatCompletionMessage{{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: o.prompts.System(),
+	}}
+	for _, m := range history {
+		messages = append(messages, openai.ChatCompletionMessage{Role: m.Role, Content: m.Content})
+	}
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: content,
+	})
+	reserve := o.maxTokens
+	if reserve <= 0 {
+		reserve = defaultReplyReserve
+	}
+	messages = fitContext(messages, o.contextWindow-reserve-o.toolTokens)
+	return openai.ChatCompletionRequest{
+		Model:       o.model,
+		Messages:    messages,
+		Temperature: temperature,
+		MaxTokens:   o.maxTokens,
+	}
+}
+
+// complete sends a chat completion request within the spend limits and
+// records the tokens it used
+func (o *OpenAIClient) complete(req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if err := o.spend.Allow(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	resp, err := o.client.CreateChatCompletion(context.Background(), req)
+	if err != nil {
+		return resp, o.apiError(err)
+	}
+	o.spend.Record(resp.Usage.TotalTokens)
+	return resp, nil
+}
+
+func (o *OpenAIClient) ProcessPrompt(prompt string) (string, error) {
+	resp, err := o.complete(o.newRequest(nil, prompt))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("%s API error: empty response", o.name)
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// RunTask sends input with the task prompt as an extra system message
+func (o *OpenAIClient) RunTask(task, input string) (string, error) {
+	instructions := o.prompts.Template(task)
+	if instructions == "" {
+		return "", fmt.Errorf("unknown task prompt %q", task)
+	}
+	req := o.newRequest([]Message{{Role: RoleSystem, Content: instructions}}, input)
+	resp, err := o.complete(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("%s API error: empty response", o.name)
+	}
 	return resp.Choices[0].Message.Content, nil
 }
 
-const systemPrompt = `You are an F5 BIG-IP expert assistant. You help users manage their BIG-IP configuration through natural language queries. Your expertise includes:
-
-1. Understanding BIG-IP Architecture:
-   - Virtual Servers (VIPs): Front-end service points that receive client traffic
-   - Pools: Groups of backend servers for load balancing
-   - Nodes: Individual backend servers providing services
-
-2. API Knowledge - Key endpoints:
-   - Virtual Servers: /mgmt/tm/ltm/virtual
-   - Pools: /mgmt/tm/ltm/pool
-   - Nodes: /mgmt/tm/ltm/node
-
-3. Operations you can help with:
-   - Listing configuration items and their status
-   - Explaining relationships between components
-   - Providing context about BIG-IP concepts
-   - Troubleshooting basic configuration issues
-   - Querying WAF (Web Application Firewall) policies
-
-When responding:
-1. Identify the specific BIG-IP components involved
-2. Determine the operation type (view, analyze, explain)
-3. Use the appropriate API endpoint
-4. Provide clear, structured information
-5. Include relevant context about component relationships
-
-For all responses:
-- Be precise with technical terms
-- Explain any acronyms used (e.g., VIP = Virtual IP)
-- Format output in an easily readable structure
-- Provide additional context when relevant
-
-Remember: Your goal is to make BIG-IP configuration management accessible and clear for users of all expertise levels.`
+// Stream returns the completion for prompt, passing each chunk to onDelta as it arrives
+func (o *OpenAIClient) Stream(prompt string, onDelta func(string)) (string, error) {
+	req := o.newRequest(nil, prompt)
+	req.Stream = true
+	if err := o.spend.Allow(); err != nil {
+		return "", err
+	}
+	stream, err := o.client.CreateChatCompletionStream(context.Background(), req)
+	if err != nil {
+		return "", o.apiError(err)
+	}
+	defer stream.Close()
+
+	var sb strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			// Streams don't report usage, so estimate it
+			o.spend.Record(estimateMessages(req.Messages) + EstimateTokens(sb.String()))
+			return sb.String(), nil
+		}
+		if err != nil {
+			return sb.String(), o.apiError(err)
+		}
+		if len(resp.Choices) == 0 {
+			continue
+		}
+		delta := resp.Choices[0].Delta.Content
+		sb.WriteString(delta)
+		if onDelta != nil && delta != "" {
+			onDelta(delta)
+		}
+	}
+}
+
+// Embed returns an embedding vector for each text, for documentation retrieval
+func (o *OpenAIClient) Embed(texts []string) ([][]float32, error) {
+	model := o.embeddingModel
+	if model == "" {
+		model = string(openai.SmallEmbedding3)
+	}
+	if err := o.spend.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := o.client.CreateEmbeddings(context.Background(), openai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: openai.EmbeddingModel(model),
+	})
+	if err != nil {
+		return nil, o.apiError(err)
+	}
+	o.spend.Record(resp.Usage.TotalTokens)
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	return vectors, nil
+}
+
+// Ping verifies the API key and endpoint by listing the available models,
+// which doesn't consume any tokens
+func (o *OpenAIClient) Ping() error {
+	if _, err := o.client.ListModels(context.Background()); err != nil {
+		return o.apiError(err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// owaspQuery matches requests for how well WAF policies cover the OWASP
+	// Top 10: "how compliant is policy VS_WAF with OWASP", "OWASP scores of
+	// the WAF policies", "is VS_WAF OWASP compliant?". "what is the OWASP
+	// Top 10?" is a concept.
+	owaspQuery = regexp.MustCompile(`(?i)\bowasp\b.*\b(?:complian(?:t|ce)|scores?|rating|coverage|covered|dashboard)\b|` +
+		`\b(?:complian(?:t|ce)|scores?|rating|coverage|covered)\b.*\bowasp\b`)
+	// owaspPolicy names the policy without the word "policy": "is VS_WAF
+	// OWASP compliant", "how compliant is VS_WAF with OWASP", "the OWASP
+	// score of VS_WAF"
+	owaspPolicy = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(?:is|are)\s+["'` + "`" + `]?([\w/.~-]+)["'` + "`" + `]?\s+(?:(?:with|against|to)\s+(?:the\s+)?)?owasp\b`),
+		regexp.MustCompile(`(?i)\bowasp\b.*\b(?:of|for)\s+["'` + "`" + `]?([\w/.~-]+)["'` + "`" + `]?\s*[?.!]*$`),
+	}
+)
+
+// ParseOWASPCompliance recognises a request for the OWASP Top 10 compliance
+// of the WAF policies, with the policy it is about when the query names one
+func ParseOWASPCompliance(query string) (*ToolCall, bool) {
+	if !owaspQuery.MatchString(query) {
+		return nil, false
+	}
+	args := map[string]string{}
+	name := policyNamed(query)
+	for _, pattern := range owaspPolicy {
+		if name != "" {
+			break
+		}
+		if m := pattern.FindStringSubmatch(query); m != nil {
+			switch strings.ToLower(m[1]) {
+			case "it", "this", "that", "we", "our", "my", "the", "each", "every", "all", "waf", "asm", "policy", "policies", "device", "big-ip", "bigip":
+			default:
+				name = m[1]
+			}
+		}
+	}
+	if name != "" {
+		args["name"] = name
+	}
+	return &ToolCall{Name: ToolOWASPCompliance, Args: args}, true
+}
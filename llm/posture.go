@@ -0,0 +1,19 @@
+package llm
+
+import "regexp"
+
+// postureQuery matches requests for the device's security posture: "how
+// secure is the BIG-IP?", "security report", "which VIPs lack an ASM
+// policy?". "show WAF policies" and "security policies" are listings.
+var postureQuery = regexp.MustCompile(`(?i)\bsecurity\s+(?:posture|report|review|assessment|audit|overview|gaps?)\b|` +
+	`\bhow\s+secure\b|\bposture\b|\bwaf\s+coverage\b|` +
+	`\b(?:vips?|virtual\s+servers?|apps?|applications?)\b.*\b(?:without|lack(?:ing)?|missing|unprotected\s+by)\s+(?:an?\s+)?(?:waf|asm)\b|` +
+	`\bunprotected\s+(?:vips?|virtual\s+servers?|apps?|applications?)\b`)
+
+// ParseSecurityPosture recognises a request for the security posture report
+func ParseSecurityPosture(query string) (*ToolCall, bool) {
+	if !postureQuery.MatchString(query) {
+		return nil, false
+	}
+	return &ToolCall{Name: ToolSecurityPosture, Args: map[string]string{}}, true
+}
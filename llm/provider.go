@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"f5chat/config"
+)
+
+// Provider is an LLM backend. The chat interface only talks to this
+// interface, so new backends just register a Factory.
+type Provider interface {
+	// Name identifies the backend in health checks and logs
+	Name() string
+	// ProcessPrompt returns a free-text completion for the prompt
+	ProcessPrompt(prompt string) (string, error)
+	// ProcessWithTools asks the model which BIG-IP operation answers the
+	// query, given the earlier turns of the conversation
+	ProcessWithTools(history []Message, query string) (*Reply, error)
+	// Stream is like ProcessPrompt but calls onDelta with each chunk as it
+	// arrives; it returns the full completion
+	Stream(prompt string, onDelta func(string)) (string, error)
+	// RunTask answers input following the named task prompt (see the
+	// prompt package), such as prompt.IRules
+	RunTask(task, input string) (string, error)
+	// Ping verifies the credentials and endpoint without spending tokens
+	Ping() error
+}
+
+// Message is one earlier turn of the conversation
+type Message struct {
+	Role    string // RoleUser, RoleAssistant or RoleSystem
+	Content string
+}
+
+// Conversation roles for Message
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	// RoleSystem carries extra instructions or context, such as retrieved documentation
+	RoleSystem = "system"
+)
+
+// Factory builds a provider from the configuration
+type Factory func(cfg *config.Config) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available by name for LLM_PROVIDER. It panics
+// if the name is registered twice, like database/sql drivers.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name = strings.ToLower(name)
+	if _, dup := registry[name]; dup {
+		panic("llm: provider registered twice: " + name)
+	}
+	registry[name] = factory
+}
+
+// Providers returns the registered provider names in sorted order
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the provider selected by cfg.LLMProvider, defaulting to
+// openai, chained to cfg.LLMFallbackProvider when one is configured
+func New(cfg *config.Config) (Provider, error) {
+	name := cfg.LLMProvider
+	if name == "" {
+		name = "openai"
+	}
+	// Both providers count against the same limits
+	spend := NewSpend(cfg)
+	primary, err := build(name, cfg, spend)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LLMFallbackProvider == "" {
+		return primary, nil
+	}
+	secondary, err := build(cfg.LLMFallbackProvider, cfg, spend)
+	if err != nil {
+		return nil, fmt.Errorf("fallback LLM provider: %w", err)
+	}
+	return NewFallback(primary, secondary), nil
+}
+
+// build runs the factory registered under name. Hosted providers are
+// subject to the spend limits; local models cost nothing to call.
+func build(name string, cfg *config.Config, spend *Spend) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[strings.ToLower(name)]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q (available: %s)", name, strings.Join(Providers(), ", "))
+	}
+	provider, err := factory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	local := localProvider(name, cfg)
+	if client, ok := provider.(*OpenAIClient); ok && !local {
+		client.spend = spend
+	}
+	if cfg.RedactEnabled && !local {
+		return NewRedactor(provider, cfg), nil
+	}
+	return provider, nil
+}
+
+// localProvider reports whether a provider keeps data on this network, so
+// nothing needs redacting before it is sent. An Ollama server is local when
+// its address is; OpenAI and Azure are billed wherever they are reached.
+func localProvider(name string, cfg *config.Config) bool {
+	switch strings.ToLower(name) {
+	case "rules":
+		return true
+	case "ollama":
+		baseURL := cfg.OllamaBaseURL
+		if baseURL == "" {
+			baseURL = ollamaBaseURL
+		}
+		return localURL(baseURL)
+	}
+	return false
+}
+
+// localURL reports whether a URL's host is this machine or on a private
+// network: loopback, RFC 1918 or IPv6 unique local. Other names aren't
+// resolved, since what they resolve to can change.
+func localURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && (addr.IsLoopback() || addr.IsPrivate())
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// qkviewQuery matches requests about qkviews and iHealth
+	qkviewQuery = regexp.MustCompile(`(?i)\bqkviews?\b|\bi-?health\b`)
+	// qkviewQuestion matches questions about them rather than requests:
+	// "what is a qkview?"
+	qkviewQuestion = regexp.MustCompile(`(?i)^\s*(?:what|why|how|when|where|who|which|is|are|does|explain|describe)\b`)
+	// qkviewGenerate matches asking for a new qkview
+	qkviewGenerate = regexp.MustCompile(`(?i)\b(?:generate|create|take|collect|make|run|get|grab|produce|new|fresh)\b`)
+	// qkviewUpload matches asking for one to be analyzed
+	qkviewUpload = regexp.MustCompile(`(?i)\b(?:upload|send|submit|push|analy[sz]e)\b|\bi-?health\b`)
+	// qkviewFile is the qkview file named: "to /tmp/bigip1.qkview"
+	qkviewFile = regexp.MustCompile(`(?i)([^\s"'` + "`" + `]+\.qkview)\b`)
+	// qkviewCase is the support case named: "for case 00123456", "SR C1234567"
+	qkviewCase = regexp.MustCompile(`(?i)\b(?:case|sr|ticket)\s*(?:#|number|no\.?)?\s*([A-Z]?\d{5,})\b`)
+)
+
+// ParseQkview recognises a request to generate a qkview, and to upload it
+// to iHealth, or to upload one already generated, with the file and the
+// support case it names, if any
+func ParseQkview(query string) (*ToolCall, bool) {
+	if !qkviewQuery.MatchString(query) || qkviewQuestion.MatchString(query) && !qkviewGenerate.MatchString(query) {
+		return nil, false
+	}
+	args := map[string]string{}
+	if m := qkviewFile.FindStringSubmatch(query); m != nil {
+		args["file"] = m[1]
+	}
+	if m := qkviewCase.FindStringSubmatch(query); m != nil {
+		args["case"] = strings.ToUpper(m[1])
+	}
+	upload := qkviewUpload.MatchString(query)
+	switch {
+	case qkviewGenerate.MatchString(query):
+		if upload {
+			args["upload"] = "true"
+		}
+		return &ToolCall{Name: ToolGenerateQkview, Args: args}, true
+	case upload:
+		return &ToolCall{Name: ToolUploadQkview, Args: args}, true
+	case strings.Contains(strings.ToLower(query), "qkview"):
+		return &ToolCall{Name: ToolGenerateQkview, Args: args}, true
+	}
+	return nil, false
+}
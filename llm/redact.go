@@ -0,0 +1,347 @@
+package llm
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"f5chat/config"
+)
+
+// Kinds of redacted data, used in placeholders and the redaction report
+const (
+	RedactCredential = "credential"
+	RedactHostname   = "hostname"
+	RedactInternalIP = "internal_ip"
+)
+
+var placeholderPrefix = map[string]string{
+	RedactCredential: "SECRET",
+	RedactHostname:   "HOST",
+	RedactInternalIP: "IP",
+}
+
+// credentialPatterns match secrets; the last submatch, if any, is the part
+// that gets replaced so the surrounding key stays readable
+var credentialPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`(?i)\b(?:password|passwd|passphrase|secret|token|api[_-]?key)"?\s*[:=]\s*"?([^\s"',;]+)`),
+	regexp.MustCompile(`(?i)\bBearer\s+([A-Za-z0-9._~+/=-]{8,})`),
+	regexp.MustCompile(`(?i)\bX-F5-Auth-Token:\s*(\S+)`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}\b`),
+	// user:password@ in URLs
+	regexp.MustCompile(`://[^/\s:@]+:([^/\s@]+)@`),
+}
+
+var (
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	// ipv6Pattern matches unique local (fc00::/7) and link-local (fe80::/10) addresses
+	ipv6Pattern = regexp.MustCompile(`(?i)\bf[c-e][0-9a-f]{2}:[0-9a-f:]*[0-9a-f]`)
+	// hostnamePattern matches dotted names ending in an alphabetic label
+	hostnamePattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{1,62}\b`)
+)
+
+// notTLDs are file extensions and similar suffixes that make a dotted word
+// look like a hostname
+var notTLDs = map[string]bool{
+	"md": true, "txt": true, "json": true, "yaml": true, "yml": true, "html": true,
+	"go": true, "tcl": true, "log": true, "conf": true, "cfg": true, "js": true,
+	"py": true, "sh": true, "xml": true, "csv": true, "pem": true, "crt": true,
+	"key": true, "tf": true, "ini": true, "irule": true, "ucs": true, "qkview": true,
+}
+
+// Redactor wraps a hosted provider, replacing credentials, hostnames and
+// optionally internal addresses with placeholders before anything is sent,
+// and restoring the originals in what comes back. A value gets the same
+// placeholder throughout a request, so the model can still refer to it.
+type Redactor struct {
+	next        Provider
+	internalIPs bool
+	// literals are known sensitive values from the configuration
+	literals map[string]string
+
+	mu       sync.Mutex
+	requests int
+	counts   map[string]int
+}
+
+var _ Provider = (*Redactor)(nil)
+
+// NewRedactor wraps next. The configured BIG-IP host and password are
+// always redacted, even where the patterns wouldn't catch them.
+func NewRedactor(next Provider, cfg *config.Config) *Redactor {
+	r := &Redactor{
+		next:        next,
+		internalIPs: cfg.RedactInternalIPs,
+		literals:    make(map[string]string),
+		counts:      make(map[string]int),
+	}
+	// Very short passwords would match ordinary words
+	if len(cfg.BigIPPassword) >= 4 {
+		r.literals[cfg.BigIPPassword] = RedactCredential
+	}
+	if host := bigipHostname(cfg.BigIPHost); host != "" {
+		r.literals[host] = RedactHostname
+	}
+	for _, d := range cfg.Devices {
+		if host := bigipHostname(d.Host); host != "" {
+			r.literals[host] = RedactHostname
+		}
+	}
+	return r
+}
+
+// bigipHostname strips the port and IPv6 brackets from a BIG-IP address
+func bigipHostname(hostPort string) string {
+	if host, _, err := net.SplitHostPort(hostPort); err == nil {
+		return host
+	}
+	return strings.Trim(hostPort, "[]")
+}
+
+// redaction holds the placeholders for one request
+type redaction struct {
+	byValue  map[string]string
+	original map[string]string
+	counts   map[string]int
+}
+
+func newRedaction() *redaction {
+	return &redaction{byValue: make(map[string]string), original: make(map[string]string), counts: make(map[string]int)}
+}
+
+func (x *redaction) placeholder(kind, value string) string {
+	if p, ok := x.byValue[value]; ok {
+		return p
+	}
+	x.counts[kind]++
+	p := fmt.Sprintf("[REDACTED_%s_%d]", placeholderPrefix[kind], x.counts[kind])
+	x.byValue[value] = p
+	x.original[p] = value
+	return p
+}
+
+// restore puts the original values back in text from the model
+func (x *redaction) restore(text string) string {
+	if len(x.original) == 0 || !strings.Contains(text, "[REDACTED_") {
+		return text
+	}
+	for p, value := range x.original {
+		text = strings.ReplaceAll(text, p, value)
+	}
+	return text
+}
+
+// scrub redacts one piece of outgoing text
+func (r *Redactor) scrub(x *redaction, text string) string {
+	for _, re := range credentialPatterns {
+		text = replaceMatches(re, text, func(value string) string {
+			// Already redacted, or an elided/masked value ("...", "****") in an example
+			if strings.HasPrefix(value, "[REDACTED_") || strings.Trim(value, ".*") == "" {
+				return value
+			}
+			return x.placeholder(RedactCredential, value)
+		})
+	}
+
+	// Longest first, so a host isn't partly replaced by a shorter literal
+	literals := make([]string, 0, len(r.literals))
+	for value := range r.literals {
+		literals = append(literals, value)
+	}
+	sort.Slice(literals, func(a, b int) bool { return len(literals[a]) > len(literals[b]) })
+	for _, value := range literals {
+		if strings.Contains(text, value) {
+			text = strings.ReplaceAll(text, value, x.placeholder(r.literals[value], value))
+		}
+	}
+
+	if r.internalIPs {
+		for _, re := range []*regexp.Regexp{ipv4Pattern, ipv6Pattern} {
+			text = re.ReplaceAllStringFunc(text, func(value string) string {
+				ip := net.ParseIP(value)
+				if ip == nil || !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()) {
+					return value
+				}
+				return x.placeholder(RedactInternalIP, value)
+			})
+		}
+	}
+
+	return hostnamePattern.ReplaceAllStringFunc(text, func(value string) string {
+		if notTLDs[strings.ToLower(value[strings.LastIndex(value, ".")+1:])] {
+			return value
+		}
+		return x.placeholder(RedactHostname, value)
+	})
+}
+
+// replaceMatches replaces the last submatch of each match of re (or the
+// whole match when there are no submatches) with replace(value)
+func replaceMatches(re *regexp.Regexp, text string, replace func(string) string) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[len(m)-2], m[len(m)-1]
+		if start < 0 {
+			continue
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString(replace(text[start:end]))
+		last = end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// record adds a request's redactions to the report and logs them
+func (r *Redactor) record(operation string, x *redaction) {
+	r.mu.Lock()
+	r.requests++
+	for kind, n := range x.counts {
+		r.counts[kind] += n
+	}
+	r.mu.Unlock()
+	if len(x.counts) > 0 {
+		slog.Info("Redacted sensitive data before LLM call", "provider", r.next.Name(), "operation", operation,
+			"credentials", x.counts[RedactCredential], "hostnames", x.counts[RedactHostname], "internal_ips", x.counts[RedactInternalIP])
+	}
+}
+
+// Report summarises what has been redacted so far
+func (r *Redactor) Report() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := fmt.Sprintf("%s: %d request(s); redacted %d credential(s), %d hostname(s), %d internal IP(s)",
+		r.next.Name(), r.requests, r.counts[RedactCredential], r.counts[RedactHostname], r.counts[RedactInternalIP])
+	if !r.internalIPs {
+		report += " (internal IPs are sent as is; set REDACT_INTERNAL_IPS=true to redact them)"
+	}
+	return report
+}
+
+// RedactionReport returns the report of each redacting provider in p, or
+// false when nothing is redacted
+func RedactionReport(p Provider) (string, bool) {
+	switch v := p.(type) {
+	case *Redactor:
+		return v.Report(), true
+	case *Fallback:
+		var lines []string
+		for _, member := range []Provider{v.primary, v.secondary} {
+			if report, ok := RedactionReport(member); ok {
+				lines = append(lines, report)
+			}
+		}
+		return strings.Join(lines, "\n"), len(lines) > 0
+	}
+	return "", false
+}
+
+func (r *Redactor) Name() string { return r.next.Name() }
+
+func (r *Redactor) ProcessPrompt(prompt string) (string, error) {
+	x := newRedaction()
+	prompt = r.scrub(x, prompt)
+	r.record("prompt", x)
+	text, err := r.next.ProcessPrompt(prompt)
+	return x.restore(text), err
+}
+
+func (r *Redactor) ProcessWithTools(history []Message, query string) (*Reply, error) {
+	x := newRedaction()
+	scrubbed := make([]Message, len(history))
+	for j, m := range history {
+		scrubbed[j] = Message{Role: m.Role, Content: r.scrub(x, m.Content)}
+	}
+	query = r.scrub(x, query)
+	r.record("tools", x)
+
+	reply, err := r.next.ProcessWithTools(scrubbed, query)
+	if err != nil {
+		return nil, err
+	}
+	reply.Text = x.restore(reply.Text)
+	if reply.ToolCall != nil {
+		for k, v := range reply.ToolCall.Args {
+			reply.ToolCall.Args[k] = x.restore(v)
+		}
+	}
+	return reply, nil
+}
+
+func (r *Redactor) RunTask(task, input string) (string, error) {
+	x := newRedaction()
+	input = r.scrub(x, input)
+	r.record(task, x)
+	text, err := r.next.RunTask(task, input)
+	return x.restore(text), err
+}
+
+// Stream restores placeholders in each chunk, holding back a trailing
+// partial placeholder until the rest of it arrives
+func (r *Redactor) Stream(prompt string, onDelta func(string)) (string, error) {
+	x := newRedaction()
+	prompt = r.scrub(x, prompt)
+	r.record("stream", x)
+
+	var pending string
+	text, err := r.next.Stream(prompt, func(delta string) {
+		pending += delta
+		cut := len(pending)
+		if open := strings.LastIndex(pending, "["); open >= 0 && !strings.Contains(pending[open:], "]") && len(pending)-open < 40 {
+			cut = open
+		}
+		if out := x.restore(pending[:cut]); out != "" && onDelta != nil {
+			onDelta(out)
+		}
+		pending = pending[cut:]
+	})
+	if pending != "" && onDelta != nil {
+		onDelta(x.restore(pending))
+	}
+	return x.restore(text), err
+}
+
+// Embed redacts the texts too: embedding APIs are hosted as well
+func (r *Redactor) Embed(texts []string) ([][]float32, error) {
+	embedder, ok := r.next.(interface {
+		Embed(texts []string) ([][]float32, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("%s does not support embeddings", r.next.Name())
+	}
+	x := newRedaction()
+	scrubbed := make([]string, len(texts))
+	for j, t := range texts {
+		scrubbed[j] = r.scrub(x, t)
+	}
+	r.record("embed", x)
+	return embedder.Embed(scrubbed)
+}
+
+func (r *Redactor) ClassifyRisk(query string, call *ToolCall) (Risk, string, error) {
+	classifier, ok := r.next.(RiskClassifier)
+	if !ok {
+		return 0, "", fmt.Errorf("%s can't classify request risk", r.next.Name())
+	}
+	x := newRedaction()
+	args := make(map[string]string, len(call.Args))
+	for k, v := range call.Args {
+		args[k] = r.scrub(x, v)
+	}
+	query = r.scrub(x, query)
+	r.record("risk", x)
+	risk, reason, err := classifier.ClassifyRisk(query, &ToolCall{Name: call.Name, Args: args})
+	return risk, x.restore(reason), err
+}
+
+func (r *Redactor) Ping() error { return r.next.Ping() }
@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"f5chat/retry"
+	"github.com/sashabaranov/go-openai"
+)
+
+// retryingDoer is the HTTP client handed to go-openai. Retrying at this
+// level sees the response headers, which go-openai's errors don't expose.
+// A Retry-After longer than the policy's MaxDelay is not waited out: the
+// error is returned so the caller isn't stuck behind a quota reset.
+type retryingDoer struct {
+	client   *http.Client
+	policy   retry.Policy
+	provider string
+}
+
+func (d *retryingDoer) Do(req *http.Request) (*http.Response, error) {
+	attempts := d.policy.Attempts()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := d.client.Do(req)
+		if !retryableResponse(resp, err) || attempt >= attempts {
+			return resp, err
+		}
+
+		delay := d.policy.Delay(attempt + 1)
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header); ok {
+				if after > d.policy.MaxDelay {
+					slog.Warn("LLM API asked to retry later than the backoff cap; giving up",
+						"provider", d.provider, "status", resp.StatusCode, "retry_after", after, "max_delay", d.policy.MaxDelay)
+					return resp, err
+				}
+				delay = after
+			}
+		}
+		if req.Body != nil && req.GetBody == nil {
+			// The request body can't be replayed
+			return resp, err
+		}
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		slog.Warn("LLM API request failed, retrying", "provider", d.provider, "path", req.URL.Path,
+			"attempt", attempt, "of", attempts, "status", status, "err", err, "delay", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			req.Body = body
+		}
+	}
+}
+
+// retryableResponse reports whether a request failed in a way that may
+// succeed on another attempt: network errors, 429 and 5xx responses
+func retryableResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter reads how long the server asked us to wait, from OpenAI's
+// retry-after-ms or the standard Retry-After (seconds or an HTTP date)
+func retryAfter(h http.Header) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// UnavailableError reports that the LLM API is rate limiting or failing
+// and retries didn't get through, as opposed to rejecting the request
+type UnavailableError struct {
+	Provider   string
+	StatusCode int
+	Err        error
+}
+
+func (e *UnavailableError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s API unavailable (HTTP %d): %v", e.Provider, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s API unavailable: %v", e.Provider, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error { return e.Err }
+
+// RateLimited reports whether the API rejected the request for rate or quota reasons
+func (e *UnavailableError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// apiError wraps an error from go-openai, marking rate limiting, server
+// errors and network failures as an UnavailableError
+func (o *OpenAIClient) apiError(err error) error {
+	var (
+		apiErr *openai.APIError
+		reqErr *openai.RequestError
+	)
+	status := 0
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+	if status == http.StatusTooManyRequests || status >= 500 || status == 0 && isNetworkError(err) {
+		return &UnavailableError{Provider: o.name, StatusCode: status, Err: err}
+	}
+	return fmt.Errorf("%s API error: %w", o.name, err)
+}
+
+// isNetworkError reports whether the request never got an HTTP response
+func isNetworkError(err error) bool {
+	var netErr interface{ Timeout() bool }
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"f5chat/prompt"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Risk is the blast radius of a request: how much it could change on the device
+type Risk int
+
+const (
+	RiskReadOnly Risk = iota
+	RiskLowRisk
+	RiskDisruptive
+)
+
+var riskNames = []string{"read-only", "low-risk", "disruptive"}
+
+func (r Risk) String() string {
+	if r < 0 || int(r) >= len(riskNames) {
+		return fmt.Sprintf("Risk(%d)", int(r))
+	}
+	return riskNames[r]
+}
+
+// ParseRisk parses "read-only", "low-risk" or "disruptive"
+func ParseRisk(s string) (Risk, error) {
+	for i, name := range riskNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return Risk(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid risk %q (want %s)", s, strings.Join(riskNames, ", "))
+}
+
+// toolRisk is the least blast radius each tool can have. Tools that aren't
+// listed are treated as disruptive, so a new write tool is guarded until
+// it is classified here.
+var toolRisk = map[string]Risk{
+	ToolListVirtualServers: RiskReadOnly,
+	ToolListPools:          RiskReadOnly,
+	ToolGetPool:            RiskReadOnly,
+	ToolListNodes:          RiskReadOnly,
+	ToolListWAFPolicies:    RiskReadOnly,
+	ToolGetWAFPolicy:       RiskReadOnly,
+	ToolGenerateIRule:      RiskReadOnly,
+	ToolExplainIRule:       RiskReadOnly,
+	ToolGenerateAS3:        RiskReadOnly,
+	ToolCompare:            RiskReadOnly,
+	ToolTroubleshoot:       RiskReadOnly,
+	ToolSecurityPosture:    RiskReadOnly,
+	ToolWAFViolations:      RiskReadOnly,
+	ToolOWASPCompliance:    RiskReadOnly,
+	ToolGTMTopology:        RiskReadOnly,
+	ToolGetAddressList:     RiskReadOnly,
+	ToolGetPortList:        RiskReadOnly,
+	ToolChangeJournal:      RiskReadOnly,
+	ToolHealthSummary:      RiskReadOnly,
+	ToolExportTerraform:    RiskReadOnly,
+	ToolGenerateAnsible:    RiskReadOnly,
+	// Only shows the entries; ToolUpdateFirewallList adds them once confirmed
+	ToolAddToFirewallList: RiskReadOnly,
+	// A qkview loads the device while it's collected, but changes nothing
+	ToolGenerateQkview: RiskReadOnly,
+	ToolUploadQkview:   RiskReadOnly,
+	// A new iRule does nothing until it is attached to a virtual server
+	ToolUploadIRule: RiskLowRisk,
+	// A declaration for a new tenant adds objects without touching existing
+	// ones; replacing a tenant is left to the classifier to escalate
+	ToolDeployAS3: RiskLowRisk,
+	// Entries are only ever added to a list, never removed; what firewall
+	// rules using it then match is left to the classifier to escalate
+	ToolUpdateFirewallList: RiskLowRisk,
+}
+
+// ToolRisk returns the declared blast radius of a tool
+func ToolRisk(name string) Risk {
+	if r, ok := toolRisk[name]; ok {
+		return r
+	}
+	return RiskDisruptive
+}
+
+// RiskClassifier is implemented by providers that can label the blast
+// radius of a request independently of the tool chosen for it
+type RiskClassifier interface {
+	// ClassifyRisk labels what the user asked for, with a short reason
+	ClassifyRisk(query string, call *ToolCall) (Risk, string, error)
+}
+
+const labelTool = "label_blast_radius"
+
+var labelTools = []openai.Tool{{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+	Name:        labelTool,
+	Description: "Record the blast radius of the user's request",
+	Parameters: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"risk":   {Type: jsonschema.String, Enum: riskNames},
+			"reason": {Type: jsonschema.String, Description: "One sentence explaining the label"},
+		},
+		Required: []string{"risk", "reason"},
+	},
+}}}
+
+// ClassifyRisk is a second, independent pass over the raw request. It sees
+// neither the conversation nor the main system prompt, so it can catch a
+// read request that was routed to a write operation.
+func (o *OpenAIClient) ClassifyRisk(query string, call *ToolCall) (Risk, string, error) {
+	req := openai.ChatCompletionRequest{
+		Model: o.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: o.prompts.Template(prompt.Guardrail)},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Request: %s\nSelected operation: %s %v", query, call.Name, call.Args)},
+		},
+		// go-openai omits a zero temperature
+		Temperature: math.SmallestNonzeroFloat32,
+		Tools:       labelTools,
+		ToolChoice:  openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: labelTool}},
+	}
+	resp, err := o.complete(req)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+		return 0, "", fmt.Errorf("%s API error: no risk label in response", o.name)
+	}
+	label, err := decodeToolCall(resp.Choices[0].Message.ToolCalls[0])
+	if err != nil {
+		return 0, "", err
+	}
+	risk, err := ParseRisk(label.Arg("risk"))
+	if err != nil {
+		return 0, "", err
+	}
+	return risk, label.Arg("reason"), nil
+}
+
+func (f *Fallback) ClassifyRisk(query string, call *ToolCall) (Risk, string, error) {
+	var (
+		risk   Risk
+		reason string
+	)
+	err := f.call("risk", func(p Provider) error {
+		classifier, ok := p.(RiskClassifier)
+		if !ok {
+			return fmt.Errorf("%s can't classify request risk", p.Name())
+		}
+		var err error
+		risk, reason, err = classifier.ClassifyRisk(query, call)
+		return err
+	})
+	return risk, reason, err
+}
@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"f5chat/config"
+)
+
+func init() {
+	Register("rules", func(cfg *config.Config) (Provider, error) { return NewRulesProvider(), nil })
+}
+
+// ErrNoLLM is returned for requests that need a language model when
+// running with the rule-based router
+var ErrNoLLM = errors.New("no LLM configured (running with -no-llm)")
+
+// RulesProvider routes queries to BIG-IP operations with fixed patterns
+// instead of a model, so the tool works without an API key. Anything the
+// rules don't recognise gets the help text.
+type RulesProvider struct{}
+
+var _ Provider = (*RulesProvider)(nil)
+
+func NewRulesProvider() *RulesProvider { return &RulesProvider{} }
+
+func (r *RulesProvider) Name() string { return "Rule-based router (no LLM)" }
+
+// rule maps queries matching pattern onto tool. When the query names an
+// object after one of nouns (see ExtractName), namedTool is used instead;
+// rules without a tool only match named objects.
+type rule struct {
+	pattern   *regexp.Regexp
+	tool      string
+	nouns     []string
+	namedTool string
+}
+
+// rules are tried in order; WAF policies come first so "policies on virtual
+// servers" isn't taken as a virtual server listing
+var rules = []rule{
+	{
+		pattern:   regexp.MustCompile(`(?i)\b(waf|asm)\b|\bpolic(y|ies)\b`),
+		tool:      ToolListWAFPolicies,
+		nouns:     []string{"policy"},
+		namedTool: ToolGetWAFPolicy,
+	},
+	{
+		// Without an LLM the iRule is shown but not explained
+		pattern:   regexp.MustCompile(`(?i)\birules?\b`),
+		nouns:     []string{"irule"},
+		namedTool: ToolExplainIRule,
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)\b(virtual[\s-]*servers?|vips?|vs)\b`),
+		tool:    ToolListVirtualServers,
+	},
+	{
+		pattern:   regexp.MustCompile(`(?i)\bpools?\b`),
+		tool:      ToolListPools,
+		nouns:     []string{"pool"},
+		namedTool: ToolGetPool,
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)\b(nodes?|backends?|servers?)\b`),
+		tool:    ToolListNodes,
+	},
+}
+
+// route returns the tool call for a query, or nil if no rule matches
+func route(query string) *ToolCall {
+	if call, ok := ParseCompare(query); ok {
+		return call
+	}
+	if call, ok := ParseAddToFirewallList(query); ok {
+		return call
+	}
+	if call, ok := ParseFirewallList(query); ok {
+		return call
+	}
+	if call, ok := ParseGTMTopology(query); ok {
+		return call
+	}
+	if call, ok := ParseTroubleshoot(query); ok {
+		return call
+	}
+	if call, ok := ParseOWASPCompliance(query); ok {
+		return call
+	}
+	if call, ok := ParseSecurityPosture(query); ok {
+		return call
+	}
+	if call, ok := ParseWAFViolations(query); ok {
+		return call
+	}
+	if call, ok := ParseChangeJournal(query); ok {
+		return call
+	}
+	if call, ok := ParseHealthSummary(query); ok {
+		return call
+	}
+	if call, ok := ParseExportTerraform(query); ok {
+		return call
+	}
+	if call, ok := ParseAnsible(query); ok {
+		return call
+	}
+	if call, ok := ParseQkview(query); ok {
+		return call
+	}
+	for _, r := range rules {
+		if !r.pattern.MatchString(query) {
+			continue
+		}
+		if len(r.nouns) > 0 {
+			if name := ExtractName(query, r.nouns...); name != "" {
+				return &ToolCall{Name: r.namedTool, Args: map[string]string{"name": name}}
+			}
+		}
+		if r.tool == "" {
+			// Only handles named objects
+			continue
+		}
+		return &ToolCall{Name: r.tool, Args: map[string]string{}}
+	}
+	return nil
+}
+
+// ProcessWithTools matches the query against the rules. Follow-ups like
+// "what members does it have?" are resolved against the most recent earlier
+// question that matched.
+func (r *RulesProvider) ProcessWithTools(history []Message, query string) (*Reply, error) {
+	if call := route(query); call != nil {
+		return &Reply{ToolCall: call}, nil
+	}
+	if refersBack(query) {
+		for j := len(history) - 1; j >= 0; j-- {
+			if history[j].Role != RoleUser {
+				continue
+			}
+			if call := route(history[j].Content); call != nil {
+				return &Reply{ToolCall: call}, nil
+			}
+		}
+	}
+	return &Reply{}, nil
+}
+
+// refersBack reports whether the query points at an earlier answer
+func refersBack(query string) bool {
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		switch strings.Trim(w, ".,!?'\"") {
+		case "it", "its", "that", "those", "them", "one", "these", "this":
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RulesProvider) ProcessPrompt(prompt string) (string, error) { return "", ErrNoLLM }
+
+func (r *RulesProvider) RunTask(task, input string) (string, error) { return "", ErrNoLLM }
+
+func (r *RulesProvider) Stream(prompt string, onDelta func(string)) (string, error) {
+	return "", ErrNoLLM
+}
+
+// Ping always succeeds; there is no API to reach
+func (r *RulesProvider) Ping() error { return nil }
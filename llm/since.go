@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// changesSince matches "what changed since this morning", "has anything
+	// changed since snapshot before-upgrade", "show changes since 9am"
+	changesSince = regexp.MustCompile(`(?i)^\s*(?:please\s+)?(?:what(?:'s|\s+has|\s+have)?|has\s+anything|did\s+anything|anything|(?:show|list)(?:\s+me)?(?:\s+the)?|the)?\s*(?:changed|changes?)\s+since\s+(.+?)[\s?.!]*$`)
+	// sinceSnapshot names a snapshot: "snapshot before-upgrade", "the
+	// before-upgrade snapshot"
+	sinceSnapshot  = regexp.MustCompile(`(?i)^(?:the\s+|my\s+)?snapshot\s+["'` + "`" + `]?([\w.-]+?)["'` + "`" + `]?$|^(?:the\s+|my\s+)?["'` + "`" + `]?([\w.-]+?)["'` + "`" + `]?\s+snapshot$`)
+	sinceLatest    = regexp.MustCompile(`(?i)^(?:the\s+|my\s+)?(?:(?:last|latest|previous|most\s+recent)\s+)?(?:snapshot|one)$`)
+	sinceAgo       = regexp.MustCompile(`(?i)^(an?|\d+)\s+(minute|min|hour|hr|day|week|month)s?\s+ago$`)
+	sinceLast      = regexp.MustCompile(`(?i)^(?:the\s+)?(?:last|past)\s+(?:(\d+)\s+)?(minute|hour|day|week|month)s?$`)
+	sinceClock     = regexp.MustCompile(`(?i)^(?:at\s+)?(\d{1,2})(?:[:.](\d{2}))?\s*(am|pm)?$`)
+	sinceLatestRef = map[string]bool{"last": true, "latest": true, "previous": true}
+)
+
+// sinceUnits are the lengths of the units in "2 hours ago"
+var sinceUnits = map[string]time.Duration{
+	"minute": time.Minute, "min": time.Minute,
+	"hour": time.Hour, "hr": time.Hour,
+	"day": 24 * time.Hour, "week": 7 * 24 * time.Hour, "month": 30 * 24 * time.Hour,
+}
+
+// ChangesSince is what a "what changed since" question compares with: the
+// snapshot called Name, the latest snapshot, or the first one taken at or
+// after Time. None of them is set when the phrase wasn't understood.
+type ChangesSince struct {
+	Phrase string
+	Name   string
+	Latest bool
+	Time   time.Time
+}
+
+// Understood reports whether the phrase after "since" could be read
+func (c ChangesSince) Understood() bool {
+	return c.Name != "" || c.Latest || !c.Time.IsZero()
+}
+
+// ParseChangesSince recognises a question about what changed on the device
+// since an earlier time or snapshot, reading times relative to now: "this
+// morning", "yesterday", "2 hours ago", "9am", "the last snapshot" or
+// "snapshot before-upgrade"
+func ParseChangesSince(query string, now time.Time) (ChangesSince, bool) {
+	m := changesSince.FindStringSubmatch(query)
+	if m == nil {
+		return ChangesSince{}, false
+	}
+	return ParseSince(m[1], now), true
+}
+
+// ParseSince reads what a phrase after "since" refers to, relative to now:
+// a time such as "this morning", "2 hours ago", "the last week" or "9am",
+// the latest snapshot or a snapshot by name
+func ParseSince(phrase string, now time.Time) ChangesSince {
+	phrase = strings.TrimSpace(phrase)
+	out := ChangesSince{Phrase: phrase}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	lower := strings.ToLower(strings.Join(strings.Fields(phrase), " "))
+
+	switch lower {
+	case "this morning", "the morning", "today", "earlier today", "earlier", "midnight":
+		out.Time = midnight
+		return out
+	case "yesterday", "yesterday morning":
+		out.Time = midnight.AddDate(0, 0, -1)
+		return out
+	case "last night", "yesterday evening", "yesterday afternoon":
+		out.Time = midnight.AddDate(0, 0, -1).Add(12 * time.Hour)
+		return out
+	case "this afternoon", "noon", "midday", "lunch", "lunchtime":
+		out.Time = midnight.Add(12 * time.Hour)
+		return out
+	case "this week":
+		out.Time = midnight.AddDate(0, 0, -(int(now.Weekday())+6)%7)
+		return out
+	}
+	if sinceLatest.MatchString(lower) {
+		out.Latest = true
+		return out
+	}
+	if s := sinceSnapshot.FindStringSubmatch(phrase); s != nil {
+		name := s[1] + s[2]
+		if sinceLatestRef[strings.ToLower(name)] {
+			out.Latest = true
+		} else {
+			out.Name = name
+		}
+		return out
+	}
+	if a := sinceAgo.FindStringSubmatch(lower); a != nil {
+		n := 1
+		if a[1] != "a" && a[1] != "an" {
+			n, _ = strconv.Atoi(a[1])
+		}
+		out.Time = now.Add(-time.Duration(n) * sinceUnits[a[2]])
+		return out
+	}
+	if l := sinceLast.FindStringSubmatch(lower); l != nil {
+		n := 1
+		if l[1] != "" {
+			n, _ = strconv.Atoi(l[1])
+		}
+		out.Time = now.Add(-time.Duration(n) * sinceUnits[l[2]])
+		return out
+	}
+	if c := sinceClock.FindStringSubmatch(lower); c != nil && (c[2] != "" || c[3] != "") {
+		hour, _ := strconv.Atoi(c[1])
+		minute, _ := strconv.Atoi(c[2])
+		switch {
+		case hour > 23 || minute > 59 || c[3] != "" && (hour == 0 || hour > 12):
+			return out
+		case c[3] == "pm" && hour < 12:
+			hour += 12
+		case c[3] == "am" && hour == 12:
+			hour = 0
+		}
+		out.Time = midnight.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		if out.Time.After(now) {
+			// "since 5pm" in the morning means yesterday's
+			out.Time = out.Time.AddDate(0, 0, -1)
+		}
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Arguments the listing tools take to order their results, as in "sorted by
+// name", "sorted by connection count" or "largest pools first"
+const (
+	SortBy    = "sort_by"
+	SortOrder = "order"
+)
+
+// Sort keys; connections are read from the device's statistics
+const (
+	SortName        = "name"
+	SortAddress     = "address"
+	SortConnections = "connections"
+	SortMembers     = "members"
+)
+
+// listSortKeys are the keys each listing tool can be sorted by
+var listSortKeys = map[string][]string{
+	ToolListVirtualServers: {SortName, SortAddress, SortConnections},
+	ToolListPools:          {SortName, SortMembers, SortConnections},
+	ToolListNodes:          {SortName, SortAddress, SortConnections},
+}
+
+// sortDefinitions describe a listing tool's sort arguments to the model
+func sortDefinitions(tool string) map[string]jsonschema.Definition {
+	return map[string]jsonschema.Definition{
+		SortBy:    {Type: jsonschema.String, Enum: listSortKeys[tool], Description: "Order the results by this, when the user asks for a sorted list or e.g. the busiest or largest first"},
+		SortOrder: {Type: jsonschema.String, Enum: []string{"asc", "desc"}, Description: "asc or desc; leave out for A to Z by name or address and most first by count"},
+	}
+}
+
+var (
+	sortedBy = regexp.MustCompile(`(?i)\b(?:sort(?:ed)?|order(?:ed)?|rank(?:ed)?)\s+(?:them\s+)?by\s+(?:the\s+|their\s+)?(\w+)`)
+	// sortPhrases are orderings people ask for without "sorted by"
+	sortPhrases = []struct {
+		pattern *regexp.Regexp
+		key     string
+	}{
+		{regexp.MustCompile(`(?i)\b(busiest|most\s+connections|fewest\s+connections|least\s+busy)\b`), SortConnections},
+		{regexp.MustCompile(`(?i)\b(largest|biggest|smallest)\b`), SortMembers},
+		{regexp.MustCompile(`(?i)\balphabetical(ly)?\b`), SortName},
+	}
+	descending = regexp.MustCompile(`(?i)\b(desc(ending)?|reversed?|most|largest|biggest|busiest|highest|z\s*-?\s*to\s*-?\s*a)\b`)
+	ascending  = regexp.MustCompile(`(?i)\b(asc(ending)?|least|fewest|smallest|lowest|a\s*-?\s*to\s*-?\s*z)\b`)
+)
+
+// sortWords maps the word after "sorted by" onto a sort key
+var sortWords = map[string]string{
+	"name": SortName, "names": SortName, "alphabet": SortName,
+	"address": SortAddress, "addresses": SortAddress, "ip": SortAddress, "destination": SortAddress,
+	"connection": SortConnections, "connections": SortConnections, "conns": SortConnections,
+	"traffic": SortConnections, "load": SortConnections,
+	"member": SortMembers, "members": SortMembers, "size": SortMembers,
+}
+
+// ParseSort picks the ordering a listing tool takes out of a query; it is
+// empty when the query asks for none or the tool can't be sorted that way
+func ParseSort(tool, query string) map[string]string {
+	key := ""
+	if m := sortedBy.FindStringSubmatch(query); m != nil {
+		key = sortWords[strings.ToLower(m[1])]
+	}
+	for _, p := range sortPhrases {
+		if key == "" && p.pattern.MatchString(query) {
+			key = p.key
+		}
+	}
+	if key == "" || !slices.Contains(listSortKeys[tool], key) {
+		return map[string]string{}
+	}
+	out := map[string]string{SortBy: key}
+	if descending.MatchString(query) {
+		out[SortOrder] = "desc"
+	} else if ascending.MatchString(query) {
+		out[SortOrder] = "asc"
+	}
+	return out
+}
+
+// SortKeys returns the keys a listing tool can be sorted by
+func SortKeys(tool string) []string { return listSortKeys[tool] }
@@ -0,0 +1,266 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"f5chat/config"
+)
+
+// SpendLimitError is returned instead of calling a hosted LLM once a
+// configured request or token limit has been reached
+type SpendLimitError struct {
+	// Scope is "session" or "daily", Unit "requests" or "tokens"
+	Scope string
+	Unit  string
+	Used  int
+	Limit int
+}
+
+func (e *SpendLimitError) Error() string {
+	reset := "start a new session"
+	if e.Scope == "daily" {
+		reset = "wait until tomorrow"
+	}
+	unit := strings.TrimSuffix(e.Unit, "s")
+	return fmt.Sprintf("the %s LLM %s limit has been reached (%d of %d); %s, raise LLM_%s_%s_LIMIT, or restart with -ignore-spend-limits",
+		e.Scope, unit, e.Used, e.Limit, reset, strings.ToUpper(e.Scope), strings.ToUpper(unit))
+}
+
+// usage counts LLM requests and tokens
+type usage struct {
+	Day      string `json:"day,omitempty"`
+	Requests int    `json:"requests"`
+	Tokens   int    `json:"tokens"`
+}
+
+// Spend enforces per-session and per-day limits on hosted LLM use. Daily
+// usage is kept in a file so it carries across sessions. Limits of 0 are
+// unlimited; with override set usage is still counted but never refused.
+type Spend struct {
+	sessionRequests, sessionTokens int
+	dailyRequests, dailyTokens     int
+	override                       bool
+	file                           string
+
+	mu      sync.Mutex
+	session usage
+	daily   usage
+	now     func() time.Time
+}
+
+// NewSpend builds the spend limiter from the configuration, loading today's
+// usage so far
+func NewSpend(cfg *config.Config) *Spend {
+	s := &Spend{
+		sessionRequests: cfg.LLMSessionRequestLimit,
+		sessionTokens:   cfg.LLMSessionTokenLimit,
+		dailyRequests:   cfg.LLMDailyRequestLimit,
+		dailyTokens:     cfg.LLMDailyTokenLimit,
+		override:        cfg.IgnoreSpendLimits,
+		file:            cfg.LLMUsageFile,
+		now:             time.Now,
+	}
+	if s.file != "" {
+		if data, err := os.ReadFile(s.file); err == nil {
+			if err := json.Unmarshal(data, &s.daily); err != nil {
+				slog.Warn("Ignoring unreadable LLM usage file", "file", s.file, "err", err)
+			}
+		}
+	}
+	s.rollover()
+	return s
+}
+
+// rollover starts a new daily count at midnight; callers hold s.mu or own s
+func (s *Spend) rollover() {
+	if today := s.now().Format("2006-01-02"); s.daily.Day != today {
+		s.daily = usage{Day: today}
+	}
+}
+
+// Allow returns a *SpendLimitError when another request would exceed a limit
+func (s *Spend) Allow() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollover()
+
+	checks := []SpendLimitError{
+		{Scope: "session", Unit: "requests", Used: s.session.Requests, Limit: s.sessionRequests},
+		{Scope: "session", Unit: "tokens", Used: s.session.Tokens, Limit: s.sessionTokens},
+		{Scope: "daily", Unit: "requests", Used: s.daily.Requests, Limit: s.dailyRequests},
+		{Scope: "daily", Unit: "tokens", Used: s.daily.Tokens, Limit: s.dailyTokens},
+	}
+	for _, c := range checks {
+		if c.Limit > 0 && c.Used >= c.Limit {
+			if s.override {
+				slog.Debug("LLM spend limit overridden", "scope", c.Scope, "unit", c.Unit, "used", c.Used, "limit", c.Limit)
+				continue
+			}
+			slog.Warn("LLM spend limit reached", "scope", c.Scope, "unit", c.Unit, "used", c.Used, "limit", c.Limit)
+			err := c
+			return &err
+		}
+	}
+	return nil
+}
+
+// Record adds a completed request and the tokens it used. The daily count
+// is added to the file rather than written over it, so that sessions
+// running at the same time all count.
+func (s *Spend) Record(tokens int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollover()
+	s.session.Requests++
+	s.session.Tokens += tokens
+	s.daily.Requests++
+	s.daily.Tokens += tokens
+	if s.file == "" {
+		return
+	}
+	daily, err := s.save(1, tokens)
+	if err != nil {
+		slog.Warn("Failed to save LLM usage", "file", s.file, "err", err)
+		return
+	}
+	s.daily = daily
+}
+
+// usageLockWait is how long Record waits for another session to finish
+// with the usage file, and usageLockStale the age at which a lock left by a
+// session that died holding it is broken
+const (
+	usageLockWait  = 2 * time.Second
+	usageLockStale = 10 * time.Second
+)
+
+// save adds to the day's usage in the file while holding its lock, and
+// returns the new totals. The file is replaced by renaming a temporary one
+// over it, so a reader never sees it half written.
+func (s *Spend) save(requests, tokens int) (usage, error) {
+	dir := filepath.Dir(s.file)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return usage{}, err
+	}
+	unlock, err := lockFile(s.file + ".lock")
+	if err != nil {
+		return usage{}, err
+	}
+	defer unlock()
+
+	var daily usage
+	data, err := os.ReadFile(s.file)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &daily); err != nil {
+			slog.Warn("Replacing unreadable LLM usage file", "file", s.file, "err", err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return usage{}, err
+	}
+	if today := s.now().Format("2006-01-02"); daily.Day != today {
+		daily = usage{Day: today}
+	}
+	daily.Requests += requests
+	daily.Tokens += tokens
+
+	if data, err = json.Marshal(daily); err != nil {
+		return usage{}, err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.file)+".*.tmp")
+	if err != nil {
+		return usage{}, err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return usage{}, err
+	}
+	return daily, nil
+}
+
+// lockFile takes an exclusive lock by creating path, waiting while another
+// process holds it; the func returned releases it
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(usageLockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > usageLockStale {
+			slog.Warn("Breaking a stale LLM usage lock", "file", path, "since", info.ModTime().Format(time.RFC3339))
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another session", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Report summarises usage against the limits
+func (s *Spend) Report() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollover()
+	limit := func(n int) string {
+		if n <= 0 {
+			return "no limit"
+		}
+		return fmt.Sprintf("limit %d", n)
+	}
+	report := fmt.Sprintf("This session: %d request(s) (%s), %d token(s) (%s)\nToday: %d request(s) (%s), %d token(s) (%s)",
+		s.session.Requests, limit(s.sessionRequests), s.session.Tokens, limit(s.sessionTokens),
+		s.daily.Requests, limit(s.dailyRequests), s.daily.Tokens, limit(s.dailyTokens))
+	if s.override {
+		report += "\nLimits are overridden (-ignore-spend-limits)."
+	}
+	return report
+}
+
+// SpendReport returns the usage report of the hosted providers in a chain
+func SpendReport(p Provider) (string, bool) {
+	switch v := p.(type) {
+	case *OpenAIClient:
+		if v.spend == nil {
+			return "", false
+		}
+		return v.spend.Report(), true
+	case *Redactor:
+		return SpendReport(v.next)
+	case *Fallback:
+		// The providers share one Spend, so the first report covers both
+		if report, ok := SpendReport(v.primary); ok {
+			return report, true
+		}
+		return SpendReport(v.secondary)
+	}
+	return "", false
+}
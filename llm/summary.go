@@ -0,0 +1,19 @@
+package llm
+
+import "regexp"
+
+// summaryQuery matches requests for the health dashboard: "give me a health
+// summary", "how is the BIG-IP doing?", "is everything OK?". "health
+// check" and /health check chatf5's own setup instead.
+var summaryQuery = regexp.MustCompile(`(?i)\bhealth\s+(?:summary|dashboard|overview|status|report)\b|` +
+	`\b(?:status|on-?call)\s+(?:summary|dashboard|overview)\b|\bdashboard\b|\boverall\s+(?:health|status)\b|` +
+	`\bhow\s+(?:healthy\s+is|is|are)\s+(?:the|this|my|our)\s+(?:device|big-?ip|box|f5|load\s+balancer)s?(?:\s+doing)?\b|` +
+	`\bis\s+everything\s+(?:ok|okay|healthy|fine|alright|all\s+right|up)\b`)
+
+// ParseHealthSummary recognises a request for the health dashboard
+func ParseHealthSummary(query string) (*ToolCall, bool) {
+	if !summaryQuery.MatchString(query) {
+		return nil, false
+	}
+	return &ToolCall{Name: ToolHealthSummary, Args: map[string]string{}}, true
+}
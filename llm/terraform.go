@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// terraformQuery matches requests for Terraform of the device's
+	// objects: "export vs_app1 as Terraform", "generate HCL for web_pool",
+	// "export the virtual servers to main.tf"
+	terraformQuery = regexp.MustCompile(`(?i)\b(?:export|convert|generate|write|turn|migrate|dump|give\s+me|show(?:\s+me)?)\b.*\b(?:terraform|hcl)\b|` +
+		`\b(?:as|to|in|into)\s+(?:a\s+)?(?:terraform|hcl)\b|^\s*(?:terraform|hcl)\s+(?:for|of)\b|` +
+		`\b(?:export|convert|write|dump)\b.*\.tf["'` + "`" + `]?[\s.!]*$`)
+	// terraformName is the object named: "export vs_app1 ...", "HCL for
+	// pool web_pool"
+	terraformName = regexp.MustCompile(`(?i)\b(?:(?:export|convert|turn|migrate|dump)\s+(?:the\s+)?|(?:terraform|hcl)\s+(?:for|of)\s+(?:the\s+)?)` +
+		`(?:(?:virtual\s+server|vs|vip|pool)\s+)?["'` + "`" + `]?([\w./-]+)`)
+	// terraformFile is the .tf file to write: "to main.tf"
+	terraformFile = regexp.MustCompile(`(?i)\b(?:to|into|in|as)\s+(?:(?:a\s+)?file\s+)?["'` + "`" + `]?([^\s"'` + "`" + `]+\.tf)\b`)
+)
+
+// terraformAll are the words naming no object in particular
+var terraformAll = map[string]bool{
+	"all": true, "every": true, "everything": true, "this": true, "that": true, "it": true,
+	"config": true, "configuration": true, "virtual": true, "servers": true, "vs": true, "vip": true, "vips": true,
+	"pools": true, "objects": true, "as": true, "to": true, "terraform": true, "hcl": true,
+}
+
+// ParseExportTerraform recognises a request for Terraform of the device's
+// objects, with the virtual server or pool and the file it names, if any
+func ParseExportTerraform(query string) (*ToolCall, bool) {
+	if !terraformQuery.MatchString(query) {
+		return nil, false
+	}
+	args := map[string]string{}
+	if m := terraformName.FindStringSubmatch(query); m != nil && !terraformAll[strings.ToLower(m[1])] && !strings.HasSuffix(strings.ToLower(m[1]), ".tf") {
+		args["name"] = m[1]
+	}
+	if m := terraformFile.FindStringSubmatch(query); m != nil {
+		args["file"] = m[1]
+	}
+	return &ToolCall{Name: ToolExportTerraform, Args: args}, true
+}
@@ -0,0 +1,366 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+
+	"f5chat/prompt"
+)
+
+// Tools the model can call to answer a query from the device
+const (
+	ToolListVirtualServers = "list_virtual_servers"
+	ToolListPools          = "list_pools"
+	ToolGetPool            = "get_pool"
+	ToolListNodes          = "list_nodes"
+	ToolListWAFPolicies    = "list_waf_policies"
+	ToolGetWAFPolicy       = "get_waf_policy"
+	ToolGenerateIRule      = "generate_irule"
+	ToolExplainIRule       = "explain_irule"
+	ToolGenerateAS3        = "generate_as3"
+	ToolCompare            = "compare_objects"
+	ToolTroubleshoot       = "troubleshoot"
+	ToolSecurityPosture    = "security_posture"
+	ToolWAFViolations      = "waf_violations"
+	ToolOWASPCompliance    = "owasp_compliance"
+	ToolGTMTopology        = "gtm_topology"
+	ToolGetAddressList     = "get_address_list"
+	ToolGetPortList        = "get_port_list"
+	ToolAddToFirewallList  = "add_to_firewall_list"
+	ToolChangeJournal      = "change_journal"
+	ToolHealthSummary      = "health_summary"
+	ToolExportTerraform    = "export_terraform"
+	ToolGenerateAnsible    = "generate_ansible"
+	ToolGenerateQkview     = "generate_qkview"
+	ToolUploadQkview       = "upload_qkview"
+	// ToolUploadIRule, ToolDeployAS3 and ToolUpdateFirewallList are never
+	// offered to the model: they only run when the user confirms a
+	// generated iRule or declaration, or entries to add to a firewall list
+	ToolUploadIRule        = "upload_irule"
+	ToolDeployAS3          = "deploy_as3"
+	ToolUpdateFirewallList = "update_firewall_list"
+)
+
+// nameParam is the schema for tools that take a single object name
+func nameParam(description string) jsonschema.Definition {
+	return jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"name": {Type: jsonschema.String, Description: description},
+		},
+		Required: []string{"name"},
+	}
+}
+
+var noParams = jsonschema.Definition{Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{}}
+
+// toolPrompts maps each tool onto the prompt template for its operation
+var toolPrompts = map[string]string{
+	ToolListVirtualServers: prompt.VirtualServers,
+	ToolListPools:          prompt.Pools,
+	ToolGetPool:            prompt.Pools,
+	ToolListNodes:          prompt.Nodes,
+	ToolListWAFPolicies:    prompt.WAFPolicies,
+	ToolGetWAFPolicy:       prompt.WAFPolicies,
+}
+
+// buildTools returns the tool definitions with each operation's prompt
+// template appended to its description, so edited templates steer the
+// model's choice
+func buildTools(prompts *prompt.Set) []openai.Tool {
+	out := make([]openai.Tool, len(tools))
+	for i, t := range tools {
+		fn := *t.Function
+		if tmpl := prompts.Template(toolPrompts[fn.Name]); tmpl != "" {
+			fn.Description += "\n\n" + tmpl
+		}
+		out[i] = openai.Tool{Type: t.Type, Function: &fn}
+	}
+	return out
+}
+
+// tools describes the BIG-IP operations offered to the model. None of them
+// change the device; see ToolUploadIRule and ToolDeployAS3.
+var tools = []openai.Tool{
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolListVirtualServers,
+		Description: "List the virtual servers (VIPs) with their destination, pool and status, optionally only those in a state or network, sorted or counted",
+		Parameters:  listParams(ToolListVirtualServers),
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolListPools,
+		Description: "List the server pools with their load balancing mode, monitor and members, optionally only those with or without members or with a number of them, sorted or counted",
+		Parameters:  listParams(ToolListPools),
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGetPool,
+		Description: "Show a single server pool and its members",
+		Parameters:  nameParam("Pool name, e.g. web_pool, or full path such as /Common/web_pool"),
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolListNodes,
+		Description: "List the backend nodes (servers) with their address and status, optionally only those in a state or network, sorted or counted",
+		Parameters:  listParams(ToolListNodes),
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolListWAFPolicies,
+		Description: "List all WAF (ASM) security policies and the virtual servers they are applied to",
+		Parameters: jsonschema.Definition{Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{
+			Detail: detailDefinition,
+		}},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGetWAFPolicy,
+		Description: "Show the details of a single WAF (ASM) policy",
+		Parameters:  nameParam("Policy name exactly as the user wrote it, e.g. VS_WAF, or full path such as /Common/VS_WAF"),
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGenerateIRule,
+		Description: "Write a new iRule (TCL) from a description of what it should do, e.g. redirect /old-path to /new-path. The iRule is shown to the user, not applied",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"requirement": {Type: jsonschema.String, Description: "What the iRule must do, in the user's words"},
+				"name":        {Type: jsonschema.String, Description: "Short snake_case name for the iRule, e.g. redirect_old_path"},
+			},
+			Required: []string{"requirement"},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolExplainIRule,
+		Description: "Fetch an existing iRule from the device and explain what it does, with potential pitfalls",
+		Parameters:  nameParam("iRule name, e.g. maintenance_page, or full path such as /Common/maintenance_page"),
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGenerateAS3,
+		Description: "Write an AS3 declaration for an application described by the user, e.g. an HTTPS app on 10.0.0.80 with two pool members and an HTTP redirect. The declaration is shown to the user, not deployed",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"description": {Type: jsonschema.String, Description: "The application to declare, in the user's words, including addresses, ports and members"},
+			},
+			Required: []string{"description"},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolCompare,
+		Description: "Compare the configuration of two objects of the same kind field by field, e.g. two virtual servers, pools or HTTP profiles",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"kind":         {Type: jsonschema.String, Enum: CompareKinds, Description: "What the objects are; leave out if the user didn't say"},
+				"first":        {Type: jsonschema.String, Description: "Name or full path of the first object"},
+				"second":       {Type: jsonschema.String, Description: "Name or full path of the second object"},
+				"profile_type": {Type: jsonschema.String, Description: "For profiles, the tmsh profile type, e.g. http, tcp or client-ssl"},
+				CompareStyle:   {Type: jsonschema.String, Enum: []string{CompareUnified}, Description: "unified when the user asks for a unified diff or patch; leave out for a table of the differing fields"},
+			},
+			Required: []string{"first", "second"},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolTroubleshoot,
+		Description: "Find out why an application isn't working, e.g. \"why is my app down?\": checks the virtual server, its pool, the pool members and their monitor, their nodes and recent log lines, and names the root cause",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"name": {Type: jsonschema.String, Description: "Name or full path of the virtual server, or of its pool; leave out to check every virtual server"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolSecurityPosture,
+		Description: "Report the device's security posture, most urgent first: virtual servers without a WAF (ASM) policy, client SSL profiles allowing old TLS versions or weak ciphers, services exposed over plain HTTP, and DoS and bot protection",
+		Parameters:  noParams,
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolWAFViolations,
+		Description: "Triage the requests the WAF (ASM) recently blocked or alerted on: groups them by attack, URL and source, e.g. \"most blocks are SQL injection against /login from 3 addresses\", with recommended actions",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"hours":  {Type: jsonschema.String, Description: "How many hours back to look, as a number, e.g. 24 for \"the last day\"; leave out for the latest requests logged"},
+				"policy": {Type: jsonschema.String, Description: "Name or full path of a WAF policy, to triage only its violations"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolOWASPCompliance,
+		Description: "Score how well WAF (ASM) policies cover the OWASP Top 10, e.g. \"how compliant is policy VS_WAF with OWASP?\": the share of each policy's security controls that comply, and each risk category compliant, partly compliant or not",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"name": {Type: jsonschema.String, Description: "Name or full path of the WAF policy; leave out to score every policy"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGTMTopology,
+		Description: "Show the GTM (BIG-IP DNS) topology records and regions that steer clients by location, and explain where clients in a place resolve to and which record decides it, e.g. \"why do EU clients resolve to DC2?\"",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"location": {Type: jsonschema.String, Description: "Where the clients are: a continent, a country, a GTM region or a client IP address, e.g. \"EU\", \"Germany\", \"emea\", \"10.1.2.3\"; leave out to list the records"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGetAddressList,
+		Description: "Show what an AFM firewall address list holds: addresses, networks and ranges, FQDNs, countries and nested lists, e.g. \"what's in address-list blocked_countries?\"; without a name, list every address list",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"name": {Type: jsonschema.String, Description: "Name or full path of the address list; leave out to list them all"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGetPortList,
+		Description: "Show what an AFM firewall port list holds: ports, port ranges and nested lists, e.g. \"what's in port-list admin_ports?\"; without a name, list every port list",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"name": {Type: jsonschema.String, Description: "Name or full path of the port list; leave out to list them all"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolAddToFirewallList,
+		Description: "Add entries to an existing AFM address list or port list, e.g. \"add 203.0.113.9 to address-list blocked_ips\". Nothing is changed until the user confirms the entries shown.",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"kind":    {Type: jsonschema.String, Enum: []string{"address", "port"}, Description: "Whether it is an address list or a port list"},
+				"name":    {Type: jsonschema.String, Description: "Name or full path of the list"},
+				"entries": {Type: jsonschema.String, Description: "The entries to add, comma-separated: addresses, networks (10.0.0.0/8), ranges (10.1.1.1-10.1.1.9), \"fqdn <name>\" or \"geo <country code>\" for an address list; ports or ranges (8000-8080) for a port list"},
+			},
+			Required: []string{"name", "entries"},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolChangeJournal,
+		Description: "List the changes chatf5 itself has made to devices, such as iRules uploaded and AS3 declarations deployed, with who made them and when, e.g. \"what did this tool change last week?\"",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"since": {Type: jsonschema.String, Description: "The period's start as the user said it, e.g. \"yesterday\", \"last week\", \"3 days ago\"; leave out for every change"},
+				"user":  {Type: jsonschema.String, Description: "Only the changes made by this user"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolHealthSummary,
+		Description: "Summarize the device's health on one screen, e.g. \"give me a health summary\": the device and its CPU, failover and config sync, virtual servers and pool members that are down, errors in the LTM log, and expired or expiring certificates",
+		Parameters:  noParams,
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolExportTerraform,
+		Description: "Export live objects as Terraform for F5's bigip provider, e.g. \"export vs_app1 as Terraform\": a virtual server with its pool, pool members and nodes, or a pool with its members and nodes, with import blocks to bring the existing objects under Terraform",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"name": {Type: jsonschema.String, Description: "Name or full path of the virtual server or pool; leave out to export every virtual server"},
+				"file": {Type: jsonschema.String, Description: "A .tf file to write the Terraform to, if the user named one"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGenerateAnsible,
+		Description: "Write an Ansible playbook of F5 module tasks (bigip_virtual_server, bigip_pool, bigip_pool_member, bigip_node): either from live objects, e.g. \"export vs_app1 as an Ansible playbook\", or for a described change, e.g. \"write a playbook that adds 10.1.20.13:80 to web_pool\"",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"name":        {Type: jsonschema.String, Description: "Name or full path of the virtual server or pool to export as it is configured now; leave out to export every virtual server"},
+				"description": {Type: jsonschema.String, Description: "The change the playbook should make, in the user's words, when they describe one rather than asking for existing objects"},
+				"file":        {Type: jsonschema.String, Description: "A .yml or .yaml file to write the playbook to, if the user named one"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolGenerateQkview,
+		Description: "Generate a qkview (the diagnostic snapshot F5 support asks for), download it, and optionally upload it to F5 iHealth and report the diagnostics it finds",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"file":   {Type: jsonschema.String, Description: "A .qkview file to save it to, if the user named one"},
+				"upload": {Type: jsonschema.String, Description: "\"true\" if the user asked for it to be uploaded to iHealth or analyzed"},
+				"case":   {Type: jsonschema.String, Description: "The F5 support case number to attach it to, if the user gave one"},
+			},
+		},
+	}},
+	{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:        ToolUploadQkview,
+		Description: "Upload a qkview already generated to F5 iHealth and report the diagnostics its heuristics find, e.g. \"upload the qkview to iHealth\"",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"file": {Type: jsonschema.String, Description: "The .qkview file to upload; leave out for the one generated last"},
+				"case": {Type: jsonschema.String, Description: "The F5 support case number to attach it to, if the user gave one"},
+			},
+		},
+	}},
+}
+
+// ToolCall is the operation the model chose, with its decoded arguments
+type ToolCall struct {
+	Name string
+	Args map[string]string
+}
+
+// Arg returns a string argument, or "" when the model didn't supply it
+func (t *ToolCall) Arg(name string) string {
+	return strings.TrimSpace(t.Args[name])
+}
+
+// Reply is the model's answer to a query: either a ToolCall to run against
+// the device or, for questions that need no device data, plain Text
+type Reply struct {
+	ToolCall *ToolCall
+	Text     string
+}
+
+// ProcessWithTools asks the model which BIG-IP operation answers the query.
+// Earlier turns let follow-ups such as "and its pool members?" resolve.
+func (o *OpenAIClient) ProcessWithTools(history []Message, query string) (*Reply, error) {
+	req := o.newRequest(history, query)
+	req.Tools = o.tools
+	resp, err := o.complete(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s API error: empty response", o.name)
+	}
+
+	msg := resp.Choices[0].Message
+	if len(msg.ToolCalls) == 0 {
+		return &Reply{Text: msg.Content}, nil
+	}
+	call, err := decodeToolCall(msg.ToolCalls[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Reply{ToolCall: call}, nil
+}
+
+// decodeToolCall flattens the JSON arguments into strings; every tool takes
+// string parameters only
+func decodeToolCall(tc openai.ToolCall) (*ToolCall, error) {
+	call := &ToolCall{Name: tc.Function.Name, Args: make(map[string]string)}
+	if strings.TrimSpace(tc.Function.Arguments) == "" {
+		return call, nil
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &raw); err != nil {
+		return nil, fmt.Errorf("invalid arguments for %s: %v", tc.Function.Name, err)
+	}
+	for k, v := range raw {
+		if v != nil {
+			call.Args[k] = fmt.Sprint(v)
+		}
+	}
+	return call, nil
+}
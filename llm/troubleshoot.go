@@ -0,0 +1,21 @@
+package llm
+
+import "regexp"
+
+// troubleshootQuery matches requests to find out why an application isn't
+// working: "why is my app down?", "troubleshoot vs_app1", "the site isn't
+// responding". "which nodes are down?" is a listing, not one of these.
+var troubleshootQuery = regexp.MustCompile(`(?i)\btroubleshoot\b|` +
+	`\bwhy\s+(?:is|are|does|do|isn'?t|aren'?t|can'?t|won'?t)\b.*\b(?:down|offline|failing|broken|unavailable|unreachable|timing\s+out|not\s+\w+)\b|` +
+	`\b(?:isn'?t|is\s+not|aren'?t|are\s+not|stopped|not)\s+(?:working|responding|serving|reachable|loading|up)\b|` +
+	`\b(?:app|application|site|website|service)\s+(?:is\s+)?(?:down|offline|broken)\b`)
+
+// ParseTroubleshoot recognises a request to find out why an application
+// isn't working. The virtual server or pool it is about is left for the
+// caller to find among the device's objects.
+func ParseTroubleshoot(query string) (*ToolCall, bool) {
+	if !troubleshootQuery.MatchString(query) {
+		return nil, false
+	}
+	return &ToolCall{Name: ToolTroubleshoot, Args: map[string]string{}}, true
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// violationsQuery matches requests to triage what the WAF has been
+	// blocking: "summarize WAF violations", "what attacks is the WAF
+	// blocking?", "triage ASM events from the last 6 hours". "show WAF
+	// policies" is a listing, and "what is a DoS attack?" a concept.
+	violationsQuery = regexp.MustCompile(`(?i)\b(?:waf|asm|security)\s+(?:violations?|events?|blocks?|alerts?|attacks?|logs?)\b|` +
+		`\b(?:summari[sz]e|triage|analy[sz]e)\b.*\b(?:violations?|attacks?|blocks?|alerts?)\b|` +
+		`\b(?:recent|latest|top)\s+(?:attacks?|violations?)\b|` +
+		`\b(?:attacks?|violations?)\b.*\b(?:blocked|blocking|seen|seeing|hitting|against\s+/)|` +
+		`\bblocked\s+requests?\b|` +
+		`\b(?:waf|asm)\s+(?:is\s+|has\s+been\s+)?(?:blocking|blocked|alerting)\s*(?:[?.!]|$|\s+(?:today|now|lately|recently|in\s+the|from|requests?|attacks?))`)
+	// violationsWindow is the time the request covers: "last 6 hours", "the
+	// past day", "in the last hour"
+	violationsWindow = regexp.MustCompile(`(?i)\b(?:last|past)\s+(\d+\s*)?(hour|hr|day)s?\b`)
+	// violationsPolicy names the WAF policy, "policy VS_WAF", and
+	// violationsPolicyBefore the same in "the VS_WAF policy"
+	violationsPolicy       = regexp.MustCompile(`(?i)\bpolicy\s+["'` + "`" + `]?([\w/.~-]+)`)
+	violationsPolicyBefore = regexp.MustCompile(`(?i)([\w/.~-]+)["'` + "`" + `]?\s+policy\b`)
+)
+
+// ParseWAFViolations recognises a request to summarize the requests the WAF
+// blocked or alerted on, with the hours it covers and the policy it is
+// about when the query gives them
+func ParseWAFViolations(query string) (*ToolCall, bool) {
+	if !violationsQuery.MatchString(query) {
+		return nil, false
+	}
+	args := map[string]string{}
+	if m := violationsWindow.FindStringSubmatch(query); m != nil {
+		n := 1
+		if m[1] != "" {
+			n, _ = strconv.Atoi(strings.TrimSpace(m[1]))
+		}
+		if strings.EqualFold(m[2], "day") {
+			n *= 24
+		}
+		if n > 0 {
+			args["hours"] = strconv.Itoa(n)
+		}
+	}
+	if policy := policyNamed(query); policy != "" {
+		args["policy"] = policy
+	}
+	return &ToolCall{Name: ToolWAFViolations, Args: args}, true
+}
+
+// policyNamed is the WAF policy a query names, "policy VS_WAF" or "the
+// VS_WAF policy", or "" if it names none
+func policyNamed(query string) string {
+	for _, pattern := range []*regexp.Regexp{violationsPolicy, violationsPolicyBefore} {
+		m := pattern.FindStringSubmatch(query)
+		if m == nil {
+			continue
+		}
+		switch strings.ToLower(m[1]) {
+		case "waf", "asm", "security", "the", "a", "each", "every", "any", "which", "that", "in", "on", "for", "by", "from":
+			continue
+		}
+		return m[1]
+	}
+	return ""
+}
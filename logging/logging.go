@@ -0,0 +1,77 @@
+// Package logging configures the process-wide slog logger. Diagnostics go to
+// a log file by default so the interactive chat on the terminal stays quiet.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"f5chat/config"
+)
+
+// Stderr is the LOG_FILE value that sends logs to the terminal instead of a file
+const Stderr = "stderr"
+
+// ParseLevel converts debug, info, warn or error into a slog level
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+// Setup installs the default slog logger described by the config and
+// returns a function that closes the log file, which is rotated as it
+// grows. Output from the standard log package, including go-bigip's, is
+// routed through the same handler.
+func Setup(cfg *config.Config) (func() error, error) {
+	level, err := ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = os.Stderr
+	closeFn := func() error { return nil }
+	if cfg.LogFile != "" && cfg.LogFile != Stderr {
+		f, err := openRotating(cfg.LogFile, int64(cfg.LogMaxSizeMB)<<20, cfg.LogMaxFiles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		out, closeFn = f, f.Close
+	} else if cfg.Quiet && level < slog.LevelError {
+		// Only errors may interrupt a quiet chat
+		level = slog.LevelError
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.LogFormat) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, opts)
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		closeFn()
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", cfg.LogFormat)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	log.SetFlags(0)
+	return closeFn, nil
+}
+
+// Discard silences all logging, for tools that only want their own output
+func Discard() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1})))
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// rotateRetry is how long a file that couldn't be rotated is written to
+// before rotating it is tried again
+const rotateRetry = time.Minute
+
+// rotatingFile appends to a log file, moving it aside once it grows past
+// maxSize: chatf5.log becomes chatf5.log.1, the old chatf5.log.1 becomes
+// chatf5.log.2, and so on, the oldest beyond keep being removed
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	f       *os.File
+	size    int64
+	// failed is when rotating last failed, zero once it has succeeded
+	failed time.Time
+}
+
+// openRotating opens path for appending; maxSize 0 never rotates
+func openRotating(path string, maxSize int64, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize && time.Since(r.failed) >= rotateRetry {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the full file rather than losing the record,
+			// without trying again for every one that follows
+			fmt.Fprintf(os.Stderr, "Failed to rotate %s: %v\n", r.path, err)
+			r.failed = time.Now()
+		} else {
+			r.failed = time.Time{}
+		}
+	}
+	if r.f == nil {
+		// It couldn't be reopened after rotating; try again for this record
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the earlier files up by one and starts a new one. Whatever
+// fails, the file is reopened, the full one if it couldn't be moved aside;
+// if even that fails, r.f is left nil for Write to try again.
+func (r *rotatingFile) rotate() error {
+	err := r.f.Close()
+	r.f = nil
+	if err == nil {
+		err = r.shift()
+	}
+	return errors.Join(err, r.open())
+}
+
+// shift moves the closed file aside as path.1, or empties it when no
+// earlier files are kept
+func (r *rotatingFile) shift() error {
+	if r.keep <= 0 {
+		return os.Truncate(r.path, 0)
+	}
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.keep))
+	for n := r.keep - 1; n > 0; n-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, n), fmt.Sprintf("%s.%d", r.path, n+1))
+	}
+	return os.Rename(r.path, r.path+".1")
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	return r.f.Close()
+}
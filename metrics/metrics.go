@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// APIRequests counts iControl REST call attempts by operation and outcome
+	APIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chatf5",
+		Subsystem: "bigip",
+		Name:      "requests_total",
+		Help:      "iControl REST call attempts by operation and result.",
+	}, []string{"operation", "result"})
+
+	// APIErrors counts failed iControl REST calls by operation and error class
+	APIErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chatf5",
+		Subsystem: "bigip",
+		Name:      "errors_total",
+		Help:      "Failed iControl REST calls by operation and error class.",
+	}, []string{"operation", "class"})
+
+	// APILatency tracks iControl REST call latency by operation
+	APILatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "chatf5",
+		Subsystem: "bigip",
+		Name:      "request_duration_seconds",
+		Help:      "iControl REST call latency by operation.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation"})
+
+	// CacheLookups counts response cache hits and misses
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chatf5",
+		Subsystem: "bigip",
+		Name:      "cache_lookups_total",
+		Help:      "Response cache lookups by result (hit or miss).",
+	}, []string{"result"})
+
+	// IntentClassifications counts queries routed by the embedding classifier
+	// (hit) versus handed to the LLM (fallback or error)
+	IntentClassifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chatf5",
+		Subsystem: "intent",
+		Name:      "classifications_total",
+		Help:      "Embedding intent classifier outcomes (hit, fallback or error).",
+	}, []string{"result"})
+
+	// GuardrailDecisions counts write requests checked by the guardrail, by
+	// the blast radius they were given and whether they were allowed
+	GuardrailDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chatf5",
+		Subsystem: "guardrail",
+		Name:      "decisions_total",
+		Help:      "Write requests checked by the guardrail, by risk and decision (allowed or blocked).",
+	}, []string{"risk", "decision"})
+
+	// Throttled counts REST calls that had to queue behind the rate limiter
+	Throttled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "chatf5",
+		Subsystem: "bigip",
+		Name:      "throttled_total",
+		Help:      "iControl REST calls delayed by the client-side rate limiter.",
+	})
+)
+
+// ObserveAPICall records the outcome of a single REST call attempt
+func ObserveAPICall(operation string, started time.Time, errClass string) {
+	APILatency.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+	if errClass == "" {
+		APIRequests.WithLabelValues(operation, "success").Inc()
+		return
+	}
+	APIRequests.WithLabelValues(operation, "error").Inc()
+	APIErrors.WithLabelValues(operation, errClass).Inc()
+}
+
+// Serve exposes /metrics on addr in the background. An empty addr disables
+// the listener.
+func Serve(addr string) {
+	if addr == "" {
+		return
+	}
+	if err := Listen(addr); err != nil {
+		slog.Error("Metrics listener stopped", "err", err)
+	}
+}
+
+// Listen exposes /metrics on addr in the background, returning an error if
+// it can't listen there
+func Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		slog.Info("Serving Prometheus metrics", "addr", addr, "path", "/metrics")
+		if err := http.Serve(listener, mux); err != nil {
+			slog.Error("Metrics listener stopped", "err", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,476 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"f5chat/config"
+)
+
+// emailTimeout bounds each delivery, from connecting to the server to its
+// accepting the message
+const emailTimeout = 30 * time.Second
+
+// EmailSink mails each event through an SMTP server: its message, such as a
+// scheduled report, with the report's tables written as HTML tables or as
+// Markdown, followed by the event's fields. It is registered as "email".
+type EmailSink struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+	markdown bool
+}
+
+// NewEmailSinkFromConfig returns the sink SMTP_HOST and the like configure,
+// nil if SMTP_HOST isn't set
+func NewEmailSinkFromConfig(cfg *config.Config) (*EmailSink, error) {
+	if cfg.SMTPHost == "" {
+		return nil, nil
+	}
+	if cfg.SMTPFrom == "" {
+		return nil, errors.New("SMTP_FROM must be set to send email")
+	}
+	from, err := mail.ParseAddress(cfg.SMTPFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM %q: %v", cfg.SMTPFrom, err)
+	}
+	to, err := ParseEmailAddresses(cfg.NotifyEmailTo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NOTIFY_EMAIL_TO: %v", err)
+	}
+	return &EmailSink{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+		from:     from.Address,
+		to:       to,
+		markdown: cfg.EmailFormat == "markdown",
+	}, nil
+}
+
+// ParseEmailAddresses parses comma-separated addresses, such as
+// "ops@example.com, Lead <lead@example.com>", returning the bare addresses
+func ParseEmailAddresses(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	parsed, err := mail.ParseAddressList(list)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %v", list, err)
+	}
+	addrs := make([]string, len(parsed))
+	for n, a := range parsed {
+		addrs[n] = a.Address
+	}
+	return addrs, nil
+}
+
+// WithRecipients returns a copy of the sink that mails to instead of the
+// configured recipients
+func (e *EmailSink) WithRecipients(to []string) *EmailSink {
+	c := *e
+	c.to = to
+	return &c
+}
+
+// Recipients are the addresses the sink mails to
+func (e *EmailSink) Recipients() []string { return e.to }
+
+func (e *EmailSink) Name() string { return "email" }
+
+func (e *EmailSink) Send(ctx context.Context, event Event) error {
+	if len(e.to) == 0 {
+		return errors.New("no one to email; set NOTIFY_EMAIL_TO")
+	}
+	msg, err := e.message(event)
+	if err != nil {
+		return err
+	}
+	if err := e.deliver(ctx, msg); err != nil {
+		return fmt.Errorf("mail through %s failed: %v", net.JoinHostPort(e.host, strconv.Itoa(e.port)), err)
+	}
+	return nil
+}
+
+// subject names the device and, for alerts, how urgent they are:
+// "[CRITICAL] Virtual server down (bigip1)"
+func subject(event Event) string {
+	s := event.Title
+	if event.Severity > SeverityInfo {
+		s = "[" + strings.ToUpper(event.Severity.String()) + "] " + s
+	}
+	if event.Device != "" {
+		s += " (" + event.Device + ")"
+	}
+	return s
+}
+
+// message writes the whole email, headers and all: plain text and HTML
+// alternatives, or Markdown as plain text
+func (e *EmailSink) message(event Event) ([]byte, error) {
+	var buf bytes.Buffer
+	header := textproto.MIMEHeader{}
+	header.Set("From", e.from)
+	header.Set("To", strings.Join(e.to, ", "))
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", subject(event)))
+	header.Set("Date", event.Time.Format(time.RFC1123Z))
+	header.Set("Message-ID", messageID(e.from))
+	header.Set("MIME-Version", "1.0")
+
+	blocks := parseBlocks(event.Message)
+	fields := sortedFields(event.Fields)
+	footer := "Sent by chatf5"
+	if event.Source != "" {
+		footer += " (" + event.Source + ")"
+	}
+	footer += " at " + event.Time.Format("2006-01-02 15:04 MST")
+
+	if e.markdown {
+		header.Set("Content-Type", `text/plain; charset="utf-8"`)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, header)
+		if err := writeQP(&buf, markdownBody(blocks, fields, footer)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header.Set("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	writeHeader(&buf, header)
+	for _, alt := range []struct{ contentType, body string }{
+		{"text/plain", textBody(event.Message, fields, footer)},
+		{"text/html", htmlBody(blocks, fields, footer)},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alt.contentType + `; charset="utf-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQP(w, alt.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s: %s\r\n", k, header.Get(k))
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQP(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(from string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	domain := "chatf5"
+	if _, d, ok := strings.Cut(from, "@"); ok {
+		domain = d
+	}
+	return fmt.Sprintf("<%x.%d@%s>", b, time.Now().Unix(), domain)
+}
+
+// deliver hands the message to the SMTP server
+func (e *EmailSink) deliver(ctx context.Context, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+	addr := net.JoinHostPort(e.host, strconv.Itoa(e.port))
+	tlsConfig := &tls.Config{ServerName: e.host}
+
+	var conn net.Conn
+	var err error
+	if e.port == 465 {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if e.port != 465 {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if e.username != "" {
+		// PlainAuth refuses to send the password unencrypted, except to
+		// localhost
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("%s: %v", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// block is a part of a text report: a heading, a table or lines of text
+type block struct {
+	heading, subheading string
+	header              []string
+	rows                [][]string
+	lines               []string
+}
+
+var (
+	// blockHeading and blockSubheading are the titles reports use:
+	// "=== SSL Certificates ===", "--- 1. show nodes ---"
+	blockHeading    = regexp.MustCompile(`^=== (.+?) ===$`)
+	blockSubheading = regexp.MustCompile(`^--- (.+?) ---$`)
+	// tableHeaderCell is a column title of a report's table: "DAYS LEFT"
+	tableHeaderCell = regexp.MustCompile(`^[A-Z][A-Z0-9 ()/%#.-]*$`)
+	columnGap       = regexp.MustCompile(`\S+(?: \S+)*`)
+)
+
+// parseBlocks splits a text report into headings, tables and paragraphs. A
+// table is a line of upper-case column titles two or more spaces apart,
+// as the formatters' tabwriters write them, and the lines up to the next
+// blank one, split where the titles start.
+func parseBlocks(text string) []block {
+	var blocks []block
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for n := 0; n < len(lines); n++ {
+		line := strings.TrimRight(lines[n], " \t")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case blockHeading.MatchString(trimmed):
+			blocks = append(blocks, block{heading: blockHeading.FindStringSubmatch(trimmed)[1]})
+			continue
+		case blockSubheading.MatchString(trimmed):
+			blocks = append(blocks, block{subheading: blockSubheading.FindStringSubmatch(trimmed)[1]})
+			continue
+		}
+		if starts := tableColumns(line); starts != nil && n+1 < len(lines) && strings.TrimSpace(lines[n+1]) != "" {
+			t := block{header: splitColumns(line, starts)}
+			for n+1 < len(lines) && strings.TrimSpace(lines[n+1]) != "" {
+				n++
+				t.rows = append(t.rows, splitColumns(lines[n], starts))
+			}
+			blocks = append(blocks, t)
+			continue
+		}
+		if len(blocks) == 0 || blocks[len(blocks)-1].lines == nil || strings.TrimSpace(lines[n-1]) == "" {
+			blocks = append(blocks, block{lines: []string{}})
+		}
+		last := &blocks[len(blocks)-1]
+		last.lines = append(last.lines, line)
+	}
+	return blocks
+}
+
+// tableColumns returns where each column of a table's header line starts,
+// nil if the line isn't one
+func tableColumns(line string) []int {
+	var starts []int
+	for _, loc := range columnGap.FindAllStringIndex(line, -1) {
+		if !tableHeaderCell.MatchString(line[loc[0]:loc[1]]) {
+			return nil
+		}
+		starts = append(starts, len([]rune(line[:loc[0]])))
+	}
+	if len(starts) < 2 {
+		return nil
+	}
+	return starts
+}
+
+// splitColumns cuts a table line where the columns start; the last column
+// takes the rest of the line
+func splitColumns(line string, starts []int) []string {
+	runes := []rune(line)
+	cells := make([]string, len(starts))
+	for n, start := range starts {
+		end := len(runes)
+		if n+1 < len(starts) && starts[n+1] < end {
+			end = starts[n+1]
+		}
+		if start < end {
+			cells[n] = strings.TrimSpace(string(runes[start:end]))
+		}
+	}
+	return cells
+}
+
+type field struct{ name, value string }
+
+func sortedFields(fields map[string]string) []field {
+	var out []field
+	for k, v := range fields {
+		if v != "" {
+			out = append(out, field{k, v})
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].name < out[b].name })
+	return out
+}
+
+func textBody(message string, fields []field, footer string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(message, "\n") + "\n")
+	if len(fields) > 0 {
+		sb.WriteString("\n")
+		for _, f := range fields {
+			fmt.Fprintf(&sb, "%s: %s\n", f.name, f.value)
+		}
+	}
+	sb.WriteString("\n-- \n" + footer + "\n")
+	return sb.String()
+}
+
+func htmlBody(blocks []block, fields []field, footer string) string {
+	var sb strings.Builder
+	esc := html.EscapeString
+	sb.WriteString("<!DOCTYPE html>\n<html><body style=\"font-family: sans-serif;\">\n")
+	table := func(header []string, rows [][]string) {
+		sb.WriteString("<table border=\"1\" cellpadding=\"4\" cellspacing=\"0\" style=\"border-collapse: collapse;\">\n")
+		if header != nil {
+			sb.WriteString("<tr>")
+			for _, h := range header {
+				sb.WriteString("<th align=\"left\">" + esc(h) + "</th>")
+			}
+			sb.WriteString("</tr>\n")
+		}
+		for _, r := range rows {
+			sb.WriteString("<tr>")
+			for _, c := range r {
+				sb.WriteString("<td>" + esc(c) + "</td>")
+			}
+			sb.WriteString("</tr>\n")
+		}
+		sb.WriteString("</table>\n")
+	}
+	for _, b := range blocks {
+		switch {
+		case b.heading != "":
+			sb.WriteString("<h2>" + esc(b.heading) + "</h2>\n")
+		case b.subheading != "":
+			sb.WriteString("<h3>" + esc(b.subheading) + "</h3>\n")
+		case b.header != nil:
+			table(b.header, b.rows)
+		default:
+			escaped := make([]string, len(b.lines))
+			for n, l := range b.lines {
+				escaped[n] = esc(l)
+			}
+			sb.WriteString("<p style=\"white-space: pre-wrap;\">" + strings.Join(escaped, "<br>\n") + "</p>\n")
+		}
+	}
+	if len(fields) > 0 {
+		rows := make([][]string, len(fields))
+		for n, f := range fields {
+			rows[n] = []string{f.name, f.value}
+		}
+		table(nil, rows)
+	}
+	sb.WriteString("<p style=\"color: #666; font-size: small;\">" + esc(footer) + "</p>\n</body></html>\n")
+	return sb.String()
+}
+
+func markdownBody(blocks []block, fields []field, footer string) string {
+	cell := strings.NewReplacer("|", `\|`)
+	row := func(values []string) string {
+		cells := make([]string, len(values))
+		for n, v := range values {
+			cells[n] = cell.Replace(v)
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	var parts []string
+	for _, b := range blocks {
+		switch {
+		case b.heading != "":
+			parts = append(parts, "## "+b.heading)
+		case b.subheading != "":
+			parts = append(parts, "### "+b.subheading)
+		case b.header != nil:
+			lines := []string{row(b.header), "|" + strings.Repeat(" --- |", len(b.header))}
+			for _, r := range b.rows {
+				lines = append(lines, row(r))
+			}
+			parts = append(parts, strings.Join(lines, "\n"))
+		default:
+			// Keep the breaks between lines
+			parts = append(parts, strings.Join(b.lines, "  \n"))
+		}
+	}
+	if len(fields) > 0 {
+		var lines []string
+		for _, f := range fields {
+			lines = append(lines, "- **"+f.name+":** "+f.value)
+		}
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	parts = append(parts, "_"+footer+"_")
+	return strings.Join(parts, "\n\n") + "\n"
+}
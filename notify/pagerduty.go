@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"f5chat/config"
+)
+
+// DefaultPagerDutyURL is the PagerDuty Events API v2 endpoint events are
+// sent to unless PAGERDUTY_EVENTS_URL names another, such as the EU one
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutySeverities maps severities onto those PagerDuty's Events API
+// takes
+var pagerDutySeverities = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+// PagerDutySink triggers a PagerDuty incident for each event through the
+// Events API v2, on the service whose integration has the routing key. The
+// dedup key is the device and the event's key, so a condition that is
+// still there after the router's deduplication window adds to the open
+// incident rather than paging again. It is registered as "pagerduty".
+type PagerDutySink struct {
+	routingKey string
+	url        string
+	client     *http.Client
+}
+
+// NewPagerDutySink returns a sink that triggers incidents with routingKey
+// through the Events API at url
+func NewPagerDutySink(routingKey, url string) *PagerDutySink {
+	return &PagerDutySink{routingKey: routingKey, url: url, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// NewPagerDutySinkFromConfig returns the sink PAGERDUTY_ROUTING_KEY
+// configures, nil if it isn't set
+func NewPagerDutySinkFromConfig(cfg *config.Config) (*PagerDutySink, error) {
+	if cfg.PagerDutyRoutingKey == "" {
+		return nil, nil
+	}
+	// Integration keys are 32 characters of letters and digits
+	if len(cfg.PagerDutyRoutingKey) != 32 || strings.Trim(cfg.PagerDutyRoutingKey, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") != "" {
+		return nil, errors.New("invalid PAGERDUTY_ROUTING_KEY: expected the 32-character integration key of an Events API v2 integration")
+	}
+	target := cfg.PagerDutyURL
+	if target == "" {
+		target = DefaultPagerDutyURL
+	}
+	if u, err := url.Parse(target); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid PAGERDUTY_EVENTS_URL %q (expected https://host/path)", target)
+	}
+	return NewPagerDutySink(cfg.PagerDutyRoutingKey, target), nil
+}
+
+func (p *PagerDutySink) Name() string { return "pagerduty" }
+
+func (p *PagerDutySink) Send(ctx context.Context, event Event) error {
+	source := event.Device
+	if source == "" {
+		source = "chatf5"
+	}
+	summary := event.Title
+	if event.Message != "" {
+		summary += ": " + event.Message
+	}
+	// PagerDuty truncates longer summaries itself, but rejects none
+	if len(summary) > 1024 {
+		summary = summary[:1021] + "..."
+	}
+	details := map[string]string{"key": event.Key}
+	for k, v := range event.Fields {
+		details[k] = v
+	}
+	if event.Source != "" {
+		details["found_by"] = event.Source
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    source + ":" + event.Key,
+		"client":       "chatf5",
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         source,
+			"severity":       pagerDutySeverities[event.Severity],
+			"timestamp":      event.Time.UTC().Format(time.RFC3339),
+			"class":          className(event.Key),
+			"custom_details": details,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatf5")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("POST to %s failed: %v", redactURL(p.url), err)
+	}
+	defer resp.Body.Close()
+	// The Events API answers 202 Accepted; 400 names what was wrong and
+	// 429 means too many events for the routing key
+	if resp.StatusCode >= 300 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 300))
+		return fmt.Errorf("HTTP %d from %s: %s", resp.StatusCode, redactURL(p.url), strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
+
+// className is the kind of condition an event key names, the part before
+// its first colon: "vs_down" of "vs_down:/Common/vs_app1"
+func className(key string) string {
+	class, _, _ := strings.Cut(key, ":")
+	return class
+}
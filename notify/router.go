@@ -0,0 +1,347 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"f5chat/config"
+)
+
+// Severity classifies how urgent a notification is
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "info"
+	}
+}
+
+// ParseSeverity converts a severity name (info, warning, critical) into a Severity
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "info", "":
+		return SeverityInfo, nil
+	case "warning", "warn":
+		return SeverityWarning, nil
+	case "critical", "crit":
+		return SeverityCritical, nil
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q (expected info, warning or critical)", name)
+}
+
+// Event is a single alert produced by watch mode, scheduled checks or any other detector
+type Event struct {
+	// Key identifies the condition being reported (e.g. "vs_down:/Common/vs_app1").
+	// Events sharing a key are deduplicated and can be silenced together.
+	Key      string            `json:"key"`
+	Severity Severity          `json:"-"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Source   string            `json:"source,omitempty"`
+	Device   string            `json:"device,omitempty"`
+	Time     time.Time         `json:"time"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// Sink delivers events to an external system (webhook, email, PagerDuty, ...)
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, event Event) error
+}
+
+// Rule routes events at or above MinSeverity to the named sinks
+type Rule struct {
+	MinSeverity Severity
+	Sinks       []string
+}
+
+// Silence suppresses events whose key matches Match (a path.Match glob) until Until
+type Silence struct {
+	Match  string
+	Until  time.Time
+	Reason string
+}
+
+// Router fans events out to sinks according to severity rules, suppressing
+// duplicates within the dedup window and anything matching an active silence
+type Router struct {
+	mu          sync.Mutex
+	sinks       map[string]Sink
+	rules       []Rule
+	silences    []Silence
+	dedupWindow time.Duration
+	lastSent    map[string]time.Time
+	now         func() time.Time
+}
+
+// NewRouter creates an empty router with the given deduplication window
+func NewRouter(dedupWindow time.Duration) *Router {
+	return &Router{
+		sinks:       make(map[string]Sink),
+		dedupWindow: dedupWindow,
+		lastSent:    make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// Register adds a sink; a later sink with the same name replaces the earlier one
+func (r *Router) Register(sink Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks[sink.Name()] = sink
+}
+
+// AddRule appends a routing rule
+func (r *Router) AddRule(rule Rule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, rule)
+}
+
+// Silence suppresses events matching the key glob for the given duration
+func (r *Router) Silence(match string, d time.Duration, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.silences = append(r.silences, Silence{Match: match, Until: r.now().Add(d), Reason: reason})
+}
+
+// Silences returns the silences that are still active
+func (r *Router) Silences() []Silence {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneSilences()
+	return append([]Silence(nil), r.silences...)
+}
+
+// Sinks returns the names of the registered sinks in sorted order
+func (r *Router) Sinks() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.sinks))
+	for name := range r.sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Notify routes the event to every sink selected by the rules. It returns nil
+// when the event was deduplicated or silenced, and a combined error listing
+// every sink that failed otherwise.
+func (r *Router) Notify(ctx context.Context, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = r.now()
+	}
+	if event.Key == "" {
+		event.Key = event.Title
+	}
+
+	r.mu.Lock()
+	r.pruneSilences()
+	for _, s := range r.silences {
+		if ok, _ := path.Match(s.Match, event.Key); ok {
+			r.mu.Unlock()
+			slog.Debug("Notification silenced", "key", event.Key, "until", s.Until.Format(time.RFC3339), "reason", s.Reason)
+			return nil
+		}
+	}
+	last, sent := r.lastSent[event.Key]
+	if sent && r.dedupWindow > 0 && event.Time.Sub(last) < r.dedupWindow {
+		r.mu.Unlock()
+		slog.Debug("Notification suppressed as duplicate", "key", event.Key, "lastSent", last.Format(time.RFC3339))
+		return nil
+	}
+	targets := r.targets(event.Severity)
+	if len(targets) > 0 {
+		// Claimed before sending so that a concurrent duplicate is suppressed
+		r.lastSent[event.Key] = event.Time
+	}
+	r.mu.Unlock()
+
+	var failures []string
+	for _, sink := range targets {
+		if err := sink.Send(ctx, event); err != nil {
+			slog.Error("Notification sink failed", "sink", sink.Name(), "key", event.Key, "err", err)
+			failures = append(failures, fmt.Sprintf("%s: %v", sink.Name(), err))
+		}
+	}
+	if len(targets) > 0 && len(failures) == len(targets) {
+		// Nothing was delivered, so the next occurrence mustn't be
+		// suppressed as a duplicate of this one
+		r.mu.Lock()
+		if r.lastSent[event.Key].Equal(event.Time) {
+			if sent {
+				r.lastSent[event.Key] = last
+			} else {
+				delete(r.lastSent, event.Key)
+			}
+		}
+		r.mu.Unlock()
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("notification delivery failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// SendTo delivers the event to the named sink alone, whatever its severity
+// and however recently it was sent, for reports that name where they go
+func (r *Router) SendTo(ctx context.Context, name string, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = r.now()
+	}
+	r.mu.Lock()
+	sink, ok := r.sinks[name]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no notification sink named %q", name)
+	}
+	return sink.Send(ctx, event)
+}
+
+// Sink returns the sink registered as name, nil if there is none
+func (r *Router) Sink(name string) Sink {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sinks[name]
+}
+
+// targets resolves the unique set of sinks for a severity; callers must hold r.mu
+func (r *Router) targets(sev Severity) []Sink {
+	seen := make(map[string]bool)
+	var sinks []Sink
+	for _, rule := range r.rules {
+		if sev < rule.MinSeverity {
+			continue
+		}
+		for _, name := range rule.Sinks {
+			sink, ok := r.sinks[name]
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks
+}
+
+// pruneSilences drops expired silences; callers must hold r.mu
+func (r *Router) pruneSilences() {
+	now := r.now()
+	active := r.silences[:0]
+	for _, s := range r.silences {
+		if now.Before(s.Until) {
+			active = append(active, s)
+		}
+	}
+	r.silences = active
+}
+
+// ParseRules parses routing rules of the form "critical=pagerduty,webhook;warning=webhook"
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		sevName, sinkList, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid notification rule %q (expected severity=sink1,sink2)", part)
+		}
+		sev, err := ParseSeverity(sevName)
+		if err != nil {
+			return nil, err
+		}
+		rule := Rule{MinSeverity: sev}
+		for _, name := range strings.Split(sinkList, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				rule.Sinks = append(rule.Sinks, name)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LogSink writes events to the application log; it is always registered as "log"
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Send(ctx context.Context, event Event) error {
+	level := slog.LevelInfo
+	switch event.Severity {
+	case SeverityWarning:
+		level = slog.LevelWarn
+	case SeverityCritical:
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, event.Title, "key", event.Key, "message", event.Message,
+		"source", event.Source, "device", event.Device)
+	return nil
+}
+
+// NewRouterFromConfig builds a router with the log sink, the configured
+// webhooks and, with SMTP_HOST, PAGERDUTY_ROUTING_KEY and SPLUNK_HEC_URL
+// set, the email, PagerDuty and Splunk sinks registered and the configured
+// routing rules applied. Other sinks register themselves on top.
+func NewRouterFromConfig(cfg *config.Config) (*Router, error) {
+	router := NewRouter(cfg.NotifyDedupWindow)
+	router.Register(LogSink{})
+	webhooks, err := ParseWebhooks(cfg.NotifyWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	for _, sink := range webhooks {
+		router.Register(sink)
+	}
+	email, err := NewEmailSinkFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if email != nil {
+		router.Register(email)
+	}
+	pagerDuty, err := NewPagerDutySinkFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if pagerDuty != nil {
+		router.Register(pagerDuty)
+	}
+	splunk, err := NewSplunkSinkFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if splunk != nil {
+		router.Register(splunk)
+	}
+
+	rules, err := ParseRules(cfg.NotifyRoutes)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		router.AddRule(rule)
+	}
+	return router, nil
+}
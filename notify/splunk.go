@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"f5chat/config"
+)
+
+// splunkCollectorPath is where a Splunk HTTP Event Collector takes events
+// in its JSON format
+const splunkCollectorPath = "/services/collector/event"
+
+// Sourcetypes chatf5 forwards to Splunk under, so searches and dashboards
+// can tell alerts, audit entries and WAF summaries apart
+const (
+	SplunkAlert = "chatf5:alert"
+	SplunkAudit = "chatf5:audit"
+	SplunkWAF   = "chatf5:waf"
+)
+
+// SplunkSink forwards to a Splunk HTTP Event Collector: the events routed
+// to it, under the sourcetype chatf5:alert, and whatever else is handed to
+// Forward, such as audit log entries and WAF violation summaries. Events go
+// to the token's default index unless an index is given. It is registered
+// as "splunk".
+type SplunkSink struct {
+	url    string
+	token  string
+	index  string
+	client *http.Client
+}
+
+// NewSplunkSink returns a sink that sends events to the collector at
+// collectorURL, e.g. "https://splunk.example.com:8088", with the HEC token
+func NewSplunkSink(collectorURL, token, index string) *SplunkSink {
+	collectorURL = strings.TrimRight(collectorURL, "/")
+	if u, err := url.Parse(collectorURL); err == nil && u.Path == "" {
+		collectorURL += splunkCollectorPath
+	}
+	return &SplunkSink{url: collectorURL, token: token, index: index, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// NewSplunkSinkFromConfig returns the sink SPLUNK_HEC_URL and
+// SPLUNK_HEC_TOKEN configure, nil if SPLUNK_HEC_URL isn't set
+func NewSplunkSinkFromConfig(cfg *config.Config) (*SplunkSink, error) {
+	if cfg.SplunkHECURL == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(cfg.SplunkHECURL); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid SPLUNK_HEC_URL %q (expected https://host:8088)", cfg.SplunkHECURL)
+	}
+	if cfg.SplunkHECToken == "" {
+		return nil, errors.New("SPLUNK_HEC_URL is set but SPLUNK_HEC_TOKEN isn't: the collector only takes events with an HEC token")
+	}
+	return NewSplunkSink(cfg.SplunkHECURL, cfg.SplunkHECToken, cfg.SplunkIndex), nil
+}
+
+func (s *SplunkSink) Name() string { return "splunk" }
+
+func (s *SplunkSink) Send(ctx context.Context, event Event) error {
+	alert := map[string]interface{}{
+		"key":      event.Key,
+		"class":    className(event.Key),
+		"severity": event.Severity.String(),
+		"title":    event.Title,
+		"message":  event.Message,
+	}
+	if event.Source != "" {
+		alert["found_by"] = event.Source
+	}
+	if len(event.Fields) > 0 {
+		alert["fields"] = event.Fields
+	}
+	return s.Forward(ctx, SplunkAlert, event.Device, event.Time, alert)
+}
+
+// Forward sends event, anything that marshals as a JSON object, to the
+// collector as having happened on host at the given time, under sourcetype
+func (s *SplunkSink) Forward(ctx context.Context, sourcetype, host string, at time.Time, event interface{}) error {
+	if host == "" {
+		host = "chatf5"
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	request := map[string]interface{}{
+		// Seconds since the epoch, to the millisecond
+		"time":       float64(at.UnixMilli()) / 1000,
+		"host":       host,
+		"source":     "chatf5",
+		"sourcetype": sourcetype,
+		"event":      event,
+	}
+	if s.index != "" {
+		request["index"] = s.index
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatf5")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("POST to %s failed: %v", redactURL(s.url), err)
+	}
+	defer resp.Body.Close()
+	// The collector answers {"text":"Success","code":0}, and otherwise
+	// says what was wrong, e.g. {"text":"Invalid token","code":4}
+	if resp.StatusCode >= 300 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 300))
+		var failure struct {
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(reply, &failure) == nil && failure.Text != "" {
+			return fmt.Errorf("HTTP %d from %s: %s", resp.StatusCode, redactURL(s.url), failure.Text)
+		}
+		return fmt.Errorf("HTTP %d from %s: %s", resp.StatusCode, redactURL(s.url), strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webhookTimeout bounds each delivery, so a slow receiver can't hold up
+// watch mode
+const webhookTimeout = 10 * time.Second
+
+// WebhookSink POSTs each event as JSON to a URL: the event's fields, plus
+// "text" summarising it in a line, which Slack and Teams incoming webhooks
+// show as the message
+type WebhookSink struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink returns a sink named name that posts to url
+func NewWebhookSink(name, url string) *WebhookSink {
+	return &WebhookSink{name: name, url: url, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (w *WebhookSink) Name() string { return w.name }
+
+func (w *WebhookSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(struct {
+		Event
+		Severity string `json:"severity"`
+		Text     string `json:"text"`
+	}{event, event.Severity.String(), fmt.Sprintf("[%s] %s: %s", strings.ToUpper(event.Severity.String()), event.Title, event.Message)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatf5")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The error quotes the URL, secret and all
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("POST to %s failed: %v", redactURL(w.url), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("HTTP %d from %s: %s", resp.StatusCode, redactURL(w.url), strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
+
+// redactURL leaves out a webhook URL's path and query, which for most
+// services hold its secret
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "the webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// ParseWebhooks parses webhooks of the form "ops=https://...,chat=https://...";
+// a URL on its own is named "webhook"
+func ParseWebhooks(spec string) ([]*WebhookSink, error) {
+	var sinks []*WebhookSink
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, target := "webhook", part
+		if n, t, ok := strings.Cut(part, "="); ok && !strings.Contains(n, "://") {
+			name, target = strings.TrimSpace(n), strings.TrimSpace(t)
+		}
+		if u, err := url.Parse(target); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("invalid webhook %q (expected name=https://host/path)", part)
+		}
+		if name == "log" || name == "email" || name == "pagerduty" || name == "splunk" {
+			return nil, fmt.Errorf("invalid webhook %q: %q is the %s sink's name", part, name, name)
+		}
+		sinks = append(sinks, NewWebhookSink(name, target))
+	}
+	return sinks, nil
+}
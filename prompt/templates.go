@@ -1,59 +1,151 @@
+// Package prompt holds the system prompt and per-operation templates sent to
+// the LLM. Built-in defaults are embedded; files in a prompt directory
+// override them so the assistant can be tuned without recompiling.
 package prompt
 
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed defaults/*.txt
+var defaults embed.FS
+
+// Prompt names. Each is loaded from <name>.txt in the prompt directory.
 const (
-	WAFPolicyListTemplate = `To list WAF policies and their associated virtual servers, I'll need to:
-1. Query the /mgmt/tm/asm/policies endpoint
-2. Format and display the results with focus on:
-   - Name: The unique identifier of the WAF policy
-   - Status: Current operational status (Active/Inactive)
-   - Virtual Servers: List of virtual servers where this policy is applied
-   - Enforcement Mode: How policy violations are handled (blocking/transparent)`
-
-	VirtualServerListTemplate = `To list virtual servers (VIPs), I'll need to:
-1. Query the /mgmt/tm/ltm/virtual endpoint
-2. Format and display the results including:
-   - Name: The unique identifier of the virtual server
-   - Destination: IP:Port combination where the virtual server listens
-   - Pool: Associated server pool name
-   - Status: Current operational status (enabled/disabled)
-Additional Information:
-- Virtual servers are the primary ingress points for client traffic
-- They distribute incoming connections across backend pool members`
-
-	PoolListTemplate = `To list server pools, I'll need to:
-1. Query the /mgmt/tm/ltm/pool endpoint
-2. Format and display the results including:
-   - Name: The unique identifier of the pool
-   - Members: List of backend servers (nodes) in the pool
-   - Monitor: Health check configuration
-   - Status: Aggregate status of pool members
-Additional Information:
-- Pools manage groups of backend servers
-- They handle load balancing and health monitoring of members`
-
-	NodeListTemplate = `To list backend nodes, I'll need to:
-1. Query the /mgmt/tm/ltm/node endpoint
-2. Format and display the results including:
-   - Name: The unique identifier of the node
-   - Address: IP address of the backend server
-   - Status: Current operational status
-   - Monitor Status: Health check status
-Additional Information:
-- Nodes represent actual backend servers
-- They can be members of multiple pools
-- Monitor status indicates their availability`
+	System         = "system"
+	VirtualServers = "virtual_servers"
+	Pools          = "pools"
+	Nodes          = "nodes"
+	WAFPolicies    = "waf_policies"
+	// Guardrail instructs the second pass that labels the blast radius of
+	// write requests
+	Guardrail = "guardrail"
+	// IRules turns a requirement into an iRule with an explanation
+	IRules = "irules"
+	// ExplainIRule describes an existing iRule and its pitfalls
+	ExplainIRule = "explain_irule"
+	// AS3 turns an application description into an AS3 declaration
+	AS3 = "as3"
+	// Ansible turns a described change into an Ansible playbook
+	Ansible = "ansible"
+	// Tmsh explains a tmsh command and its iControl REST equivalent
+	Tmsh = "tmsh"
+	// Agent steers the multi-step troubleshooting loop
+	Agent = "agent"
+	// Concepts answers general questions about how BIG-IP works
+	Concepts = "concepts"
+	// WAFViolations summarizes recent WAF violations and recommends
+	// actions
+	WAFViolations = "waf_violations"
 )
 
-func GetPromptTemplate(operation string) string {
-	templates := map[string]string{
-		"virtual_servers": VirtualServerListTemplate,
-		"pools":          PoolListTemplate,
-		"nodes":          NodeListTemplate,
-		"waf_policies":   WAFPolicyListTemplate,
+// Set is a loaded collection of prompts
+type Set struct {
+	prompts map[string]string
+}
+
+// Default returns the built-in prompts
+func Default() *Set {
+	s := &Set{prompts: make(map[string]string)}
+	entries, _ := fs.ReadDir(defaults, "defaults")
+	for _, e := range entries {
+		data, err := defaults.ReadFile("defaults/" + e.Name())
+		if err == nil {
+			s.prompts[strings.TrimSuffix(e.Name(), ".txt")] = strings.TrimSpace(string(data))
+		}
+	}
+	return s
+}
+
+// Load returns the built-in prompts with any <name>.txt files in dir
+// replacing them. An empty dir uses the built-ins only.
+func Load(dir string) (*Set, error) {
+	s := Default()
+	if dir == "" {
+		return s, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt directory: %v", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".txt" {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ".txt")
+		if _, known := s.prompts[name]; !known {
+			slog.Warn("Ignoring unknown prompt file", "file", filepath.Join(dir, e.Name()), "known", strings.Join(s.Names(), ", "))
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt %s: %v", e.Name(), err)
+		}
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			return nil, fmt.Errorf("prompt %s is empty", filepath.Join(dir, e.Name()))
+		}
+		s.prompts[name] = text
+		slog.Info("Loaded custom prompt", "name", name, "file", filepath.Join(dir, e.Name()))
 	}
+	return s, nil
+}
 
-	if template, exists := templates[operation]; exists {
-		return template
+// Names returns the prompt names in sorted order
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.prompts))
+	for name := range s.prompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// System returns the system prompt
+func (s *Set) System() string {
+	return s.prompts[System]
+}
+
+// Template returns the template for an operation, or "" if there is none
+func (s *Set) Template(operation string) string {
+	if operation == System {
+		return ""
+	}
+	return s.prompts[operation]
+}
+
+// GetPromptTemplate returns the built-in template for an operation
+func GetPromptTemplate(operation string) string {
+	return Default().Template(operation)
+}
+
+// WriteDefaults writes the built-in prompts to dir as a starting point for
+// customisation. Existing files are left alone.
+func WriteDefaults(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create prompt directory: %v", err)
+	}
+	var written []string
+	for name, text := range Default().prompts {
+		path := filepath.Join(dir, name+".txt")
+		if _, err := os.Stat(path); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return written, err
+		}
+		if err := os.WriteFile(path, []byte(text+"\n"), 0o644); err != nil {
+			return written, fmt.Errorf("failed to write prompt %s: %v", path, err)
+		}
+		written = append(written, path)
 	}
-	return ""
-}
\ No newline at end of file
+	sort.Strings(written)
+	return written, nil
+}
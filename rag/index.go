@@ -0,0 +1,298 @@
+// Package rag retrieves F5 documentation snippets relevant to a question so
+// they can be added to the LLM prompt. It indexes a built-in corpus of
+// BIG-IP and iControl REST notes plus any local documents by embedding.
+package rag
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed corpus/*.md
+var corpus embed.FS
+
+// embedBatchSize keeps each embeddings request well under the API input limit
+const embedBatchSize = 100
+
+// Embedder turns texts into embedding vectors; LLM providers that support
+// embeddings implement it
+type Embedder interface {
+	Embed(texts []string) ([][]float32, error)
+}
+
+// Document is a source file in the corpus
+type Document struct {
+	Source string
+	Text   string
+}
+
+// Chunk is one section of a document with its embedding
+type Chunk struct {
+	Source string
+	Title  string
+	Text   string
+	Vector []float32
+}
+
+// Result is a chunk matched by a search, with its cosine similarity
+type Result struct {
+	Chunk
+	Score float64
+}
+
+// LoadDocuments returns the built-in corpus plus the .md and .txt files in
+// dir, if set. Local files with the same name replace built-in ones.
+func LoadDocuments(dir string) ([]Document, error) {
+	docs := make(map[string]Document)
+	err := fs.WalkDir(corpus, "corpus", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := corpus.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		docs[d.Name()] = Document{Source: d.Name(), Text: string(data)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if ext := strings.ToLower(filepath.Ext(path)); ext != ".md" && ext != ".txt" {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			docs[d.Name()] = Document{Source: d.Name(), Text: string(data)}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read documentation from %s: %v", dir, err)
+		}
+	}
+
+	out := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		out = append(out, doc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out, nil
+}
+
+// chunkDocument splits a document into sections on "## " headings, titling
+// each section "Document title > Section heading"
+func chunkDocument(doc Document) []Chunk {
+	var (
+		chunks  []Chunk
+		docName = strings.TrimSuffix(doc.Source, filepath.Ext(doc.Source))
+		heading string
+		body    strings.Builder
+	)
+	flush := func() {
+		text := strings.TrimSpace(body.String())
+		body.Reset()
+		if text == "" {
+			return
+		}
+		title := docName
+		if heading != "" {
+			title += " > " + heading
+		}
+		chunks = append(chunks, Chunk{Source: doc.Source, Title: title, Text: text})
+	}
+
+	for _, line := range strings.Split(doc.Text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# "):
+			docName = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		case strings.HasPrefix(line, "## "):
+			flush()
+			heading = strings.TrimSpace(strings.TrimPrefix(line, "## "))
+		default:
+			body.WriteString(line)
+			body.WriteString("\n")
+		}
+	}
+	flush()
+	return chunks
+}
+
+// Index holds embedded chunks for similarity search
+type Index struct {
+	chunks []Chunk
+}
+
+// Len returns the number of indexed chunks
+func (ix *Index) Len() int { return len(ix.chunks) }
+
+// BuildIndex chunks and embeds the documents. When cacheFile is set,
+// embeddings are reused from it by content hash and new ones are saved back,
+// so unchanged documents are not embedded again on the next start.
+func BuildIndex(embedder Embedder, docs []Document, cacheFile string) (*Index, error) {
+	var chunks []Chunk
+	for _, doc := range docs {
+		chunks = append(chunks, chunkDocument(doc)...)
+	}
+
+	cache := loadCache(cacheFile)
+	embedded, err := embedChunks(embedder, chunks, cache)
+	if err != nil {
+		return nil, err
+	}
+	// A changed embedding model yields vectors of a different size; start
+	// over without the cache rather than compare incompatible vectors
+	if !sameDims(chunks) {
+		for i := range chunks {
+			chunks[i].Vector = nil
+		}
+		cache = make(map[string][]float32)
+		if embedded, err = embedChunks(embedder, chunks, cache); err != nil {
+			return nil, err
+		}
+	}
+	if embedded > 0 {
+		saveCache(cacheFile, cache, chunks)
+	}
+	slog.Info("Indexed documentation", "chunks", len(chunks), "embedded", embedded)
+	return &Index{chunks: chunks}, nil
+}
+
+// embedChunks fills in vectors from the cache and embeds the rest, adding
+// them to the cache. It returns how many chunks were embedded.
+func embedChunks(embedder Embedder, chunks []Chunk, cache map[string][]float32) (int, error) {
+	var missing []int
+	for i := range chunks {
+		if v, ok := cache[chunkKey(chunks[i])]; ok {
+			chunks[i].Vector = v
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	for start := 0; start < len(missing); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		texts := make([]string, 0, end-start)
+		for _, i := range missing[start:end] {
+			texts = append(texts, chunks[i].Title+"\n"+chunks[i].Text)
+		}
+		vectors, err := embedder.Embed(texts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to embed documentation: %w", err)
+		}
+		if len(vectors) != len(texts) {
+			return 0, fmt.Errorf("failed to embed documentation: got %d embeddings for %d chunks", len(vectors), len(texts))
+		}
+		for j, i := range missing[start:end] {
+			chunks[i].Vector = vectors[j]
+			cache[chunkKey(chunks[i])] = vectors[j]
+		}
+	}
+	return len(missing), nil
+}
+
+func sameDims(chunks []Chunk) bool {
+	for i := 1; i < len(chunks); i++ {
+		if len(chunks[i].Vector) != len(chunks[0].Vector) {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns up to k chunks most similar to the query vector, skipping
+// any below minScore
+func (ix *Index) Search(query []float32, k int, minScore float64) []Result {
+	var results []Result
+	for _, c := range ix.chunks {
+		if score := Cosine(query, c.Vector); score >= minScore {
+			results = append(results, Result{Chunk: c, Score: score})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 when their sizes differ
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func chunkKey(c Chunk) string {
+	sum := sha256.Sum256([]byte(c.Title + "\n" + c.Text))
+	return hex.EncodeToString(sum[:])
+}
+
+func loadCache(path string) map[string][]float32 {
+	cache := make(map[string][]float32)
+	if path == "" {
+		return cache
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to read documentation index cache", "file", path, "err", err)
+		}
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		slog.Warn("Ignoring corrupt documentation index cache", "file", path, "err", err)
+		return make(map[string][]float32)
+	}
+	return cache
+}
+
+// saveCache writes the embeddings of the current chunks, dropping entries
+// for sections that no longer exist
+func saveCache(path string, cache map[string][]float32, chunks []Chunk) {
+	if path == "" {
+		return
+	}
+	current := make(map[string][]float32, len(chunks))
+	for _, c := range chunks {
+		current[chunkKey(c)] = cache[chunkKey(c)]
+	}
+	data, err := json.Marshal(current)
+	if err == nil {
+		err = os.WriteFile(path, data, 0o600)
+	}
+	if err != nil {
+		slog.Warn("Failed to save documentation index cache", "file", path, "err", err)
+	}
+}
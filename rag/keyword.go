@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"sort"
+	"strings"
+)
+
+// stopWords are left out of keyword matching
+var stopWords = map[string]bool{
+	"what": true, "is": true, "are": true, "the": true, "a": true, "an": true,
+	"and": true, "or": true, "of": true, "in": true, "on": true, "to": true,
+	"how": true, "does": true, "do": true, "explain": true, "between": true,
+	"difference": true, "when": true, "should": true, "use": true, "i": true,
+	"it": true, "work": true, "big-ip": true, "bigip": true, "f5": true,
+}
+
+// SearchWords returns up to k sections of docs that share the most words
+// with the query, scored by the fraction of its words they contain. It
+// stands in for Retriever when there is no model to embed with.
+func SearchWords(docs []Document, query string, k int) []Result {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		w = strings.Trim(w, ".,!?;:'\"()")
+		if len(w) > 1 && !stopWords[w] {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	var results []Result
+	for _, doc := range docs {
+		for _, c := range chunkDocument(doc) {
+			text := strings.ToLower(c.Title + "\n" + c.Text)
+			found := 0
+			for _, w := range words {
+				if strings.Contains(text, w) {
+					found++
+				}
+			}
+			if found > 0 {
+				results = append(results, Result{Chunk: c, Score: float64(found) / float64(len(words))})
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
@@ -0,0 +1,83 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"f5chat/config"
+)
+
+// Retriever finds documentation relevant to a question. The index is built
+// on first use so startup stays fast.
+type Retriever struct {
+	embedder  Embedder
+	docsDir   string
+	cacheFile string
+	topK      int
+	minScore  float64
+
+	once  sync.Once
+	index *Index
+	err   error
+}
+
+// NewRetriever creates a retriever from the RAG settings in the config
+func NewRetriever(cfg *config.Config, embedder Embedder) *Retriever {
+	topK := cfg.RAGTopK
+	if topK <= 0 {
+		topK = 3
+	}
+	return &Retriever{
+		embedder:  embedder,
+		docsDir:   cfg.RAGDocsDir,
+		cacheFile: cfg.RAGIndexFile,
+		topK:      topK,
+		minScore:  cfg.RAGMinScore,
+	}
+}
+
+// Retrieve returns the documentation sections most relevant to the query
+func (r *Retriever) Retrieve(query string) ([]Result, error) {
+	r.once.Do(func() {
+		var docs []Document
+		if docs, r.err = LoadDocuments(r.docsDir); r.err == nil {
+			r.index, r.err = BuildIndex(r.embedder, docs, r.cacheFile)
+		}
+	})
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	vectors, err := r.embedder.Embed([]string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	return r.index.Search(vectors[0], r.topK, r.minScore), nil
+}
+
+// FormatContext renders retrieved sections as a prompt block
+func FormatContext(results []Result) string {
+	var sb strings.Builder
+	sb.WriteString("Relevant F5 documentation. Base your answer on it when it applies, and say so if it doesn't cover the question:\n")
+	for _, r := range results {
+		sb.WriteString(fmt.Sprintf("\n[%s] %s\n%s\n", r.Source, r.Title, r.Text))
+	}
+	return sb.String()
+}
+
+// Sources lists the distinct documents behind the results, in rank order
+func Sources(results []Result) []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if !seen[r.Source] {
+			seen[r.Source] = true
+			sources = append(sources, r.Source)
+		}
+	}
+	return sources
+}
@@ -0,0 +1,106 @@
+// Package retry is the policy calls to the BIG-IP and LLM APIs are retried
+// under: how many attempts are made, the exponential backoff between them
+// and which failures are worth another attempt.
+package retry
+
+import (
+	"math/rand"
+	"time"
+
+	"f5chat/config"
+)
+
+// Policy controls how failed calls are retried
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// MaxDelay caps the backoff
+	MaxDelay time.Duration
+	// RetryOn lists the error classes that are retried, for callers that
+	// classify their errors; everything else fails immediately
+	RetryOn []string
+	// Jitter spreads each delay over its upper half, so concurrent clients
+	// don't retry in step
+	Jitter bool
+}
+
+// DefaultBIGIP matches the behaviour the BIG-IP client has always had:
+// three attempts with exponential backoff from 5s capped at 30s. The error
+// classes are the bigip package's.
+var DefaultBIGIP = Policy{
+	MaxAttempts: 3,
+	BaseDelay:   5 * time.Second,
+	MaxDelay:    30 * time.Second,
+	RetryOn:     []string{"connection", "timeout", "server", "parse", "unknown"},
+}
+
+// DefaultLLM rides out brief rate limiting without making an interactive
+// user wait long
+var DefaultLLM = Policy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    20 * time.Second,
+	Jitter:      true,
+}
+
+// BIGIPFromConfig builds the BIG-IP client's policy from the config,
+// falling back to DefaultBIGIP for any value that is not set
+func BIGIPFromConfig(cfg *config.Config) Policy {
+	return DefaultBIGIP.with(cfg.RetryMaxAttempts, cfg.RetryBaseDelay, cfg.RetryMaxDelay, cfg.RetryOn)
+}
+
+// LLMFromConfig builds the LLM clients' policy from the config, falling
+// back to DefaultLLM for any value that is not set
+func LLMFromConfig(cfg *config.Config) Policy {
+	return DefaultLLM.with(cfg.LLMRetryMaxAttempts, cfg.LLMRetryBaseDelay, cfg.LLMRetryMaxDelay, nil)
+}
+
+// with returns the policy with the values that are set in place of its own
+func (p Policy) with(maxAttempts int, baseDelay, maxDelay time.Duration, retryOn []string) Policy {
+	if maxAttempts > 0 {
+		p.MaxAttempts = maxAttempts
+	}
+	if baseDelay > 0 {
+		p.BaseDelay = baseDelay
+	}
+	if maxDelay > 0 {
+		p.MaxDelay = maxDelay
+	}
+	if len(retryOn) > 0 {
+		p.RetryOn = retryOn
+	}
+	return p
+}
+
+// Attempts is the number of attempts to make, at least one
+func (p Policy) Attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// Delay returns the exponential backoff delay before the given attempt (1-based)
+func (p Policy) Delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+	delay := p.BaseDelay * time.Duration(uint(1)<<uint(attempt-2))
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 || !p.Jitter {
+		return max(delay, 0)
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Retryable reports whether errors of the given class should be retried
+func (p Policy) Retryable(class string) bool {
+	for _, c := range p.RetryOn {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Reports are the built-in reports a job can make, besides running a saved
+// query
+var Reports = []string{"inventory", "certs", "down", "alerts", "compliance", "summary"}
+
+// webhookPrefix marks a job's target as a webhook rather than a file
+const webhookPrefix = "webhook:"
+
+// emailTarget marks a job's target as email, to NOTIFY_EMAIL_TO or to the
+// addresses after a colon
+const emailTarget = "email"
+
+// Job is a report made on a schedule
+type Job struct {
+	// Spec is the cron expression as written, Cron the parsed form
+	Spec string
+	Cron *Cron
+	// Report is one of Reports, or "run" for the saved query Saved
+	Report string
+	Saved  string
+	// Target is where the result goes: a file, whose {date} and {time} are
+	// filled in, "webhook:NAME", "email" or "email:ADDRESS,...", or "" for
+	// stdout
+	Target string
+}
+
+// Name describes what the job reports: "certs", "run morning-check"
+func (j Job) Name() string {
+	if j.Report == "run" {
+		return "run " + j.Saved
+	}
+	return j.Report
+}
+
+// Webhook returns the name of the webhook the job sends to, if it does
+func (j Job) Webhook() (string, bool) {
+	return strings.CutPrefix(j.Target, webhookPrefix)
+}
+
+// Email returns the addresses the job emails, "" for the configured ones,
+// if it emails the report
+func (j Job) Email() (string, bool) {
+	if j.Target == emailTarget {
+		return "", true
+	}
+	return strings.CutPrefix(j.Target, emailTarget+":")
+}
+
+// Path returns the file a run at t writes, "" if the job doesn't write one
+func (j Job) Path(t time.Time) string {
+	if _, ok := j.Webhook(); ok || j.Target == "" {
+		return ""
+	}
+	if _, ok := j.Email(); ok {
+		return ""
+	}
+	return strings.NewReplacer("{date}", t.Format("2006-01-02"), "{time}", t.Format("1504")).Replace(j.Target)
+}
+
+// ParseJobs parses jobs separated by semicolons or new lines, each
+// "CRON REPORT [> TARGET]":
+//
+//	0 7 * * mon-fri certs > reports/certs-{date}.txt
+//	*/30 * * * * down > webhook:ops
+//	0 6 * * * inventory > email:ops@example.com,lead@example.com
+//	@weekly run morning-check
+func ParseJobs(spec string) ([]Job, error) {
+	var jobs []Job
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		job, err := parseJob(line)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func parseJob(line string) (Job, error) {
+	what, target, _ := strings.Cut(line, ">")
+	fields := strings.Fields(what)
+	n := 5
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		n = 1
+	}
+	if len(fields) <= n {
+		return Job{}, fmt.Errorf("invalid scheduled report %q: want a cron expression then a report, e.g. \"0 7 * * * certs > certs.txt\"", line)
+	}
+	job := Job{Spec: strings.Join(fields[:n], " "), Target: strings.TrimSpace(target)}
+	var err error
+	if job.Cron, err = ParseCron(job.Spec); err != nil {
+		return Job{}, err
+	}
+	if name, ok := job.Webhook(); ok && name == "" {
+		return Job{}, fmt.Errorf("invalid scheduled report %q: name the webhook, e.g. webhook:ops", line)
+	}
+
+	report := fields[n:]
+	switch {
+	case strings.EqualFold(report[0], "run") && len(report) == 2:
+		job.Report, job.Saved = "run", report[1]
+		return job, nil
+	case len(report) == 1:
+		for _, r := range Reports {
+			if strings.EqualFold(report[0], r) {
+				job.Report = r
+				return job, nil
+			}
+		}
+	}
+	return Job{}, fmt.Errorf("invalid scheduled report %q: the report is one of %s, or \"run NAME\" for a saved query", line, strings.Join(Reports, ", "))
+}
+
+// Run calls run with each job and the time it was due, at the times the
+// jobs' cron expressions give, until stop is closed. Jobs due at the same
+// time run one after another, in the order given.
+func Run(jobs []Job, run func(job Job, at time.Time), stop <-chan struct{}) {
+	now := time.Now()
+	next := make([]time.Time, len(jobs))
+	for n, job := range jobs {
+		next[n] = job.Cron.Next(now)
+	}
+	for {
+		var due time.Time
+		for _, t := range next {
+			if !t.IsZero() && (due.IsZero() || t.Before(due)) {
+				due = t
+			}
+		}
+		if due.IsZero() {
+			slog.Warn("No scheduled report is due again")
+			<-stop
+			return
+		}
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		for n, job := range jobs {
+			if next[n].Equal(due) {
+				run(job, due)
+				next[n] = job.Cron.Next(due)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package script reads batch scripts: queries to run one after another,
+// either one per line or as a YAML list such as
+//
+//	# Daily checks
+//	- show virtual servers
+//	- name: Down nodes
+//	  query: which nodes are down
+//
+// The list may also sit under a top-level "queries:" key.
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Step is a query of a script and the name its answer is labelled with
+type Step struct {
+	Name  string
+	Query string
+}
+
+// Label is the step's name, or its query when it has none
+func (s Step) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Query
+}
+
+// Parse reads a script. It is read as YAML when it starts with a list item
+// or a "queries:" key, and otherwise as one query per line. Blank lines and
+// lines starting with "#" are skipped either way.
+func Parse(data []byte) ([]Step, error) {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimRight(sc.Text(), " \t\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") || trimmed == "queries:" {
+			return parseYAML(data)
+		}
+		break
+	}
+
+	var steps []Step
+	for _, line := range lines {
+		if q := strings.TrimSpace(line); q != "" && !strings.HasPrefix(q, "#") {
+			steps = append(steps, Step{Query: q})
+		}
+	}
+	return steps, nil
+}
+
+// parseYAML reads the YAML form: a list whose items are a query or a map
+// with "query" and optionally "name"
+func parseYAML(data []byte) ([]Step, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	list := doc.Content[0]
+	if list.Kind == yaml.MappingNode {
+		if len(list.Content) != 2 || list.Content[0].Value != "queries" {
+			return nil, fmt.Errorf("line %d: expected \"queries:\" and a list of queries", list.Line)
+		}
+		list = list.Content[1]
+	}
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		return nil, nil
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: expected a list item (\"- query\")", list.Line)
+	}
+
+	var steps []Step
+	for _, item := range list.Content {
+		switch item.Kind {
+		case yaml.ScalarNode:
+			if item.Value == "" {
+				return nil, fmt.Errorf("line %d: the item has no query", item.Line)
+			}
+			steps = append(steps, Step{Query: item.Value})
+			continue
+		case yaml.MappingNode:
+		default:
+			return nil, fmt.Errorf("line %d: expected a query, or a name and a query", item.Line)
+		}
+		var step Step
+		for n := 0; n+1 < len(item.Content); n += 2 {
+			key, value := item.Content[n], item.Content[n+1]
+			if value.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: the %s is a list or mapping, not text", value.Line, key.Value)
+			}
+			switch key.Value {
+			case "name":
+				step.Name = value.Value
+			case "query":
+				step.Query = value.Value
+			default:
+				return nil, fmt.Errorf("line %d: unknown field %q; items have a name and a query", key.Line, key.Value)
+			}
+		}
+		if step.Query == "" {
+			return nil, fmt.Errorf("line %d: the item has no query", item.Line)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
@@ -0,0 +1,178 @@
+// Package snapshot keeps copies of the device's object lists, taken when
+// the user asks, in a file of the user's so that later questions such as
+// "what changed since this morning" can be answered by comparing them with
+// the device as it is now.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// maxSnapshots is how many snapshots are kept of each device; the oldest
+// are dropped
+const maxSnapshots = 50
+
+// snapshotName is what a snapshot may be called: "before-upgrade", "am.1"
+var snapshotName = regexp.MustCompile(`^[A-Za-z0-9][\w.-]*$`)
+
+// Objects are the objects of one kind by full path, each as its flattened
+// configuration fields (see utils.Flatten)
+type Objects map[string]map[string]string
+
+// Snapshot is the object lists read from the device at one time. Kinds
+// that couldn't be read, such as WAF policies without ASM, are left out.
+type Snapshot struct {
+	Name   string             `json:"name,omitempty"`
+	Device string             `json:"device,omitempty"`
+	Taken  time.Time          `json:"taken"`
+	Kinds  map[string]Objects `json:"kinds"`
+}
+
+// Label names a snapshot in messages: its name if it has one, and when it
+// was taken
+func (s *Snapshot) Label() string {
+	taken := s.Taken.Local().Format("Mon 2 Jan 15:04")
+	if s.Name == "" {
+		return "the snapshot from " + taken
+	}
+	return fmt.Sprintf("snapshot %s (%s)", s.Name, taken)
+}
+
+// Store holds the snapshots taken so far, oldest first. The file may hold
+// snapshots of several devices; a store only shows and adds its own.
+type Store struct {
+	mu     sync.Mutex
+	file   string
+	device string
+	// snapshots are this device's, others those of other devices, kept as
+	// they were
+	snapshots []Snapshot
+	others    []Snapshot
+}
+
+// Open loads the snapshots of device, the BIG-IP's host, from file. An
+// empty file name keeps them in memory only; a missing file starts with
+// none.
+func Open(file, device string) (*Store, error) {
+	s := &Store{file: file, device: device}
+	if file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []Snapshot
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("unreadable snapshots in %s: %w", file, err)
+	}
+	for _, snap := range all {
+		if snap.Device == device {
+			s.snapshots = append(s.snapshots, snap)
+		} else {
+			s.others = append(s.others, snap)
+		}
+	}
+	return s, nil
+}
+
+// ValidName reports whether name can be used for a snapshot
+func ValidName(name string) bool {
+	return snapshotName.MatchString(name)
+}
+
+// Add keeps a snapshot of the store's device, replacing an earlier one with
+// the same name
+func (s *Store) Add(snap Snapshot) error {
+	if snap.Name != "" && !ValidName(snap.Name) {
+		return fmt.Errorf("'%s' can't be used as a name; use letters, digits, dots, dashes and underscores", snap.Name)
+	}
+	snap.Device = s.device
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snap.Name != "" {
+		kept := s.snapshots[:0]
+		for _, earlier := range s.snapshots {
+			if earlier.Name != snap.Name {
+				kept = append(kept, earlier)
+			}
+		}
+		s.snapshots = kept
+	}
+	s.snapshots = append(s.snapshots, snap)
+	if len(s.snapshots) > maxSnapshots {
+		s.snapshots = append([]Snapshot(nil), s.snapshots[len(s.snapshots)-maxSnapshots:]...)
+	}
+	return s.write()
+}
+
+// List returns the snapshots, oldest first
+func (s *Store) List() []Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Snapshot(nil), s.snapshots...)
+}
+
+// Named returns the snapshot with this name
+func (s *Store) Named(name string) (Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, snap := range s.snapshots {
+		if snap.Name == name {
+			return snap, true
+		}
+	}
+	return Snapshot{}, false
+}
+
+// Latest returns the most recent snapshot
+func (s *Store) Latest() (Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return s.snapshots[len(s.snapshots)-1], true
+}
+
+// Since returns the first snapshot taken at or after t, so "since this
+// morning" compares with the morning's first one. Without one it returns
+// the last taken before t, with after false; ok is false when there are no
+// snapshots at all.
+func (s *Store) Since(t time.Time) (snap Snapshot, after, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, snap = range s.snapshots {
+		if !snap.Taken.Before(t) {
+			return snap, true, true
+		}
+	}
+	if len(s.snapshots) == 0 {
+		return Snapshot{}, false, false
+	}
+	return s.snapshots[len(s.snapshots)-1], false, true
+}
+
+// write saves the snapshots to the file; callers hold s.mu
+func (s *Store) write() error {
+	if s.file == "" {
+		return nil
+	}
+	data, err := json.Marshal(append(append([]Snapshot(nil), s.others...), s.snapshots...))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.file, append(data, '\n'), 0o600)
+}
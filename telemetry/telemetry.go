@@ -0,0 +1,408 @@
+// Package telemetry traces where the time of a query goes, as OpenTelemetry
+// spans: the query, the operation it runs, each request to the LLM and to
+// the BIG-IP, and the formatting of the answer. Each query's spans are
+// exported to an OTLP collector over HTTP, as JSON, once it's answered.
+//
+// Spans nest in the order they're started: a span started while another is
+// open is its child, and requests made through Transport are children of
+// the innermost open span. Requests made while no span is open, such as
+// the exporter's, aren't traced.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"f5chat/config"
+)
+
+// exportTimeout bounds each export, and flushTimeout how long exiting waits
+// for the last ones
+const (
+	exportTimeout = 10 * time.Second
+	flushTimeout  = 5 * time.Second
+)
+
+// Span kinds, as OTLP numbers them
+const (
+	kindInternal = 1
+	kindClient   = 3
+)
+
+// Span statuses, as OTLP numbers them
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+var (
+	mu sync.Mutex
+	// exp is where spans go; nil while tracing is off
+	exp *exporter
+	// open is the innermost span that hasn't ended
+	open *Span
+	// ended is the spans of the trace in progress that have ended
+	ended []*Span
+	// exports counts the exports still being sent
+	exports sync.WaitGroup
+)
+
+// Span is a timed step of a query. A nil *Span, which Start returns while
+// tracing is off, ignores everything done with it.
+type Span struct {
+	traceID [16]byte
+	spanID  [8]byte
+	parent  *Span
+	name    string
+	kind    int
+	start   time.Time
+	end     time.Time
+	attrs   map[string]interface{}
+	keys    []string
+	failed  bool
+	failure string
+}
+
+// exporter posts spans to an OTLP/HTTP traces endpoint
+type exporter struct {
+	url     string
+	headers map[string]string
+	service string
+	client  *http.Client
+}
+
+// Setup turns tracing on when OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT names a collector. The returned func
+// waits for the spans still being exported; call it before exiting.
+func Setup(cfg *config.Config) (func(), error) {
+	e, err := newExporter(cfg)
+	if err != nil || e == nil {
+		return func() {}, err
+	}
+	mu.Lock()
+	exp = e
+	mu.Unlock()
+	slog.Debug("Tracing queries", "endpoint", e.url, "service", e.service)
+	return flush, nil
+}
+
+// Check reports what's wrong with the tracing settings, without turning
+// tracing on
+func Check(cfg *config.Config) error {
+	_, err := newExporter(cfg)
+	return err
+}
+
+// newExporter returns the exporter the settings configure, nil if they
+// name no collector
+func newExporter(cfg *config.Config) (*exporter, error) {
+	target := cfg.OTelTracesEndpoint
+	if target == "" && cfg.OTelEndpoint != "" {
+		target = strings.TrimRight(cfg.OTelEndpoint, "/") + "/v1/traces"
+	}
+	if target == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(target); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q (expected http://host:4318)", target)
+	}
+	switch strings.ToLower(cfg.OTelProtocol) {
+	case "", "http/json":
+	default:
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL=%s isn't supported: spans are sent as http/json, which collectors take on the same port as http/protobuf (4318)", cfg.OTelProtocol)
+	}
+	headers, err := parseHeaders(cfg.OTelHeaders)
+	if err != nil {
+		return nil, err
+	}
+	service := cfg.OTelServiceName
+	if service == "" {
+		service = "chatf5"
+	}
+	return &exporter{url: target, headers: headers, service: service, client: &http.Client{Timeout: exportTimeout}}, nil
+}
+
+// parseHeaders reads OTEL_EXPORTER_OTLP_HEADERS: "key=value,key=value",
+// with the values URL-encoded
+func parseHeaders(spec string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS entry %q (expected key=value)", part)
+		}
+		if decoded, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = decoded
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// flush waits, for a while, for the exports still being sent
+func flush() {
+	done := make(chan struct{})
+	go func() {
+		exports.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(flushTimeout):
+		slog.Warn("Gave up waiting for traces to be exported", "after", flushTimeout)
+	}
+}
+
+// Start opens a span named name, the child of the innermost open span or,
+// without one, the root of a new trace. It's nil while tracing is off.
+func Start(name string) *Span {
+	mu.Lock()
+	defer mu.Unlock()
+	if exp == nil {
+		return nil
+	}
+	s := newSpan(name, kindInternal, open)
+	open = s
+	return s
+}
+
+// child opens a span under the innermost open span without nesting later
+// spans under it, nil if there's no open span to put it under
+func child(name string, kind int) *Span {
+	mu.Lock()
+	defer mu.Unlock()
+	if exp == nil || open == nil {
+		return nil
+	}
+	return newSpan(name, kind, open)
+}
+
+// newSpan makes a span under parent; callers must hold mu
+func newSpan(name string, kind int, parent *Span) *Span {
+	s := &Span{parent: parent, name: name, kind: kind, start: time.Now(), attrs: map[string]interface{}{}}
+	if parent != nil {
+		s.traceID = parent.traceID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return s
+}
+
+// Set records an attribute of the span: a string, an int or a bool
+func (s *Span) Set(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := s.attrs[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.attrs[key] = value
+}
+
+// Fail marks the span as failed with err, if there is one
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s.failed, s.failure = true, err.Error()
+}
+
+// End closes the span. Ending the root of a trace exports the trace; a
+// span that ends after its root is dropped.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	if open == s {
+		open = s.parent
+	}
+	root := s
+	for root.parent != nil {
+		root = root.parent
+	}
+	if root != s {
+		if root.end.IsZero() {
+			ended = append(ended, s)
+		}
+		return
+	}
+
+	if open != nil && open.traceID == s.traceID {
+		open = nil
+	}
+	trace, rest := []*Span{s}, ended[:0]
+	for _, span := range ended {
+		if span.traceID == s.traceID {
+			trace = append(trace, span)
+		} else {
+			rest = append(rest, span)
+		}
+	}
+	ended = rest
+	e := exp
+	if e == nil {
+		return
+	}
+	body, err := e.encode(trace)
+	if err != nil {
+		slog.Warn("Failed to encode a trace", "spans", len(trace), "err", err)
+		return
+	}
+	exports.Add(1)
+	go func() {
+		defer exports.Done()
+		if err := e.send(body); err != nil {
+			slog.Warn("Failed to export a trace", "spans", len(trace), "err", err)
+		}
+	}()
+}
+
+// Transport traces each request made through next while a span is open,
+// as a client span of service, such as "BIG-IP" or "OpenAI"
+func Transport(next http.RoundTripper, service string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next, service: service}
+}
+
+type transport struct {
+	next    http.RoundTripper
+	service string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := child(req.Method+" "+req.URL.Path, kindClient)
+	if span == nil {
+		return t.next.RoundTrip(req)
+	}
+	target := *req.URL
+	target.User = nil
+	span.Set("peer.service", t.service)
+	span.Set("http.request.method", req.Method)
+	span.Set("server.address", req.URL.Hostname())
+	span.Set("url.full", target.String())
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.Fail(err)
+	} else {
+		span.Set("http.response.status_code", resp.StatusCode)
+		if resp.StatusCode >= 400 {
+			span.Fail(errors.New(resp.Status))
+		}
+	}
+	span.End()
+	return resp, err
+}
+
+// encode lays the spans of a trace out as an OTLP ExportTraceServiceRequest
+// in JSON; callers must hold mu
+func (e *exporter) encode(trace []*Span) ([]byte, error) {
+	spans := make([]map[string]interface{}, len(trace))
+	for n, s := range trace {
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        attributes(s.keys, s.attrs),
+			"status":            map[string]interface{}{"code": statusUnset},
+		}
+		if s.parent != nil {
+			span["parentSpanId"] = hex.EncodeToString(s.parent.spanID[:])
+		}
+		if s.failed {
+			span["status"] = map[string]interface{}{"code": statusError, "message": s.failure}
+		}
+		spans[n] = span
+	}
+	return json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attributes([]string{"service.name"}, map[string]interface{}{"service.name": e.service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "f5chat"},
+				"spans": spans,
+			}},
+		}},
+	})
+}
+
+// attributes writes attributes as OTLP key-values, in the order they were
+// set
+func attributes(keys []string, values map[string]interface{}) []interface{} {
+	list := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := values[key].(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		list = append(list, map[string]interface{}{"key": key, "value": value})
+	}
+	return list
+}
+
+// send posts an encoded trace to the collector
+func (e *exporter) send(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatf5")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("POST to %s failed: %v", e.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 300))
+		return fmt.Errorf("HTTP %d from %s: %s", resp.StatusCode, e.url, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"f5chat/notify"
+)
+
+// FormatAlerts lists alerts most severe first
+func FormatAlerts(events []notify.Event) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== Alerts ===\n")
+	if len(events) == 0 {
+		sb.WriteString("\nNo virtual servers down, certificates expiring or sync problems.\n")
+		return sb.String()
+	}
+
+	sorted := make([]notify.Event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a].Severity > sorted[b].Severity })
+
+	sb.WriteString("\n")
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tALERT\tDETAILS")
+	for _, e := range sorted {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Severity, e.Title, e.Message)
+	}
+	w.Flush()
+	fmt.Fprintf(&sb, "\n%d alert(s)\n", len(events))
+	return sb.String()
+}
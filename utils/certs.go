@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// FormatCertificates lists certificates soonest to expire first, each
+// valid, expiring (within warnDays of now) or expired
+func FormatCertificates(certs []Certificate, now time.Time, warnDays int) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== SSL Certificates ===\n")
+	if len(certs) == 0 {
+		sb.WriteString("\nNo certificates are installed.\n")
+		return sb.String()
+	}
+
+	sorted := make([]Certificate, len(certs))
+	copy(sorted, certs)
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a].ExpirationDate < sorted[b].ExpirationDate })
+
+	expired, expiring := 0, 0
+	sb.WriteString("\n")
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSUBJECT\tEXPIRES\tDAYS LEFT\tSTATUS")
+	for _, c := range sorted {
+		expires, days, status := "-", "-", "unknown"
+		if t := c.Expires(); !t.IsZero() {
+			left := int(math.Round(t.Sub(now).Hours() / 24))
+			expires, days = t.UTC().Format("2006-01-02"), fmt.Sprint(left)
+			switch {
+			case !t.After(now):
+				status = "expired"
+				expired++
+			case left < warnDays:
+				status = "expiring"
+				expiring++
+			default:
+				status = "valid"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.FullPath, orNone(c.Subject), expires, days, status)
+	}
+	w.Flush()
+
+	sb.WriteString(fmt.Sprintf("\n%d certificate(s): %d expired, %d expiring within %d days.\n", len(certs), expired, expiring, warnDays))
+	return sb.String()
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ANSI escapes for highlighting answers on a terminal
+const (
+	colorReset = "\033[0m"
+	colorBold  = "\033[1m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorAmber = "\033[33m"
+)
+
+var (
+	// colorField is a field whose value is a status: "State:   up",
+	// "Enforcement Mode: blocking"
+	colorField = regexp.MustCompile(`(?m)^(\s*(?:Status|State|Availability|Enforcement Mode|Signature Mode)\s*:\s+)([\w-]+)`)
+	// colorColumn is a status in the last column of a compact listing:
+	// "web2  10.1.20.12  down"
+	colorColumn = regexp.MustCompile(`(?m)(  )([\w-]+)$`)
+	// colorBadge is a check's result, "[PASS] Reachability", a finding's
+	// severity, "[HIGH] WAF", or what the WAF did, "[BLOCKED] 32"
+	colorBadge = regexp.MustCompile(`\[(PASS|OK|WARN|SKIP|FAIL|HIGH|MEDIUM|LOW|BLOCKED|ALERTED)\]`)
+	// colorHeading is a title: "=== Server Pools ==="
+	colorHeading = regexp.MustCompile(`(?m)^=== .+ ===$`)
+	// colorDiff is a unified diff (see FormatUnified), from its "---" and
+	// "+++" lines to its last hunk
+	colorDiff = regexp.MustCompile(`(?m)^--- .*\n\+\+\+ .*\n(?:[-+ @].*(?:\n|$))*`)
+)
+
+// statusColors are the colors of status values: green when all is well,
+// amber when it's unknown or only partly so, red when down
+var statusColors = map[string]string{
+	"up": colorGreen, "enabled": colorGreen, "active": colorGreen, "available": colorGreen,
+	"blocking": colorGreen, "production": colorGreen,
+	"unknown": colorAmber, "unchecked": colorAmber, "checking": colorAmber, "inactive": colorAmber,
+	"transparent": colorAmber, "staging": colorAmber, "user-down": colorAmber,
+	"valid": colorGreen, "expiring": colorAmber, "expired": colorRed,
+	"down": colorRed, "disabled": colorRed, "offline": colorRed, "forced-offline": colorRed,
+}
+
+// badgeColors are the colors of check results, severities and WAF actions.
+// Like "blocking", a block is the WAF doing its job; an alert let the
+// request through.
+var badgeColors = map[string]string{
+	"PASS": colorGreen, "OK": colorGreen, "WARN": colorAmber, "SKIP": colorAmber, "FAIL": colorRed,
+	"HIGH": colorRed, "MEDIUM": colorAmber, "LOW": colorAmber,
+	"BLOCKED": colorGreen, "ALERTED": colorAmber,
+}
+
+// Colorize highlights a text answer for a terminal: statuses of objects in
+// green, amber or red, check results likewise, diffs' removed and added
+// lines in red and green, and headings in bold
+func Colorize(text string) string {
+	text = colorField.ReplaceAllStringFunc(text, func(field string) string {
+		m := colorField.FindStringSubmatch(field)
+		color, ok := statusColors[strings.ToLower(m[2])]
+		if !ok {
+			return field
+		}
+		return m[1] + color + m[2] + colorReset
+	})
+	text = colorColumn.ReplaceAllStringFunc(text, func(column string) string {
+		m := colorColumn.FindStringSubmatch(column)
+		color, ok := statusColors[strings.ToLower(m[2])]
+		if !ok {
+			return column
+		}
+		return m[1] + color + m[2] + colorReset
+	})
+	text = colorBadge.ReplaceAllStringFunc(text, func(badge string) string {
+		return badgeColors[strings.Trim(badge, "[]")] + badge + colorReset
+	})
+	text = colorDiff.ReplaceAllStringFunc(text, colorizeDiff)
+	return colorHeading.ReplaceAllStringFunc(text, func(heading string) string {
+		return colorBold + heading + colorReset
+	})
+}
+
+// colorizeDiff colors a unified diff's lines: removed ones red, added ones
+// green and hunk headers amber, with the file names in bold
+func colorizeDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+	for n, line := range lines {
+		color := ""
+		switch {
+		case n < 2:
+			color = colorBold
+		case strings.HasPrefix(line, "@@"):
+			color = colorAmber
+		case strings.HasPrefix(line, "-"):
+			color = colorRed
+		case strings.HasPrefix(line, "+"):
+			color = colorGreen
+		}
+		if color != "" {
+			lines[n] = color + line + colorReset
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// compactHint ends a compact listing, saying how to see every field
+const compactHint = "\nAsk for them \"in detail\" (or use /verbose on) to see every field.\n"
+
+// compactTable writes rows as aligned columns under a header. The status,
+// if any, is the last column, where Colorize finds it.
+func compactTable(sb *strings.Builder, header []string, rows [][]string) {
+	sb.WriteString("\n")
+	w := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	sb.WriteString(compactHint)
+}
+
+// orNone shows an unset value in a column
+func orNone(s string) string {
+	if s = strings.TrimSpace(s); s == "" {
+		return "-"
+	}
+	return s
+}
+
+// FormatVirtualServersCompact lists virtual servers one per line
+func FormatVirtualServersCompact(vs []VirtualServer) string {
+	if len(vs) == 0 {
+		return FormatVirtualServers(vs)
+	}
+	var sb strings.Builder
+	sb.WriteString("\n=== Virtual Servers (VIPs) ===\n")
+	compactTable(&sb, virtualServerColumns, virtualServerRows(vs))
+	return sb.String()
+}
+
+var virtualServerColumns = []string{"NAME", "DESTINATION", "POOL", "STATUS"}
+
+func virtualServerRows(vs []VirtualServer) [][]string {
+	rows := make([][]string, len(vs))
+	for n, v := range vs {
+		status := "enabled"
+		if !v.Enabled {
+			status = "disabled"
+		}
+		rows[n] = []string{v.Name, orNone(v.Destination), orNone(v.Pool), status}
+	}
+	return rows
+}
+
+// FormatPoolsCompact lists pools one per line with their member counts
+func FormatPoolsCompact(pools []Pool, poolMembers map[string][]PoolMember) string {
+	if len(pools) == 0 {
+		return FormatPools(pools, poolMembers)
+	}
+	var sb strings.Builder
+	sb.WriteString("\n=== Server Pools ===\n")
+	compactTable(&sb, poolColumns, poolRows(pools, poolMembers))
+	return sb.String()
+}
+
+var poolColumns = []string{"NAME", "LOAD BALANCING", "MONITOR", "MEMBERS"}
+
+func poolRows(pools []Pool, poolMembers map[string][]PoolMember) [][]string {
+	rows := make([][]string, len(pools))
+	for n, p := range pools {
+		rows[n] = []string{p.Name, orNone(p.LoadBalancingMode), orNone(p.Monitor), fmt.Sprint(len(poolMembers[p.FullPath]))}
+	}
+	return rows
+}
+
+// FormatNodesCompact lists nodes one per line
+func FormatNodesCompact(nodes []Node) string {
+	if len(nodes) == 0 {
+		return FormatNodes(nodes)
+	}
+	var sb strings.Builder
+	sb.WriteString("\n=== Backend Nodes ===\n")
+	compactTable(&sb, nodeColumns, nodeRows(nodes))
+	return sb.String()
+}
+
+var nodeColumns = []string{"NAME", "ADDRESS", "STATE"}
+
+func nodeRows(nodes []Node) [][]string {
+	rows := make([][]string, len(nodes))
+	for n, node := range nodes {
+		rows[n] = []string{node.Name, orNone(node.Address), orNone(node.State)}
+	}
+	return rows
+}
+
+// FormatWAFPoliciesCompact lists WAF policies one per line with the
+// virtual servers they're applied to
+func FormatWAFPoliciesCompact(policies []*WAFPolicy) string {
+	if len(policies) == 0 {
+		return FormatWAFPolicies(policies)
+	}
+	var sb strings.Builder
+	sb.WriteString("\n=== WAF (Web Application Firewall) Policies ===\n")
+	rows := make([][]string, len(policies))
+	for n, p := range policies {
+		active := "inactive"
+		if p.Active {
+			active = "active"
+		}
+		rows[n] = []string{p.Name, orNone(strings.Join(p.VirtualServers, ", ")), active, orNone(p.EnforcementMode)}
+	}
+	compactTable(&sb, []string{"NAME", "VIRTUAL SERVERS", "STATUS", "ENFORCEMENT"}, rows)
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldDiff is one configuration field that differs between two objects;
+// Left or Right is empty when only one of them sets the field
+type FieldDiff struct {
+	Field string `json:"field"`
+	Left  string `json:"first,omitempty"`
+	Right string `json:"second,omitempty"`
+}
+
+// diffIgnored are fields that identify or version an object rather than
+// configure it, so they always differ
+var diffIgnored = map[string]bool{
+	"name": true, "fullPath": true, "selfLink": true, "generation": true,
+	"kind": true, "id": true, "link": true, "isSubcollection": true,
+}
+
+// Flatten turns a struct or map into field paths and values, via its JSON
+// form so the iControl REST property names are used: nested objects become
+// "parent.child", lists of objects "list[0].field", and lists of plain
+// values one sorted, comma-separated value
+func Flatten(v interface{}) (map[string]string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	flattenInto(fields, "", generic)
+	return fields, nil
+}
+
+func flattenInto(fields map[string]string, prefix string, v interface{}) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if diffIgnored[key] {
+				continue
+			}
+			flattenInto(fields, join(key), value)
+		}
+	case []interface{}:
+		plain := make([]string, 0, len(t))
+		for i, item := range t {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				flattenInto(fields, fmt.Sprintf("%s[%d]", prefix, i), item)
+			default:
+				plain = append(plain, fmt.Sprint(item))
+			}
+		}
+		if len(plain) > 0 {
+			sort.Strings(plain)
+			fields[prefix] = strings.Join(plain, ", ")
+		}
+	case nil:
+		// An unset field is the same as a missing one
+	default:
+		fields[prefix] = strings.TrimSpace(fmt.Sprint(t))
+	}
+}
+
+// Diff compares two objects of the same kind field by field. It returns the
+// fields that differ, sorted by name, and how many are the same.
+func Diff(left, right interface{}) ([]FieldDiff, int, error) {
+	l, err := Flatten(left)
+	if err != nil {
+		return nil, 0, err
+	}
+	r, err := Flatten(right)
+	if err != nil {
+		return nil, 0, err
+	}
+	var diffs []FieldDiff
+	same := 0
+	for field, lv := range l {
+		if rv, ok := r[field]; ok && rv == lv {
+			same++
+		} else {
+			diffs = append(diffs, FieldDiff{Field: field, Left: lv, Right: r[field]})
+		}
+	}
+	for field, rv := range r {
+		if _, ok := l[field]; !ok {
+			diffs = append(diffs, FieldDiff{Field: field, Right: rv})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs, same, nil
+}
+
+// maxDiffValue caps each value in the comparison table
+const maxDiffValue = 40
+
+// FormatComparison shows the differing fields of two objects side by side
+func FormatComparison(kind, leftName, rightName string, diffs []FieldDiff, same int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n=== Comparing %s %s and %s ===\n", kind, leftName, rightName))
+	if len(diffs) == 0 {
+		sb.WriteString(fmt.Sprintf("\nNo configuration differences (%d fields compared).\n", same))
+		return sb.String()
+	}
+
+	rows := make([][3]string, len(diffs))
+	for n, d := range diffs {
+		rows[n] = [3]string{d.Field, d.Left, d.Right}
+	}
+	sb.WriteString("\n")
+	sb.WriteString(FormatSideBySide([3]string{"Field", leftName, rightName}, rows, maxDiffValue))
+	sb.WriteString(fmt.Sprintf("\n%d field(s) differ, %d match.\n", len(diffs), same))
+	return sb.String()
+}
+
+// FormatComparisonUnified shows the fields of two objects as a unified
+// diff, each field a "field: value" line
+func FormatComparisonUnified(kind, leftName, rightName string, left, right map[string]string, diffs []FieldDiff, same int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n=== Comparing %s %s and %s ===\n", kind, leftName, rightName))
+	if len(diffs) == 0 {
+		sb.WriteString(fmt.Sprintf("\nNo configuration differences (%d fields compared).\n", same))
+		return sb.String()
+	}
+	sb.WriteString("\n")
+	sb.WriteString(FormatUnified(leftName, rightName, FieldLines(left), FieldLines(right), 2))
+	sb.WriteString(fmt.Sprintf("\n%d field(s) differ, %d match.\n", len(diffs), same))
+	return sb.String()
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// DownObject is an object that isn't serving traffic
+type DownObject struct {
+	Kind   string
+	Name   string
+	Status string
+}
+
+// FormatDownObjects lists the objects that are down, in the order given
+func FormatDownObjects(down []DownObject) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== Objects Down ===\n")
+	if len(down) == 0 {
+		sb.WriteString("\nEverything enabled is up.\n")
+		return sb.String()
+	}
+
+	sb.WriteString("\n")
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tSTATUS")
+	for _, d := range down {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Kind, d.Name, d.Status)
+	}
+	w.Flush()
+	fmt.Fprintf(&sb, "\n%d object(s) down\n", len(down))
+	return sb.String()
+}
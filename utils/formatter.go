@@ -5,20 +5,25 @@ import (
 	"strings"
 
 	"f5chat/bigip"
+	"f5chat/compliance"
+	"f5chat/config"
 )
 
 // Type aliases for bigip package types
 type (
 	VirtualServer = bigip.VirtualServer
-	Pool         = bigip.Pool
-	Node         = bigip.Node
-	WAFPolicy    = bigip.WAFPolicy
+	Pool          = bigip.Pool
+	PoolMember    = bigip.PoolMember
+	Node          = bigip.Node
+	WAFPolicy     = bigip.WAFPolicy
+	HealthCheck   = bigip.HealthCheck
+	Certificate   = bigip.Certificate
 )
 
 func FormatVirtualServers(vs []VirtualServer) string {
 	var sb strings.Builder
 	sb.WriteString("\n=== Virtual Servers (VIPs) ===\n")
-	
+
 	if len(vs) == 0 {
 		sb.WriteString("\nNo virtual servers are currently configured.\n")
 		return sb.String()
@@ -44,7 +49,7 @@ func FormatVirtualServers(vs []VirtualServer) string {
 	return sb.String()
 }
 
-func FormatPools(pools []Pool, poolMembers map[string][]string) string {
+func FormatPools(pools []Pool, poolMembers map[string][]PoolMember) string {
 	var sb strings.Builder
 	sb.WriteString("\n=== Server Pools ===\n")
 
@@ -59,16 +64,16 @@ func FormatPools(pools []Pool, poolMembers map[string][]string) string {
 		sb.WriteString(fmt.Sprintf("Name:         %s\n", p.Name))
 		sb.WriteString(fmt.Sprintf("Load Balance: %s\n", p.LoadBalancingMode))
 		sb.WriteString(fmt.Sprintf("Monitor:      %s\n", p.Monitor))
-		
+
 		sb.WriteString("\nPool Members:\n")
-		if members, ok := poolMembers[p.Name]; ok && len(members) > 0 {
+		if members, ok := poolMembers[p.FullPath]; ok && len(members) > 0 {
 			for j, m := range members {
-				sb.WriteString(fmt.Sprintf("  %d. %s\n", j+1, m))
+				sb.WriteString(fmt.Sprintf("  %d. %s\n", j+1, m.FullPath))
 			}
 		} else {
 			sb.WriteString("  No members configured\n")
 		}
-		
+
 		if p.Description != "" {
 			sb.WriteString(fmt.Sprintf("\nDescription: %s\n", p.Description))
 		}
@@ -81,7 +86,7 @@ func FormatPools(pools []Pool, poolMembers map[string][]string) string {
 func FormatNodes(nodes []Node) string {
 	var sb strings.Builder
 	sb.WriteString("\n=== Backend Nodes ===\n")
-	
+
 	if len(nodes) == 0 {
 		sb.WriteString("\nNo backend nodes are currently configured.\n")
 		return sb.String()
@@ -102,7 +107,7 @@ func FormatNodes(nodes []Node) string {
 func FormatWAFPolicies(policies []*WAFPolicy) string {
 	var sb strings.Builder
 	sb.WriteString("\n=== WAF (Web Application Firewall) Policies ===\n")
-	
+
 	if len(policies) == 0 {
 		sb.WriteString("\nNo WAF policies are currently configured on this BIG-IP system.\n")
 		sb.WriteString("\nNote: WAF policies protect web applications from:")
@@ -116,13 +121,13 @@ func FormatWAFPolicies(policies []*WAFPolicy) string {
 	}
 
 	sb.WriteString(fmt.Sprintf("\nFound %d WAF Policies:\n", len(policies)))
-	
+
 	for i, policy := range policies {
 		sb.WriteString(fmt.Sprintf("\n[%d] WAF Policy Details:\n", i+1))
 		sb.WriteString("----------------------------------------\n")
 		sb.WriteString(fmt.Sprintf("Name: %s\n", policy.Name))
 		sb.WriteString(fmt.Sprintf("Status: %s\n", map[bool]string{true: "Active", false: "Inactive"}[policy.Active]))
-		
+
 		// Display Virtual Server associations prominently
 		if len(policy.VirtualServers) > 0 {
 			sb.WriteString("\nApplied to Virtual Servers:\n")
@@ -133,7 +138,7 @@ func FormatWAFPolicies(policies []*WAFPolicy) string {
 		} else {
 			sb.WriteString("\nNot currently applied to any Virtual Servers\n\n")
 		}
-		
+
 		if policy.EnforcementMode != "" {
 			sb.WriteString(fmt.Sprintf("Enforcement Mode: %s\n", policy.EnforcementMode))
 			if policy.EnforcementMode == "blocking" {
@@ -142,27 +147,27 @@ func FormatWAFPolicies(policies []*WAFPolicy) string {
 				sb.WriteString("  (Monitoring mode - logging only)\n")
 			}
 		}
-		
+
 		if policy.Type != "" {
 			sb.WriteString(fmt.Sprintf("Type: %s\n", policy.Type))
 		}
-		
+
 		sb.WriteString(fmt.Sprintf("Signature Staging: %v\n", map[bool]string{
 			true:  "Enabled (New signatures in staging mode)",
 			false: "Disabled (All signatures in production)",
 		}[policy.SignatureStaging]))
-		
+
 		if len(policy.VirtualServers) > 0 {
 			sb.WriteString("\nAssociated Virtual Servers:\n")
 			for _, vs := range policy.VirtualServers {
 				sb.WriteString(fmt.Sprintf("- %s\n", vs))
 			}
 		}
-		
+
 		if policy.Description != "" {
 			sb.WriteString(fmt.Sprintf("\nDescription: %s\n", policy.Description))
 		}
-		
+
 		sb.WriteString("----------------------------------------\n")
 	}
 
@@ -179,31 +184,146 @@ func FormatWAFPolicyDetails(policy *bigip.WAFPolicy) string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("\n=== WAF Policy Details: %s ===\n", policy.Name))
 	sb.WriteString("----------------------------------------\n")
-	
+
 	sb.WriteString(fmt.Sprintf("Name: %s\n", policy.Name))
 	sb.WriteString(fmt.Sprintf("ID: %s\n", policy.ID))
 	sb.WriteString(fmt.Sprintf("Type: %s\n", policy.Type))
 	sb.WriteString(fmt.Sprintf("Status: %s\n", map[bool]string{true: "Active", false: "Inactive"}[policy.Active]))
 	sb.WriteString(fmt.Sprintf("Enforcement Mode: %s\n", policy.EnforcementMode))
-	
+
 	if policy.Description != "" {
 		sb.WriteString(fmt.Sprintf("Description: %s\n", policy.Description))
 	}
-	
+
 	if policy.SignatureStaging {
 		sb.WriteString("Signature Mode: Staging\n")
 	} else {
 		sb.WriteString("Signature Mode: Production\n")
 	}
-	
+
 	if len(policy.VirtualServers) > 0 {
 		sb.WriteString("\nAssociated Virtual Servers:\n")
 		for _, vs := range policy.VirtualServers {
 			sb.WriteString(fmt.Sprintf("- %s\n", vs))
 		}
 	}
-	
+
 	sb.WriteString("\nConfiguration Path: " + policy.FullPath + "\n")
-	
+
+	return sb.String()
+}
+func FormatHealthChecks(checks []HealthCheck) string {
+	return formatChecks("Health Check", checks, "All checks passed - the chat is ready to use.")
+}
+
+// FormatSelfTest reports the queries a self-test asked as checks
+func FormatSelfTest(checks []HealthCheck) string {
+	return formatChecks("Self-Test", checks, "All queries passed - the chat answers as it should.")
+}
+
+// formatChecks writes checks under a title, one [PASS] or [FAIL] line
+// each, ending with passed when all of them did
+func formatChecks(title string, checks []HealthCheck, passed string) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== " + title + " ===\n")
+	sb.WriteString("----------------------------------------\n")
+
+	width := 13
+	for _, c := range checks {
+		width = max(width, len(c.Name)+1)
+	}
+	failed := 0
+	for _, c := range checks {
+		status := "PASS"
+		if !c.Passed {
+			status = "FAIL"
+			failed++
+		}
+		sb.WriteString(fmt.Sprintf("[%s] %-*s %s\n", status, width, c.Name, c.Detail))
+	}
+	sb.WriteString("----------------------------------------\n")
+
+	if failed == 0 {
+		sb.WriteString(passed + "\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("%d of %d checks failed. Fix the first failure above; later checks often depend on it.\n", failed, len(checks)))
+	}
+	return sb.String()
+}
+
+// FormatConfigChecks reports what "config validate" found, laid out as
+// formatChecks lays out a health check, ending with what to do next
+func FormatConfigChecks(checks []config.Check) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== Configuration ===\n")
+	sb.WriteString("----------------------------------------\n")
+
+	width := 13
+	for _, c := range checks {
+		width = max(width, len(c.Name)+1)
+	}
+	failed, warned := 0, 0
+	for _, c := range checks {
+		switch c.Status {
+		case "FAIL":
+			failed++
+		case "WARN":
+			warned++
+		}
+		sb.WriteString(fmt.Sprintf("[%s] %-*s %s\n", c.Status, width, c.Name, c.Detail))
+	}
+	sb.WriteString("----------------------------------------\n")
+
+	switch {
+	case failed > 0:
+		sb.WriteString(fmt.Sprintf("%d of %d checks failed. Fix them before starting a session; each says how.\n", failed, len(checks)))
+	case warned > 0:
+		sb.WriteString(fmt.Sprintf("The configuration works, but %d checks have warnings worth a look.\n", warned))
+	default:
+		sb.WriteString("The configuration is valid - chatf5 is ready to start.\n")
+	}
+	return sb.String()
+}
+
+// FormatCompliance reports each compliance rule as a check, with the
+// objects that fail it and how to fix them, under the overall score
+func FormatCompliance(report compliance.Report) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== Compliance Report ===\n")
+	sb.WriteString("----------------------------------------\n")
+	sb.WriteString(fmt.Sprintf("Score: %d/100\n\n", report.Score))
+
+	width := 6
+	for _, r := range report.Results {
+		width = max(width, len(r.Rule.ID)+1)
+	}
+	for _, r := range report.Results {
+		detail := r.Rule.Title
+		switch r.Status() {
+		case "SKIP":
+			detail += fmt.Sprintf(" (no %s objects)", r.Rule.Object)
+		case "FAIL":
+			detail += fmt.Sprintf(" (%d of %d failed)", len(r.Findings), r.Checked)
+		default:
+			detail += fmt.Sprintf(" (%d checked)", r.Checked)
+		}
+		sb.WriteString(fmt.Sprintf("[%s] %-6s %-*s %s\n", r.Status(), r.Rule.Severity, width, r.Rule.ID, detail))
+		for _, f := range r.Findings {
+			sb.WriteString(fmt.Sprintf("       - %s: %s\n", f.Object, f.Reason))
+		}
+		if len(r.Findings) > 0 && r.Rule.Fix != "" {
+			sb.WriteString("       Fix: " + r.Rule.Fix + "\n")
+		}
+	}
+	sb.WriteString("----------------------------------------\n")
+
+	if len(report.Unavailable) > 0 {
+		sb.WriteString(fmt.Sprintf("Not checked, as the device doesn't have them: %s\n", strings.Join(report.Unavailable, ", ")))
+	}
+	if failed := report.Failed(); failed > 0 {
+		sb.WriteString(fmt.Sprintf("%d of %d rules failed. Fix the high-severity findings first; each rule says how.\n", failed, len(report.Results)))
+	} else {
+		sb.WriteString("The configuration follows every rule.\n")
+	}
 	return sb.String()
-}
\ No newline at end of file
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// SortKey is an object's place in a sorted listing: a count such as
+// connections or members, or otherwise text such as its name
+type SortKey struct {
+	Text    string
+	Number  int64
+	Numeric bool
+}
+
+// TextKey orders by text, ignoring case
+func TextKey(s string) SortKey { return SortKey{Text: strings.ToLower(s)} }
+
+// NumberKey orders by a count
+func NumberKey(n int64) SortKey { return SortKey{Number: n, Numeric: true} }
+
+// AddressKey orders by IP address, numerically rather than as text so
+// 10.0.0.9 comes before 10.0.0.10; anything else sorts after all addresses
+func AddressKey(address string) SortKey {
+	if ip := ParseAddress(address); ip != nil {
+		return SortKey{Text: string(ip.To16())}
+	}
+	return SortKey{Text: "\xff" + strings.ToLower(address)}
+}
+
+// ParseAddress reads the IP address in a BIG-IP address or destination,
+// ignoring a partition, route domain or port: /Common/10.0.0.1:443,
+// 10.0.0.1%2 or /Common/2001:db8::1.443. It is nil if there is none.
+func ParseAddress(address string) net.IP {
+	host := address[strings.LastIndex(address, "/")+1:]
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	} else if i := strings.LastIndexAny(host, ":."); i >= 0 && net.ParseIP(host) == nil {
+		host = host[:i]
+	}
+	return net.ParseIP(host)
+}
+
+// ParsePort reads the port of a BIG-IP destination: /Common/10.0.0.1:443
+// or /Common/2001:db8::1.443. It is "" if there is none.
+func ParsePort(destination string) string {
+	host := destination[strings.LastIndex(destination, "/")+1:]
+	i := strings.LastIndexAny(host, ":.")
+	if i < 0 || net.ParseIP(host) != nil {
+		return ""
+	}
+	return host[i+1:]
+}
+
+func (k SortKey) less(o SortKey) bool {
+	if k.Numeric {
+		return k.Number < o.Number
+	}
+	return k.Text < o.Text
+}
+
+// Sorted returns a copy of items ordered by key, leaving items as they are
+// since listings are shared through the response cache. Equal keys keep
+// their listing order.
+func Sorted[T any](items []T, desc bool, key func(T) SortKey) []T {
+	keys := make([]SortKey, len(items))
+	index := make([]int, len(items))
+	for n, item := range items {
+		keys[n], index[n] = key(item), n
+	}
+	sort.SliceStable(index, func(a, b int) bool {
+		if desc {
+			return keys[index[b]].less(keys[index[a]])
+		}
+		return keys[index[a]].less(keys[index[b]])
+	})
+	sorted := make([]T, len(items))
+	for n, i := range index {
+		sorted[n] = items[i]
+	}
+	return sorted
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ListingStream writes a compact listing a page at a time, as its pages are
+// read, so a long listing is shown without being held whole. Its columns
+// are as wide as the first page needs; a longer value on a later page
+// pushes the rest of its row over.
+type ListingStream struct {
+	write  func(string)
+	noun   string
+	widths []int
+	shown  int
+}
+
+// NewListingStream returns a listing that hands what it writes to write,
+// a page at a time
+func NewListingStream(write func(string)) *ListingStream {
+	return &ListingStream{write: write}
+}
+
+// VirtualServers writes a page of virtual servers, and how many of the
+// total have been written
+func (s *ListingStream) VirtualServers(page []VirtualServer, total int) {
+	s.page("Virtual Servers (VIPs)", "virtual servers", virtualServerColumns, virtualServerRows(page), total)
+}
+
+// Pools writes a page of pools with their member counts
+func (s *ListingStream) Pools(page []Pool, poolMembers map[string][]PoolMember, total int) {
+	s.page("Server Pools", "pools", poolColumns, poolRows(page, poolMembers), total)
+}
+
+// Nodes writes a page of nodes
+func (s *ListingStream) Nodes(page []Node, total int) {
+	s.page("Backend Nodes", "nodes", nodeColumns, nodeRows(page), total)
+}
+
+// Shown is how many objects have been written
+func (s *ListingStream) Shown() int {
+	return s.shown
+}
+
+// Done ends the listing, saying how many objects it had
+func (s *ListingStream) Done() string {
+	return fmt.Sprintf("Listed %s %s, a page at a time as they were read.\n%s", thousands(s.shown), s.noun, compactHint)
+}
+
+func (s *ListingStream) page(title, noun string, header []string, rows [][]string, total int) {
+	var sb strings.Builder
+	if s.widths == nil {
+		s.noun = noun
+		s.widths = make([]int, len(header))
+		for _, row := range append([][]string{header}, rows...) {
+			for n, cell := range row {
+				s.widths[n] = max(s.widths[n], utf8.RuneCountInString(cell))
+			}
+		}
+		fmt.Fprintf(&sb, "\n=== %s ===\n\n", title)
+		s.line(&sb, header)
+	}
+	for _, row := range rows {
+		s.line(&sb, row)
+	}
+	s.shown += len(rows)
+	fmt.Fprintf(&sb, "... %s of %s %s\n", thousands(s.shown), thousands(total), noun)
+	s.write(sb.String())
+}
+
+// line writes a row with each cell but the last padded to its column's
+// width, two spaces apart as compactTable's are
+func (s *ListingStream) line(sb *strings.Builder, row []string) {
+	for n, cell := range row {
+		if n == len(row)-1 {
+			sb.WriteString(cell)
+			break
+		}
+		sb.WriteString(cell)
+		sb.WriteString(strings.Repeat(" ", max(s.widths[n]-utf8.RuneCountInString(cell), 0)+2))
+	}
+	sb.WriteString("\n")
+}
+
+// thousands writes n with commas between each group of three digits
+func thousands(n int) string {
+	digits := strconv.Itoa(n)
+	for i := len(digits) - 3; i > 0 && digits[i-1] != '-'; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
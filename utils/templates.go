@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"f5chat/bigip"
+)
+
+// Output template names. Each is loaded from <name>.tmpl in the template
+// directory and replaces the built-in layout of that answer.
+const (
+	// TemplateVirtualServers is given []VirtualServer
+	TemplateVirtualServers = "virtual_servers"
+	// TemplatePools is given []PoolWithMembers
+	TemplatePools = "pools"
+	// TemplateNodes is given []Node
+	TemplateNodes = "nodes"
+	// TemplateWAFPolicies is given []*WAFPolicy
+	TemplateWAFPolicies = "waf_policies"
+	// TemplateWAFPolicy is given one *WAFPolicy, for its details
+	TemplateWAFPolicy = "waf_policy"
+)
+
+// templateNames are the templates that can be replaced
+var templateNames = []string{TemplateVirtualServers, TemplatePools, TemplateNodes, TemplateWAFPolicies, TemplateWAFPolicy}
+
+// templateFuncs are available to templates besides the text/template
+// built-ins
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	// pad left-aligns s in a column of width: {{pad 20 .Name}}
+	"pad": func(width int, s string) string { return fmt.Sprintf("%-*s", width, s) },
+}
+
+// PoolWithMembers is a pool as pool templates see it: the pool's fields,
+// such as .Name and .LoadBalancingMode, and .Members
+type PoolWithMembers struct {
+	Pool
+	Members []string
+}
+
+// PoolsWithMembers pairs each pool with its members for TemplatePools
+func PoolsWithMembers(pools []Pool, poolMembers map[string][]PoolMember) []PoolWithMembers {
+	out := make([]PoolWithMembers, len(pools))
+	for n, p := range pools {
+		out[n] = PoolWithMembers{Pool: p, Members: bigip.MemberPaths(poolMembers[p.FullPath])}
+	}
+	return out
+}
+
+// Templates are the user's output templates, by name
+type Templates struct {
+	templates map[string]*template.Template
+}
+
+// LoadTemplates parses the <name>.tmpl files in dir. An empty dir has no
+// templates, so every answer keeps its built-in layout.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{templates: make(map[string]*template.Template)}
+	if dir == "" {
+		return t, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory: %v", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".tmpl" {
+			continue
+		}
+		file := filepath.Join(dir, e.Name())
+		name := strings.TrimSuffix(e.Name(), ".tmpl")
+		if !knownTemplate(name) {
+			slog.Warn("Ignoring unknown template file", "file", file, "known", strings.Join(templateNames, ", "))
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %v", e.Name(), err)
+		}
+		parsed, err := template.New(e.Name()).Funcs(templateFuncs).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %v", file, err)
+		}
+		t.templates[name] = parsed
+		slog.Info("Loaded output template", "name", name, "file", file)
+	}
+	return t, nil
+}
+
+// Names returns the names of the templates loaded, sorted
+func (t *Templates) Names() []string {
+	if t == nil {
+		return nil
+	}
+	names := make([]string, 0, len(t.templates))
+	for name := range t.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render writes data with the template called name; ok is false when there
+// is no such template, so the built-in layout is used
+func (t *Templates) Render(name string, data interface{}) (out string, ok bool, err error) {
+	if t == nil || t.templates[name] == nil {
+		return "", false, nil
+	}
+	var buf bytes.Buffer
+	if err := t.templates[name].Execute(&buf, data); err != nil {
+		return "", true, fmt.Errorf("output template %s.tmpl failed: %v", name, err)
+	}
+	return buf.String(), true, nil
+}
+
+func knownTemplate(name string) bool {
+	for _, known := range templateNames {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
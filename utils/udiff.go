@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DiffLine is a line of a line-by-line comparison: Op is ' ' for a line
+// both sides have, '-' for one only the old side has and '+' for one only
+// the new side has
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+// maxDiffCells bounds the work DiffLines does; longer inputs are shown as
+// replaced outright
+const maxDiffCells = 4_000_000
+
+// DiffLines compares two texts line by line, keeping the longest run of
+// lines they have in common
+func DiffLines(old, new []string) []DiffLine {
+	n, m := len(old), len(new)
+	if n*m > maxDiffCells {
+		out := make([]DiffLine, 0, n+m)
+		for _, l := range old {
+			out = append(out, DiffLine{'-', l})
+		}
+		for _, l := range new {
+			out = append(out, DiffLine{'+', l})
+		}
+		return out
+	}
+	// common[a][b] is the longest common run of old[a:] and new[b:]
+	common := make([][]int, n+1)
+	for a := range common {
+		common[a] = make([]int, m+1)
+	}
+	for a := n - 1; a >= 0; a-- {
+		for b := m - 1; b >= 0; b-- {
+			if old[a] == new[b] {
+				common[a][b] = common[a+1][b+1] + 1
+			} else {
+				common[a][b] = max(common[a+1][b], common[a][b+1])
+			}
+		}
+	}
+	var out []DiffLine
+	a, b := 0, 0
+	for a < n && b < m {
+		switch {
+		case old[a] == new[b]:
+			out = append(out, DiffLine{' ', old[a]})
+			a++
+			b++
+		case common[a+1][b] >= common[a][b+1]:
+			out = append(out, DiffLine{'-', old[a]})
+			a++
+		default:
+			out = append(out, DiffLine{'+', new[b]})
+			b++
+		}
+	}
+	for ; a < n; a++ {
+		out = append(out, DiffLine{'-', old[a]})
+	}
+	for ; b < m; b++ {
+		out = append(out, DiffLine{'+', new[b]})
+	}
+	return out
+}
+
+// FormatUnified shows the differences between two texts as a unified diff,
+// with context unchanged lines around each change. It is empty when the
+// texts are the same.
+func FormatUnified(oldName, newName string, old, new []string, context int) string {
+	lines := DiffLines(old, new)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	changed := false
+	// oldLine and newLine number the lines before index n, from 1
+	oldLine, newLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for n, l := range lines {
+		oldLine[n+1], newLine[n+1] = oldLine[n], newLine[n]
+		if l.Op != '+' {
+			oldLine[n+1]++
+		}
+		if l.Op != '-' {
+			newLine[n+1]++
+		}
+	}
+	for start := 0; start < len(lines); {
+		if lines[start].Op == ' ' {
+			start++
+			continue
+		}
+		// A hunk runs from context lines before this change to context
+		// lines after the last change closer than 2*context+1 to the next
+		from, to := max(start-context, 0), start
+		for to < len(lines) {
+			if lines[to].Op != ' ' {
+				to++
+				continue
+			}
+			next := to
+			for next < len(lines) && lines[next].Op == ' ' {
+				next++
+			}
+			if next == len(lines) || next-to > 2*context {
+				break
+			}
+			to = next
+		}
+		end := min(to+context, len(lines))
+		oldCount, newCount := oldLine[end]-oldLine[from], newLine[end]-newLine[from]
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(oldLine[from], oldCount), hunkRange(newLine[from], newCount))
+		for _, l := range lines[from:end] {
+			sb.WriteByte(l.Op)
+			sb.WriteString(l.Text + "\n")
+		}
+		changed = true
+		start = end
+	}
+	if !changed {
+		return ""
+	}
+	return sb.String()
+}
+
+// FormatDiffLines writes diff lines one per line after indent, each marked
+// with its Op
+func FormatDiffLines(lines []DiffLine, indent string) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		sb.WriteString(indent + string(l.Op) + " " + l.Text + "\n")
+	}
+	return sb.String()
+}
+
+// hunkRange writes a hunk's start and length as diff does: an empty range
+// starts at the line before it
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// FieldLines writes an object's flattened fields (see Flatten) as
+// "field: value" lines sorted by field, for diffing objects as text
+func FieldLines(fields map[string]string) []string {
+	lines := make([]string, 0, len(fields))
+	for field, value := range fields {
+		lines = append(lines, field+": "+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// FormatSideBySide shows rows of three columns, such as a field and its
+// value on each side, aligned under a header. Values longer than width are
+// shortened and empty ones shown as "(not set)".
+func FormatSideBySide(header [3]string, rows [][3]string, width int) string {
+	cell := func(s string) string {
+		if s == "" {
+			return "(not set)"
+		}
+		if len(s) > width {
+			return s[:width-3] + "..."
+		}
+		return s
+	}
+	firstWidth, secondWidth := len(header[0]), len(header[1])
+	for _, r := range rows {
+		firstWidth = max(firstWidth, len(r[0]))
+		secondWidth = max(secondWidth, len(cell(r[1])))
+	}
+	secondWidth = min(secondWidth, width)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%-*s  %-*s  %s\n", firstWidth, header[0], secondWidth, cell(header[1]), cell(header[2])))
+	sb.WriteString(strings.Repeat("-", firstWidth+secondWidth+4+min(max(len(header[2]), 9), width)) + "\n")
+	for _, r := range rows {
+		sb.WriteString(fmt.Sprintf("%-*s  %-*s  %s\n", firstWidth, r[0], secondWidth, cell(r[1]), cell(r[2])))
+	}
+	return sb.String()
+}
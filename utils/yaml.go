@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// yamlNode is a value read from JSON with its keys kept in order, so YAML
+// shows fields in the order iControl REST and the structs have them
+type yamlNode struct {
+	// scalar is a string, json.Number, bool or nil when the node is neither
+	// a map nor a list
+	scalar interface{}
+	isMap  bool
+	isList bool
+	keys   []string
+	items  []*yamlNode
+}
+
+// yamlPlain is a string that can be written without quotes
+var yamlPlain = regexp.MustCompile(`^[A-Za-z0-9_./][A-Za-z0-9_./ :@()+,=-]*$`)
+
+// yamlReserved are plain words YAML would read as something other than a
+// string
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true, ".inf": true, ".nan": true,
+}
+
+// YAML writes a struct or map as a YAML document, via its JSON form so the
+// iControl REST property names and omitempty rules apply. Multi-line strings
+// are written as literal blocks.
+func YAML(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := readYAMLNode(dec)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	switch {
+	case node.isMap && len(node.keys) > 0, node.isList && len(node.items) > 0:
+		writeYAMLBlock(&sb, node, 0)
+	default:
+		sb.WriteString(yamlInline(node, 0) + "\n")
+	}
+	return sb.String(), nil
+}
+
+// readYAMLNode reads the next JSON value, keeping map keys in order
+func readYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		node := &yamlNode{isMap: t == '{', isList: t == '['}
+		for dec.More() {
+			if node.isMap {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, fmt.Sprint(key))
+			}
+			item, err := readYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, item)
+		}
+		// The closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	default:
+		return &yamlNode{scalar: t}, nil
+	}
+}
+
+// writeYAMLBlock writes a non-empty map or list at indent
+func writeYAMLBlock(sb *strings.Builder, node *yamlNode, indent int) {
+	pad := strings.Repeat("  ", indent)
+	for n, item := range node.items {
+		prefix := pad + "- "
+		if node.isMap {
+			prefix = pad + yamlKey(node.keys[n]) + ":"
+		}
+		switch {
+		case node.isList && item.isMap && len(item.items) > 0:
+			// The map's first field goes on the dash's line
+			var inner strings.Builder
+			writeYAMLBlock(&inner, item, indent+1)
+			sb.WriteString(prefix + strings.TrimPrefix(inner.String(), pad+"  "))
+		case item.isMap && len(item.items) > 0, item.isList && len(item.items) > 0:
+			sb.WriteString(strings.TrimRight(prefix, " ") + "\n")
+			writeYAMLBlock(sb, item, indent+1)
+		case node.isMap:
+			sb.WriteString(prefix + " " + yamlInline(item, indent+1) + "\n")
+		default:
+			sb.WriteString(prefix + yamlInline(item, indent+1) + "\n")
+		}
+	}
+}
+
+// yamlInline writes a scalar, empty map or empty list on its line; a
+// multi-line string continues as a literal block indented under it
+func yamlInline(node *yamlNode, indent int) string {
+	switch {
+	case node.isMap:
+		return "{}"
+	case node.isList:
+		return "[]"
+	}
+	switch s := node.scalar.(type) {
+	case nil:
+		return "null"
+	case string:
+		return YAMLString(s, indent)
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+// yamlKey writes a map key, quoted unless it's plain
+func yamlKey(key string) string {
+	if yamlPlain.MatchString(key) && !strings.Contains(key, ": ") && !yamlReserved[strings.ToLower(key)] {
+		return key
+	}
+	return jsonQuote(key)
+}
+
+// YAMLString writes a string plainly when YAML would read it back the same,
+// as a literal block when it has several lines, and quoted otherwise. indent
+// is the level, in steps of two spaces, the block's lines are indented to:
+// one more than the key the string is the value of.
+func YAMLString(s string, indent int) string {
+	if yamlPlain.MatchString(s) && !yamlReserved[strings.ToLower(s)] && !looksNumeric(s) &&
+		!strings.Contains(s, ": ") && !strings.HasSuffix(s, ":") && !strings.HasSuffix(s, " ") {
+		return s
+	}
+	if strings.Contains(s, "\n") && literalSafe(s) {
+		chomp := "-"
+		body := s
+		if strings.HasSuffix(s, "\n") {
+			chomp, body = "", strings.TrimSuffix(s, "\n")
+		}
+		indicator := ""
+		if strings.HasPrefix(body, " ") {
+			indicator = "2"
+		}
+		pad := strings.Repeat("  ", indent)
+		lines := strings.Split(body, "\n")
+		for n, line := range lines {
+			if line != "" {
+				lines[n] = pad + line
+			}
+		}
+		return "|" + indicator + chomp + "\n" + strings.Join(lines, "\n")
+	}
+	return jsonQuote(s)
+}
+
+// YAMLFlowString writes a string inside a flow sequence or mapping, such
+// as "[a, b]", where a comma ends a plain string and blocks can't go
+func YAMLFlowString(s string) string {
+	if YAMLString(s, 0) == s && !strings.Contains(s, ",") {
+		return s
+	}
+	return jsonQuote(s)
+}
+
+// YAMLFlowList writes strings as a flow sequence on one line
+func YAMLFlowList(items []string) string {
+	quoted := make([]string, len(items))
+	for n, item := range items {
+		quoted[n] = YAMLFlowString(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// literalSafe reports whether a multi-line string survives a literal block:
+// no characters YAML can't hold there, no trailing blank lines and no lines
+// that end in spaces only
+func literalSafe(s string) bool {
+	if strings.HasSuffix(s, "\n\n") {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' && r != '\n' && r != '\t' || r == 0x7f || r == '\ufeff' {
+			return false
+		}
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" && strings.TrimSpace(line) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// looksNumeric reports whether YAML would read a plain string as a number
+func looksNumeric(s string) bool {
+	var f float64
+	_, err := fmt.Sscanf(s, "%g", &f)
+	return err == nil && strings.Trim(s, "0123456789.eE+-_") == "" && strings.Count(s, ".") <= 1 ||
+		strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o")
+}
+
+// jsonQuote writes a double-quoted string; JSON's escapes are YAML's too
+func jsonQuote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}